@@ -0,0 +1,219 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"reflect"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// ChangeKind describes how a statement changed between two files.
+type ChangeKind int
+
+const (
+	Inserted ChangeKind = iota
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Inserted:
+		return "inserted"
+	case Removed:
+		return "removed"
+	default:
+		return "modified"
+	}
+}
+
+// Change represents a statement that was inserted, removed or modified.
+type Change struct {
+	Kind ChangeKind
+
+	// Old and New are the statements in each file. Old is nil for
+	// insertions and New is nil for removals.
+	Old, New *syntax.Stmt
+
+	// OldPos and NewPos are the positions of the statements in each
+	// file. If a statement is missing from one of the files, its
+	// position is where the statement would be.
+	OldPos, NewPos syntax.Position
+}
+
+// Diff reports the statements that differ structurally between two
+// files. Changes that only affect formatting or comments are ignored.
+//
+// Compound commands like blocks, loops or functions whose header is
+// unchanged are compared recursively, so that a single modified
+// statement within a long function body is reported as such instead of
+// as a modification of the entire function.
+func Diff(old, new *syntax.File) []Change {
+	d := differ{old: old, new: new}
+	d.stmts(old.Stmts, new.Stmts, 1, 1)
+	return d.changes
+}
+
+type differ struct {
+	old, new *syntax.File
+	changes  []Change
+}
+
+func (d *differ) add(kind ChangeKind, os, ns *syntax.Stmt, opos, npos syntax.Pos) {
+	d.changes = append(d.changes, Change{
+		Kind:   kind,
+		Old:    os,
+		New:    ns,
+		OldPos: d.old.Position(opos),
+		NewPos: d.new.Position(npos),
+	})
+}
+
+// anchor returns the position where the i-th statement of a list is or
+// would be.
+func anchor(list []*syntax.Stmt, i int, parent syntax.Pos) syntax.Pos {
+	switch {
+	case i < len(list):
+		return list[i].Pos()
+	case len(list) > 0:
+		return list[len(list)-1].End()
+	}
+	return parent
+}
+
+func (d *differ) stmts(olds, news []*syntax.Stmt, opos, npos syntax.Pos) {
+	oh := make([]uint64, len(olds))
+	for i, s := range olds {
+		oh[i] = syntax.Hash(s)
+	}
+	nh := make([]uint64, len(news))
+	for i, s := range news {
+		nh[i] = syntax.Hash(s)
+	}
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oh[i:] and nh[j:]
+	lcs := make([][]int, len(oh)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(nh)+1)
+	}
+	for i := len(oh) - 1; i >= 0; i-- {
+		for j := len(nh) - 1; j >= 0; j-- {
+			switch {
+			case oh[i] == nh[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	gapI, gapJ := 0, 0
+	for i < len(oh) || j < len(nh) {
+		switch {
+		case i < len(oh) && j < len(nh) && oh[i] == nh[j]:
+			d.gap(olds, news, gapI, i, gapJ, j, opos, npos)
+			i++
+			j++
+			gapI, gapJ = i, j
+		case j == len(nh) || (i < len(oh) && lcs[i+1][j] >= lcs[i][j+1]):
+			i++
+		default:
+			j++
+		}
+	}
+	d.gap(olds, news, gapI, i, gapJ, j, opos, npos)
+}
+
+// gap reports the changes between olds[i0:i1] and news[j0:j1], none of
+// which have a structurally equal counterpart.
+func (d *differ) gap(olds, news []*syntax.Stmt, i0, i1, j0, j1 int, opos, npos syntax.Pos) {
+	i, j := i0, j0
+	for i < i1 && j < j1 && sameKind(olds[i], news[j]) {
+		d.modified(olds[i], news[j])
+		i++
+		j++
+	}
+	for ; i < i1; i++ {
+		d.add(Removed, olds[i], nil, olds[i].Pos(), anchor(news, j1, npos))
+	}
+	for ; j < j1; j++ {
+		d.add(Inserted, nil, news[j], anchor(olds, i1, opos), news[j].Pos())
+	}
+}
+
+// sameKind reports whether two statements have the same type of
+// command, in which case they are considered a modification of one
+// another rather than an unrelated removal and insertion.
+func sameKind(os, ns *syntax.Stmt) bool {
+	return reflect.TypeOf(os.Cmd) == reflect.TypeOf(ns.Cmd)
+}
+
+func (d *differ) modified(os, ns *syntax.Stmt) {
+	oh, obodies := splitStmt(os)
+	nh, nbodies := splitStmt(ns)
+	if obodies == nil || len(obodies) != len(nbodies) || syntax.Hash(oh) != syntax.Hash(nh) {
+		d.add(Modified, os, ns, os.Pos(), ns.Pos())
+		return
+	}
+	for k := range obodies {
+		d.stmts(obodies[k], nbodies[k], os.Pos(), ns.Pos())
+	}
+}
+
+// splitStmt returns a copy of a statement without the statement lists
+// nested in its command, along with those lists.
+func splitStmt(s *syntax.Stmt) (*syntax.Stmt, [][]*syntax.Stmt) {
+	header, bodies := splitCompound(s.Cmd)
+	if header == nil {
+		return s, nil
+	}
+	cp := *s
+	cp.Cmd = header
+	return &cp, bodies
+}
+
+// splitCompound returns a copy of a compound command without its nested
+// statement lists, along with those lists. It returns nil for commands
+// that aren't compound.
+func splitCompound(cmd syntax.Command) (syntax.Command, [][]*syntax.Stmt) {
+	switch x := cmd.(type) {
+	case *syntax.Block:
+		return &syntax.Block{}, [][]*syntax.Stmt{x.Stmts}
+	case *syntax.Subshell:
+		return &syntax.Subshell{}, [][]*syntax.Stmt{x.Stmts}
+	case *syntax.IfClause:
+		bodies := [][]*syntax.Stmt{x.CondStmts, x.ThenStmts}
+		for _, elif := range x.Elifs {
+			bodies = append(bodies, elif.CondStmts, elif.ThenStmts)
+		}
+		bodies = append(bodies, x.ElseStmts)
+		return &syntax.IfClause{Elifs: make([]*syntax.Elif, len(x.Elifs))}, bodies
+	case *syntax.WhileClause:
+		return &syntax.WhileClause{}, [][]*syntax.Stmt{x.CondStmts, x.DoStmts}
+	case *syntax.UntilClause:
+		return &syntax.UntilClause{}, [][]*syntax.Stmt{x.CondStmts, x.DoStmts}
+	case *syntax.ForClause:
+		return &syntax.ForClause{Loop: x.Loop}, [][]*syntax.Stmt{x.DoStmts}
+	case *syntax.FuncDecl:
+		return &syntax.FuncDecl{
+			BashStyle: x.BashStyle,
+			Name:      x.Name,
+		}, [][]*syntax.Stmt{{x.Body}}
+	case *syntax.CaseClause:
+		cc := &syntax.CaseClause{Word: x.Word}
+		var bodies [][]*syntax.Stmt
+		for _, pl := range x.List {
+			cc.List = append(cc.List, &syntax.PatternList{
+				Op:       pl.Op,
+				Patterns: pl.Patterns,
+			})
+			bodies = append(bodies, pl.Stmts)
+		}
+		return cc, bodies
+	}
+	return nil, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		old, new string
+		want     []string
+	}{
+		{"foo\nbar", "foo\n\n  bar # comment", nil},
+		{"foo", "foo\nbar", []string{"inserted 1:4 2:1"}},
+		{"foo\nbar", "bar", []string{"removed 1:1 1:1"}},
+		{"foo a", "foo b", []string{"modified 1:1 1:1"}},
+		{"foo", "a=b", []string{"removed 1:1 1:4", "inserted 1:4 1:1"}},
+		{
+			"a\nb\nc",
+			"a\nx\nc\nd",
+			[]string{"modified 2:1 2:1", "inserted 3:2 4:1"},
+		},
+		{
+			"f() {\n\ta\n\tb\n}",
+			"f() {\n\ta\n\tc\n\td\n}",
+			[]string{"modified 3:2 3:2", "inserted 3:3 4:2"},
+		},
+		{
+			"f() { a; }",
+			"g() { a; }",
+			[]string{"modified 1:1 1:1"},
+		},
+		{
+			"if a; then b; else c; fi",
+			"if a; then b; else d; fi",
+			[]string{"modified 1:20 1:20"},
+		},
+		{
+			"for i in 1 2; do a; done",
+			"for i in 1 3; do a; done",
+			[]string{"modified 1:1 1:1"},
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var got []string
+			for _, c := range Diff(parse(t, tc.old), parse(t, tc.new)) {
+				got = append(got, fmt.Sprintf("%s %d:%d %d:%d", c.Kind,
+					c.OldPos.Line, c.OldPos.Column,
+					c.NewPos.Line, c.NewPos.Column))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Diff mismatch in %q -> %q\nwant: %q\ngot:  %q",
+					tc.old, tc.new, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// DefaultMarkers are the markers that are looked for if
+// DirectiveConfig.Markers is nil.
+var DefaultMarkers = []string{"TODO", "FIXME", "XXX", "shellcheck", "shfmt"}
+
+// DirectiveConfig controls which comments are considered directives.
+type DirectiveConfig struct {
+	// Markers are the words that a comment must start with. They
+	// may be followed by a colon, a space or an opening
+	// parenthesis, like in "TODO: foo", "shellcheck disable=SC2086"
+	// or "TODO(user) foo". If nil, DefaultMarkers is used.
+	Markers []string
+}
+
+// Directive is a comment that starts with a marker.
+type Directive struct {
+	Marker  string
+	Comment *syntax.Comment
+
+	// Text is what follows the marker, without a leading colon nor
+	// surrounding whitespace. For example, "disable=SC2086" or
+	// "off".
+	Text string
+
+	// Stmt is the statement the directive applies to. It is either
+	// the statement on the same line that precedes the comment, or
+	// the statement that follows the comment. It is nil if there are
+	// no statements after the comment.
+	Stmt *syntax.Stmt
+}
+
+// Directives returns the directives in a file with the default
+// configuration.
+func Directives(f *syntax.File) []*Directive {
+	return DirectiveConfig{}.Directives(f)
+}
+
+// Directives returns the directives in a file, in the order they
+// appear. The file must have been parsed with syntax.ParseComments.
+func (c DirectiveConfig) Directives(f *syntax.File) []*Directive {
+	markers := c.Markers
+	if markers == nil {
+		markers = DefaultMarkers
+	}
+	var stmts []*syntax.Stmt
+	syntax.Walk(stmtCollector{&stmts}, f)
+	var dirs []*Directive
+	for _, cm := range f.Comments {
+		text := strings.TrimSpace(cm.Text)
+		for _, m := range markers {
+			rest, ok := afterMarker(text, m)
+			if !ok {
+				continue
+			}
+			dirs = append(dirs, &Directive{
+				Marker:  m,
+				Comment: cm,
+				Text:    rest,
+				Stmt:    owner(f, stmts, cm),
+			})
+			break
+		}
+	}
+	return dirs
+}
+
+func afterMarker(text, marker string) (string, bool) {
+	if !strings.HasPrefix(text, marker) {
+		return "", false
+	}
+	rest := text[len(marker):]
+	if rest != "" && !strings.ContainsAny(rest[:1], ": \t(") {
+		return "", false
+	}
+	rest = strings.TrimPrefix(rest, ":")
+	return strings.TrimSpace(rest), true
+}
+
+// owner returns the statement that a comment applies to.
+func owner(f *syntax.File, stmts []*syntax.Stmt, cm *syntax.Comment) *syntax.Stmt {
+	line := f.Position(cm.Pos()).Line
+	var inline, next *syntax.Stmt
+	for _, s := range stmts {
+		switch {
+		case s.End() <= cm.Pos() && f.Position(s.End()-1).Line == line:
+			// the innermost statement starts last
+			if inline == nil || s.Pos() >= inline.Pos() {
+				inline = s
+			}
+		case s.Pos() > cm.Pos():
+			if next == nil || s.Pos() < next.Pos() {
+				next = s
+			}
+		}
+	}
+	if inline != nil {
+		return inline
+	}
+	return next
+}
+
+type stmtCollector struct {
+	stmts *[]*syntax.Stmt
+}
+
+func (v stmtCollector) Visit(node syntax.Node) syntax.Visitor {
+	if s, ok := node.(*syntax.Stmt); ok {
+		*v.stmts = append(*v.stmts, s)
+	}
+	return v
+}
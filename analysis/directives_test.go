@@ -0,0 +1,51 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestDirectives(t *testing.T) {
+	t.Parallel()
+	src := `# shellcheck disable=SC2086
+echo $foo
+bar # TODO: remove bar
+# TODOS are not directives
+if true; then
+	# FIXME(user) handle errors
+	baz
+fi
+# shfmt: off
+`
+	f := parse(t, src)
+	var got []string
+	for _, d := range Directives(f) {
+		stmt := "<nil>"
+		if d.Stmt != nil {
+			var buf bytes.Buffer
+			syntax.Fprint(&buf, &syntax.File{Stmts: []*syntax.Stmt{d.Stmt}})
+			stmt = string(bytes.TrimSpace(buf.Bytes()))
+		}
+		got = append(got, fmt.Sprintf("%s|%s|%s", d.Marker, d.Text, stmt))
+	}
+	want := []string{
+		"shellcheck|disable=SC2086|echo $foo",
+		"TODO|remove bar|bar",
+		"FIXME|(user) handle errors|baz",
+		"shfmt|off|<nil>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Directives mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	dirs := DirectiveConfig{Markers: []string{"TODOS"}}.Directives(f)
+	if len(dirs) != 1 || dirs[0].Text != "are not directives" {
+		t.Fatalf("unexpected custom directives: %v", dirs)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// FuncDoc is the documentation of a function, taken from the block of
+// comments that directly precedes its declaration.
+type FuncDoc struct {
+	Func *Func
+
+	// Comments are the comments that make up the documentation.
+	Comments []*syntax.Comment
+
+	// Text is the text of the comments without the leading hashes,
+	// one line per comment.
+	Text string
+
+	// Summary is the first paragraph of the text if it isn't part
+	// of a section like "Usage:".
+	Summary string
+
+	// Usage is the text of the "Usage:" section.
+	Usage string
+
+	// Args are the entries of the "Args:" or "Arguments:" section.
+	Args []DocArg
+}
+
+// DocArg documents a single function argument.
+type DocArg struct {
+	Name string
+	Desc string
+}
+
+// Docs returns the documentation of each function declared in a file,
+// in the order they are declared. Functions without a preceding comment
+// block are omitted. The file must have been parsed with
+// syntax.ParseComments.
+//
+// Lines like "Usage: foo [-v] file" and sections like the following
+// are recognised:
+//
+//	# Args:
+//	#   $1 - the file to read
+//	#   verbose: whether to print progress
+func Docs(f *syntax.File) []*FuncDoc {
+	// lines where a statement ends, to tell apart the comments that
+	// follow code on the same line
+	ends := make(map[int]bool)
+	syntax.Walk(endVisitor{f, ends}, f)
+	var docs []*FuncDoc
+	for _, fn := range Funcs(f).Funcs {
+		line := f.Position(fn.Pos()).Line
+		var block []*syntax.Comment
+		for i := len(f.Comments) - 1; i >= 0; i-- {
+			c := f.Comments[i]
+			cline := f.Position(c.Pos()).Line
+			if cline >= line {
+				continue
+			}
+			if cline != line-1-len(block) || ends[cline] ||
+				(cline == 1 && strings.HasPrefix(c.Text, "!")) {
+				break
+			}
+			block = append(block, c)
+		}
+		if len(block) == 0 {
+			continue
+		}
+		for i, j := 0, len(block)-1; i < j; i, j = i+1, j-1 {
+			block[i], block[j] = block[j], block[i]
+		}
+		docs = append(docs, parseDoc(fn, block))
+	}
+	return docs
+}
+
+type endVisitor struct {
+	f    *syntax.File
+	ends map[int]bool
+}
+
+func (v endVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if s, ok := node.(*syntax.Stmt); ok {
+		// the statement may end at the newline that follows it
+		v.ends[v.f.Position(s.End()-1).Line] = true
+	}
+	return v
+}
+
+func parseDoc(fn *Func, block []*syntax.Comment) *FuncDoc {
+	doc := &FuncDoc{Func: fn, Comments: block}
+	lines := make([]string, len(block))
+	for i, c := range block {
+		s := c.Text
+		if strings.HasPrefix(s, " ") {
+			s = s[1:]
+		}
+		lines[i] = strings.TrimRight(s, " \t")
+	}
+	doc.Text = strings.Join(lines, "\n")
+	var summary []string
+	section := ""
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		switch lower := strings.ToLower(trimmed); {
+		case trimmed == "":
+			section = "end"
+			continue
+		case strings.HasPrefix(lower, "usage:"):
+			section = "usage"
+			doc.Usage = strings.TrimSpace(trimmed[len("usage:"):])
+			continue
+		case lower == "args:" || lower == "arguments:":
+			section = "args"
+			continue
+		}
+		switch {
+		case section == "" && i == len(summary):
+			summary = append(summary, trimmed)
+		case section == "usage" && trimmed != l:
+			if doc.Usage != "" {
+				doc.Usage += "\n"
+			}
+			doc.Usage += trimmed
+		case section == "args" && (trimmed != l || trimmed[0] == '$'):
+			if arg, ok := parseArg(trimmed); ok {
+				doc.Args = append(doc.Args, arg)
+			} else if n := len(doc.Args); n > 0 {
+				// continuation of the previous description
+				doc.Args[n-1].Desc += " " + trimmed
+			}
+		default:
+			section = "end"
+		}
+	}
+	doc.Summary = strings.Join(summary, "\n")
+	return doc
+}
+
+// parseArg parses lines like "$1 - desc", "name: desc" or
+// "name - desc".
+func parseArg(s string) (DocArg, bool) {
+	name, desc := s, ""
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		name, desc = s[:i], strings.TrimSpace(s[i:])
+	}
+	switch {
+	case strings.HasSuffix(name, ":"):
+		name = name[:len(name)-1]
+	case strings.HasPrefix(desc, "- "):
+		desc = strings.TrimSpace(desc[2:])
+	case strings.HasPrefix(name, "$"):
+	default:
+		return DocArg{}, false
+	}
+	param := strings.TrimPrefix(name, "$")
+	param = strings.TrimSuffix(strings.TrimPrefix(param, "{"), "}")
+	if !ValidName(param) && (param == "" || strings.Trim(param, "0123456789@*") != "") {
+		return DocArg{}, false
+	}
+	return DocArg{Name: name, Desc: desc}, true
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	t.Parallel()
+	src := `#!/bin/sh
+# Library header.
+
+# Copy a file somewhere.
+# It is very fast.
+#
+# Usage: copy [-v] src dst
+# Args:
+#   $1 - the source file
+#   dst: the destination, which
+#     may be a directory
+#   $@ - everything else
+copy() {
+	cp "$@" # inline
+}
+nodoc() { :; }
+foo # not a doc
+bar() { :; }
+
+# Say hi.
+function hi {
+	# Inner greeting.
+	inner() { echo hi; }
+}
+`
+	docs := Docs(parse(t, src))
+	var names []string
+	for _, d := range docs {
+		names = append(names, d.Func.Name)
+	}
+	if want := []string{"copy", "hi", "inner"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Docs mismatch\nwant: %q\ngot:  %q", want, names)
+	}
+	d := docs[0]
+	if want := "Copy a file somewhere.\nIt is very fast."; d.Summary != want {
+		t.Errorf("Summary mismatch\nwant: %q\ngot:  %q", want, d.Summary)
+	}
+	if want := "copy [-v] src dst"; d.Usage != want {
+		t.Errorf("Usage mismatch\nwant: %q\ngot:  %q", want, d.Usage)
+	}
+	wantArgs := []DocArg{
+		{"$1", "the source file"},
+		{"dst", "the destination, which may be a directory"},
+		{"$@", "everything else"},
+	}
+	if !reflect.DeepEqual(d.Args, wantArgs) {
+		t.Errorf("Args mismatch\nwant: %q\ngot:  %q", wantArgs, d.Args)
+	}
+	if len(d.Comments) != 9 {
+		t.Errorf("wanted 9 comments, got %d", len(d.Comments))
+	}
+	if want := "Say hi."; docs[1].Text != want {
+		t.Errorf("Text mismatch\nwant: %q\ngot:  %q", want, docs[1].Text)
+	}
+}
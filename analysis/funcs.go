@@ -0,0 +1,203 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package analysis implements static analyses over shell programs.
+package analysis
+
+import (
+	"sort"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Func represents a function declaration.
+type Func struct {
+	Name string
+	Decl *syntax.FuncDecl
+
+	// Parent is the function whose body contains this declaration,
+	// if any.
+	Parent *Func
+}
+
+// Pos returns the position of the function declaration.
+func (f *Func) Pos() syntax.Pos { return f.Decl.Pos() }
+
+// Body returns the body of the function.
+func (f *Func) Body() *syntax.Stmt { return f.Decl.Body }
+
+// CallKind describes what a call site refers to.
+type CallKind int
+
+const (
+	// ExternalCall is a call to a command that is neither a builtin
+	// nor a function declared in the file.
+	ExternalCall CallKind = iota
+	// BuiltinCall is a call to a shell builtin.
+	BuiltinCall
+	// FuncCall is a call to a function declared in the file.
+	FuncCall
+	// UndefinedCall is a call to a function that is declared in the
+	// file, but only after the call is executed.
+	UndefinedCall
+)
+
+func (k CallKind) String() string {
+	switch k {
+	case BuiltinCall:
+		return "builtin"
+	case FuncCall:
+		return "func"
+	case UndefinedCall:
+		return "undefined"
+	default:
+		return "external"
+	}
+}
+
+// Call represents a call site, that is, a simple command whose name is
+// a literal.
+type Call struct {
+	Name string
+	Stmt *syntax.Stmt
+	Expr *syntax.CallExpr
+	Kind CallKind
+
+	// Func is the function declaration the call resolves to, if any.
+	Func *Func
+	// Caller is the function whose body contains the call, if any.
+	Caller *Func
+}
+
+// FuncTable holds the functions declared in a file and the call sites
+// found in it.
+type FuncTable struct {
+	Funcs []*Func
+	Calls []*Call
+
+	byName map[string][]*Func
+}
+
+// Lookup returns all the declarations of a function, in source order.
+// It returns nil if no function has the given name.
+func (t *FuncTable) Lookup(name string) []*Func {
+	return t.byName[name]
+}
+
+// CallsTo returns the call sites that resolve to the given function.
+func (t *FuncTable) CallsTo(fn *Func) []*Call {
+	var calls []*Call
+	for _, c := range t.Calls {
+		if c.Func == fn {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Funcs collects all function declarations and call sites in a file,
+// resolving each call site to the declaration that it would use.
+//
+// Calls executed at the top level resolve to the last declaration that
+// precedes them. Calls inside function bodies run at an unknown time, so
+// they resolve to the last declaration preceding them or, failing that,
+// to the first one in the file.
+func Funcs(f *syntax.File) *FuncTable {
+	t := &FuncTable{byName: make(map[string][]*Func)}
+	syntax.Walk(funcVisitor{t: t}, f)
+	for _, c := range t.Calls {
+		t.resolve(c)
+	}
+	return t
+}
+
+func (t *FuncTable) resolve(c *Call) {
+	pos := c.Expr.Pos()
+	fns := t.byName[c.Name]
+	for _, fn := range fns {
+		if fn.Pos() < pos {
+			c.Func = fn
+		}
+	}
+	switch {
+	case c.Func != nil:
+		c.Kind = FuncCall
+	case len(fns) > 0:
+		c.Func = fns[0]
+		if c.Caller == nil {
+			c.Kind = UndefinedCall
+		} else {
+			c.Kind = FuncCall
+		}
+	case IsBuiltin(c.Name):
+		c.Kind = BuiltinCall
+	}
+}
+
+type funcVisitor struct {
+	t  *FuncTable
+	fn *Func
+}
+
+func (v funcVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.FuncDecl:
+		fn := &Func{Name: x.Name.Value, Decl: x, Parent: v.fn}
+		v.t.Funcs = append(v.t.Funcs, fn)
+		v.t.byName[fn.Name] = append(v.t.byName[fn.Name], fn)
+		return funcVisitor{t: v.t, fn: fn}
+	case *syntax.Stmt:
+		ce, ok := x.Cmd.(*syntax.CallExpr)
+		if !ok {
+			break
+		}
+		if name, ok := callName(ce); ok {
+			v.t.Calls = append(v.t.Calls, &Call{
+				Name:   name,
+				Stmt:   x,
+				Expr:   ce,
+				Caller: v.fn,
+			})
+		}
+	}
+	return v
+}
+
+// callName returns the name of the command being called, if it is
+// static.
+func callName(ce *syntax.CallExpr) (string, bool) {
+	name, ok := syntax.StaticValue(ce.Args[0])
+	return name, ok && name != ""
+}
+
+var builtins = map[string]bool{
+	".": true, ":": true, "[": true, "alias": true, "bg": true,
+	"bind": true, "break": true, "builtin": true, "caller": true,
+	"cd": true, "command": true, "compgen": true, "complete": true,
+	"compopt": true, "continue": true, "declare": true, "dirs": true,
+	"disown": true, "echo": true, "enable": true, "eval": true,
+	"exec": true, "exit": true, "export": true, "false": true,
+	"fc": true, "fg": true, "getopts": true, "hash": true,
+	"help": true, "history": true, "jobs": true, "kill": true,
+	"let": true, "local": true, "logout": true, "mapfile": true,
+	"popd": true, "printf": true, "pushd": true, "pwd": true,
+	"read": true, "readarray": true, "readonly": true,
+	"return": true, "set": true, "shift": true, "shopt": true,
+	"source": true, "suspend": true, "test": true, "times": true,
+	"trap": true, "true": true, "type": true, "typeset": true,
+	"ulimit": true, "umask": true, "unalias": true, "unset": true,
+	"wait": true,
+}
+
+// IsBuiltin reports whether a command name is a Bash builtin.
+func IsBuiltin(name string) bool { return builtins[name] }
+
+// Builtins returns the names of the Bash builtins, sorted.
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func parse(tb testing.TB, src string) *syntax.File {
+	f, err := syntax.Parse([]byte(src), "", syntax.ParseComments)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return f
+}
+
+func TestFuncs(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in    string
+		funcs []string
+		calls []string
+	}{
+		{"foo", nil, []string{"foo:external"}},
+		{"echo; cd", nil, []string{"echo:builtin", "cd:builtin"}},
+		{
+			"foo() { bar; }; foo",
+			[]string{"foo"},
+			[]string{"bar:external", "foo:func"},
+		},
+		{
+			"foo; foo() { :; }",
+			[]string{"foo"},
+			[]string{"foo:undefined", "::builtin"},
+		},
+		{
+			"a() { b; }; b() { a; }; a",
+			[]string{"a", "b"},
+			[]string{"b:func", "a:func", "a:func"},
+		},
+		{
+			"function outer { inner() { :; }; }",
+			[]string{"outer", "inner"},
+			[]string{"::builtin"},
+		},
+		{"$cmd foo; \"quoted\" bar", nil, []string{"quoted:external"}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			table := Funcs(parse(t, tc.in))
+			var funcs, calls []string
+			for _, fn := range table.Funcs {
+				funcs = append(funcs, fn.Name)
+			}
+			for _, c := range table.Calls {
+				calls = append(calls, fmt.Sprintf("%s:%s", c.Name, c.Kind))
+			}
+			if !reflect.DeepEqual(funcs, tc.funcs) {
+				t.Fatalf("Funcs mismatch\nwant: %q\ngot:  %q", tc.funcs, funcs)
+			}
+			if !reflect.DeepEqual(calls, tc.calls) {
+				t.Fatalf("Calls mismatch\nwant: %q\ngot:  %q", tc.calls, calls)
+			}
+		})
+	}
+}
+
+func TestFuncsResolve(t *testing.T) {
+	t.Parallel()
+	table := Funcs(parse(t, "f() { a; }\nf\nf() { b; }\nf"))
+	fns := table.Lookup("f")
+	if len(fns) != 2 {
+		t.Fatalf("wanted 2 declarations of f, got %d", len(fns))
+	}
+	for _, fn := range fns {
+		if calls := table.CallsTo(fn); len(calls) != 1 {
+			t.Fatalf("wanted 1 call to f at %d, got %d", fn.Pos(), len(calls))
+		}
+	}
+	if inner := Funcs(parse(t, "a() { b() { :; }; }")).Lookup("b"); inner[0].Parent == nil {
+		t.Fatalf("nested function has no parent")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"github.com/mvdan/sh/syntax"
+)
+
+// Metrics are measures of the size and complexity of some code.
+type Metrics struct {
+	// Complexity is the cyclomatic complexity, that is, one plus the
+	// number of decisions like if, elif, loops, case items, && and
+	// ||.
+	Complexity int
+
+	// Depth is the deepest nesting of if, case and loops.
+	Depth int
+
+	// Stmts is the number of statements, including those within
+	// compound commands and pipelines.
+	Stmts int
+
+	// Pipeline is the number of commands in the longest pipeline.
+	Pipeline int
+}
+
+// FuncMetrics are the metrics of a function's body.
+type FuncMetrics struct {
+	Func *Func
+	Metrics
+}
+
+// Measure returns the metrics of a node. The bodies of the functions
+// declared within it are left out, as they are measured separately.
+func Measure(node syntax.Node) Metrics {
+	m := &metricsVisitor{m: Metrics{Complexity: 1}}
+	if fd, ok := node.(*syntax.FuncDecl); ok {
+		node = fd.Body
+	}
+	syntax.Walk(m, node)
+	return m.m
+}
+
+// MeasureFuncs returns the metrics of the top level of a file, and of
+// each of its functions in the order they are declared.
+func MeasureFuncs(f *syntax.File, t *FuncTable) (Metrics, []FuncMetrics) {
+	fms := make([]FuncMetrics, len(t.Funcs))
+	for i, fn := range t.Funcs {
+		fms[i] = FuncMetrics{Func: fn, Metrics: Measure(fn.Decl)}
+	}
+	return Measure(f), fms
+}
+
+type metricsVisitor struct {
+	m     Metrics
+	depth int
+
+	// nests are the nodes that increased the depth, so that it can
+	// be decreased after their children
+	nests []syntax.Node
+}
+
+func (v *metricsVisitor) nest(node syntax.Node) {
+	v.nests = append(v.nests, node)
+	v.depth++
+	if v.depth > v.m.Depth {
+		v.m.Depth = v.depth
+	}
+}
+
+func (v *metricsVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node == nil {
+		return nil
+	}
+	switch x := node.(type) {
+	case *syntax.FuncDecl:
+		return nil
+	case *syntax.Stmt:
+		v.m.Stmts++
+		if n := pipeLen(x); n > v.m.Pipeline {
+			v.m.Pipeline = n
+		}
+	case *syntax.IfClause:
+		v.m.Complexity += 1 + len(x.Elifs)
+		v.nest(x)
+	case *syntax.WhileClause, *syntax.UntilClause, *syntax.ForClause:
+		v.m.Complexity++
+		v.nest(x)
+	case *syntax.CaseClause:
+		v.m.Complexity += len(x.List)
+		v.nest(x)
+	case *syntax.BinaryCmd:
+		if x.Op == syntax.AndStmt || x.Op == syntax.OrStmt {
+			v.m.Complexity++
+		}
+	case *syntax.BinaryTest:
+		if x.Op == syntax.AndTest || x.Op == syntax.OrTest {
+			v.m.Complexity++
+		}
+	}
+	return nestVisitor{v, node}
+}
+
+// nestVisitor visits the children of a node, and decreases the depth
+// after them if the node increased it.
+type nestVisitor struct {
+	v    *metricsVisitor
+	node syntax.Node
+}
+
+func (n nestVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node != nil {
+		return n.v.Visit(node)
+	}
+	if last := len(n.v.nests) - 1; last >= 0 && n.v.nests[last] == n.node {
+		n.v.nests = n.v.nests[:last]
+		n.v.depth--
+	}
+	return nil
+}
+
+// pipeLen returns the number of commands in the pipeline that a
+// statement holds, or 1 if it isn't one.
+func pipeLen(s *syntax.Stmt) int {
+	n := 1
+	for {
+		bc, ok := s.Cmd.(*syntax.BinaryCmd)
+		if !ok || (bc.Op != syntax.Pipe && bc.Op != syntax.PipeAll) {
+			return n
+		}
+		n++
+		s = bc.Y
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want Metrics
+	}{
+		{"", Metrics{Complexity: 1}},
+		{"foo; bar", Metrics{Complexity: 1, Stmts: 2, Pipeline: 1}},
+		{"a && b || c", Metrics{Complexity: 3, Stmts: 5, Pipeline: 1}},
+		{"if a; then b; elif c; then d; else e; fi", Metrics{Complexity: 3, Depth: 1, Stmts: 6, Pipeline: 1}},
+		{"for i in 1; do while a; do if [[ x && y ]]; then :; fi; done; done",
+			Metrics{Complexity: 5, Depth: 3, Stmts: 6, Pipeline: 1}},
+		{"if a; then :; fi; if b; then :; fi", Metrics{Complexity: 3, Depth: 1, Stmts: 6, Pipeline: 1}},
+		{"case x in a) ;; b) ;; esac", Metrics{Complexity: 3, Depth: 1, Stmts: 1, Pipeline: 1}},
+		{"a | b | c; d | e", Metrics{Complexity: 1, Stmts: 8, Pipeline: 3}},
+		{"f() { if a; then b; fi; }; g", Metrics{Complexity: 1, Stmts: 2, Pipeline: 1}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f := parse(t, tc.in)
+			if got := Measure(f); got != tc.want {
+				t.Fatalf("wrong metrics in %q:\nwant: %+v\ngot:  %+v",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMeasureFuncs(t *testing.T) {
+	t.Parallel()
+	f := parse(t, "f() {\n\tif a; then\n\t\tg() { a && b; }\n\tfi\n}\nf")
+	top, fms := MeasureFuncs(f, Funcs(f))
+	if want := (Metrics{Complexity: 1, Stmts: 2, Pipeline: 1}); top != want {
+		t.Fatalf("wrong top level metrics:\nwant: %+v\ngot:  %+v", want, top)
+	}
+	want := []Metrics{
+		{Complexity: 2, Depth: 1, Stmts: 4, Pipeline: 1},
+		{Complexity: 2, Stmts: 4, Pipeline: 1},
+	}
+	if len(fms) != len(want) {
+		t.Fatalf("want %d functions, got %d", len(want), len(fms))
+	}
+	for i, fm := range fms {
+		if fm.Metrics != want[i] {
+			t.Errorf("wrong metrics for %s:\nwant: %+v\ngot:  %+v",
+				fm.Func.Name, want[i], fm.Metrics)
+		}
+	}
+}
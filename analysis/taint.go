@@ -0,0 +1,308 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// SinkKind describes where tainted data ends up being executed.
+type SinkKind int
+
+const (
+	// EvalSink is an argument to eval.
+	EvalSink SinkKind = iota
+	// ShellSink is the command string of a shell started with -c,
+	// such as "sh -c".
+	ShellSink
+	// CommandSink is the name of a command that is run.
+	CommandSink
+	// SourceSink is a file that is sourced.
+	SourceSink
+)
+
+func (k SinkKind) String() string {
+	switch k {
+	case EvalSink:
+		return "eval"
+	case ShellSink:
+		return "shell"
+	case CommandSink:
+		return "command"
+	default:
+		return "source"
+	}
+}
+
+// DefaultTaintSources are the environment variables that are
+// considered tainted if TaintConfig.Sources is nil. They are set by web
+// servers from the request, as done by CGI.
+var DefaultTaintSources = []string{
+	"QUERY_STRING",
+	"REQUEST_URI",
+	"PATH_INFO",
+	"CONTENT_TYPE",
+	"REMOTE_USER",
+	"HTTP_*",
+}
+
+// TaintConfig controls how the taint analysis is done.
+type TaintConfig struct {
+	// Sources are the names of the variables whose values are not
+	// trusted. A trailing '*' matches any suffix. If nil,
+	// DefaultTaintSources is used.
+	//
+	// Positional parameters and variables set by commands like read
+	// are always tainted.
+	Sources []string
+}
+
+// TaintFlow represents tainted data reaching a sink.
+type TaintFlow struct {
+	Kind SinkKind
+	Stmt *syntax.Stmt
+
+	// Exp is the tainted expansion used in the sink.
+	Exp *syntax.ParamExp
+
+	// Var is the name of the expanded parameter, and Origin the
+	// untrusted source its value comes from, such as "1",
+	// "QUERY_STRING" or "read".
+	Var, Origin string
+}
+
+// Pos returns the position of the tainted expansion.
+func (t *TaintFlow) Pos() syntax.Pos { return t.Exp.Pos() }
+
+// Taint reports where untrusted data reaches a sink like eval, using
+// the default configuration.
+func Taint(f *syntax.File) []*TaintFlow {
+	return TaintConfig{}.Taint(f)
+}
+
+// Taint reports where untrusted data reaches a sink like eval.
+//
+// The analysis is flow-insensitive: a variable is tainted if any of
+// its assignments in the file may derive from an untrusted source,
+// regardless of the order in which the assignments are run. Function
+// arguments are always considered tainted, as they may be passed
+// through from the script's arguments.
+func (c TaintConfig) Taint(f *syntax.File) []*TaintFlow {
+	t := &tainter{sources: c.Sources, vars: make(map[string]string)}
+	if t.sources == nil {
+		t.sources = DefaultTaintSources
+	}
+	for {
+		t.changed = false
+		syntax.Walk(propVisitor{t}, f)
+		if !t.changed {
+			break
+		}
+	}
+	syntax.Walk(sinkVisitor{t: t}, f)
+	return t.flows
+}
+
+type tainter struct {
+	sources []string
+
+	// vars maps tainted variables to their origin.
+	vars    map[string]string
+	changed bool
+
+	flows []*TaintFlow
+}
+
+func (t *tainter) mark(name, origin string) {
+	if _, ok := t.vars[name]; ok || name == "" {
+		return
+	}
+	t.vars[name] = origin
+	t.changed = true
+}
+
+// origin returns where the value of a parameter comes from, if it is
+// tainted.
+func (t *tainter) origin(param string) (string, bool) {
+	if param == "@" || param == "*" {
+		return param, true
+	}
+	if param != "0" && strings.Trim(param, "0123456789") == "" {
+		return param, true
+	}
+	if o, ok := t.vars[param]; ok {
+		return o, true
+	}
+	for _, s := range t.sources {
+		if s == param || strings.HasSuffix(s, "*") &&
+			strings.HasPrefix(param, s[:len(s)-1]) {
+			return param, true
+		}
+	}
+	return "", false
+}
+
+// tainted returns the first tainted expansion within a node, if any.
+func (t *tainter) tainted(node syntax.Node) (*syntax.ParamExp, string) {
+	v := &expVisitor{t: t}
+	syntax.Walk(v, node)
+	return v.exp, v.origin
+}
+
+type expVisitor struct {
+	t      *tainter
+	exp    *syntax.ParamExp
+	origin string
+}
+
+func (v *expVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if v.exp != nil {
+		return nil
+	}
+	switch x := node.(type) {
+	case *syntax.ArithmExp:
+		// the result is a number
+		return nil
+	case *syntax.ParamExp:
+		if x.Length {
+			return nil
+		}
+		if o, ok := v.t.origin(x.Param.Value); ok {
+			v.exp, v.origin = x, o
+			return nil
+		}
+	}
+	return v
+}
+
+// propVisitor marks the variables that are assigned tainted values.
+type propVisitor struct {
+	t *tainter
+}
+
+func (v propVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Assign:
+		if x.Name == nil || x.Value == nil {
+			break
+		}
+		if exp, origin := v.t.tainted(x.Value); exp != nil {
+			v.t.mark(identPrefix(x.Name.Value), origin)
+		}
+	case *syntax.WordIter:
+		for _, w := range x.List {
+			if exp, origin := v.t.tainted(w); exp != nil {
+				v.t.mark(x.Name.Value, origin)
+				break
+			}
+		}
+	case *syntax.CallExpr:
+		name, _ := callName(x)
+		switch name {
+		case "read", "mapfile", "readarray", "getopts":
+			for _, ref := range Vars(x) {
+				if ref.Kind == AssignRef {
+					v.t.mark(ref.Name, name)
+				}
+			}
+			if name == "getopts" {
+				v.t.mark("OPTARG", name)
+			}
+		case "printf":
+			exp, origin := v.t.tainted(x)
+			if exp == nil {
+				break
+			}
+			for _, ref := range Vars(x) {
+				if ref.Kind == AssignRef {
+					v.t.mark(ref.Name, origin)
+				}
+			}
+		}
+	}
+	return v
+}
+
+// shells are the commands that run a command string given via -c.
+var shells = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"mksh": true,
+	"zsh":  true,
+}
+
+// sinkVisitor reports the tainted expansions used in sinks.
+type sinkVisitor struct {
+	t    *tainter
+	stmt *syntax.Stmt
+}
+
+func (v sinkVisitor) add(kind SinkKind, node syntax.Node) bool {
+	exp, origin := v.t.tainted(node)
+	if exp == nil {
+		return false
+	}
+	v.t.flows = append(v.t.flows, &TaintFlow{
+		Kind:   kind,
+		Stmt:   v.stmt,
+		Exp:    exp,
+		Var:    exp.Param.Value,
+		Origin: origin,
+	})
+	return true
+}
+
+func (v sinkVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		return sinkVisitor{t: v.t, stmt: x}
+	case *syntax.EvalClause:
+		if x.Stmt != nil && v.add(EvalSink, x.Stmt) {
+			return nil
+		}
+	case *syntax.CallExpr:
+		v.callExpr(x)
+	}
+	return v
+}
+
+func (v sinkVisitor) callExpr(ce *syntax.CallExpr) {
+	if len(ce.Args) == 0 || v.add(CommandSink, ce.Args[0]) {
+		return
+	}
+	name, _ := callName(ce)
+	args := ce.Args[1:]
+	switch {
+	case name == "source" || name == ".":
+		if len(args) > 0 {
+			v.add(SourceSink, args[0])
+		}
+	case name == "eval":
+		for _, w := range args {
+			if v.add(EvalSink, w) {
+				break
+			}
+		}
+	case name == "exec" || name == "command":
+		for _, w := range args {
+			if !strings.HasPrefix(wordLit(w), "-") {
+				v.add(CommandSink, w)
+				break
+			}
+		}
+	case shells[name]:
+		for i, w := range args {
+			s := wordLit(w)
+			if len(s) > 1 && s[0] == '-' && s[1] != '-' &&
+				contains(s[1:], 'c') && i+1 < len(args) {
+				v.add(ShellSink, args[i+1])
+				break
+			}
+		}
+	}
+}
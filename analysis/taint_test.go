@@ -0,0 +1,56 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTaint(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"eval foo; $bar", nil},
+		{"eval $1", []string{"eval:1:1"}},
+		{`eval "echo $@"`, []string{"eval:@:@"}},
+		{"x=$1; y=${x}z; eval $y", []string{"eval:y:1"}},
+		{"eval $y; y=$x; x=$QUERY_STRING", []string{"eval:y:QUERY_STRING"}},
+		{"sh -c \"$HTTP_COOKIE\"", []string{"shell:HTTP_COOKIE:HTTP_COOKIE"}},
+		{"bash -ec $1 foo", []string{"shell:1:1"}},
+		{"sh -c foo $1", nil},
+		{"read -r line; $line arg", []string{"command:line:read"}},
+		{"for f in \"$@\"; do exec $f; done", []string{"command:f:@"}},
+		{"source \"$1\"", []string{"source:1:1"}},
+		{"printf -v cmd '%s' \"$2\"; eval \"$cmd\"", []string{"eval:cmd:2"}},
+		{"n=${#1}; eval $n; m=$(($1)); eval $m", nil},
+		{"foo \"$1\"; $0", nil},
+		{"getopts ab opt; eval $OPTARG", []string{"eval:OPTARG:getopts"}},
+		{"f() { local x=$1; eval \"$x\"; }", []string{"eval:x:1"}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var got []string
+			for _, fl := range Taint(parse(t, tc.in)) {
+				got = append(got, fmt.Sprintf("%s:%s:%s", fl.Kind, fl.Var, fl.Origin))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Taint mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTaintSources(t *testing.T) {
+	t.Parallel()
+	f := parse(t, "eval $QUERY_STRING; eval $INPUT_x")
+	flows := TaintConfig{Sources: []string{"INPUT_*"}}.Taint(f)
+	if len(flows) != 1 || flows[0].Var != "INPUT_x" {
+		t.Fatalf("unexpected flows: %v", flows)
+	}
+}
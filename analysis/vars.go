@@ -0,0 +1,305 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"github.com/mvdan/sh/syntax"
+)
+
+// RefKind describes how a variable is referenced.
+type RefKind int
+
+const (
+	// ReadRef is an expansion or arithmetic use of a variable.
+	ReadRef RefKind = iota
+	// AssignRef is an assignment, including those done by commands
+	// like read or getopts.
+	AssignRef
+	// DeclRef is a declaration without a value, such as "export
+	// foo" or "local foo".
+	DeclRef
+	// UnsetRef is the removal of a variable via unset.
+	UnsetRef
+)
+
+func (k RefKind) String() string {
+	switch k {
+	case AssignRef:
+		return "assign"
+	case DeclRef:
+		return "decl"
+	case UnsetRef:
+		return "unset"
+	default:
+		return "read"
+	}
+}
+
+// VarRef represents a reference to a variable.
+type VarRef struct {
+	Name string
+	Kind RefKind
+
+	// Lit is the literal whose value starts with the variable name.
+	// It may contain more characters after the name, such as an
+	// array index in "arr[1]=x".
+	Lit *syntax.Lit
+}
+
+// Pos returns the position of the variable name.
+func (r *VarRef) Pos() syntax.Pos { return r.Lit.Pos() }
+
+// Vars collects all the references to variables within a node, in the
+// order they appear. Special parameters such as $1 or $@ are not
+// included. The indexes of arrays are arithmetic expressions, except
+// for the names declared as associative arrays with "declare -A".
+func Vars(node syntax.Node) []*VarRef {
+	v := &varVisitor{assoc: make(map[string]bool)}
+	syntax.Walk(v, node)
+	return v.refs
+}
+
+type varVisitor struct {
+	refs []*VarRef
+
+	// assoc holds the names declared as associative arrays
+	assoc map[string]bool
+}
+
+func (v *varVisitor) add(lit *syntax.Lit, kind RefKind) {
+	if lit == nil {
+		return
+	}
+	if name := identPrefix(lit.Value); name != "" {
+		v.refs = append(v.refs, &VarRef{Name: name, Kind: kind, Lit: lit})
+	}
+}
+
+// addWord adds a reference if the word is a single literal name.
+func (v *varVisitor) addWord(w *syntax.Word, kind RefKind) bool {
+	if w == nil || len(w.Parts) != 1 {
+		return false
+	}
+	lit, ok := w.Parts[0].(*syntax.Lit)
+	if !ok || identPrefix(lit.Value) == "" {
+		return false
+	}
+	v.add(lit, kind)
+	return true
+}
+
+func (v *varVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Assign:
+		if x.Name != nil {
+			v.add(x.Name, AssignRef)
+		}
+	case *syntax.WordIter:
+		v.add(x.Name, AssignRef)
+		for _, w := range x.List {
+			syntax.Walk(v, w)
+		}
+		return nil
+	case *syntax.ParamExp:
+		v.add(x.Param, ReadRef)
+		arithm := arithmVisitor{v}
+		if x.Ind != nil {
+			if x.Param != nil && v.assoc[x.Param.Value] {
+				// the keys are strings
+				syntax.Walk(v, x.Ind.Expr)
+			} else {
+				syntax.Walk(arithm, x.Ind.Expr)
+			}
+		}
+		if x.Slice != nil {
+			if x.Slice.Offset != nil {
+				syntax.Walk(arithm, x.Slice.Offset)
+			}
+			if x.Slice.Length != nil {
+				syntax.Walk(arithm, x.Slice.Length)
+			}
+		}
+		if x.Repl != nil {
+			syntax.Walk(v, x.Repl.Orig)
+			syntax.Walk(v, x.Repl.With)
+		}
+		if x.Exp != nil {
+			syntax.Walk(v, x.Exp.Word)
+		}
+		return nil
+	case *syntax.ArithmExp, *syntax.ArithmCmd, *syntax.LetClause,
+		*syntax.CStyleLoop:
+		return arithmVisitor{v}
+	case *syntax.DeclClause:
+		if declOpt(x.Opts, 'f') || declOpt(x.Opts, 'F') {
+			// names refer to functions
+			return nil
+		}
+		assoc := declOpt(x.Opts, 'A')
+		for _, a := range x.Assigns {
+			if a.Name == nil {
+				if v.addWord(a.Value, DeclRef) {
+					if assoc {
+						v.assoc[wordLit(a.Value)] = true
+					}
+					continue
+				}
+			} else if assoc {
+				v.assoc[a.Name.Value] = true
+			}
+			syntax.Walk(v, a)
+		}
+		return nil
+	case *syntax.UnaryTest:
+		if x.Op == syntax.TsVarSet {
+			if w, ok := x.X.(*syntax.Word); ok && v.addWord(w, ReadRef) {
+				return nil
+			}
+		}
+	case *syntax.CallExpr:
+		v.callExpr(x)
+	}
+	return v
+}
+
+// callExpr adds the references made by builtins that take variable
+// names as arguments.
+func (v *varVisitor) callExpr(ce *syntax.CallExpr) {
+	name, _ := callName(ce)
+	args := ce.Args[1:]
+	switch name {
+	case "unset":
+		if len(args) > 0 && wordLit(args[0]) == "-f" {
+			return
+		}
+		for _, w := range args {
+			if s := wordLit(w); s != "-v" {
+				v.addWord(w, UnsetRef)
+			}
+		}
+	case "read", "mapfile", "readarray":
+		// options that take an argument
+		withArg := "dnOsuCc"
+		if name == "read" {
+			withArg = "adinNptu"
+		}
+		for i := 0; i < len(args); i++ {
+			s := wordLit(args[i])
+			if len(s) < 2 || s[0] != '-' {
+				v.addWord(args[i], AssignRef)
+				continue
+			}
+			for j := 1; j < len(s); j++ {
+				if !contains(withArg, s[j]) {
+					continue
+				}
+				if j == len(s)-1 && i+1 < len(args) {
+					i++
+					if s[j] == 'a' {
+						v.addWord(args[i], AssignRef)
+					}
+				}
+				break
+			}
+		}
+	case "getopts":
+		if len(args) > 1 {
+			v.addWord(args[1], AssignRef)
+		}
+	case "printf":
+		if len(args) > 1 && wordLit(args[0]) == "-v" {
+			v.addWord(args[1], AssignRef)
+		}
+	}
+}
+
+type arithmVisitor struct {
+	v *varVisitor
+}
+
+func (a arithmVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Word:
+		if a.v.addWord(x, ReadRef) {
+			return nil
+		}
+		return a.v
+	case *syntax.BinaryArithm:
+		if assignOp(x.Op) {
+			if w, ok := x.X.(*syntax.Word); ok && a.v.addWord(w, AssignRef) {
+				syntax.Walk(a, x.Y)
+				return nil
+			}
+		}
+	case *syntax.UnaryArithm:
+		if x.Op == syntax.Inc || x.Op == syntax.Dec {
+			if w, ok := x.X.(*syntax.Word); ok && a.v.addWord(w, AssignRef) {
+				return nil
+			}
+		}
+	}
+	return a
+}
+
+func assignOp(op syntax.BinAritOperator) bool {
+	switch op {
+	case syntax.Assgn, syntax.AddAssgn, syntax.SubAssgn, syntax.MulAssgn,
+		syntax.QuoAssgn, syntax.RemAssgn, syntax.AndAssgn, syntax.OrAssgn,
+		syntax.XorAssgn, syntax.ShlAssgn, syntax.ShrAssgn:
+		return true
+	}
+	return false
+}
+
+// declOpt reports whether a declare clause has the given option.
+func declOpt(opts []*syntax.Word, opt byte) bool {
+	for _, w := range opts {
+		s := wordLit(w)
+		if len(s) > 1 && s[0] == '-' && contains(s[1:], opt) {
+			return true
+		}
+	}
+	return false
+}
+
+// wordLit returns the static value of a word, or the empty string if
+// it isn't static.
+func wordLit(w *syntax.Word) string {
+	s, _ := syntax.StaticValue(w)
+	return s
+}
+
+func contains(s string, b byte) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == b {
+			return true
+		}
+	}
+	return false
+}
+
+// identPrefix returns the variable name that the string starts with. It
+// may only be followed by an array index.
+func identPrefix(s string) string {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			if i > 0 && c == '[' {
+				return s[:i]
+			}
+			return ""
+		}
+		i++
+	}
+	return s
+}
+
+// ValidName reports whether a string is a valid variable name.
+func ValidName(s string) bool {
+	return s != "" && identPrefix(s) == s
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestVars(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"echo $1 $@ ${#}", nil},
+		{"a=b; echo $a", []string{"a:assign", "a:read"}},
+		{"a[1]=x; echo ${b[c]}", []string{"a:assign", "b:read", "c:read"}},
+		{"echo ${a:-$b} ${c/$d/e}", []string{"a:read", "b:read", "c:read", "d:read"}},
+		{"((i++)); echo $((x + y)); let z=x", []string{
+			"i:assign", "x:read", "y:read", "z:assign", "x:read",
+		}},
+		{"for i in $l; do :; done", []string{"i:assign", "l:read"}},
+		{"local a b=$c; declare -f fn; unset -v d", []string{
+			"a:decl", "b:assign", "c:read", "d:unset",
+		}},
+		{"read -rp prompt a b; read -a arr", []string{
+			"a:assign", "b:assign", "arr:assign",
+		}},
+		{"mapfile -t lines; readarray -u 3 -n 2 arr", []string{
+			"lines:assign", "arr:assign",
+		}},
+		{"getopts ab: opt; printf -v out %s x", []string{
+			"opt:assign", "out:assign",
+		}},
+		{"[[ -v a ]]; echo ${x:1:y}", []string{"a:read", "x:read", "y:read"}},
+		{"echo $(a=b)", []string{"a:assign"}},
+		{"declare -A m; m[x]=1; echo ${m[x]} ${m[$k]} ${a[x]}", []string{
+			"m:decl", "m:assign", "m:read", "m:read", "k:read", "a:read", "x:read",
+		}},
+		{"local -A m=([k]=v); echo ${m[k]}", []string{"m:assign", "m:read"}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var got []string
+			for _, ref := range Vars(parse(t, tc.in)) {
+				got = append(got, fmt.Sprintf("%s:%s", ref.Name, ref.Kind))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Vars mismatch\nwant: %q\ngot:  %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package cfg builds control-flow graphs of shell programs.
+package cfg
+
+import (
+	"strconv"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Node is a node in a control-flow graph. Each statement is a node,
+// including the statements that contain others like if clauses or
+// loops.
+type Node struct {
+	// Index is the position of the node in Graph.Nodes.
+	Index int
+
+	// Stmt is the statement the node represents. It is nil for the
+	// entry and exit nodes.
+	Stmt *syntax.Stmt
+
+	Succs, Preds []*Node
+}
+
+// Graph is a control-flow graph.
+//
+// The statements within command substitutions and process
+// substitutions are not part of the graph, and neither are the bodies
+// of function declarations, which have graphs of their own. See Func.
+type Graph struct {
+	// Entry and Exit are synthetic nodes where execution starts and
+	// ends. Exit is reached after the last statement and via
+	// statements like exit, return or exec.
+	Entry, Exit *Node
+
+	// Nodes holds all the nodes in the graph in the order they
+	// appear in the source, starting with Entry and ending with Exit.
+	Nodes []*Node
+}
+
+// File builds the control-flow graph of a file.
+func File(f *syntax.File) *Graph { return New(f.Stmts) }
+
+// Func builds the control-flow graph of a function body.
+func Func(fd *syntax.FuncDecl) *Graph { return New([]*syntax.Stmt{fd.Body}) }
+
+// New builds the control-flow graph of a list of statements.
+func New(stmts []*syntax.Stmt) *Graph {
+	b := builder{g: &Graph{}}
+	b.g.Entry = b.node(nil)
+	exit := &Node{}
+	b.g.Exit = exit
+	b.cur = []*Node{b.g.Entry}
+	b.stmts(stmts)
+	b.jump(exit)
+	exit.Index = len(b.g.Nodes)
+	b.g.Nodes = append(b.g.Nodes, exit)
+	return b.g
+}
+
+// Reachable returns whether each node can be reached from the entry
+// node, indexed by Node.Index.
+func (g *Graph) Reachable() []bool {
+	seen := make([]bool, len(g.Nodes))
+	stack := []*Node{g.Entry}
+	seen[g.Entry.Index] = true
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, s := range n.Succs {
+			if !seen[s.Index] {
+				seen[s.Index] = true
+				stack = append(stack, s)
+			}
+		}
+	}
+	return seen
+}
+
+// Unreachable returns the statements that can never be executed, such
+// as those following an exit. Statements contained in an unreachable
+// statement are included too.
+func (g *Graph) Unreachable() []*syntax.Stmt {
+	var stmts []*syntax.Stmt
+	for i, ok := range g.Reachable() {
+		if n := g.Nodes[i]; !ok && n.Stmt != nil {
+			stmts = append(stmts, n.Stmt)
+		}
+	}
+	return stmts
+}
+
+// frame is a loop or a subshell that may be jumped out of.
+type frame struct {
+	loop bool
+
+	// head is where continue jumps to in a loop.
+	head *Node
+
+	// outs are the nodes that jump to the end of the frame, such as
+	// breaks in a loop or exits in a subshell.
+	outs []*Node
+}
+
+type builder struct {
+	g *Graph
+
+	// cur are the nodes that continue to the next statement.
+	cur []*Node
+
+	frames []*frame
+}
+
+func (b *builder) node(s *syntax.Stmt) *Node {
+	n := &Node{Index: len(b.g.Nodes), Stmt: s}
+	b.g.Nodes = append(b.g.Nodes, n)
+	return n
+}
+
+func link(from, to *Node) {
+	for _, s := range from.Succs {
+		if s == to {
+			return
+		}
+	}
+	from.Succs = append(from.Succs, to)
+	to.Preds = append(to.Preds, from)
+}
+
+// jump links all the current nodes to a node, after which there are no
+// current nodes left.
+func (b *builder) jump(to *Node) {
+	for _, n := range b.cur {
+		link(n, to)
+	}
+	b.cur = nil
+}
+
+func (b *builder) push(f *frame) { b.frames = append(b.frames, f) }
+
+func (b *builder) pop() *frame {
+	f := b.frames[len(b.frames)-1]
+	b.frames = b.frames[:len(b.frames)-1]
+	return f
+}
+
+func (b *builder) stmts(stmts []*syntax.Stmt) {
+	for _, s := range stmts {
+		b.stmt(s)
+	}
+}
+
+// sub builds the statement as if it ran in a subshell, which exit and
+// return cannot escape.
+func (b *builder) sub(fn func()) {
+	f := &frame{}
+	b.push(f)
+	fn()
+	b.pop()
+	b.cur = append(b.cur, f.outs...)
+}
+
+func (b *builder) stmt(s *syntax.Stmt) {
+	n := b.node(s)
+	b.jump(n)
+	b.cur = []*Node{n}
+	if s.Background {
+		b.sub(func() { b.cmd(n, s.Cmd) })
+		// the shell doesn't wait for the command
+		b.cur = []*Node{n}
+		return
+	}
+	b.cmd(n, s.Cmd)
+}
+
+func (b *builder) cmd(n *Node, cmd syntax.Command) {
+	switch x := cmd.(type) {
+	case *syntax.CallExpr:
+		b.call(n, x)
+	case *syntax.Block:
+		b.stmts(x.Stmts)
+	case *syntax.Subshell:
+		b.sub(func() { b.stmts(x.Stmts) })
+	case *syntax.BinaryCmd:
+		switch x.Op {
+		case syntax.AndStmt, syntax.OrStmt:
+			b.stmt(x.X)
+			skip := b.cur
+			b.stmt(x.Y)
+			b.cur = append(b.cur, skip...)
+		default:
+			b.sub(func() { b.stmt(x.X) })
+			b.sub(func() { b.stmt(x.Y) })
+		}
+	case *syntax.IfClause:
+		b.stmts(x.CondStmts)
+		cond := b.cur
+		b.stmts(x.ThenStmts)
+		outs := b.cur
+		for _, elif := range x.Elifs {
+			b.cur = cond
+			b.stmts(elif.CondStmts)
+			cond = b.cur
+			b.stmts(elif.ThenStmts)
+			outs = append(outs, b.cur...)
+		}
+		b.cur = cond
+		b.stmts(x.ElseStmts)
+		b.cur = append(b.cur, outs...)
+	case *syntax.WhileClause:
+		b.loop(n, x.CondStmts, x.DoStmts)
+	case *syntax.UntilClause:
+		b.loop(n, x.CondStmts, x.DoStmts)
+	case *syntax.ForClause:
+		b.loop(n, nil, x.DoStmts)
+	case *syntax.CaseClause:
+		var outs, carry []*Node
+		for _, pl := range x.List {
+			b.cur = append([]*Node{n}, carry...)
+			b.stmts(pl.Stmts)
+			carry = nil
+			switch pl.Op {
+			case syntax.SemiFall:
+				carry = b.cur
+			case syntax.DblSemiFall:
+				carry = b.cur
+				outs = append(outs, b.cur...)
+			default:
+				outs = append(outs, b.cur...)
+			}
+		}
+		// no pattern matched
+		b.cur = append(outs, n)
+	}
+}
+
+func (b *builder) loop(n *Node, cond, body []*syntax.Stmt) {
+	f := &frame{loop: true, head: n}
+	b.stmts(cond)
+	done := b.cur
+	b.push(f)
+	b.stmts(body)
+	b.pop()
+	b.jump(n)
+	b.cur = append(done, f.outs...)
+}
+
+func (b *builder) call(n *Node, ce *syntax.CallExpr) {
+	if len(ce.Args) == 0 {
+		return
+	}
+	name, _ := syntax.StaticValue(ce.Args[0])
+	switch name {
+	case "exec":
+		// without a command, exec only applies its redirects
+		if len(ce.Args) > 1 {
+			b.exit(n)
+		}
+	case "exit", "return":
+		b.exit(n)
+	case "break", "continue":
+		level := 1
+		if len(ce.Args) > 1 {
+			s, _ := syntax.StaticValue(ce.Args[1])
+			if l, err := strconv.Atoi(s); err == nil && l > 0 {
+				level = l
+			}
+		}
+		for i := len(b.frames) - 1; i >= 0; i-- {
+			f := b.frames[i]
+			if !f.loop {
+				// cannot break out of a subshell
+				f.outs = append(f.outs, n)
+				b.cur = nil
+				return
+			}
+			// like bash, break out of all loops if the level
+			// is too large
+			if level--; level > 0 && i > 0 && b.frames[i-1].loop {
+				continue
+			}
+			if name == "break" {
+				f.outs = append(f.outs, n)
+				b.cur = nil
+			} else {
+				b.jump(f.head)
+			}
+			return
+		}
+		// not in a loop, so it does nothing
+	}
+}
+
+// exit ends the innermost subshell or function, or the whole program.
+func (b *builder) exit(n *Node) {
+	for i := len(b.frames) - 1; i >= 0; i-- {
+		if f := b.frames[i]; !f.loop {
+			f.outs = append(f.outs, n)
+			b.cur = nil
+			return
+		}
+	}
+	b.jump(b.g.Exit)
+}
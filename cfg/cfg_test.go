@@ -0,0 +1,123 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func parse(tb testing.TB, src string) *syntax.File {
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return f
+}
+
+// edges returns the successors of each node as a string, such as
+// "0:1 1:2,3". The nodes are numbered by their index.
+func edges(g *Graph) string {
+	var buf bytes.Buffer
+	for i, n := range g.Nodes {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d:", i)
+		for j, s := range n.Succs {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%d", s.Index)
+		}
+	}
+	return buf.String()
+}
+
+func TestGraph(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"", "0:1 1:"},
+		{"a; b", "0:1 1:2 2:3 3:"},
+		{"a && b", "0:1 1:2 2:3,4 3:4 4:"},
+		{"a || b; c", "0:1 1:2 2:3,4 3:4 4:5 5:"},
+		{"a | b", "0:1 1:2 2:3 3:4 4:"},
+		{"if a; then b; else c; fi", "0:1 1:2 2:3,4 3:5 4:5 5:"},
+		{"if a; then b; fi", "0:1 1:2 2:3,4 3:4 4:"},
+		{
+			"if a; then b; elif c; then d; fi",
+			"0:1 1:2 2:3,4 3:6 4:5,6 5:6 6:",
+		},
+		{"while a; do b; done", "0:1 1:2 2:3,4 3:1 4:"},
+		{"for i; do a; done; b", "0:1 1:2,3 2:1 3:4 4:"},
+		{"for i; do break; a; done", "0:1 1:2,4 2:4 3:1 4:"},
+		{"for i; do continue; a; done", "0:1 1:2,4 2:1 3:1 4:"},
+		{
+			"for i; do for j; do break 2; done; done",
+			"0:1 1:2,4 2:3,1 3:4 4:",
+		},
+		{"exit; a", "0:1 1:3 2:3 3:"},
+		{"f() { return; }; a", "0:1 1:2 2:3 3:"},
+		{"(exit); a", "0:1 1:2 2:3 3:4 4:"},
+		{"a & b", "0:1 1:2 2:3 3:"},
+		{"case x in a) b ;; c) d ;; esac", "0:1 1:2,3,4 2:4 3:4 4:"},
+		{"case x in a) b ;& c) d ;; esac", "0:1 1:2,3,4 2:3 3:4 4:"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := edges(File(parse(t, tc.in)))
+			if got != tc.want {
+				t.Fatalf("Graph mismatch in %q\nwant: %s\ngot:  %s",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"a; b", nil},
+		{"exit 1; a; b", []string{"a", "b"}},
+		{"(exit 1); a", nil},
+		{"if a; then exit; else exit; fi; b", []string{"b"}},
+		{"while a; do break; b; done; c", []string{"b"}},
+		{"exit | a; b", nil},
+		{"exec cmd; a; exec >f", []string{"a", "exec >f"}},
+		{"exec >f; a", nil},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var got []string
+			for _, s := range File(parse(t, tc.in)).Unreachable() {
+				var buf bytes.Buffer
+				syntax.Fprint(&buf, &syntax.File{Stmts: []*syntax.Stmt{s}})
+				got = append(got, string(bytes.TrimSpace(buf.Bytes())))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Unreachable mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFunc(t *testing.T) {
+	t.Parallel()
+	f := parse(t, "f() { a; return; b; }")
+	g := Func(f.Stmts[0].Cmd.(*syntax.FuncDecl))
+	want := "0:1 1:2 2:3 3:5 4:5 5:"
+	if got := edges(g); got != want {
+		t.Fatalf("Graph mismatch\nwant: %s\ngot:  %s", want, got)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// gosh is a shell run by the interpreter in the interp package. Without
+// arguments, it reads commands interactively from its standard input.
+// Otherwise, it runs the program in the first argument, with the rest
+// as its parameters.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mvdan/sh/interp"
+	"github.com/mvdan/sh/syntax"
+)
+
+var command = flag.String("c", "", "command to be executed")
+
+func main() {
+	flag.Parse()
+	err := run()
+	if e, ok := err.(interp.ExitCode); ok {
+		os.Exit(int(e))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	r := interp.Runner{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	ctx := context.Background()
+	args := flag.Args()
+	var file *syntax.File
+	switch {
+	case *command != "":
+		var err error
+		if file, err = syntax.Parse([]byte(*command), "", 0); err != nil {
+			return err
+		}
+		r.Params = args
+	case len(args) > 0:
+		src, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		if file, err = syntax.Parse(src, args[0], 0); err != nil {
+			return err
+		}
+		r.Params = args[1:]
+	default:
+		return r.Interactive(ctx, os.Stdin)
+	}
+	return r.Run(ctx, file)
+}
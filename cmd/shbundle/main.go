@@ -0,0 +1,70 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// shbundle joins shell programs into a single self-contained one, with
+// the files they source inlined, and minifies it. It's useful to ship
+// installers and container entrypoints as one file.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+var (
+	output = flag.String("o", "", "write the program to a file instead of stdout")
+	posix  = flag.Bool("p", false, "parse POSIX shell code instead of bash")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: shbundle [-o file] [-p] script...")
+		os.Exit(2)
+	}
+	if err := bundle(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func bundle(paths []string) error {
+	mode := syntax.ParseComments
+	if *posix {
+		mode |= syntax.PosixConformant
+	}
+	var src bytes.Buffer
+	for _, path := range paths {
+		prog, err := loader.Config{Mode: mode}.Load(path)
+		if err != nil {
+			return err
+		}
+		b, err := prog.Inline()
+		if err != nil {
+			return err
+		}
+		src.Write(b)
+		src.WriteByte('\n')
+	}
+	// only the shebang of the first program is kept, as the others
+	// are comments once joined
+	f, err := syntax.Parse(src.Bytes(), "", mode)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := (syntax.PrintConfig{Minify: true}).Fprint(&buf, f); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*output, buf.Bytes(), 0755)
+}
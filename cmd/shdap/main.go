@@ -0,0 +1,21 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// shdap is a debug adapter for shell programs, which editors like VS Code
+// run to debug scripts via the Debug Adapter Protocol. It speaks the
+// protocol over its standard input and output.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mvdan/sh/dap"
+)
+
+func main() {
+	if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/mvdan/sh/syntax"
+	"github.com/mvdan/sh/treesitter"
+)
+
+// writeJSON prints the syntax tree of a file as JSON. Each node is an
+// object with its fields, plus "Type" with the name of its type and
+// "Pos" and "End" with its position. Positions are objects with the
+// offset, line and column, and operators are their strings like "&&".
+// End positions are exclusive, so a node that ends at a newline ends at
+// the column after its last character, on the same line. Zero fields
+// are omitted.
+func writeJSON(w io.Writer, f *syntax.File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(jsonValue(f, reflect.ValueOf(f)))
+}
+
+// writeTreeSitter prints the syntax tree of a file as JSON, with the
+// nodes and ranges of the tree-sitter-bash grammar.
+func writeTreeSitter(w io.Writer, f *syntax.File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(treesitter.Convert(f))
+}
+
+var posType = reflect.TypeOf(syntax.Pos(0))
+
+func jsonPos(f *syntax.File, p syntax.Pos) interface{} {
+	pos := f.Position(p)
+	return map[string]int{"Offset": pos.Offset, "Line": pos.Line, "Col": pos.Column}
+}
+
+// jsonValue returns the value to encode for a part of the syntax tree,
+// or nil if it's zero.
+func jsonValue(f *syntax.File, v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if node, ok := v.Interface().(syntax.Node); ok && v.Kind() == reflect.Ptr {
+			m := jsonValue(f, v.Elem()).(map[string]interface{})
+			m["Type"] = v.Elem().Type().Name()
+			if node.Pos() > 0 {
+				m["Pos"] = jsonPos(f, node.Pos())
+				m["End"] = jsonPos(f, node.End())
+			}
+			return m
+		}
+		return jsonValue(f, v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			if v.Type() == reflect.TypeOf(syntax.File{}) &&
+				(field.Name == "Lines" || field.Name == "Source" || field.Name == "Mode") {
+				continue
+			}
+			if fv := jsonValue(f, v.Field(i)); fv != nil {
+				m[field.Name] = fv
+			}
+		}
+		return m
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = jsonValue(f, v.Index(i))
+		}
+		return list
+	}
+	if v.Type() == posType {
+		if v.Uint() == 0 {
+			return nil
+		}
+		return jsonPos(f, syntax.Pos(v.Uint()))
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		if v.Uint() == 0 {
+			return nil
+		}
+		return s.String()
+	}
+	if v.Interface() == reflect.Zero(v.Type()).Interface() {
+		return nil
+	}
+	return v.Interface()
+}
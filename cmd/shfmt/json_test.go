@@ -0,0 +1,46 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestWriteJSONEnd(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in       string
+		pos, end map[string]int
+	}{
+		{"echo foo\n", map[string]int{"Offset": 0, "Line": 1, "Col": 1}, map[string]int{"Offset": 8, "Line": 1, "Col": 9}},
+		{"echo foo", map[string]int{"Offset": 0, "Line": 1, "Col": 1}, map[string]int{"Offset": 8, "Line": 1, "Col": 9}},
+		{"\n\nfoo\n", map[string]int{"Offset": 2, "Line": 3, "Col": 1}, map[string]int{"Offset": 5, "Line": 3, "Col": 4}},
+	}
+	for _, tc := range tests {
+		f, err := syntax.Parse([]byte(tc.in), "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, f); err != nil {
+			t.Fatal(err)
+		}
+		var tree struct {
+			Stmts []struct{ Pos, End map[string]int }
+		}
+		if err := json.Unmarshal(buf.Bytes(), &tree); err != nil {
+			t.Fatal(err)
+		}
+		got := tree.Stmts[0]
+		if !reflect.DeepEqual(got.Pos, tc.pos) || !reflect.DeepEqual(got.End, tc.end) {
+			t.Fatalf("wrong statement range in %q:\nwant: %v %v\ngot:  %v %v",
+				tc.in, tc.pos, tc.end, got.Pos, got.End)
+		}
+	}
+}
@@ -8,19 +8,46 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"path/filepath"
-	"regexp"
+	"os/exec"
 	"strings"
 
+	"github.com/mvdan/sh/config"
+	"github.com/mvdan/sh/diff"
+	"github.com/mvdan/sh/internal/walk"
+	"github.com/mvdan/sh/markdown"
+	"github.com/mvdan/sh/refactor"
 	"github.com/mvdan/sh/syntax"
+	"github.com/mvdan/sh/tofish"
+	"github.com/mvdan/sh/togo"
+	"github.com/mvdan/sh/topwsh"
 )
 
 var (
-	write  = flag.Bool("w", false, "write result to file instead of stdout")
-	list   = flag.Bool("l", false, "list files whose formatting differs from shfmt's")
-	indent = flag.Int("i", 0, "indent: 0 for tabs (default), >0 for number of spaces")
-	posix  = flag.Bool("p", false, "parse POSIX shell code instead of bash")
+	write   = flag.Bool("w", false, "write result to file instead of stdout")
+	list    = flag.Bool("l", false, "list files whose formatting differs from shfmt's")
+	diffs   = flag.Bool("d", false, "print diffs of the files whose formatting differs")
+	indent  = flag.Int("i", 0, "indent: 0 for tabs (default), >0 for number of spaces")
+	posix   = flag.Bool("p", false, "parse POSIX shell code instead of bash")
+	lang    = flag.String("ln", "bash", "language variant to parse: bash or posix")
+	toJSON  = flag.Bool("tojson", false, "print the syntax tree as JSON instead of formatting")
+	toTS    = flag.Bool("totreesitter", false, "print the syntax tree as JSON with the node names of tree-sitter-bash")
+	toGo    = flag.Bool("togo", false, "print a Go program converted from the shell one; experimental")
+	toFish  = flag.Bool("tofish", false, "print a fish program converted from the shell one; experimental")
+	toPwsh  = flag.Bool("topwsh", false, "print a PowerShell script converted from the shell one; experimental")
+	toPOSIX = flag.Bool("toposix", false, "rewrite bash constructs to POSIX shell where possible")
+	check   = flag.Bool("check", false, "never write files, and print a summary of the unformatted ones")
+	watch   = flag.Bool("watch", false, "keep running, and format the files again when they change")
+	md      = flag.Bool("md", false, "format the sh and bash code blocks of Markdown files instead")
+	tmpl    = flag.String("template", "", "keep template markup in files as is: go for {{ }}, or jinja")
+	skip    = flag.String("skip", strings.Join(walk.DefaultSkip, ","), "comma-separated patterns of paths to skip in directories")
+
+	modernize   = flag.String("modernize", "", "comma-separated rewrites to modern bash: tests, arithm, ranges or all")
+	bashVersion = flag.String("bash", "", "version of bash that -modernize must support, like 3.2")
+
+	filesFrom = flag.String("files-from", "", "read the paths of the files to format from a file, or - for stdin")
+	stage     = flag.Bool("stage", false, "run git add on the files written by -w, for pre-commit hooks")
 
 	parseMode         syntax.ParseMode
 	printConfig       syntax.PrintConfig
@@ -29,37 +56,183 @@ var (
 	copyBuf = make([]byte, 32*1024)
 
 	out io.Writer
+
+	// changed is set when -d finds a file whose formatting differs
+	changed bool
+
+	// checked and unformatted are the files formatted by -check, and
+	// the ones whose formatting differs
+	checked     int
+	unformatted []string
+
+	// written are the files whose formatting was written by -w
+	written []string
+
+	// setFlags are the flags given explicitly, which take precedence
+	// over the configuration files
+	setFlags = make(map[string]bool)
+
+	// modernizations are the rewrites chosen via -modernize
+	modernizations refactor.Modernization
+
+	// tmplDelims are the delimiters of the markup chosen via -template
+	tmplDelims []syntax.TemplateDelims
 )
 
 func main() {
 	flag.Parse()
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
 
 	out = os.Stdout
 	printConfig.Spaces = *indent
 	parseMode |= syntax.ParseComments
+	switch *lang {
+	case "bash":
+	case "posix", "sh":
+		*posix = true
+	default:
+		fmt.Fprintf(os.Stderr, "unknown language variant: %q\n", *lang)
+		os.Exit(2)
+	}
+	if *posix && *toPOSIX {
+		fmt.Fprintln(os.Stderr, "-toposix converts bash, so it cannot be used with -p")
+		os.Exit(2)
+	}
 	if *posix {
 		parseMode |= syntax.PosixConformant
 	}
-	if flag.NArg() == 0 {
+	switch *tmpl {
+	case "":
+	case "go":
+		tmplDelims = syntax.GoTemplate
+	case "jinja":
+		tmplDelims = syntax.Jinja
+	default:
+		fmt.Fprintf(os.Stderr, "unknown template language: %q\n", *tmpl)
+		os.Exit(2)
+	}
+	if *modernize != "" {
+		if *posix || *toPOSIX {
+			fmt.Fprintln(os.Stderr, "-modernize produces bash, so it cannot be used with -p or -toposix")
+			os.Exit(2)
+		}
+		if err := parseModernize(*modernize, *bashVersion); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *check && (*write || *watch || *toJSON || *toTS || *toGo || *toFish || *toPwsh) {
+		fmt.Fprintln(os.Stderr, "-check cannot be used with -w, -watch, -tojson, -totreesitter, -togo, -tofish or -topwsh")
+		os.Exit(2)
+	}
+	if (*toJSON || *toTS || *toGo || *toFish || *toPwsh) && (*write || *list || *diffs) {
+		fmt.Fprintln(os.Stderr, "-tojson, -totreesitter, -togo, -tofish and -topwsh cannot be used with -w, -l or -d")
+		os.Exit(2)
+	}
+	if *md && (*toJSON || *toTS || *toGo || *toFish || *toPwsh || *toPOSIX || *modernize != "") {
+		fmt.Fprintln(os.Stderr, "-md cannot be used with -tojson, -totreesitter, -togo, -tofish, -topwsh, -toposix or -modernize")
+		os.Exit(2)
+	}
+	if *stage && !*write {
+		fmt.Fprintln(os.Stderr, "-stage can only be used with -w")
+		os.Exit(2)
+	}
+	if flag.NArg() == 0 && *filesFrom == "" {
+		if *watch {
+			fmt.Fprintln(os.Stderr, "-watch can only be used on files")
+			os.Exit(2)
+		}
 		if err := formatStdin(); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
+		if changed {
+			os.Exit(1)
+		}
 		return
 	}
-	anyErr := false
+	errors := 0
 	onError := func(err error) {
-		anyErr = true
+		errors++
 		fmt.Fprintln(os.Stderr, err)
 	}
+	wc := walk.Config{Skip: walk.DefaultSkip, Markdown: *md}
+	if *skip != "" {
+		wc.Skip = strings.Split(*skip, ",")
+	}
+	if *watch {
+		// runs until shfmt is interrupted
+		w := &walk.Watcher{Config: wc}
+		w.Run(flag.Args(), formatPath, onError, nil)
+	}
 	for _, path := range flag.Args() {
-		walk(path, onError)
+		wc.Walk(path, formatPath, onError)
+	}
+	if *filesFrom != "" {
+		formatListed(*filesFrom, onError)
 	}
-	if anyErr {
+	if *stage && len(written) > 0 {
+		cmd := exec.Command("git", append([]string{"add", "--"}, written...)...)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			onError(fmt.Errorf("git add: %v", err))
+		}
+	}
+	if *check {
+		for _, path := range unformatted {
+			fmt.Fprintf(out, "file\t%s\n", path)
+		}
+		fmt.Fprintf(out, "total\t%d\t%d\t%d\n", checked, len(unformatted), errors)
+		switch {
+		case errors > 0:
+			os.Exit(2)
+		case len(unformatted) > 0:
+			os.Exit(1)
+		}
+		return
+	}
+	if errors > 0 || changed {
 		os.Exit(1)
 	}
 }
 
+// formatListed formats the shell programs in a list of paths read from
+// a file, like the staged files given to a pre-commit hook. Paths are
+// separated by newlines or null bytes, and the ones that aren't shell
+// programs or don't exist are skipped, without walking any directory.
+func formatListed(from string, onError func(error)) {
+	var list []byte
+	var err error
+	if from == "-" {
+		list, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		list, err = ioutil.ReadFile(from)
+	}
+	if err != nil {
+		onError(err)
+		return
+	}
+	paths := strings.FieldsFunc(string(list), func(r rune) bool {
+		return r == '\n' || r == '\r' || r == 0
+	})
+	for _, path := range paths {
+		ok, err := walk.IsShell(path)
+		if *md {
+			_, err = os.Stat(path)
+			ok = walk.IsMarkdown(path) && err == nil
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err == nil && ok {
+			err = formatPath(path)
+		}
+		if err != nil {
+			onError(err)
+		}
+	}
+}
+
 func formatStdin() error {
 	if *write || *list {
 		return fmt.Errorf("-w and -l can only be used on files")
@@ -69,73 +242,138 @@ func formatStdin() error {
 		return err
 	}
 	src := readBuf.Bytes()
-	prog, err := syntax.Parse(src, "", parseMode)
+	if *md {
+		res, err := markdown.Config{Mode: parseMode, Print: printConfig}.Format("", src)
+		if err != nil {
+			return err
+		}
+		if *diffs {
+			return printDiff("<standard input>", src, res)
+		}
+		_, err = out.Write(res)
+		return err
+	}
+	prog, err := parse(src, "", parseMode)
 	if err != nil {
 		return err
 	}
-	return printConfig.Fprint(out, prog)
+	if *toJSON {
+		return writeJSON(out, prog)
+	}
+	if *toTS {
+		return writeTreeSitter(out, prog)
+	}
+	if *toGo {
+		return writeGo(prog)
+	}
+	if *toFish {
+		return writeFish(prog)
+	}
+	if *toPwsh {
+		return writePwsh(prog)
+	}
+	if modernizations != 0 {
+		refactor.Modernize(prog, modernizations, *bashVersion)
+	}
+	var perr error
+	if *toPOSIX {
+		perr = convertPOSIX(prog)
+	}
+	if !*diffs {
+		err = printConfig.Fprint(out, prog)
+	} else {
+		writeBuf.Reset()
+		printConfig.Fprint(&writeBuf, prog)
+		err = printDiff("<standard input>", src, writeBuf.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+	return perr
 }
 
-var (
-	shellFile    = regexp.MustCompile(`\.(sh|bash)$`)
-	validShebang = regexp.MustCompile(`^#!\s?/(usr/)?bin/(env *)?(sh|bash)`)
-	vcsDir       = regexp.MustCompile(`^\.(git|svn|hg)$`)
-)
+var modernizeNames = map[string]refactor.Modernization{
+	"tests":  refactor.ModernTests,
+	"arithm": refactor.ModernArithm,
+	"ranges": refactor.ModernRanges,
+	"all":    refactor.ModernAll,
+}
 
-type shellConfidence int
+// parseModernize sets modernizations from the -modernize and -bash
+// flags.
+func parseModernize(names, version string) error {
+	for _, name := range strings.Split(names, ",") {
+		m, ok := modernizeNames[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown -modernize rewrite: %q", name)
+		}
+		modernizations |= m
+	}
+	var major, minor int
+	if n, _ := fmt.Sscanf(version, "%d.%d", &major, &minor); version != "" && n == 0 {
+		return fmt.Errorf("invalid -bash version: %q", version)
+	}
+	return nil
+}
 
-const (
-	notShellFile shellConfidence = iota
-	ifValidShebang
-	isShellFile
-)
+// writeGo prints the Go program converted from a file.
+func writeGo(f *syntax.File) error {
+	src, err := togo.Convert(f)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(src)
+	return err
+}
 
-func getConfidence(info os.FileInfo) shellConfidence {
-	name := info.Name()
-	switch {
-	case info.IsDir(), name[0] == '.', !info.Mode().IsRegular():
-		return notShellFile
-	case shellFile.MatchString(name):
-		return isShellFile
-	case strings.Contains(name, "."):
-		return notShellFile // different extension
-	case info.Size() < 8:
-		return notShellFile // cannot possibly hold valid shebang
-	default:
-		return ifValidShebang
+// writeFish prints the fish program converted from a file.
+func writeFish(f *syntax.File) error {
+	src, err := tofish.Convert(f)
+	if err != nil {
+		return err
 	}
+	_, err = out.Write(src)
+	return err
 }
 
-func walk(path string, onError func(error)) {
-	info, err := os.Stat(path)
+// writePwsh prints the PowerShell script converted from a file.
+func writePwsh(f *syntax.File) error {
+	src, err := topwsh.Convert(f)
 	if err != nil {
-		onError(err)
-		return
+		return err
 	}
-	if !info.IsDir() {
-		if err := formatPath(path, false); err != nil {
-			onError(err)
-		}
-		return
+	_, err = out.Write(src)
+	return err
+}
+
+// convertPOSIX rewrites the bash constructs in a file to POSIX shell,
+// and returns an error listing the ones it couldn't.
+func convertPOSIX(f *syntax.File) error {
+	_, problems := refactor.ToPOSIX(f)
+	if len(problems) == 0 {
+		return nil
 	}
-	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() && vcsDir.MatchString(info.Name()) {
-			return filepath.SkipDir
-		}
-		if err != nil {
-			onError(err)
-			return nil
-		}
-		conf := getConfidence(info)
-		if conf == notShellFile {
-			return nil
-		}
-		err = formatPath(path, conf == ifValidShebang)
-		if err != nil && !os.IsNotExist(err) {
-			onError(err)
-		}
+	name := f.Name
+	if name == "" {
+		name = "<standard input>"
+	}
+	var lines []string
+	for _, p := range problems {
+		pos := f.Position(p.Pos)
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", name, pos.Line, pos.Column, p.Message))
+	}
+	return fmt.Errorf("%s", strings.Join(lines, "\n"))
+}
+
+// printDiff prints the changes that formatting makes to a file, if any.
+func printDiff(name string, src, res []byte) error {
+	d := diff.Unified(name, src, res)
+	if len(d) == 0 {
 		return nil
-	})
+	}
+	changed = true
+	_, err := out.Write(d)
+	return err
 }
 
 func empty(f *os.File) error {
@@ -146,7 +384,7 @@ func empty(f *os.File) error {
 	return err
 }
 
-func formatPath(path string, checkShebang bool) error {
+func formatPath(path string) error {
 	openMode := os.O_RDONLY
 	if *write {
 		openMode = os.O_RDWR
@@ -157,31 +395,62 @@ func formatPath(path string, checkShebang bool) error {
 	}
 	defer f.Close()
 	readBuf.Reset()
-	if checkShebang {
-		n, err := f.Read(copyBuf[:32])
-		if err != nil {
-			return err
-		}
-		if !validShebang.Match(copyBuf[:n]) {
-			return nil
-		}
-		readBuf.Write(copyBuf[:n])
-	}
 	if _, err := io.CopyBuffer(&readBuf, f, copyBuf); err != nil {
 		return err
 	}
 	src := readBuf.Bytes()
-	prog, err := syntax.Parse(src, path, parseMode)
+	mode, pconf, err := fileConfig(path)
 	if err != nil {
 		return err
 	}
-	writeBuf.Reset()
-	printConfig.Fprint(&writeBuf, prog)
-	res := writeBuf.Bytes()
+	var res []byte
+	var perr error
+	if *md {
+		res, err = markdown.Config{Mode: mode, Print: pconf}.Format(path, src)
+		if err != nil {
+			return err
+		}
+	} else {
+		prog, err := parse(src, path, mode)
+		if err != nil {
+			return err
+		}
+		if *toJSON {
+			return writeJSON(out, prog)
+		}
+		if *toTS {
+			return writeTreeSitter(out, prog)
+		}
+		if *toGo {
+			return writeGo(prog)
+		}
+		if *toFish {
+			return writeFish(prog)
+		}
+		if *toPwsh {
+			return writePwsh(prog)
+		}
+		if modernizations != 0 {
+			refactor.Modernize(prog, modernizations, *bashVersion)
+		}
+		if *toPOSIX {
+			perr = convertPOSIX(prog)
+		}
+		writeBuf.Reset()
+		pconf.Fprint(&writeBuf, prog)
+		res = writeBuf.Bytes()
+	}
+	checked++
 	if !bytes.Equal(src, res) {
+		unformatted = append(unformatted, path)
 		if *list {
 			fmt.Fprintln(out, path)
 		}
+		if *diffs {
+			if err := printDiff(path, src, res); err != nil {
+				return err
+			}
+		}
 		if *write {
 			if err := empty(f); err != nil {
 				return err
@@ -189,12 +458,47 @@ func formatPath(path string, checkShebang bool) error {
 			if _, err := f.Write(res); err != nil {
 				return err
 			}
+			written = append(written, path)
 		}
 	}
-	if !*list && !*write {
+	if !*list && !*write && !*diffs && !*check {
 		if _, err := out.Write(res); err != nil {
 			return err
 		}
 	}
-	return nil
+	return perr
+}
+
+// parse parses a shell program, keeping its template markup if
+// -template is used.
+func parse(src []byte, name string, mode syntax.ParseMode) (*syntax.File, error) {
+	if tmplDelims != nil {
+		return syntax.ParseTemplate(src, name, mode, tmplDelims)
+	}
+	return syntax.Parse(src, name, mode)
+}
+
+// fileConfig returns how to parse and print a file, from the flags and
+// the configuration files of its project.
+func fileConfig(path string) (syntax.ParseMode, syntax.PrintConfig, error) {
+	mode, pconf := parseMode, printConfig
+	s, err := config.Find(path)
+	if err != nil {
+		return 0, pconf, err
+	}
+	if n, ok := s.Indent(); ok && !setFlags["i"] {
+		pconf.Spaces = n
+	}
+	if !setFlags["p"] && !setFlags["ln"] && !*toPOSIX {
+		switch s.Variant() {
+		case "bash":
+			mode &^= syntax.PosixConformant
+		case "posix", "sh":
+			mode |= syntax.PosixConformant
+		}
+	}
+	if next, ok := s.BinaryNextLine(); ok {
+		pconf.BinaryEndLine = !next
+	}
+	return mode, pconf, nil
 }
@@ -9,7 +9,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/mvdan/sh/internal/walk"
 )
 
 var walkTests = []struct {
@@ -68,7 +71,7 @@ func TestWalk(t *testing.T) {
 	doWalk := func(path string) {
 		gotError = false
 		buf.Reset()
-		walk(path, onError)
+		walk.Config{}.Walk(path, formatPath, onError)
 	}
 	doWalk(".")
 	modified := make(map[string]bool, 0)
@@ -99,6 +102,51 @@ func TestWalk(t *testing.T) {
 	if doWalk(".hidden"); buf.Len() == 0 {
 		t.Fatal("`shfmt .hidden` did not print anything")
 	}
+	*diffs = true
+	if doWalk(".hidden"); !strings.HasPrefix(buf.String(), "--- .hidden\n+++ .hidden\n") {
+		t.Fatalf("`shfmt -d .hidden` did not print a diff: %q", buf.String())
+	}
+	if doWalk("ext.sh"); buf.Len() > 0 {
+		t.Fatal("`shfmt -d ext.sh` printed a diff of a formatted file")
+	}
+	*diffs, changed = false, false
+	*toJSON = true
+	if doWalk("ext.sh"); !strings.Contains(buf.String(), `"Type": "CallExpr"`) {
+		t.Fatalf("`shfmt -tojson ext.sh` did not print the syntax tree: %q", buf.String())
+	}
+	*toJSON, *toTS = false, true
+	if doWalk("ext.sh"); !strings.Contains(buf.String(), `"type": "command_name"`) {
+		t.Fatalf("`shfmt -totreesitter ext.sh` did not print the syntax tree: %q", buf.String())
+	}
+	*toTS, *toFish = false, true
+	if doWalk("ext.sh"); !strings.HasPrefix(buf.String(), "# This program was converted from ext.sh.\n") {
+		t.Fatalf("`shfmt -tofish ext.sh` did not print a fish program: %q", buf.String())
+	}
+	*toFish, *toPwsh = false, true
+	if doWalk("ext.sh"); !strings.HasPrefix(buf.String(), "# This program was converted from ext.sh.\n") {
+		t.Fatalf("`shfmt -topwsh ext.sh` did not print a PowerShell script: %q", buf.String())
+	}
+	*toPwsh = false
+	if err := ioutil.WriteFile("listed.sh", []byte(" foo"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile("list", []byte("listed.sh\n.hidden\x00missing.sh\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	*list = true
+	if formatListed("list", onError); buf.String() != "listed.sh\n" || gotError {
+		t.Fatalf("`shfmt -l -files-from list` printed %q", buf.String())
+	}
+	*list = false
+	if err := ioutil.WriteFile("doc.md", []byte("text\n\n```sh\n foo\n```\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	*md = true
+	if doWalk("doc.md"); buf.String() != "text\n\n```sh\nfoo\n```\n" {
+		t.Fatalf("`shfmt -md doc.md` printed %q", buf.String())
+	}
+	*md = false
 	if doWalk("nonexistent"); !gotError {
 		t.Fatal("`shfmt nonexistent` did not error")
 	}
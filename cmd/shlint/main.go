@@ -0,0 +1,272 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// shlint reports likely bugs and other problems in shell programs, via
+// the analyzers in the lint package. It exits with status 1 if any
+// diagnostics were reported, and 2 if any file couldn't be linted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/config"
+	"github.com/mvdan/sh/diff"
+	"github.com/mvdan/sh/dot"
+	"github.com/mvdan/sh/internal/walk"
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+var (
+	list   = flag.Bool("list", false, "list the analyzers and exit")
+	only   = flag.String("only", "", "comma-separated analyzers or groups to run")
+	source = flag.Bool("source", false, "load the files sourced by each file")
+	shell  = flag.String("shell", "", "target shell: posix, dash, bash3.2, bash4.4 or mksh")
+	apply  = flag.Bool("apply", false, "apply the suggested fixes to the files")
+	patch  = flag.Bool("diff", false, "print the suggested fixes as a patch")
+
+	commands = flag.String("commands", "", "file listing the commands that exist, one per line")
+	hostPath = flag.Bool("path", false, "check that the commands called exist in $PATH")
+
+	skip    = flag.String("skip", strings.Join(walk.DefaultSkip, ","), "comma-separated patterns of paths to skip in directories")
+	metrics = flag.Bool("metrics", false, "print the metrics of each function and exit")
+	graph   = flag.String("dot", "", "print a graph of each file in DOT and exit: ast, calls or includes")
+	watch   = flag.Bool("watch", false, "keep running, and lint the files again when they change")
+	check   = flag.Bool("check", false, "never write files, and print a summary of the diagnostics")
+)
+
+func main() {
+	flag.Parse()
+	if *list {
+		for _, a := range lint.Analyzers() {
+			doc := a.Doc
+			if i := strings.IndexByte(doc, '\n'); i >= 0 {
+				doc = doc[:i]
+			}
+			name := a.Name
+			if a.Group != "" {
+				name = a.Group + "/" + name
+			}
+			fmt.Printf("%s\t%s\t%s\n", name, a.Code, doc)
+		}
+		return
+	}
+	wc := walk.Config{Skip: walk.DefaultSkip}
+	if *skip != "" {
+		wc.Skip = strings.Split(*skip, ",")
+	}
+	if *metrics {
+		for _, path := range flag.Args() {
+			wc.Walk(path, printMetrics, func(err error) {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			})
+		}
+		return
+	}
+	if *graph != "" {
+		switch *graph {
+		case "ast", "calls", "includes":
+		default:
+			fmt.Fprintf(os.Stderr, "unknown graph: %q\n", *graph)
+			os.Exit(2)
+		}
+		for _, path := range flag.Args() {
+			wc.Walk(path, printGraph, func(err error) {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			})
+		}
+		return
+	}
+	l := &lint.Linter{}
+	if *only != "" {
+		for _, name := range strings.Split(*only, ",") {
+			if group := lint.Group(name); len(group) > 0 {
+				l.Analyzers = append(l.Analyzers, group...)
+				continue
+			}
+			a := lint.Lookup(name)
+			if a == nil {
+				fmt.Fprintf(os.Stderr, "unknown analyzer: %q\n", name)
+				os.Exit(2)
+			}
+			l.Analyzers = append(l.Analyzers, a)
+		}
+	}
+	if *shell != "" {
+		if l.Dialect = lint.LookupDialect(*shell); l.Dialect == nil {
+			fmt.Fprintf(os.Stderr, "unknown shell: %q\n", *shell)
+			os.Exit(2)
+		}
+	}
+	if *commands != "" {
+		f, err := os.Open(*commands)
+		if err == nil {
+			l.Commands, err = lint.ReadCommands(f)
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *hostPath {
+		l.Commands = append(l.Commands, lint.PathCommands(os.Getenv("PATH"))...)
+	}
+	if *check && (*apply || *watch) {
+		fmt.Fprintln(os.Stderr, "-check cannot be used with -apply or -watch")
+		os.Exit(2)
+	}
+	found, anyErr := false, false
+	sum := newSummary()
+	lintPath := func(path string) error {
+		fl, err := fileLinter(l, path)
+		if err != nil {
+			return err
+		}
+		f, diags, err := lintFile(fl, path)
+		if err == nil && (*apply || *patch) {
+			diags, err = fixFile(f, diags)
+		}
+		if err == nil && *check {
+			sum.add(path, diags)
+		}
+		for _, d := range diags {
+			if *patch {
+				fmt.Fprintln(os.Stderr, d)
+			} else {
+				fmt.Println(d)
+			}
+			found = true
+		}
+		return err
+	}
+	onError := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		anyErr = true
+		sum.errors++
+	}
+	if *watch {
+		// runs until shlint is interrupted
+		w := &walk.Watcher{Config: wc}
+		w.Run(flag.Args(), lintPath, onError, nil)
+	}
+	for _, path := range flag.Args() {
+		wc.Walk(path, lintPath, onError)
+	}
+	if *check {
+		sum.write(os.Stdout)
+	}
+	switch {
+	case anyErr:
+		os.Exit(2)
+	case found:
+		os.Exit(1)
+	}
+}
+
+// fileLinter returns the linter for a file, with the settings in the
+// configuration files of its project that the flags don't override.
+func fileLinter(l *lint.Linter, path string) (*lint.Linter, error) {
+	s, err := config.Find(path)
+	if err != nil {
+		return nil, err
+	}
+	fl := *l
+	if d := lint.LookupDialect(s.Variant()); d != nil && *shell == "" {
+		fl.Dialect = d
+	}
+	if *only == "" {
+		fl.Disable = s.LintDisable()
+	}
+	return &fl, nil
+}
+
+func lintFile(l *lint.Linter, path string) (*syntax.File, []lint.Diagnostic, error) {
+	if *source {
+		prog, err := loader.Config{Mode: syntax.ParseComments}.Load(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		l.Program = prog
+		return prog.Files[0], l.Lint(prog.Files[0]), nil
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := syntax.Parse(src, path, syntax.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, l.Lint(f), nil
+}
+
+// fixFile applies the fixes of the diagnostics to a file, or prints
+// them as a patch, and returns the diagnostics that weren't fixed.
+func fixFile(f *syntax.File, diags []lint.Diagnostic) ([]lint.Diagnostic, error) {
+	res, rest := lint.ApplyFixes(f.Source, diags)
+	if *patch {
+		os.Stdout.Write(diff.Unified(f.Name, f.Source, res))
+		return rest, nil
+	}
+	if len(rest) == len(diags) {
+		return rest, nil
+	}
+	info, err := os.Stat(f.Name)
+	if err != nil {
+		return nil, err
+	}
+	return rest, ioutil.WriteFile(f.Name, res, info.Mode())
+}
+
+func printMetrics(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := syntax.Parse(src, path, 0)
+	if err != nil {
+		return err
+	}
+	top, fms := analysis.MeasureFuncs(f, analysis.Funcs(f))
+	show := func(pos syntax.Pos, name string, m analysis.Metrics) {
+		p := f.Position(pos)
+		fmt.Printf("%s:%d:%d: %s\tcomplexity=%d depth=%d stmts=%d pipeline=%d\n",
+			path, p.Line, p.Column, name, m.Complexity, m.Depth, m.Stmts, m.Pipeline)
+	}
+	show(1, "(top level)", top)
+	for _, fm := range fms {
+		show(fm.Func.Pos(), fm.Func.Name, fm.Metrics)
+	}
+	return nil
+}
+
+func printGraph(path string) error {
+	if *graph == "includes" {
+		prog, err := loader.Load(path)
+		if err != nil {
+			return err
+		}
+		return dot.IncludeGraph(os.Stdout, prog)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := syntax.Parse(src, path, syntax.ParseComments)
+	if err != nil {
+		return err
+	}
+	if *graph == "ast" {
+		return dot.AST(os.Stdout, f)
+	}
+	return dot.CallGraph(os.Stdout, f, true)
+}
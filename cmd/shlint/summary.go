@@ -0,0 +1,73 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mvdan/sh/lint"
+)
+
+// summary counts the diagnostics of a run, for -check.
+type summary struct {
+	files, diags, errors int
+
+	// codes holds the analyzer name and count of each code
+	codes map[string]*codeCount
+	paths map[string]int
+}
+
+type codeCount struct {
+	analyzer string
+	n        int
+}
+
+func newSummary() *summary {
+	return &summary{
+		codes: make(map[string]*codeCount),
+		paths: make(map[string]int),
+	}
+}
+
+// add counts the diagnostics of a linted file.
+func (s *summary) add(path string, diags []lint.Diagnostic) {
+	s.files++
+	s.diags += len(diags)
+	for _, d := range diags {
+		cc := s.codes[d.Code]
+		if cc == nil {
+			cc = &codeCount{analyzer: d.Analyzer}
+			s.codes[d.Code] = cc
+		}
+		cc.n++
+		s.paths[path]++
+	}
+}
+
+// write prints the summary as tab-separated lines: one per code like
+// "rule SH1002 undefined 3", one per file with diagnostics like
+// "file a.sh 3", and "total" with the number of files, diagnostics and
+// errors.
+func (s *summary) write(w io.Writer) {
+	var codes []string
+	for code := range s.codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		cc := s.codes[code]
+		fmt.Fprintf(w, "rule\t%s\t%s\t%d\n", code, cc.analyzer, cc.n)
+	}
+	var paths []string
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(w, "file\t%s\t%d\n", path, s.paths[path])
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%d\n", s.files, s.diags, s.errors)
+}
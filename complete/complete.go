@@ -0,0 +1,360 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package complete finds the completions of partially typed command
+// lines, for interactive shells and editors.
+//
+// The line is parsed up to the cursor to find out what is being typed,
+// like a command name or a variable in "${", and the candidates come
+// from pluggable providers such as Files and Commands.
+package complete
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Kind is the kind of word being completed.
+type Kind int
+
+const (
+	None     Kind = iota // nothing to complete, like inside a comment
+	Command              // the name of a command
+	Argument             // an argument to a command, or an assigned value
+	Variable             // the name of a variable, like after "$" or "${"
+	Redirect             // the file of a redirect, like after ">"
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Command:
+		return "command"
+	case Argument:
+		return "argument"
+	case Variable:
+		return "variable"
+	case Redirect:
+		return "redirect"
+	}
+	return "none"
+}
+
+// Context is what is being typed at the cursor.
+type Context struct {
+	Kind Kind
+
+	// Prefix is the part of the word before the cursor, without
+	// quotes nor escapes. Expansions that can't be resolved without
+	// running the program, like "$HOME", are kept as they were typed.
+	// For Variable, it's the part of the name that was typed.
+	Prefix string
+
+	// Start and End are the byte offsets of the word's text before
+	// the cursor, which is where End is.
+	Start, End int
+
+	// Args are the fields before the word being completed for
+	// Argument, starting with the command name. They are nil for
+	// assigned values.
+	Args []string
+
+	// Quote is the quote that the word is in at the cursor, if any,
+	// like '"'.
+	Quote byte
+
+	// brace is set for variables in "${"
+	brace bool
+}
+
+// Candidate is a possible completion of a word.
+type Candidate struct {
+	// Text is the whole word, without quotes.
+	Text string
+
+	// Desc optionally describes the candidate, like "builtin".
+	Desc string
+
+	// Partial is set when the candidate isn't a whole word, like a
+	// directory with a trailing slash, so that the word isn't ended
+	// when it's chosen.
+	Partial bool
+}
+
+// Provider lists the candidates for a context. It may return candidates
+// that don't start with the context's prefix, as the Completer filters
+// them.
+type Provider interface {
+	Complete(ctx *Context) []Candidate
+}
+
+// ProviderFunc is a Provider implemented by a function.
+type ProviderFunc func(ctx *Context) []Candidate
+
+// Complete calls f(ctx).
+func (f ProviderFunc) Complete(ctx *Context) []Candidate { return f(ctx) }
+
+// Completer completes command lines with the candidates of its
+// providers.
+type Completer struct {
+	Providers []Provider
+}
+
+// Complete returns the context at the cursor, which is a byte offset in
+// line, along with the candidates of all the providers that start with
+// the prefix. The candidates are sorted, and only the first of those
+// with the same text is kept.
+func (c *Completer) Complete(line string, cursor int) (*Context, []Candidate) {
+	ctx := Parse(line, cursor)
+	if ctx.Kind == None {
+		return ctx, nil
+	}
+	var cands []Candidate
+	seen := make(map[string]bool)
+	for _, p := range c.Providers {
+		for _, cand := range p.Complete(ctx) {
+			if seen[cand.Text] || !strings.HasPrefix(cand.Text, ctx.Prefix) {
+				continue
+			}
+			seen[cand.Text] = true
+			cands = append(cands, cand)
+		}
+	}
+	sort.Sort(byText(cands))
+	return ctx, cands
+}
+
+type byText []Candidate
+
+func (b byText) Len() int           { return len(b) }
+func (b byText) Less(i, j int) bool { return b[i].Text < b[j].Text }
+func (b byText) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// cursorMark is added at the cursor to find it in the syntax tree. It
+// is made of name characters, so that it joins variable names.
+const cursorMark = "_sh_complete_cursor_"
+
+// Parse finds out what is being typed at the cursor, which is a byte
+// offset in line. Only the line up to the cursor is parsed, and
+// unclosed constructs like quotes and if clauses are allowed.
+func Parse(line string, cursor int) *Context {
+	if cursor < 0 || cursor > len(line) {
+		cursor = len(line)
+	}
+	ctx := &Context{Start: cursor, End: cursor}
+	src := line[:cursor] + cursorMark
+	f, _ := syntax.Parse([]byte(src), "", 0)
+	path := findMark(f)
+	if path == nil {
+		// a single quote takes the rest of the source with it, so
+		// close it
+		f, _ = syntax.Parse([]byte(src+"'"), "", 0)
+		if path = findMark(f); path == nil {
+			return ctx
+		}
+	}
+	mark := path[len(path)-1]
+	if pe, ok := path[len(path)-2].(*syntax.ParamExp); ok && pe.Param == mark {
+		lit := mark.(*syntax.Lit)
+		ctx.Kind, ctx.brace = Variable, !pe.Short
+		ctx.Start = int(lit.Pos()) - 1
+		ctx.Prefix = strings.TrimSuffix(lit.Value, cursorMark)
+		return ctx
+	}
+	i := len(path) - 2
+	if _, ok := path[i].(*syntax.DblQuoted); ok {
+		i--
+	}
+	word, ok := path[i].(*syntax.Word)
+	if !ok {
+		return ctx
+	}
+	ctx.Start = int(word.Pos()) - 1
+	ctx.Prefix, ctx.Quote = wordPrefix(src, word)
+	switch x := path[i-1].(type) {
+	case *syntax.CallExpr:
+		if x.Args[0] == word {
+			ctx.Kind = Command
+			break
+		}
+		ctx.Kind = Argument
+		for _, w := range x.Args {
+			if w == word {
+				break
+			}
+			arg, _ := wordPrefix(src, w)
+			ctx.Args = append(ctx.Args, arg)
+		}
+	case *syntax.Redirect:
+		if x.Word == word {
+			ctx.Kind = Redirect
+		}
+	case *syntax.DeclClause:
+		ctx.Kind = Argument
+		ctx.Args = declArgs(src, x)
+	case *syntax.Assign:
+		ctx.Kind = Argument
+		if dc, ok := path[i-2].(*syntax.DeclClause); ok {
+			ctx.Args = declArgs(src, dc)
+		}
+	case *syntax.BinaryArithm, *syntax.UnaryArithm, *syntax.ParenArithm,
+		*syntax.ArithmExp, *syntax.ArithmCmd, *syntax.LetClause:
+		// in arithmetic, names are variables
+		ctx.Kind = Variable
+	default:
+		ctx.Kind = Argument
+	}
+	return ctx
+}
+
+// findMark returns the path of nodes from the file to the node with the
+// cursor mark, or nil if there isn't one.
+func findMark(f *syntax.File) []syntax.Node {
+	if f == nil {
+		return nil
+	}
+	v := &markVisitor{}
+	syntax.Walk(v, f)
+	return v.found
+}
+
+type markVisitor struct {
+	stack, found []syntax.Node
+}
+
+func (v *markVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node == nil {
+		v.stack = v.stack[:len(v.stack)-1]
+		return v
+	}
+	if v.found != nil {
+		return nil
+	}
+	v.stack = append(v.stack, node)
+	value := ""
+	switch x := node.(type) {
+	case *syntax.Lit:
+		value = x.Value
+	case *syntax.SglQuoted:
+		value = x.Value
+	}
+	if strings.HasSuffix(value, cursorMark) {
+		v.found = append([]syntax.Node(nil), v.stack...)
+	}
+	return v
+}
+
+func declArgs(src string, dc *syntax.DeclClause) []string {
+	name := dc.Variant
+	if name == "" {
+		name = "declare"
+	}
+	args := []string{name}
+	for _, w := range dc.Opts {
+		arg, _ := wordPrefix(src, w)
+		args = append(args, arg)
+	}
+	return args
+}
+
+// wordPrefix returns the text of a word without quotes nor escapes, up
+// to the cursor mark if it's in the word, along with the quote that the
+// mark is in.
+func wordPrefix(src string, word *syntax.Word) (string, byte) {
+	var buf bytes.Buffer
+	quote := byte(0)
+	for _, part := range word.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			buf.WriteString(unescape(x.Value, ""))
+		case *syntax.SglQuoted:
+			if strings.HasSuffix(x.Value, cursorMark) {
+				quote = '\''
+			}
+			buf.WriteString(x.Value)
+		case *syntax.DblQuoted:
+			for _, part := range x.Parts {
+				if lit, ok := part.(*syntax.Lit); ok {
+					if strings.HasSuffix(lit.Value, cursorMark) {
+						quote = '"'
+					}
+					buf.WriteString(unescape(lit.Value, "\"\\$`"))
+				} else {
+					buf.WriteString(src[part.Pos()-1 : part.End()-1])
+				}
+			}
+		default:
+			buf.WriteString(src[part.Pos()-1 : part.End()-1])
+		}
+	}
+	s := buf.String()
+	if i := strings.Index(s, cursorMark); i >= 0 {
+		s = s[:i]
+	}
+	return s, quote
+}
+
+// unescape removes the backslashes that escape characters. If only is
+// not empty, only the characters in it can be escaped, like in double
+// quotes.
+func unescape(s, only string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) &&
+			(only == "" || strings.IndexByte(only, s[i+1]) >= 0) {
+			i++
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// Apply returns the line with a candidate chosen at the cursor, along
+// with the new cursor. The rest of the candidate after the prefix is
+// added, quoted like the word, followed by the end of the word unless
+// the candidate is Partial.
+func (c *Context) Apply(line string, cand Candidate) (string, int) {
+	rest := strings.TrimPrefix(cand.Text, c.Prefix)
+	var buf bytes.Buffer
+	buf.WriteString(line[:c.End])
+	switch {
+	case c.Kind == Variable:
+		buf.WriteString(rest)
+		if c.brace && !cand.Partial {
+			buf.WriteByte('}')
+		}
+	case c.Quote == '\'':
+		buf.WriteString(strings.Replace(rest, "'", `'\''`, -1))
+	case c.Quote == '"':
+		for i := 0; i < len(rest); i++ {
+			if strings.IndexByte("\"\\$`", rest[i]) >= 0 {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(rest[i])
+		}
+	default:
+		for i := 0; i < len(rest); i++ {
+			b := rest[i]
+			start := c.Start == c.End && i == 0
+			if strings.IndexByte(" \t\n'\"\\$`|&;<>()*?[]{}!", b) >= 0 ||
+				(start && (b == '#' || b == '~')) {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(b)
+		}
+	}
+	if c.Kind != Variable && !cand.Partial {
+		if c.Quote != 0 {
+			buf.WriteByte(c.Quote)
+		}
+		buf.WriteByte(' ')
+	}
+	cursor := buf.Len()
+	buf.WriteString(line[c.End:])
+	return buf.String(), cursor
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package complete
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mvdan/sh/expand"
+)
+
+var parseTests = []struct {
+	in   string
+	want Context
+}{
+	{"", Context{Kind: Command}},
+	{"ec", Context{Kind: Command, Prefix: "ec"}},
+	{"echo ", Context{Kind: Argument, Start: 5, Args: []string{"echo"}}},
+	{"echo foo ba", Context{Kind: Argument, Prefix: "ba", Start: 9, Args: []string{"echo", "foo"}}},
+	{"echo 'a b", Context{Kind: Argument, Prefix: "a b", Start: 5, Args: []string{"echo"}, Quote: '\''}},
+	{`echo "a b`, Context{Kind: Argument, Prefix: "a b", Start: 5, Args: []string{"echo"}, Quote: '"'}},
+	{`echo a\ b`, Context{Kind: Argument, Prefix: "a b", Start: 5, Args: []string{"echo"}}},
+	{`echo "$HOME/fo`, Context{Kind: Argument, Prefix: "$HOME/fo", Start: 5, Args: []string{"echo"}, Quote: '"'}},
+	{"echo $FO", Context{Kind: Variable, Prefix: "FO", Start: 6}},
+	{"echo ${FO", Context{Kind: Variable, Prefix: "FO", Start: 7, brace: true}},
+	{`echo "$`, Context{Kind: Variable, Start: 7}},
+	{"let FO", Context{Kind: Variable, Prefix: "FO", Start: 4}},
+	{"declare -x fo", Context{Kind: Argument, Prefix: "fo", Start: 11, Args: []string{"declare", "-x"}}},
+	{"foo > ba", Context{Kind: Redirect, Prefix: "ba", Start: 6}},
+	{"foo 2>>ba", Context{Kind: Redirect, Prefix: "ba", Start: 7}},
+	{"foo && ba", Context{Kind: Command, Prefix: "ba", Start: 7}},
+	{"foo | ba", Context{Kind: Command, Prefix: "ba", Start: 6}},
+	{"if foo; then ec", Context{Kind: Command, Prefix: "ec", Start: 13}},
+	{"while a; do b x", Context{Kind: Argument, Prefix: "x", Start: 14, Args: []string{"b"}}},
+	{"echo $(ec", Context{Kind: Command, Prefix: "ec", Start: 7}},
+	{"echo `ec", Context{Kind: Command, Prefix: "ec", Start: 6}},
+	{"x=fo", Context{Kind: Argument, Prefix: "fo", Start: 2}},
+	{"x=1 ec", Context{Kind: Command, Prefix: "ec", Start: 4}},
+	{"echo foo # ba", Context{Start: 13, End: 13}},
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	for i, tc := range parseTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			want := tc.want
+			want.End = len(tc.in)
+			if want.Kind == None {
+				want.Start = len(tc.in)
+			}
+			got := Parse(tc.in, len(tc.in))
+			if !reflect.DeepEqual(*got, want) {
+				t.Fatalf("wrong context in %q:\nwant: %+v\ngot:  %+v",
+					tc.in, want, *got)
+			}
+		})
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	t.Parallel()
+	got := Parse("echo fo bar", 7)
+	want := Context{Kind: Argument, Prefix: "fo", Start: 5, End: 7, Args: []string{"echo"}}
+	if !reflect.DeepEqual(*got, want) {
+		t.Fatalf("wrong context:\nwant: %+v\ngot:  %+v", want, *got)
+	}
+}
+
+var applyTests = []struct {
+	in   string
+	cand Candidate
+	want string
+}{
+	{"ec|", Candidate{Text: "echo"}, "echo |"},
+	{"ec| bar", Candidate{Text: "echo"}, "echo | bar"},
+	{"cat fo|", Candidate{Text: "foo bar"}, `cat foo\ bar |`},
+	{"cat 'fo|", Candidate{Text: "foo's"}, `cat 'foo'\''s' |`},
+	{`cat "fo|`, Candidate{Text: "foo$"}, `cat "foo\$" |`},
+	{"cat |", Candidate{Text: "#foo"}, `cat \#foo |`},
+	{"cat di|", Candidate{Text: "dir/", Partial: true}, "cat dir/|"},
+	{"echo $FO|", Candidate{Text: "FOO"}, "echo $FOO|"},
+	{"echo ${FO|", Candidate{Text: "FOO"}, "echo ${FOO}|"},
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	for i, tc := range applyTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			cursor := strings.IndexByte(tc.in, '|')
+			in := tc.in[:cursor] + tc.in[cursor+1:]
+			ctx := Parse(in, cursor)
+			line, cursor := ctx.Apply(in, tc.cand)
+			got := line[:cursor] + "|" + line[cursor:]
+			if got != tc.want {
+				t.Fatalf("wrong line in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "complete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"bin", "src", ".hidden"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]os.FileMode{
+		"bin/foo":   0777,
+		"bin/fox":   0666,
+		"src/a.sh":  0666,
+		"src/b.sh":  0666,
+		"script.sh": 0777,
+	}
+	for name, mode := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &Completer{Providers: []Provider{
+		Keywords,
+		Words(Command, "builtin", "echo", "exit"),
+		Commands(filepath.Join(dir, "bin")),
+		Files(dir),
+		Env(expand.ListEnviron("FOO=1", "FOOBAR=2", "BAR=3")),
+	}}
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"e", []string{"echo", "elif", "else", "esac", "exit"}},
+		{"fo", []string{"foo", "for"}},
+		{"./", []string{"./bin/", "./script.sh", "./src/"}},
+		{"cat s", []string{"script.sh", "src/"}},
+		{"cat src/", []string{"src/a.sh", "src/b.sh"}},
+		{"cat .", []string{".hidden/"}},
+		{"cat > src/a", []string{"src/a.sh"}},
+		{"echo $FO", []string{"FOO", "FOOBAR"}},
+		{"echo # e", nil},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, cands := c.Complete(tc.in, len(tc.in))
+			var got []string
+			for _, cand := range cands {
+				got = append(got, cand.Text)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("wrong candidates in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package complete
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mvdan/sh/expand"
+)
+
+// Words returns a provider of a fixed list of words for one kind of
+// context, like the names of some commands.
+func Words(kind Kind, desc string, words ...string) Provider {
+	return ProviderFunc(func(ctx *Context) []Candidate {
+		if ctx.Kind != kind {
+			return nil
+		}
+		cands := make([]Candidate, len(words))
+		for i, word := range words {
+			cands[i] = Candidate{Text: word, Desc: desc}
+		}
+		return cands
+	})
+}
+
+// Keywords provides the reserved words that can start a command.
+var Keywords = Words(Command, "keyword", "if", "then", "elif", "else",
+	"fi", "while", "until", "for", "do", "done", "case", "esac",
+	"function", "select", "time", "coproc", "{", "}", "[[", "]]", "!")
+
+// Files returns a provider of the files matching the typed path, which
+// is relative to dir if it isn't absolute. It completes arguments and
+// redirects, and command names that contain a slash, where only
+// directories and executables are listed. Directories have a trailing
+// slash, and hidden files are only listed if the name starts with a
+// dot.
+func Files(dir string) Provider {
+	return ProviderFunc(func(ctx *Context) []Candidate {
+		switch ctx.Kind {
+		case Argument, Redirect:
+		case Command:
+			if !strings.Contains(ctx.Prefix, "/") {
+				return nil
+			}
+		default:
+			return nil
+		}
+		pdir, base := "", ctx.Prefix
+		if i := strings.LastIndexByte(ctx.Prefix, '/'); i >= 0 {
+			pdir, base = ctx.Prefix[:i+1], ctx.Prefix[i+1:]
+		}
+		lookup := pdir
+		if !filepath.IsAbs(lookup) {
+			lookup = filepath.Join(dir, lookup)
+		}
+		infos, err := ioutil.ReadDir(lookup)
+		if err != nil {
+			return nil
+		}
+		var cands []Candidate
+		for _, info := range infos {
+			name := info.Name()
+			if !strings.HasPrefix(name, base) ||
+				(name[0] == '.' && !strings.HasPrefix(base, ".")) {
+				continue
+			}
+			if info.IsDir() {
+				cands = append(cands, Candidate{Text: pdir + name + "/", Partial: true})
+			} else if ctx.Kind != Command || isExecutable(info) {
+				cands = append(cands, Candidate{Text: pdir + name})
+			}
+		}
+		return cands
+	})
+}
+
+// Commands returns a provider of the executables in the directories of
+// a list like $PATH.
+func Commands(path string) Provider {
+	return ProviderFunc(func(ctx *Context) []Candidate {
+		if ctx.Kind != Command || strings.Contains(ctx.Prefix, "/") {
+			return nil
+		}
+		var cands []Candidate
+		for _, dir := range filepath.SplitList(path) {
+			infos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, info := range infos {
+				name := info.Name()
+				if strings.HasPrefix(name, ctx.Prefix) && !info.IsDir() && isExecutable(info) {
+					cands = append(cands, Candidate{Text: name, Desc: "command"})
+				}
+			}
+		}
+		return cands
+	})
+}
+
+func isExecutable(info os.FileInfo) bool {
+	return info.Mode()&0111 != 0
+}
+
+// Env returns a provider of the names of the variables that are set in
+// an environment.
+func Env(env expand.Environ) Provider {
+	return ProviderFunc(func(ctx *Context) []Candidate {
+		if ctx.Kind != Variable {
+			return nil
+		}
+		var cands []Candidate
+		env.Each(func(name string, vr expand.Variable) bool {
+			if strings.HasPrefix(name, ctx.Prefix) {
+				cands = append(cands, Candidate{Text: name, Desc: "variable"})
+			}
+			return true
+		})
+		return cands
+	})
+}
@@ -0,0 +1,444 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package cond evaluates conditional expressions, like the ones in a
+// Bash [[ ]] clause or in the arguments to the test builtin.
+package cond
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/pattern"
+	"github.com/mvdan/sh/syntax"
+)
+
+// FS is the file system that file predicates like -f are evaluated
+// against.
+type FS interface {
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// DirFS returns an FS backed by the operating system, where relative
+// paths are relative to dir.
+func DirFS(dir string) FS { return dirFS(dir) }
+
+type dirFS string
+
+func (d dirFS) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(string(d), name)
+}
+
+func (d dirFS) Stat(name string) (os.FileInfo, error)  { return os.Stat(d.path(name)) }
+func (d dirFS) Lstat(name string) (os.FileInfo, error) { return os.Lstat(d.path(name)) }
+
+// Config specifies how conditional expressions are evaluated.
+type Config struct {
+	// Expand is used to expand the words in a [[ ]] expression, and
+	// its Env holds the variables that -v checks and that
+	// BASH_REMATCH is set in.
+	Expand expand.Config
+
+	// FS is used for the file predicates. If nil, DirFS(".") is
+	// used.
+	FS FS
+}
+
+// EvalError is returned when an expression cannot be evaluated, such
+// as when a regular expression is invalid. Shells use an exit status
+// of 2 in that case.
+type EvalError struct {
+	Text string
+}
+
+func (e *EvalError) Error() string { return e.Text }
+
+func evalErrf(format string, a ...interface{}) error {
+	return &EvalError{Text: fmt.Sprintf(format, a...)}
+}
+
+// Eval evaluates an expression from a [[ ]] clause.
+//
+// The words on the right of == and != are patterns, the ones on the
+// right of =~ are regular expressions, and the operands of numeric
+// comparisons like -eq are arithmetic expressions. If a regular
+// expression matches, BASH_REMATCH is set to an indexed array holding
+// the matched string followed by the text of each group.
+func (c Config) Eval(expr syntax.TestExpr) (bool, error) {
+	e := &evaluator{cfg: c}
+	b := e.testExpr(expr)
+	return b, e.err
+}
+
+type evaluator struct {
+	cfg Config
+	err error
+}
+
+func (e *evaluator) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *evaluator) fs() FS {
+	if e.cfg.FS == nil {
+		return dirFS(".")
+	}
+	return e.cfg.FS
+}
+
+func (e *evaluator) word(expr syntax.TestExpr) *syntax.Word {
+	w, ok := expr.(*syntax.Word)
+	if !ok {
+		e.fail(evalErrf("expected a word, found %T", expr))
+		return &syntax.Word{}
+	}
+	return w
+}
+
+func (e *evaluator) literal(expr syntax.TestExpr) string {
+	s, err := e.cfg.Expand.Literal(e.word(expr))
+	if err != nil {
+		e.fail(err)
+	}
+	return s
+}
+
+func (e *evaluator) arithm(expr syntax.TestExpr) int {
+	n, err := e.cfg.Expand.Arithm(e.word(expr))
+	if err != nil {
+		e.fail(err)
+	}
+	return n
+}
+
+func (e *evaluator) testExpr(expr syntax.TestExpr) bool {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return e.literal(x) != ""
+	case *syntax.ParenTest:
+		return e.testExpr(x.X)
+	case *syntax.BinaryTest:
+		switch x.Op {
+		case syntax.AndTest:
+			return e.testExpr(x.X) && e.testExpr(x.Y)
+		case syntax.OrTest:
+			return e.testExpr(x.X) || e.testExpr(x.Y)
+		case syntax.TsAssgn, syntax.TsEqual:
+			return e.match(e.word(x.Y), e.literal(x.X))
+		case syntax.TsNequal:
+			return !e.match(e.word(x.Y), e.literal(x.X))
+		case syntax.TsReMatch:
+			return e.reMatch(e.literal(x.X), e.regexp(e.word(x.Y)))
+		case syntax.TsEql, syntax.TsNeq, syntax.TsLeq,
+			syntax.TsGeq, syntax.TsLss, syntax.TsGtr:
+			return numTest(x.Op, e.arithm(x.X), e.arithm(x.Y))
+		}
+		return e.binTest(x.Op, e.literal(x.X), e.literal(x.Y))
+	case *syntax.UnaryTest:
+		if x.Op == syntax.TsNot {
+			return !e.testExpr(x.X)
+		}
+		return e.unTest(x.Op, e.literal(x.X))
+	}
+	e.fail(evalErrf("unexpected test expr: %T", expr))
+	return false
+}
+
+func (e *evaluator) match(w *syntax.Word, s string) bool {
+	pat, err := e.cfg.Expand.Pattern(w)
+	if err != nil {
+		e.fail(err)
+		return false
+	}
+	return pattern.Match(pat, s, pattern.ExtGlob)
+}
+
+// regexp expands a word into a regular expression, where the quoted
+// parts only match themselves.
+func (e *evaluator) regexp(w *syntax.Word) string {
+	var buf bytes.Buffer
+	for _, wp := range w.Parts {
+		s, err := e.cfg.Expand.Literal(&syntax.Word{Parts: []syntax.WordPart{wp}})
+		if err != nil {
+			e.fail(err)
+			return ""
+		}
+		switch wp.(type) {
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			s = regexp.QuoteMeta(s)
+		}
+		buf.WriteString(s)
+	}
+	return buf.String()
+}
+
+func (e *evaluator) reMatch(s, expr string) bool {
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		e.fail(evalErrf("invalid regex %q: %v", expr, err))
+		return false
+	}
+	groups := rx.FindStringSubmatch(s)
+	if groups == nil {
+		return false
+	}
+	if env := e.cfg.Expand.Env; env != nil {
+		vr := expand.Variable{Set: true, List: groups}
+		if err := env.Set("BASH_REMATCH", vr); err != nil {
+			e.fail(err)
+		}
+	}
+	return true
+}
+
+func numTest(op syntax.BinTestOperator, x, y int) bool {
+	switch op {
+	case syntax.TsEql:
+		return x == y
+	case syntax.TsNeq:
+		return x != y
+	case syntax.TsLeq:
+		return x <= y
+	case syntax.TsGeq:
+		return x >= y
+	case syntax.TsLss:
+		return x < y
+	default: // syntax.TsGtr
+		return x > y
+	}
+}
+
+func (e *evaluator) binTest(op syntax.BinTestOperator, x, y string) bool {
+	switch op {
+	case syntax.TsNewer:
+		i1, i2 := e.stat(x), e.stat(y)
+		return i1 != nil && (i2 == nil || i1.ModTime().After(i2.ModTime()))
+	case syntax.TsOlder:
+		i1, i2 := e.stat(x), e.stat(y)
+		return i2 != nil && (i1 == nil || i1.ModTime().Before(i2.ModTime()))
+	case syntax.TsDevIno:
+		i1, i2 := e.stat(x), e.stat(y)
+		return i1 != nil && i2 != nil && os.SameFile(i1, i2)
+	case syntax.TsAssgn, syntax.TsEqual:
+		return x == y
+	case syntax.TsNequal:
+		return x != y
+	case syntax.TsBefore:
+		return x < y
+	case syntax.TsAfter:
+		return x > y
+	}
+	e.fail(evalErrf("unexpected test operator: %s", op))
+	return false
+}
+
+func (e *evaluator) stat(name string) os.FileInfo {
+	info, err := e.fs().Stat(name)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+func (e *evaluator) statMode(name string, mode os.FileMode) bool {
+	info := e.stat(name)
+	return info != nil && info.Mode()&mode != 0
+}
+
+func (e *evaluator) unTest(op syntax.UnTestOperator, x string) bool {
+	switch op {
+	case syntax.TsExists:
+		return e.stat(x) != nil
+	case syntax.TsRegFile:
+		info := e.stat(x)
+		return info != nil && info.Mode().IsRegular()
+	case syntax.TsDirect:
+		return e.statMode(x, os.ModeDir)
+	case syntax.TsCharSp:
+		return e.statMode(x, os.ModeCharDevice)
+	case syntax.TsBlckSp:
+		info := e.stat(x)
+		return info != nil && info.Mode()&os.ModeDevice != 0 &&
+			info.Mode()&os.ModeCharDevice == 0
+	case syntax.TsNmPipe:
+		return e.statMode(x, os.ModeNamedPipe)
+	case syntax.TsSocket:
+		return e.statMode(x, os.ModeSocket)
+	case syntax.TsSmbLink:
+		info, err := e.fs().Lstat(x)
+		return err == nil && info.Mode()&os.ModeSymlink != 0
+	case syntax.TsGIDSet:
+		return e.statMode(x, os.ModeSetgid)
+	case syntax.TsUIDSet:
+		return e.statMode(x, os.ModeSetuid)
+	// The permission checks only use the mode bits, as the owner of
+	// a file in an FS is unknown.
+	case syntax.TsRead:
+		return e.statMode(x, 0444)
+	case syntax.TsWrite:
+		return e.statMode(x, 0222)
+	case syntax.TsExec:
+		return e.statMode(x, 0111)
+	case syntax.TsNoEmpty:
+		info := e.stat(x)
+		return info != nil && info.Size() > 0
+	case syntax.TsFdTerm:
+		// terminals aren't supported
+		return false
+	case syntax.TsEmpStr:
+		return x == ""
+	case syntax.TsNempStr:
+		return x != ""
+	case syntax.TsVarSet:
+		if env := e.cfg.Expand.Env; env != nil {
+			return env.Get(x).Set
+		}
+		return false
+	}
+	// TsOptSet and TsRefVar
+	return false
+}
+
+var (
+	unaryOps  = make(map[string]syntax.UnTestOperator)
+	binaryOps = make(map[string]syntax.BinTestOperator)
+)
+
+func init() {
+	for op := syntax.TsExists; op <= syntax.TsRefVar; op++ {
+		unaryOps[op.String()] = op
+	}
+	unaryOps["-h"] = syntax.TsSmbLink
+	for op := syntax.TsNewer; op <= syntax.TsGtr; op++ {
+		binaryOps[op.String()] = op
+	}
+	for _, op := range []syntax.BinTestOperator{
+		syntax.TsAssgn, syntax.TsEqual, syntax.TsNequal,
+		syntax.TsBefore, syntax.TsAfter,
+	} {
+		binaryOps[op.String()] = op
+	}
+}
+
+// EvalArgs evaluates the arguments to the test builtin, excluding the
+// closing "]" of the [ form.
+//
+// Unlike with Eval, the arguments are already expanded, == and != are
+// plain string comparisons, and the operands of numeric comparisons
+// must be integers. The -a and -o operators are logical and and or.
+func (c Config) EvalArgs(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	a := &argEvaluator{e: evaluator{cfg: c}, args: args}
+	b := a.or()
+	if a.e.err == nil && a.pos < len(a.args) {
+		a.e.fail(evalErrf("unexpected argument: %q", a.args[a.pos]))
+	}
+	return b, a.e.err
+}
+
+type argEvaluator struct {
+	e    evaluator
+	args []string
+	pos  int
+}
+
+func (a *argEvaluator) peek(n int) string {
+	if a.pos+n < len(a.args) {
+		return a.args[a.pos+n]
+	}
+	return ""
+}
+
+func (a *argEvaluator) next() string {
+	s := a.peek(0)
+	a.pos++
+	return s
+}
+
+func (a *argEvaluator) left() int { return len(a.args) - a.pos }
+
+func (a *argEvaluator) or() bool {
+	b := a.and()
+	for a.left() > 0 && a.peek(0) == "-o" {
+		a.pos++
+		// evaluate both sides to consume the arguments
+		b2 := a.and()
+		b = b || b2
+	}
+	return b
+}
+
+func (a *argEvaluator) and() bool {
+	b := a.not()
+	for a.left() > 0 && a.peek(0) == "-a" {
+		a.pos++
+		b2 := a.not()
+		b = b && b2
+	}
+	return b
+}
+
+func (a *argEvaluator) not() bool {
+	// a lone "!" or a "!" being compared is a string
+	if a.peek(0) == "!" && a.left() > 1 && !a.isBinary(1) {
+		a.pos++
+		return !a.not()
+	}
+	return a.primary()
+}
+
+func (a *argEvaluator) isBinary(n int) bool {
+	_, ok := binaryOps[a.peek(n)]
+	return ok && a.left() > n+1
+}
+
+func (a *argEvaluator) primary() bool {
+	if a.left() == 0 {
+		a.e.fail(evalErrf("argument expected"))
+		return false
+	}
+	if a.isBinary(1) {
+		x := a.next()
+		op := binaryOps[a.next()]
+		y := a.next()
+		if op >= syntax.TsEql && op <= syntax.TsGtr {
+			return numTest(op, a.integer(x), a.integer(y))
+		}
+		return a.e.binTest(op, x, y)
+	}
+	if a.peek(0) == "(" && a.left() > 1 && !a.isBinary(0) {
+		a.pos++
+		b := a.or()
+		if a.next() != ")" {
+			a.e.fail(evalErrf("missing ')'"))
+		}
+		return b
+	}
+	if op, ok := unaryOps[a.peek(0)]; ok && a.left() > 1 {
+		a.pos++
+		return a.e.unTest(op, a.next())
+	}
+	return a.next() != ""
+}
+
+func (a *argEvaluator) integer(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		a.e.fail(evalErrf("%s: integer expression expected", s))
+	}
+	return n
+}
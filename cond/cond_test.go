@@ -0,0 +1,238 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package cond
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// mapEnv is an Environ of string variables, to keep the tests short.
+type mapEnv map[string]string
+
+func (m mapEnv) Get(name string) expand.Variable {
+	val, ok := m[name]
+	return expand.Variable{Set: ok, Value: val}
+}
+
+func (m mapEnv) Set(name string, vr expand.Variable) error {
+	m[name] = vr.Value
+	return nil
+}
+
+func (m mapEnv) Each(fn func(name string, vr expand.Variable) bool) {
+	for name, val := range m {
+		if !fn(name, expand.Variable{Set: true, Value: val}) {
+			return
+		}
+	}
+}
+
+// listEnv records the indexed arrays set in an Environ.
+type listEnv struct {
+	expand.Environ
+	lists map[string][]string
+}
+
+func (l *listEnv) Set(name string, vr expand.Variable) error {
+	if l.lists == nil {
+		l.lists = make(map[string][]string)
+	}
+	l.lists[name] = vr.List
+	return l.Environ.Set(name, vr)
+}
+
+type fileInfo struct {
+	os.FileInfo
+	name  string
+	mode  os.FileMode
+	size  int64
+	mtime int
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Mode() os.FileMode  { return f.mode }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) ModTime() time.Time { return time.Unix(int64(f.mtime), 0) }
+func (f fileInfo) IsDir() bool        { return f.mode.IsDir() }
+
+// mapFS is a file system where every file is at the root.
+type mapFS map[string]fileInfo
+
+func (m mapFS) Lstat(name string) (os.FileInfo, error) {
+	info, ok := m[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return info, nil
+}
+
+func (m mapFS) Stat(name string) (os.FileInfo, error) {
+	info, err := m.Lstat(name)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return m.Lstat("file")
+	}
+	return info, err
+}
+
+func testConfig() Config {
+	return Config{
+		Expand: expand.Config{Env: mapEnv{
+			"foo":   "bar",
+			"empty": "",
+			"n":     "3",
+		}},
+		FS: mapFS{
+			"file":  {name: "file", mode: 0644, size: 3, mtime: 2},
+			"empty": {name: "empty", mode: 0755, mtime: 1},
+			"dir":   {name: "dir", mode: os.ModeDir | 0755, mtime: 3},
+			"link":  {name: "link", mode: os.ModeSymlink | 0777},
+		},
+	}
+}
+
+var evalTests = []struct {
+	in   string
+	want bool
+}{
+	{"a", true},
+	{"''", false},
+	{"$empty", false},
+	{"-n $foo && -z $empty", true},
+	{"! -n $foo", false},
+	{"-v foo && ! -v unset", true},
+	{"$foo == b*", true},
+	{"$foo == 'b*'", false},
+	{"$foo = b?r", true},
+	{"$foo != bar", false},
+	{"a < b && b > a", true},
+	{"(a == b) || a == a", true},
+	{"n -eq 3 && 4 -gt $n && 1+2 -le n", true},
+	{"010 -eq 8", true},
+	{"$foo =~ ^b.r$", true},
+	{"$foo =~ 'b.r'", false},
+	{"-e file && -f file && ! -d file", true},
+	{"-d dir && ! -f dir && ! -e nosuch", true},
+	{"-s file && ! -s empty", true},
+	{"-L link && ! -L file && -f link", true},
+	{"-x empty && ! -x file && -r file && -w file", true},
+	{"file -nt empty && empty -ot dir && file -nt nosuch", true},
+	{"! dir -ef file && ! nosuch -ef nosuch", true},
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+	for i, tc := range evalTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			src := "[[ " + tc.in + " ]]"
+			f, err := syntax.Parse([]byte(src), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expr := f.Stmts[0].Cmd.(*syntax.TestClause).X
+			got, err := testConfig().Eval(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Eval mismatch in %q\nwant: %t\ngot:  %t",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalRematch(t *testing.T) {
+	t.Parallel()
+	f, err := syntax.Parse([]byte("[[ foobar =~ o+(b)(x)? ]]"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig()
+	env := &listEnv{Environ: cfg.Expand.Env}
+	cfg.Expand.Env = env
+	if ok, err := cfg.Eval(f.Stmts[0].Cmd.(*syntax.TestClause).X); !ok || err != nil {
+		t.Fatalf("expected a match, got %t and %v", ok, err)
+	}
+	want := []string{"oob", "b", ""}
+	if got := env.lists["BASH_REMATCH"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("BASH_REMATCH mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+var evalArgsTests = []struct {
+	args []string
+	want bool
+}{
+	{nil, false},
+	{[]string{""}, false},
+	{[]string{"a"}, true},
+	{[]string{"-n"}, true},
+	{[]string{"!"}, true},
+	{[]string{"!", ""}, true},
+	{[]string{"!", "a"}, false},
+	{[]string{"-z", ""}, true},
+	{[]string{"-f", "file"}, true},
+	{[]string{"-d", "file"}, false},
+	{[]string{"a", "=", "a"}, true},
+	{[]string{"a*", "==", "abc"}, false},
+	{[]string{"!", "=", "!"}, true},
+	{[]string{"3", "-lt", "10"}, true},
+	{[]string{"a", "-a", ""}, false},
+	{[]string{"a", "-o", ""}, true},
+	{[]string{"!", "a", "-o", "b"}, true},
+	{[]string{"(", "a", "=", "b", ")", "-o", "x"}, true},
+	{[]string{"!", "(", "-e", "nosuch", ")"}, true},
+}
+
+func TestEvalArgs(t *testing.T) {
+	t.Parallel()
+	for i, tc := range evalArgsTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := testConfig().EvalArgs(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("EvalArgs mismatch in %q\nwant: %t\ngot:  %t",
+					tc.args, tc.want, got)
+			}
+		})
+	}
+}
+
+var evalArgsErrors = []struct {
+	args []string
+	want string
+}{
+	{[]string{"a", "-lt", "3"}, "a: integer expression expected"},
+	{[]string{"a", "b"}, `unexpected argument: "b"`},
+	{[]string{"(", "a"}, "missing ')'"},
+	{[]string{"a", "-a"}, "argument expected"},
+}
+
+func TestEvalArgsErrors(t *testing.T) {
+	t.Parallel()
+	for i, tc := range evalArgsErrors {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, err := testConfig().EvalArgs(tc.args)
+			if err == nil {
+				t.Fatalf("Expected error in %q: %v", tc.args, tc.want)
+			}
+			if _, ok := err.(*EvalError); !ok {
+				t.Fatalf("Expected an EvalError, got %T", err)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("Error mismatch in %q\nwant: %q\ngot:  %q",
+					tc.args, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package config finds the settings of shell files in the configuration
+// files of their projects, so that the commands format and lint them
+// the same way without flags.
+//
+// The settings are read from .editorconfig and .shfmt files in the
+// directory of a file and in its parents. Both use the EditorConfig
+// syntax, with sections like "[*.sh]" that apply to the files matching
+// a glob; the keys before the first section apply to all files. The
+// closest files take precedence, and a file may set "root = true" to
+// stop the search.
+//
+// Along with the EditorConfig keys indent_style and indent_size, the
+// keys understood are shell_variant, like "bash" or "posix",
+// binary_next_line, like "true" or "false", and lint_disable, a
+// comma-separated list of analyzers like in lint directives.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Names are the names of the configuration files, in increasing order
+// of precedence within a directory.
+var Names = []string{".editorconfig", ".shfmt"}
+
+// Settings holds the settings of a file, keyed by their lowercase
+// names.
+type Settings map[string]string
+
+// Find returns the settings of the file at a path, from the
+// configuration files in its directory and its parents.
+func Find(path string) (Settings, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	// the files found, closest first
+	var files []*file
+	dir := filepath.Dir(abs)
+	for {
+		root := false
+		for i := len(Names) - 1; i >= 0; i-- {
+			src, err := ioutil.ReadFile(filepath.Join(dir, Names[i]))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			f, err := parse(bytes.NewReader(src))
+			if err != nil {
+				return nil, err
+			}
+			f.dir = dir
+			files = append(files, f)
+			root = root || strings.EqualFold(f.preamble()["root"], "true")
+		}
+		parent := filepath.Dir(dir)
+		if root || parent == dir {
+			break
+		}
+		dir = parent
+	}
+	s := make(Settings)
+	for i := len(files) - 1; i >= 0; i-- {
+		files[i].apply(s, abs)
+	}
+	delete(s, "root")
+	return s, nil
+}
+
+// Indent returns the number of spaces to indent with, or 0 for tabs. It
+// reports whether the indentation is set.
+func (s Settings) Indent() (int, bool) {
+	switch strings.ToLower(s["indent_style"]) {
+	case "tab":
+		return 0, true
+	case "space":
+		if n, err := strconv.Atoi(s["indent_size"]); err == nil && n > 0 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// Variant returns the shell that files are written for, like "bash" or
+// "posix", or an empty string if it isn't set.
+func (s Settings) Variant() string { return strings.ToLower(s["shell_variant"]) }
+
+// BinaryNextLine returns whether the binary operators like && of lines
+// split in two start the second line, and reports if it's set.
+func (s Settings) BinaryNextLine() (bool, bool) {
+	b, err := strconv.ParseBool(s["binary_next_line"])
+	return b, err == nil
+}
+
+// LintDisable returns the analyzers that are disabled, like "quote" or
+// "SH1002".
+func (s Settings) LintDisable() []string {
+	var rules []string
+	for _, rule := range strings.Split(s["lint_disable"], ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// file is a parsed configuration file.
+type file struct {
+	dir      string
+	sections []section
+}
+
+type section struct {
+	// glob is the pattern of the section, or nil for the keys
+	// before the first one
+	glob *regexp.Regexp
+	keys [][2]string
+}
+
+func (f *file) preamble() map[string]string {
+	m := make(map[string]string)
+	if len(f.sections) > 0 && f.sections[0].glob == nil {
+		for _, kv := range f.sections[0].keys {
+			m[kv[0]] = kv[1]
+		}
+	}
+	return m
+}
+
+// apply sets the settings of the sections that match the file at a
+// path, in order.
+func (f *file) apply(s Settings, path string) {
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	for _, sec := range f.sections {
+		if sec.glob != nil && !sec.glob.MatchString(rel) {
+			continue
+		}
+		for _, kv := range sec.keys {
+			s[kv[0]] = kv[1]
+		}
+	}
+}
+
+func parse(r io.Reader) (*file, error) {
+	f := &file{sections: []section{{}}}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "", line[0] == '#', line[0] == ';':
+		case line[0] == '[' && line[len(line)-1] == ']':
+			glob, err := globRegexp(line[1 : len(line)-1])
+			if err != nil {
+				return nil, err
+			}
+			f.sections = append(f.sections, section{glob: glob})
+		default:
+			i := strings.IndexAny(line, "=:")
+			if i < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(line[:i]))
+			value := strings.TrimSpace(line[i+1:])
+			sec := &f.sections[len(f.sections)-1]
+			sec.keys = append(sec.keys, [2]string{key, value})
+		}
+	}
+	return f, sc.Err()
+}
+
+// globRegexp translates an EditorConfig glob into a regular expression
+// matching slash-separated paths relative to the file's directory. A
+// glob without slashes matches files in any directory.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	buf.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		buf.WriteString("(.*/)?")
+	}
+	glob = strings.TrimPrefix(glob, "/")
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				buf.WriteString(".*")
+				i++
+			} else {
+				buf.WriteString("[^/]*")
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j < 0 {
+				buf.WriteString(`\[`)
+				break
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += j
+		case '{':
+			braces++
+			buf.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				buf.WriteString(`\}`)
+				break
+			}
+			braces--
+			buf.WriteString(")")
+		case ',':
+			if braces == 0 {
+				buf.WriteString(",")
+				break
+			}
+			buf.WriteString("|")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	for ; braces > 0; braces-- {
+		buf.WriteString(")")
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"*", "foo.sh", true},
+		{"*", "a/b/foo.sh", true},
+		{"*.sh", "foo.sh", true},
+		{"*.sh", "a/foo.sh", true},
+		{"*.sh", "foo.bash", false},
+		{"*.{sh,bash}", "a/foo.bash", true},
+		{"*.{sh,bash}", "foo.zsh", false},
+		{"/foo.sh", "foo.sh", true},
+		{"/foo.sh", "a/foo.sh", false},
+		{"lib/*.sh", "lib/foo.sh", true},
+		{"lib/*.sh", "lib/a/foo.sh", false},
+		{"lib/**.sh", "lib/a/foo.sh", true},
+		{"lib/**/*.sh", "lib/a/b/foo.sh", true},
+		{"foo?.sh", "foo1.sh", true},
+		{"foo?.sh", "foo/.sh", false},
+		{"foo[0-9].sh", "foo1.sh", true},
+		{"foo[!0-9].sh", "foo1.sh", false},
+		{`\*.sh`, "*.sh", true},
+		{`\*.sh`, "foo.sh", false},
+	}
+	for _, tc := range tests {
+		rx, err := globRegexp(tc.glob)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.glob, err)
+		}
+		if got := rx.MatchString(tc.path); got != tc.want {
+			t.Errorf("%q matching %q: want %v, got %v", tc.glob, tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-find")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		".editorconfig": `
+# the root of the project
+root = true
+
+[*]
+indent_style = space
+indent_size = 4
+
+[*.bash]
+shell_variant = bash
+`,
+		"lib/.shfmt": `
+shell_variant = posix
+binary_next_line = false
+
+[vendored/*.sh]
+lint_disable = quote, SH1002
+`,
+		"lib/.editorconfig": `
+[*]
+indent_style = tab
+`,
+		"sub/.editorconfig": `
+root = true
+
+[*.sh]
+indent_size = 2
+`,
+	}
+	for path, body := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		path string
+		want Settings
+	}{
+		{"foo.sh", Settings{"indent_style": "space", "indent_size": "4"}},
+		{"a/foo.bash", Settings{
+			"indent_style":  "space",
+			"indent_size":   "4",
+			"shell_variant": "bash",
+		}},
+		{"lib/foo.bash", Settings{
+			"indent_style":     "tab",
+			"indent_size":      "4",
+			"shell_variant":    "posix",
+			"binary_next_line": "false",
+		}},
+		{"lib/vendored/foo.sh", Settings{
+			"indent_style":     "tab",
+			"indent_size":      "4",
+			"shell_variant":    "posix",
+			"binary_next_line": "false",
+			"lint_disable":     "quote, SH1002",
+		}},
+		{"sub/foo.sh", Settings{"indent_size": "2"}},
+	}
+	for _, tc := range tests {
+		got, err := Find(filepath.Join(dir, tc.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("settings mismatch in %s\nwant: %v\ngot:  %v", tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+	s := Settings{
+		"indent_style":     "Space",
+		"indent_size":      "2",
+		"shell_variant":    "POSIX",
+		"binary_next_line": "false",
+		"lint_disable":     "quote, ,SH1002",
+	}
+	if n, ok := s.Indent(); n != 2 || !ok {
+		t.Errorf("Indent: got %d, %v", n, ok)
+	}
+	if got := s.Variant(); got != "posix" {
+		t.Errorf("Variant: got %q", got)
+	}
+	if next, ok := s.BinaryNextLine(); next || !ok {
+		t.Errorf("BinaryNextLine: got %v, %v", next, ok)
+	}
+	want := []string{"quote", "SH1002"}
+	if got := s.LintDisable(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LintDisable: want %q, got %q", want, got)
+	}
+	if _, ok := (Settings{"indent_style": "space"}).Indent(); ok {
+		t.Errorf("Indent without a size is set")
+	}
+	if n, ok := (Settings{"indent_style": "tab"}).Indent(); n != 0 || !ok {
+		t.Errorf("Indent with tabs: got %d, %v", n, ok)
+	}
+}
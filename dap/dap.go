@@ -0,0 +1,152 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package dap implements a Debug Adapter Protocol server, so that
+// editors like VS Code can debug shell programs run by the interp
+// package, with breakpoints, stepping and variable panes.
+//
+// A session debugs a single program, given by the "program" argument
+// of the launch request. The optional "args", "cwd" and "stopOnEntry"
+// arguments set its positional parameters, its working directory and
+// whether it pauses before its first statement.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Serve runs a debugging session, reading requests from in and writing
+// responses and events to out, until the client disconnects or in is
+// closed.
+func Serve(in io.Reader, out io.Writer) error {
+	s := newSession(in, out)
+	return s.serve()
+}
+
+// request is a message sent by the client.
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// conn reads and writes messages in the base protocol, where each
+// message is a JSON object after a Content-Length header.
+type conn struct {
+	in *bufio.Reader
+
+	// mu guards out and seq, as events are sent while the program
+	// runs
+	mu  sync.Mutex
+	out io.Writer
+	seq int
+}
+
+// read reads the next request.
+func (c *conn) read() (*request, error) {
+	buf, err := c.readRaw()
+	if err != nil {
+		return nil, err
+	}
+	var req request
+	if err := json.Unmarshal(buf, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// readRaw reads the JSON body of the next message.
+func (c *conn) readRaw() ([]byte, error) {
+	length := -1
+	for {
+		line, err := c.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid header: %q", line)
+		}
+		if strings.TrimSpace(line[:i]) == "Content-Length" {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[i+1:])); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %q", line[i+1:])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(c.in, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// write writes a response or an event, setting its sequence number.
+func (c *conn) write(msg interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	switch x := msg.(type) {
+	case *response:
+		x.Seq, x.Type = c.seq, "response"
+	case *event:
+		x.Seq, x.Type = c.seq, "event"
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.out.Write(body)
+	return err
+}
+
+func (c *conn) event(name string, body interface{}) error {
+	return c.write(&event{Event: name, Body: body})
+}
+
+// outputWriter sends what is written to it as output events.
+type outputWriter struct {
+	c        *conn
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	err := w.c.event("output", outputBody{Category: w.category, Output: string(p)})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// client drives a session from the side of an editor.
+type client struct {
+	t   *testing.T
+	c   *conn
+	seq int
+
+	// output is the program output seen so far
+	output string
+}
+
+type message struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *client) send(command string, args interface{}) {
+	c.seq++
+	raw, err := json.Marshal(args)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req := &request{Seq: c.seq, Type: "request", Command: command, Arguments: raw}
+	if err := c.c.write(req); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads messages until a response to a command or an event with
+// a name, decoding its body into v if non-nil.
+func (c *client) expect(name string, v interface{}) {
+	for {
+		raw, err := c.c.readRaw()
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", name, err)
+		}
+		var msg message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.Event == "output" {
+			var body outputBody
+			json.Unmarshal(msg.Body, &body)
+			c.output += body.Output
+		}
+		if msg.Command != name && msg.Event != name {
+			continue
+		}
+		if msg.Type == "response" && !msg.Success {
+			c.t.Fatalf("%s failed: %s", name, msg.Message)
+		}
+		if v != nil {
+			if err := json.Unmarshal(msg.Body, v); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script.sh")
+	src := "x=1\necho one\necho $x\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(inR, outW)
+		outW.Close()
+	}()
+	c := &client{t: t, c: &conn{in: bufio.NewReader(outR), out: inW}}
+
+	c.send("initialize", map[string]string{"adapterID": "sh"})
+	c.expect("initialized", nil)
+	c.send("launch", map[string]string{"program": path})
+	c.expect("launch", nil)
+	c.send("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 3}},
+	})
+	var bps setBreakpointsBody
+	c.expect("setBreakpoints", &bps)
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified {
+		t.Fatalf("wrong breakpoints: %+v", bps)
+	}
+	c.send("configurationDone", nil)
+
+	var stopped stoppedBody
+	c.expect("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("wrong stop reason: %q", stopped.Reason)
+	}
+	c.send("stackTrace", map[string]int{"threadId": threadID})
+	var trace stackTraceBody
+	c.expect("stackTrace", &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 3 {
+		t.Fatalf("wrong stack trace: %+v", trace)
+	}
+	c.send("variables", map[string]int{"variablesReference": varsRef})
+	var vars variablesBody
+	c.expect("variables", &vars)
+	found := false
+	for _, vr := range vars.Variables {
+		if vr.Name == "x" {
+			found = vr.Value == "1"
+		}
+	}
+	if !found {
+		t.Fatalf("x=1 not in the variables: %+v", vars)
+	}
+	c.send("setVariable", map[string]interface{}{
+		"variablesReference": varsRef,
+		"name":               "x",
+		"value":              "changed",
+	})
+	c.expect("setVariable", nil)
+	c.send("continue", map[string]int{"threadId": threadID})
+
+	var exited exitedBody
+	c.expect("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Fatalf("wrong exit code: %d", exited.ExitCode)
+	}
+	if want := "one\nchanged\n"; c.output != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, c.output)
+	}
+	c.send("disconnect", nil)
+	c.expect("disconnect", nil)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
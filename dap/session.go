@@ -0,0 +1,509 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/interp"
+	"github.com/mvdan/sh/syntax"
+)
+
+// The program runs in a single thread, as far as the client is
+// concerned.
+const threadID = 1
+
+// The references to the scopes in the variables pane.
+const (
+	varsRef = iota + 1
+	paramsRef
+)
+
+type session struct {
+	conn *conn
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dbg    *interp.Debugger
+	runner *interp.Runner
+	file   *syntax.File
+
+	// launched and configured are set once the launch and
+	// configurationDone requests arrive, as the program starts once
+	// both are done
+	launched, configured bool
+
+	// done is closed once the program finishes
+	done chan struct{}
+
+	// resume receives the action that a paused program continues with
+	resume chan interp.DebugAction
+
+	// mu guards the fields below, which the program sets when it
+	// pauses
+	mu sync.Mutex
+
+	// frame is the state of the paused program, or nil if it's
+	// running
+	frame *interp.Frame
+
+	// reason is why the program will pause next, as reported to the
+	// client
+	reason string
+}
+
+func newSession(in io.Reader, out io.Writer) *session {
+	s := &session{
+		conn:   &conn{in: bufio.NewReader(in), out: out},
+		dbg:    &interp.Debugger{},
+		resume: make(chan interp.DebugAction),
+		reason: "breakpoint",
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.dbg.Paused = s.paused
+	return s
+}
+
+func (s *session) serve() error {
+	defer s.cancel()
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		body, err := s.handle(req)
+		resp := &response{
+			RequestSeq: req.Seq,
+			Command:    req.Command,
+			Success:    err == nil,
+			Body:       body,
+		}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.conn.write(resp); err != nil {
+			return err
+		}
+		switch req.Command {
+		case "initialize":
+			// the client may now send its configuration, like the
+			// breakpoints
+			if err := s.conn.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (s *session) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsSetVariable:              true,
+		}, nil
+	case "launch":
+		var args launchArgs
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args setBreakpointsArgs
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "configurationDone":
+		s.configured = true
+		s.start()
+		return nil, nil
+	case "threads":
+		return threadsBody{Threads: []thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		f, err := s.pausedFrame()
+		if err != nil {
+			return nil, err
+		}
+		return stackTrace(f), nil
+	case "scopes":
+		return scopesBody{Scopes: []scope{
+			{Name: "Variables", VariablesReference: varsRef},
+			{Name: "Positional parameters", VariablesReference: paramsRef},
+		}}, nil
+	case "variables":
+		var args variablesArgs
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		f, err := s.pausedFrame()
+		if err != nil {
+			return nil, err
+		}
+		return variables(f, args.VariablesReference), nil
+	case "setVariable":
+		var args setVariableArgs
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		return s.setVariable(args)
+	case "continue":
+		return continueBody{AllThreadsContinued: true}, s.continueWith(interp.DebugContinue, "breakpoint")
+	case "next":
+		return nil, s.continueWith(interp.DebugNext, "step")
+	case "stepIn":
+		return nil, s.continueWith(interp.DebugStep, "step")
+	case "stepOut":
+		return nil, s.continueWith(interp.DebugStepOut, "step")
+	case "pause":
+		s.mu.Lock()
+		s.reason = "pause"
+		s.mu.Unlock()
+		s.dbg.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		s.cancel()
+		if s.done != nil {
+			<-s.done
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported command: %s", req.Command)
+}
+
+func decodeArgs(req *request, v interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments to %s: %v", req.Command, err)
+	}
+	return nil
+}
+
+func (s *session) launch(args launchArgs) error {
+	if args.Program == "" {
+		return fmt.Errorf("missing program to debug")
+	}
+	src, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	file, err := syntax.Parse(src, filepath.Clean(args.Program), 0)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.runner = &interp.Runner{
+		Dir:      args.Cwd,
+		Params:   args.Args,
+		Stdout:   outputWriter{s.conn, "stdout"},
+		Stderr:   outputWriter{s.conn, "stderr"},
+		Debugger: s.dbg,
+	}
+	if args.StopOnEntry {
+		s.reason = "entry"
+		s.dbg.Pause()
+	}
+	s.launched = true
+	s.start()
+	return nil
+}
+
+// start runs the program once it's launched and configured.
+func (s *session) start() {
+	if !s.launched || !s.configured || s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		code := 0
+		switch err := s.runner.Run(s.ctx, s.file).(type) {
+		case nil:
+		case interp.ExitCode:
+			code = int(err)
+		default:
+			if err != context.Canceled {
+				s.conn.event("output", outputBody{Category: "stderr", Output: err.Error() + "\n"})
+			}
+			code = 1
+		}
+		s.conn.event("exited", exitedBody{ExitCode: code})
+		s.conn.event("terminated", nil)
+	}()
+}
+
+// paused is called by the program when it pauses, and waits until the
+// client tells it how to continue.
+func (s *session) paused(f *interp.Frame) interp.DebugAction {
+	s.mu.Lock()
+	s.frame = f
+	reason := s.reason
+	s.mu.Unlock()
+	s.conn.event("stopped", stoppedBody{
+		Reason:            reason,
+		ThreadID:          threadID,
+		AllThreadsStopped: true,
+	})
+	select {
+	case action := <-s.resume:
+		return action
+	case <-s.ctx.Done():
+		return interp.DebugContinue
+	}
+}
+
+func (s *session) pausedFrame() (*interp.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.frame == nil {
+		return nil, fmt.Errorf("the program is not paused")
+	}
+	return s.frame, nil
+}
+
+// continueWith resumes a paused program with an action. reason is why
+// the program will pause next.
+func (s *session) continueWith(action interp.DebugAction, reason string) error {
+	s.mu.Lock()
+	if s.frame == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("the program is not paused")
+	}
+	s.frame, s.reason = nil, reason
+	s.mu.Unlock()
+	s.resume <- action
+	return nil
+}
+
+func (s *session) setBreakpoints(args setBreakpointsArgs) setBreakpointsBody {
+	name := filepath.Clean(args.Source.Path)
+	for _, bp := range s.dbg.Breakpoints() {
+		if bp.Filename == name {
+			s.dbg.ClearBreakpoint(bp.Filename, bp.Line)
+		}
+	}
+	body := setBreakpointsBody{Breakpoints: []breakpoint{}}
+	for _, sbp := range args.Breakpoints {
+		s.dbg.SetBreakpoint(name, sbp.Line)
+		body.Breakpoints = append(body.Breakpoints, breakpoint{Verified: true, Line: sbp.Line})
+	}
+	return body
+}
+
+// stackTrace returns a frame for each function call in the stack. Only
+// the innermost one has a position, as the interpreter doesn't keep
+// track of where the rest were called from.
+func stackTrace(f *interp.Frame) stackTraceBody {
+	names := append([]string{"main"}, f.Funcs()...)
+	var body stackTraceBody
+	for i := len(names) - 1; i >= 0; i-- {
+		sf := stackFrame{ID: i, Name: names[i]}
+		if i == len(names)-1 {
+			sf.Source = &source{Name: filepath.Base(f.Filename), Path: f.Filename}
+			sf.Line, sf.Column = f.Position.Line, f.Position.Column
+		}
+		body.StackFrames = append(body.StackFrames, sf)
+	}
+	body.TotalFrames = len(body.StackFrames)
+	return body
+}
+
+func variables(f *interp.Frame, ref int) variablesBody {
+	body := variablesBody{Variables: []variable{}}
+	switch ref {
+	case varsRef:
+		vars := make(map[string]expand.Variable)
+		var names []string
+		f.Env().Each(func(name string, vr expand.Variable) bool {
+			if vr.Set {
+				vars[name] = vr
+				names = append(names, name)
+			}
+			return true
+		})
+		sort.Strings(names)
+		for _, name := range names {
+			body.Variables = append(body.Variables, variable{
+				Name:  name,
+				Value: varValue(vars[name]),
+			})
+		}
+	case paramsRef:
+		for i, param := range f.Params() {
+			body.Variables = append(body.Variables, variable{
+				Name:  strconv.Itoa(i + 1),
+				Value: param,
+			})
+		}
+	}
+	return body
+}
+
+// varValue formats a variable for the variables pane, with arrays
+// written like in their assignments.
+func varValue(vr expand.Variable) string {
+	switch {
+	case vr.List != nil:
+		return "(" + strings.Join(vr.List, " ") + ")"
+	case vr.Map != nil:
+		keys := make([]string, 0, len(vr.Map))
+		for key := range vr.Map {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		elems := make([]string, len(keys))
+		for i, key := range keys {
+			elems[i] = "[" + key + "]=" + vr.Map[key]
+		}
+		return "(" + strings.Join(elems, " ") + ")"
+	}
+	return vr.Value
+}
+
+func (s *session) setVariable(args setVariableArgs) (interface{}, error) {
+	f, err := s.pausedFrame()
+	if err != nil {
+		return nil, err
+	}
+	if args.VariablesReference != varsRef {
+		return nil, fmt.Errorf("positional parameters cannot be modified")
+	}
+	env := f.Env()
+	vr := env.Get(args.Name)
+	vr.Set, vr.Value, vr.List, vr.Map = true, args.Value, nil, nil
+	if err := env.Set(args.Name, vr); err != nil {
+		return nil, err
+	}
+	return setVariableBody{Value: args.Value}, nil
+}
+
+type capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsSetVariable              bool `json:"supportsSetVariable"`
+}
+
+type launchArgs struct {
+	Program     string   `json:"program"`
+	Args        []string `json:"args"`
+	Cwd         string   `json:"cwd"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+}
+
+type setBreakpointsArgs struct {
+	Source      source `json:"source"`
+	Breakpoints []struct {
+		Line int `json:"line"`
+	} `json:"breakpoints"`
+}
+
+type breakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+type setBreakpointsBody struct {
+	Breakpoints []breakpoint `json:"breakpoints"`
+}
+
+type thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type threadsBody struct {
+	Threads []thread `json:"threads"`
+}
+
+type stackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+type stackTraceBody struct {
+	StackFrames []stackFrame `json:"stackFrames"`
+	TotalFrames int          `json:"totalFrames"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type scopesBody struct {
+	Scopes []scope `json:"scopes"`
+}
+
+type variablesArgs struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type variablesBody struct {
+	Variables []variable `json:"variables"`
+}
+
+type setVariableArgs struct {
+	VariablesReference int    `json:"variablesReference"`
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+}
+
+type setVariableBody struct {
+	Value string `json:"value"`
+}
+
+type continueBody struct {
+	AllThreadsContinued bool `json:"allThreadsContinued"`
+}
+
+type stoppedBody struct {
+	Reason            string `json:"reason"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+}
+
+type outputBody struct {
+	Category string `json:"category"`
+	Output   string `json:"output"`
+}
+
+type exitedBody struct {
+	ExitCode int `json:"exitCode"`
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package diff computes line-based differences between files, to show
+// the changes that formatting or fixes make to them as patches.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// context is the number of unchanged lines around each hunk.
+const context = 3
+
+type lineOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+func splitLines(src []byte) []string {
+	var lines []string
+	for len(src) > 0 {
+		i := bytes.IndexByte(src, '\n') + 1
+		if i == 0 {
+			i = len(src)
+		}
+		lines = append(lines, string(src[:i]))
+		src = src[i:]
+	}
+	return lines
+}
+
+// lineOps returns the edit script between two lists of lines. The
+// common prefix and suffix are trimmed, and the rest is diffed with
+// the linear space variant of Myers' algorithm. Within each run of
+// changes, the removed lines come before the added ones.
+func lineOps(a, b []string) []lineOp {
+	var ops []lineOp
+	diffLines(&ops, a, b)
+	// group the removals and additions in each run of changes
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].kind != ' ' {
+			j++
+		}
+		var run []lineOp
+		for _, kind := range []byte{'-', '+'} {
+			for _, op := range ops[i:j] {
+				if op.kind == kind {
+					run = append(run, op)
+				}
+			}
+		}
+		copy(ops[i:j], run)
+		i = j
+	}
+	return ops
+}
+
+// diffLines appends the edit script between a and b to ops.
+func diffLines(ops *[]lineOp, a, b []string) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		*ops = append(*ops, lineOp{' ', line})
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(ma) == 0:
+		for _, line := range mb {
+			*ops = append(*ops, lineOp{'+', line})
+		}
+	case len(mb) == 0:
+		for _, line := range ma {
+			*ops = append(*ops, lineOp{'-', line})
+		}
+	default:
+		x, y := middle(ma, mb)
+		diffLines(ops, ma[:x], mb[:y])
+		diffLines(ops, ma[x:], mb[y:])
+	}
+	for _, line := range a[len(a)-suffix:] {
+		*ops = append(*ops, lineOp{' ', line})
+	}
+}
+
+// middle returns a point on a shortest edit path between a and b,
+// found where the paths searched forwards from the start and backwards
+// from the end meet. Neither a nor b may be empty, and they must not
+// start or end with the same line, so that the point is never at the
+// start or end of the path.
+func middle(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	max := (n + m + 1) / 2
+	// the furthest x reached on each diagonal k, at index k+max+1,
+	// forwards and backwards; the latter counts from the end
+	fwd := make([]int, 2*max+3)
+	bwd := make([]int, 2*max+3)
+	delta := n - m
+	odd := delta%2 != 0
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			i := k + max + 1
+			var x int
+			if k == -d || (k != d && fwd[i-1] < fwd[i+1]) {
+				x = fwd[i+1]
+			} else {
+				x = fwd[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			fwd[i] = x
+			// the backward diagonal that ends at this one
+			if bk := delta - k; odd && bk >= -(d-1) && bk <= d-1 {
+				if x+bwd[bk+max+1] >= n {
+					return x, y
+				}
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			i := k + max + 1
+			var x int
+			if k == -d || (k != d && bwd[i-1] < bwd[i+1]) {
+				x = bwd[i+1]
+			} else {
+				x = bwd[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			bwd[i] = x
+			if fk := delta - k; !odd && fk >= -d && fk <= d {
+				if fx := fwd[fk+max+1]; fx+x >= n {
+					return fx, fx - fk
+				}
+			}
+		}
+	}
+	panic("diff: the edit paths didn't meet")
+}
+
+// Unified returns the changes from a to b in the unified format, as a
+// patch for the file at path. It is empty if they are equal.
+func Unified(path string, a, b []byte) []byte {
+	ops := lineOps(splitLines(a), splitLines(b))
+	var buf bytes.Buffer
+	// line numbers of the next op in each file
+	aline, bline := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			aline++
+			bline++
+			i++
+			continue
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", path, path)
+		}
+		// extend the hunk while changes are close to each other
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := end + context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		astart, bstart := aline-(i-start), bline-(i-start)
+		var alen, blen int
+		var hunk bytes.Buffer
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				alen++
+			}
+			if op.kind != '-' {
+				blen++
+			}
+			hunk.WriteByte(op.kind)
+			hunk.WriteString(op.line)
+			if op.line[len(op.line)-1] != '\n' {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(astart, alen), hunkRange(bstart, blen))
+		buf.Write(hunk.Bytes())
+		aline, bline = astart+alen, bstart+blen
+		i = stop
+	}
+	return buf.Bytes()
+}
+
+// Format prints a parsed file with a config, and returns the changes
+// that formatting makes to its source in the unified format. It is
+// empty if the file is formatted already. The file must have been
+// parsed from source, which also gives the name of the file in the
+// patch.
+func Format(c syntax.PrintConfig, f *syntax.File) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.Fprint(&buf, f); err != nil {
+		return nil, err
+	}
+	return Unified(f.Name, f.Source, buf.Bytes()), nil
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		start--
+	}
+	if n == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		a, b, want string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- f\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "1\n2\n3\n4\n5\n6\n7\n8\n9\nx\n",
+			"--- f\n+++ f\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+x\n"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"--- f\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n"},
+		{"a\n", "a\nb", "--- f\n+++ f\n@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n"},
+		{"", "a\n", "--- f\n+++ f\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\nb\nc\nd\ne\n", "b\nx\nc\ne\ny\n", "--- f\n+++ f\n@@ -1,5 +1,5 @@\n-a\n b\n+x\n c\n-d\n e\n+y\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := string(Unified("f", []byte(tc.a), []byte(tc.b)))
+			if got != tc.want {
+				t.Fatalf("wrong diff:\nwant: %q\ngot:  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnifiedLarge(t *testing.T) {
+	t.Parallel()
+	// a table of all pairs of lines would need billions of entries
+	var a, b bytes.Buffer
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&a, "%d\n", i)
+		fmt.Fprintf(&b, "%d\n", i)
+		if i == 20000 {
+			b.WriteString("x\n")
+		}
+	}
+	want := "--- f\n+++ f\n@@ -19999,6 +19999,7 @@\n 19998\n 19999\n 20000\n+x\n 20001\n 20002\n 20003\n"
+	if got := string(Unified("f", a.Bytes(), b.Bytes())); got != want {
+		t.Fatalf("wrong diff:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"foo\n", ""},
+		{"foo ;  bar\n", "--- f.sh\n+++ f.sh\n@@ -1 +1,2 @@\n-foo ;  bar\n+foo\n+bar\n"},
+		{"if a; then\nb\nfi", "--- f.sh\n+++ f.sh\n@@ -1,3 +1,3 @@\n if a; then\n-b\n-fi\n\\ No newline at end of file\n+\tb\n+fi\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "f.sh", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(syntax.PrintConfig{}, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("wrong diff:\nwant: %q\ngot:  %q", tc.want, string(got))
+			}
+		})
+	}
+}
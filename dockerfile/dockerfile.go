@@ -0,0 +1,345 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package dockerfile handles the shell programs in the RUN instructions
+// of Dockerfiles, so that tools working with Dockerfiles can parse,
+// format and lint them without dealing with shell themselves.
+package dockerfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Config holds the settings of a Dockerfile that affect its RUN
+// instructions.
+type Config struct {
+	// Escape is the escape character set by an "# escape=" parser
+	// directive. If zero, it's a backslash.
+	Escape byte
+
+	// Shell is the shell set by a SHELL instruction, like
+	// ["/bin/bash", "-o", "pipefail", "-c"]. If nil, it's
+	// ["/bin/sh", "-c"].
+	Shell []string
+
+	// Mode is the mode used to parse the shell programs.
+	Mode syntax.ParseMode
+
+	// Indent is the number of spaces that FormatRun indents
+	// continuation lines with. If zero, 4 is used.
+	Indent int
+
+	// Width is the column after which FormatRun splits the
+	// arguments of a command into lines. If zero, 80 is used.
+	Width int
+}
+
+func (c Config) escape() byte {
+	if c.Escape == 0 {
+		return '\\'
+	}
+	return c.Escape
+}
+
+// Run is the command of a RUN instruction.
+type Run struct {
+	// Flags are the options before the command, like
+	// "--mount=type=cache,target=/root/.cache".
+	Flags []string
+
+	// Exec is set if the command has the JSON array form, like
+	// ["make", "all"], in which Args are run directly instead of via
+	// a shell.
+	Exec bool
+	Args []string
+
+	// Shell is the name of the shell that runs File, like "sh" or
+	// "bash".
+	Shell string
+
+	// File is the shell program that the command runs. In the JSON
+	// array form, it's the program given to a shell via -c, like in
+	// ["sh", "-c", "make all"], or nil if there isn't one.
+	//
+	// In the shell form, its positions are relative to the start of
+	// the payload given to ParseRun, so that line 1 is the line of
+	// the RUN instruction. In the JSON array form, they are relative
+	// to the start of the program.
+	File *syntax.File
+}
+
+// ParseRun parses the payload of a RUN instruction, which is the source
+// after "RUN", including any continuation lines, like:
+//
+//	--mount=type=cache,target=/var/cache/apt apt-get update && \
+//	    apt-get install -y curl
+//
+// Like in Docker, the lines with only comments or spaces within a
+// continuation are ignored, and the shell form is used if the payload
+// looks like a JSON array but isn't one.
+func (c Config) ParseRun(payload string) (*Run, error) {
+	r := &Run{Shell: "sh"}
+	shell := c.Shell
+	if len(shell) > 0 {
+		r.Shell = path.Base(shell[0])
+	}
+	src := []byte(payload)
+	start := 0
+	for {
+		for start < len(src) && (src[start] == ' ' || src[start] == '\t') {
+			start++
+		}
+		if !bytes.HasPrefix(src[start:], []byte("--")) {
+			break
+		}
+		end := start
+		for end < len(src) && src[end] != ' ' && src[end] != '\t' && src[end] != '\n' {
+			end++
+		}
+		r.Flags = append(r.Flags, string(src[start:end]))
+		for i := start; i < end; i++ {
+			// keep the positions of the program
+			src[i] = ' '
+		}
+		start = end
+	}
+	c.continuations(src)
+	if trimmed := bytes.TrimSpace(src); bytes.HasPrefix(trimmed, []byte("[")) {
+		joined := bytes.Replace(trimmed, []byte{'\\', '\n'}, nil, -1)
+		if err := json.Unmarshal(joined, &r.Args); err == nil {
+			r.Exec = true
+			if len(r.Args) > 2 && r.Args[1] == "-c" && isShell(r.Args[0]) {
+				r.Shell = path.Base(r.Args[0])
+				f, err := syntax.Parse([]byte(r.Args[2]), "", c.Mode)
+				if err != nil {
+					return nil, err
+				}
+				r.File = f
+			}
+			return r, nil
+		}
+		r.Args = nil
+	}
+	f, err := syntax.Parse(src, "", c.Mode)
+	if err != nil {
+		return nil, err
+	}
+	r.File = f
+	return r, nil
+}
+
+func isShell(name string) bool {
+	switch path.Base(name) {
+	case "sh", "bash", "dash", "ash", "ksh", "mksh", "zsh":
+		return true
+	}
+	return false
+}
+
+// continuations turns the continuation lines of a payload into ones
+// the shell understands, without moving any of its bytes: a different
+// escape character becomes a backslash, and the lines that Docker
+// ignores become spaces followed by a backslash.
+func (c Config) continuations(src []byte) {
+	esc := c.escape()
+	continued := false
+	for len(src) > 0 {
+		line := src
+		i := bytes.IndexByte(src, '\n')
+		if i >= 0 {
+			line, src = src[:i], src[i+1:]
+		} else {
+			src = nil
+		}
+		if trimmed := bytes.TrimLeft(line, " \t"); continued && len(line) > 0 &&
+			(len(trimmed) == 0 || trimmed[0] == '#') {
+			for j := range line {
+				line[j] = ' '
+			}
+			line[len(line)-1] = '\\'
+			continue
+		}
+		continued = i >= 0 && len(line) > 0 && line[len(line)-1] == esc
+		if continued {
+			line[len(line)-1] = '\\'
+		}
+	}
+}
+
+// LintRun lints the shell program of a RUN instruction. If l is nil, the
+// default linter is used. Unless l has a dialect, the one of the
+// instruction's shell is used, or POSIX.
+func LintRun(r *Run, l *lint.Linter) []lint.Diagnostic {
+	if r.File == nil {
+		return nil
+	}
+	var lc lint.Linter
+	if l != nil {
+		lc = *l
+	}
+	if lc.Dialect == nil {
+		if lc.Dialect = lint.LookupDialect(r.Shell); lc.Dialect == nil {
+			lc.Dialect = lint.POSIX
+		}
+	}
+	return lc.Lint(r.File)
+}
+
+// FormatRun returns the formatted payload of a RUN instruction, to be
+// written after "RUN". Lists of commands joined by &&, || or ; are
+// split into continuation lines, one per command, and commands that are
+// too long have their arguments split too:
+//
+//	apt-get update \
+//	    && apt-get install -y --no-install-recommends \
+//	        curl \
+//	        git \
+//	    && rm -rf /var/lib/apt/lists/*
+//
+// Other commands, like if clauses, are kept on one line. Comments are
+// dropped, as Docker ignores them anyway.
+func (c Config) FormatRun(r *Run) (string, error) {
+	var buf bytes.Buffer
+	for _, flag := range r.Flags {
+		buf.WriteString(flag)
+		buf.WriteByte(' ')
+	}
+	if r.Exec {
+		var enc bytes.Buffer
+		e := json.NewEncoder(&enc)
+		e.SetEscapeHTML(false)
+		buf.WriteByte('[')
+		for i, arg := range r.Args {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			enc.Reset()
+			if err := e.Encode(arg); err != nil {
+				return "", err
+			}
+			buf.Write(bytes.TrimSuffix(enc.Bytes(), []byte("\n")))
+		}
+		buf.WriteByte(']')
+		return buf.String(), nil
+	}
+	f := &formatter{c: c, buf: &buf, col: len("RUN ") + buf.Len()}
+	if f.c.Indent == 0 {
+		f.c.Indent = 4
+	}
+	if f.c.Width == 0 {
+		f.c.Width = 80
+	}
+	stmts := r.File.Stmts
+	for i, s := range stmts {
+		if i > 0 {
+			if !stmts[i-1].Background {
+				buf.WriteByte(';')
+			}
+			f.contLine(1)
+		}
+		f.list(s)
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return buf.String(), nil
+}
+
+var errMultiline = errors.New("heredocs and multi-line strings can't be formatted in RUN")
+
+type formatter struct {
+	c   Config
+	buf *bytes.Buffer
+	col int
+	err error
+}
+
+// write adds text to the current line, separated by a space.
+func (f *formatter) write(s string) {
+	if s == "" {
+		return
+	}
+	if n := f.buf.Len(); n > 0 && f.buf.Bytes()[n-1] != ' ' {
+		f.buf.WriteByte(' ')
+		f.col++
+	}
+	f.buf.WriteString(s)
+	f.col += len(s)
+}
+
+// contLine ends the current line with a continuation, and indents the
+// next one by level.
+func (f *formatter) contLine(level int) {
+	f.buf.WriteByte(' ')
+	f.buf.WriteByte(f.c.escape())
+	f.buf.WriteByte('\n')
+	indent := strings.Repeat(" ", f.c.Indent*level)
+	f.buf.WriteString(indent)
+	f.col = len(indent)
+}
+
+// list writes a list of commands joined by && and ||, one per line,
+// with the operators at the start of the lines.
+func (f *formatter) list(s *syntax.Stmt) {
+	for {
+		bc, ok := s.Cmd.(*syntax.BinaryCmd)
+		if !ok || s.Negated || s.Background || len(s.Redirs) > 0 ||
+			(bc.Op != syntax.AndStmt && bc.Op != syntax.OrStmt) {
+			f.stmt(s)
+			return
+		}
+		// the parser groups && and || from the right
+		f.stmt(bc.X)
+		f.contLine(1)
+		f.write(bc.Op.String())
+		s = bc.Y
+	}
+}
+
+// stmt writes a single command, splitting its arguments if it's too
+// long.
+func (f *formatter) stmt(s *syntax.Stmt) {
+	line := f.print(s)
+	ce, ok := s.Cmd.(*syntax.CallExpr)
+	if f.col+1+len(line) <= f.c.Width || !ok || len(ce.Args) < 3 ||
+		len(s.Assigns) > 0 || len(s.Redirs) > 0 || s.Negated || s.Background {
+		f.write(line)
+		return
+	}
+	args := make([]string, len(ce.Args))
+	for i, w := range ce.Args {
+		args[i] = f.print(&syntax.Stmt{Cmd: &syntax.CallExpr{Args: []*syntax.Word{w}}})
+	}
+	// the command and its options stay on the first line, or its
+	// first argument if it has none, like "pip install"
+	n := 2
+	for i, arg := range args[:len(args)-1] {
+		if strings.HasPrefix(arg, "-") {
+			n = i + 1
+		}
+	}
+	f.write(strings.Join(args[:n], " "))
+	for _, arg := range args[n:] {
+		f.contLine(2)
+		f.write(arg)
+	}
+}
+
+// print returns a statement printed on a single line.
+func (f *formatter) print(s *syntax.Stmt) string {
+	var buf bytes.Buffer
+	c := syntax.PrintConfig{SingleLine: true}
+	c.Fprint(&buf, &syntax.File{Stmts: []*syntax.Stmt{s}})
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if strings.Contains(line, "\n") && f.err == nil {
+		f.err = errMultiline
+	}
+	return line
+}
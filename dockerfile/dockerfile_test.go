@@ -0,0 +1,136 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package dockerfile
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestParseRun(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		c       Config
+		payload string
+		flags   []string
+		args    []string
+		shell   string
+		want    string
+	}{
+		{Config{}, "make all", nil, nil, "sh", "make all"},
+		{Config{}, "--mount=type=cache,target=/c --network=none make", []string{"--mount=type=cache,target=/c", "--network=none"}, nil, "sh", "make"},
+		{Config{}, "a && \\\n  # comment\n\n  b", nil, nil, "sh", "a && b"},
+		{Config{Escape: '`'}, "a `\n  b", nil, nil, "sh", "a b"},
+		{Config{Shell: []string{"/bin/bash", "-c"}}, "a", nil, nil, "bash", "a"},
+		{Config{}, `["make", "all"]`, nil, []string{"make", "all"}, "sh", ""},
+		{Config{}, "[\"/bin/bash\", \\\n \"-c\", \"echo $x\"]", nil, []string{"/bin/bash", "-c", "echo $x"}, "bash", "echo $x"},
+		{Config{}, "[ -f x ] && b", nil, nil, "sh", "[ -f x ] && b"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			r, err := tc.c.ParseRun(tc.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.Flags, tc.flags) {
+				t.Fatalf("wrong flags in %q:\nwant: %q\ngot:  %q", tc.payload, tc.flags, r.Flags)
+			}
+			if !reflect.DeepEqual(r.Args, tc.args) || r.Exec != (tc.args != nil) {
+				t.Fatalf("wrong args in %q:\nwant: %q\ngot:  %q", tc.payload, tc.args, r.Args)
+			}
+			if r.Shell != tc.shell {
+				t.Fatalf("wrong shell in %q: want %q, got %q", tc.payload, tc.shell, r.Shell)
+			}
+			got := ""
+			if r.File != nil {
+				got = single(t, r.File)
+			}
+			if got != tc.want {
+				t.Fatalf("ParseRun mismatch in %q:\nwant: %q\ngot:  %q", tc.payload, tc.want, got)
+			}
+		})
+	}
+}
+
+func single(t *testing.T, f *syntax.File) string {
+	r := &Run{File: f}
+	s, err := Config{Width: 1000, Indent: 1}.FormatRun(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// undo the lines of lists
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == '\n' {
+			i += 2
+			continue
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+func TestRunPositions(t *testing.T) {
+	t.Parallel()
+	r, err := Config{}.ParseRun("--network=none apt-get update && \\\n  # comment\n  apt-get install $HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := LintRun(r, nil)
+	if len(diags) != 1 {
+		t.Fatalf("want 1 diagnostic, got %d: %v", len(diags), diags)
+	}
+	if got, want := diags[0].Pos, (syntax.Position{Offset: 65, Line: 3, Column: 19}); got != want {
+		t.Fatalf("wrong position:\nwant: %+v\ngot:  %+v", want, got)
+	}
+	if got, want := diags[0].Code, "SH1001"; got != want {
+		t.Fatalf("wrong code: want %s, got %s", want, got)
+	}
+	r, err = Config{}.ParseRun(`["make", "all"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := LintRun(r, nil); diags != nil {
+		t.Fatalf("want no diagnostics in the exec form, got %v", diags)
+	}
+}
+
+func TestFormatRun(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		c             Config
+		payload, want string
+	}{
+		{Config{}, "make   all", "make all"},
+		{Config{}, "--network=none a&&b ||   c", "--network=none a \\\n    && b \\\n    || c"},
+		{Config{}, "set -e; a & b", "set -e; \\\n    a & \\\n    b"},
+		{Config{}, "if a; then \\\n b; \\\n c; \\\n fi", "if a; then b; c; fi"},
+		{
+			Config{Width: 40},
+			"apt-get update && apt-get install -y --no-install-recommends curl git",
+			"apt-get update \\\n    && apt-get install -y --no-install-recommends \\\n        curl \\\n        git",
+		},
+		{Config{Width: 20}, "pip install aaaaaaaa bbbbbbbb", "pip install \\\n        aaaaaaaa \\\n        bbbbbbbb"},
+		{Config{Escape: '`', Indent: 2}, "a `\n && b", "a `\n  && b"},
+		{Config{}, `[ "sh",  "-c",   "a && b" ]`, `["sh", "-c", "a && b"]`},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			r, err := tc.c.ParseRun(tc.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tc.c.FormatRun(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("FormatRun mismatch in %q:\nwant: %q\ngot:  %q", tc.payload, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package dot renders the structure of shell programs in the DOT
+// language of Graphviz, like their syntax trees, the calls between their
+// functions and the files they source, so that large scripts can be
+// visualized with tools like dot -Tsvg.
+package dot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+// maxLabel is the length after which the values in labels, like the
+// ones of literals, are cut.
+const maxLabel = 40
+
+// label quotes a label, cutting it if it's too long.
+func label(s string) string {
+	if r := []rune(s); len(r) > maxLabel {
+		s = string(r[:maxLabel-3]) + "..."
+	}
+	return strconv.Quote(s)
+}
+
+// AST writes the syntax tree of a node as a DOT digraph. Each node is
+// labelled with its type and, if it has any, its value or operator. The
+// line where a node starts is shown if it's not the one of its parent.
+// Children are in the order of syntax.Walk.
+//
+// Positions can only be shown if node is a *syntax.File.
+func AST(w io.Writer, node syntax.Node) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph ast {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+	v := &astVisitor{w: bw}
+	v.file, _ = node.(*syntax.File)
+	syntax.Walk(v, node)
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+type astVisitor struct {
+	w     *bufio.Writer
+	file  *syntax.File
+	last  int
+	stack []astNode
+}
+
+type astNode struct {
+	id, line int
+}
+
+func (v *astVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node == nil {
+		v.stack = v.stack[:len(v.stack)-1]
+		return v
+	}
+	v.last++
+	cur := astNode{id: v.last}
+	text := reflect.TypeOf(node).Elem().Name()
+	if detail := nodeDetail(node); detail != "" {
+		text += " " + detail
+	}
+	if v.file != nil && node.Pos() > 0 {
+		cur.line = v.file.Position(node.Pos()).Line
+	}
+	var parent astNode
+	if len(v.stack) > 0 {
+		parent = v.stack[len(v.stack)-1]
+	}
+	// only show the lines that differ from the parent's
+	if cur.line != parent.line {
+		text += fmt.Sprintf("\nline %d", cur.line)
+	}
+	fmt.Fprintf(v.w, "\tn%d [label=%s];\n", cur.id, label(text))
+	if parent.id > 0 {
+		fmt.Fprintf(v.w, "\tn%d -> n%d;\n", parent.id, cur.id)
+	}
+	v.stack = append(v.stack, cur)
+	return v
+}
+
+// nodeDetail returns the value or the operator of a node, if it has one.
+func nodeDetail(node syntax.Node) string {
+	switch x := node.(type) {
+	case *syntax.Lit:
+		return strconv.Quote(x.Value)
+	case *syntax.SglQuoted:
+		return strconv.Quote(x.Value)
+	case *syntax.Comment:
+		return strconv.Quote(x.Text)
+	case *syntax.TemplateExpr:
+		return strconv.Quote(x.Text)
+	case *syntax.FuncDecl:
+		return x.Name.Value
+	case *syntax.Redirect:
+		return x.Op.String()
+	case *syntax.BinaryCmd:
+		return x.Op.String()
+	case *syntax.BinaryArithm:
+		return x.Op.String()
+	case *syntax.UnaryArithm:
+		return x.Op.String()
+	case *syntax.BinaryTest:
+		return x.Op.String()
+	case *syntax.UnaryTest:
+		return x.Op.String()
+	case *syntax.ExtGlob:
+		return x.Op.String()
+	case *syntax.ProcSubst:
+		return x.Op.String()
+	}
+	return ""
+}
+
+// CallGraph writes the calls between the functions of a file as a DOT
+// digraph, resolved like analysis.Funcs does. The top level of the file
+// is a node of its own, and each function declared is another; if a
+// function is declared more than once, each declaration is a separate
+// node with the line where it's declared.
+//
+// Calls to functions declared only after they run are drawn dashed. If
+// external is true, the external commands called are drawn too, as
+// ellipses.
+func CallGraph(w io.Writer, f *syntax.File, external bool) error {
+	t := analysis.Funcs(f)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph calls {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+	fmt.Fprintln(bw, "\tmain [label=\"(top level)\", style=bold];")
+	ids := make(map[*analysis.Func]string, len(t.Funcs))
+	for i, fn := range t.Funcs {
+		id := fmt.Sprintf("f%d", i)
+		ids[fn] = id
+		text := fn.Name
+		if len(t.Lookup(fn.Name)) > 1 {
+			text += fmt.Sprintf("\nline %d", f.Position(fn.Pos()).Line)
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s];\n", id, label(text))
+	}
+	caller := func(c *analysis.Call) string {
+		if c.Caller == nil {
+			return "main"
+		}
+		return ids[c.Caller]
+	}
+	type edge struct{ from, to string }
+	seen := make(map[edge]bool)
+	cmds := make(map[string]string)
+	for _, c := range t.Calls {
+		var e edge
+		attrs := ""
+		switch c.Kind {
+		case analysis.FuncCall, analysis.UndefinedCall:
+			e = edge{caller(c), ids[c.Func]}
+			if c.Kind == analysis.UndefinedCall {
+				attrs = " [style=dashed]"
+			}
+		case analysis.ExternalCall:
+			if !external {
+				continue
+			}
+			id, ok := cmds[c.Name]
+			if !ok {
+				id = fmt.Sprintf("c%d", len(cmds))
+				cmds[c.Name] = id
+				fmt.Fprintf(bw, "\t%s [label=%s, shape=ellipse];\n", id, label(c.Name))
+			}
+			e = edge{caller(c), id}
+		default:
+			continue
+		}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		fmt.Fprintf(bw, "\t%s -> %s%s;\n", e.from, e.to, attrs)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// IncludeGraph writes the files that the files of a program source as a
+// DOT digraph, with the root file drawn bold. The includes that couldn't
+// be resolved are drawn dashed and red, to a node with their name, or
+// "?" if it isn't static.
+func IncludeGraph(w io.Writer, prog *loader.Program) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph includes {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+	ids := make(map[*syntax.File]string, len(prog.Files))
+	for i, f := range prog.Files {
+		id := fmt.Sprintf("f%d", i)
+		ids[f] = id
+		attrs := ""
+		if i == 0 {
+			attrs = ", style=bold"
+		}
+		fmt.Fprintf(bw, "\t%s [label=%s%s];\n", id, label(f.Name), attrs)
+	}
+	type edge struct{ from, to string }
+	seen := make(map[edge]bool)
+	missing := 0
+	for _, inc := range prog.Includes {
+		from := ids[inc.From]
+		if inc.File != nil {
+			e := edge{from, ids[inc.File]}
+			if !seen[e] {
+				seen[e] = true
+				fmt.Fprintf(bw, "\t%s -> %s;\n", e.from, e.to)
+			}
+			continue
+		}
+		name := inc.Name
+		if name == "" {
+			name = "?"
+		}
+		id := fmt.Sprintf("m%d", missing)
+		missing++
+		fmt.Fprintf(bw, "\t%s [label=%s, color=red, style=dashed];\n", id, label(name))
+		attrs := ""
+		if inc.Err != nil {
+			attrs = fmt.Sprintf(", tooltip=%s", strconv.Quote(inc.Err.Error()))
+		}
+		p := inc.From.Position(inc.Stmt.Pos())
+		fmt.Fprintf(bw, "\t%s -> %s [color=red, style=dashed, label=%s%s];\n",
+			from, id, label(fmt.Sprintf("line %d", p.Line)), attrs)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestAST(t *testing.T) {
+	t.Parallel()
+	f, err := syntax.Parse([]byte("a && b >x\n\nc 'd'"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := AST(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph ast {
+	node [shape=box, fontname=monospace];
+	n1 [label="File\nline 1"];
+	n2 [label="Stmt"];
+	n1 -> n2;
+	n3 [label="BinaryCmd &&"];
+	n2 -> n3;
+	n4 [label="Stmt"];
+	n3 -> n4;
+	n5 [label="CallExpr"];
+	n4 -> n5;
+	n6 [label="Word"];
+	n5 -> n6;
+	n7 [label="Lit \"a\""];
+	n6 -> n7;
+	n8 [label="Stmt"];
+	n3 -> n8;
+	n9 [label="CallExpr"];
+	n8 -> n9;
+	n10 [label="Word"];
+	n9 -> n10;
+	n11 [label="Lit \"b\""];
+	n10 -> n11;
+	n12 [label="Redirect >"];
+	n8 -> n12;
+	n13 [label="Word"];
+	n12 -> n13;
+	n14 [label="Lit \"x\""];
+	n13 -> n14;
+	n15 [label="Stmt\nline 3"];
+	n1 -> n15;
+	n16 [label="CallExpr"];
+	n15 -> n16;
+	n17 [label="Word"];
+	n16 -> n17;
+	n18 [label="Lit \"c\""];
+	n17 -> n18;
+	n19 [label="Word"];
+	n16 -> n19;
+	n20 [label="SglQuoted \"d\""];
+	n19 -> n20;
+}
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("AST mismatch:\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestCallGraph(t *testing.T) {
+	t.Parallel()
+	src := `
+foo() { bar; ls; }
+bar() { ls; ls; }
+foo
+baz
+baz() { :; }
+[ -n "$x" ] && baz() { cat; }
+`
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		external bool
+		want     string
+	}{
+		{false, `digraph calls {
+	node [shape=box, fontname=monospace];
+	main [label="(top level)", style=bold];
+	f0 [label="foo"];
+	f1 [label="bar"];
+	f2 [label="baz\nline 6"];
+	f3 [label="baz\nline 7"];
+	f0 -> f1;
+	main -> f0;
+	main -> f2 [style=dashed];
+}
+`},
+		{true, `digraph calls {
+	node [shape=box, fontname=monospace];
+	main [label="(top level)", style=bold];
+	f0 [label="foo"];
+	f1 [label="bar"];
+	f2 [label="baz\nline 6"];
+	f3 [label="baz\nline 7"];
+	f0 -> f1;
+	c0 [label="ls", shape=ellipse];
+	f0 -> c0;
+	f1 -> c0;
+	main -> f0;
+	main -> f2 [style=dashed];
+	c1 [label="cat", shape=ellipse];
+	f3 -> c1;
+}
+`},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := CallGraph(&buf, f, tc.external); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("CallGraph mismatch:\nwant: %s\ngot:  %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIncludeGraph(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"main.sh":  "source a.sh\n. b.sh\nsource $dyn\n. a.sh\nsource missing.sh",
+		"a.sh":     "source b.sh",
+		"b.sh":     "foo",
+		"other.sh": "bar",
+	}
+	c := loader.Config{
+		Dir: ".",
+		ReadFile: func(path string) ([]byte, error) {
+			src, ok := files[path]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(src), nil
+		},
+	}
+	prog, err := c.Load("main.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := IncludeGraph(&buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph includes {
+	node [shape=box, fontname=monospace];
+	f0 [label="main.sh", style=bold];
+	f1 [label="a.sh"];
+	f2 [label="b.sh"];
+	f0 -> f1;
+	f0 -> f2;
+	m0 [label="?", color=red, style=dashed];
+	f0 -> m0 [color=red, style=dashed, label="line 3"];
+	m1 [label="missing.sh", color=red, style=dashed];
+	f0 -> m1 [color=red, style=dashed, label="line 5", tooltip="missing.sh: file not found"];
+	f1 -> f2;
+}
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("IncludeGraph mismatch:\nwant: %s\ngot:  %s", want, got)
+	}
+}
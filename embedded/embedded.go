@@ -0,0 +1,126 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package embedded handles shell programs embedded in other files, like
+// the run: blocks of GitHub Actions workflows or the script: lists of
+// GitLab CI jobs, mapping their positions back to the outer file.
+package embedded
+
+import (
+	"bytes"
+
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Chunk is a piece of an embedded program, along with where it starts
+// in the outer file. Its text must be the same as in the outer file,
+// except for the indentation of its lines, like in a YAML literal block
+// scalar or a plain scalar on a single line.
+type Chunk struct {
+	Text string
+
+	// Line and Column are where Text starts in the outer file,
+	// starting at 1.
+	Line, Column int
+
+	// Indent is the column where the lines of Text after the first
+	// one start in the outer file, since their indentation isn't
+	// part of Text. If zero, Column is used.
+	Indent int
+}
+
+// Script is a shell program embedded in another file.
+type Script struct {
+	// File is the parsed program, made of the text of its chunks
+	// separated by newlines.
+	File *syntax.File
+
+	// Filename is the name of the outer file.
+	Filename string
+
+	// lines holds the outer line and column of each line of the
+	// program
+	lines []syntax.Position
+}
+
+// Parse parses a program embedded in an outer file, made of one or more
+// chunks, with the chunks of a GitLab CI script: list running one
+// after the other like the lines of a single program. For a GitHub
+// Actions workflow like:
+//
+//	steps:
+//	  - run: |
+//	      make
+//	      make test
+//
+// the chunk is {Text: "make\nmake test\n", Line: 3, Column: 7}.
+//
+// If the program can't be parsed, the error is a *syntax.ParseError
+// with its position in the outer file.
+func Parse(filename string, chunks []Chunk, mode syntax.ParseMode) (*Script, error) {
+	s := &Script{Filename: filename}
+	var src bytes.Buffer
+	for i, c := range chunks {
+		if i > 0 {
+			src.WriteByte('\n')
+		}
+		src.WriteString(c.Text)
+		indent := c.Indent
+		if indent == 0 {
+			indent = c.Column
+		}
+		n := 1 + bytes.Count([]byte(c.Text), []byte{'\n'})
+		for j := 0; j < n; j++ {
+			pos := syntax.Position{Line: c.Line + j, Column: indent}
+			if j == 0 {
+				pos.Column = c.Column
+			}
+			s.lines = append(s.lines, pos)
+		}
+	}
+	f, err := syntax.Parse(src.Bytes(), filename, mode)
+	if err != nil {
+		if perr, ok := err.(*syntax.ParseError); ok {
+			perr.Position = s.outer(perr.Position)
+		}
+		return nil, err
+	}
+	s.File = f
+	return s, nil
+}
+
+// outer maps a position in the program to one in the outer file.
+func (s *Script) outer(pos syntax.Position) syntax.Position {
+	if pos.Line < 1 || pos.Line > len(s.lines) {
+		return pos
+	}
+	start := s.lines[pos.Line-1]
+	return syntax.Position{
+		Line:   start.Line,
+		Column: start.Column + pos.Column - 1,
+	}
+}
+
+// Position returns the line and column in the outer file of a position
+// in the program. Its offset is zero, as the outer file isn't known.
+func (s *Script) Position(pos syntax.Pos) syntax.Position {
+	return s.outer(s.File.Position(pos))
+}
+
+// Lint lints the program, and returns the diagnostics with their
+// positions and filename in the outer file. If l is nil, the default
+// linter is used. Their fixes, if any, still apply to the program in
+// File, as its text isn't the same as in the outer file.
+func (s *Script) Lint(l *lint.Linter) []lint.Diagnostic {
+	if l == nil {
+		l = &lint.Linter{}
+	}
+	diags := l.Lint(s.File)
+	for i := range diags {
+		d := &diags[i]
+		d.Filename = s.Filename
+		d.Pos, d.End = s.outer(d.Pos), s.outer(d.End)
+	}
+	return diags
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package embedded
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		chunks []Chunk
+		want   []string
+	}{
+		{
+			// steps:
+			//   - run: |
+			//       make
+			//       echo $HOME
+			[]Chunk{{Text: "make\necho $HOME\n", Line: 3, Column: 7}},
+			[]string{"ci.yml:4:12: warning: unquoted $HOME is split and globbed; quote it like \"$HOME\" (SH1001)"},
+		},
+		{
+			// script:
+			//   - echo $HOME
+			//   - |
+			//     make
+			//     echo $PWD
+			[]Chunk{
+				{Text: "echo $HOME", Line: 2, Column: 5},
+				{Text: "make\necho $PWD\n", Line: 4, Column: 5},
+			},
+			[]string{
+				"ci.yml:2:10: warning: unquoted $HOME is split and globbed; quote it like \"$HOME\" (SH1001)",
+				"ci.yml:5:10: warning: unquoted $PWD is split and globbed; quote it like \"$PWD\" (SH1001)",
+			},
+		},
+		{
+			// run: echo $HOME && \
+			//   echo $PWD
+			[]Chunk{{Text: "echo $HOME && \\\necho $PWD", Line: 1, Column: 6, Indent: 3}},
+			[]string{
+				"ci.yml:1:11: warning: unquoted $HOME is split and globbed; quote it like \"$HOME\" (SH1001)",
+				"ci.yml:2:8: warning: unquoted $PWD is split and globbed; quote it like \"$PWD\" (SH1001)",
+			},
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			s, err := Parse("ci.yml", tc.chunks, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range s.Lint(nil) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch:\nwant: %q\ngot:  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+	_, err := Parse("ci.yml", []Chunk{
+		{Text: "make", Line: 10, Column: 5},
+		{Text: "echo 'x", Line: 12, Column: 9},
+	}, 0)
+	perr, ok := err.(*syntax.ParseError)
+	if !ok {
+		t.Fatalf("want a *syntax.ParseError, got %v", err)
+	}
+	if perr.Line != 12 || perr.Column != 14 {
+		t.Fatalf("wrong error position: %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Arithm evaluates an arithmetic expression, like the ones in $((expr))
+// or ((expr)).
+func (c Config) Arithm(expr syntax.ArithmExpr) (int, error) {
+	e := &expander{cfg: c}
+	n := e.arithm(expr)
+	return n, e.err
+}
+
+func (e *expander) arithm(expr syntax.ArithmExpr) int {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return e.arithmStr(e.literal(x))
+	case *syntax.ParenArithm:
+		return e.arithm(x.X)
+	case *syntax.UnaryArithm:
+		switch x.Op {
+		case syntax.Inc, syntax.Dec:
+			name, ok := arithmName(x.X)
+			if !ok {
+				e.fail(fmt.Errorf("%s must be followed by a name", x.Op))
+				return 0
+			}
+			old := atoi(e.getVar(name))
+			val := old + 1
+			if x.Op == syntax.Dec {
+				val = old - 1
+			}
+			e.set(name, strconv.Itoa(val))
+			if x.Post {
+				return old
+			}
+			return val
+		}
+		val := e.arithm(x.X)
+		switch x.Op {
+		case syntax.Not:
+			return oneIf(val == 0)
+		case syntax.Minus:
+			return -val
+		default: // syntax.Plus
+			return val
+		}
+	case *syntax.BinaryArithm:
+		switch x.Op {
+		case syntax.Assgn, syntax.AddAssgn, syntax.SubAssgn,
+			syntax.MulAssgn, syntax.QuoAssgn, syntax.RemAssgn,
+			syntax.AndAssgn, syntax.OrAssgn, syntax.XorAssgn,
+			syntax.ShlAssgn, syntax.ShrAssgn:
+			return e.assgnArit(x)
+		case syntax.Quest: // Colon can't happen here
+			cond := e.arithm(x.X)
+			b2 := x.Y.(*syntax.BinaryArithm) // must have Op==Colon
+			if cond != 0 {
+				return e.arithm(b2.X)
+			}
+			return e.arithm(b2.Y)
+		case syntax.AndArit:
+			return oneIf(e.arithm(x.X) != 0 && e.arithm(x.Y) != 0)
+		case syntax.OrArit:
+			return oneIf(e.arithm(x.X) != 0 || e.arithm(x.Y) != 0)
+		}
+		return e.binArit(x.Op, e.arithm(x.X), e.arithm(x.Y))
+	default:
+		e.fail(fmt.Errorf("unexpected arithm expr: %T", x))
+		return 0
+	}
+}
+
+// arithmStr evaluates a string as an arithmetic expression, like the
+// values of variables and the indexes of arrays, which are like "1" or
+// "i+1".
+func (e *expander) arithmStr(str string) int {
+	// recursively fetch vars
+	for i := 0; i < 100 && validName(str); i++ {
+		str = e.getVar(str)
+	}
+	if isNumber(str) {
+		return atoi(str)
+	}
+	if e.arithmDepth++; e.arithmDepth > 100 {
+		e.fail(fmt.Errorf("%s: expression recursion level exceeded", str))
+		return 0
+	}
+	defer func() { e.arithmDepth-- }()
+	f, err := syntax.Parse([]byte("(("+str+"))"), "", 0)
+	if err != nil || len(f.Stmts) != 1 {
+		e.fail(fmt.Errorf("%s: syntax error in expression", str))
+		return 0
+	}
+	ac, ok := f.Stmts[0].Cmd.(*syntax.ArithmCmd)
+	if !ok || ac.X == nil {
+		e.fail(fmt.Errorf("%s: syntax error in expression", str))
+		return 0
+	}
+	if w, ok := ac.X.(*syntax.Word); ok && e.literal(w) == str {
+		// parsing it again would give the same word
+		e.fail(fmt.Errorf("%s: syntax error in expression", str))
+		return 0
+	}
+	return e.arithm(ac.X)
+}
+
+// isNumber reports whether a string is empty or a number like 10, 0x1f
+// or 2#101, as opposed to an expression.
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "-")
+	if s == "" {
+		return true
+	}
+	if s[0] < '0' || s[0] > '9' {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case c == '#', c == '@', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// arithmName returns the variable name that an arithmetic operand
+// refers to, if any.
+func arithmName(expr syntax.ArithmExpr) (string, bool) {
+	w, ok := expr.(*syntax.Word)
+	if !ok {
+		return "", false
+	}
+	s, ok := syntax.StaticValue(w)
+	return s, ok && validName(s)
+}
+
+func validName(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// atoi parses an integer like the shell does, supporting the 0x and 0
+// prefixes for hexadecimal and octal numbers, as well as arbitrary
+// bases like in 2#101. Invalid numbers are zero.
+func atoi(s string) int {
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	var n int64
+	var err error
+	if i := strings.IndexByte(s, '#'); i > 0 {
+		base, err2 := strconv.Atoi(s[:i])
+		if err2 != nil || base < 2 || base > 36 {
+			return 0
+		}
+		n, err = strconv.ParseInt(s[i+1:], base, 64)
+	} else {
+		n, err = strconv.ParseInt(s, 0, 64)
+	}
+	if err != nil {
+		return 0
+	}
+	if neg {
+		n = -n
+	}
+	return int(n)
+}
+
+func (e *expander) assgnArit(b *syntax.BinaryArithm) int {
+	name, ok := arithmName(b.X)
+	if !ok {
+		e.fail(fmt.Errorf("attempted assignment to non-variable"))
+		return 0
+	}
+	val := atoi(e.getVar(name))
+	arg := e.arithm(b.Y)
+	switch b.Op {
+	case syntax.Assgn:
+		val = arg
+	case syntax.AddAssgn:
+		val += arg
+	case syntax.SubAssgn:
+		val -= arg
+	case syntax.MulAssgn:
+		val *= arg
+	case syntax.QuoAssgn, syntax.RemAssgn:
+		if arg == 0 {
+			e.fail(fmt.Errorf("division by zero"))
+			return 0
+		}
+		if b.Op == syntax.QuoAssgn {
+			val /= arg
+		} else {
+			val %= arg
+		}
+	case syntax.AndAssgn:
+		val &= arg
+	case syntax.OrAssgn:
+		val |= arg
+	case syntax.XorAssgn:
+		val ^= arg
+	case syntax.ShlAssgn:
+		val <<= uint(arg)
+	case syntax.ShrAssgn:
+		val >>= uint(arg)
+	}
+	e.set(name, strconv.Itoa(val))
+	return val
+}
+
+func intPow(a, b int) int {
+	p := 1
+	for b > 0 {
+		if b&1 != 0 {
+			p *= a
+		}
+		b >>= 1
+		a *= a
+	}
+	return p
+}
+
+func (e *expander) binArit(op syntax.BinAritOperator, x, y int) int {
+	switch op {
+	case syntax.Add:
+		return x + y
+	case syntax.Sub:
+		return x - y
+	case syntax.Mul:
+		return x * y
+	case syntax.Quo, syntax.Rem:
+		if y == 0 {
+			e.fail(fmt.Errorf("division by zero"))
+			return 0
+		}
+		if op == syntax.Quo {
+			return x / y
+		}
+		return x % y
+	case syntax.Pow:
+		return intPow(x, y)
+	case syntax.Eql:
+		return oneIf(x == y)
+	case syntax.Gtr:
+		return oneIf(x > y)
+	case syntax.Lss:
+		return oneIf(x < y)
+	case syntax.Neq:
+		return oneIf(x != y)
+	case syntax.Leq:
+		return oneIf(x <= y)
+	case syntax.Geq:
+		return oneIf(x >= y)
+	case syntax.And:
+		return x & y
+	case syntax.Or:
+		return x | y
+	case syntax.Xor:
+		return x ^ y
+	case syntax.Shr:
+		return x >> uint(y)
+	case syntax.Shl:
+		return x << uint(y)
+	default: // syntax.Comma
+		return y
+	}
+}
+
+func oneIf(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Braces performs Bash brace expansion on a word, such as a{b,c}d or
+// {1..10..2}. It returns the word itself if it contains no brace
+// expansions.
+//
+// Only the unquoted literal parts of a word take part in brace
+// expansions, although the alternatives may contain other parts like
+// in {a,${b}}. Like in Bash, uses of braces that aren't valid brace
+// expansions, like {a}, are kept as literals.
+func Braces(word *syntax.Word) []*syntax.Word {
+	items := braceItems(word)
+	alts := expandBraces(items)
+	if len(alts) == 1 && len(alts[0]) == len(items) {
+		return []*syntax.Word{word}
+	}
+	words := make([]*syntax.Word, len(alts))
+	for i, alt := range alts {
+		words[i] = itemsWord(alt)
+	}
+	return words
+}
+
+// braceItem is either a character of an unquoted literal, which may be
+// escaped, or any other word part.
+type braceItem struct {
+	raw string
+	pos syntax.Pos
+	// ch is the unescaped character, or 0 if escaped or not a literal
+	ch   byte
+	part syntax.WordPart
+}
+
+func braceItems(word *syntax.Word) []braceItem {
+	var items []braceItem
+	for _, wp := range word.Parts {
+		lit, ok := wp.(*syntax.Lit)
+		if !ok {
+			items = append(items, braceItem{part: wp})
+			continue
+		}
+		for i := 0; i < len(lit.Value); i++ {
+			item := braceItem{pos: lit.ValuePos + syntax.Pos(i)}
+			if lit.Value[i] == '\\' && i+1 < len(lit.Value) {
+				item.raw = lit.Value[i : i+2]
+				i++
+			} else {
+				item.raw = lit.Value[i : i+1]
+				item.ch = lit.Value[i]
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func itemsWord(items []braceItem) *syntax.Word {
+	w := &syntax.Word{}
+	var buf bytes.Buffer
+	var pos syntax.Pos
+	flush := func() {
+		if buf.Len() > 0 {
+			w.Parts = append(w.Parts, &syntax.Lit{
+				ValuePos: pos,
+				ValueEnd: pos + syntax.Pos(buf.Len()),
+				Value:    buf.String(),
+			})
+			buf.Reset()
+		}
+	}
+	for _, item := range items {
+		if item.part != nil {
+			flush()
+			w.Parts = append(w.Parts, item.part)
+			continue
+		}
+		if buf.Len() == 0 {
+			pos = item.pos
+		}
+		buf.WriteString(item.raw)
+	}
+	flush()
+	return w
+}
+
+func litItems(s string, pos syntax.Pos) []braceItem {
+	items := make([]braceItem, len(s))
+	for i := range items {
+		items[i] = braceItem{raw: s[i : i+1], ch: s[i], pos: pos}
+	}
+	return items
+}
+
+func expandBraces(items []braceItem) [][]braceItem {
+	for i, item := range items {
+		if item.ch != '{' {
+			continue
+		}
+		end, commas := braceEnd(items, i)
+		if end < 0 {
+			continue
+		}
+		var alts [][]braceItem
+		if len(commas) > 0 {
+			start := i + 1
+			for _, comma := range append(commas, end) {
+				alts = append(alts, expandBraces(items[start:comma])...)
+				start = comma + 1
+			}
+		} else if seq := braceSeq(items[i+1 : end]); seq != nil {
+			for _, s := range seq {
+				alts = append(alts, litItems(s, item.pos))
+			}
+		} else {
+			continue
+		}
+		suffixes := expandBraces(items[end+1:])
+		var result [][]braceItem
+		for _, alt := range alts {
+			for _, suffix := range suffixes {
+				var joined []braceItem
+				joined = append(joined, items[:i]...)
+				joined = append(joined, alt...)
+				joined = append(joined, suffix...)
+				result = append(result, joined)
+			}
+		}
+		return result
+	}
+	return [][]braceItem{items}
+}
+
+// braceEnd returns the index of the brace closing the one at start, or
+// -1 if there is none, along with the indexes of the commas directly
+// within the braces.
+func braceEnd(items []braceItem, start int) (int, []int) {
+	depth := 0
+	var commas []int
+	for i := start; i < len(items); i++ {
+		switch items[i].ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// braceSeq returns the elements of a sequence expression like 1..5 or
+// a..e..2, or nil if the items don't form one.
+func braceSeq(items []braceItem) []string {
+	var buf bytes.Buffer
+	for _, item := range items {
+		if item.ch == 0 {
+			return nil
+		}
+		buf.WriteByte(item.ch)
+	}
+	fields := strings.Split(buf.String(), "..")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil
+	}
+	step := 1
+	if len(fields) == 3 {
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+		}
+		if n != 0 {
+			step = n
+		}
+	}
+	from, to := fields[0], fields[1]
+	if isSeqChar(from) && isSeqChar(to) {
+		var seq []string
+		for _, n := range seqInts(int(from[0]), int(to[0]), step) {
+			seq = append(seq, string(rune(n)))
+		}
+		return seq
+	}
+	n1, err1 := strconv.Atoi(from)
+	n2, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	// a leading zero pads all the numbers to the same width
+	width := 0
+	if zeroPadded(from) || zeroPadded(to) {
+		width = len(from)
+		if len(to) > width {
+			width = len(to)
+		}
+	}
+	var seq []string
+	for _, n := range seqInts(n1, n2, step) {
+		s := strconv.Itoa(n)
+		if width > 0 {
+			s = padNumber(n, width)
+		}
+		seq = append(seq, s)
+	}
+	return seq
+}
+
+func isSeqChar(s string) bool {
+	return len(s) == 1 && ('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+}
+
+func zeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+func padNumber(n, width int) string {
+	s := strconv.Itoa(n)
+	neg := n < 0
+	if neg {
+		s = s[1:]
+		width--
+	}
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+func seqInts(from, to, step int) []int {
+	var ns []int
+	if from <= to {
+		for n := from; n <= to; n += step {
+			ns = append(ns, n)
+		}
+	} else {
+		for n := from; n >= to; n -= step {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var bracesTests = []struct {
+	in   string
+	want string
+}{
+	{"a", "a"},
+	{"{a,b}", "a b"},
+	{"x{a,b}y", "xay xby"},
+	{"{a,b}{1,2}", "a1 a2 b1 b2"},
+	{"{a,{b,c}d}", "a bd cd"},
+	{"{,a}x", "x ax"},
+	{"{a}", "{a}"},
+	{"{}", "{}"},
+	{"{a,b", "{a,b"},
+	{"a,b}", "a,b}"},
+	{"{a}{b,c}", "{a}b {a}c"},
+	{`\{a,b}`, "{a,b}"},
+	{`{a\,b}`, "{a,b}"},
+	{`{a,b\}}`, "a b}"},
+	{"'{a,b}'", "{a,b}"},
+	{"{1..3}", "1 2 3"},
+	{"{3..1}", "3 2 1"},
+	{"{1..10..4}", "1 5 9"},
+	{"{10..1..-4}", "10 6 2"},
+	{"{-2..1}", "-2 -1 0 1"},
+	{"{01..10..3}", "01 04 07 10"},
+	{"{a..e..2}", "a c e"},
+	{"{C..A}", "C B A"},
+	{"{1..a}", "{1..a}"},
+	{"{1..2..3..4}", "{1..2..3..4}"},
+	{"{a,b}{1..2}", "a1 a2 b1 b2"},
+	{"{x,${foo}}z", "xz barz"},
+}
+
+func TestBraces(t *testing.T) {
+	t.Parallel()
+	cfg := Config{Env: mapEnv{"foo": "bar"}}
+	for i, tc := range bracesTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			word := parseWords(t, tc.in)[0]
+			var got []string
+			for _, w := range Braces(word) {
+				s, err := cfg.Literal(w)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, s)
+			}
+			if want := strings.Fields(tc.want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("Braces mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, want, got)
+			}
+		})
+	}
+}
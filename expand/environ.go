@@ -0,0 +1,420 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variable is a shell variable, along with its attributes. The zero
+// value is an unset variable.
+type Variable struct {
+	// Set is whether the variable is set, even if to an empty value.
+	Set bool
+
+	// Exported variables are passed on to the programs that are run.
+	Exported bool
+
+	// ReadOnly variables cannot be modified nor unset.
+	ReadOnly bool
+
+	// Value is the value of a string variable.
+	Value string
+
+	// List holds the elements of an indexed array, and Map those of
+	// an associative array. At most one of them is non-nil.
+	List []string
+	Map  map[string]string
+
+	// Indexes holds the index of each element in List, in increasing
+	// order, if the indexed array is sparse. If nil, the elements are
+	// at the indexes from 0 to len(List)-1.
+	Indexes []int
+}
+
+// String returns the value of a variable as a string. Like with
+// "$arr", arrays result in their element at index 0.
+func (v Variable) String() string {
+	if v.Map != nil {
+		return v.Map["0"]
+	}
+	val, _ := v.Elem(0)
+	return val
+}
+
+// position returns the position in List of the element at index i, and
+// whether it exists. If it doesn't, the position is where it would be
+// inserted.
+func (v Variable) position(i int) (int, bool) {
+	if v.Indexes == nil {
+		if i > len(v.List) {
+			return len(v.List), false
+		}
+		return i, i < len(v.List)
+	}
+	j := sort.SearchInts(v.Indexes, i)
+	return j, j < len(v.Indexes) && v.Indexes[j] == i
+}
+
+// Elem returns the element at index i of an indexed array, and whether
+// it is set. A string variable that is set is an array with a single
+// element.
+func (v Variable) Elem(i int) (string, bool) {
+	switch {
+	case v.Map != nil, i < 0:
+		return "", false
+	case v.List == nil:
+		return v.Value, v.Set && i == 0
+	}
+	j, ok := v.position(i)
+	if !ok {
+		return "", false
+	}
+	return v.List[j], true
+}
+
+// MaxIndex returns the largest index of the elements in an indexed
+// array, or -1 if it has none.
+func (v Variable) MaxIndex() int {
+	switch {
+	case v.Map != nil:
+		return -1
+	case v.List == nil:
+		if v.Set {
+			return 0
+		}
+		return -1
+	case v.Indexes != nil && len(v.Indexes) > 0:
+		return v.Indexes[len(v.Indexes)-1]
+	}
+	return len(v.List) - 1
+}
+
+// Keys returns the indexes of the elements of an indexed array, or the
+// keys of an associative array sorted by name, like ${!arr[@]}.
+func (v Variable) Keys() []string {
+	if v.Map != nil {
+		keys := make([]string, 0, len(v.Map))
+		for key := range v.Map {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	if v.List == nil {
+		if v.Set {
+			return []string{"0"}
+		}
+		return nil
+	}
+	keys := make([]string, len(v.List))
+	for j := range v.List {
+		i := j
+		if v.Indexes != nil {
+			i = v.Indexes[j]
+		}
+		keys[j] = strconv.Itoa(i)
+	}
+	return keys
+}
+
+// SetElem returns the variable as an indexed array with the element at
+// index i set to value. The elements are copied, as they may be shared
+// with other variables. i must not be negative.
+func (v Variable) SetElem(i int, value string) Variable {
+	if v.List == nil {
+		v.List = []string{}
+		if v.Set {
+			v.List = append(v.List, v.Value)
+		}
+	}
+	v.Set, v.Value, v.Map = true, "", nil
+	j, ok := v.position(i)
+	list := append([]string{}, v.List...)
+	if ok {
+		list[j] = value
+		v.List = list
+		return v
+	}
+	if v.Indexes == nil && i == len(list) {
+		v.List = append(list, value)
+		return v
+	}
+	indexes := v.Indexes
+	if indexes == nil {
+		// the array becomes sparse
+		indexes = make([]int, len(list))
+		for k := range indexes {
+			indexes[k] = k
+		}
+	}
+	v.List = append(list[:j], append([]string{value}, list[j:]...)...)
+	v.Indexes = append(append(append([]int{}, indexes[:j]...), i), indexes[j:]...)
+	return v
+}
+
+// UnsetElem returns the variable as an indexed array without the
+// element at index i, as done by "unset arr[i]". The elements are
+// copied, as they may be shared with other variables.
+func (v Variable) UnsetElem(i int) Variable {
+	if v.List == nil {
+		if v.Set && i == 0 {
+			v.Value, v.List = "", []string{}
+		}
+		return v
+	}
+	j, ok := v.position(i)
+	if !ok {
+		return v
+	}
+	list := append(append([]string{}, v.List[:j]...), v.List[j+1:]...)
+	if v.Indexes == nil && j == len(list) {
+		// the last element, so the array isn't sparse
+		v.List = list
+		return v
+	}
+	indexes := v.Indexes
+	if indexes == nil {
+		indexes = make([]int, len(v.List))
+		for k := range indexes {
+			indexes[k] = k
+		}
+	}
+	v.List = list
+	v.Indexes = append(append([]int{}, indexes[:j]...), indexes[j+1:]...)
+	return v
+}
+
+// Environ is the set of variables that expansions read and write.
+//
+// Special parameters like $? that aren't positional parameters are
+// looked up via Get too.
+type Environ interface {
+	// Get returns a variable, which is unset if it doesn't exist.
+	Get(name string) Variable
+
+	// Set sets a variable, as done by ${name:=value} or by
+	// assignments in arithmetic expressions. Setting a variable that
+	// isn't Set unsets it. An error is returned if the variable
+	// can't be modified, such as when it is read-only.
+	Set(name string, vr Variable) error
+
+	// Each calls fn for every variable that is set, in no particular
+	// order, until it returns false.
+	Each(fn func(name string, vr Variable) bool)
+}
+
+// ReadOnlyError is returned when modifying a read-only variable.
+type ReadOnlyError struct {
+	Name string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s: readonly variable", e.Name)
+}
+
+// MapEnviron is an Environ backed by a map.
+type MapEnviron map[string]Variable
+
+// ListEnviron returns a MapEnviron with the exported variables in a list
+// of "key=value" pairs, like the ones returned by os.Environ. Malformed
+// pairs are ignored, and later pairs override earlier ones.
+func ListEnviron(pairs ...string) MapEnviron {
+	m := make(MapEnviron, len(pairs))
+	for _, kv := range pairs {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			continue
+		}
+		m[kv[:i]] = Variable{Set: true, Exported: true, Value: kv[i+1:]}
+	}
+	return m
+}
+
+func (m MapEnviron) Get(name string) Variable { return m[name] }
+
+func (m MapEnviron) Set(name string, vr Variable) error {
+	if m[name].ReadOnly {
+		return &ReadOnlyError{Name: name}
+	}
+	if !vr.Set {
+		delete(m, name)
+		return nil
+	}
+	m[name] = vr
+	return nil
+}
+
+func (m MapEnviron) Each(fn func(name string, vr Variable) bool) {
+	for name, vr := range m {
+		if !fn(name, vr) {
+			return
+		}
+	}
+}
+
+// OSEnviron is an Environ backed by the environment of the current
+// process. All of its variables are exported strings; setting arrays
+// or read-only variables results in an error.
+var OSEnviron Environ = osEnviron{}
+
+type osEnviron struct{}
+
+func (osEnviron) Get(name string) Variable {
+	val, ok := os.LookupEnv(name)
+	return Variable{Set: ok, Exported: ok, Value: val}
+}
+
+func (osEnviron) Set(name string, vr Variable) error {
+	switch {
+	case !vr.Set:
+		return os.Unsetenv(name)
+	case vr.List != nil || vr.Map != nil:
+		return fmt.Errorf("%s: cannot set arrays in the process environment", name)
+	case vr.ReadOnly:
+		return fmt.Errorf("%s: cannot set read-only variables in the process environment", name)
+	}
+	return os.Setenv(name, vr.Value)
+}
+
+func (osEnviron) Each(fn func(name string, vr Variable) bool) {
+	for name, vr := range ListEnviron(os.Environ()...) {
+		if !fn(name, vr) {
+			return
+		}
+	}
+}
+
+// Pairs returns the exported string variables of an Environ as
+// "key=value" pairs, sorted by name, like the environment that a
+// program is run with.
+func Pairs(env Environ) []string {
+	var pairs []string
+	env.Each(func(name string, vr Variable) bool {
+		if vr.Exported && vr.List == nil && vr.Map == nil {
+			pairs = append(pairs, name+"="+vr.Value)
+		}
+		return true
+	})
+	sort.Strings(pairs)
+	return pairs
+}
+
+// Snapshot returns a copy of the variables that are set in an Environ,
+// which doesn't change along with it.
+func Snapshot(env Environ) MapEnviron {
+	m := make(MapEnviron)
+	env.Each(func(name string, vr Variable) bool {
+		if !vr.Set {
+			return true
+		}
+		if vr.List != nil {
+			vr.List = append([]string{}, vr.List...)
+		}
+		if vr.Indexes != nil {
+			vr.Indexes = append([]int{}, vr.Indexes...)
+		}
+		if vr.Map != nil {
+			vm := make(map[string]string, len(vr.Map))
+			for k, v := range vr.Map {
+				vm[k] = v
+			}
+			vr.Map = vm
+		}
+		m[name] = vr
+		return true
+	})
+	return m
+}
+
+// ChangeKind is the kind of change made to a variable.
+type ChangeKind int
+
+const (
+	VarSet      ChangeKind = iota // set when it wasn't set before
+	VarModified                   // a new value or new attributes
+	VarUnset                      // unset when it was set before
+	VarExported                   // only exported, with the same value
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case VarSet:
+		return "set"
+	case VarModified:
+		return "modified"
+	case VarUnset:
+		return "unset"
+	}
+	return "exported"
+}
+
+// Change is a change made to a variable, with its old and new state.
+type Change struct {
+	Name     string
+	Kind     ChangeKind
+	Old, New Variable
+}
+
+// Diff returns the changes made to the variables from one Environ to
+// another, sorted by name. It's meant to compare Snapshots, like the
+// ones from before and after running a program, to find what it
+// changed.
+func Diff(before, after Environ) []Change {
+	var changes []Change
+	after.Each(func(name string, vr Variable) bool {
+		if !vr.Set {
+			return true
+		}
+		old := before.Get(name)
+		switch {
+		case !old.Set:
+			changes = append(changes, Change{name, VarSet, old, vr})
+		case !sameValue(old, vr) || old.ReadOnly != vr.ReadOnly || (old.Exported && !vr.Exported):
+			changes = append(changes, Change{name, VarModified, old, vr})
+		case !old.Exported && vr.Exported:
+			changes = append(changes, Change{name, VarExported, old, vr})
+		}
+		return true
+	})
+	before.Each(func(name string, vr Variable) bool {
+		if vr.Set && !after.Get(name).Set {
+			changes = append(changes, Change{name, VarUnset, vr, Variable{}})
+		}
+		return true
+	})
+	sort.Sort(byName(changes))
+	return changes
+}
+
+func sameValue(v1, v2 Variable) bool {
+	if v1.Value != v2.Value || (v1.List == nil) != (v2.List == nil) ||
+		(v1.Map == nil) != (v2.Map == nil) ||
+		len(v1.List) != len(v2.List) || len(v1.Map) != len(v2.Map) ||
+		!reflect.DeepEqual(v1.Indexes, v2.Indexes) {
+		return false
+	}
+	for i, s := range v1.List {
+		if v2.List[i] != s {
+			return false
+		}
+	}
+	for k, s := range v1.Map {
+		if s2, ok := v2.Map[k]; !ok || s2 != s {
+			return false
+		}
+	}
+	return true
+}
+
+type byName []Change
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
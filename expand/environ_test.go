@@ -0,0 +1,93 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListEnviron(t *testing.T) {
+	t.Parallel()
+	env := ListEnviron("A=1", "B=", "malformed", "=x", "A=2", "C=a=b")
+	want := []string{"A=2", "B=", "C=a=b"}
+	if got := Pairs(env); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pairs mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	if vr := env.Get("B"); !vr.Set || !vr.Exported || vr.Value != "" {
+		t.Fatalf("unexpected B: %#v", vr)
+	}
+	if vr := env.Get("D"); vr.Set {
+		t.Fatalf("unexpected D: %#v", vr)
+	}
+}
+
+func TestMapEnviron(t *testing.T) {
+	t.Parallel()
+	env := MapEnviron{
+		"ro":  {Set: true, ReadOnly: true},
+		"arr": {Set: true, Exported: true, List: []string{"a", "b"}},
+		"str": {Set: true, Exported: true, Value: "s"},
+	}
+	if err := env.Set("ro", Variable{Set: true, Value: "y"}); err == nil {
+		t.Fatalf("expected an error when setting a read-only variable")
+	}
+	if err := env.Set("ro", Variable{}); err == nil {
+		t.Fatalf("expected an error when unsetting a read-only variable")
+	}
+	if err := env.Set("str", Variable{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env["str"]; ok {
+		t.Fatalf("setting a variable that isn't set did not unset it")
+	}
+	if got := env.Get("arr").String(); got != "a" {
+		t.Fatalf("array String mismatch\nwant: %q\ngot:  %q", "a", got)
+	}
+	// arrays are never exported
+	if got := Pairs(env); len(got) > 0 {
+		t.Fatalf("unexpected pairs: %q", got)
+	}
+	cfg := Config{Env: env}
+	word := parseWords(t, "${ro:=new}")[0]
+	_, err := cfg.Literal(word)
+	if want := "ro: readonly variable"; err == nil || err.Error() != want {
+		t.Fatalf("error mismatch\nwant: %q\ngot:  %v", want, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	before := MapEnviron{
+		"same":  {Set: true, Value: "x"},
+		"mod":   {Set: true, Value: "x"},
+		"gone":  {Set: true, Value: "x"},
+		"exp":   {Set: true, Value: "x"},
+		"unexp": {Set: true, Exported: true, Value: "x"},
+		"arr":   {Set: true, List: []string{"a", "b"}},
+	}
+	snap := Snapshot(before)
+	after := Snapshot(before)
+	after.Set("mod", Variable{Set: true, Value: "y"})
+	after.Set("gone", Variable{})
+	after.Set("exp", Variable{Set: true, Exported: true, Value: "x"})
+	after.Set("unexp", Variable{Set: true, Value: "x"})
+	after.Set("new", Variable{Set: true, Value: "z"})
+	after["arr"].List[1] = "c"
+	var got []string
+	for _, c := range Diff(before, after) {
+		got = append(got, c.Name+" "+c.Kind.String())
+	}
+	want := []string{"arr modified", "exp exported", "gone unset",
+		"mod modified", "new set", "unexp modified"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	if !reflect.DeepEqual(snap, before) {
+		t.Fatalf("changing a snapshot changed the original")
+	}
+	if changes := Diff(before, snap); len(changes) > 0 {
+		t.Fatalf("unexpected changes: %v", changes)
+	}
+}
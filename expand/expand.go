@@ -0,0 +1,935 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package expand implements the expansions that a shell performs on
+// words, such as parameter expansion, arithmetic expansion and field
+// splitting, followed by quote removal.
+package expand
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mvdan/sh/pattern"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Config specifies how words are expanded.
+type Config struct {
+	// Env holds the variables. If nil, no variables are set and
+	// assignments are discarded.
+	Env Environ
+
+	// Params are the positional parameters, like $1, which also
+	// determine $#, $@ and $*.
+	Params []string
+
+	// CmdSubst runs the statements of a command substitution,
+	// writing their standard output to w. If nil, expanding a
+	// command substitution results in an error.
+	CmdSubst func(w io.Writer, cs *syntax.CmdSubst) error
+
+	// ProcSubst starts the statements of a process substitution like
+	// <(cmd), and returns the path of the file connecting them to the
+	// command being run. If nil, expanding a process substitution
+	// results in an error.
+	ProcSubst func(ps *syntax.ProcSubst) (string, error)
+
+	// FS is the file system that filename patterns are expanded
+	// against. If nil, the operating system's is used.
+	FS FS
+
+	// NoGlob disables filename expansion, like set -f.
+	NoGlob bool
+
+	// NullGlob removes the patterns that match no files, instead of
+	// keeping them as they are.
+	NullGlob bool
+
+	// DotGlob makes patterns match files starting with a dot, even
+	// if the pattern doesn't start with a dot.
+	DotGlob bool
+
+	// GlobStar makes ** match any number of directories.
+	GlobStar bool
+
+	// NoUnset makes expanding an unset parameter an error, like
+	// set -u, unless the expansion provides a default like ${x-}.
+	NoUnset bool
+}
+
+// UnsetParameterError is returned when expanding a parameter like
+// ${name:?message} while name is unset or null.
+type UnsetParameterError struct {
+	Exp     *syntax.ParamExp
+	Message string
+}
+
+func (e *UnsetParameterError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Exp.Param.Value, e.Message)
+}
+
+// Fields expands a list of words into fields, like the arguments to a
+// command. Brace expansion is performed first. Unquoted expansions
+// are then split as per $IFS, and the fields are expanded as filename
+// patterns unless NoGlob is set.
+func (c Config) Fields(words ...*syntax.Word) ([]string, error) {
+	e := &expander{cfg: c}
+	var fields []string
+	for _, w := range words {
+		for _, w := range Braces(w) {
+			for _, field := range e.wordFields(w) {
+				fields = append(fields, e.globField(field)...)
+			}
+			if e.err != nil {
+				return nil, e.err
+			}
+		}
+	}
+	return fields, nil
+}
+
+// Literal expands a word into a single string, without field
+// splitting, like in the value of an assignment.
+func (c Config) Literal(w *syntax.Word) (string, error) {
+	e := &expander{cfg: c}
+	s := e.literal(w)
+	return s, e.err
+}
+
+// Document expands a word like the body of a heredoc, which is like
+// within double quotes except that double quotes aren't special.
+func (c Config) Document(w *syntax.Word) (string, error) {
+	e := &expander{cfg: c}
+	var buf bytes.Buffer
+	for _, wp := range w.Parts {
+		if lit, ok := wp.(*syntax.Lit); ok {
+			buf.WriteString(unescape(lit.Value, docQuotable))
+			continue
+		}
+		buf.WriteString(e.wordPart(wp))
+	}
+	return buf.String(), e.err
+}
+
+// Pattern expands a word into a pattern, as understood by the pattern
+// package. The quoted parts of the word are escaped, so that only the
+// unquoted parts match more than themselves.
+func (c Config) Pattern(w *syntax.Word) (string, error) {
+	e := &expander{cfg: c}
+	s := e.pattern(w)
+	return s, e.err
+}
+
+type expander struct {
+	cfg Config
+
+	// err is the first error found, which stops the expansion
+	err error
+
+	// arithmDepth is how many strings are being evaluated as
+	// arithmetic expressions, which may refer to each other
+	arithmDepth int
+}
+
+func (e *expander) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *expander) get(name string) (string, bool) {
+	params := e.cfg.Params
+	switch name {
+	case "#":
+		return strconv.Itoa(len(params)), true
+	case "@":
+		return strings.Join(params, " "), true
+	case "*":
+		return strings.Join(params, e.ifsSep()), true
+	}
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		if n > len(params) {
+			return "", false
+		}
+		return params[n-1], true
+	}
+	vr := e.variable(name)
+	return vr.String(), vr.Set
+}
+
+// variable returns a variable from the environment, which is unset if
+// there is no environment.
+func (e *expander) variable(name string) Variable {
+	if e.cfg.Env == nil {
+		return Variable{}
+	}
+	return e.cfg.Env.Get(name)
+}
+
+func (e *expander) getVar(name string) string {
+	val, _ := e.get(name)
+	return val
+}
+
+// set sets the value of a variable, keeping its attributes.
+func (e *expander) set(name, value string) {
+	if e.cfg.Env == nil {
+		return
+	}
+	vr := e.cfg.Env.Get(name)
+	vr.Set, vr.Value, vr.List, vr.Map, vr.Indexes = true, value, nil, nil, nil
+	if err := e.cfg.Env.Set(name, vr); err != nil {
+		e.fail(err)
+	}
+}
+
+// fieldPart is a piece of a field, which remembers whether it was
+// quoted.
+type fieldPart struct {
+	val   string
+	quote bool
+}
+
+// fieldBuilder builds the fields that a word expands to.
+type fieldBuilder struct {
+	ifs    string
+	fields [][]fieldPart
+	cur    []fieldPart
+
+	// curSet is true if the current field exists even if empty, as
+	// happens with quotes
+	curSet bool
+	// afterWS is true if the last field was ended by IFS whitespace
+	afterWS bool
+}
+
+func (b *fieldBuilder) add(val string, quote bool) {
+	if quote {
+		b.curSet = true
+	}
+	if val != "" || quote {
+		b.cur = append(b.cur, fieldPart{val: val, quote: quote})
+		b.afterWS = false
+	}
+}
+
+// addLit adds an unquoted literal, where the characters escaped with a
+// backslash are quoted.
+func (b *fieldBuilder) addLit(s string) {
+	for {
+		i := strings.IndexByte(s, '\\')
+		if i < 0 || i+1 == len(s) {
+			b.add(s, false)
+			return
+		}
+		b.add(s[:i], false)
+		if s[i+1] != '\n' { // not a line continuation
+			b.add(s[i+1:i+2], true)
+		}
+		s = s[i+2:]
+	}
+}
+
+// hasContent reports whether the current field must be kept.
+func (b *fieldBuilder) hasContent() bool {
+	if b.curSet {
+		return true
+	}
+	for _, part := range b.cur {
+		if part.val != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *fieldBuilder) flush(force bool) {
+	if force || b.hasContent() {
+		b.fields = append(b.fields, b.cur)
+	}
+	b.cur, b.curSet = nil, false
+}
+
+// split adds the result of an unquoted expansion, splitting it into
+// fields as per IFS.
+func (b *fieldBuilder) split(val string) {
+	if b.ifs == "" {
+		b.add(val, false)
+		return
+	}
+	start := 0
+	for i, c := range val {
+		if !strings.ContainsRune(b.ifs, c) {
+			continue
+		}
+		b.add(val[start:i], false)
+		start = i + utf8.RuneLen(c)
+		if isIFSSpace(c) {
+			if b.hasContent() {
+				b.flush(false)
+				b.afterWS = true
+			}
+			continue
+		}
+		if b.afterWS && !b.hasContent() {
+			// IFS whitespace around a delimiter is part of it
+			b.afterWS = false
+			continue
+		}
+		b.flush(true)
+	}
+	b.add(val[start:], false)
+}
+
+// Fields splits a string into fields as per the shell's field splitting
+// rules, where ifs is the value of $IFS.
+//
+// Runs of the whitespace characters in ifs delimit fields, and are
+// ignored at the start and end of the string. Any other character in
+// ifs delimits a field on its own, along with the whitespace around it,
+// so that "a::b" split with ":" results in "a", "" and "b". An empty
+// ifs results in no splitting.
+func Fields(s, ifs string) []string {
+	b := &fieldBuilder{ifs: ifs}
+	b.split(s)
+	b.flush(false)
+	fields := make([]string, len(b.fields))
+	for i, field := range b.fields {
+		fields[i] = joinField(field)
+	}
+	return fields
+}
+
+func isIFSSpace(c rune) bool { return c == ' ' || c == '\t' || c == '\n' }
+
+func (e *expander) ifs() string {
+	if val, ok := e.get("IFS"); ok {
+		return val
+	}
+	return " \t\n"
+}
+
+// ifsSep returns the separator that joins the elements of $* and
+// ${a[*]}, the first character of $IFS.
+func (e *expander) ifsSep() string {
+	ifs := e.ifs()
+	if ifs == "" {
+		return ""
+	}
+	_, size := utf8.DecodeRuneInString(ifs)
+	return ifs[:size]
+}
+
+func joinField(field []fieldPart) string {
+	if len(field) == 1 {
+		return field[0].val
+	}
+	var buf bytes.Buffer
+	for _, part := range field {
+		buf.WriteString(part.val)
+	}
+	return buf.String()
+}
+
+func (e *expander) wordFields(w *syntax.Word) [][]fieldPart {
+	b := &fieldBuilder{ifs: e.ifs()}
+	for i, wp := range w.Parts {
+		switch x := wp.(type) {
+		case *syntax.Lit:
+			s := x.Value
+			if i == 0 && isTilde(s) {
+				// the home directory isn't globbed
+				b.add(e.getVar("HOME"), true)
+				s = s[1:]
+			}
+			b.addLit(s)
+		case *syntax.SglQuoted:
+			s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+			b.add(s, true)
+		case *syntax.DblQuoted:
+			e.dblQuoted(b, x)
+		case *syntax.ParamExp:
+			if elems, ok := e.multiFields(x, false); ok {
+				for i, elem := range elems {
+					if i > 0 {
+						b.flush(false)
+					}
+					b.split(elem)
+				}
+				break
+			}
+			b.split(e.paramExp(x))
+		default:
+			b.split(e.wordPart(wp))
+		}
+		if e.err != nil {
+			return nil
+		}
+	}
+	b.flush(false)
+	return b.fields
+}
+
+func (e *expander) dblQuoted(b *fieldBuilder, dq *syntax.DblQuoted) {
+	if len(dq.Parts) == 1 {
+		pe, _ := dq.Parts[0].(*syntax.ParamExp)
+		if elems, ok := e.multiFields(pe, true); ok {
+			// "$@" and "${a[@]}" expand to one field per element
+			for i, elem := range elems {
+				if i > 0 {
+					b.flush(true)
+				}
+				b.add(elem, true)
+			}
+			return
+		}
+	}
+	b.add(e.dblQuotedValue(dq), true)
+}
+
+func (e *expander) dblQuotedValue(dq *syntax.DblQuoted) string {
+	var buf bytes.Buffer
+	for _, wp := range dq.Parts {
+		if lit, ok := wp.(*syntax.Lit); ok {
+			buf.WriteString(unescape(lit.Value, dblQuotable))
+			continue
+		}
+		buf.WriteString(e.wordPart(wp))
+	}
+	return buf.String()
+}
+
+// multiFields returns the elements of a parameter expansion that
+// expands to one field per element, like $@ or ${a[@]}, and whether pe
+// is one. pe may be nil. Within double quotes, $* and ${a[*]} expand to
+// a single field instead.
+func (e *expander) multiFields(pe *syntax.ParamExp, quoted bool) ([]string, bool) {
+	if pe == nil || pe.Length || pe.Repl != nil || pe.Exp != nil {
+		return nil, false
+	}
+	all := pe.Param.Value
+	if pe.Ind != nil {
+		all = allIndex(pe.Ind)
+	}
+	if all != "@" && (all != "*" || quoted) {
+		return nil, false
+	}
+	return e.elemList(pe)
+}
+
+// elemList returns the elements that an expansion like $@, ${a[*]} or
+// ${!a[@]} refers to, after slicing them if it's like ${a[@]:1:2}. It
+// reports whether pe is such an expansion.
+func (e *expander) elemList(pe *syntax.ParamExp) ([]string, bool) {
+	name := pe.Param.Value
+	switch {
+	case pe.Ind == nil && (name == "@" || name == "*"):
+		if pe.Slice == nil {
+			return e.cfg.Params, true
+		}
+		// $0 is at offset 0, like in ${@:0:1}
+		params := append([]string{e.getVar("0")}, e.cfg.Params...)
+		return e.sliceElems(params, nil, pe.Slice), true
+	case pe.Ind == nil || allIndex(pe.Ind) == "":
+		return nil, false
+	}
+	var list []string
+	var indexes []int
+	if len(name) > 1 && name[0] == '!' {
+		list = e.variable(name[1:]).Keys()
+	} else {
+		vr := e.variable(name)
+		list, indexes = elems(vr), vr.Indexes
+	}
+	if pe.Slice != nil {
+		list = e.sliceElems(list, indexes, pe.Slice)
+	}
+	return list, true
+}
+
+// sliceElems slices the elements of an indexed array, where the offset
+// is the index to start at. indexes is nil if the elements are at the
+// indexes from 0 to len(list)-1.
+func (e *expander) sliceElems(list []string, indexes []int, sl *syntax.Slice) []string {
+	offset := e.arithm(sl.Offset)
+	if len(list) == 0 {
+		return list
+	}
+	max := len(list) - 1
+	if indexes != nil {
+		max = indexes[len(indexes)-1]
+	}
+	if offset < 0 {
+		if offset += max + 1; offset < 0 {
+			return nil
+		}
+	}
+	start := offset
+	if indexes != nil {
+		start = sort.SearchInts(indexes, offset)
+	} else if start > len(list) {
+		start = len(list)
+	}
+	list = list[start:]
+	if sl.Length != nil {
+		length := e.arithm(sl.Length)
+		if length < 0 {
+			e.fail(fmt.Errorf("substring expression < 0"))
+			return nil
+		}
+		if length < len(list) {
+			list = list[:length]
+		}
+	}
+	return list
+}
+
+// plainParam reports whether a parameter expansion has no operators.
+func plainParam(pe *syntax.ParamExp) bool {
+	return !pe.Length && pe.Ind == nil && pe.Slice == nil &&
+		pe.Repl == nil && pe.Exp == nil
+}
+
+// wordPart returns the value of a word part, without splitting it.
+func (e *expander) wordPart(wp syntax.WordPart) string {
+	switch x := wp.(type) {
+	case *syntax.Lit:
+		return unescape(x.Value, "")
+	case *syntax.SglQuoted, *syntax.DblQuoted:
+		return e.literal(&syntax.Word{Parts: []syntax.WordPart{x}})
+	case *syntax.ParamExp:
+		return e.paramExp(x)
+	case *syntax.CmdSubst:
+		return e.cmdSubst(x)
+	case *syntax.ArithmExp:
+		return strconv.Itoa(e.arithm(x.X))
+	case *syntax.ProcSubst:
+		return e.procSubst(x)
+	case *syntax.ExtGlob:
+		return x.Op.String() + x.Pattern.Value + ")"
+	default:
+		e.fail(fmt.Errorf("unsupported word part: %T", wp))
+	}
+	return ""
+}
+
+func (e *expander) cmdSubst(cs *syntax.CmdSubst) string {
+	if e.cfg.CmdSubst == nil {
+		e.fail(fmt.Errorf("command substitutions are not supported"))
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := e.cfg.CmdSubst(&buf, cs); err != nil {
+		e.fail(err)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (e *expander) procSubst(ps *syntax.ProcSubst) string {
+	if e.cfg.ProcSubst == nil {
+		e.fail(fmt.Errorf("process substitutions are not supported"))
+		return ""
+	}
+	path, err := e.cfg.ProcSubst(ps)
+	if err != nil {
+		e.fail(err)
+	}
+	return path
+}
+
+func (e *expander) literal(w *syntax.Word) string {
+	if w == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	for i, wp := range w.Parts {
+		switch x := wp.(type) {
+		case *syntax.Lit:
+			s := x.Value
+			if i == 0 {
+				s = e.tilde(s)
+			}
+			buf.WriteString(unescape(s, ""))
+		case *syntax.SglQuoted:
+			s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+			buf.WriteString(s)
+		case *syntax.DblQuoted:
+			buf.WriteString(e.dblQuotedValue(x))
+		default:
+			buf.WriteString(e.wordPart(wp))
+		}
+	}
+	return buf.String()
+}
+
+// tilde expands a leading "~" in an unquoted literal to the home
+// directory.
+func (e *expander) tilde(s string) string {
+	if !isTilde(s) {
+		return s
+	}
+	return e.getVar("HOME") + s[1:]
+}
+
+func isTilde(s string) bool { return s == "~" || strings.HasPrefix(s, "~/") }
+
+// unescape removes the backslashes that quote characters in a literal.
+// Only the quotable characters can be quoted, or any character if
+// quotable is empty.
+func unescape(s, quotable string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '\n':
+			// line continuation
+		case quotable == "" || strings.IndexByte(quotable, next) >= 0:
+			buf.WriteByte(next)
+		default:
+			buf.WriteByte(c)
+			buf.WriteByte(next)
+		}
+		i++
+	}
+	return buf.String()
+}
+
+// The characters that a backslash can quote within double quotes and
+// within heredocs, where double quotes aren't special.
+const (
+	dblQuotable = "$`\"\\"
+	docQuotable = "$`\\"
+)
+
+// patternMeta are the characters that must be escaped in a pattern to
+// be matched literally.
+const patternMeta = `\*?[]()|+@!`
+
+func escapePattern(s string) string {
+	if !strings.ContainsAny(s, patternMeta) {
+		return s
+	}
+	var buf bytes.Buffer
+	for _, c := range s {
+		if strings.ContainsRune(patternMeta, c) {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+func (e *expander) pattern(w *syntax.Word) string {
+	var buf bytes.Buffer
+	for _, wp := range w.Parts {
+		switch x := wp.(type) {
+		case *syntax.Lit:
+			buf.WriteString(x.Value)
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			buf.WriteString(escapePattern(e.wordPart(x)))
+		default:
+			buf.WriteString(e.wordPart(wp))
+		}
+	}
+	return buf.String()
+}
+
+// substUnset reports whether an expansion like ${x-def} handles its
+// parameter being unset.
+func substUnset(exp *syntax.Expansion) bool {
+	if exp == nil {
+		return false
+	}
+	switch exp.Op {
+	case syntax.SubstPlus, syntax.SubstColPlus, syntax.SubstMinus,
+		syntax.SubstColMinus, syntax.SubstQuest, syntax.SubstColQuest,
+		syntax.SubstAssgn, syntax.SubstColAssgn:
+		return true
+	}
+	return false
+}
+
+func (e *expander) paramExp(pe *syntax.ParamExp) string {
+	if pe.Param == nil {
+		// the parser reads ${#:-a} as a length without a name, but
+		// it's $# followed by an operator
+		pe2 := *pe
+		pe2.Length, pe2.Param = false, &syntax.Lit{Value: "#"}
+		pe = &pe2
+	}
+	name := pe.Param.Value
+	val, set := e.get(name)
+	count := -1 // the number of elements, if all of them are expanded
+	if list, ok := e.elemList(pe); ok {
+		sep := " "
+		if (pe.Ind == nil && name == "*") || (pe.Ind != nil && allIndex(pe.Ind) == "*") {
+			sep = e.ifsSep()
+		}
+		val, set, count = strings.Join(list, sep), true, len(list)
+	} else if pe.Ind != nil {
+		val, set = e.index(e.variable(name), pe.Ind)
+	} else if len(name) > 1 && name[0] == '!' {
+		// indirect expansion, like ${!ref}
+		val, set = e.get(e.getVar(name[1:]))
+	}
+	if !set && e.cfg.NoUnset && !substUnset(pe.Exp) {
+		e.fail(&UnsetParameterError{Exp: pe, Message: "unbound variable"})
+		return ""
+	}
+	if pe.Length {
+		if count >= 0 {
+			return strconv.Itoa(count)
+		}
+		return strconv.Itoa(utf8.RuneCountInString(val))
+	}
+	if pe.Slice != nil && count < 0 {
+		val = e.slice(val, pe.Slice)
+	}
+	if pe.Repl != nil {
+		val = e.replace(val, pe.Repl)
+	}
+	if pe.Exp == nil {
+		return val
+	}
+	arg := func() string { return e.literal(pe.Exp.Word) }
+	null := !set || val == ""
+	switch op := pe.Exp.Op; op {
+	case syntax.SubstPlus:
+		if set {
+			return arg()
+		}
+		return ""
+	case syntax.SubstColPlus:
+		if !null {
+			return arg()
+		}
+		return ""
+	case syntax.SubstMinus:
+		if !set {
+			return arg()
+		}
+	case syntax.SubstColMinus:
+		if null {
+			return arg()
+		}
+	case syntax.SubstQuest, syntax.SubstColQuest:
+		if !set || (op == syntax.SubstColQuest && val == "") {
+			msg := arg()
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			e.fail(&UnsetParameterError{Exp: pe, Message: msg})
+		}
+	case syntax.SubstAssgn, syntax.SubstColAssgn:
+		if !set || (op == syntax.SubstColAssgn && val == "") {
+			val = arg()
+			e.set(name, val)
+		}
+	case syntax.RemSmallPrefix, syntax.RemLargePrefix,
+		syntax.RemSmallSuffix, syntax.RemLargeSuffix:
+		pat := ""
+		if pe.Exp.Word != nil {
+			pat = e.pattern(pe.Exp.Word)
+		}
+		return removePattern(val, pat, op)
+	case syntax.UpperFirst, syntax.UpperAll, syntax.LowerFirst, syntax.LowerAll:
+		caseFn := unicode.ToUpper
+		if op == syntax.LowerFirst || op == syntax.LowerAll {
+			caseFn = unicode.ToLower
+		}
+		if op == syntax.UpperAll || op == syntax.LowerAll {
+			return strings.Map(caseFn, val)
+		}
+		if val == "" {
+			return ""
+		}
+		first, size := utf8.DecodeRuneInString(val)
+		return string(caseFn(first)) + val[size:]
+	}
+	return val
+}
+
+// allIndex returns "@" or "*" if an index is either of them, which
+// refer to all the elements of an array, and an empty string otherwise.
+func allIndex(ind *syntax.Index) string {
+	w, ok := ind.Expr.(*syntax.Word)
+	if !ok {
+		return ""
+	}
+	if s, _ := syntax.StaticValue(w); s == "@" || s == "*" {
+		return s
+	}
+	return ""
+}
+
+// index returns the value of an element of a variable, and whether it is
+// set. The index is an arithmetic expression for indexed arrays and a
+// key for associative arrays. A string variable is an indexed array with
+// a single element.
+func (e *expander) index(vr Variable, ind *syntax.Index) (string, bool) {
+	if vr.Map != nil {
+		var key string
+		if w, ok := ind.Expr.(*syntax.Word); ok {
+			key = e.literal(w)
+		} else {
+			key = strconv.Itoa(e.arithm(ind.Expr))
+		}
+		val, ok := vr.Map[key]
+		return val, ok
+	}
+	i := e.arithm(ind.Expr)
+	if i < 0 {
+		i += vr.MaxIndex() + 1
+	}
+	return vr.Elem(i)
+}
+
+// elems returns the elements of a variable, ordered by key for
+// associative arrays.
+func elems(vr Variable) []string {
+	switch {
+	case vr.List != nil:
+		return vr.List
+	case vr.Map != nil:
+		keys := make([]string, 0, len(vr.Map))
+		for key := range vr.Map {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		list := make([]string, len(keys))
+		for i, key := range keys {
+			list[i] = vr.Map[key]
+		}
+		return list
+	case vr.Set:
+		return []string{vr.Value}
+	}
+	return nil
+}
+
+func (e *expander) slice(val string, sl *syntax.Slice) string {
+	runes := []rune(val)
+	offset := e.arithm(sl.Offset)
+	if offset < 0 {
+		offset += len(runes)
+	}
+	if offset < 0 || offset > len(runes) {
+		return ""
+	}
+	runes = runes[offset:]
+	if sl.Length != nil {
+		length := e.arithm(sl.Length)
+		if length < 0 {
+			length += len(runes)
+		}
+		if length < 0 {
+			e.fail(fmt.Errorf("substring expression < 0"))
+			return ""
+		}
+		if length < len(runes) {
+			runes = runes[:length]
+		}
+	}
+	return string(runes)
+}
+
+// removePattern removes the shortest or longest prefix or suffix of a
+// string that matches a pattern.
+func removePattern(val, pat string, op syntax.ParExpOperator) string {
+	matches := func(s string) bool { return pattern.Match(pat, s, pattern.ExtGlob) }
+	switch op {
+	case syntax.RemSmallPrefix:
+		for i := 0; i <= len(val); i++ {
+			if runeStart(val, i) && matches(val[:i]) {
+				return val[i:]
+			}
+		}
+	case syntax.RemLargePrefix:
+		for i := len(val); i >= 0; i-- {
+			if runeStart(val, i) && matches(val[:i]) {
+				return val[i:]
+			}
+		}
+	case syntax.RemSmallSuffix:
+		for i := len(val); i >= 0; i-- {
+			if runeStart(val, i) && matches(val[i:]) {
+				return val[:i]
+			}
+		}
+	case syntax.RemLargeSuffix:
+		for i := 0; i <= len(val); i++ {
+			if runeStart(val, i) && matches(val[i:]) {
+				return val[:i]
+			}
+		}
+	}
+	return val
+}
+
+// runeStart reports whether i is a valid index to split s at.
+func runeStart(s string, i int) bool {
+	return i == len(s) || utf8.RuneStart(s[i])
+}
+
+func (e *expander) replace(val string, repl *syntax.Replace) string {
+	pat := e.pattern(repl.Orig)
+	with := e.literal(repl.With)
+	anchorStart, anchorEnd := false, false
+	switch {
+	case strings.HasPrefix(pat, "#"):
+		anchorStart, pat = true, pat[1:]
+	case strings.HasPrefix(pat, "%"):
+		anchorEnd, pat = true, pat[1:]
+	}
+	if pat == "" {
+		return val
+	}
+	var buf bytes.Buffer
+	last := 0
+	for i := 0; i < len(val); i++ {
+		if anchorStart && i > 0 {
+			break
+		}
+		if !runeStart(val, i) {
+			continue
+		}
+		// find the longest non-empty match starting at i
+		j := len(val)
+		for ; j > i; j-- {
+			if runeStart(val, j) && pattern.Match(pat, val[i:j], pattern.ExtGlob) {
+				break
+			}
+			if anchorEnd {
+				j = i
+				break
+			}
+		}
+		if j == i {
+			continue
+		}
+		buf.WriteString(val[last:i])
+		buf.WriteString(with)
+		last = j
+		if !repl.All {
+			break
+		}
+		i = j - 1
+	}
+	buf.WriteString(val[last:])
+	return buf.String()
+}
@@ -0,0 +1,318 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// mapEnv is an Environ of string variables, to keep the tests short.
+type mapEnv map[string]string
+
+func (m mapEnv) Get(name string) Variable {
+	val, ok := m[name]
+	return Variable{Set: ok, Value: val}
+}
+
+func (m mapEnv) Set(name string, vr Variable) error {
+	m[name] = vr.Value
+	return nil
+}
+
+func (m mapEnv) Each(fn func(name string, vr Variable) bool) {
+	for name, val := range m {
+		if !fn(name, Variable{Set: true, Value: val}) {
+			return
+		}
+	}
+}
+
+func parseWords(t *testing.T, src string) []*syntax.Word {
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Stmts) == 0 {
+		return nil
+	}
+	return f.Stmts[0].Cmd.(*syntax.CallExpr).Args
+}
+
+func testConfig() Config {
+	return Config{
+		Env: mapEnv{
+			"foo":  "bar",
+			"sp":   " a  b ",
+			"path": "/usr/local/bin",
+			"n":    "3",
+			"HOME": "/home/user",
+		},
+		Params: []string{"x y", "z"},
+		CmdSubst: func(w io.Writer, cs *syntax.CmdSubst) error {
+			fmt.Fprintf(w, "out%d\n\n", len(cs.Stmts))
+			return nil
+		},
+	}
+}
+
+var fieldsTests = []struct {
+	in   string
+	want []string
+}{
+	{"a b c", []string{"a", "b", "c"}},
+	{"$foo ${foo}x", []string{"bar", "barx"}},
+	{"$sp", []string{"a", "b"}},
+	{`"$sp"`, []string{" a  b "}},
+	{`x$sp"y"`, []string{"x", "a", "b", "y"}},
+	{`$unset "" ''`, []string{"", ""}},
+	{`'$foo' "\$foo" \$foo`, []string{"$foo", "$foo", "$foo"}},
+	{`"a\b" a\ b`, []string{`a\b`, "a b"}},
+	{"$# $1 $2 $3", []string{"2", "x", "y", "z"}},
+	{`"$@"`, []string{"x y", "z"}},
+	{"$@", []string{"x", "y", "z"}},
+	{`"$*"`, []string{"x y z"}},
+	{"$(foo) `foo; bar`", []string{"out1", "out2"}},
+	{"$((n * 2)) $((1 << 4))", []string{"6", "16"}},
+	{"${foo:-x} ${unset:-x y} ${unset-$foo}", []string{"bar", "x", "y", "bar"}},
+	{"${foo:+set} ${unset:+set}", []string{"set"}},
+	{"${#foo} ${#@}", []string{"3", "2"}},
+	{"${path##*/} ${path%/*} ${path#/} ${path%%/*}x", []string{"bin", "/usr/local", "usr/local/bin", "x"}},
+	{"${path/\\//:} ${path//\\//:}", []string{":usr/local/bin", ":usr:local:bin"}},
+	{"${path/#\\/usr/x} ${path/%bin/x} ${path/#bin/x} ${foo//?/-}", []string{"x/local/bin", "/usr/local/x", "/usr/local/bin", "---"}},
+	{"${foo%@(r|ar)} ${foo#@(b|ba)} ${foo%%!(r)}x", []string{"ba", "ar", "x"}},
+	{"${foo:1} ${foo:0:2} ${foo: -1}", []string{"ar", "ba", "r"}},
+	{"${foo^^} ${foo^}", []string{"BAR", "Bar"}},
+	{"~ ~/x '~' x~", []string{"/home/user", "/home/user/x", "~", "x~"}},
+}
+
+func TestFields(t *testing.T) {
+	t.Parallel()
+	for i, tc := range fieldsTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			words := parseWords(t, "_ "+tc.in)[1:]
+			got, err := testConfig().Fields(words...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Fields mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFieldsIFS(t *testing.T) {
+	t.Parallel()
+	cfg := Config{Env: mapEnv{"IFS": ":", "v": "a::b:"}, Params: []string{"x", "y"}}
+	got, err := cfg.Fields(parseWords(t, `$v "$*" "$@"`)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "", "b", "x:y", "x", "y"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Fields mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestFieldsArrays(t *testing.T) {
+	t.Parallel()
+	cfg := Config{Env: MapEnviron{
+		"a": {Set: true, List: []string{"x y", "z"}},
+		"m": {Set: true, Map: map[string]string{"k": "v", "j": "w"}},
+		"s": {Set: true, Value: "str"},
+	}}
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`"${a[@]}" ${a[@]}`, []string{"x y", "z", "x", "y", "z"}},
+		{`"${a[*]}" ${#a[@]} ${a[1]} ${a[-2]}`, []string{"x y z", "2", "z", "x", "y"}},
+		{`"${a[2]}" ${a[2]-unset}`, []string{"", "unset"}},
+		{`${m[k]} ${m[j]} "${m[@]}" ${#m[*]}`, []string{"v", "w", "w", "v", "2"}},
+		{`${s[0]} ${s[@]} ${#s[@]} ${s[1]-unset}`, []string{"str", "str", "1", "unset"}},
+		{`"${unset[@]}" ${#unset[@]}`, []string{"0"}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := cfg.Fields(parseWords(t, "_ "+tc.in)[1:]...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Fields mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	t.Parallel()
+	cfg := testConfig()
+	w := parseWords(t, `"$sp"x$sp${unset:=new}`)[0]
+	got, err := cfg.Literal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := " a  b x a  b new"; got != want {
+		t.Fatalf("Literal mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	if val := cfg.Env.Get("unset").Value; val != "new" {
+		t.Fatalf("${unset:=new} did not assign, got %q", val)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	t.Parallel()
+	f, err := syntax.Parse([]byte("cat <<EOF\n\"$foo\" \\$foo \\\" $((n+1))\nEOF"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := testConfig().Document(f.Stmts[0].Redirs[0].Hdoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\"bar\" $foo \\\" 4\n"; got != want {
+		t.Fatalf("Document mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	t.Parallel()
+	w := parseWords(t, `*.$foo"*"'?'`)[0]
+	got, err := testConfig().Pattern(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `*.bar\*\?`; got != want {
+		t.Fatalf("Pattern mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+var arithmTests = []struct {
+	in   string
+	want int
+}{
+	{"1 + 2 * 3", 7},
+	{"(1 + 2) * 3", 9},
+	{"7 / 2 + 7 % 2", 4},
+	{"2 ** 10", 1024},
+	{"0x10 + 010 + 2#101", 29},
+	{"n + 1", 4},
+	{"$n * n", 9},
+	{"unset + 1", 1},
+	{"1 < 2 && 2 < 1", 0},
+	{"!0 || 0", 1},
+	{"1 ? 2 : 3", 2},
+	{"-n", -3},
+	{"1, 2", 2},
+}
+
+func TestArithm(t *testing.T) {
+	t.Parallel()
+	for i, tc := range arithmTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			words := parseWords(t, "_ $(("+tc.in+"))")
+			expr := words[1].Parts[0].(*syntax.ArithmExp).X
+			got, err := testConfig().Arithm(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Arithm mismatch in %q\nwant: %d\ngot:  %d",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestArithmAssign(t *testing.T) {
+	t.Parallel()
+	cfg := testConfig()
+	words := parseWords(t, "_ $((n++, n *= 2, ++n))")
+	got, err := cfg.Arithm(words[1].Parts[0].(*syntax.ArithmExp).X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val := cfg.Env.Get("n").Value; got != 9 || val != "9" {
+		t.Fatalf("wanted 9 and n=9, got %d and n=%s", got, val)
+	}
+}
+
+var errorTests = []struct {
+	in, want string
+}{
+	{"${unset?}", "unset: parameter null or not set"},
+	{"${foo:?} ${unset:?must be $foo}", "unset: must be bar"},
+	{"$((1 / 0))", "division by zero"},
+	{"$((1 % 0))", "division by zero"},
+	{"${foo:0:-5}", "substring expression < 0"},
+	{"<(foo)", "process substitutions are not supported"},
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+	for i, tc := range errorTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			words := parseWords(t, "_ "+tc.in)[1:]
+			_, err := testConfig().Fields(words...)
+			if err == nil {
+				t.Fatalf("Expected error in %q: %v", tc.in, tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("Error mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNoCmdSubst(t *testing.T) {
+	t.Parallel()
+	_, err := Config{}.Fields(parseWords(t, "$(rm -rf /)")...)
+	if err == nil {
+		t.Fatal("expected an error without CmdSubst")
+	}
+}
+
+var splitTests = []struct {
+	in, ifs string
+	want    []string
+}{
+	{"", " \t\n", []string{}},
+	{"  ", " \t\n", []string{}},
+	{"a", " \t\n", []string{"a"}},
+	{" a \t b\n", " \t\n", []string{"a", "b"}},
+	{"a b", "", []string{"a b"}},
+	{"", "", []string{}},
+	{"a:b", ":", []string{"a", "b"}},
+	{"a::b", ":", []string{"a", "", "b"}},
+	{":a:", ":", []string{"", "a"}},
+	{"a::", ":", []string{"a", ""}},
+	{" a b ", ":", []string{" a b "}},
+	{"a : b", " :", []string{"a", "b"}},
+	{"a  :  : b", " :", []string{"a", "", "b"}},
+	{" : a", " :", []string{"", "a"}},
+	{"a\tb c", "\t", []string{"a", "b c"}},
+	{"aXbYc", "XY", []string{"a", "b", "c"}},
+	{"a·b", "·", []string{"a", "b"}},
+}
+
+func TestSplitFields(t *testing.T) {
+	t.Parallel()
+	for i, tc := range splitTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := Fields(tc.in, tc.ifs)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Fields mismatch in %q with IFS %q\nwant: %q\ngot:  %q",
+					tc.in, tc.ifs, tc.want, got)
+			}
+		})
+	}
+}
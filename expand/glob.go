@@ -0,0 +1,228 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mvdan/sh/pattern"
+)
+
+// FS is the file system that filename patterns are expanded against.
+// Relative paths are relative to its current directory.
+type FS interface {
+	ReadDir(dir string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+// DirFS returns an FS backed by the operating system, where relative
+// paths are relative to dir.
+func DirFS(dir string) FS { return dirFS(dir) }
+
+type dirFS string
+
+func (d dirFS) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	if name != "" && os.IsPathSeparator(name[0]) {
+		// on Windows, a path like "/foo" is on the drive of dir
+		return filepath.Join(filepath.VolumeName(string(d)), name)
+	}
+	return filepath.Join(string(d), name)
+}
+
+func (d dirFS) ReadDir(dir string) ([]os.FileInfo, error) { return ioutil.ReadDir(d.path(dir)) }
+func (d dirFS) Stat(name string) (os.FileInfo, error)     { return os.Stat(d.path(name)) }
+
+// globMode is the mode that filename patterns are matched with.
+const globMode = pattern.Filenames | pattern.ExtGlob
+
+// globField expands a field as a filename pattern.
+func (e *expander) globField(field []fieldPart) []string {
+	val := joinField(field)
+	if e.cfg.NoGlob {
+		return []string{val}
+	}
+	var buf []string
+	for _, part := range field {
+		if part.quote {
+			buf = append(buf, escapePattern(part.val))
+		} else {
+			buf = append(buf, part.val)
+		}
+	}
+	pat := strings.Join(buf, "")
+	if !pattern.HasMeta(pat, pattern.ExtGlob) {
+		return []string{val}
+	}
+	matches := e.glob(pat)
+	if len(matches) == 0 && !e.cfg.NullGlob {
+		return []string{val}
+	}
+	return matches
+}
+
+// Glob returns the names of the files that match a pattern, such as one
+// returned by Pattern, sorted in lexical order. A nil slice is returned
+// if no files match.
+func (c Config) Glob(pat string) []string {
+	e := &expander{cfg: c}
+	return e.glob(pat)
+}
+
+func (e *expander) fs() FS {
+	if e.cfg.FS == nil {
+		return dirFS("")
+	}
+	return e.cfg.FS
+}
+
+func (e *expander) glob(pat string) []string {
+	matches := []string{""}
+	if strings.HasPrefix(pat, "/") {
+		matches[0] = "/"
+		pat = strings.TrimLeft(pat, "/")
+	}
+	parts := strings.Split(pat, "/")
+	globbed := false
+	for i, part := range parts {
+		last := i == len(parts)-1
+		var next []string
+		switch {
+		case part == "" && last:
+			// a trailing slash only matches directories
+			for _, m := range matches {
+				if e.isDir(m) {
+					next = append(next, m+"/")
+				}
+			}
+		case part == "**" && e.cfg.GlobStar:
+			for _, m := range matches {
+				if !last {
+					next = append(next, m)
+				}
+				next = append(next, e.globStar(m, last)...)
+			}
+			globbed = true
+		case !pattern.HasMeta(part, pattern.ExtGlob):
+			lit := unescapePattern(part)
+			for _, m := range matches {
+				name := joinPath(m, lit)
+				if !globbed || e.exists(name) {
+					next = append(next, name)
+				}
+			}
+		default:
+			for _, m := range matches {
+				next = append(next, e.globDir(m, part, last)...)
+			}
+			globbed = true
+		}
+		matches = next
+		if len(matches) == 0 {
+			return nil
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+func fsDir(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+func (e *expander) exists(name string) bool {
+	_, err := e.fs().Stat(name)
+	return err == nil
+}
+
+func (e *expander) isDir(name string) bool {
+	info, err := e.fs().Stat(fsDir(name))
+	return err == nil && info.IsDir()
+}
+
+// hidden reports whether a file name must be matched explicitly.
+func (e *expander) hidden(name, pat string) bool {
+	return name[0] == '.' && !e.cfg.DotGlob &&
+		!strings.HasPrefix(pat, ".") && !strings.HasPrefix(pat, `\.`)
+}
+
+// globDir returns the files in a directory that match a pattern. Unless
+// the pattern is the last element of a path, only directories are
+// returned.
+func (e *expander) globDir(dir, pat string, last bool) []string {
+	infos, err := e.fs().ReadDir(fsDir(dir))
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, info := range infos {
+		name := info.Name()
+		if e.hidden(name, pat) || !pattern.Match(pat, name, globMode) {
+			continue
+		}
+		path := joinPath(dir, name)
+		if !last && !e.isDir(path) {
+			continue
+		}
+		matches = append(matches, path)
+	}
+	return matches
+}
+
+// globStar returns all the directories within a directory, at any
+// depth. If withFiles is true, other files are also returned.
+func (e *expander) globStar(dir string, withFiles bool) []string {
+	infos, err := e.fs().ReadDir(fsDir(dir))
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, info := range infos {
+		name := info.Name()
+		if e.hidden(name, "") {
+			continue
+		}
+		path := joinPath(dir, name)
+		// symlinks to directories aren't followed
+		if info.IsDir() {
+			matches = append(matches, path)
+			matches = append(matches, e.globStar(path, withFiles)...)
+		} else if withFiles {
+			matches = append(matches, path)
+		}
+	}
+	return matches
+}
+
+// unescapePattern removes the backslashes from a pattern without any
+// special characters.
+func unescapePattern(pat string) string {
+	if strings.IndexByte(pat, '\\') < 0 {
+		return pat
+	}
+	var buf []byte
+	for i := 0; i < len(pat); i++ {
+		if pat[i] == '\\' && i+1 < len(pat) {
+			i++
+		}
+		buf = append(buf, pat[i])
+	}
+	return string(buf)
+}
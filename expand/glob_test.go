@@ -0,0 +1,89 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package expand
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var globTests = []struct {
+	in   string
+	cfg  Config
+	want string
+}{
+	{in: "*", want: "a.go b.sh dir"},
+	{in: "*.go x", want: "a.go x"},
+	{in: "?.*", want: "a.go b.sh"},
+	{in: "[ab].*", want: "a.go b.sh"},
+	{in: "'*'", want: "*"},
+	{in: `\*`, want: "*"},
+	{in: `"*".go`, want: "*.go"},
+	{in: "*.c", want: "*.c"},
+	{in: "*.c", cfg: Config{NullGlob: true}, want: ""},
+	{in: "*", cfg: Config{NoGlob: true}, want: "*"},
+	{in: "*", cfg: Config{DotGlob: true}, want: ".hidden a.go b.sh dir"},
+	{in: ".*", want: ".hidden"},
+	{in: "*/", want: "dir/"},
+	{in: "*/*", want: "dir/c.go dir/sub"},
+	{in: "dir/*/d.go", want: "dir/sub/d.go"},
+	{in: "*/nosuch", want: "*/nosuch"},
+	{in: "*/c.go", want: "dir/c.go"},
+	{in: "**/*.go", want: "dir/c.go"},
+	{in: "**/*.go", cfg: Config{GlobStar: true}, want: "a.go dir/c.go dir/sub/d.go"},
+	{in: "**", cfg: Config{GlobStar: true}, want: "a.go b.sh dir dir/c.go dir/sub dir/sub/d.go"},
+	{in: "dir/**/", cfg: Config{GlobStar: true}, want: "dir/ dir/sub/"},
+	{in: "@(a|b).*", want: "a.go b.sh"},
+	{in: "!(*.go)", want: "b.sh dir"},
+	{in: "$pat", want: "a.go"},
+	{in: `"$pat"`, want: "*.go"},
+}
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "expand-glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		".hidden", "a.go", "b.sh", "dir/c.go", "dir/sub/d.go",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, tc := range globTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			cfg := tc.cfg
+			cfg.FS = DirFS(dir)
+			cfg.Env = mapEnv{"pat": "*.go"}
+			got, err := cfg.Fields(parseWords(t, "_ "+tc.in)[1:]...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Fields(tc.want)
+			if len(got) == 0 {
+				got = want[:0]
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Fields mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, want, got)
+			}
+		})
+	}
+	got := Config{FS: DirFS(dir)}.Glob(filepath.Join(dir, "*.sh"))
+	if want := []string{filepath.Join(dir, "b.sh")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Glob mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
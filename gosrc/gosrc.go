@@ -0,0 +1,393 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package gosrc handles the shell programs in the string literals of Go
+// files, like the scripts given to sh -c via os/exec, so that they can
+// be parsed and linted with positions in the Go files.
+package gosrc
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Directive is the comment that marks a string literal as a shell
+// program, like:
+//
+//	//sh:script bash
+//	const setup = `
+//		set -eu
+//		make all
+//	`
+//
+// It applies to the first string literal on the line after it, or on
+// the same line if it's at the end of one. The name of the shell is
+// optional, and is "sh" by default.
+const Directive = "//sh:script"
+
+// Script is a shell program in a string literal of a Go file.
+type Script struct {
+	// Lit is the string literal.
+	Lit *ast.BasicLit
+
+	// Shell is the name of the shell that runs the program, like "sh"
+	// or "bash".
+	Shell string
+
+	// File is the parsed program. Use Position to map its positions
+	// to the Go file.
+	File *syntax.File
+
+	fset *token.FileSet
+
+	// offsets holds the offset in Lit of each byte of the program,
+	// plus one for its end.
+	offsets []int
+}
+
+// Position returns the position in the Go file of a position in the
+// program.
+func (s *Script) Position(pos syntax.Pos) syntax.Position {
+	return s.outer(int(pos) - 1)
+}
+
+// outer maps an offset in the program to a position in the Go file.
+func (s *Script) outer(offset int) syntax.Position {
+	switch {
+	case offset < 0:
+		offset = 0
+	case offset >= len(s.offsets):
+		offset = len(s.offsets) - 1
+	}
+	p := s.fset.Position(s.Lit.Pos() + token.Pos(s.offsets[offset]))
+	return syntax.Position{Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+// Scripts returns the shell programs in a Go file: the string literals
+// given as the program of a shell's -c flag to exec.Command or
+// exec.CommandContext, like in exec.Command("sh", "-c", "make all"),
+// and the ones marked with Directive. The file must have been parsed
+// with comments.
+//
+// If a program can't be parsed, the error is a *syntax.ParseError with
+// its position in the Go file.
+func Scripts(fset *token.FileSet, f *ast.File, mode syntax.ParseMode) ([]*Script, error) {
+	var scripts []*Script
+	seen := make(map[*ast.BasicLit]bool)
+	for _, c := range calls(f) {
+		if lit := constLit(c.script); lit != nil && !seen[lit] {
+			seen[lit] = true
+			scripts = append(scripts, &Script{Lit: lit, Shell: c.shell})
+		}
+	}
+	for _, s := range marked(fset, f) {
+		if !seen[s.Lit] {
+			scripts = append(scripts, s)
+		}
+	}
+	for _, s := range scripts {
+		if err := s.parse(fset, mode); err != nil {
+			return nil, err
+		}
+	}
+	return scripts, nil
+}
+
+func (s *Script) parse(fset *token.FileSet, mode syntax.ParseMode) error {
+	s.fset = fset
+	var src []byte
+	src, s.offsets = unquote(s.Lit.Value)
+	if s.Shell == "sh" || s.Shell == "dash" {
+		mode |= syntax.PosixConformant
+	}
+	name := fset.Position(s.Lit.Pos()).Filename
+	f, err := syntax.Parse(src, name, mode)
+	if err != nil {
+		if perr, ok := err.(*syntax.ParseError); ok {
+			perr.Position = s.outer(perr.Position.Offset)
+		}
+		return err
+	}
+	s.File = f
+	return nil
+}
+
+// unquote returns the value of a string literal, along with the offset
+// in the literal of each of its bytes, plus one for its end.
+func unquote(lit string) ([]byte, []int) {
+	var val []byte
+	var offsets []int
+	body := lit[1 : len(lit)-1]
+	if lit[0] == '`' {
+		for i := 0; i < len(body); i++ {
+			// raw strings don't keep carriage returns
+			if body[i] != '\r' {
+				val = append(val, body[i])
+				offsets = append(offsets, 1+i)
+			}
+		}
+		return val, append(offsets, len(lit)-1)
+	}
+	var buf [utf8.UTFMax]byte
+	for s := body; s != ""; {
+		r, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			// the Go parser already checked the literal
+			break
+		}
+		var b []byte
+		if r < utf8.RuneSelf || !multibyte {
+			b = []byte{byte(r)}
+		} else {
+			b = buf[:utf8.EncodeRune(buf[:], r)]
+		}
+		for range b {
+			offsets = append(offsets, 1+len(body)-len(s))
+		}
+		val = append(val, b...)
+		s = tail
+	}
+	return val, append(offsets, len(lit)-1)
+}
+
+// shellCall is a call to exec.Command or exec.CommandContext that runs
+// a shell program given via -c.
+type shellCall struct {
+	shell  string
+	script ast.Expr
+}
+
+// calls returns the calls that run shell programs in a file.
+func calls(f *ast.File) []shellCall {
+	pkg := ""
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"os/exec"` {
+			pkg = "exec"
+			if imp.Name != nil {
+				pkg = imp.Name.Name
+			}
+		}
+	}
+	if pkg == "" || pkg == "_" || pkg == "." {
+		return nil
+	}
+	var found []shellCall
+	ast.Inspect(f, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != pkg {
+			return true
+		}
+		args := call.Args
+		switch sel.Sel.Name {
+		case "Command":
+		case "CommandContext":
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		default:
+			return true
+		}
+		if len(args) < 3 || stringLit(args[1]) != "-c" {
+			return true
+		}
+		shell := path.Base(stringLit(args[0]))
+		if shell != "sh" && lint.LookupDialect(shell) == nil {
+			return true
+		}
+		found = append(found, shellCall{shell, args[2]})
+		return true
+	})
+	return found
+}
+
+// stringLit returns the value of an expression if it's a string
+// literal, or an empty string otherwise.
+func stringLit(x ast.Expr) string {
+	lit, ok := x.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	s, _ := strconv.Unquote(lit.Value)
+	return s
+}
+
+// constLit returns the string literal that an expression is, or that
+// the constant it's the name of is declared with.
+func constLit(x ast.Expr) *ast.BasicLit {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if x.Kind == token.STRING {
+			return x
+		}
+	case *ast.ParenExpr:
+		return constLit(x.X)
+	case *ast.Ident:
+		if x.Obj == nil || x.Obj.Kind != ast.Con {
+			break
+		}
+		spec, ok := x.Obj.Decl.(*ast.ValueSpec)
+		if !ok {
+			break
+		}
+		for i, name := range spec.Names {
+			if name.Name == x.Name && i < len(spec.Values) {
+				return constLit(spec.Values[i])
+			}
+		}
+	}
+	return nil
+}
+
+// isConst reports whether an expression is made of literals and
+// constants only, so that it can't contain input.
+func isConst(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isConst(x.X)
+	case *ast.BinaryExpr:
+		return isConst(x.X) && isConst(x.Y)
+	case *ast.Ident:
+		return x.Obj != nil && x.Obj.Kind == ast.Con
+	}
+	return false
+}
+
+// marked returns the string literals marked with Directive.
+func marked(fset *token.FileSet, f *ast.File) []*Script {
+	type directive struct {
+		line  int
+		shell string
+	}
+	var dirs []directive
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, Directive) {
+				continue
+			}
+			rest := c.Text[len(Directive):]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				continue
+			}
+			shell := "sh"
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				shell = fields[0]
+			}
+			dirs = append(dirs, directive{fset.Position(c.End()).Line, shell})
+		}
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+	var scripts []*Script
+	ast.Inspect(f, func(node ast.Node) bool {
+		lit, ok := node.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING || len(dirs) == 0 {
+			return true
+		}
+		line := fset.Position(lit.Pos()).Line
+		for len(dirs) > 0 && dirs[0].line < line-1 {
+			// nothing to apply to
+			dirs = dirs[1:]
+		}
+		if len(dirs) > 0 && dirs[0].line >= line-1 && dirs[0].line <= line {
+			scripts = append(scripts, &Script{Lit: lit, Shell: dirs[0].shell})
+			dirs = dirs[1:]
+		}
+		return true
+	})
+	return scripts
+}
+
+// Lint lints the shell programs in a Go file, and returns the
+// diagnostics with their positions and filename in the Go file. If l is
+// nil, the default linter is used. Unless l has a dialect, the one of
+// each program's shell is used, or POSIX. The file must have been
+// parsed with comments.
+//
+// Like the shellcmd analyzer, Lint also reports the programs given to a
+// shell via exec.Command that are built at run time, like with
+// fmt.Sprintf, since the values in them are run as code.
+func Lint(fset *token.FileSet, f *ast.File, l *lint.Linter) ([]lint.Diagnostic, error) {
+	var lc lint.Linter
+	if l != nil {
+		lc = *l
+	}
+	scripts, err := Scripts(fset, f, syntax.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var diags []lint.Diagnostic
+	for _, s := range scripts {
+		sl := lc
+		if sl.Dialect == nil {
+			if sl.Dialect = lint.LookupDialect(s.Shell); sl.Dialect == nil {
+				sl.Dialect = lint.POSIX
+			}
+		}
+		for _, d := range sl.Lint(s.File) {
+			d.Filename = s.File.Name
+			d.Pos, d.End = s.outer(d.Pos.Offset), s.outer(d.End.Offset)
+			diags = append(diags, d)
+		}
+	}
+	for _, c := range calls(f) {
+		if isConst(c.script) || !shellCmdEnabled(&lc) {
+			continue
+		}
+		pos, end := fset.Position(c.script.Pos()), fset.Position(c.script.End())
+		diags = append(diags, lint.Diagnostic{
+			Analyzer: lint.ShellCmd.Name,
+			Code:     lint.ShellCmd.Code,
+			Severity: lint.ShellCmd.Severity,
+			Filename: pos.Filename,
+			Pos:      syntax.Position{Offset: pos.Offset, Line: pos.Line, Column: pos.Column},
+			End:      syntax.Position{Offset: end.Offset, Line: end.Line, Column: end.Column},
+			Message: "the " + c.shell + " program is built at run time, so its values run as code; " +
+				`pass them as arguments instead, like exec.Command("sh", "-c", "echo \"$1\"", "sh", x)`,
+		})
+	}
+	sort.Stable(byPos(diags))
+	return diags, nil
+}
+
+type byPos []lint.Diagnostic
+
+func (b byPos) Len() int           { return len(b) }
+func (b byPos) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPos) Less(i, j int) bool { return b[i].Pos.Offset < b[j].Pos.Offset }
+
+// shellCmdEnabled reports whether a linter runs the shellcmd analyzer.
+func shellCmdEnabled(l *lint.Linter) bool {
+	if l.Analyzers != nil {
+		found := false
+		for _, a := range l.Analyzers {
+			found = found || a == lint.ShellCmd
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, name := range l.Disable {
+		if name == "all" || name == lint.ShellCmd.Name || name == lint.ShellCmd.Code {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package gosrc
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+const goSrc = `package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+const build = "make $TARGET"
+
+//sh:script bash
+const setup = ` + "`" + `
+set -e
+[[ -d $HOME ]]
+` + "`" + `
+
+func main() {
+	exec.Command("sh", "-c", "echo \"x\" $HOME")
+	exec.Command("/bin/sh", "-c", build)
+	exec.CommandContext(context.Background(), "bash", "-c", "rm -rf $1/", "bash", "x")
+	exec.Command("sh", "-c", fmt.Sprintf("cat %s", "x"))
+	exec.Command("python", "-c", "print(1)")
+	x := "echo $PWD" //sh:script
+	_ = x
+}
+`
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", goSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := Scripts(fset, f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shells []string
+	for _, s := range scripts {
+		shells = append(shells, s.Shell)
+	}
+	if got, want := fmt.Sprint(shells), "[sh sh bash bash sh]"; got != want {
+		t.Fatalf("wrong scripts:\nwant: %s\ngot:  %s", want, got)
+	}
+	diags, err := Lint(fset, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, fmt.Sprintf("%d:%d: %s", d.Pos.Line, d.Pos.Column, d.Code))
+	}
+	want := []string{
+		"9:21: SH1001",
+		"18:39: SH1001",
+		"20:66: SH1001",
+		"20:66: SH1007",
+		"21:27: SH1006",
+		"23:13: SH1001",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Lint mismatch:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+	src := "package p\n\nimport \"os/exec\"\n\nvar c = exec.Command(\"sh\", \"-c\", \"a\\t'b\")\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Scripts(fset, f, 0)
+	perr, ok := err.(*syntax.ParseError)
+	if !ok {
+		t.Fatalf("want a *syntax.ParseError, got %v", err)
+	}
+	if got, want := perr.Error(), "p.go:5:38: reached EOF without closing quote '"; got != want {
+		t.Fatalf("wrong error:\nwant: %s\ngot:  %s", want, got)
+	}
+}
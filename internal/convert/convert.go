@@ -0,0 +1,91 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package convert holds what the packages that convert shell programs
+// to other languages have in common.
+package convert
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Error is a construct that couldn't be converted.
+type Error struct {
+	syntax.Position
+	Filename, Text string
+}
+
+// Errorf returns the error for a node of a file.
+func Errorf(f *syntax.File, node syntax.Node, format string, a ...interface{}) Error {
+	return Error{
+		Position: f.Position(node.Pos()),
+		Filename: f.Name,
+		Text:     fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *Error) Error() string {
+	prefix := ""
+	if e.Filename != "" {
+		prefix = e.Filename + ":"
+	}
+	return fmt.Sprintf("%s%d:%d: %s", prefix, e.Line, e.Column, e.Text)
+}
+
+// LeftAssoc returns a list of commands with && and || grouped from the
+// left, as the shell runs them, since the parser groups them from the
+// right.
+func LeftAssoc(bc *syntax.BinaryCmd) *syntax.BinaryCmd {
+	for IsList(bc) {
+		y, ok := bc.Y.Cmd.(*syntax.BinaryCmd)
+		if !ok || !IsList(y) || bc.Y.Negated || len(bc.Y.Redirs) > 0 || bc.Y.Background {
+			break
+		}
+		x := &syntax.Stmt{Position: bc.X.Pos(), Cmd: &syntax.BinaryCmd{
+			OpPos: bc.OpPos, Op: bc.Op, X: bc.X, Y: y.X,
+		}}
+		bc = &syntax.BinaryCmd{OpPos: y.OpPos, Op: y.Op, X: x, Y: y.Y}
+	}
+	return bc
+}
+
+// IsList reports whether a binary command is a list with && or ||.
+func IsList(bc *syntax.BinaryCmd) bool {
+	return bc.Op == syntax.AndStmt || bc.Op == syntax.OrStmt
+}
+
+// CmdName returns how a command is named in errors.
+func CmdName(cmd syntax.Command) string {
+	switch cmd.(type) {
+	case *syntax.Subshell:
+		return "a subshell"
+	case *syntax.FuncDecl:
+		return "a function"
+	case *syntax.CaseClause:
+		return "a case clause"
+	case *syntax.ArithmCmd:
+		return "(( ))"
+	case *syntax.LetClause:
+		return "let"
+	case *syntax.EvalClause:
+		return "eval"
+	case *syntax.CoprocClause:
+		return "a coprocess"
+	}
+	return "this command"
+}
+
+// AllArgs reports whether a list of words is just "$@".
+func AllArgs(list []*syntax.Word) bool {
+	if len(list) != 1 || len(list[0].Parts) != 1 {
+		return false
+	}
+	dq, ok := list[0].Parts[0].(*syntax.DblQuoted)
+	if !ok || len(dq.Parts) != 1 {
+		return false
+	}
+	pe, ok := dq.Parts[0].(*syntax.ParamExp)
+	return ok && pe.Param != nil && pe.Param.Value == "@" && pe.Exp == nil && pe.Ind == nil
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package walk finds the shell programs in directories, for the
+// commands that process whole repositories.
+package walk
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultSkip are the patterns of the vendored paths that are skipped
+// by default.
+var DefaultSkip = []string{"vendor", "node_modules"}
+
+// Config controls which files are found when walking directories.
+type Config struct {
+	// Skip are the patterns of the files and directories to skip, as
+	// understood by path.Match. A pattern matches the name of a file,
+	// or its slash-separated path relative to the walked directory.
+	Skip []string
+
+	// Markdown makes Walk find the Markdown documents instead of the
+	// shell programs, which are the files with the .md or .markdown
+	// extension.
+	Markdown bool
+}
+
+var (
+	shellFile    = regexp.MustCompile(`\.(sh|bash)$`)
+	markdownFile = regexp.MustCompile(`\.(md|markdown)$`)
+	validShebang = regexp.MustCompile(`^#!\s?/(usr/)?bin/(env\s+)?(sh|bash|dash|ksh|mksh)(\s|$)`)
+	vcsDir       = regexp.MustCompile(`^\.(git|svn|hg)$`)
+)
+
+type shellConfidence int
+
+const (
+	notShellFile shellConfidence = iota
+	ifValidShebang
+	isShellFile
+)
+
+func getConfidence(info os.FileInfo) shellConfidence {
+	name := info.Name()
+	switch {
+	case info.IsDir(), name[0] == '.', !info.Mode().IsRegular():
+		return notShellFile
+	case shellFile.MatchString(name):
+		return isShellFile
+	case strings.Contains(name, "."):
+		return notShellFile // different extension
+	case info.Size() < 8:
+		return notShellFile // cannot possibly hold valid shebang
+	default:
+		return ifValidShebang
+	}
+}
+
+// headSize is how much of a file is read to find out whether it's a
+// shell program.
+const headSize = 512
+
+// Walk calls fn with the path of each shell program found in root. If
+// root isn't a directory, fn is called with it as is.
+//
+// Hidden files, version control directories and the paths matching the
+// skip patterns are skipped. Files are shell programs if they have the
+// .sh or .bash extension, or no extension and a shell shebang like
+// "#!/bin/sh". Binary files are skipped.
+//
+// The errors found while walking are passed to onError, and so are the
+// ones returned by fn.
+func (c Config) Walk(root string, fn func(path string) error, onError func(error)) {
+	info, err := os.Stat(root)
+	if err != nil {
+		onError(err)
+		return
+	}
+	if !info.IsDir() {
+		if err := fn(root); err != nil {
+			onError(err)
+		}
+		return
+	}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			onError(err)
+			return nil
+		}
+		if path != root && c.skip(root, path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if vcsDir.MatchString(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		var ok bool
+		if c.Markdown {
+			ok = IsMarkdown(path) && info.Mode().IsRegular()
+		} else if conf := getConfidence(info); conf != notShellFile {
+			ok, err = isShell(path, conf == ifValidShebang)
+		}
+		if err == nil && ok {
+			err = fn(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			onError(err)
+		}
+		return nil
+	})
+}
+
+// IsShell reports whether the file at a path is a shell program, the
+// same way as Walk does for the files in directories. It doesn't
+// report an error if the file doesn't exist.
+func IsShell(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	conf := getConfidence(info)
+	if conf == notShellFile {
+		return false, nil
+	}
+	return isShell(path, conf == ifValidShebang)
+}
+
+// IsMarkdown reports whether a path is of a Markdown document, from its
+// extension. Hidden files aren't.
+func IsMarkdown(path string) bool {
+	name := filepath.Base(path)
+	return name[0] != '.' && markdownFile.MatchString(name)
+}
+
+func (c Config) skip(root, fpath string) bool {
+	rel, err := filepath.Rel(root, fpath)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pat := range c.Skip {
+		if ok, _ := path.Match(pat, path.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isShell reads the start of a file to find out whether it's a shell
+// program, which isn't binary and may need a shebang.
+func isShell(path string, needShebang bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, headSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	head = head[:n]
+	if needShebang && !validShebang.Match(head) {
+		return false, nil
+	}
+	return bytes.IndexByte(head, 0) < 0, nil
+}
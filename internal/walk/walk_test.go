@@ -0,0 +1,98 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package walk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "sh-walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.sh":                 "foo",
+		"dash-script":          "#!/bin/dash\nfoo",
+		"env-script":           "#!/usr/bin/env mksh\nfoo",
+		"python-script":        "#!/usr/bin/env python\nfoo",
+		"shell-lookalike":      "#!/bin/shell\nfoo",
+		"binary.sh":            "\x7fELF\x00\x00",
+		"binary-shebang":       "#!/bin/sh\n\x00\x00\x00",
+		"vendor/lib.sh":        "foo",
+		"node_modules/x/b.sh":  "foo",
+		"build/gen/c.sh":       "foo",
+		"build/d.sh":           "foo",
+		"sub/.hidden/e.sh":     "foo",
+		".git/hooks/pre.sh":    "foo",
+		"sub/deeper/f.bash":    "foo",
+		"sub/deeper/README.md": "foo",
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	c := Config{Skip: append(DefaultSkip, "build/gen")}
+	c.Walk(dir, func(path string) error {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	}, func(err error) { t.Error(err) })
+	sort.Strings(got)
+	want := []string{"a.sh", "build/d.sh", "dash-script", "env-script",
+		"sub/.hidden/e.sh", "sub/deeper/f.bash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Walk mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+
+	got = nil
+	mc := Config{Skip: c.Skip, Markdown: true}
+	mc.Walk(dir, func(path string) error {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	}, func(err error) { t.Error(err) })
+	if want := []string{"sub/deeper/README.md"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Walk of Markdown mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+
+	got = nil
+	c.Walk(filepath.Join(dir, "python-script"), func(path string) error {
+		got = append(got, filepath.Base(path))
+		return nil
+	}, func(err error) { t.Error(err) })
+	if want := []string{"python-script"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Walk of a file mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+
+	for name, want := range map[string]bool{
+		"a.sh":                 true,
+		"dash-script":          true,
+		"python-script":        false,
+		"binary.sh":            false,
+		"sub/deeper/README.md": false,
+		"missing.sh":           false,
+	} {
+		got, err := IsShell(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("IsShell(%q): want %v, got %v", name, want, got)
+		}
+	}
+}
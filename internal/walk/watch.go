@@ -0,0 +1,94 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package walk
+
+import (
+	"os"
+	"time"
+)
+
+// DefaultInterval is how often a Watcher looks for changes by default.
+const DefaultInterval = 500 * time.Millisecond
+
+// Watcher finds the shell programs that change in directories, by
+// periodically walking them and comparing the modification times and
+// sizes of their files.
+type Watcher struct {
+	Config
+
+	// Interval is how often to look for changes. If zero,
+	// DefaultInterval is used.
+	Interval time.Duration
+
+	seen map[string]fileState
+}
+
+type fileState struct {
+	mtime time.Time
+	size  int64
+}
+
+func stat(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{info.ModTime(), info.Size()}, nil
+}
+
+// Changed returns the shell programs in roots that were created or
+// modified since the last call, or all of them on the first call.
+func (w *Watcher) Changed(roots []string, onError func(error)) []string {
+	if w.seen == nil {
+		w.seen = make(map[string]fileState)
+	}
+	var changed []string
+	found := make(map[string]bool)
+	for _, root := range roots {
+		w.Walk(root, func(path string) error {
+			st, err := stat(path)
+			if err != nil {
+				return err
+			}
+			found[path] = true
+			if old, ok := w.seen[path]; !ok || old != st {
+				w.seen[path] = st
+				changed = append(changed, path)
+			}
+			return nil
+		}, onError)
+	}
+	for path := range w.seen {
+		if !found[path] {
+			// removed; if it comes back, it's changed
+			delete(w.seen, path)
+		}
+	}
+	return changed
+}
+
+// Run calls fn with each shell program in roots, and then again with
+// each one that changes, until stop is closed. Changes made by fn
+// itself, like formatting the file, don't make it run again.
+func (w *Watcher) Run(roots []string, fn func(path string) error, onError func(error), stop <-chan struct{}) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	for {
+		for _, path := range w.Changed(roots, onError) {
+			if err := fn(path); err != nil {
+				onError(err)
+			}
+			if st, err := stat(path); err == nil {
+				w.seen[path] = st
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
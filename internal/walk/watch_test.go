@@ -0,0 +1,71 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package walk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "sh-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a.sh"), filepath.Join(dir, "b.sh")
+	write := func(path, body string) {
+		if err := ioutil.WriteFile(path, []byte(body), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "foo")
+	write(b, "bar")
+	w := &Watcher{}
+	changed := func(want ...string) {
+		got := w.Changed([]string{dir}, func(err error) { t.Fatal(err) })
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("wrong changed files:\nwant: %q\ngot:  %q", want, got)
+		}
+	}
+	changed(a, b)
+	changed()
+	write(a, "foo bar")
+	changed(a)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(b, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed(b)
+	if err := os.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	changed()
+	write(a, "foo")
+	changed(a)
+
+	stop := make(chan struct{})
+	var ran []string
+	w = &Watcher{Interval: time.Millisecond}
+	w.Run([]string{dir}, func(path string) error {
+		ran = append(ran, path)
+		// a change made by fn itself is ignored
+		write(path, "formatted")
+		if len(ran) == 2 {
+			close(stop)
+		}
+		return nil
+	}, func(err error) { t.Fatal(err) }, stop)
+	sort.Strings(ran)
+	if want := []string{a, b}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("wrong files run:\nwant: %q\ngot:  %q", want, ran)
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// builtinNames are the names of the builtins, sorted.
+var builtinNames = []string{
+	".", ":", "[", "break", "cd", "continue", "declare", "echo",
+	"eval", "exec", "exit", "export", "false", "getopts", "history",
+	"local", "printf", "pwd", "read", "readonly", "return", "set",
+	"shift", "source", "test", "true", "typeset", "unset", "wait",
+}
+
+func isBuiltin(name string) bool {
+	i := sort.SearchStrings(builtinNames, name)
+	return i < len(builtinNames) && builtinNames[i] == name
+}
+
+// builtin runs a builtin command and returns its exit status.
+func (r *Runner) builtin(pos syntax.Pos, name string, args []string) int {
+	switch name {
+	case "true", ":":
+	case "false":
+		return 1
+	case "exit":
+		switch len(args) {
+		case 0:
+			r.exitShell = true
+			return r.exit
+		case 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				r.errf("exit: invalid exit code: %q\n", args[0])
+				r.exitShell = true
+				return 2
+			}
+			r.exitShell = true
+			// statuses are a byte, like in other shells
+			return n & 255
+		default:
+			r.errf("exit: too many arguments\n")
+			return 1
+		}
+	case "return":
+		if len(r.funcStack) == 0 && r.sourcing == 0 {
+			r.errf("return: can only be done from a func or sourced script\n")
+			return 1
+		}
+		code := r.exit
+		switch len(args) {
+		case 0:
+		case 1:
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				r.errf("return: invalid exit code: %q\n", args[0])
+				return 2
+			}
+			code = n & 255
+		default:
+			r.errf("return: too many arguments\n")
+			return 1
+		}
+		r.returning = true
+		return code
+	case "source", ".":
+		if len(args) == 0 {
+			r.errf("%s: filename argument required\n", name)
+			return 2
+		}
+		return r.source(name, args)
+	case "wait":
+		if len(args) > 0 {
+			r.errf("wait: waiting for specific jobs isn't supported\n")
+			return 2
+		}
+		r.bgJobs.Wait()
+	case "break", "continue":
+		if r.inLoop == 0 {
+			r.errf("%s: only meaningful in a loop\n", name)
+			return 0
+		}
+		n := 1
+		switch len(args) {
+		case 0:
+		case 1:
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				r.errf("%s: invalid loop count: %q\n", name, args[0])
+				return 1
+			}
+		default:
+			r.errf("%s: too many arguments\n", name)
+			return 1
+		}
+		if n > r.inLoop {
+			n = r.inLoop
+		}
+		if name == "break" {
+			r.breakEnclosing = n
+		} else {
+			r.contnEnclosing = n
+		}
+	case "cd":
+		var dir string
+		switch len(args) {
+		case 0:
+			dir = r.getVar("HOME")
+		case 1:
+			dir = args[0]
+		default:
+			r.errf("cd: too many arguments\n")
+			return 2
+		}
+		if dir == "-" {
+			// like in other shells, "cd -" prints the directory
+			dir = r.getVar("OLDPWD")
+			r.outf("%s\n", dir)
+		}
+		info, err := r.fs().Stat(r.relPath(dir))
+		if err != nil || !info.IsDir() {
+			r.errf("cd: %s: not a directory\n", dir)
+			return 1
+		}
+		oldDir := r.dir
+		r.dir = filepath.Clean(r.relPath(dir))
+		if err = r.setVar("OLDPWD", oldDir); err == nil {
+			err = r.setVar("PWD", r.dir)
+		}
+		if err != nil {
+			r.errf("cd: %v\n", err)
+			return 1
+		}
+	case "pwd":
+		r.outf("%s\n", r.dir)
+	case "unset":
+		funcs := false
+		for _, arg := range args {
+			switch arg {
+			case "-v":
+				funcs = false
+			case "-f":
+				funcs = true
+			default:
+				if funcs {
+					r.ownFuncs()
+					delete(r.funcs, arg)
+				} else if name, index, ok := splitIndex(arg); ok {
+					r.unsetElem(name, index)
+				} else {
+					r.delVar(arg)
+				}
+			}
+		}
+	case "shift":
+		n := 1
+		switch len(args) {
+		case 0:
+		case 1:
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+				r.errf("shift: invalid number: %q\n", args[0])
+				return 1
+			}
+		default:
+			r.errf("shift: too many arguments\n")
+			return 2
+		}
+		if n > len(r.params) {
+			return 1
+		}
+		r.params = r.params[n:]
+	case "set":
+		return r.setBuiltin(args)
+	case "getopts":
+		return r.getopts(args)
+	case "history":
+		return r.historyBuiltin(args)
+	case "test", "[":
+		return r.testBuiltin(name, args)
+	case "read":
+		return r.read(args)
+	case "eval":
+		r.evalSrc(pos, strings.Join(args, " "))
+		return r.exit
+	case "exec":
+		if len(args) == 0 {
+			// the redirects apply to the rest of the shell
+			r.keepRedirs = true
+			return 0
+		}
+		r.exec(pos, args)
+		r.exitShell = true
+		return r.exit
+	case "export", "local", "readonly", "declare", "typeset":
+		r.declBuiltin(name, args)
+		return r.exit
+	case "echo":
+		r.echo(args)
+	case "printf":
+		return r.printf(args)
+	default:
+		r.runErr(pos, "unhandled builtin: %s", name)
+	}
+	return 0
+}
+
+// setBuiltin implements the set builtin, which sets the shell options
+// and positional parameters, and lists the variables if run with no
+// arguments.
+func (r *Runner) setBuiltin(args []string) int {
+	if len(args) == 0 {
+		vars := r.allVars()
+		names := make([]string, 0, len(vars))
+		for name, vr := range vars {
+			if vr.Set {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			r.outf("%s=%s\n", name, quote(vars[name].String()))
+		}
+		return 0
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			r.params = args[i+1:]
+			return 0
+		case len(arg) > 1 && (arg[0] == '-' || arg[0] == '+'):
+			enable := arg[0] == '-'
+			for j, c := range arg[1:] {
+				if c == 'o' && j == len(arg)-2 {
+					// the long name is the next argument, like
+					// in "set -eo pipefail"
+					if i+1 == len(args) {
+						r.printOptions(enable)
+						return 0
+					}
+					i++
+					opt := r.option(args[i])
+					if opt == nil {
+						r.errf("set: invalid option name: %q\n", args[i])
+						return 2
+					}
+					*opt = enable
+					break
+				}
+				opt := r.shortOption(c)
+				if opt == nil {
+					r.errf("set: invalid option: %q\n", arg)
+					return 2
+				}
+				*opt = enable
+			}
+		default:
+			r.params = args[i:]
+			return 0
+		}
+	}
+	return 0
+}
+
+// shellOptions lists the options that set supports, with their short
+// names if they have one.
+var shellOptions = []struct {
+	short rune
+	long  string
+}{
+	{'e', "errexit"},
+	{'u', "nounset"},
+	{0, "pipefail"},
+	{'x', "xtrace"},
+}
+
+func (r *Runner) option(long string) *bool {
+	switch long {
+	case "errexit":
+		return &r.opts.ErrExit
+	case "nounset":
+		return &r.opts.NoUnset
+	case "pipefail":
+		return &r.opts.PipeFail
+	case "xtrace":
+		return &r.opts.XTrace
+	}
+	return nil
+}
+
+func (r *Runner) shortOption(c rune) *bool {
+	for _, opt := range shellOptions {
+		if opt.short == c && c != 0 {
+			return r.option(opt.long)
+		}
+	}
+	return nil
+}
+
+// printOptions lists the state of the options like set -o does, or as
+// the set commands that would restore them, like set +o does.
+func (r *Runner) printOptions(human bool) {
+	for _, opt := range shellOptions {
+		enabled := *r.option(opt.long)
+		switch {
+		case human && enabled:
+			r.outf("%s\ton\n", opt.long)
+		case human:
+			r.outf("%s\toff\n", opt.long)
+		case enabled:
+			r.outf("set -o %s\n", opt.long)
+		default:
+			r.outf("set +o %s\n", opt.long)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Coverage records which statements of the programs run, and how many
+// times, to export them as a coverage report. It is used by setting
+// Runner.Coverage.
+//
+// A Coverage can be shared by many runners, such as to add up the
+// statements run by all the scripts of a test suite. The statements of
+// the files with the same name are counted together, so the same file
+// must always be given the same name. Code run via eval isn't counted.
+type Coverage struct {
+	mu sync.Mutex
+
+	// files are the files being covered, by name, and names their
+	// names in the order that they were first run
+	files map[string]*fileCoverage
+	names []string
+
+	// known holds the parsed files whose statements are counted,
+	// unlike the ones parsed by eval
+	known map[*syntax.File]bool
+}
+
+// StmtCoverage is the coverage of a single statement.
+type StmtCoverage struct {
+	Pos, End syntax.Position
+	Hits     int
+}
+
+type fileCoverage struct {
+	// stmts are sorted by position, and index maps their positions
+	// to their index in stmts
+	stmts []StmtCoverage
+	index map[syntax.Pos]int
+}
+
+// addFile starts counting the statements in a file.
+func (c *Coverage) addFile(f *syntax.File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.known == nil {
+		c.known = make(map[*syntax.File]bool)
+		c.files = make(map[string]*fileCoverage)
+	}
+	c.known[f] = true
+	if c.files[f.Name] != nil {
+		return
+	}
+	fc := &fileCoverage{index: make(map[syntax.Pos]int)}
+	var poss []syntax.Pos
+	syntax.Walk(stmtVisitor(func(s *syntax.Stmt) {
+		poss = append(poss, s.Pos())
+		fc.stmts = append(fc.stmts, StmtCoverage{
+			Pos: f.Position(s.Pos()),
+			End: f.Position(s.End()),
+		})
+	}), f)
+	sort.Sort(byPos{fc.stmts, poss})
+	for i, pos := range poss {
+		fc.index[pos] = i
+	}
+	c.files[f.Name] = fc
+	c.names = append(c.names, f.Name)
+}
+
+type stmtVisitor func(*syntax.Stmt)
+
+func (v stmtVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		v(x)
+	case *syntax.EvalClause:
+		// the arguments to eval are source code, not a statement
+		return nil
+	}
+	return v
+}
+
+// byPos sorts statements along with their positions.
+type byPos struct {
+	stmts []StmtCoverage
+	poss  []syntax.Pos
+}
+
+func (b byPos) Len() int           { return len(b.stmts) }
+func (b byPos) Less(i, j int) bool { return b.poss[i] < b.poss[j] }
+func (b byPos) Swap(i, j int) {
+	b.stmts[i], b.stmts[j] = b.stmts[j], b.stmts[i]
+	b.poss[i], b.poss[j] = b.poss[j], b.poss[i]
+}
+
+// hit counts a statement of a file that is about to run.
+func (c *Coverage) hit(f *syntax.File, s *syntax.Stmt) {
+	c.mu.Lock()
+	if c.known[f] {
+		fc := c.files[f.Name]
+		if i, ok := fc.index[s.Pos()]; ok {
+			fc.stmts[i].Hits++
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Files returns the names of the files that ran, in the order that they
+// first ran.
+func (c *Coverage) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.names...)
+}
+
+// Stmts returns the coverage of the statements in a file, sorted by
+// position. The statements that never ran have zero hits.
+func (c *Coverage) Stmts(name string) []StmtCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fc := c.files[name]
+	if fc == nil {
+		return nil
+	}
+	return append([]StmtCoverage(nil), fc.stmts...)
+}
+
+// lineHits returns the hits of each line that has statements, which are
+// the most hits of any statement starting in it, along with the sorted
+// lines.
+func lineHits(stmts []StmtCoverage) (map[int]int, []int) {
+	hits := make(map[int]int)
+	var lines []int
+	for _, sc := range stmts {
+		n, ok := hits[sc.Pos.Line]
+		if !ok {
+			lines = append(lines, sc.Pos.Line)
+		}
+		if !ok || sc.Hits > n {
+			hits[sc.Pos.Line] = sc.Hits
+		}
+	}
+	sort.Ints(lines)
+	return hits, lines
+}
+
+// WriteLCOV writes the coverage of the lines with statements in the LCOV
+// tracefile format, which tools like genhtml understand.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, name := range c.Files() {
+		hits, lines := lineHits(c.Stmts(name))
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", name)
+		hit := 0
+		for _, line := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, hits[line])
+			if hits[line] > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+	return bw.Flush()
+}
+
+// WriteHTML writes an HTML page with the source of each file, where the
+// lines with statements are marked as run or not, and with their hits.
+// The sources are read via readFile, or ioutil.ReadFile if nil.
+func (c *Coverage) WriteHTML(w io.Writer, readFile func(name string) ([]byte, error)) error {
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+	type htmlLine struct {
+		Num, Hits int
+		Class     string
+		Text      string
+	}
+	type htmlFile struct {
+		Name     string
+		Percent  string
+		Lines    []htmlLine
+		Hit, All int
+	}
+	var files []htmlFile
+	for _, name := range c.Files() {
+		src, err := readFile(name)
+		if err != nil {
+			return err
+		}
+		hits, lines := lineHits(c.Stmts(name))
+		hf := htmlFile{Name: name, All: len(lines)}
+		text := strings.TrimSuffix(string(bytes.Replace(src, []byte("\r\n"), []byte("\n"), -1)), "\n")
+		for i, s := range strings.Split(text, "\n") {
+			hl := htmlLine{Num: i + 1, Text: s}
+			if n, ok := hits[hl.Num]; ok {
+				hl.Hits, hl.Class = n, "miss"
+				if n > 0 {
+					hl.Class = "hit"
+					hf.Hit++
+				}
+			}
+			hf.Lines = append(hf.Lines, hl)
+		}
+		if hf.All > 0 {
+			hf.Percent = fmt.Sprintf("%.1f%%", 100*float64(hf.Hit)/float64(hf.All))
+		}
+		files = append(files, hf)
+	}
+	return htmlCoverage.Execute(w, files)
+}
+
+var htmlCoverage = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { line-height: 1.3; }
+.num, .hits { color: #888; display: inline-block; text-align: right; width: 4em; margin-right: 1em; }
+.hit { background: #cfc; }
+.miss { background: #fcc; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.Name}}{{if .Percent}} ({{.Percent}} of {{.All}} lines){{end}}</h2>
+<pre>{{range .Lines}}<span class="num">{{.Num}}</span><span class="hits">{{if .Class}}{{.Hits}}{{end}}</span><span class="{{.Class}}">{{.Text}}</span>
+{{end}}</pre>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,185 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// A Debugger controls the execution of a program, pausing it before
+// statements so that its state can be inspected and modified. It is
+// used by setting Runner.Debugger, and its methods are safe to call
+// from any goroutine while the program runs.
+type Debugger struct {
+	// Paused is called when the program pauses before a statement,
+	// either because of a breakpoint or because it was stepping. The
+	// program waits until Paused returns, and the returned action
+	// decides how it continues.
+	//
+	// Only one call to Paused runs at a time. Statements that run
+	// concurrently, like in pipelines, wait for it to return.
+	Paused func(f *Frame) DebugAction
+
+	// pauseMu is held while Paused runs
+	pauseMu sync.Mutex
+
+	mu          sync.Mutex
+	breakpoints map[Breakpoint]bool
+
+	// step is the current stepping action, and stepDepth the depth
+	// of the function calls when it was chosen
+	step      DebugAction
+	stepDepth int
+
+	// last is the line of the last statement, so that a breakpoint
+	// hits only once for all the statements in its line
+	last Breakpoint
+}
+
+// Breakpoint is a line in a file, which pauses the program before the
+// first statement that starts in it. Filename is the name of the
+// syntax.File being run.
+type Breakpoint struct {
+	Filename string
+	Line     int
+}
+
+// DebugAction is how a paused program continues.
+type DebugAction uint8
+
+const (
+	DebugContinue DebugAction = iota // until the next breakpoint
+	DebugStep                        // until the next statement
+	DebugNext                        // until the next statement outside further function calls
+	DebugStepOut                     // until the current function returns
+)
+
+// SetBreakpoint adds a breakpoint at a line in a file.
+func (d *Debugger) SetBreakpoint(filename string, line int) {
+	d.mu.Lock()
+	if d.breakpoints == nil {
+		d.breakpoints = make(map[Breakpoint]bool)
+	}
+	d.breakpoints[Breakpoint{filename, line}] = true
+	d.mu.Unlock()
+}
+
+// ClearBreakpoint removes a breakpoint, if it exists.
+func (d *Debugger) ClearBreakpoint(filename string, line int) {
+	d.mu.Lock()
+	delete(d.breakpoints, Breakpoint{filename, line})
+	d.mu.Unlock()
+}
+
+// Breakpoints returns the breakpoints, sorted by file and line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	bps := make([]Breakpoint, 0, len(d.breakpoints))
+	for bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	d.mu.Unlock()
+	sort.Sort(byLine(bps))
+	return bps
+}
+
+type byLine []Breakpoint
+
+func (b byLine) Len() int      { return len(b) }
+func (b byLine) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byLine) Less(i, j int) bool {
+	if b[i].Filename != b[j].Filename {
+		return b[i].Filename < b[j].Filename
+	}
+	return b[i].Line < b[j].Line
+}
+
+// Pause makes the program pause before the next statement, such as to
+// stop on entry if called before Run.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.step, d.stepDepth = DebugStep, 0
+	d.mu.Unlock()
+}
+
+// shouldPause reports whether the program must pause before a statement
+// at a position, with depth function calls in the stack.
+func (d *Debugger) shouldPause(pos Breakpoint, depth int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	newLine := pos != d.last
+	d.last = pos
+	switch d.step {
+	case DebugStep:
+		return true
+	case DebugNext:
+		if depth <= d.stepDepth {
+			return true
+		}
+	case DebugStepOut:
+		if depth < d.stepDepth {
+			return true
+		}
+	}
+	return newLine && d.breakpoints[pos]
+}
+
+// beforeStmt pauses the program before a statement if needed. Blocks,
+// like the bodies of functions, pause before their first statement
+// instead.
+func (d *Debugger) beforeStmt(r *Runner, s *syntax.Stmt) {
+	if _, ok := s.Cmd.(*syntax.Block); ok || d.Paused == nil {
+		return
+	}
+	p := r.file.Position(s.Pos())
+	depth := len(r.funcStack)
+	if !d.shouldPause(Breakpoint{r.file.Name, p.Line}, depth) {
+		return
+	}
+	d.pauseMu.Lock()
+	action := d.Paused(&Frame{
+		Stmt:     s,
+		Filename: r.file.Name,
+		Position: p,
+		r:        r,
+	})
+	d.pauseMu.Unlock()
+	d.mu.Lock()
+	d.step, d.stepDepth = action, depth
+	d.mu.Unlock()
+}
+
+// Frame is the state of a paused program. It must only be used until
+// Paused returns.
+type Frame struct {
+	// Stmt is the statement that is about to run.
+	Stmt *syntax.Stmt
+
+	// Filename and Position are where the statement is.
+	Filename string
+	Position syntax.Position
+
+	r *Runner
+}
+
+// Env returns the variables of the program, in the scope of the
+// statement. Changes made via Set apply to the rest of the program,
+// except when paused in a subshell, where they are lost once it ends.
+func (f *Frame) Env() expand.Environ { return expandEnv{f.r} }
+
+// Params returns the positional parameters, like $1, of the script or
+// of the function being run.
+func (f *Frame) Params() []string {
+	return append([]string(nil), f.r.params...)
+}
+
+// Funcs returns the names of the functions in the call stack, innermost
+// last.
+func (f *Frame) Funcs() []string {
+	return append([]string(nil), f.r.funcStack...)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// dryf writes a comment to DryRun about the node at pos.
+func (r *Runner) dryf(pos syntax.Pos, format string, a ...interface{}) {
+	p := r.file.Position(pos)
+	fmt.Fprintf(r.DryRun, "# %d:%d: %s\n", p.Line, p.Column,
+		fmt.Sprintf(format, a...))
+}
+
+// dryCmd writes a command to DryRun instead of running it.
+func (r *Runner) dryCmd(fields []string, assigns []*syntax.Assign) {
+	var buf bytes.Buffer
+	for _, as := range assigns {
+		fmt.Fprintf(&buf, "%s=%s ", as.Name.Value, quote(r.assignValue(as)))
+	}
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(quote(field))
+	}
+	buf.WriteByte('\n')
+	r.DryRun.Write(buf.Bytes())
+	r.exit = 0
+	r.unknown = true
+}
+
+// dryCond notes when a condition depends on the exit status of a
+// command that wasn't run.
+func (r *Runner) dryCond(pos syntax.Pos, what string) {
+	if r.DryRun != nil && r.unknown {
+		r.dryf(pos, "%s depends on an unknown exit status, assuming success", what)
+	}
+}
+
+// dryOutput notes when a word that decides the control flow depends on
+// the output of a command that wasn't run.
+func (r *Runner) dryOutput(pos syntax.Pos, what string) {
+	if r.DryRun != nil && r.unknown {
+		r.dryf(pos, "%s depends on unknown command output, assuming none", what)
+	}
+}
+
+// quote returns s quoted so that the shell reads it as a single word.
+func quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("_-+=.,:/@%", c):
+		default:
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		}
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"io"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/pattern"
+	"github.com/mvdan/sh/syntax"
+)
+
+// expandEnv exposes the variables of a runner to the expand package.
+type expandEnv struct {
+	r *Runner
+}
+
+func (e expandEnv) Get(name string) expand.Variable {
+	if !isVarName(name) {
+		val, ok := e.r.getParam(name)
+		return expand.Variable{Set: ok, Value: val}
+	}
+	vr, _ := e.r.lookupVar(name)
+	return vr
+}
+
+func (e expandEnv) Set(name string, vr expand.Variable) error {
+	if old, _ := e.r.lookupVar(name); old.ReadOnly {
+		return &expand.ReadOnlyError{Name: name}
+	}
+	if !vr.Set {
+		e.r.delVar(name)
+		return nil
+	}
+	e.r.setVarFull(name, vr)
+	return nil
+}
+
+func (e expandEnv) Each(fn func(name string, vr expand.Variable) bool) {
+	for name, vr := range e.r.allVars() {
+		if !fn(name, vr) {
+			return
+		}
+	}
+}
+
+func (r *Runner) expandConfig() expand.Config {
+	return expand.Config{
+		Env:     expandEnv{r},
+		Params:  r.params,
+		NoUnset: r.opts.NoUnset,
+		FS:      dirFS{r},
+		CmdSubst: func(w io.Writer, cs *syntax.CmdSubst) error {
+			r.exit = r.subExit(func(r2 *Runner) {
+				r2.stdout = w
+				r2.stmts(cs.Stmts)
+			})
+			return nil
+		},
+		ProcSubst: r.procSubst,
+	}
+}
+
+// expandErr handles an error found while expanding the node at pos.
+func (r *Runner) expandErr(pos syntax.Pos, err error) {
+	switch err.(type) {
+	case *expand.UnsetParameterError, *expand.ReadOnlyError:
+		r.errf("%v\n", err)
+		r.exit = 1
+		r.exitShell = true
+		return
+	}
+	r.runErr(pos, "%v", err)
+}
+
+// fields expands a list of words into fields, like the arguments to a
+// command.
+func (r *Runner) fields(words []*syntax.Word) []string {
+	cfg := r.expandConfig()
+	var fields []string
+	for _, w := range words {
+		wfields, err := cfg.Fields(w)
+		if err != nil {
+			r.expandErr(w.Pos(), err)
+			return nil
+		}
+		if r.Trace != nil {
+			r.Trace(TraceEvent{Kind: TraceExpand, Pos: w.Pos(), Args: wfields, Word: w})
+		}
+		fields = append(fields, wfields...)
+	}
+	return fields
+}
+
+// loneWord expands a word into a single string, without field
+// splitting, like in assignments.
+func (r *Runner) loneWord(w *syntax.Word) string {
+	s, err := r.expandConfig().Literal(w)
+	if err != nil {
+		r.expandErr(w.Pos(), err)
+	} else if r.Trace != nil {
+		r.Trace(TraceEvent{Kind: TraceExpand, Pos: w.Pos(), Args: []string{s}, Word: w})
+	}
+	return s
+}
+
+func (r *Runner) arithm(expr syntax.ArithmExpr) int {
+	n, err := r.expandConfig().Arithm(expr)
+	if err != nil {
+		r.expandErr(expr.Pos(), err)
+	}
+	return n
+}
+
+// match reports whether a string matches the pattern that a word
+// expands to.
+func (r *Runner) match(w *syntax.Word, s string) bool {
+	pat, err := r.expandConfig().Pattern(w)
+	if err != nil {
+		r.expandErr(w.Pos(), err)
+		return false
+	}
+	return pattern.Match(pat, s, pattern.ExtGlob)
+}
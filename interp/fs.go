@@ -0,0 +1,54 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FS is the file system that a Runner uses to open files for redirects,
+// expand filename patterns, evaluate file tests like -f and change
+// directories with cd. Names are always absolute and clean.
+//
+// Note that the programs that the Runner executes use the operating
+// system's file system regardless.
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error)
+	ReadDir(dir string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+}
+
+// OSFS is the FS backed by the operating system.
+var OSFS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	return os.OpenFile(name, flag, perm)
+}
+func (osFS) ReadDir(dir string) ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }
+func (osFS) Stat(name string) (os.FileInfo, error)     { return os.Stat(name) }
+func (osFS) Lstat(name string) (os.FileInfo, error)    { return os.Lstat(name) }
+
+func (r *Runner) fs() FS {
+	if r.FS == nil {
+		return OSFS
+	}
+	return r.FS
+}
+
+// dirFS exposes the FS of a runner to the expand and cond packages,
+// which may use paths relative to the working directory.
+type dirFS struct {
+	r *Runner
+}
+
+func (d dirFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	return d.r.fs().ReadDir(d.r.relPath(dir))
+}
+func (d dirFS) Stat(name string) (os.FileInfo, error)  { return d.r.fs().Stat(d.r.relPath(name)) }
+func (d dirFS) Lstat(name string) (os.FileInfo, error) { return d.r.fs().Lstat(d.r.relPath(name)) }
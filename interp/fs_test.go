@@ -0,0 +1,198 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// memFS is a minimal in-memory file system, where directories are
+// implied by the files that they contain.
+type memFS struct {
+	mu    sync.Mutex
+	files map[string]*bytes.Buffer
+}
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (m memInfo) Name() string       { return m.name }
+func (m memInfo) Size() int64        { return m.size }
+func (m memInfo) ModTime() time.Time { return time.Time{} }
+func (m memInfo) IsDir() bool        { return m.dir }
+func (m memInfo) Sys() interface{}   { return nil }
+func (m memInfo) Mode() os.FileMode {
+	if m.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+type memFile struct {
+	fs *memFS
+	r  io.Reader
+	w  *bytes.Buffer
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.r == nil {
+		return 0, fmt.Errorf("file not open for reading")
+	}
+	return f.r.Read(p)
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.w == nil {
+		return 0, fmt.Errorf("file not open for writing")
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.w.Write(p)
+}
+
+func (f *memFile) Close() error { return nil }
+
+func (m *memFS) OpenFile(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf := m.files[name]
+	if buf == nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		buf = new(bytes.Buffer)
+		m.files[name] = buf
+	}
+	if flag&os.O_TRUNC != 0 {
+		buf.Reset()
+	}
+	f := &memFile{fs: m}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		f.w = buf
+	}
+	if flag&os.O_WRONLY == 0 {
+		f.r = bytes.NewReader(buf.Bytes())
+	}
+	return f, nil
+}
+
+func (m *memFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]os.FileInfo)
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for name, buf := range m.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			seen[rest[:i]] = memInfo{name: rest[:i], dir: true}
+		} else {
+			seen[rest] = memInfo{name: rest, size: int64(buf.Len())}
+		}
+	}
+	if len(seen) == 0 {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: os.ErrNotExist}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	infos := make([]os.FileInfo, len(names))
+	for i, name := range names {
+		infos[i] = seen[name]
+	}
+	return infos, nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	buf := m.files[name]
+	m.mu.Unlock()
+	if buf != nil {
+		return memInfo{name: path.Base(name), size: int64(buf.Len())}, nil
+	}
+	if name == "/" {
+		return memInfo{name: "/", dir: true}, nil
+	}
+	if _, err := m.ReadDir(name); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{name: path.Base(name), dir: true}, nil
+}
+
+func (m *memFS) Lstat(name string) (os.FileInfo, error) { return m.Stat(name) }
+
+var fsTests = []struct {
+	in, want string
+}{
+	{"cd /data; pwd", "/data\n"},
+	{"cd /nosuchdir", "cd: /nosuchdir: not a directory\nexit status 1"},
+	{"echo /data/*", "/data/a.txt /data/b.txt /data/sub\n"},
+	{"cd /data; echo *.txt s*/*", "a.txt b.txt sub/c.txt\n"},
+	{"cat </data/a.txt", "foo\n"},
+	{"cat <nosuchfile", "open /tmp/nosuchfile: file does not exist\nexit status 1"},
+	{"echo bar >>/data/a.txt; cat </data/a.txt", "foo\nbar\n"},
+	{"cd /data/sub; echo new >new; cat <new; echo *", "new\nc.txt new\n"},
+	{"[[ -f /data/a.txt && -d /data/sub && ! -e /data/c.txt ]]", ""},
+}
+
+func TestRunFS(t *testing.T) {
+	t.Parallel()
+	for i, tc := range fsTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			fs := &memFS{files: map[string]*bytes.Buffer{
+				"/data/a.txt":     bytes.NewBufferString("foo\n"),
+				"/data/b.txt":     bytes.NewBufferString("bar\n"),
+				"/data/sub/c.txt": bytes.NewBufferString("baz\n"),
+			}}
+			var buf concBuffer
+			r := Runner{
+				Env:    expand.ListEnviron(),
+				Dir:    "/tmp",
+				FS:     fs,
+				Stdout: &buf,
+				Stderr: &buf,
+			}
+			// programs would use the real file system
+			r.Register("echo", func(ctx ExecContext, args []string) error {
+				fmt.Fprintln(ctx.Stdout, strings.Join(args[1:], " "))
+				return nil
+			})
+			r.Register("cat", func(ctx ExecContext, args []string) error {
+				_, err := io.Copy(ctx.Stdout, ctx.Stdin)
+				return err
+			})
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import "strconv"
+
+// GetoptsSpec is an option string as understood by getopts, like
+// ":ab:c".
+type GetoptsSpec struct {
+	// Silent is set if the string starts with a colon, in which case
+	// getopts reports errors via OPTARG instead of printing them.
+	Silent bool
+
+	// Flags are the options, in the order that they appear.
+	Flags []GetoptsFlag
+}
+
+// GetoptsFlag is an option in a getopts option string.
+type GetoptsFlag struct {
+	Name byte
+
+	// HasArg is set if the option takes an argument, which is marked
+	// with a colon after its name.
+	HasArg bool
+}
+
+// ParseGetopts parses a getopts option string.
+func ParseGetopts(optstring string) GetoptsSpec {
+	var spec GetoptsSpec
+	if len(optstring) > 0 && optstring[0] == ':' {
+		spec.Silent = true
+		optstring = optstring[1:]
+	}
+	for i := 0; i < len(optstring); i++ {
+		c := optstring[i]
+		if c == ':' {
+			continue
+		}
+		flag := GetoptsFlag{Name: c}
+		if i+1 < len(optstring) && optstring[i+1] == ':' {
+			flag.HasArg = true
+			i++
+		}
+		spec.Flags = append(spec.Flags, flag)
+	}
+	return spec
+}
+
+// Flag returns the option with a name, and whether it exists.
+func (s GetoptsSpec) Flag(name byte) (GetoptsFlag, bool) {
+	for _, flag := range s.Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return GetoptsFlag{}, false
+}
+
+// getopts implements the getopts builtin. Each call parses the next
+// option in the arguments, or in the positional parameters if there are
+// none, and sets the variable with the given name to it.
+func (r *Runner) getopts(args []string) int {
+	if len(args) < 2 {
+		r.errf("getopts: usage: getopts optstring name [arg ...]\n")
+		return 2
+	}
+	spec, name := ParseGetopts(args[0]), args[1]
+	args = args[2:]
+	if len(args) == 0 {
+		args = r.params
+	}
+	optind, err := strconv.Atoi(r.getVar("OPTIND"))
+	if err != nil || optind < 1 {
+		optind = 1
+	}
+	if optind != r.optIndex {
+		// OPTIND was reset, so start at the beginning of an argument
+		r.optPos = 0
+	}
+	done := func() int {
+		r.optIndex, r.optPos = optind, 0
+		r.getoptsSet("OPTIND", strconv.Itoa(optind))
+		r.getoptsSet(name, "?")
+		return 1
+	}
+	if optind > len(args) {
+		return done()
+	}
+	arg := args[optind-1]
+	if r.optPos == 0 {
+		switch {
+		case arg == "--":
+			optind++
+			return done()
+		case len(arg) < 2 || arg[0] != '-':
+			return done()
+		}
+		r.optPos = 1
+	}
+	c := arg[r.optPos]
+	r.optPos++
+	if r.optPos == len(arg) {
+		optind++
+		r.optPos = 0
+	}
+	value, optarg := string(c), ""
+	hasOptarg := false
+	flag, ok := spec.Flag(c)
+	switch {
+	case !ok && spec.Silent:
+		value, optarg, hasOptarg = "?", string(c), true
+	case !ok:
+		r.errf("getopts: illegal option -- %c\n", c)
+		value = "?"
+	case !flag.HasArg:
+	case r.optPos > 0:
+		// the argument is the rest of this one, like "-ofile"
+		optarg, hasOptarg = arg[r.optPos:], true
+		optind++
+		r.optPos = 0
+	case optind <= len(args):
+		optarg, hasOptarg = args[optind-1], true
+		optind++
+	case spec.Silent:
+		value, optarg, hasOptarg = ":", string(c), true
+	default:
+		r.errf("getopts: option requires an argument -- %c\n", c)
+		value = "?"
+	}
+	r.optIndex = optind
+	r.getoptsSet("OPTIND", strconv.Itoa(optind))
+	r.getoptsSet(name, value)
+	if hasOptarg {
+		r.getoptsSet("OPTARG", optarg)
+	} else {
+		r.delVar("OPTARG")
+	}
+	return 0
+}
+
+func (r *Runner) getoptsSet(name, value string) {
+	if err := r.setVar(name, value); err != nil {
+		r.errf("getopts: %v\n", err)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// ExecContext holds the state of the interpreter that a command is run
+// with.
+type ExecContext struct {
+	// Context is done when the command must stop, such as when the
+	// program is cancelled or the command times out.
+	Context context.Context
+
+	// Env is the environment of the command, in the form
+	// "key=value". It includes the exported variables and the
+	// assignments that preceded the command.
+	Env []string
+
+	// Dir is the working directory of the interpreter.
+	Dir string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Pos is the position of the command in the program.
+	Pos syntax.Pos
+
+	// Usage, if non-nil, is where the handler reports the resources
+	// used by the command, such as for Runner.Profile.
+	Usage *Usage
+}
+
+// Usage is the CPU time used by a command, as reported by the operating
+// system for the processes that it ran.
+type Usage struct {
+	User, System time.Duration
+}
+
+// ExecHandler runs a command that isn't a function nor a builtin.
+// args[0] is the name of the command, as written in the program.
+//
+// A nil error means that the command succeeded. To exit with a non-zero
+// status, return an ExitCode. Any other error is written to the
+// standard error, and results in an exit status of 126.
+type ExecHandler func(ctx ExecContext, args []string) error
+
+// ExecMiddleware wraps an ExecHandler, to intercept, rewrite or deny
+// some commands while passing the rest on to next.
+type ExecMiddleware func(next ExecHandler) ExecHandler
+
+// DefaultExec is the ExecHandler that runs the programs found in the
+// PATH of the interpreter. If a program can't be found, an error is
+// printed and the exit status is 127. On Windows, programs may omit the
+// extensions listed in PATHEXT, like ".exe".
+func DefaultExec(ctx ExecContext, args []string) error {
+	path := lookPath(ctx.Dir, envValue(ctx.Env, "PATH"), args[0], pathExts(ctx.Env))
+	if path == "" {
+		fmt.Fprintf(ctx.Stderr, "%s: command not found\n", args[0])
+		return ExitCode(127)
+	}
+	cmd := exec.CommandContext(ctx.Context, path)
+	cmd.Args = args
+	cmd.Env = ctx.Env
+	cmd.Dir = ctx.Dir
+	cmd.Stdin = ctx.Stdin
+	cmd.Stdout = ctx.Stdout
+	cmd.Stderr = ctx.Stderr
+	err := cmd.Run()
+	if ctx.Usage != nil && cmd.ProcessState != nil {
+		ctx.Usage.User = cmd.ProcessState.UserTime()
+		ctx.Usage.System = cmd.ProcessState.SystemTime()
+	}
+	if x, ok := err.(*exec.ExitError); ok {
+		status, ok := x.Sys().(syscall.WaitStatus)
+		switch {
+		case !ok:
+			return ExitCode(1)
+		case status.Signaled():
+			// like shells, such as when the command is killed
+			return ExitCode(128 + int(status.Signal()))
+		}
+		return ExitCode(status.ExitStatus())
+	}
+	return err
+}
+
+// execHandler returns the ExecHandler that results from wrapping the
+// configured handler with the middlewares, the first one being the
+// outermost.
+func (r *Runner) execHandler() ExecHandler {
+	h := r.Exec
+	if h == nil {
+		h = DefaultExec
+	}
+	for i := len(r.ExecMiddlewares) - 1; i >= 0; i-- {
+		h = r.ExecMiddlewares[i](h)
+	}
+	return h
+}
+
+// Register adds a command implemented in Go, which the program can call
+// like any other command, without spawning a process. Registered
+// commands take precedence over builtins and programs, but not over
+// functions declared by the program. Register must be called before Run.
+func (r *Runner) Register(name string, fn ExecHandler) {
+	if r.registered == nil {
+		r.registered = make(map[string]ExecHandler)
+	}
+	r.registered[name] = fn
+}
+
+// runHandler runs a command via an ExecHandler and sets the exit status
+// according to its result.
+func (r *Runner) runHandler(pos syntax.Pos, h ExecHandler, args []string, env []string) {
+	cmdCtx := r.ctx
+	if r.CmdTimeout > 0 {
+		var cancel context.CancelFunc
+		cmdCtx, cancel = context.WithTimeout(cmdCtx, r.CmdTimeout)
+		defer cancel()
+	}
+	ctx := ExecContext{
+		Context: cmdCtx,
+		Env:     env,
+		Dir:     r.dir,
+		Stdin:   r.stdin,
+		Stdout:  r.stdout,
+		Stderr:  r.stderr,
+		Pos:     pos,
+		Usage:   r.usage,
+	}
+	switch x := h(ctx, args).(type) {
+	case nil:
+		r.exit = 0
+	case ExitCode:
+		r.exit = int(x)
+	default:
+		r.errf("%v\n", x)
+		r.exit = 126
+	}
+}
+
+// envValue returns the value of a variable in an environment in the
+// form "key=value".
+func envValue(env []string, name string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], name+"=") {
+			return env[i][len(name)+1:]
+		}
+	}
+	return ""
+}
+
+// lookPath finds an executable like exec.LookPath, but using the given
+// PATH list and working directory. exts are the extensions that
+// executables may have, like ".exe" on Windows; if there are none,
+// executables are files with an executable permission bit.
+func lookPath(dir, list, file string, exts []string) string {
+	if strings.ContainsAny(file, `/`+string(filepath.Separator)) {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return findExecutable(path, exts)
+	}
+	for _, elem := range filepath.SplitList(list) {
+		if elem == "" {
+			elem = "."
+		}
+		path := filepath.Join(elem, file)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if path = findExecutable(path, exts); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// findExecutable returns the executable at path, which may be missing
+// one of exts, or an empty string if there is none.
+func findExecutable(path string, exts []string) string {
+	if len(exts) == 0 {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return path
+		}
+		return ""
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range exts {
+		if ext == e && isRegular(path) {
+			return path
+		}
+	}
+	for _, e := range exts {
+		if isRegular(path + e) {
+			return path + e
+		}
+	}
+	return ""
+}
+
+func isRegular(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mvdan/sh/complete"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Interactive reads commands from in and runs each of them as soon as
+// it's complete, like an interactive shell, until in ends or the
+// program exits. Like with Run, the state of the shell carries on from
+// before, and Reset is called first if it hasn't been yet. Timeout
+// doesn't apply.
+//
+// Errors only stop the command that caused them. Before each command,
+// the PS1 prompt is expanded and written to Stderr, and PS2 is used
+// while the command isn't complete, like after an unclosed quote. The
+// prompts may contain the escapes \u, \h, \w, \W, \$, \n and \\ like
+// in Bash. The commands are kept in the history, which the history
+// builtin lists.
+//
+// The error returned is like the one returned by Run, for the last
+// command that ran.
+func (r *Runner) Interactive(ctx context.Context, in io.Reader) error {
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 1) {
+		return fmt.Errorf("interp: Interactive called while the Runner is running")
+	}
+	defer atomic.StoreInt32(&r.running, 0)
+	if !r.didReset {
+		if err := r.Reset(); err != nil {
+			return err
+		}
+	}
+	r.ctx = ctx
+	r.err, r.exitShell = nil, false
+	r.interactive = true
+	defer func() { r.interactive = false }()
+	br := bufio.NewReader(in)
+	var src bytes.Buffer
+	for !r.exitShell {
+		if src.Len() == 0 {
+			r.errf("%s", r.prompt("PS1", "$ "))
+		} else {
+			r.errf("%s", r.prompt("PS2", "> "))
+		}
+		line, err := br.ReadString('\n')
+		if line == "" && err != nil {
+			if src.Len() > 0 {
+				r.errf("syntax error: unexpected end of file\n")
+				r.exit = 2
+			}
+			break
+		}
+		src.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			src.WriteByte('\n')
+		}
+		file, perr := syntax.Parse(src.Bytes(), "", 0)
+		if err == nil && (lineContinues(src.String()) || isIncomplete(perr)) {
+			continue
+		}
+		if cmd := strings.TrimSuffix(src.String(), "\n"); strings.TrimSpace(cmd) != "" {
+			r.history = append(r.history, cmd)
+		}
+		src.Reset()
+		if perr != nil {
+			r.errf("%v\n", perr)
+			r.exit = 2
+			continue
+		}
+		r.file = file
+		r.stmts(file.Stmts)
+		if _, ok := r.err.(*RunError); ok {
+			// the error only stops this command
+			r.errf("%v\n", r.err)
+			r.err, r.exit = nil, 1
+		}
+		if r.err != nil {
+			break
+		}
+		r.returning = false
+		r.breakEnclosing, r.contnEnclosing = 0, 0
+	}
+	r.bgWait.Wait()
+	if r.stop(); r.err != nil {
+		return r.err
+	}
+	if r.exit != 0 {
+		return ExitCode(r.exit)
+	}
+	return nil
+}
+
+func isIncomplete(err error) bool {
+	pe, ok := err.(*syntax.ParseError)
+	return ok && pe.Incomplete
+}
+
+// lineContinues reports whether the source ends with a backslash that
+// escapes the last newline, so that the command goes on in the next
+// line.
+func lineContinues(src string) bool {
+	src = strings.TrimSuffix(src, "\n")
+	n := len(src) - len(strings.TrimRight(src, `\`))
+	return n%2 == 1
+}
+
+// prompt returns the expansion of a prompt variable, or of def if it's
+// unset.
+func (r *Runner) prompt(name, def string) string {
+	val, ok := r.getParam(name)
+	if !ok {
+		val = def
+	}
+	val = r.promptEscapes(val)
+	if !strings.ContainsAny(val, "$`") {
+		return val
+	}
+	// expand the prompt like the body of a heredoc, which has no
+	// quotes
+	const delim = "__PROMPT__"
+	f, err := syntax.Parse([]byte(":<<"+delim+"\n"+val+"\n"+delim+"\n"), "", 0)
+	if err != nil || len(f.Stmts) != 1 || len(f.Stmts[0].Redirs) != 1 {
+		return val
+	}
+	s, err := r.expandConfig().Document(f.Stmts[0].Redirs[0].Hdoc)
+	if err != nil {
+		return val
+	}
+	return strings.TrimSuffix(s, "\n")
+}
+
+// promptEscapes decodes the backslash escapes in a prompt.
+func (r *Runner) promptEscapes(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'u':
+			buf.WriteString(r.getVar("USER"))
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if j := strings.IndexByte(host, '.'); c == 'h' && j >= 0 {
+				host = host[:j]
+			}
+			buf.WriteString(host)
+		case 'w', 'W':
+			dir := r.dir
+			home := r.getVar("HOME")
+			switch {
+			case home != "" && dir == home:
+				dir = "~"
+			case c == 'W':
+				dir = filepath.Base(dir)
+			case home != "" && strings.HasPrefix(dir, home+string(filepath.Separator)):
+				dir = "~" + dir[len(home):]
+			}
+			buf.WriteString(dir)
+		case '$':
+			if os.Geteuid() == 0 {
+				buf.WriteByte('#')
+			} else {
+				buf.WriteByte('$')
+			}
+		case 'n':
+			buf.WriteByte('\n')
+		case '\\':
+			buf.WriteByte('\\')
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// historyBuiltin implements the history builtin, which lists the
+// commands run interactively, or clears them with -c.
+func (r *Runner) historyBuiltin(args []string) int {
+	switch {
+	case len(args) == 0:
+		for i, cmd := range r.history {
+			r.outf("%5d  %s\n", i+1, cmd)
+		}
+	case len(args) == 1 && args[0] == "-c":
+		r.history = nil
+	default:
+		r.errf("history: usage: history [-c]\n")
+		return 2
+	}
+	return 0
+}
+
+// History returns the commands run interactively via Interactive, oldest
+// first.
+func (r *Runner) History() []string {
+	return append([]string(nil), r.history...)
+}
+
+// Complete implements complete.Provider with the state of the shell,
+// listing its variables, and its functions and builtins as commands.
+// It's meant to be used while Interactive waits for the next line, such
+// as by the line editor that in reads from.
+func (r *Runner) Complete(ctx *complete.Context) []complete.Candidate {
+	var cands []complete.Candidate
+	switch ctx.Kind {
+	case complete.Variable:
+		for name, vr := range r.allVars() {
+			if !vr.Set {
+				continue
+			}
+			cands = append(cands, complete.Candidate{Text: name, Desc: "variable"})
+		}
+	case complete.Command:
+		for name := range r.funcs {
+			cands = append(cands, complete.Candidate{Text: name, Desc: "function"})
+		}
+		for _, name := range builtinNames {
+			cands = append(cands, complete.Candidate{Text: name, Desc: "builtin"})
+		}
+	}
+	return cands
+}
@@ -0,0 +1,1312 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package interp implements an interpreter that executes shell
+// programs. It aims to support POSIX shell and the most common Bash
+// features, without needing a shell binary to be installed.
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// A Runner interprets shell programs. Run can be called many times,
+// such as with one statement at a time, and the state of the shell
+// like its variables and functions carries on from one call to the
+// next. To run another program from the state given by the exported
+// fields, call Reset first, which reuses the memory of the variables
+// and functions.
+//
+// A Runner isn't safe for concurrent use: its fields must not be
+// modified, and Run nor Reset called, while it's running. Once Run
+// returns, all the goroutines it started have finished. To run many
+// programs at once, use a Runner for each.
+//
+// The common builtins like cd, echo, printf, read and test are
+// implemented in Go, so that simple programs don't need a shell nor
+// coreutils to be installed, like on Windows. Use Register to replace
+// any of them.
+//
+// Subshells, command substitutions and the commands in a pipeline get
+// their own copy of the variables, functions and working directory.
+// The commands in a pipeline and background commands run concurrently,
+// each in its own goroutine.
+//
+// Note that writes to Stdout and Stderr may not be sequential. If you
+// plan on using an io.Writer implementation that isn't safe for
+// concurrent use, consider a workaround like hiding writes behind a
+// mutex.
+type Runner struct {
+	// Env specifies the initial variables of the interpreter. It is
+	// only read by Reset, as the interpreter keeps its own copy. If
+	// Env is nil, expand.OSEnviron is used.
+	Env expand.Environ
+
+	// Dir specifies the working directory of the program. If Dir is
+	// empty, the current process's working directory is used.
+	Dir string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// FS is used to open files for redirects, expand filename
+	// patterns, evaluate file tests and change directories. If nil,
+	// OSFS is used.
+	FS FS
+
+	// Timeout, if non-zero, is the maximum time that each call to
+	// Run may take.
+	Timeout time.Duration
+
+	// CmdTimeout, if non-zero, is the maximum time that each command
+	// that isn't a function nor a builtin may run for. Commands that
+	// time out are killed, but the program continues.
+	CmdTimeout time.Duration
+
+	// Options are the initial shell options, which can be changed by
+	// the program via set.
+	Options Options
+
+	// Params are the initial positional parameters, like $1. They
+	// can be changed by the program via set and shift.
+	Params []string
+
+	// Exec runs the commands that aren't functions nor builtins. If
+	// nil, DefaultExec is used.
+	Exec ExecHandler
+
+	// ExecMiddlewares wrap Exec, the first one being the outermost.
+	ExecMiddlewares []ExecMiddleware
+
+	// DryRun, if non-nil, makes the runner write the commands that
+	// it would run to DryRun instead of running them, one per line.
+	// Only builtins and functions are run. Files aren't opened for
+	// redirects either.
+	//
+	// As the exit status and output of the commands aren't known,
+	// they are assumed to succeed with no output. Comments are
+	// written where the control flow depends on that assumption.
+	DryRun io.Writer
+
+	// Trace, if non-nil, is called as the program runs with structured
+	// events about the commands, expansions and redirects, such as for
+	// progress reports or audit logs.
+	Trace TraceFunc
+
+	// Debugger, if non-nil, can pause the program before statements,
+	// such as at breakpoints or to single-step.
+	Debugger *Debugger
+
+	// Coverage, if non-nil, records which statements of the files
+	// given to Run are run and how many times.
+	Coverage *Coverage
+
+	// Profile, if non-nil, adds up the time spent by each command of
+	// the program, by its position.
+	Profile *Profile
+
+	// ctx is the context that the program runs with, including the
+	// timeout
+	ctx context.Context
+
+	// file is the file being run, which positions refer to
+	file *syntax.File
+
+	// running is non-zero while Run is running, to catch concurrent
+	// uses of the runner
+	running int32
+
+	// didReset is set once Reset has set up the state of the shell
+	didReset bool
+
+	// interactive is set while Interactive runs commands, but not in
+	// its subshells
+	interactive bool
+
+	// dir is the working directory, which changes with cd
+	dir string
+
+	// the streams that commands use, which change with redirects
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	vars map[string]expand.Variable
+
+	// locals holds the local variables of the function calls in the
+	// stack, innermost last
+	locals []map[string]expand.Variable
+
+	funcs map[string]*syntax.Stmt
+
+	// varsShared and funcsShared are set when vars and locals, or
+	// funcs, are shared with other runners. Shared maps must not be
+	// modified, so they are copied first.
+	varsShared, funcsShared bool
+
+	// registered holds the commands added via Register
+	registered map[string]ExecHandler
+
+	// params are the positional parameters, like $1
+	params []string
+
+	opts Options
+
+	// noErrExit is non-zero when errexit doesn't apply, such as in
+	// the condition of an if clause
+	noErrExit int
+
+	// bgWait waits for background commands, and is shared by all the
+	// runners created for subshells
+	bgWait *sync.WaitGroup
+
+	// bgJobs waits for the background commands started by this shell
+	// rather than by its subshells, which is what wait waits for
+	bgJobs *sync.WaitGroup
+
+	// keepRedirs is set by exec without a command, so that the
+	// redirects of its statement apply to the rest of the shell.
+	// kept are the files opened by those redirects, which are closed
+	// once the shell ends.
+	keepRedirs bool
+	kept       []io.Closer
+
+	// usage is where the resources used by the command being run are
+	// reported, if it's being profiled
+	usage *Usage
+
+	// procSubsts are the process substitutions started by the
+	// statements being run, outermost first
+	procSubsts []*procSubst
+
+	// err is a fatal error that stops the interpreter
+	err error
+
+	// exit is the status code of the last command
+	exit int
+
+	// exitShell is set once the shell must stop, like with exit
+	exitShell bool
+
+	// returning is set when returning from a function or a sourced
+	// script
+	returning bool
+
+	// sourcing is the number of scripts being run by source
+	sourcing int
+
+	// unknown is set when the exit status of the last command is
+	// unknown, as it wasn't run because of DryRun
+	unknown bool
+
+	// funcStack holds the names of the functions being called,
+	// innermost last
+	funcStack []string
+
+	// optIndex is the last OPTIND set by getopts, and optPos the
+	// position of the next option within that argument, like in
+	// "-abc"
+	optIndex, optPos int
+	// history holds the commands run by Interactive, oldest first
+	history []string
+
+	// inLoop is the number of loops the runner is in
+	inLoop int
+
+	breakEnclosing, contnEnclosing int
+}
+
+// Options are the shell options, most of which can be enabled with set.
+type Options struct {
+	// ErrExit makes the shell exit when a command fails, like set -e.
+	// As in other shells, it does not apply to the commands whose
+	// exit status is checked, like conditions and all but the last
+	// command in && and || lists.
+	ErrExit bool
+
+	// NoUnset makes expanding an unset parameter an error, like
+	// set -u.
+	NoUnset bool
+
+	// PipeFail makes the exit status of a pipeline the one of the
+	// last command to fail, like set -o pipefail.
+	PipeFail bool
+
+	// XPGEcho makes echo decode backslash escapes without -e, like
+	// POSIX shells do and like Bash does with shopt -s xpg_echo.
+	XPGEcho bool
+
+	// XTrace writes each command to the standard error before running
+	// it, like set -x.
+	XTrace bool
+}
+
+// ExitCode is returned by Run when the program ends with a non-zero
+// exit status.
+type ExitCode uint8
+
+func (e ExitCode) Error() string { return fmt.Sprintf("exit status %d", e) }
+
+// RunError is returned by Run when the interpreter cannot continue,
+// such as when a parameter expansion is invalid.
+type RunError struct {
+	syntax.Position
+	Filename, Text string
+}
+
+func (e *RunError) Error() string {
+	prefix := ""
+	if e.Filename != "" {
+		prefix = e.Filename + ":"
+	}
+	return fmt.Sprintf("%s%d:%d: %s", prefix, e.Line, e.Column, e.Text)
+}
+
+func (r *Runner) runErr(pos syntax.Pos, format string, a ...interface{}) {
+	if r.err == nil {
+		r.err = &RunError{
+			Position: r.file.Position(pos),
+			Filename: r.file.Name,
+			Text:     fmt.Sprintf(format, a...),
+		}
+	}
+}
+
+func (r *Runner) outf(format string, a ...interface{}) {
+	fmt.Fprintf(r.stdout, format, a...)
+}
+
+// errf writes an error message to the standard error, like a shell
+// does when a command fails.
+func (r *Runner) errf(format string, a ...interface{}) {
+	fmt.Fprintf(r.stderr, format, a...)
+}
+
+// Run interprets a node, which can be a *syntax.File, a *syntax.Stmt, a
+// syntax.Command or a *syntax.Word. A word is run like a simple command
+// with its fields as the arguments. Positions in errors refer to the
+// last file run, so it's best to run the statements of a file after
+// the file itself, or one at a time via a file.
+//
+// The state of the shell carries on from the previous call to Run,
+// such as to feed a program one statement at a time. If Reset hasn't
+// been called yet, Run calls it first. The files kept open by exec
+// redirects stay open until Reset.
+//
+// Run returns an ExitCode error if the node ends with a non-zero exit
+// status, or a RunError if it cannot continue. If the context is done
+// or the timeout is reached, the context's error is returned.
+func (r *Runner) Run(ctx context.Context, node syntax.Node) error {
+	if !atomic.CompareAndSwapInt32(&r.running, 0, 1) {
+		return fmt.Errorf("interp: Run called while the Runner is running")
+	}
+	defer atomic.StoreInt32(&r.running, 0)
+	if !r.didReset {
+		if err := r.Reset(); err != nil {
+			return err
+		}
+	}
+	r.ctx = ctx
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		r.ctx, cancel = context.WithTimeout(r.ctx, r.Timeout)
+		defer cancel()
+	}
+	r.err, r.exitShell, r.returning = nil, false, false
+	r.breakEnclosing, r.contnEnclosing = 0, 0
+	switch x := node.(type) {
+	case *syntax.File:
+		r.file = x
+		if r.Coverage != nil {
+			r.Coverage.addFile(x)
+		}
+		r.stmts(x.Stmts)
+	case *syntax.Stmt:
+		r.stmt(x)
+	case syntax.Command:
+		r.stmt(&syntax.Stmt{Cmd: x})
+	case *syntax.Word:
+		r.stmt(&syntax.Stmt{Cmd: &syntax.CallExpr{Args: []*syntax.Word{x}}})
+	default:
+		return fmt.Errorf("interp: Run called with an unsupported node type: %T", node)
+	}
+	r.bgWait.Wait()
+	if r.stop(); r.err != nil {
+		return r.err
+	}
+	if r.exit != 0 {
+		return ExitCode(r.exit)
+	}
+	return nil
+}
+
+// noFile is the file that positions refer to before any file is run.
+var noFile = &syntax.File{}
+
+// Exited reports whether the last call to Run ended the shell, like
+// via exit, so that an interactive shell should stop.
+func (r *Runner) Exited() bool {
+	return r.exitShell
+}
+
+// Reset discards the state left by the last program, like its
+// variables, functions and options, and sets up the initial state
+// given by the exported fields. It also closes the files kept open by
+// exec redirects.
+func (r *Runner) Reset() error {
+	r.closeKept()
+	r.ctx, r.file = context.Background(), noFile
+	r.didReset = true
+	r.stdin, r.stdout, r.stderr = r.Stdin, r.Stdout, r.Stderr
+	if r.stdout == nil {
+		r.stdout = ioutil.Discard
+	}
+	if r.stderr == nil {
+		r.stderr = ioutil.Discard
+	}
+	r.params = append(r.params[:0], r.Params...)
+	r.opts = r.Options
+	// the previous program is done, so its maps aren't used by any
+	// subshell anymore and can be cleared
+	if r.vars == nil {
+		r.vars = make(map[string]expand.Variable)
+		r.funcs = make(map[string]*syntax.Stmt)
+		r.bgWait = new(sync.WaitGroup)
+		r.bgJobs = new(sync.WaitGroup)
+	}
+	for name := range r.vars {
+		delete(r.vars, name)
+	}
+	for name := range r.funcs {
+		delete(r.funcs, name)
+	}
+	r.varsShared, r.funcsShared = false, false
+	r.locals, r.funcStack = r.locals[:0], r.funcStack[:0]
+	r.noErrExit, r.inLoop, r.sourcing = 0, 0, 0
+	r.breakEnclosing, r.contnEnclosing = 0, 0
+	r.keepRedirs, r.kept, r.procSubsts = false, nil, nil
+	r.usage = nil
+	r.err, r.exit = nil, 0
+	r.exitShell, r.returning, r.unknown = false, false, false
+	r.optIndex, r.optPos = 0, 0
+	r.history = r.history[:0]
+	env := r.Env
+	if env == nil {
+		env = expand.OSEnviron
+	}
+	env.Each(func(name string, vr expand.Variable) bool {
+		r.vars[envName(name)] = vr
+		return true
+	})
+	r.dir = r.Dir
+	if r.dir == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("could not get current dir: %v", err)
+		}
+		r.dir = dir
+	}
+	return r.setVar("PWD", r.dir)
+}
+
+// Vars returns a snapshot of the variables of the shell. After Run,
+// they are the ones left by the program, and after Reset, the ones
+// that it starts with. The changes made by a program can then be found
+// with expand.Diff:
+//
+//	r.Reset()
+//	before := r.Vars()
+//	err := r.Run(ctx, file)
+//	changes := expand.Diff(before, r.Vars())
+func (r *Runner) Vars() expand.MapEnviron {
+	return expand.Snapshot(expandEnv{r})
+}
+
+// sub returns a runner for a subshell, which has a copy of the state
+// of the shell so that its changes don't affect the parent.
+//
+// The variables and functions aren't copied right away. Instead, both
+// runners share them until either modifies them, which is often never.
+func (r *Runner) sub() *Runner {
+	r.varsShared, r.funcsShared = true, true
+	r2 := *r
+	r2.procSubsts, r2.kept = nil, nil
+	r2.interactive = false
+	r2.bgJobs = new(sync.WaitGroup)
+	// a function call in either runner must not overwrite the other's
+	// stack
+	r2.funcStack = r.funcStack[:len(r.funcStack):len(r.funcStack)]
+	return &r2
+}
+
+// subExit runs a function in a subshell and returns its exit status.
+func (r *Runner) subExit(fn func(r2 *Runner)) int {
+	r2 := r.sub()
+	fn(r2)
+	r2.closeKept()
+	if r2.err != nil && r.err == nil {
+		r.err = r2.err
+	}
+	r.unknown = r.unknown || r2.unknown
+	return r2.exit
+}
+
+// stop reports whether the statements being run should stop, such as
+// after an exit or a break.
+func (r *Runner) stop() bool {
+	if r.err == nil {
+		r.err = r.ctx.Err()
+	}
+	return r.err != nil || r.exitShell || r.returning ||
+		r.breakEnclosing > 0 || r.contnEnclosing > 0
+}
+
+func (r *Runner) stmts(stmts []*syntax.Stmt) {
+	for _, s := range stmts {
+		if r.stop() {
+			return
+		}
+		r.stmt(s)
+	}
+}
+
+func (r *Runner) stmt(s *syntax.Stmt) {
+	if s.Background {
+		r2 := r.sub()
+		r.bgWait.Add(1)
+		r.bgJobs.Add(1)
+		go func() {
+			r2.stmtSync(s)
+			r2.closeKept()
+			r.bgJobs.Done()
+			r.bgWait.Done()
+		}()
+		r.exit = 0
+		return
+	}
+	r.stmtSync(s)
+}
+
+func (r *Runner) stmtSync(s *syntax.Stmt) {
+	if r.Coverage != nil {
+		r.Coverage.hit(r.file, s)
+	}
+	if r.Debugger != nil {
+		r.Debugger.beforeStmt(r, s)
+	}
+	r.unknown = false
+	numProcSubsts := len(r.procSubsts)
+	oldIn, oldOut, oldErr := r.stdin, r.stdout, r.stderr
+	var closers []io.Closer
+	failed := false
+	for _, rd := range s.Redirs {
+		cls, err := r.redir(rd)
+		if err != nil {
+			r.errf("%v\n", err)
+			failed = true
+			break
+		}
+		if cls != nil {
+			closers = append(closers, cls)
+		}
+	}
+	switch {
+	case failed:
+		// the command isn't run
+		r.exit = 1
+	case s.Cmd == nil:
+		r.onlyAssigns(s.Assigns, nil)
+		r.errExit()
+	case s.Negated:
+		r.noErrExit++
+		r.cmd(s.Cmd, s.Assigns)
+		r.noErrExit--
+	default:
+		r.cmd(s.Cmd, s.Assigns)
+	}
+	if r.keepRedirs {
+		// the process substitutions in the redirects, like in
+		// "exec > >(tee log)", must also outlive the statement
+		r.keepRedirs = false
+		r.kept = append(r.kept, closers...)
+		for _, p := range r.procSubsts[numProcSubsts:] {
+			r.kept = append(r.kept, p)
+		}
+		r.procSubsts = r.procSubsts[:numProcSubsts]
+	} else if len(s.Redirs) > 0 {
+		for _, cls := range closers {
+			cls.Close()
+		}
+		r.stdin, r.stdout, r.stderr = oldIn, oldOut, oldErr
+	}
+	r.endProcSubsts(numProcSubsts)
+	if s.Negated {
+		r.exit = oneIf(r.exit == 0)
+	}
+}
+
+// errExit makes the shell exit if errexit is enabled, it applies and
+// the last command failed. It is only called for commands that aren't
+// made of other statements, like executions or subshells, so that the
+// exit status of a list isn't checked once for each of its levels.
+func (r *Runner) errExit() {
+	if r.opts.ErrExit && r.exit != 0 && r.noErrExit == 0 {
+		r.exitShell = true
+	}
+}
+
+// noErrExitStmts runs a list of statements whose exit status is checked,
+// such as a condition, where errexit doesn't apply.
+func (r *Runner) noErrExitStmts(stmts []*syntax.Stmt) {
+	r.noErrExit++
+	r.stmts(stmts)
+	r.noErrExit--
+}
+
+func oneIf(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *Runner) cmd(cm syntax.Command, assigns []*syntax.Assign) {
+	if r.stop() {
+		return
+	}
+	switch x := cm.(type) {
+	case *syntax.Block:
+		r.stmts(x.Stmts)
+	case *syntax.Subshell:
+		r.exit = r.subExit(func(r2 *Runner) { r2.stmts(x.Stmts) })
+		r.errExit()
+	case *syntax.CallExpr:
+		args := x.Args
+		for len(args) > 0 {
+			if _, _, _, _, ok := indexAssign(args[0]); !ok {
+				break
+			}
+			args = args[1:]
+		}
+		if len(args) == 0 && len(x.Args) > 0 {
+			// only assignments like "m[$k]=v"
+			r.onlyAssigns(assigns, x.Args)
+			return
+		}
+		for _, w := range x.Args[:len(x.Args)-len(args)] {
+			r.assignIndex(w)
+		}
+		fields := r.fields(args)
+		if r.stop() {
+			return
+		}
+		if len(fields) == 0 {
+			r.onlyAssigns(assigns, nil)
+			return
+		}
+		pos := args[0].Pos()
+		if !r.tracing() && r.Profile == nil {
+			r.call(pos, fields, assigns)
+			r.errExit()
+			break
+		}
+		r.trace(TraceEvent{Kind: TraceCmdStart, Pos: pos, Args: fields})
+		var usage Usage
+		oldUsage := r.usage
+		if r.Profile != nil {
+			r.usage = &usage
+		}
+		start := time.Now()
+		r.call(pos, fields, assigns)
+		elapsed := time.Since(start)
+		r.usage = oldUsage
+		r.trace(TraceEvent{
+			Kind:    TraceCmdEnd,
+			Pos:     pos,
+			Args:    fields,
+			Exit:    r.exit,
+			Elapsed: elapsed,
+		})
+		if r.Profile != nil {
+			r.Profile.add(r.file, pos, fields[0], elapsed, usage)
+		}
+		r.errExit()
+	case *syntax.BinaryCmd:
+		switch x.Op {
+		case syntax.AndStmt:
+			r.noErrExit++
+			r.stmt(x.X)
+			r.noErrExit--
+			r.dryCond(x.OpPos, "&&")
+			if r.exit == 0 {
+				r.stmt(x.Y)
+			}
+		case syntax.OrStmt:
+			r.noErrExit++
+			r.stmt(x.X)
+			r.noErrExit--
+			r.dryCond(x.OpPos, "||")
+			if r.exit != 0 {
+				r.stmt(x.Y)
+			}
+		case syntax.Pipe, syntax.PipeAll:
+			r.pipe(x)
+			r.errExit()
+		}
+	case *syntax.IfClause:
+		r.noErrExitStmts(x.CondStmts)
+		if r.stop() {
+			return
+		}
+		r.dryCond(x.If, "if")
+		if r.exit == 0 {
+			r.stmts(x.ThenStmts)
+			return
+		}
+		for _, el := range x.Elifs {
+			r.noErrExitStmts(el.CondStmts)
+			if r.stop() {
+				return
+			}
+			r.dryCond(el.Elif, "elif")
+			if r.exit == 0 {
+				r.stmts(el.ThenStmts)
+				return
+			}
+		}
+		r.exit = 0
+		r.stmts(x.ElseStmts)
+	case *syntax.WhileClause:
+		r.loop(x.While, x.CondStmts, x.DoStmts, false)
+	case *syntax.UntilClause:
+		r.loop(x.Until, x.CondStmts, x.DoStmts, true)
+	case *syntax.ForClause:
+		r.forClause(x)
+	case *syntax.CaseClause:
+		r.caseClause(x)
+	case *syntax.FuncDecl:
+		r.ownFuncs()
+		r.funcs[x.Name.Value] = x.Body
+		r.exit = 0
+	case *syntax.ArithmCmd:
+		r.exit = oneIf(r.arithm(x.X) == 0)
+		r.errExit()
+	case *syntax.LetClause:
+		var n int
+		for _, expr := range x.Exprs {
+			n = r.arithm(expr)
+		}
+		r.exit = oneIf(n == 0)
+		r.errExit()
+	case *syntax.TestClause:
+		r.testClause(x)
+		r.errExit()
+	case *syntax.DeclClause:
+		r.declClause(x)
+		r.errExit()
+	case *syntax.EvalClause:
+		r.evalClause(x)
+		r.errExit()
+	default:
+		r.runErr(cm.Pos(), "unsupported command node: %T", cm)
+	}
+}
+
+func (r *Runner) pipe(x *syntax.BinaryCmd) {
+	if r.DryRun != nil {
+		// run both sides in order, so that the output is stable
+		exit := r.subExit(func(r2 *Runner) {
+			r2.stdout = ioutil.Discard
+			r2.stmt(x.X)
+		})
+		r.exit = r.subExit(func(r2 *Runner) {
+			r2.stdin = nil
+			r2.stmt(x.Y)
+		})
+		if r.opts.PipeFail && r.exit == 0 {
+			r.exit = exit
+		}
+		return
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		r.runErr(x.OpPos, "could not create pipe: %v", err)
+		return
+	}
+	r2 := r.sub()
+	r2.stdout = pw
+	if x.Op == syntax.PipeAll {
+		r2.stderr = pw
+	}
+	done := make(chan struct{})
+	go func() {
+		r2.stmt(x.X)
+		r2.closeKept()
+		pw.Close()
+		close(done)
+	}()
+	r.exit = r.subExit(func(r3 *Runner) {
+		r3.stdin = pr
+		r3.stmt(x.Y)
+	})
+	pr.Close()
+	<-done
+	if r2.err != nil && r.err == nil {
+		r.err = r2.err
+	}
+	if r.opts.PipeFail && r.exit == 0 {
+		r.exit = r2.exit
+	}
+}
+
+// loopStmtsBroken runs the body of a loop, and reports whether the loop
+// must stop because of a break or a continue of an outer loop.
+func (r *Runner) loopStmtsBroken(stmts []*syntax.Stmt) bool {
+	r.inLoop++
+	defer func() { r.inLoop-- }()
+	r.stmts(stmts)
+	if r.contnEnclosing > 0 {
+		r.contnEnclosing--
+		return r.contnEnclosing > 0
+	}
+	if r.breakEnclosing > 0 {
+		r.breakEnclosing--
+		return true
+	}
+	return r.stop()
+}
+
+func (r *Runner) loop(pos syntax.Pos, cond, body []*syntax.Stmt, until bool) {
+	exit := 0
+	for i := 0; ; i++ {
+		r.noErrExitStmts(cond)
+		if r.stop() {
+			break
+		}
+		if r.unknown && i > 0 {
+			// assuming success forever would never stop
+			r.dryf(pos, "loop condition depends on an unknown exit status, stopping")
+			break
+		}
+		r.dryCond(pos, "loop")
+		if (r.exit == 0) == until {
+			break
+		}
+		broken := r.loopStmtsBroken(body)
+		exit = r.exit
+		if broken {
+			break
+		}
+	}
+	r.exit = exit
+}
+
+func (r *Runner) forClause(x *syntax.ForClause) {
+	switch y := x.Loop.(type) {
+	case *syntax.WordIter:
+		name := y.Name.Value
+		var items []string
+		if y.List == nil {
+			// "for i; do" iterates over the parameters
+			items = r.params
+		} else {
+			items = r.fields(y.List)
+			r.dryOutput(x.For, "for")
+		}
+		r.exit = 0
+		for _, item := range items {
+			if r.stop() {
+				break
+			}
+			if err := r.setVar(name, item); err != nil {
+				r.errf("%v\n", err)
+				r.exit = 1
+				break
+			}
+			if r.loopStmtsBroken(x.DoStmts) {
+				break
+			}
+		}
+	case *syntax.CStyleLoop:
+		r.exit = 0
+		if y.Init != nil {
+			r.arithm(y.Init)
+		}
+		for y.Cond == nil || r.arithm(y.Cond) != 0 {
+			if r.stop() || r.loopStmtsBroken(x.DoStmts) {
+				break
+			}
+			if y.Post != nil {
+				r.arithm(y.Post)
+			}
+		}
+	}
+}
+
+func (r *Runner) caseClause(x *syntax.CaseClause) {
+	str := r.loneWord(x.Word)
+	r.dryOutput(x.Case, "case")
+	r.exit = 0
+	fallthru := false
+	for _, pl := range x.List {
+		matched := fallthru
+		for _, pat := range pl.Patterns {
+			if matched {
+				break
+			}
+			matched = r.match(pat, str)
+		}
+		if !matched {
+			continue
+		}
+		r.stmts(pl.Stmts)
+		switch pl.Op {
+		case syntax.SemiFall:
+			fallthru = true
+		case syntax.DblSemiFall:
+			fallthru = false
+		default:
+			return
+		}
+	}
+}
+
+func (r *Runner) evalClause(x *syntax.EvalClause) {
+	if x.Stmt == nil {
+		r.exit = 0
+		return
+	}
+	ce, ok := x.Stmt.Cmd.(*syntax.CallExpr)
+	if !ok || len(x.Stmt.Assigns) > 0 || len(x.Stmt.Redirs) > 0 {
+		r.stmt(x.Stmt)
+		return
+	}
+	src := strings.Join(r.fields(ce.Args), " ")
+	r.evalSrc(ce.Pos(), src)
+}
+
+// evalSrc parses and runs a piece of shell source, like eval does.
+func (r *Runner) evalSrc(pos syntax.Pos, src string) {
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		r.errf("eval: %v\n", err)
+		r.exit = 1
+		return
+	}
+	// positions in errors refer to the original file
+	file := r.file
+	r.file = f
+	r.exit = 0
+	r.stmts(f.Stmts)
+	r.file = file
+}
+
+// source runs a script in the current shell, like the source builtin
+// does. Its return value is the exit status.
+func (r *Runner) source(name string, args []string) int {
+	path := args[0]
+	f, err := r.fs().OpenFile(r.relPath(path), os.O_RDONLY, 0)
+	if err != nil {
+		r.errf("%s: %v\n", name, err)
+		return 1
+	}
+	src, err := ioutil.ReadAll(f)
+	f.Close()
+	var prog *syntax.File
+	if err == nil {
+		prog, err = syntax.Parse(src, path, 0)
+	}
+	if err != nil {
+		r.errf("%s: %v\n", name, err)
+		return 1
+	}
+	if r.Coverage != nil {
+		r.Coverage.addFile(prog)
+	}
+	oldParams := r.params
+	if len(args) > 1 {
+		r.params = args[1:]
+	}
+	file := r.file
+	r.file = prog
+	r.sourcing++
+	r.exit = 0
+	r.stmts(prog.Stmts)
+	r.sourcing--
+	r.file = file
+	r.returning = false
+	if len(args) > 1 {
+		r.params = oldParams
+	}
+	return r.exit
+}
+
+func (r *Runner) call(pos syntax.Pos, fields []string, assigns []*syntax.Assign) {
+	name := fields[0]
+	if body := r.funcs[name]; body != nil {
+		r.callFunc(name, body, fields[1:], assigns)
+		return
+	}
+	if r.DryRun != nil && !isBuiltin(name) {
+		r.dryCmd(fields, assigns)
+		return
+	}
+	if fn := r.registered[name]; fn != nil {
+		r.runHandler(pos, fn, fields, r.cmdEnv(assigns))
+		return
+	}
+	if isBuiltin(name) {
+		restore := r.tempAssigns(assigns)
+		r.exit = r.builtin(pos, name, fields[1:])
+		restore()
+		return
+	}
+	r.runHandler(pos, r.execHandler(), fields, r.cmdEnv(assigns))
+}
+
+// exec runs a command that isn't a function nor a builtin, like the
+// exec builtin does.
+func (r *Runner) exec(pos syntax.Pos, args []string) {
+	if r.DryRun != nil {
+		r.dryCmd(args, nil)
+		return
+	}
+	h := r.execHandler()
+	if fn := r.registered[args[0]]; fn != nil {
+		h = fn
+	}
+	r.runHandler(pos, h, args, r.environ())
+}
+
+// closeKept closes the files that exec kept open, waiting for the
+// process substitutions among them.
+func (r *Runner) closeKept() {
+	for _, cls := range r.kept {
+		cls.Close()
+	}
+	r.kept = nil
+}
+
+// cmdEnv returns the environment of a command, including the
+// assignments that precede it.
+func (r *Runner) cmdEnv(assigns []*syntax.Assign) []string {
+	env := r.environ()
+	for _, as := range assigns {
+		env = append(env, as.Name.Value+"="+r.assignValue(as))
+	}
+	return env
+}
+
+func (r *Runner) callFunc(name string, body *syntax.Stmt, args []string, assigns []*syntax.Assign) {
+	oldParams := r.params
+	r.params = args
+	r.ownVars()
+	r.locals = append(r.locals, make(map[string]expand.Variable))
+	for _, as := range assigns {
+		r.assign(as, true)
+	}
+	r.funcStack = append(r.funcStack, name)
+	r.stmt(body)
+	r.funcStack = r.funcStack[:len(r.funcStack)-1]
+	r.returning = false
+	r.locals = r.locals[:len(r.locals)-1]
+	r.params = oldParams
+}
+
+// tempAssigns applies the assignments made before a builtin, and
+// returns a function that undoes them.
+func (r *Runner) tempAssigns(assigns []*syntax.Assign) func() {
+	if len(assigns) == 0 {
+		return func() {}
+	}
+	type saved struct {
+		vr    expand.Variable
+		found bool
+	}
+	old := make(map[string]saved, len(assigns))
+	for _, as := range assigns {
+		name, _, _ := splitIndex(as.Name.Value)
+		if _, ok := old[name]; !ok {
+			vr, found := r.lookupVar(name)
+			old[name] = saved{vr, found}
+		}
+		r.assign(as, false)
+	}
+	return func() {
+		for name, s := range old {
+			if s.found {
+				r.setVarFull(name, s.vr)
+			} else {
+				r.delVar(name)
+			}
+		}
+	}
+}
+
+// relPath makes a path relative to the working directory of the
+// interpreter absolute.
+func (r *Runner) relPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	if path != "" && os.IsPathSeparator(path[0]) {
+		// on Windows, a path like "/foo" is on the drive of the
+		// working directory
+		return filepath.Join(filepath.VolumeName(r.dir), path)
+	}
+	return filepath.Join(r.dir, path)
+}
+
+func (r *Runner) redir(rd *syntax.Redirect) (io.Closer, error) {
+	arg := r.loneWord(rd.Word)
+	cls, err := r.applyRedir(rd, arg)
+	if r.tracing() {
+		r.trace(TraceEvent{
+			Kind:     TraceRedirect,
+			Pos:      rd.Pos(),
+			Args:     []string{arg},
+			Redirect: rd,
+			Err:      err,
+		})
+	}
+	return cls, err
+}
+
+// applyRedir applies a redirect whose word expanded to arg.
+func (r *Runner) applyRedir(rd *syntax.Redirect, arg string) (io.Closer, error) {
+	n := -1
+	if rd.N != nil {
+		var err error
+		if n, err = parseFd(rd.N.Value); err != nil {
+			return nil, err
+		}
+	}
+	switch rd.Op {
+	case syntax.DplIn, syntax.DplOut:
+		if n < 0 {
+			n = 0
+			if rd.Op == syntax.DplOut {
+				n = 1
+			}
+		}
+		if arg == "-" {
+			r.setFd(n, nil)
+			return nil, nil
+		}
+		if rd.Op == syntax.DplOut {
+			if m, err := parseFd(arg); err == nil {
+				return nil, r.dupFd(n, m)
+			}
+			if rd.N == nil {
+				// ">&file" is like "&>file"
+				return r.openFd(rd.OpPos, bothFds, arg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+			}
+		}
+		m, err := parseFd(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: ambiguous redirect", arg)
+		}
+		return nil, r.dupFd(n, m)
+	case syntax.RdrOut, syntax.ClbOut:
+		if n < 0 {
+			n = 1
+		}
+		return r.openFd(rd.OpPos, n, arg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	case syntax.AppOut:
+		if n < 0 {
+			n = 1
+		}
+		return r.openFd(rd.OpPos, n, arg, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	case syntax.RdrAll:
+		return r.openFd(rd.OpPos, bothFds, arg, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	case syntax.AppAll:
+		return r.openFd(rd.OpPos, bothFds, arg, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	case syntax.RdrIn:
+		if n < 0 {
+			n = 0
+		}
+		return r.openFd(rd.OpPos, n, arg, os.O_RDONLY)
+	case syntax.RdrInOut:
+		if n < 0 {
+			n = 0
+		}
+		return r.openFd(rd.OpPos, n, arg, os.O_RDWR|os.O_CREATE)
+	case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+		if n > 0 {
+			return nil, fmt.Errorf("unsupported redirect: %d%s", n, rd.Op)
+		}
+		if rd.Op == syntax.WordHdoc {
+			r.stdin = strings.NewReader(arg + "\n")
+		} else {
+			r.stdin = strings.NewReader(r.hdocBody(rd))
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported redirect: %s", rd.Op)
+}
+
+// hdocBody returns the body of a heredoc, which is expanded unless its
+// delimiter is quoted.
+func (r *Runner) hdocBody(rd *syntax.Redirect) string {
+	if rd.Hdoc == nil {
+		return ""
+	}
+	w := rd.Hdoc
+	if r.file.Mode&syntax.LazyHeredocs != 0 {
+		// the body's expansions weren't parsed yet
+		var err error
+		if w, err = syntax.ParseHeredoc(r.file, rd); err != nil {
+			r.runErr(rd.Hdoc.Pos(), "%v", err)
+			return ""
+		}
+	}
+	if rd.Op == syntax.DashHdoc {
+		w = stripTabs(w)
+	}
+	if !isLit(rd.Word) {
+		// the body of a heredoc with a quoted delimiter is a literal
+		var buf bytes.Buffer
+		for _, wp := range w.Parts {
+			if lit, ok := wp.(*syntax.Lit); ok {
+				buf.WriteString(lit.Value)
+			}
+		}
+		return buf.String()
+	}
+	s, err := r.expandConfig().Document(w)
+	if err != nil {
+		r.expandErr(w.Pos(), err)
+	}
+	return s
+}
+
+// isLit reports whether a word is a literal without quotes or escape
+// characters.
+func isLit(w *syntax.Word) bool {
+	for _, wp := range w.Parts {
+		lit, ok := wp.(*syntax.Lit)
+		if !ok || strings.IndexByte(lit.Value, '\\') >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// stripTabs returns a copy of the body of a <<- heredoc without the tabs
+// at the start of each line.
+func stripTabs(w *syntax.Word) *syntax.Word {
+	w2 := &syntax.Word{Parts: make([]syntax.WordPart, len(w.Parts))}
+	lineStart := true
+	for i, wp := range w.Parts {
+		lit, ok := wp.(*syntax.Lit)
+		if !ok {
+			w2.Parts[i] = wp
+			lineStart = false
+			continue
+		}
+		var buf bytes.Buffer
+		for j := 0; j < len(lit.Value); j++ {
+			c := lit.Value[j]
+			if lineStart && c == '\t' {
+				continue
+			}
+			buf.WriteByte(c)
+			lineStart = c == '\n'
+		}
+		lit2 := *lit
+		lit2.Value = buf.String()
+		w2.Parts[i] = &lit2
+	}
+	return w2
+}
+
+func parseFd(s string) (int, error) {
+	switch s {
+	case "0", "1", "2":
+		return int(s[0] - '0'), nil
+	}
+	return -1, fmt.Errorf("unsupported file descriptor: %s", s)
+}
+
+// bothFds is used in place of a file descriptor to refer to both the
+// standard output and error.
+const bothFds = -2
+
+// openFd opens a file and sets it as a file descriptor.
+func (r *Runner) openFd(pos syntax.Pos, n int, path string, flag int) (io.Closer, error) {
+	if r.DryRun != nil {
+		r.dryf(pos, "skipped opening %s", quote(path))
+		if n == bothFds {
+			r.setFd(1, nil)
+			r.setFd(2, nil)
+		} else {
+			r.setFd(n, nil)
+		}
+		return nil, nil
+	}
+	name := r.relPath(path)
+	if path == "/dev/null" {
+		// so that it works on Windows too
+		name = os.DevNull
+	}
+	f, err := r.fs().OpenFile(name, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if n == bothFds {
+		r.stdout, r.stderr = f, f
+	} else {
+		r.setFd(n, f)
+	}
+	return f, nil
+}
+
+func (r *Runner) setFd(n int, f io.ReadWriteCloser) {
+	switch n {
+	case 0:
+		if f == nil {
+			r.stdin = nil
+		} else {
+			r.stdin = f
+		}
+	case 1:
+		if f == nil {
+			r.stdout = ioutil.Discard
+		} else {
+			r.stdout = f
+		}
+	case 2:
+		if f == nil {
+			r.stderr = ioutil.Discard
+		} else {
+			r.stderr = f
+		}
+	}
+}
+
+func (r *Runner) dupFd(n, m int) error {
+	switch {
+	case m == 1 && n == 2:
+		r.stderr = r.stdout
+	case m == 2 && n == 1:
+		r.stdout = r.stderr
+	case m == n:
+	default:
+		return fmt.Errorf("unsupported redirect: %d>&%d", n, m)
+	}
+	return nil
+}
@@ -0,0 +1,1333 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mvdan/sh/complete"
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+var runTests = []struct {
+	in, want string
+}{
+	// no-op programs
+	{"", ""},
+	{"true", ""},
+	{":", ""},
+	{"exit", ""},
+	{"exit 0", ""},
+	{"{ :; }", ""},
+	{"(:)", ""},
+
+	// exit codes
+	{"exit 1", "exit status 1"},
+	{"exit 256", ""},
+	{"exit 257", "exit status 1"},
+	{"exit -1", "exit status 255"},
+	{"f() { return 257; }; f; echo $?; g() { return 256; }; g; echo $?", "1\n0\n"},
+	{"exit -1", "exit status 255"},
+	{"exit 300", "exit status 44"},
+	{"false", "exit status 1"},
+	{"false; true", ""},
+	{"false; exit", "exit status 1"},
+	{"exit; echo foo", ""},
+	{"! false", ""},
+	{"! true", "exit status 1"},
+	{"false; echo $?", "1\n"},
+	{"nosuchcmd_x", "nosuchcmd_x: command not found\nexit status 127"},
+
+	// echo via the system binary
+	{"echo", "\n"},
+	{"echo a b c", "a b c\n"},
+	{"echo -n foo", "foo"},
+	{`echo -e '\t'`, "\t\n"},
+
+	// variables
+	{"echo $foo", "\n"},
+	{"foo=bar; echo $foo", "bar\n"},
+	{"foo=bar foo=baz; echo $foo", "baz\n"},
+	{"foo=bar; foo+=baz; echo $foo", "barbaz\n"},
+	{"foo=bar; echo ${foo}baz", "barbaz\n"},
+	{"foo=bar; unset foo; echo $foo", "\n"},
+	{"echo ${#foo}; foo=bar; echo ${#foo}", "0\n3\n"},
+	{"foo=bar sh -c 'echo $foo'; echo $foo", "bar\n\n"},
+	{"foo=bar; sh -c 'echo $foo'", "\n"},
+	{"export foo=bar; sh -c 'echo $foo'", "bar\n"},
+	{"foo=bar; export foo; sh -c 'echo $foo'", "bar\n"},
+	{"readonly foo=bar; foo=x; echo $foo", "foo: readonly variable\nexit status 1"},
+	{"readonly r=1; x=2 r=2 y=3; echo unreached", "r: readonly variable\nexit status 1"},
+	{"readonly r=1; (r=2); echo $?; r=2 true; echo $? $r", "r: readonly variable\n1\nr: readonly variable\n0 1\n"},
+	{"readonly r=1; f() { r=2; echo unreached; }; f; echo unreached", "r: readonly variable\nexit status 1"},
+	{"readonly a=(x); a[0]=y; echo unreached", "a: readonly variable\nexit status 1"},
+	{"declare -r foo=bar; declare foo=x", "foo: readonly variable\nexit status 1"},
+	{"readonly foo; : ${foo:=x}; echo unreached", "foo: readonly variable\nexit status 1"},
+	{"readonly foo=bar; for foo in x; do echo $foo; done", "foo: readonly variable\nexit status 1"},
+	{"export foo=bar; readonly foo; sh -c 'echo $foo'", "bar\n"},
+	{"f() { local foo; echo ${foo-unset}; }; foo=x; f", "unset\n"},
+	{"foo=bar; declare -x foo; sh -c 'echo $foo'", "bar\n"},
+	{"foo=bar :; echo $foo", "\n"},
+
+	// parameter expansions
+	{"echo ${foo:-bar} ${foo-bar}; foo=; echo ${foo:-bar} ${foo-bar}", "bar bar\nbar\n"},
+	{"foo=x; echo ${foo:+bar} ${foo+bar}", "bar bar\n"},
+	{"echo ${foo:=bar}; echo $foo", "bar\nbar\n"},
+	{"foo=abcde; echo ${foo:1} ${foo:1:2} ${foo: -2}", "bcde bc de\n"},
+	{"foo=a.b.c; echo ${foo#*.} ${foo##*.} ${foo%.*} ${foo%%.*}", "b.c c a.b a\n"},
+	{"foo=aXbXc; echo ${foo/X/-} ${foo//X/-}", "a-bXc a-b-c\n"},
+	{"foo=abc; echo ${foo^} ${foo^^}; foo=ABC; echo ${foo,,}", "Abc ABC\nabc\n"},
+	{"echo ${foo:?must be set}; echo unreached", "foo: must be set\nexit status 1"},
+
+	// field splitting and quoting
+	{"foo='a  b'; echo $foo", "a b\n"},
+	{"foo='a  b'; echo \"$foo\"", "a  b\n"},
+	{"foo='a:b'; IFS=:; echo $foo", "a b\n"},
+	{"foo=; echo x $foo y", "x y\n"},
+	{"printf '%s\\n' x \"\" y | wc -l", "3\n"},
+	{`echo 'a\b' "a\"b" a\ b`, "a\\b a\"b a b\n"},
+	{"echo {a,b}{1..2} '{a,b}'", "a1 a2 b1 b2 {a,b}\n"},
+	{"touch a.go b.go; echo *.go '*.go' *.c; rm *.go; echo *.go", "a.go b.go *.go *.c\n*.go\n"},
+	{"echo ~/x | grep -c '^/'", "1\n"},
+
+	// positional parameters
+	{"f() { echo $# $1 $2; }; f a b", "2 a b\n"},
+	{"f() { for i; do echo $i; done; }; f 'a b' c", "a b\nc\n"},
+	{"f() { for i in \"$@\"; do echo $i; done; }; f 'a b' c", "a b\nc\n"},
+	{"f() { for i in $@; do echo $i; done; }; f 'a b' c", "a\nb\nc\n"},
+	{"f() { shift; echo $@; }; f a b c", "b c\n"},
+	{"set -- a 'b c'; echo $#; for i in \"$@\"; do echo $i; done", "2\na\nb c\n"},
+	{"set a b c; shift 2; echo $@ $#", "c 1\n"},
+	{"set a; set --; echo $#", "0\n"},
+	{"f() { set -- x; echo $1; }; set -- y; f; echo $1", "x\ny\n"},
+	{"set -q", "set: invalid option: \"-q\"\nexit status 2"},
+	{"foo='a b'; set | grep '^foo='", "foo='a b'\n"},
+
+	// errexit
+	{"set -e; false; echo unreached", "exit status 1"},
+	{"set -e; sh -c 'exit 3'; echo unreached", "exit status 3"},
+	{"set -e; (false); echo unreached", "exit status 1"},
+	{"set -e; true | false; echo unreached", "exit status 1"},
+	{"set -e; false | true; echo ok", "ok\n"},
+	{"set -e; x=$(false); echo unreached", "exit status 1"},
+	{"set -e; false || true; echo ok", "ok\n"},
+	{"set -e; false && true; echo ok", "ok\n"},
+	{"set -e; true && false; echo unreached", "exit status 1"},
+	{"set -e; { false && true; }; echo ok", "ok\n"},
+	{"set -e; ! true; ! false; echo ok", "ok\n"},
+	{"set -e; if false; then :; elif false; then :; fi; echo ok", "ok\n"},
+	{"set -e; while false; do :; done; until true; do :; done; echo ok", "ok\n"},
+	{"set -e; f() { false; echo in; }; if f; then echo then; fi", "in\nthen\n"},
+	{"set -e; f() { false; echo unreached; }; f", "exit status 1"},
+	{"set -e; [[ a == b ]]; echo unreached", "exit status 1"},
+	{"set -e; (( 0 )); echo unreached", "exit status 1"},
+	{"set -e; set +e; false; echo ok", "ok\n"},
+	{"set -o errexit; false; echo unreached", "exit status 1"},
+	{"(set -e); false; echo ok", "ok\n"},
+
+	// nounset
+	{"set -u; echo $foo; echo unreached", "foo: unbound variable\nexit status 1"},
+	{"set -u; echo ${foo-a} ${foo:-b} ${foo+c}x; echo ${#@} $@ $*", "a b x\n0\n"},
+	{"set -u; echo $1", "1: unbound variable\nexit status 1"},
+	{"set -u; echo ${#foo}", "foo: unbound variable\nexit status 1"},
+	{"set -u; foo=; echo \"$foo\"", "\n"},
+	{"set -eu; set +u; echo $foo", "\n"},
+
+	// pipefail
+	{"false | true; echo $?", "0\n"},
+	{"set -o pipefail; false | true; echo $?", "1\n"},
+	{"set -o pipefail; sh -c 'exit 2' | sh -c 'exit 3' | true; echo $?", "3\n"},
+	{"set -o pipefail; true | true; echo $?", "0\n"},
+	{"set -eo pipefail; false | true; echo unreached", "exit status 1"},
+	{"set -eo pipefail; set -o", "errexit\ton\nnounset\toff\npipefail\ton\nxtrace\toff\n"},
+	{"set -u; set +o", "set +o errexit\nset -o nounset\nset +o pipefail\nset +o xtrace\n"},
+	{"set -o nosuch", "set: invalid option name: \"nosuch\"\nexit status 2"},
+
+	// xtrace
+	{"set -x; echo foo 'a b'; set +x; echo bar", "+ echo foo 'a b'\nfoo a b\n+ set +x\nbar\n"},
+	{"set -x; f() { echo $1; }; f x", "+ f x\n+ echo x\nx\n"},
+	{"set -x; x=$(echo y)", "+ echo y\n"},
+
+	// command substitution and arithmetic
+	{"echo $(echo foo)", "foo\n"},
+	{"echo `echo foo`", "foo\n"},
+	{"foo=$(echo a; echo b); echo \"$foo\"", "a\nb\n"},
+	{"echo $(false); echo $?", "\n0\n"},
+	{"foo=$(false); echo $?", "1\n"},
+	{"echo $((1 + 2 * 3)) $(((1 + 2) * 3))", "7 9\n"},
+	{"echo $((7 / 2)) $((7 % 2)) $((2 ** 10))", "3 1 1024\n"},
+	{"echo $((0x10)) $((010)) $((2#101))", "16 8 5\n"},
+	{"a=3; echo $((a + 1)) $((a++)) $a $((--a))", "4 3 4 3\n"},
+	{"a=2; ((a *= 3)); echo $a", "6\n"},
+	{"echo $((1 ? 2 : 3)) $((0 ? 2 : 3))", "2 3\n"},
+	{"echo $((1 < 2)) $((1 == 2)) $((!0))", "1 0 1\n"},
+	{"((0))", "exit status 1"},
+	{"echo $((1 / 0))", "1:6: division by zero"},
+	{"set -- a b; echo ${#:-x} ${#:+x}", "2 x\n"},
+	{"echo $((5 ? 3 : 4)) $((2 ? 1 : 2)) $((0 ? 1 : 2))", "3 1 2\n"},
+	{"x=4; echo $((4/2)) $((x/2)) $((-7/2))", "2 2 -3\n"},
+	{"echo $((1/0))", "1:6: division by zero"},
+	{"let a=1+2; echo $a", "3\n"},
+
+	// if, loops and case
+	{"if true; then echo foo; fi", "foo\n"},
+	{"if false; then echo foo; else echo bar; fi", "bar\n"},
+	{"if false; then :; elif true; then echo bar; fi", "bar\n"},
+	{"if false; then :; fi", ""},
+	{"for i in a b c; do echo $i; done", "a\nb\nc\n"},
+	{"for ((i = 0; i < 3; i++)); do echo $i; done", "0\n1\n2\n"},
+	{"i=0; while [[ $i -lt 3 ]]; do echo $i; ((i++)); done", "0\n1\n2\n"},
+	{"i=0; until [[ $i -ge 2 ]]; do echo $i; ((i++)); done", "0\n1\n"},
+	{"for i in a b c; do echo $i; break; done", "a\n"},
+	{"for i in a b c; do [[ $i = b ]] && continue; echo $i; done", "a\nc\n"},
+	{"for i in 1 2; do for j in a b; do echo $i$j; break 2; done; done", "1a\n"},
+	{"for i in 1 2; do for j in a b; do continue 2; done; echo x; done", ""},
+	{"break", "break: only meaningful in a loop\n"},
+	{"case foo in f*) echo bar;; esac", "bar\n"},
+	{"case foo in bar) echo a;; *) echo b;; esac", "b\n"},
+	{"case a in a) echo 1;& b) echo 2;; c) echo 3;; esac", "1\n2\n"},
+	{"case a in a) echo 1;;& a) echo 2;; *) echo 3;; esac", "1\n2\n"},
+	{"case 'a b' in 'a b') echo yes;; esac", "yes\n"},
+	{"case foo.go in !(*.sh)) echo yes;; esac", "yes\n"},
+	{"foo='*'; case x in \"$foo\") echo no;; $foo) echo yes;; esac", "yes\n"},
+
+	// arrays
+	{"a=(x y z); echo ${a[0]} ${a[2]} ${a[-1]}; echo $a", "x z z\nx\n"},
+	{"a=(x y z); echo ${#a[@]} ${#a[*]} ${#a[1]}", "3 3 1\n"},
+	{"a=(x 'y z'); for e in \"${a[@]}\"; do echo $e; done", "x\ny z\n"},
+	{"a=(x 'y z'); for e in \"${a[*]}\"; do echo $e; done", "x y z\n"},
+	{"a=(x 'y z'); for e in ${a[@]}; do echo $e; done", "x\ny\nz\n"},
+	{"a=(); for e in \"${a[@]}\"; do echo $e; done; echo ${#a[@]}", "0\n"},
+	{"b='y z'; a=(x $b); echo ${#a[@]}", "3\n"},
+	{"a=(x y); a[1]=w; a[3]=z; echo ${a[@]} ${#a[@]}", "x w z 3\n"},
+	{"a=(x); a+=(y z); a[0]+=1; echo ${a[@]}", "x1 y z\n"},
+	{"a=x; a[1]=y; echo ${a[@]}", "x y\n"},
+	{"a=(x y); a=z; echo ${a[@]}", "z y\n"},
+	{"a=([2]=x y); echo ${#a[@]} ${a[3]}", "2 y\n"},
+	{"i=1; a=(x y); echo ${a[i]} ${a[$i]} ${a[i + 1]-unset}", "y y unset\n"},
+	{"a=(x); i=-2; a[i]=y", "a[i]: bad array subscript\nexit status 1"},
+	{"a=(x y); b=(\"${a[@]}\"); a[0]=z; echo ${b[@]}", "x y\n"},
+	{"declare -a a; echo ${#a[@]}; a+=(x); echo ${a[@]}", "0\nx\n"},
+	{"declare -A m=([a]=x [b]='y z'); echo ${m[a]} ${m[b]} ${#m[@]}", "x y z 2\n"},
+	{"declare -A m; m[k]=v; m[k]+=w; echo ${m[k]} ${m[x]-unset}", "vw unset\n"},
+	{"declare -A m=([b]=2 [a]=1); m+=([c]=3); echo ${m[@]}", "1 2 3\n"},
+	{"declare -A m=(x)", "m: must use subscript when assigning associative array\nexit status 1"},
+	{"a=(x); declare -A a", "a: cannot convert indexed to associative array\nexit status 1"},
+	{"f() { local -a a=(x y); echo ${a[1]}; }; f; echo ${#a[@]}", "y\n0\n"},
+	{"export a=(x); sh -c 'echo ${a-unset}'", "unset\n"},
+	{"readonly a=(x); a[0]=y", "a: readonly variable\nexit status 1"},
+
+	// functions and scoping
+	{"f() { echo foo; }; f", "foo\n"},
+	{"function f { echo foo; }; f", "foo\n"},
+	{"f() { return 3; echo no; }; f; echo $?", "3\n"},
+	{"f() { false; return; }; f", "exit status 1"},
+	{"return", "return: can only be done from a func or sourced script\nexit status 1"},
+	{"echo 'echo in $# $1; return 3; echo no' >src1.sh; . ./src1.sh a; echo $? $#", "in 1 a\n3 0\n"},
+	{"set -- x; echo 'y=1; echo $1' >src2.sh; source src2.sh; echo $y $1", "x\n1 x\n"},
+	{"f() { echo 'return 4' >src3.sh; . ./src3.sh; echo in $?; }; f", "in 4\n"},
+	{"source", "source: filename argument required\nexit status 2"},
+	{"(sleep 0.05; echo a) & wait; echo b", "a\nb\n"},
+	{"{ (sleep 0.05; echo a) & wait; echo b; } & wait; echo c", "a\nb\nc\n"},
+	{"wait 1", "wait: waiting for specific jobs isn't supported\nexit status 2"},
+	{"f() { foo=bar; }; f; echo $foo", "bar\n"},
+	{"f() { local foo=bar; }; foo=x; f; echo $foo", "x\n"},
+	{"f() { local foo=bar; g; }; g() { echo $foo; }; f", "bar\n"},
+	{"f() { declare foo=bar; }; f; echo $foo", "\n"},
+	{"f() { exit 2; }; f; echo no", "exit status 2"},
+	{"local foo", "local: can only be used in a function\nexit status 1"},
+
+	// subshells and pipes
+	{"(foo=bar); echo $foo", "\n"},
+	{"(exit 3); echo $?", "3\n"},
+	{"(echo a; exit; echo b)", "a\n"},
+	{"echo foo | cat", "foo\n"},
+	{"echo foo | sed s/o/a/g | cat", "faa\n"},
+	{"echo foo | false", "exit status 1"},
+	{"false | true", ""},
+	{"true && echo foo", "foo\n"},
+	{"false && echo foo", "exit status 1"},
+	{"false || echo foo", "foo\n"},
+	{"true || echo foo", ""},
+	{"echo foo &", "foo\n"},
+
+	// eval
+	{"eval 'echo foo'", "foo\n"},
+	{"foo='echo bar'; eval $foo", "bar\n"},
+	{"eval 'foo=bar'; echo $foo", "bar\n"},
+
+	// tests
+	{"[[ a ]]", ""},
+	{"[[ '' ]]", "exit status 1"},
+	{"[[ -n a && -z '' ]]", ""},
+	{"[[ ! -n a ]]", "exit status 1"},
+	{"[[ abc == a* ]]", ""},
+	{"[[ abc == 'a*' ]]", "exit status 1"},
+	{"[[ abc != b* ]]", ""},
+	{"[[ a < b ]]", ""},
+	{"[[ 10 -gt 9 ]]", ""},
+	{"[[ (a = b) || b = b ]]", ""},
+	{"[[ abc =~ b.$ ]] && echo $BASH_REMATCH", "bc\n"},
+	{"[[ abc =~ a(b)c ]] && echo ${BASH_REMATCH[1]} ${#BASH_REMATCH[@]}", "b 2\n"},
+	{"[[ -e . && -d . && ! -f . ]]", ""},
+	{"foo=x; [[ -v foo ]] && ! [[ -v bar ]]", ""},
+	{"[[ a =~ a{2,1} ]]", "invalid regex \"a{2,1}\": error parsing regexp: invalid repeat count: `{2,1}`\nexit status 2"},
+
+	// redirects
+	{"echo foo >&2", "foo\n"},
+	{"echo foo 2>&1 >/dev/null", ""},
+	{"{ echo foo >&2; } 2>&1 >/dev/null", "foo\n"},
+	{"echo foo >/dev/null 2>&1", ""},
+	{"echo foo &>/dev/null", ""},
+	{"{ echo foo; echo bar >&2; } 2>/dev/null", "foo\n"},
+	{"cat </dev/null", ""},
+	{"echo foo >/nosuchdir/x; echo $?", "open /nosuchdir/x: no such file or directory\n1\n"},
+
+	// heredocs, herestrings and process substitutions
+	{"foo=bar; cat <<EOF\na $foo \\$foo \"$foo\"\nEOF", "a bar $foo \"bar\"\n"},
+	{"foo=bar; cat <<'EOF'\na $foo \\$foo\nEOF", "a $foo \\$foo\n"},
+	{"foo=bar; cat <<\\EOF\n$foo\nEOF", "$foo\n"},
+	{"cat <<-EOF\n\ta\n\t\tb\n\tEOF", "a\nb\n"},
+	{"cat <<EOF\n$(echo a)\nEOF\necho b", "a\nb\n"},
+	{"sed 's/^/-/' <<EOF\na\nb\nEOF", "-a\n-b\n"},
+	{"foo='a b'; cat <<< \"$foo\"; cat <<<x", "a b\nx\n"},
+	{"cat <(echo a) <(echo b)", "a\nb\n"},
+	{"sed 's/^/-/' < <(echo a; echo b)", "-a\n-b\n"},
+	{"echo a > >(cat)", "a\n"},
+	{"true <(echo a); echo b", "b\n"},
+	{"foo=$(cat <(echo a)); echo $foo", "a\n"},
+
+	// echo and printf
+	{"echo a b; echo -n c; echo", "a b\nc\n"},
+	{"echo -e 'a\\tb\\x41\\0101\\c' x; echo -E 'a\\tb'; echo 'a\\tb'", "a\tbAAa\\tb\na\\tb\n"},
+	{"echo -x -n; echo -- a; echo -nE a; echo", "-x -n\n-- a\na\n"},
+	{"printf '%s-%s\\n' a b c", "a-b\nc-\n"},
+	{"printf 'x\\n' a b", "x\n"},
+	{"printf '%d %i %5d|%-5d|%05d\\n' 3 -4 5 6 7", "3 -4     5|6    |00007\n"},
+	{"printf '%x %X %o %u %#x\\n' 255 255 8 3 16", "ff FF 10 3 0x10\n"},
+	{"printf '%.2f %e %g %g\\n' 3.14159 1500 0.0001 1234567", "3.14 1.500000e+03 0.0001 1.23457e+06\n"},
+	{"printf '%c%c\\n' hello 'w'", "hw\n"},
+	{"printf '%q %q\\n' 'a b' \"it's\"", "'a b' 'it'\\''s'\n"},
+	{"printf '%b|%s\\n' 'a\\tb' 'a\\tb'", "a\tb|a\\tb\n"},
+	{"printf '%b' 'a\\cb' c; echo", "a\n"},
+	{"printf '\\101\\x42á\\n'", "ABá\n"},
+	{"printf '%d\\n' \"'A\" 0x10 010", "65\n16\n8\n"},
+	{"printf '%*d|%.*f\\n' 4 1 2 3.14159", "   1|3.14\n"},
+	{"printf '%s %s %s\\n' a b", "a b \n"},
+	{"printf '%%\\n'", "%\n"},
+	{"printf -v v '%s=%d' x 3; echo $v", "x=3\n"},
+	{"printf '%d\\n' abc; echo $?", "printf: abc: invalid number\n0\n1\n"},
+	{"printf '%z\\n'", "printf: %z: invalid format character\nexit status 1"},
+	{"printf", "printf: usage: printf [-v var] format [arguments]\nexit status 2"},
+
+	// POSIX builtins
+	{"test a = a && [ -n a ] && ! [ -z a ] && [ 3 -gt 2 -a -d / ]", ""},
+	{"test; echo $?; [ a = b ]; echo $?", "1\n1\n"},
+	{"[ a = a", "[: missing ]\nexit status 2"},
+	{"test 1 -eq x", "test: x: integer expression expected\nexit status 2"},
+	{"read a b <<< '  x  y  z  '; echo \"[$a] [$b]\"", "[x] [y  z]\n"},
+	{"read a b c <<< 'x'; echo \"[$a] [$b] [${c-unset}]\"", "[x] [] []\n"},
+	{"read <<< '  x  '; echo \"[$REPLY]\"", "[  x  ]\n"},
+	{"IFS=: read a b <<< 'x::y'; echo \"[$a] [$b]\"", "[x] [:y]\n"},
+	{"read a <<< 'x\\ y\\\nz'; echo \"$a\"; read -r a <<< 'x\\y'; echo \"$a\"", "x yz\nx\\y\n"},
+	{"read -a arr <<< 'x y z'; echo ${arr[1]} ${#arr[@]}", "y 3\n"},
+	{"read -d : a b <<< 'x y:z'; echo $a $b", "x y\n"},
+	{"printf 'x\\ny\\n' | { read a; read b; echo $b $a; }", "y x\n"},
+	{"read a < /dev/null; echo $? ${a-unset}", "1\n"},
+	{"read -z a", "read: invalid option: -z\nexit status 2"},
+	{"e=eval; $e 'foo=bar; echo $foo'", "bar\n"},
+	{"c=export; $c foo=bar; sh -c 'echo $foo'", "bar\n"},
+	{"f() { c=local; $c foo=x; echo $foo; }; foo=y; f; echo $foo", "x\ny\n"},
+	{"c=readonly; $c -a foo; foo=x", "foo: readonly variable\nexit status 1"},
+	{"exec sh -c 'exit 3'; echo unreached", "exit status 3"},
+	{"(exec echo foo); echo bar", "foo\nbar\n"},
+	{"exec >/dev/null; echo hidden", ""},
+	{"{ exec 2>&1; } 2>/dev/null; echo foo >&2", "foo\n"},
+	{"(exec >/dev/null; echo hidden); echo shown", "shown\n"},
+	{"cd /; cd /tmp; cd - >/dev/null; pwd; echo $OLDPWD", "/\n/tmp\n"},
+	{"f() { echo f; }; unset -f f; f", "f: command not found\nexit status 127"},
+
+	// getopts
+	{"while getopts ab:c o -a -b x -c y; do echo $o ${OPTARG-unset}; done; echo $OPTIND", "a unset\nb x\nc unset\n5\n"},
+	{"while getopts ab: o -acb x; do echo $o ${OPTARG-}; done", "a\ngetopts: illegal option -- c\n?\nb x\n"},
+	{"while getopts :ab: o -c -b; do echo $o $OPTARG; done", "? c\n: b\n"},
+	{"while getopts b: o -b; do echo $o; done", "getopts: option requires an argument -- b\n?\n"},
+	{"while getopts b: o -bfoo -- -b; do echo $OPTARG; done; echo $OPTIND $o", "foo\n3 ?\n"},
+	{"set -- -a x -b; while getopts ab o; do echo $o; done; echo $OPTIND", "a\n2\n"},
+	{"getopts a o -a; OPTIND=1; getopts a o -a; echo $o $OPTIND", "a 2\n"},
+	{"f() { local OPTIND; getopts a o \"$@\"; echo $o; }; f -a; f -a", "a\na\n"},
+	{"getopts a", "getopts: usage: getopts optstring name [arg ...]\nexit status 2"},
+
+	// cd and pwd
+	{"cd /; pwd", "/\n"},
+	{"cd /; cd tmp; pwd; echo $PWD", "/tmp\n/tmp\n"},
+	{"(cd /); pwd | grep -c '^/$'", "0\nexit status 1"},
+	{"cd /; (cd /tmp; pwd); pwd; echo $(cd /tmp; pwd) $PWD", "/tmp\n/\n/tmp /\n"},
+	{"cd /; cd /tmp | true; pwd", "/\n"},
+
+	// subshell isolation
+	{"foo=a; (foo=b; bar=c); echo $foo ${bar-unset}", "a unset\n"},
+	{"foo=a; bar=$(foo=b; echo $foo); echo $foo $bar", "a b\n"},
+	{"foo=a; foo=b | { foo=c; }; echo $foo", "a\n"},
+	{"foo=a; (unset foo); echo $foo", "a\n"},
+	{"f() { echo f; }; (f() { echo g; }; f); f", "g\nf\n"},
+	{"f() { local foo=b; (foo=c); echo $foo; }; foo=a; f; echo $foo", "b\na\n"},
+	{"f() { (g() { :; }); foo=b; }; (foo=c; f; echo $foo); echo ${foo-unset}", "b\nunset\n"},
+	{"foo=a; (foo=b) & foo=c; echo $foo", "c\n"},
+	{"for i in 1 2 3; do (i=x; echo $i) | cat; done; echo $i", "x\nx\nx\n3\n"},
+	{"cd /nosuchdir", "cd: /nosuchdir: not a directory\nexit status 1"},
+}
+
+// concBuffer is a buffer that can be written to concurrently, as
+// happens with the standard output and error of pipelines.
+type concBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *concBuffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+func (c *concBuffer) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "interp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	env := expand.ListEnviron("PATH="+os.Getenv("PATH"), "HOME="+dir)
+	for i, tc := range runTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			var buf concBuffer
+			r := Runner{
+				Env:    env,
+				Dir:    dir,
+				Stdin:  strings.NewReader(""),
+				Stdout: &buf,
+				Stderr: &buf,
+			}
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+// bashTests are also run with Bash if it's installed, to check that
+// both produce the same output.
+var bashTests = []struct {
+	in, want string
+}{
+	// array indexes are arithmetic expressions
+	{"a=(1 2 3); i=1; echo ${a[i+1]} ${a[i*2-2]}; a[i+1]=z; echo ${a[@]}", "3 1\n1 2 z\n"},
+	{"a=(1 2 3); x='1+1'; echo ${a[x]} $((x * 2))", "3 4\n"},
+
+	// indexes with expansions in assignments
+	{"declare -A m; k=foo; m[$k]=1; m[$k]+=2; echo ${m[foo]}", "12\n"},
+	{"a=(x y); i=1; a[$i]=z; a[$i+1]=w; echo ${a[@]}", "x z w\n"},
+
+	// unsetting elements
+	{"a=(x y z); unset 'a[1]'; echo ${a[@]} ${#a[@]} ${!a[@]}", "x z 2 0 2\n"},
+	{"declare -A m=([x]=1 [y]=2); unset 'm[x]'; echo ${!m[@]} ${m[y]}", "y 2\n"},
+
+	// slices of all the elements
+	{"a=(a b c); for e in \"${a[@]:1}\"; do echo \"[$e]\"; done", "[b]\n[c]\n"},
+	{"a=(a b c); for e in \"${a[@]:1:1}\"; do echo \"[$e]\"; done; echo ${a[@]: -1}", "[b]\nc\n"},
+	{"a=(a b c); echo \"${a[*]:1}\"", "b c\n"},
+	{"set -- x y z; for e in \"${@:2}\"; do echo \"[$e]\"; done", "[y]\n[z]\n"},
+
+	// sparse arrays
+	{"a[5]=x; echo ${#a[@]} ${a[5]} ${!a[@]}", "1 x 5\n"},
+	{"a=([1]=a [5]=b [6]=c); echo ${a[@]:2} ${a[@]:5:1} ${a[-1]}", "b c b c\n"},
+	{"a=(x y); a+=(z); unset 'a[0]'; a+=(w); echo ${!a[@]} ${a[@]}", "1 2 3 y z w\n"},
+
+	// the keys of arrays
+	{"a=(x y z); echo ${!a[@]}; for k in \"${!a[@]}\"; do echo $k; done", "0 1 2\n0\n1\n2\n"},
+	{"declare -A m=([k]=v); echo ${!m[@]} ${!m[*]}", "k k\n"},
+
+	// "$*" joins with the first character of IFS
+	{"set -- a b c; IFS=:; echo \"$*\" \"$@\"; x=\"$*\"; echo $x", "a:b:c a b c\na b c\n"},
+	{"set -- a b c; IFS=; echo \"$*\"; IFS=' :'; echo \"$*\" \"${*:2}\"", "abc\na b c b c\n"},
+	{"set -- a b; unset IFS; echo \"$*\"", "a b\n"},
+	{"a=(x y); IFS=-; echo \"${a[*]}\" \"${a[@]}\"; b=\"${a[*]}\"; echo \"$b\"", "x-y x y\nx-y\n"},
+	{"a=(x y); IFS=,:; echo \"${a[*]}\" \"${!a[*]}\"", "x,y 0,1\n"},
+}
+
+func TestRunBash(t *testing.T) {
+	t.Parallel()
+	bash, _ := exec.LookPath("bash")
+	for i, tc := range bashTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			var buf concBuffer
+			r := Runner{Stdout: &buf, Stderr: &buf}
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+			if bash == "" {
+				return
+			}
+			out, err := exec.Command(bash, "-c", tc.in).CombinedOutput()
+			if err != nil {
+				t.Fatalf("bash failed in %q: %v", tc.in, err)
+			}
+			if got := string(out); got != tc.want {
+				t.Fatalf("wrong bash output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var execTests = []struct {
+	in, want string
+}{
+	{"fetch foo", "fetched foo\n"},
+	{"fetch; echo $?", "fetch: missing URL\n2\n"},
+	{"rm -rf /", "rm: denied\nexit status 126"},
+	{"X=y env | grep '^X='", "X=y\n"},
+	{"nosuchcmd", "nosuchcmd: command not found\nexit status 127"},
+	{"f() { fetch \"$@\"; }; f bar", "fetched bar\n"},
+}
+
+func TestExecHandler(t *testing.T) {
+	t.Parallel()
+	fetch := func(next ExecHandler) ExecHandler {
+		return func(ctx ExecContext, args []string) error {
+			if args[0] != "fetch" {
+				return next(ctx, args)
+			}
+			if len(args) < 2 {
+				fmt.Fprintf(ctx.Stderr, "fetch: missing URL\n")
+				return ExitCode(2)
+			}
+			fmt.Fprintf(ctx.Stdout, "fetched %s\n", args[1])
+			return nil
+		}
+	}
+	deny := func(next ExecHandler) ExecHandler {
+		return func(ctx ExecContext, args []string) error {
+			if args[0] == "rm" {
+				return fmt.Errorf("%s: denied", args[0])
+			}
+			return next(ctx, args)
+		}
+	}
+	env := expand.ListEnviron("PATH=" + os.Getenv("PATH"))
+	for i, tc := range execTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			var buf concBuffer
+			r := Runner{
+				Env:             env,
+				Stdout:          &buf,
+				Stderr:          &buf,
+				ExecMiddlewares: []ExecMiddleware{deny, fetch},
+			}
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var registerTests = []struct {
+	in, want string
+}{
+	{"notify hello world", "notify: hello world\n"},
+	{"echo foo | notify -", "notify: foo\n"},
+	{"notify; echo $?", "notify: no message\n1\n"},
+	{"TO=me notify x; echo ${TO-unset}", "notify me: x\nunset\n"},
+	{"notify() { echo func; }; notify x", "func\n"},
+	{"cd() { notify cd; }; cd /", "notify: cd\n"},
+}
+
+func TestLookPath(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "interp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{
+		"prog":     0777,
+		"prog.bat": 0666,
+		"tool.exe": 0666,
+		"data":     0666,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(bin, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exts := []string{".com", ".exe", ".bat"}
+	tests := []struct {
+		file string
+		exts []string
+		want string
+	}{
+		{"prog", nil, "bin/prog"},
+		{"data", nil, ""},
+		{"tool", nil, ""},
+		{"bin/prog", nil, "bin/prog"},
+		{"prog", exts, "bin/prog.bat"},
+		{"tool", exts, "bin/tool.exe"},
+		{"tool.exe", exts, "bin/tool.exe"},
+		{"data", exts, ""},
+		{"bin/tool", exts, "bin/tool.exe"},
+	}
+	for _, tc := range tests {
+		want := tc.want
+		if want != "" {
+			want = filepath.Join(dir, want)
+		}
+		if got := lookPath(dir, bin, tc.file, tc.exts); got != want {
+			t.Errorf("lookPath(%q, %q) got %q, want %q", tc.file, tc.exts, got, want)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	notify := func(ctx ExecContext, args []string) error {
+		if len(args) < 2 {
+			fmt.Fprintf(ctx.Stderr, "notify: no message\n")
+			return ExitCode(1)
+		}
+		msg := strings.Join(args[1:], " ")
+		if msg == "-" {
+			bs, err := ioutil.ReadAll(ctx.Stdin)
+			if err != nil {
+				return err
+			}
+			msg = strings.TrimSpace(string(bs))
+		}
+		if to := envValue(ctx.Env, "TO"); to != "" {
+			fmt.Fprintf(ctx.Stdout, "notify %s: %s\n", to, msg)
+		} else {
+			fmt.Fprintf(ctx.Stdout, "notify: %s\n", msg)
+		}
+		return nil
+	}
+	env := expand.ListEnviron("PATH=" + os.Getenv("PATH"))
+	for i, tc := range registerTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			var buf concBuffer
+			r := Runner{
+				Env:    env,
+				Stdout: &buf,
+				Stderr: &buf,
+			}
+			r.Register("notify", notify)
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var dryRunTests = []struct {
+	in, want string
+}{
+	{"rm -rf /tmp/foo", "rm -rf /tmp/foo\n"},
+	{`say "a b" '' "it's"`, "say 'a b' '' 'it'\\''s'\n"},
+	{"X=1 make all", "X=1 make all\n"},
+	{"x=foo; say $x; true", "say foo\n"},
+	{"f() { deploy $1; }; f prod; f dev", "deploy prod\ndeploy dev\n"},
+	{"cd /; say $PWD", "say /\n"},
+	{"say foo >/etc/passwd", "# 1:9: skipped opening /etc/passwd\nsay foo\n"},
+	{"a | notify", "a\nnotify\n"},
+	{"grep -q x f && say yes", "grep -q x f\n# 1:13: && depends on an unknown exit status, assuming success\nsay yes\n"},
+	{"echo foo; x=$(printf bar); say $x", "foo\nsay bar\n"},
+	{"if true; then a; else b; fi", "a\n"},
+	{
+		"if grep -q x f; then a; else b; fi",
+		"grep -q x f\n# 1:1: if depends on an unknown exit status, assuming success\na\n",
+	},
+	{
+		"while ping -c1 host; do sleep 1; done",
+		"ping -c1 host\n# 1:1: loop depends on an unknown exit status, assuming success\nsleep 1\nping -c1 host\n# 1:1: loop condition depends on an unknown exit status, stopping\n",
+	},
+	{
+		"for f in $(ls); do rm $f; done",
+		"ls\n# 1:1: for depends on unknown command output, assuming none\n",
+	},
+	{"exit 3; rm x", "exit status 3"},
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	for i, tc := range dryRunTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			var buf concBuffer
+			r := Runner{
+				Env:    expand.ListEnviron(),
+				Stdout: &buf,
+				Stderr: &buf,
+				DryRun: &buf,
+			}
+			r.Register("notify", func(ctx ExecContext, args []string) error {
+				return fmt.Errorf("registered commands must not run")
+			})
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		in      string
+		runner  Runner
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Timeout",
+			in:      "echo foo; sleep 10; echo bar",
+			runner:  Runner{Timeout: 50 * time.Millisecond},
+			want:    "foo\n",
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "TimeoutLoop",
+			in:      "while true; do :; done",
+			runner:  Runner{Timeout: 50 * time.Millisecond},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:   "CmdTimeout",
+			in:     "sleep 10; echo $?; sleep 0; echo $?",
+			runner: Runner{CmdTimeout: 50 * time.Millisecond},
+			want:   "137\n0\n",
+		},
+		{
+			name:    "Cancel",
+			in:      "sleep 10 & sleep 10",
+			runner:  Runner{},
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatalf("could not parse: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var buf concBuffer
+			r := tc.runner
+			r.Env = expand.ListEnviron("PATH=" + os.Getenv("PATH"))
+			r.Stdout = &buf
+			if tc.wantErr == context.Canceled {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+			start := time.Now()
+			err = r.Run(ctx, file)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("Run took too long: %v", elapsed)
+			}
+			if err != tc.wantErr {
+				t.Fatalf("wrong error in %q:\nwant: %v\ngot:  %v",
+					tc.in, tc.wantErr, err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRunReuse(t *testing.T) {
+	t.Parallel()
+	var buf concBuffer
+	r := Runner{
+		Env:    expand.ListEnviron("A=env"),
+		Dir:    "/tmp",
+		Params: []string{"p"},
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+	srcs := []string{
+		"A=changed; x=1; f() { :; }; set -e -- q; cd /; exit 3",
+		"echo $A ${x-unset} $1 $PWD; false; echo $?; f; echo $?",
+	}
+	for _, src := range srcs {
+		file, err := syntax.Parse([]byte(src), "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Run(context.Background(), file); err != nil {
+			fmt.Fprint(&buf, err)
+		}
+	}
+	want := "exit status 3env unset p /tmp\n1\nf: command not found\n127\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+	if r.Dir != "/tmp" {
+		t.Fatalf("the program modified Dir: %q", r.Dir)
+	}
+
+	file, err := syntax.Parse([]byte("block"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, unblock := make(chan bool), make(chan bool)
+	r.Register("block", func(ctx ExecContext, args []string) error {
+		started <- true
+		<-unblock
+		return nil
+	})
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background(), file) }()
+	<-started
+	if err := r.Run(context.Background(), file); err == nil {
+		t.Fatalf("wanted an error when running a Runner twice at once")
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunFragments(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte("x=foo\nf() { echo $x $1; }\nf bar\nexit 2\necho after"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	r := Runner{Env: expand.ListEnviron(), Stdout: &buf}
+	ctx := context.Background()
+	for _, stmt := range file.Stmts[:3] {
+		if err := r.Run(ctx, stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Run(ctx, file.Stmts[3]); err != ExitCode(2) || !r.Exited() {
+		t.Fatalf("wanted exit status 2 and Exited, got %v and %t", err, r.Exited())
+	}
+	// the shell can go on after an exit
+	if err := r.Run(ctx, file.Stmts[4].Cmd); err != nil || r.Exited() {
+		t.Fatalf("wanted no error nor Exited, got %v and %t", err, r.Exited())
+	}
+	word, err := syntax.Parse([]byte("f$x"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(ctx, word.Stmts[0].Cmd.(*syntax.CallExpr).Args[0]); err != ExitCode(127) {
+		t.Fatalf("wanted exit status 127, got %v", err)
+	}
+	if want, got := "foo bar\nafter\n", buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+	if err := r.Run(ctx, &syntax.Lit{}); err == nil {
+		t.Fatalf("wanted an error when running a Lit")
+	}
+}
+
+func TestVars(t *testing.T) {
+	t.Parallel()
+	src := "a=changed; unset b; export c; d=new; declare -r e; f=(1 2); g=same"
+	file, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Runner{
+		Dir: "/",
+		Env: expand.ListEnviron("a=1", "b=2", "e=5", "g=same"),
+	}
+	r.Env.(expand.MapEnviron)["c"] = expand.Variable{Set: true, Value: "3"}
+	if err := r.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	before := r.Vars()
+	if err := r.Run(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range expand.Diff(before, r.Vars()) {
+		got = append(got, c.Name+" "+c.Kind.String())
+	}
+	want := []string{"a modified", "b unset", "c exported", "d set",
+		"e modified", "f set"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong changes:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestRunParams(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte(`echo $# "$1"; shift; echo "$@"; set -- c`), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf concBuffer
+	params := []string{"a", "b c", "d"}
+	r := Runner{
+		Env:    expand.ListEnviron("PATH=" + os.Getenv("PATH")),
+		Params: params,
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+	if err := r.Run(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "3 a\nb c d\n", buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+	if params[0] != "a" || len(params) != 3 {
+		t.Fatalf("the program modified Params: %q", params)
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte("false | true; echo $?; echo ${unset-x}; echo $unset"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf concBuffer
+	r := Runner{
+		Env:     expand.ListEnviron("PATH=" + os.Getenv("PATH")),
+		Options: Options{NoUnset: true, PipeFail: true},
+		Stdout:  &buf,
+		Stderr:  &buf,
+	}
+	if err := r.Run(context.Background(), file); err != ExitCode(1) {
+		t.Fatalf("wanted exit status 1, got: %v", err)
+	}
+	if want, got := "1\nx\nunset: unbound variable\n", buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestRunXPGEcho(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte(`echo 'a\tb'; echo -E 'a\tb'`), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf concBuffer
+	r := Runner{
+		Options: Options{XPGEcho: true},
+		Stdout:  &buf,
+		Stderr:  &buf,
+	}
+	if err := r.Run(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "a\tb\na\\tb\n", buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestRunLazyHeredocs(t *testing.T) {
+	t.Parallel()
+	src := "x=1\ncat <<EOF\nval $x $((1+1))\nEOF\ncat <<-EOF\n\t$x\n\tEOF\ncat <<'EOF'\n$x\nEOF"
+	file, err := syntax.Parse([]byte(src), "", syntax.LazyHeredocs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf concBuffer
+	r := Runner{
+		Env:    expand.ListEnviron("PATH=" + os.Getenv("PATH")),
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+	if err := r.Run(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "val 1 2\n1\n$x\n", buf.String(); got != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte("x=b; echo a $x >/dev/null; false"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	r := Runner{
+		Trace: func(ev TraceEvent) {
+			s := fmt.Sprintf("%s %q", ev.Kind, ev.Args)
+			switch ev.Kind {
+			case TraceCmdEnd:
+				s += fmt.Sprintf(" %d", ev.Exit)
+			case TraceRedirect:
+				s += " " + ev.Redirect.Op.String()
+			}
+			events = append(events, s)
+		},
+	}
+	if err := r.Run(context.Background(), file); err != ExitCode(1) {
+		t.Fatalf("wanted exit status 1, got: %v", err)
+	}
+	want := []string{
+		`expand ["b"]`,
+		`expand ["/dev/null"]`,
+		`redirect ["/dev/null"] >`,
+		`expand ["echo"]`,
+		`expand ["a"]`,
+		`expand ["b"]`,
+		`cmd-start ["echo" "a" "b"]`,
+		`cmd-end ["echo" "a" "b"] 0`,
+		`expand ["false"]`,
+		`cmd-start ["false"]`,
+		`cmd-end ["false"] 1`,
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("wrong events:\nwant: %q\ngot:  %q", want, events)
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	t.Parallel()
+	src := "f() {\n\techo in f\n}\nx=1\nf\necho $x\n"
+	tests := []struct {
+		actions []DebugAction
+		want    string
+	}{
+		{[]DebugAction{DebugContinue}, "4 "},
+		{[]DebugAction{DebugStep, DebugNext, DebugContinue}, "4 5 6 "},
+		{[]DebugAction{DebugStep, DebugStep, DebugStepOut, DebugContinue}, "4 5 2[f] 6 "},
+		{[]DebugAction{DebugStep, DebugStep, DebugStep, DebugStep}, "4 5 2[f] 6 "},
+	}
+	for i, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			t.Parallel()
+			file, err := syntax.Parse([]byte(src), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out, paused concBuffer
+			d := &Debugger{}
+			d.SetBreakpoint("", 4)
+			d.Paused = func(f *Frame) DebugAction {
+				fmt.Fprint(&paused, f.Position.Line)
+				if funcs := f.Funcs(); len(funcs) > 0 {
+					fmt.Fprint(&paused, funcs)
+				}
+				fmt.Fprint(&paused, " ")
+				if f.Position.Line == 6 {
+					f.Env().Set("x", expand.Variable{Set: true, Value: "changed"})
+				}
+				action := tc.actions[0]
+				tc.actions = tc.actions[1:]
+				return action
+			}
+			r := Runner{Stdout: &out, Debugger: d}
+			if err := r.Run(context.Background(), file); err != nil {
+				t.Fatal(err)
+			}
+			if got := paused.String(); got != tc.want {
+				t.Fatalf("wrong pauses:\nwant: %q\ngot:  %q", tc.want, got)
+			}
+			want := "in f\n1\n"
+			if strings.HasSuffix(tc.want, "6 ") {
+				want = "in f\nchanged\n"
+			}
+			if got := out.String(); got != want {
+				t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+			}
+		})
+	}
+	d := &Debugger{}
+	d.SetBreakpoint("b", 2)
+	d.SetBreakpoint("a", 3)
+	d.SetBreakpoint("a", 1)
+	d.ClearBreakpoint("b", 2)
+	want := []Breakpoint{{"a", 1}, {"a", 3}}
+	if got := d.Breakpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong breakpoints:\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	t.Parallel()
+	src := "f() {\n\techo f\n}\nif [ \"$1\" = a ]; then\n\tf; f\nelse\n\teval 'echo b'\nfi\n"
+	cover := &Coverage{}
+	for _, param := range []string{"a", "a", "b"} {
+		file, err := syntax.Parse([]byte(src), "lib.sh", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := Runner{Params: []string{param}, Coverage: cover}
+		if err := r.Run(context.Background(), file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, sc := range cover.Stmts("lib.sh") {
+		got = append(got, fmt.Sprintf("%d:%d %d", sc.Pos.Line, sc.Pos.Column, sc.Hits))
+	}
+	want := []string{"1:1 3", "1:5 4", "2:2 4", "4:1 3", "4:4 3", "5:2 2", "5:5 2", "7:2 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong coverage:\nwant: %q\ngot:  %q", want, got)
+	}
+	var buf bytes.Buffer
+	if err := cover.WriteLCOV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	wantLCOV := "TN:\nSF:lib.sh\nDA:1,4\nDA:2,4\nDA:4,3\nDA:5,2\nDA:7,1\nLF:5\nLH:5\nend_of_record\n"
+	if got := buf.String(); got != wantLCOV {
+		t.Fatalf("wrong LCOV:\nwant: %q\ngot:  %q", wantLCOV, got)
+	}
+	buf.Reset()
+	err := cover.WriteHTML(&buf, func(name string) ([]byte, error) {
+		return []byte(src), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<span class="hits">2</span><span class="hit">	f; f</span>`; !strings.Contains(buf.String(), want) {
+		t.Fatalf("HTML report is missing %q:\n%s", want, buf.String())
+	}
+}
+
+func TestProfile(t *testing.T) {
+	t.Parallel()
+	file, err := syntax.Parse([]byte("f() {\n\tsleep 0.05\n}\nf; f\ntrue"), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prof := &Profile{}
+	r := Runner{
+		Env:     expand.ListEnviron("PATH=" + os.Getenv("PATH")),
+		Profile: prof,
+	}
+	if err := r.Run(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range prof.Entries() {
+		got = append(got, fmt.Sprintf("%d:%d %s %d", e.Pos.Line, e.Pos.Column, e.Name, e.Calls))
+	}
+	// each function call took about half of the time in sleep, and
+	// which of them took longer depends on the timing
+	sort.Strings(got[1:3])
+	want := []string{"2:2 sleep 2", "4:1 f 1", "4:4 f 1", "5:1 true 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong profile:\nwant: %q\ngot:  %q", want, got)
+	}
+	if e := prof.Entries()[0]; e.Wall < 100*time.Millisecond {
+		t.Fatalf("sleep took less than 100ms in total: %v", e.Wall)
+	}
+	var buf bytes.Buffer
+	if err := prof.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "2  2:2: sleep\n") {
+		t.Fatalf("wrong text profile:\n%s", out)
+	}
+}
+
+var interactiveTests = []struct {
+	in, want string
+}{
+	{"", "$ "},
+	{"echo foo\n", "$ foo\n$ "},
+	{"echo foo", "$ foo\n$ "},
+	{"a=1\necho $a\n", "$ $ 1\n$ "},
+	{"echo 'foo\nbar'\n", "$ > foo\nbar\n$ "},
+	{"if true\nthen echo foo\nfi\n", "$ > > foo\n$ "},
+	{"echo foo \\\nbar\n", "$ > foo bar\n$ "},
+	{"echo foo \\\\\n", "$ foo \\\n$ "},
+	{"f() {\necho in f\n}\nf\n", "$ > > $ in f\n$ "},
+	{"echo )\necho foo\n", "$ 1:6: a command can only contain words and redirects\n$ foo\n$ "},
+	{"(exit 3)\necho $?\n", "$ $ 3\n$ "},
+	{"echo \"foo\n", "$ > syntax error: unexpected end of file\n"},
+	{"exit 4\necho foo\n", "$ "},
+	{"PS1='x$a> '; a=1\n", "$ x1> "},
+	{"PS1='\\\\ '; PS2='.. '\n{\n}\n", "$ \\ .. \\ "},
+	{"echo foo\necho bar\nhistory\n", "$ foo\n$ bar\n$     1  echo foo\n    2  echo bar\n    3  history\n$ "},
+	{"echo foo\nhistory -c\nhistory\n", "$ foo\n$ $     1  history\n$ "},
+	{"readonly r=1\nr=2\necho $?\n", "$ $ r: readonly variable\n$ 1\n$ "},
+	{"readonly r=1\n(r=2; echo no)\n", "$ $ r: readonly variable\n$ "},
+}
+
+func TestInteractive(t *testing.T) {
+	t.Parallel()
+	for i, tc := range interactiveTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			var buf bytes.Buffer
+			r := Runner{
+				Env:    expand.ListEnviron(),
+				Stdout: &buf,
+				Stderr: &buf,
+			}
+			r.Interactive(context.Background(), strings.NewReader(tc.in))
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInteractiveExit(t *testing.T) {
+	t.Parallel()
+	r := Runner{Env: expand.ListEnviron()}
+	err := r.Interactive(context.Background(), strings.NewReader("false\ntrue\nexit 3\n"))
+	if err != ExitCode(3) {
+		t.Fatalf("wrong error: want %v, got %v", ExitCode(3), err)
+	}
+	want := []string{"false", "true", "exit 3"}
+	if got := r.History(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong history:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	t.Parallel()
+	r := Runner{Env: expand.ListEnviron("FOO=1")}
+	c := &complete.Completer{Providers: []complete.Provider{&r}}
+	var got []string
+	in := "fooFn() { :; }; FOOBAR=2\n"
+	err := r.Interactive(context.Background(), readerFunc(func(p []byte) (int, error) {
+		if in == "" {
+			_, cands := c.Complete("echo $FO", 8)
+			for _, cand := range cands {
+				got = append(got, cand.Text)
+			}
+			_, cands = c.Complete("fo", 2)
+			for _, cand := range cands {
+				got = append(got, cand.Text)
+			}
+			return 0, io.EOF
+		}
+		n := copy(p, in)
+		in = in[n:]
+		return n, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"FOO", "FOOBAR", "fooFn"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong candidates:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestParseGetopts(t *testing.T) {
+	t.Parallel()
+	want := GetoptsSpec{
+		Silent: true,
+		Flags: []GetoptsFlag{
+			{Name: 'a'},
+			{Name: 'b', HasArg: true},
+			{Name: 'c'},
+		},
+	}
+	got := ParseGetopts(":ab:c")
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong spec:\nwant: %+v\ngot:  %+v", want, got)
+	}
+	if flag, ok := got.Flag('b'); !ok || !flag.HasArg {
+		t.Fatalf("Flag('b') did not find the option with an argument")
+	}
+	if _, ok := got.Flag('d'); ok {
+		t.Fatalf("Flag('d') found an option that doesn't exist")
+	}
+}
+
+func BenchmarkRunReuse(b *testing.B) {
+	file, err := syntax.Parse([]byte(`x=foo; f() { echo "$1" $x; }; f bar`), "", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := Runner{Env: expand.ListEnviron("A=b", "C=d")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := r.Reset(); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.Run(context.Background(), file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
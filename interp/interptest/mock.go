@@ -0,0 +1,151 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package interptest helps test shell programs run by the interp
+// package, by replacing the programs that they run with mocks.
+package interptest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/mvdan/sh/interp"
+	"github.com/mvdan/sh/pattern"
+)
+
+// TB is the part of testing.TB that a Mock uses to report failures.
+type TB interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Mock replaces the programs that a Runner runs with expected commands,
+// which write canned output and exit with a canned status. Commands that
+// weren't expected fail the test, as does Check if any expected command
+// didn't run.
+//
+// For example, to test a deploy script without running it for real:
+//
+//	m := interptest.NewMock(t)
+//	m.Expect("git", "rev-parse", "HEAD").Stdout("abc123\n")
+//	m.Expect("curl", "-fsS", "https://*").Exit(22)
+//	r := interp.Runner{Exec: m.Exec}
+//	r.Run(ctx, file)
+//	m.Check()
+type Mock struct {
+	t TB
+
+	mu      sync.Mutex
+	expects []*Expect
+}
+
+// NewMock returns a Mock that reports failures to t.
+func NewMock(t TB) *Mock {
+	return &Mock{t: t}
+}
+
+// Expect is a command that the program is expected to run.
+type Expect struct {
+	desc  string
+	match func(args []string) bool
+
+	stdout, stderr string
+	exit           uint8
+	times          int
+
+	calls int
+}
+
+// Expect adds a command that the program is expected to run once, whose
+// arguments match the given shell patterns, like "https://*". The name
+// of the command is the first argument.
+func (m *Mock) Expect(pats ...string) *Expect {
+	desc := strings.Join(pats, " ")
+	return m.ExpectFunc(desc, func(args []string) bool {
+		if len(args) != len(pats) {
+			return false
+		}
+		for i, pat := range pats {
+			if !pattern.Match(pat, args[i], pattern.ExtGlob) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ExpectFunc is like Expect, but the arguments are matched by a
+// function. desc describes the command in failures.
+func (m *Mock) ExpectFunc(desc string, match func(args []string) bool) *Expect {
+	e := &Expect{desc: desc, match: match, times: 1}
+	m.mu.Lock()
+	m.expects = append(m.expects, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Stdout sets the output of the command.
+func (e *Expect) Stdout(s string) *Expect {
+	e.stdout = s
+	return e
+}
+
+// Stderr sets the error output of the command.
+func (e *Expect) Stderr(s string) *Expect {
+	e.stderr = s
+	return e
+}
+
+// Exit sets the exit status of the command, which is zero by default.
+func (e *Expect) Exit(code uint8) *Expect {
+	e.exit = code
+	return e
+}
+
+// Times sets how many times the command is expected to run, which is
+// once by default. If n is negative, it may run any number of times.
+func (e *Expect) Times(n int) *Expect {
+	e.times = n
+	return e
+}
+
+// Exec is an ExecHandler that runs the expected commands, to be set as
+// Runner.Exec. Each command runs the first expectation that matches it
+// and that hasn't run as many times as expected yet.
+func (m *Mock) Exec(ctx interp.ExecContext, args []string) error {
+	m.mu.Lock()
+	var found *Expect
+	for _, e := range m.expects {
+		if (e.times < 0 || e.calls < e.times) && e.match(args) {
+			found = e
+			e.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+	if found == nil {
+		m.t.Errorf("unexpected command: %s", strings.Join(args, " "))
+		fmt.Fprintf(ctx.Stderr, "%s: unexpected command\n", args[0])
+		return interp.ExitCode(127)
+	}
+	io.WriteString(ctx.Stdout, found.stdout)
+	io.WriteString(ctx.Stderr, found.stderr)
+	if found.exit != 0 {
+		return interp.ExitCode(found.exit)
+	}
+	return nil
+}
+
+// Check fails the test if an expected command ran fewer times than
+// expected.
+func (m *Mock) Check() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expects {
+		if e.times >= 0 && e.calls < e.times {
+			m.t.Errorf("expected command did not run: %s (ran %d of %d times)",
+				e.desc, e.calls, e.times)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interptest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/interp"
+	"github.com/mvdan/sh/syntax"
+)
+
+// failures records the failures that a Mock reports.
+type failures []string
+
+func (f *failures) Errorf(format string, args ...interface{}) {
+	*f = append(*f, fmt.Sprintf(format, args...))
+}
+
+func TestMock(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in, want string
+		errs     []string
+	}{
+		{
+			"rev=$(git rev-parse HEAD); echo $rev",
+			"abc\n",
+			[]string{"expected command did not run: curl -fsS https://* (ran 0 of 1 times)"},
+		},
+		{
+			"git rev-parse HEAD; curl -fsS https://example.com; echo $?",
+			"abc\nfailed\n22\n",
+			nil,
+		},
+		{
+			"git push",
+			"git: unexpected command\nexit status 127",
+			[]string{
+				"unexpected command: git push",
+				"expected command did not run: git rev-parse HEAD (ran 0 of 1 times)",
+				"expected command did not run: curl -fsS https://* (ran 0 of 1 times)",
+			},
+		},
+		{
+			"git rev-parse HEAD; git rev-parse HEAD; curl -fsS https://a; sleep 1; sleep 2",
+			"abc\ngit: unexpected command\nfailed\n",
+			[]string{"unexpected command: git rev-parse HEAD"},
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			file, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var errs failures
+			m := NewMock(&errs)
+			m.Expect("git", "rev-parse", "HEAD").Stdout("abc\n")
+			m.Expect("curl", "-fsS", "https://*").Stderr("failed\n").Exit(22)
+			m.Expect("sleep", "*").Times(-1)
+			var buf bytes.Buffer
+			r := interp.Runner{Exec: m.Exec, Stdout: &buf, Stderr: &buf}
+			if err := r.Run(context.Background(), file); err != nil {
+				fmt.Fprint(&buf, err)
+			}
+			m.Check()
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+			if !reflect.DeepEqual([]string(errs), tc.errs) {
+				t.Fatalf("wrong failures in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.errs, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build !windows
+// +build !windows
+
+package interp
+
+import "syscall"
+
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0666)
+}
+
+// pathExts returns the extensions that executables may have, which is
+// none as executables are marked by their permissions instead.
+func pathExts(env []string) []string { return nil }
+
+// envName returns the name that a variable from the environment has in
+// the shell.
+func envName(name string) string { return name }
@@ -0,0 +1,47 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"fmt"
+	"strings"
+)
+
+func mkfifo(path string) error {
+	return fmt.Errorf("process substitutions are not supported on Windows")
+}
+
+// pathExts returns the extensions that executables may have, as listed
+// in PATHEXT.
+func pathExts(env []string) []string {
+	list := ".com;.exe;.bat;.cmd"
+	for i := len(env) - 1; i >= 0; i-- {
+		if kv := env[i]; strings.HasPrefix(strings.ToUpper(kv), "PATHEXT=") {
+			list = kv[len("PATHEXT="):]
+			break
+		}
+	}
+	var exts []string
+	for _, ext := range strings.Split(strings.ToLower(list), ";") {
+		if ext == "" {
+			continue
+		}
+		if ext[0] != '.' {
+			ext = "." + ext
+		}
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// envName returns the name that a variable from the environment has in
+// the shell. Names are case-insensitive on Windows, where the list of
+// directories is often named Path, so it becomes PATH like in other
+// shells.
+func envName(name string) string {
+	if strings.ToUpper(name) == "PATH" {
+		return "PATH"
+	}
+	return name
+}
@@ -0,0 +1,384 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The kinds of backslash escapes, which differ in how octal escapes are
+// written and in whether \c stops the output.
+const (
+	formatEscapes = iota // in the format of printf, like \101
+	argEscapes           // in the arguments to printf %b, like \101 or \0101
+	echoEscapes          // in the arguments to echo -e, like \0101
+)
+
+// decodeEscapes decodes the backslash escapes in s. It also reports
+// whether a \c was found, which stops the output.
+func decodeEscapes(s string, kind int) (string, bool) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, false
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'e', 'E':
+			buf.WriteByte('\x1b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case '\\':
+			buf.WriteByte('\\')
+		case 'c':
+			if kind == formatEscapes {
+				buf.WriteString(`\c`)
+				break
+			}
+			return buf.String(), true
+		case 'x', 'u', 'U':
+			n, size := parseDigits(s[i+1:], 16, hexDigits(c))
+			if size == 0 {
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+				break
+			}
+			if c == 'x' {
+				buf.WriteByte(byte(n))
+			} else {
+				buf.WriteRune(rune(n))
+			}
+			i += size
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			digits := s[i:]
+			switch {
+			case kind != formatEscapes && c == '0':
+				// \0 is followed by up to three digits
+				digits = s[i+1:]
+				i++
+			case kind == echoEscapes:
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+				continue
+			}
+			n, size := parseDigits(digits, 8, 3)
+			buf.WriteByte(byte(n))
+			i += size - 1
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), false
+}
+
+// parseDigits parses up to max digits in a base at the start of s, and
+// returns the number along with how many digits there were.
+func parseDigits(s string, base, max int) (int, int) {
+	n, size := 0, 0
+	for size < max && size < len(s) {
+		d := strings.IndexByte("0123456789abcdef", lower(s[size]))
+		if d < 0 || d >= base {
+			break
+		}
+		n = n*base + d
+		size++
+	}
+	return n, size
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// echo implements the echo builtin. Like in Bash, backslash escapes are
+// only decoded with -e, unless XPGEcho is set.
+func (r *Runner) echo(args []string) {
+	newline, escapes := true, r.opts.XPGEcho
+	for len(args) > 0 {
+		arg := args[0]
+		// an argument like -x isn't a flag, but printed
+		if len(arg) < 2 || arg[0] != '-' || strings.Trim(arg[1:], "neE") != "" {
+			break
+		}
+		for _, c := range arg[1:] {
+			switch c {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+	s := strings.Join(args, " ")
+	if escapes {
+		var stop bool
+		if s, stop = decodeEscapes(s, echoEscapes); stop {
+			newline = false
+		}
+	}
+	if newline {
+		s += "\n"
+	}
+	r.outf("%s", s)
+}
+
+// printf implements the printf builtin. The format is reused until all
+// the arguments are consumed. With -v, the output is assigned to a
+// variable instead of printed.
+func (r *Runner) printf(args []string) int {
+	varName := ""
+	if len(args) > 1 && args[0] == "-v" {
+		varName, args = args[1], args[2:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		r.errf("printf: usage: printf [-v var] format [arguments]\n")
+		return 2
+	}
+	p := printer{format: args[0], args: args[1:]}
+	for {
+		used := len(p.args)
+		if p.formatOnce() {
+			break
+		}
+		// stop once all the arguments are consumed, or if the
+		// format doesn't consume any
+		if len(p.args) == 0 || len(p.args) == used {
+			break
+		}
+	}
+	for _, msg := range p.errs {
+		r.errf("printf: %s\n", msg)
+	}
+	if varName != "" {
+		if err := r.setVar(varName, p.buf.String()); err != nil {
+			r.errf("printf: %v\n", err)
+			return 1
+		}
+	} else {
+		r.outf("%s", p.buf.String())
+	}
+	if len(p.errs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printer formats the arguments to printf.
+type printer struct {
+	format string
+	args   []string
+
+	buf  bytes.Buffer
+	errs []string
+}
+
+// next returns the next argument, or an empty string if there are none
+// left.
+func (p *printer) next() string {
+	if len(p.args) == 0 {
+		return ""
+	}
+	arg := p.args[0]
+	p.args = p.args[1:]
+	return arg
+}
+
+// number parses the next argument as an integer. Like in other shells,
+// an argument starting with a quote is the code of the character after
+// it.
+func (p *printer) number() int64 {
+	arg := p.next()
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(arg[1:])
+		return int64(r)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(arg), 0, 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Sprintf("%s: invalid number", arg))
+	}
+	return n
+}
+
+func (p *printer) float() float64 {
+	arg := p.next()
+	if arg == "" {
+		return 0
+	}
+	if arg[0] == '\'' || arg[0] == '"' {
+		r, _ := utf8.DecodeRuneInString(arg[1:])
+		return float64(r)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Sprintf("%s: invalid number", arg))
+	}
+	return f
+}
+
+// formatOnce writes the format once, consuming the arguments that its
+// directives need. It reports whether the output must stop early.
+func (p *printer) formatOnce() bool {
+	format := p.format
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '\\' && i+1 < len(format):
+			end := i + 2 + escapeLen(format[i+1:])
+			s, _ := decodeEscapes(format[i:end], formatEscapes)
+			p.buf.WriteString(s)
+			i = end - 1
+		case c != '%':
+			p.buf.WriteByte(c)
+		case i+1 < len(format) && format[i+1] == '%':
+			p.buf.WriteByte('%')
+			i++
+		default:
+			var stop bool
+			i, stop = p.directive(format, i)
+			if stop {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// escapeLen returns how many bytes follow the first one in an escape
+// sequence like "101" or "x41", after the backslash.
+func escapeLen(s string) int {
+	switch s[0] {
+	case 'x', 'u', 'U':
+		_, n := parseDigits(s[1:], 16, hexDigits(s[0]))
+		return n
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		_, n := parseDigits(s, 8, 3)
+		return n - 1
+	}
+	return 0
+}
+
+// hexDigits returns the maximum number of digits in an escape sequence
+// like \x41, \u00e1 or \U0001f600.
+func hexDigits(c byte) int {
+	switch c {
+	case 'u':
+		return 4
+	case 'U':
+		return 8
+	}
+	return 2
+}
+
+// directive writes the directive like "%-5s" at the start of
+// format[start:], and returns the index of its last byte. It reports
+// whether the output must stop, because of a %b argument with \c or an
+// invalid directive.
+func (p *printer) directive(format string, start int) (int, bool) {
+	i := start + 1
+	var spec bytes.Buffer
+	spec.WriteByte('%')
+	for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+		spec.WriteByte(format[i])
+		i++
+	}
+	hasPrec := false
+	for i < len(format) {
+		c := format[i]
+		switch {
+		case c == '*':
+			spec.WriteString(strconv.FormatInt(p.number(), 10))
+		case c == '.':
+			hasPrec = true
+			spec.WriteByte(c)
+		case '0' <= c && c <= '9':
+			spec.WriteByte(c)
+		default:
+			goto verb
+		}
+		i++
+	}
+verb:
+	if i == len(format) {
+		p.errs = append(p.errs, fmt.Sprintf("%s: missing format character", format[start:]))
+		return i, true
+	}
+	verb := format[i]
+	switch verb {
+	case 's':
+		spec.WriteByte('s')
+		fmt.Fprintf(&p.buf, spec.String(), p.next())
+	case 'b':
+		s, stop := decodeEscapes(p.next(), argEscapes)
+		spec.WriteByte('s')
+		fmt.Fprintf(&p.buf, spec.String(), s)
+		return i, stop
+	case 'q':
+		spec.WriteByte('s')
+		fmt.Fprintf(&p.buf, spec.String(), quote(p.next()))
+	case 'c':
+		s := p.next()
+		if s != "" {
+			_, size := utf8.DecodeRuneInString(s)
+			s = s[:size]
+		}
+		spec.WriteByte('s')
+		fmt.Fprintf(&p.buf, spec.String(), s)
+	case 'd', 'i':
+		spec.WriteByte('d')
+		fmt.Fprintf(&p.buf, spec.String(), p.number())
+	case 'u', 'o', 'x', 'X':
+		// like in C, these print the number as unsigned
+		if verb == 'u' {
+			verb = 'd'
+		}
+		spec.WriteByte(verb)
+		fmt.Fprintf(&p.buf, spec.String(), uint64(p.number()))
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if !hasPrec && (verb == 'g' || verb == 'G') {
+			// Go's default precision is the smallest necessary,
+			// but it's 6 in C
+			spec.WriteString(".6")
+		}
+		spec.WriteByte(verb)
+		fmt.Fprintf(&p.buf, spec.String(), p.float())
+	default:
+		p.errs = append(p.errs, fmt.Sprintf("%%%c: invalid format character", verb))
+		return i, true
+	}
+	return i, false
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// procSubst is a process substitution like <(cmd) or >(cmd), whose
+// statements run in the background connected to a named pipe.
+type procSubst struct {
+	dir, path string
+
+	// opened is closed once the statements have opened their end of
+	// the pipe, and done once they have finished
+	opened, done chan struct{}
+}
+
+// procSubst starts the statements of a process substitution and returns
+// the path of the named pipe that the command reads from or writes to.
+// The pipe is removed once the statement that expanded it finishes.
+func (r *Runner) procSubst(ps *syntax.ProcSubst) (string, error) {
+	if r.DryRun != nil {
+		r.dryf(ps.Pos(), "skipped process substitution")
+		r.unknown = true
+		return os.DevNull, nil
+	}
+	dir, err := ioutil.TempDir("", "interp")
+	if err != nil {
+		return "", err
+	}
+	p := &procSubst{
+		dir:    dir,
+		path:   filepath.Join(dir, "fifo"),
+		opened: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := mkfifo(p.path); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	r2 := r.sub()
+	go func() {
+		defer close(p.done)
+		flag := os.O_WRONLY
+		if ps.Op == syntax.CmdOut {
+			flag = os.O_RDONLY
+		}
+		f, err := os.OpenFile(p.path, flag, 0)
+		close(p.opened)
+		if err != nil {
+			r2.errf("%v\n", err)
+			return
+		}
+		defer f.Close()
+		if ps.Op == syntax.CmdOut {
+			r2.stdin = f
+		} else {
+			// like background commands, don't read the stdin
+			// that the command may be reading from
+			r2.stdin, r2.stdout = nil, f
+		}
+		r2.stmts(ps.Stmts)
+		r2.closeKept()
+	}()
+	r.procSubsts = append(r.procSubsts, p)
+	return p.path, nil
+}
+
+// endProcSubsts waits for the process substitutions started after the
+// first n, and removes their named pipes.
+func (r *Runner) endProcSubsts(n int) {
+	for _, p := range r.procSubsts[n:] {
+		p.Close()
+	}
+	r.procSubsts = r.procSubsts[:n]
+}
+
+// Close waits for the statements to finish and removes the named pipe.
+func (p *procSubst) Close() error {
+	for {
+		select {
+		case <-p.opened:
+			<-p.done
+			return os.RemoveAll(p.dir)
+		default:
+		}
+		// The command didn't open the pipe, so the statements are
+		// still blocked opening their end of it. Opening the pipe for
+		// both reading and writing doesn't block, and closing it right
+		// away unblocks them. Retry in case they weren't blocked yet.
+		if f, err := os.OpenFile(p.path, os.O_RDWR, 0); err == nil {
+			f.Close()
+		}
+		select {
+		case <-p.opened:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Profile adds up the time spent by the commands of programs, by their
+// position, to find which parts of a slow program cost the most. It is
+// used by setting Runner.Profile, and it can be shared by many runners.
+type Profile struct {
+	mu      sync.Mutex
+	entries map[profileKey]*ProfileEntry
+}
+
+type profileKey struct {
+	filename string
+	pos      syntax.Pos
+}
+
+// ProfileEntry is the time spent by a command in the program, added up
+// for all the times that it ran.
+type ProfileEntry struct {
+	Filename string
+	Pos      syntax.Position
+
+	// Name is the name of the command, like "grep".
+	Name string
+
+	// Calls is the number of times that the command ran.
+	Calls int
+
+	// Wall is the real time that the command took. For functions, it
+	// includes the commands that they run.
+	Wall time.Duration
+
+	// Usage is the CPU time used by the programs that the command
+	// ran, if the ExecHandler reports it like DefaultExec does.
+	Usage
+}
+
+func (p *Profile) add(f *syntax.File, pos syntax.Pos, name string, wall time.Duration, usage Usage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries == nil {
+		p.entries = make(map[profileKey]*ProfileEntry)
+	}
+	key := profileKey{f.Name, pos}
+	e := p.entries[key]
+	if e == nil {
+		e = &ProfileEntry{Filename: f.Name, Pos: f.Position(pos), Name: name}
+		p.entries[key] = e
+	}
+	e.Calls++
+	e.Wall += wall
+	e.User += usage.User
+	e.System += usage.System
+}
+
+// Entries returns the commands that ran, the most costly first.
+func (p *Profile) Entries() []ProfileEntry {
+	p.mu.Lock()
+	entries := make([]ProfileEntry, 0, len(p.entries))
+	for _, e := range p.entries {
+		entries = append(entries, *e)
+	}
+	p.mu.Unlock()
+	sort.Sort(byWall(entries))
+	return entries
+}
+
+type byWall []ProfileEntry
+
+func (b byWall) Len() int      { return len(b) }
+func (b byWall) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byWall) Less(i, j int) bool {
+	if b[i].Wall != b[j].Wall {
+		return b[i].Wall > b[j].Wall
+	}
+	if b[i].Filename != b[j].Filename {
+		return b[i].Filename < b[j].Filename
+	}
+	return b[i].Pos.Offset < b[j].Pos.Offset
+}
+
+// WriteText writes the entries as a table, the most costly first.
+func (p *Profile) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "wall\tuser\tsys\tcalls\t\tcommand\n")
+	for _, e := range p.Entries() {
+		prefix := ""
+		if e.Filename != "" {
+			prefix = e.Filename + ":"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%d\t\t%s%d:%d: %s\n", e.Wall, e.User,
+			e.System, e.Calls, prefix, e.Pos.Line, e.Pos.Column, e.Name)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/mvdan/sh/expand"
+)
+
+// read implements the read builtin, which reads a line from the
+// standard input and splits it into fields as per IFS. Each variable is
+// set to a field, and the last one to the rest of the line.
+func (r *Runner) read(args []string) int {
+	raw, delim := false, byte('\n')
+	prompt, arrayName := "", ""
+opts:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := args[0]
+		args = args[1:]
+		if opt == "--" {
+			break
+		}
+		for j := 1; j < len(opt); j++ {
+			c := opt[j]
+			switch c {
+			case 'r':
+				raw = true
+				continue
+			case 'p', 'd', 'a':
+			default:
+				r.errf("read: invalid option: -%c\n", c)
+				return 2
+			}
+			// the value is the rest of the option, like -d: or
+			// the next argument
+			val := opt[j+1:]
+			if val == "" {
+				if len(args) == 0 {
+					r.errf("read: -%c: option requires an argument\n", c)
+					return 2
+				}
+				val, args = args[0], args[1:]
+			}
+			switch c {
+			case 'p':
+				prompt = val
+			case 'd':
+				delim = 0
+				if val != "" {
+					delim = val[0]
+				}
+			case 'a':
+				arrayName = val
+			}
+			continue opts
+		}
+	}
+	if prompt != "" {
+		r.errf("%s", prompt)
+	}
+	line, ok := r.readLine(delim, raw)
+	ifs, set := r.getParam("IFS")
+	if !set {
+		ifs = " \t\n"
+	}
+	var err error
+	switch {
+	case arrayName != "":
+		vr, _ := r.lookupVar(arrayName)
+		if vr.ReadOnly {
+			err = &expand.ReadOnlyError{Name: arrayName}
+			break
+		}
+		vr.Set, vr.Value, vr.List, vr.Map, vr.Indexes = true, "", expand.Fields(line, ifs), nil, nil
+		if vr.List == nil {
+			vr.List = []string{}
+		}
+		r.setVarFull(arrayName, vr)
+	case len(args) == 0:
+		// like in Bash, REPLY gets the entire line
+		err = r.setVar("REPLY", line)
+	default:
+		fields := splitRead(line, ifs, len(args))
+		for i, name := range args {
+			val := ""
+			if i < len(fields) {
+				val = fields[i]
+			}
+			if err = r.setVar(name, val); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		r.errf("read: %v\n", err)
+		return 1
+	}
+	return oneIf(!ok)
+}
+
+// readLine reads from the standard input until a delimiter, one byte at
+// a time so that no input past it is consumed. Unless raw is true, a
+// backslash quotes the next byte and a backslash-newline is removed.
+// It reports whether the delimiter was found before the end of the
+// input.
+func (r *Runner) readLine(delim byte, raw bool) (string, bool) {
+	if r.stdin == nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	b := make([]byte, 1)
+	escaped := false
+	for {
+		n, err := r.stdin.Read(b)
+		if n == 0 {
+			if err == nil {
+				continue
+			}
+			if err != io.EOF {
+				r.errf("read: %v\n", err)
+			}
+			return buf.String(), false
+		}
+		c := b[0]
+		switch {
+		case escaped:
+			escaped = false
+			if c != '\n' {
+				buf.WriteByte(c)
+			}
+		case c == delim:
+			return buf.String(), true
+		case c == '\\' && !raw:
+			escaped = true
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// splitRead splits a line read by read into at most n fields as per
+// ifs, where the last field is the rest of the line without the IFS
+// whitespace around it.
+func splitRead(line, ifs string, n int) []string {
+	isSpace := func(c rune) bool {
+		return strings.ContainsRune(ifs, c) && strings.ContainsRune(" \t\n", c)
+	}
+	var fields []string
+	s := strings.TrimLeftFunc(line, isSpace)
+	for len(fields) < n-1 && s != "" {
+		i := strings.IndexAny(s, ifs)
+		if i < 0 {
+			break
+		}
+		fields = append(fields, s[:i])
+		// skip the delimiter, along with the IFS whitespace around it
+		s = strings.TrimLeftFunc(s[i:], isSpace)
+		if s != "" && strings.ContainsRune(ifs, rune(s[0])) && !isSpace(rune(s[0])) {
+			s = strings.TrimLeftFunc(s[1:], isSpace)
+		}
+	}
+	if s = strings.TrimRightFunc(s, isSpace); s != "" {
+		fields = append(fields, s)
+	}
+	return fields
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"github.com/mvdan/sh/cond"
+	"github.com/mvdan/sh/syntax"
+)
+
+func (r *Runner) testClause(tc *syntax.TestClause) {
+	cfg := cond.Config{Expand: r.expandConfig(), FS: dirFS{r}}
+	ok, err := cfg.Eval(tc.X)
+	switch err.(type) {
+	case nil:
+		r.exit = oneIf(!ok)
+	case *cond.EvalError:
+		r.errf("%v\n", err)
+		r.exit = 2
+	default:
+		r.expandErr(tc.Pos(), err)
+	}
+}
+
+// testBuiltin implements the test and [ builtins, whose arguments are
+// already expanded.
+func (r *Runner) testBuiltin(name string, args []string) int {
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			r.errf("[: missing ]\n")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+	cfg := cond.Config{Expand: r.expandConfig(), FS: dirFS{r}}
+	ok, err := cfg.EvalArgs(args)
+	if err != nil {
+		r.errf("%s: %v\n", name, err)
+		return 2
+	}
+	return oneIf(!ok)
+}
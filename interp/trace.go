@@ -0,0 +1,94 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind uint8
+
+const (
+	TraceCmdStart TraceKind = iota // a simple command is about to run
+	TraceCmdEnd                    // a simple command finished
+	TraceExpand                    // a word was expanded
+	TraceRedirect                  // a redirect was applied
+)
+
+var traceKindNames = [...]string{
+	TraceCmdStart: "cmd-start",
+	TraceCmdEnd:   "cmd-end",
+	TraceExpand:   "expand",
+	TraceRedirect: "redirect",
+}
+
+func (k TraceKind) String() string {
+	if int(k) < len(traceKindNames) {
+		return traceKindNames[k]
+	}
+	return "unknown"
+}
+
+// TraceEvent describes a step of the execution of a program, as
+// reported to a TraceFunc.
+type TraceEvent struct {
+	Kind TraceKind
+
+	// Pos is the position of the node that the event is about.
+	Pos syntax.Pos
+
+	// Args are the fields of the command for TraceCmdStart and
+	// TraceCmdEnd, the fields that the word expanded to for
+	// TraceExpand, and the target that the redirect's word expanded
+	// to for TraceRedirect.
+	Args []string
+
+	// Word is the word that was expanded, for TraceExpand.
+	Word *syntax.Word
+
+	// Redirect is the redirect that was applied, for TraceRedirect.
+	Redirect *syntax.Redirect
+
+	// Exit is the exit status of the command, for TraceCmdEnd.
+	Exit int
+
+	// Elapsed is how long the command took to run, for TraceCmdEnd.
+	Elapsed time.Duration
+
+	// Err is the error that made a redirect fail, for TraceRedirect.
+	Err error
+}
+
+// TraceFunc receives the events of a program as it runs. As the
+// commands in a pipeline and background commands run concurrently, it
+// may be called from multiple goroutines at once.
+type TraceFunc func(ev TraceEvent)
+
+// tracing reports whether the events must be computed, either for Trace
+// or for xtrace.
+func (r *Runner) tracing() bool {
+	return r.Trace != nil || r.opts.XTrace
+}
+
+// trace sends an event to Trace, and writes the commands that are about
+// to run to the standard error if xtrace is enabled.
+func (r *Runner) trace(ev TraceEvent) {
+	if r.opts.XTrace && ev.Kind == TraceCmdStart {
+		var buf bytes.Buffer
+		buf.WriteString("+")
+		for _, arg := range ev.Args {
+			buf.WriteByte(' ')
+			buf.WriteString(quote(arg))
+		}
+		buf.WriteByte('\n')
+		r.stderr.Write(buf.Bytes())
+	}
+	if r.Trace != nil {
+		r.Trace(ev)
+	}
+}
@@ -0,0 +1,635 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package interp
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// lookupVar returns a variable that isn't a special parameter, looking
+// in the innermost function scopes first. It reports whether any scope
+// declares the variable, even if it's unset.
+func (r *Runner) lookupVar(name string) (expand.Variable, bool) {
+	for i := len(r.locals) - 1; i >= 0; i-- {
+		if vr, ok := r.locals[i][name]; ok {
+			return vr, true
+		}
+	}
+	vr, ok := r.vars[name]
+	return vr, ok
+}
+
+// getParam returns the value of a parameter, including special ones
+// like $? or $1, and whether it is set.
+func (r *Runner) getParam(name string) (string, bool) {
+	switch name {
+	case "#":
+		return strconv.Itoa(len(r.params)), true
+	case "@":
+		return strings.Join(r.params, " "), true
+	case "*":
+		// joined with the first character of $IFS
+		sep := " "
+		if ifs, ok := r.getParam("IFS"); ok {
+			sep = ifs
+			if _, size := utf8.DecodeRuneInString(ifs); size < len(ifs) {
+				sep = ifs[:size]
+			}
+		}
+		return strings.Join(r.params, sep), true
+	case "?":
+		return strconv.Itoa(r.exit), true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	case "0":
+		return r.file.Name, true
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(r.params) {
+			return "", false
+		}
+		return r.params[n-1], true
+	}
+	vr, _ := r.lookupVar(name)
+	return vr.String(), vr.Set
+}
+
+func (r *Runner) getVar(name string) string {
+	val, _ := r.getParam(name)
+	return val
+}
+
+// ownVars copies the variables if they are shared with other runners,
+// so that they can be modified.
+func (r *Runner) ownVars() {
+	if !r.varsShared {
+		return
+	}
+	vars := make(map[string]expand.Variable, len(r.vars))
+	for name, vr := range r.vars {
+		vars[name] = vr
+	}
+	// leave room for a function call without another copy
+	locals := make([]map[string]expand.Variable, len(r.locals), len(r.locals)+1)
+	for i, scope := range r.locals {
+		locals[i] = make(map[string]expand.Variable, len(scope))
+		for name, vr := range scope {
+			locals[i][name] = vr
+		}
+	}
+	r.vars, r.locals, r.varsShared = vars, locals, false
+}
+
+// ownFuncs is like ownVars, but for the functions.
+func (r *Runner) ownFuncs() {
+	if !r.funcsShared {
+		return
+	}
+	funcs := make(map[string]*syntax.Stmt, len(r.funcs))
+	for name, body := range r.funcs {
+		funcs[name] = body
+	}
+	r.funcs, r.funcsShared = funcs, false
+}
+
+// setVarFull sets a variable in the innermost scope that declares it,
+// or as a global variable otherwise.
+func (r *Runner) setVarFull(name string, vr expand.Variable) {
+	r.ownVars()
+	for i := len(r.locals) - 1; i >= 0; i-- {
+		if _, ok := r.locals[i][name]; ok {
+			r.locals[i][name] = vr
+			return
+		}
+	}
+	r.vars[name] = vr
+}
+
+// setVar sets the value of a variable, keeping its attributes. It fails
+// if the variable is read-only.
+func (r *Runner) setVar(name, value string) error {
+	vr, _ := r.lookupVar(name)
+	if vr.ReadOnly {
+		return &expand.ReadOnlyError{Name: name}
+	}
+	vr.Set, vr.Value, vr.List, vr.Map, vr.Indexes = true, value, nil, nil, nil
+	r.setVarFull(name, vr)
+	return nil
+}
+
+func (r *Runner) setLocal(name string, vr expand.Variable) {
+	r.ownVars()
+	r.locals[len(r.locals)-1][name] = vr
+}
+
+func (r *Runner) delVar(name string) {
+	r.ownVars()
+	for i := len(r.locals) - 1; i >= 0; i-- {
+		if _, ok := r.locals[i][name]; ok {
+			delete(r.locals[i], name)
+			return
+		}
+	}
+	delete(r.vars, name)
+}
+
+// allVars returns the variables that aren't special parameters, with
+// the ones in inner function scopes shadowing the outer ones.
+func (r *Runner) allVars() map[string]expand.Variable {
+	all := make(map[string]expand.Variable, len(r.vars))
+	for name, vr := range r.vars {
+		all[name] = vr
+	}
+	for _, scope := range r.locals {
+		for name, vr := range scope {
+			all[name] = vr
+		}
+	}
+	return all
+}
+
+// environ returns the exported variables in the form "key=value", as
+// passed to the programs that are run.
+func (r *Runner) environ() []string {
+	var env []string
+	for name, vr := range r.allVars() {
+		if exportable(vr) {
+			env = append(env, name+"="+vr.Value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+// isVarName reports whether name is a valid variable name, as opposed to
+// a special or positional parameter.
+func isVarName(name string) bool {
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// exportable reports whether a variable is passed on to programs. Like
+// in Bash, arrays are never exported.
+func exportable(vr expand.Variable) bool {
+	return vr.Set && vr.Exported && vr.List == nil && vr.Map == nil
+}
+
+func (r *Runner) assignValue(as *syntax.Assign) string {
+	if as.Value == nil {
+		return ""
+	}
+	return r.loneWord(as.Value)
+}
+
+// assign runs an assignment. If local is true, the variable is set in
+// the scope of the current function. It reports whether the variable
+// could be set, as it can't if it's readonly.
+func (r *Runner) assign(as *syntax.Assign, local bool) bool {
+	name, _, _ := splitIndex(as.Name.Value)
+	vr, _ := r.lookupVar(name)
+	if vr.ReadOnly {
+		r.errf("%v\n", &expand.ReadOnlyError{Name: name})
+		r.exit = 1
+		return false
+	}
+	vr = r.assignedVar(as, vr)
+	if local {
+		r.setLocal(name, vr)
+	} else {
+		r.setVarFull(name, vr)
+	}
+	return true
+}
+
+// splitIndex splits the name of an assignment like "a[1]=x" into the
+// name of the variable and the index, if any.
+func splitIndex(s string) (name, index string, ok bool) {
+	i := strings.IndexByte(s, '[')
+	if i < 0 || s[len(s)-1] != ']' {
+		return s, "", false
+	}
+	return s[:i], s[i+1 : len(s)-1], true
+}
+
+// assignedVar returns the variable that results from running an
+// assignment on the variable vr.
+func (r *Runner) assignedVar(as *syntax.Assign, vr expand.Variable) expand.Variable {
+	name, index, hasIndex := splitIndex(as.Name.Value)
+	if as.Value != nil && len(as.Value.Parts) == 1 {
+		if ae, ok := as.Value.Parts[0].(*syntax.ArrayExpr); ok {
+			return r.arrayVar(name, vr, ae, as.Append)
+		}
+	}
+	return r.assignedValue(vr, name, index, hasIndex, r.assignValue(as), as.Append)
+}
+
+// assignedValue returns the variable that results from assigning a
+// string to the variable vr, or to one of its elements if hasIndex is
+// true. If appnd is true, the string is appended to the old value.
+func (r *Runner) assignedValue(vr expand.Variable, name, index string, hasIndex bool, value string, appnd bool) expand.Variable {
+	if !hasIndex && vr.List == nil && vr.Map == nil {
+		if appnd {
+			value = vr.Value + value
+		}
+		vr.Set, vr.Value = true, value
+		return vr
+	}
+	if !hasIndex {
+		// like in Bash, "a=x" sets the first element of an array
+		index = "0"
+	}
+	if vr.Map != nil {
+		m := copyMap(vr.Map)
+		if appnd {
+			value = m[index] + value
+		}
+		m[index] = value
+		vr.Set, vr.Map = true, m
+		return vr
+	}
+	i := r.listIndex(index, vr)
+	if i < 0 {
+		r.errf("%s[%s]: bad array subscript\n", name, index)
+		r.exit = 1
+		return vr
+	}
+	if appnd {
+		old, _ := vr.Elem(i)
+		value = old + value
+	}
+	return vr.SetElem(i, value)
+}
+
+// arrayVar returns the variable that results from assigning an array
+// expression like "(x y)" to the variable vr. The elements are keys and
+// values like "[k]=v" if vr is an associative array.
+func (r *Runner) arrayVar(name string, vr expand.Variable, ae *syntax.ArrayExpr, appnd bool) expand.Variable {
+	vr.Set, vr.Value = true, ""
+	if vr.Map != nil {
+		m := make(map[string]string)
+		if appnd {
+			m = copyMap(vr.Map)
+		}
+		for _, w := range ae.List {
+			key, value, ok := r.keyedElem(w)
+			if !ok {
+				r.errf("%s: must use subscript when assigning associative array\n", name)
+				r.exit = 1
+				continue
+			}
+			m[key] = value
+		}
+		vr.Map = m
+		return vr
+	}
+	if !appnd {
+		vr.List, vr.Indexes = []string{}, nil
+	} else if vr.List == nil {
+		vr = vr.SetElem(0, vr.Value)
+	}
+	next := vr.MaxIndex() + 1
+	for _, w := range ae.List {
+		if key, value, ok := r.keyedElem(w); ok {
+			i := r.listIndex(key, vr)
+			if i < 0 {
+				r.errf("%s[%s]: bad array subscript\n", name, key)
+				r.exit = 1
+				continue
+			}
+			vr = vr.SetElem(i, value)
+			next = i + 1
+			continue
+		}
+		// elements without a key are split into fields
+		for _, field := range r.fields([]*syntax.Word{w}) {
+			vr = vr.SetElem(next, field)
+			next++
+		}
+	}
+	return vr
+}
+
+// keyedElem splits an array element like "[k]=v" into its key and its
+// value.
+func (r *Runner) keyedElem(w *syntax.Word) (key, value string, ok bool) {
+	lit, _ := w.Parts[0].(*syntax.Lit)
+	if lit == nil || !strings.HasPrefix(lit.Value, "[") {
+		return "", "", false
+	}
+	i := strings.Index(lit.Value, "]=")
+	if i < 0 {
+		return "", "", false
+	}
+	rest := &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{
+		ValuePos: lit.ValuePos + syntax.Pos(i+2),
+		ValueEnd: lit.ValueEnd,
+		Value:    lit.Value[i+2:],
+	}}}
+	rest.Parts = append(rest.Parts, w.Parts[1:]...)
+	return lit.Value[1:i], r.loneWord(rest), true
+}
+
+// listIndex returns the index of an element in the indexed array vr.
+// The index is an arithmetic expression like "1" or "i+1", and negative
+// indexes count from the end.
+func (r *Runner) listIndex(index string, vr expand.Variable) int {
+	i := r.arithm(&syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: index}}})
+	if i < 0 {
+		i += vr.MaxIndex() + 1
+	}
+	return i
+}
+
+// indexAssign splits a word like "m[$k]=v", which the parser doesn't
+// take as an assignment since its index has expansions, into the name
+// of the array, the index and the value.
+func indexAssign(w *syntax.Word) (name string, index, value *syntax.Word, appnd, ok bool) {
+	first, _ := w.Parts[0].(*syntax.Lit)
+	if first == nil || len(w.Parts) < 2 {
+		return "", nil, nil, false, false
+	}
+	i := strings.IndexByte(first.Value, '[')
+	if i < 1 || !isVarName(first.Value[:i]) || assignEnd(first.Value) >= 0 {
+		return "", nil, nil, false, false
+	}
+	index = &syntax.Word{}
+	if i+1 < len(first.Value) {
+		index.Parts = append(index.Parts, &syntax.Lit{Value: first.Value[i+1:]})
+	}
+	for j, wp := range w.Parts[1:] {
+		lit, _ := wp.(*syntax.Lit)
+		if lit == nil {
+			index.Parts = append(index.Parts, wp)
+			continue
+		}
+		k := assignEnd(lit.Value)
+		if k < 0 {
+			index.Parts = append(index.Parts, wp)
+			continue
+		}
+		if k > 0 {
+			index.Parts = append(index.Parts, &syntax.Lit{Value: lit.Value[:k]})
+		}
+		rest := lit.Value[k+2:]
+		if lit.Value[k+1] == '+' {
+			appnd, rest = true, lit.Value[k+3:]
+		}
+		value = &syntax.Word{}
+		if rest != "" {
+			value.Parts = append(value.Parts, &syntax.Lit{Value: rest})
+		}
+		value.Parts = append(value.Parts, w.Parts[j+2:]...)
+		return first.Value[:i], index, value, appnd, true
+	}
+	return "", nil, nil, false, false
+}
+
+// assignEnd returns the offset of the "]=" or "]+=" that ends the index
+// of an assignment like "a[i]=x", or -1 if there is none.
+func assignEnd(s string) int {
+	for i := strings.IndexByte(s, ']'); i >= 0 && i+1 < len(s); {
+		if s[i+1] == '=' || strings.HasPrefix(s[i+1:], "+=") {
+			return i
+		}
+		j := strings.IndexByte(s[i+1:], ']')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return -1
+}
+
+// assignIndex runs an assignment like "m[$k]=v", as split by
+// indexAssign. Like assign, it reports whether the variable could be
+// set.
+func (r *Runner) assignIndex(w *syntax.Word) bool {
+	name, index, value, appnd, _ := indexAssign(w)
+	vr, _ := r.lookupVar(name)
+	if vr.ReadOnly {
+		r.errf("%v\n", &expand.ReadOnlyError{Name: name})
+		r.exit = 1
+		return false
+	}
+	ind, val := r.loneWord(index), r.loneWord(value)
+	r.setVarFull(name, r.assignedValue(vr, name, ind, true, val, appnd))
+	return true
+}
+
+// onlyAssigns runs the assignments of a statement without a command,
+// like "a=1 m[$k]=v". As in Bash, an assignment to a readonly variable
+// stops the rest of them, and the shell too unless it's interactive.
+func (r *Runner) onlyAssigns(assigns []*syntax.Assign, words []*syntax.Word) {
+	r.exit = 0
+	ok := true
+	for _, as := range assigns {
+		if ok = r.assign(as, false); !ok {
+			break
+		}
+	}
+	for _, w := range words {
+		if !ok {
+			break
+		}
+		ok = r.assignIndex(w)
+	}
+	if !ok && !r.interactive {
+		r.exitShell = true
+	}
+}
+
+// unsetElem unsets an element of an array, like "unset 'a[1]'".
+func (r *Runner) unsetElem(name, index string) {
+	vr, found := r.lookupVar(name)
+	switch {
+	case !found:
+		return
+	case vr.ReadOnly:
+		r.errf("unset: %v\n", &expand.ReadOnlyError{Name: name})
+		r.exit = 1
+		return
+	case vr.Map != nil:
+		vr.Map = copyMap(vr.Map)
+		delete(vr.Map, index)
+	default:
+		i := r.listIndex(index, vr)
+		if i < 0 {
+			r.errf("unset: %s[%s]: bad array subscript\n", name, index)
+			r.exit = 1
+			return
+		}
+		vr = vr.UnsetElem(i)
+	}
+	r.setVarFull(name, vr)
+}
+
+// copyMap copies the elements of an associative array before modifying
+// them, as the array may be shared with other runners.
+func copyMap(m map[string]string) map[string]string {
+	m2 := make(map[string]string, len(m))
+	for k, v := range m {
+		m2[k] = v
+	}
+	return m2
+}
+
+func (r *Runner) declClause(x *syntax.DeclClause) {
+	local, attrs, ok := r.declOpts(x.Variant, r.fields(x.Opts))
+	if !ok {
+		return
+	}
+	for _, as := range x.Assigns {
+		if as.Name == nil {
+			// "export foo" or "local foo" without a value
+			r.declFields(local, attrs, r.fields([]*syntax.Word{as.Value}))
+			continue
+		}
+		name, _, _ := splitIndex(as.Name.Value)
+		r.declare(name, local, attrs, func(vr expand.Variable) expand.Variable {
+			return r.assignedVar(as, vr)
+		})
+	}
+}
+
+// declBuiltin runs a declaration like export or local whose name and
+// arguments come from expansions, which the parser doesn't see as a
+// declaration.
+func (r *Runner) declBuiltin(variant string, args []string) {
+	if variant == "declare" || variant == "typeset" {
+		variant = ""
+	}
+	i := 0
+	for i < len(args) && len(args[i]) > 1 && args[i][0] == '-' {
+		i++
+	}
+	local, attrs, ok := r.declOpts(variant, args[:i])
+	if ok {
+		r.declFields(local, attrs, args[i:])
+	}
+}
+
+// declOpts returns the scope and the attributes of a declaration, given
+// its variant and options. It reports whether the declaration can run.
+func (r *Runner) declOpts(variant string, opts []string) (bool, declAttrs, bool) {
+	// declare and typeset have an empty variant
+	local := variant == "local" || (len(r.funcStack) > 0 && variant == "")
+	if variant == "local" && len(r.funcStack) == 0 {
+		r.errf("local: can only be used in a function\n")
+		r.exit = 1
+		return false, declAttrs{}, false
+	}
+	attrs := declAttrs{
+		exported: variant == "export",
+		readOnly: variant == "readonly",
+	}
+	for _, opt := range opts {
+		if len(opt) < 2 || opt[0] != '-' {
+			continue
+		}
+		for _, c := range opt[1:] {
+			switch c {
+			case 'x':
+				attrs.exported = true
+			case 'r':
+				attrs.readOnly = true
+			case 'a':
+				attrs.array = true
+			case 'A':
+				attrs.assoc = true
+			}
+		}
+	}
+	r.exit = 0
+	return local, attrs, true
+}
+
+// declFields declares the variables in fields like "foo" or "foo=bar".
+func (r *Runner) declFields(local bool, attrs declAttrs, fields []string) {
+	for _, field := range fields {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			r.declare(field, local, attrs, nil)
+			continue
+		}
+		name, value := field[:i], field[i+1:]
+		r.declare(name, local, attrs, func(vr expand.Variable) expand.Variable {
+			return r.assignedValue(vr, name, "", false, value, false)
+		})
+	}
+}
+
+// declAttrs are the attributes that a declaration like export or
+// declare -r adds to variables.
+type declAttrs struct {
+	exported, readOnly bool
+
+	// array and assoc are set by -a and -A, which make the variable an
+	// indexed or an associative array
+	array, assoc bool
+}
+
+// declare runs a declaration of a variable. If assign isn't nil, it
+// returns the variable that results from the assignment in the
+// declaration.
+func (r *Runner) declare(name string, local bool, attrs declAttrs, assign func(expand.Variable) expand.Variable) {
+	vr, found := r.lookupVar(name)
+	if local {
+		vr, found = r.locals[len(r.locals)-1][name]
+	}
+	if vr.ReadOnly && (assign != nil || !attrs.readOnly) {
+		r.errf("%v\n", &expand.ReadOnlyError{Name: name})
+		r.exit = 1
+		return
+	}
+	if assign == nil && !found && !local && !attrs.exported &&
+		!attrs.readOnly && !attrs.array && !attrs.assoc {
+		return
+	}
+	switch {
+	case attrs.assoc && vr.List != nil:
+		r.errf("%s: cannot convert indexed to associative array\n", name)
+		r.exit = 1
+		return
+	case attrs.assoc && vr.Map == nil:
+		vr.Map = make(map[string]string)
+		if vr.Set {
+			vr.Map["0"] = vr.Value
+		}
+		vr.Value = ""
+	case attrs.array && vr.List == nil && vr.Map == nil:
+		if vr.Set {
+			vr = vr.SetElem(0, vr.Value)
+		} else {
+			vr.List = []string{}
+		}
+	}
+	if assign != nil {
+		vr = assign(vr)
+	}
+	if attrs.exported {
+		vr.Exported = true
+	}
+	if attrs.readOnly {
+		vr.ReadOnly = true
+	}
+	if local {
+		r.setLocal(name, vr)
+	} else {
+		r.setVarFull(name, vr)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package launch
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// CronConfig holds how crontabs are parsed.
+type CronConfig struct {
+	// System is set for system crontabs like /etc/crontab, whose
+	// entries have the user to run the command as before it.
+	System bool
+
+	// Mode is the mode used to parse the commands. Commands run by
+	// sh or dash are always parsed as POSIX shell.
+	Mode syntax.ParseMode
+}
+
+// CronEntry is a job in a crontab.
+type CronEntry struct {
+	// Line is the line of the entry in the crontab, starting at 1.
+	Line int
+
+	// Schedule are the five time fields of the entry, like
+	// ["*/5", "*", "*", "*", "1-5"], or a single one like "@daily".
+	Schedule []string
+
+	// User is the user that the command runs as, in system crontabs.
+	User string
+
+	// Shell is the name of the shell that runs the command, set by
+	// the last SHELL variable before the entry, or "sh".
+	Shell string
+
+	// Command is the shell program of the entry, which is the text up
+	// to the first % that isn't escaped with a backslash, and Stdin is
+	// the text after it, given to the program as its standard input
+	// with each other % as a newline.
+	Command, Stdin string
+
+	// File is the parsed Command. Its positions are relative to it;
+	// use Position to map them to the crontab.
+	File *syntax.File
+
+	// offset and column are where Command starts in the crontab,
+	// and escapes holds the offsets in Command where a \% became a %.
+	offset, column int
+	escapes        []int
+}
+
+// Position returns the position in the crontab of a position in the
+// entry's command.
+func (e *CronEntry) Position(pos syntax.Pos) syntax.Position {
+	return e.outer(e.File.Position(pos))
+}
+
+func (e *CronEntry) outer(pos syntax.Position) syntax.Position {
+	col := pos.Column
+	for _, off := range e.escapes {
+		if off < pos.Column {
+			col++
+		}
+	}
+	return syntax.Position{
+		Offset: e.offset + col - 1,
+		Line:   e.Line,
+		Column: e.column + col - 1,
+	}
+}
+
+var cronFields = [...]struct {
+	name     string
+	min, max int
+	names    []string
+}{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun",
+		"jul", "aug", "sep", "oct", "nov", "dec"}},
+	{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronSpecial = map[string]bool{
+	"@reboot": true, "@yearly": true, "@annually": true, "@monthly": true,
+	"@weekly": true, "@daily": true, "@midnight": true, "@hourly": true,
+}
+
+// ParseCrontab parses the entries of a crontab, like:
+//
+//	SHELL=/bin/bash
+//	*/5 * * * * cd /srv && ./backup.sh >>backup.log 2>&1
+//	@daily mail -s report admin%Done.%
+//
+// Like in cron, the blank lines and the ones starting with # are
+// skipped, and the lines with variables like SHELL=/bin/bash set the
+// environment of the entries after them. Their time fields are
+// validated, and their commands parsed.
+//
+// Errors have the filename and line of the entry, and the errors of
+// parsing a command are a *syntax.ParseError with its position in the
+// crontab.
+func (c CronConfig) ParseCrontab(filename string, src []byte) ([]*CronEntry, error) {
+	var entries []*CronEntry
+	shell := "sh"
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	line, offset := 0, 0
+	for sc.Scan() {
+		text := sc.Text()
+		line++
+		start := offset
+		offset += len(text) + 1
+		trimmed := strings.TrimLeft(text, " \t")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if name, value, ok := cronVariable(trimmed); ok {
+			if name == "SHELL" {
+				shell = path.Base(value)
+			}
+			continue
+		}
+		indent := len(text) - len(trimmed)
+		e, err := c.parseEntry(trimmed, start+indent, indent+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+		e.Line = line
+		e.Shell = shell
+		if e.File, err = c.parseCommand(e); err != nil {
+			if perr, ok := err.(*syntax.ParseError); ok {
+				perr.Filename = filename
+			}
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// cronVariable returns the variable set by a crontab line like
+// "MAILTO=admin", if it is one. Its value may be quoted.
+func cronVariable(text string) (name, value string, ok bool) {
+	i := strings.IndexByte(text, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	// jobs like "0 * * * * a=b" have spaces before the =
+	name = strings.TrimSpace(text[:i])
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false
+	}
+	value = strings.TrimSpace(text[i+1:])
+	if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return name, value, true
+}
+
+// parseEntry parses the fields of an entry, which starts at an offset
+// and column of its line.
+func (c CronConfig) parseEntry(text string, offset, column int) (*CronEntry, error) {
+	e := &CronEntry{}
+	rest := text
+	field := func() string {
+		rest = strings.TrimLeft(rest, " \t")
+		i := strings.IndexAny(rest, " \t")
+		if i < 0 {
+			i = len(rest)
+		}
+		f := rest[:i]
+		rest = rest[i:]
+		return f
+	}
+	if strings.HasPrefix(text, "@") {
+		f := field()
+		if !cronSpecial[f] {
+			return nil, fmt.Errorf("unknown schedule %q", f)
+		}
+		e.Schedule = []string{f}
+	} else {
+		for _, cf := range cronFields {
+			f := field()
+			if f == "" {
+				return nil, fmt.Errorf("missing %s field", cf.name)
+			}
+			if err := validCronField(f, cf.min, cf.max, cf.names); err != nil {
+				return nil, fmt.Errorf("invalid %s field %q: %v", cf.name, f, err)
+			}
+			e.Schedule = append(e.Schedule, f)
+		}
+	}
+	if c.System {
+		if e.User = field(); e.User == "" {
+			return nil, fmt.Errorf("missing user field")
+		}
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	if rest == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+	skipped := len(text) - len(rest)
+	e.offset, e.column = offset+skipped, column+skipped
+	var cmd bytes.Buffer
+	for i := 0; i < len(rest); i++ {
+		b := rest[i]
+		if b == '\\' && i+1 < len(rest) && rest[i+1] == '%' {
+			e.escapes = append(e.escapes, cmd.Len())
+			cmd.WriteByte('%')
+			i++
+			continue
+		}
+		if b == '%' {
+			e.Stdin = strings.Replace(rest[i+1:], "%", "\n", -1)
+			break
+		}
+		cmd.WriteByte(b)
+	}
+	e.Command = cmd.String()
+	return e, nil
+}
+
+func (c CronConfig) parseCommand(e *CronEntry) (*syntax.File, error) {
+	mode := c.Mode
+	if e.Shell == "sh" || e.Shell == "dash" {
+		mode |= syntax.PosixConformant
+	}
+	f, err := syntax.Parse([]byte(e.Command), "", mode)
+	if err != nil {
+		if perr, ok := err.(*syntax.ParseError); ok {
+			perr.Position = e.outer(perr.Position)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// validCronField checks a time field of a crontab entry, which is a
+// list of values like "1,5-10,*/15".
+func validCronField(field string, min, max int, names []string) error {
+	for _, item := range strings.Split(field, ",") {
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			step, err := strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return fmt.Errorf("invalid step %q", item[i+1:])
+			}
+			item = item[:i]
+		}
+		if item == "*" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		var vals [2]int
+		for j, b := range bounds {
+			n, err := cronValue(b, min, names)
+			if err != nil {
+				return err
+			}
+			if n < min || n > max {
+				return fmt.Errorf("%d is out of range %d-%d", n, min, max)
+			}
+			vals[j] = n
+		}
+		if len(bounds) == 2 && vals[0] > vals[1] {
+			return fmt.Errorf("range %s goes backwards", item)
+		}
+	}
+	return nil
+}
+
+// cronValue returns the number in a time field, or the one of a name
+// like "jan" or "mon".
+func cronValue(s string, min int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package launch
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestParseCrontab(t *testing.T) {
+	t.Parallel()
+	src := `# backups
+MAILTO = "admin"
+*/5 * * * 1-5 cd /srv && ./backup.sh >>backup.log 2>&1
+
+SHELL=/bin/bash
+@daily mail -s "50\% done" admin%Done.%Bye
+0 4 1 jan,jul sun [[ -f x ]] && a=b rm x
+`
+	entries, err := CronConfig{}.ParseCrontab("crontab", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		line           int
+		schedule       []string
+		shell, command string
+		stdin          string
+	}
+	var got []entry
+	for _, e := range entries {
+		got = append(got, entry{e.Line, e.Schedule, e.Shell, e.Command, e.Stdin})
+	}
+	want := []entry{
+		{3, []string{"*/5", "*", "*", "*", "1-5"}, "sh", "cd /srv && ./backup.sh >>backup.log 2>&1", ""},
+		{6, []string{"@daily"}, "bash", `mail -s "50% done" admin`, "Done.\nBye"},
+		{7, []string{"0", "4", "1", "jan,jul", "sun"}, "bash", "[[ -f x ]] && a=b rm x", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseCrontab mismatch:\nwant: %+v\ngot:  %+v", want, got)
+	}
+	// the position of admin, after an escaped %
+	e := entries[1]
+	w := e.File.Stmts[0].Cmd.(*syntax.CallExpr).Args[3]
+	if got, want := e.Position(w.Pos()), (syntax.Position{Offset: 126, Line: 6, Column: 28}); got != want {
+		t.Fatalf("wrong position:\nwant: %+v\ngot:  %+v", want, got)
+	}
+}
+
+func TestParseCrontabErrors(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		c        CronConfig
+		src, err string
+	}{
+		{CronConfig{}, "* * * *", "crontab:1: missing day of week field"},
+		{CronConfig{}, "60 * * * * foo", `crontab:1: invalid minute field "60": 60 is out of range 0-59`},
+		{CronConfig{}, "* 5-2 * * * foo", `crontab:1: invalid hour field "5-2": range 5-2 goes backwards`},
+		{CronConfig{}, "*/0 * * * * foo", `crontab:1: invalid minute field "*/0": invalid step "0"`},
+		{CronConfig{}, "* * * foo * bar", `crontab:1: invalid month field "foo": invalid value "foo"`},
+		{CronConfig{}, "@often foo", `crontab:1: unknown schedule "@often"`},
+		{CronConfig{}, "@daily", "crontab:1: missing command"},
+		{CronConfig{System: true}, "@daily", "crontab:1: missing user field"},
+		{CronConfig{System: true}, "\n  @daily root echo 'x", "crontab:2:20: reached EOF without closing quote '"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, err := tc.c.ParseCrontab("crontab", []byte(tc.src))
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("ParseCrontab error mismatch in %q:\nwant: %s\ngot:  %v", tc.src, tc.err, err)
+			}
+		})
+	}
+}
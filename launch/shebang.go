@@ -0,0 +1,116 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package launch parses the ways of launching shell programs that live
+// outside of the programs themselves, like the shebang lines of scripts
+// and the commands of crontabs, so that schedulers and launchers can
+// validate them.
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mvdan/sh/lint"
+)
+
+// Shebang is the line at the start of a script that says which
+// interpreter runs it, like "#!/bin/sh -e".
+type Shebang struct {
+	// Interpreter is the path of the program that runs the script,
+	// like "/bin/bash" or "/usr/bin/env".
+	Interpreter string
+
+	// Args are the arguments given to Interpreter before the path of
+	// the script. Like Linux does, all the text after Interpreter is
+	// a single argument.
+	Args []string
+
+	// Shell is the name of the shell that runs the script, like
+	// "bash", directly or via env. It's empty if the interpreter isn't
+	// a known shell.
+	Shell string
+
+	// Options are the arguments given to the shell before the path of
+	// the script, like ["-eu"] in "#!/usr/bin/env -S bash -eu".
+	Options []string
+}
+
+// ParseShebang parses the shebang line at the start of a source. It
+// returns an error if there isn't one, or if the interpreter would get
+// its arguments wrong. For example, in "#!/usr/bin/env bash -e" env
+// gets "bash -e" as the name of the program to run, as Linux doesn't
+// split the arguments of a shebang; env -S must be used to split them.
+func ParseShebang(src []byte) (*Shebang, error) {
+	if !bytes.HasPrefix(src, []byte("#!")) {
+		return nil, fmt.Errorf("no #! shebang line")
+	}
+	line := src[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	rest := strings.TrimSpace(string(line))
+	if rest == "" {
+		return nil, fmt.Errorf("shebang without an interpreter")
+	}
+	sb := &Shebang{Interpreter: rest}
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		sb.Interpreter = rest[:i]
+		sb.Args = []string{strings.TrimSpace(rest[i:])}
+	}
+	name, opts := path.Base(sb.Interpreter), sb.Args
+	if name == "env" {
+		var err error
+		if name, opts, err = envCommand(sb.Args); err != nil {
+			return nil, err
+		}
+		name = path.Base(name)
+	}
+	if name == "sh" || lint.LookupDialect(name) != nil {
+		sb.Shell = name
+		if len(opts) > 0 {
+			sb.Options = opts
+		}
+		if len(opts) == 1 && strings.HasPrefix(opts[0], "-") && strings.ContainsAny(opts[0], " \t") {
+			return nil, fmt.Errorf("%s gets %q as a single option; use a single option like -eu, or env -S to split them",
+				name, opts[0])
+		}
+	}
+	return sb, nil
+}
+
+// envCommand returns the program that env runs in a shebang, along with
+// its arguments.
+func envCommand(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("env in a shebang without a program to run")
+	}
+	arg := args[0]
+	var words []string
+	switch {
+	case strings.HasPrefix(arg, "-S"):
+		words = strings.Fields(arg[2:])
+	case strings.HasPrefix(arg, "--split-string="):
+		words = strings.Fields(arg[len("--split-string="):])
+	case strings.ContainsAny(arg, " \t"):
+		return "", nil, fmt.Errorf("env gets %q as the name of a single program; use env -S to split it", arg)
+	default:
+		words = []string{arg}
+	}
+	// skip the options of env and the variables it sets
+	for len(words) > 0 && (strings.HasPrefix(words[0], "-") || strings.Contains(words[0], "=")) {
+		switch words[0] {
+		case "-u", "--unset", "-C", "--chdir":
+			if len(words) > 1 {
+				words = words[1:]
+			}
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return "", nil, fmt.Errorf("env in a shebang without a program to run")
+	}
+	return words[0], words[1:], nil
+}
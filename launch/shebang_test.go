@@ -0,0 +1,52 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package launch
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseShebang(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in      string
+		want    *Shebang
+		wantErr string
+	}{
+		{"#!/bin/sh\necho", &Shebang{Interpreter: "/bin/sh", Shell: "sh"}, ""},
+		{"#! /bin/bash -e\n", &Shebang{Interpreter: "/bin/bash", Args: []string{"-e"},
+			Shell: "bash", Options: []string{"-e"}}, ""},
+		{"#!/usr/bin/env bash", &Shebang{Interpreter: "/usr/bin/env", Args: []string{"bash"},
+			Shell: "bash"}, ""},
+		{"#!/usr/bin/env -S bash -eu", &Shebang{Interpreter: "/usr/bin/env", Args: []string{"-S bash -eu"},
+			Shell: "bash", Options: []string{"-eu"}}, ""},
+		{"#!/usr/bin/env -S -i PATH=/bin bash -e", &Shebang{Interpreter: "/usr/bin/env",
+			Args: []string{"-S -i PATH=/bin bash -e"}, Shell: "bash", Options: []string{"-e"}}, ""},
+		{"#!/usr/bin/python3 -u", &Shebang{Interpreter: "/usr/bin/python3", Args: []string{"-u"}}, ""},
+		{"echo", nil, "no #! shebang line"},
+		{"#!\n", nil, "shebang without an interpreter"},
+		{"#!/usr/bin/env", nil, "env in a shebang without a program to run"},
+		{"#!/usr/bin/env bash -e", nil, `env gets "bash -e" as the name of a single program; use env -S to split it`},
+		{"#!/bin/bash -e -u", nil, `bash gets "-e -u" as a single option; use a single option like -eu, or env -S to split them`},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := ParseShebang([]byte(tc.in))
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("ParseShebang error mismatch in %q:\nwant: %s\ngot:  %v", tc.in, tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("ParseShebang mismatch in %q:\nwant: %+v\ngot:  %+v", tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"github.com/mvdan/sh/syntax"
+)
+
+// CmdLoop reports loops and arrays over the fields of unquoted command
+// substitutions.
+var CmdLoop = &Analyzer{
+	Name: "cmdloop",
+	Doc: `report iterating over the output of a command split into fields
+
+In "for f in $(ls)" or "arr=($(cmd))", the output of the command is
+split at any whitespace and each field is expanded as a glob, so lines
+with spaces or "*" break. Globs like "for f in *" list files safely,
+and "while IFS= read -r line; do ...; done < <(cmd)" or mapfile read
+whole lines.`,
+	Code:       "SH1004",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2045", "SC2044", "SC2207"},
+	Run:        runCmdLoop,
+}
+
+func init() { Register(CmdLoop) }
+
+func runCmdLoop(pass *Pass) {
+	syntax.Walk(cmdLoopVisitor{pass}, pass.File)
+}
+
+type cmdLoopVisitor struct {
+	pass *Pass
+}
+
+func (v cmdLoopVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.WordIter:
+		for _, w := range x.List {
+			v.words(w, false)
+		}
+	case *syntax.ArrayExpr:
+		for _, w := range x.List {
+			v.words(w, true)
+		}
+	}
+	return v
+}
+
+func (v cmdLoopVisitor) words(w *syntax.Word, array bool) {
+	for _, part := range w.Parts {
+		cs, ok := part.(*syntax.CmdSubst)
+		if !ok {
+			continue
+		}
+		name := substName(cs)
+		switch {
+		case name == "ls":
+			v.pass.Reportf(cs, "iterating over the output of ls breaks on names with spaces or globs; use a glob like * instead")
+		case name == "find":
+			v.pass.Reportf(cs, "iterating over the output of find breaks on names with spaces or globs; use find -exec, or find -print0 with while IFS= read -r -d ''")
+		case array:
+			v.pass.Reportf(cs, "the output of the command is split at whitespace and globbed; use mapfile -t or a while IFS= read -r loop")
+		default:
+			v.pass.Reportf(cs, "iterating over the output of the command splits it at whitespace and globs each field; use a while IFS= read -r loop")
+		}
+	}
+}
+
+// substName returns the name of the first command run by a command
+// substitution, like "ls" in "$(ls | sort)".
+func substName(cs *syntax.CmdSubst) string {
+	if len(cs.Stmts) == 0 {
+		return ""
+	}
+	cmd := cs.Stmts[0].Cmd
+	for {
+		bc, ok := cmd.(*syntax.BinaryCmd)
+		if !ok || (bc.Op != syntax.Pipe && bc.Op != syntax.PipeAll) {
+			break
+		}
+		cmd = bc.X.Cmd
+	}
+	ce, ok := cmd.(*syntax.CallExpr)
+	if !ok || len(ce.Args) == 0 {
+		return ""
+	}
+	name, _ := syntax.StaticValue(ce.Args[0])
+	return name
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCmdLoop(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{`for f in *.txt "$(cmd)"; do :; done; arr=("$(cmd)" a)`, nil},
+		{"for f in $(ls *.txt); do :; done", []string{
+			"1:10: warning: iterating over the output of ls breaks on names with spaces or globs; use a glob like * instead (SH1004)",
+		}},
+		{"for f in `find . | sort`; do :; done", []string{
+			"1:10: warning: iterating over the output of find breaks on names with spaces or globs; use find -exec, or find -print0 with while IFS= read -r -d '' (SH1004)",
+		}},
+		{"for x in a $(cmd); do :; done", []string{
+			"1:12: warning: iterating over the output of the command splits it at whitespace and globs each field; use a while IFS= read -r loop (SH1004)",
+		}},
+		{"arr=($(cmd))", []string{
+			"1:6: warning: the output of the command is split at whitespace and globbed; use mapfile -t or a while IFS= read -r loop (SH1004)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, CmdLoop)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Command reports calls to commands that don't exist.
+var Command = &Analyzer{
+	Name: "command",
+	Doc: `report calls to commands that don't exist
+
+The names of the commands called in a file are checked against the
+known commands, such as the executables in the $PATH of the image that
+the file runs in, and typos like "gerp" are reported along with the
+closest command. Builtins, functions and aliases are known too, and so
+are commands checked for with "command -v" and similar.
+
+The analyzer does nothing unless the known commands are given, via the
+Commands field of the Linter.`,
+	Code:     "SH1017",
+	Severity: Error,
+	Run:      runCommand,
+}
+
+func init() { Register(Command) }
+
+// ReadCommands reads a manifest of command names, one per line. Empty
+// lines and those starting with "#" are skipped.
+func ReadCommands(r io.Reader) ([]string, error) {
+	var names []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && line[0] != '#' {
+			names = append(names, line)
+		}
+	}
+	return names, sc.Err()
+}
+
+// PathCommands returns the names of the executables in the directories
+// of a list like $PATH.
+func PathCommands(path string) []string {
+	var names []string
+	for _, dir := range filepath.SplitList(path) {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			if !info.IsDir() && info.Mode()&0111 != 0 {
+				names = append(names, info.Name())
+			}
+		}
+	}
+	return names
+}
+
+// cmdWrappers are the commands that run the command in their first
+// argument that isn't an option, along with whether they may take
+// options
+var cmdWrappers = map[string]bool{
+	"command": true,
+	"exec":    true,
+	"nohup":   false,
+	"env":     false,
+	"sudo":    false,
+}
+
+func runCommand(pass *Pass) {
+	if pass.Commands == nil {
+		return
+	}
+	known := make(map[string]bool)
+	for _, name := range pass.Commands {
+		known[name] = true
+	}
+	files := []*syntax.File{pass.File}
+	if pass.Program != nil {
+		files = pass.Program.Files
+	}
+	for _, f := range files {
+		defined(f, known)
+	}
+	for _, c := range pass.Funcs().Calls {
+		if c.Kind == analysis.FuncCall || c.Kind == analysis.UndefinedCall {
+			continue
+		}
+		name, args := c.Name, c.Expr.Args
+		for args != nil {
+			opts, ok := cmdWrappers[name]
+			if !ok || !known[name] && !analysis.IsBuiltin(name) {
+				break
+			}
+			if args = wrappedArgs(args[1:], opts); args != nil {
+				name = wordValue(args[0])
+			}
+		}
+		if args == nil || strings.Contains(name, "/") ||
+			known[name] || analysis.IsBuiltin(name) {
+			continue
+		}
+		if sugg := closest(name, known); sugg != "" {
+			pass.Reportf(args[0], "command not found: %s; did you mean %s?", name, sugg)
+		} else {
+			pass.Reportf(args[0], "command not found: %s", name)
+		}
+	}
+}
+
+// wrappedArgs returns the arguments of the command run by a wrapper
+// like exec, or nil if there isn't one or it can't be found.
+func wrappedArgs(args []*syntax.Word, opts bool) []*syntax.Word {
+	for i, w := range args {
+		value := wordValue(w)
+		switch {
+		case value == "":
+			return nil
+		case value[0] == '-':
+			if !opts || value == "-v" || value == "-V" {
+				return nil
+			}
+		case strings.Contains(value, "="):
+			// env assignments
+		default:
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+// defined adds the names of the commands that a file defines or checks
+// for, like functions and aliases, to a set.
+func defined(f *syntax.File, known map[string]bool) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.FuncDecl:
+			known[x.Name.Value] = true
+		case *syntax.CallExpr:
+			if len(x.Args) == 0 {
+				break
+			}
+			switch wordValue(x.Args[0]) {
+			case "alias":
+				for _, w := range x.Args[1:] {
+					value := wordValue(w)
+					if i := strings.IndexByte(value, '='); i > 0 {
+						known[value[:i]] = true
+					}
+				}
+			case "command", "type", "which", "hash":
+				for _, w := range x.Args[1:] {
+					known[wordValue(w)] = true
+				}
+			}
+		}
+		return true
+	}), f)
+}
+
+// closest returns the known command with the closest name to a name,
+// if any is close enough to be a typo.
+func closest(name string, known map[string]bool) string {
+	max := 1
+	if len(name) > 4 {
+		max = 2
+	}
+	var names []string
+	for k := range known {
+		names = append(names, k)
+	}
+	names = append(names, analysis.Builtins()...)
+	sort.Strings(names)
+	best, bestDist := "", max+1
+	for _, k := range names {
+		if d := editDistance(name, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of single-byte insertions, deletions,
+// substitutions or transpositions of adjacent bytes that turn a into b.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := prev[j-1] + cost
+			if prev[j]+1 < d {
+				d = prev[j] + 1
+			}
+			if cur[j-1]+1 < d {
+				d = cur[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] &&
+				prev2[j-2]+1 < d {
+				d = prev2[j-2] + 1
+			}
+			cur[j] = d
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
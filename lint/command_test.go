@@ -0,0 +1,71 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+	known := []string{"grep", "sed", "sudo", "env", "docker"}
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"grep a | sed b; echo; ./run; $cmd; f() { :; }; f", nil},
+		{"gerp foo", []string{
+			"1:1: error: command not found: gerp; did you mean grep? (SH1017)",
+		}},
+		{"dokcer run; sde", []string{
+			"1:1: error: command not found: dokcer; did you mean docker? (SH1017)",
+			"1:13: error: command not found: sde; did you mean sed? (SH1017)",
+		}},
+		{"curl x", []string{
+			"1:1: error: command not found: curl (SH1017)",
+		}},
+		{"exec gerp; sudo dcoker; command -v jq && jq; env A=b gnu", []string{
+			"1:6: error: command not found: gerp; did you mean grep? (SH1017)",
+			"1:17: error: command not found: dcoker; did you mean docker? (SH1017)",
+			"1:54: error: command not found: gnu (SH1017)",
+		}},
+		{"alias ll='ls -l'; ll; nohup x", []string{
+			"1:23: error: command not found: nohup (SH1017)",
+		}},
+		{"ecoh hi", []string{
+			"1:1: error: command not found: ecoh; did you mean echo? (SH1017)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f := parse(t, tc.in)
+			l := &Linter{Analyzers: []*Analyzer{Command}, Commands: known}
+			var got []string
+			for _, d := range l.Lint(f) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+	if got := lintStrings(t, "gerp", Command); got != nil {
+		t.Fatalf("unexpected diagnostics without commands: %q", got)
+	}
+}
+
+func TestReadCommands(t *testing.T) {
+	t.Parallel()
+	got, err := ReadCommands(strings.NewReader("# tools\ngrep\n\n  sed \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"grep", "sed"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadCommands mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Limits are the largest metrics allowed by the complexity analyzer. A
+// zero limit isn't checked.
+type Limits struct {
+	Complexity int // cyclomatic complexity of a function
+	Depth      int // nesting depth within a function
+	Stmts      int // statements in a function
+	Pipeline   int // commands in a pipeline
+}
+
+// DefaultLimits are the limits used by Complexity.
+var DefaultLimits = Limits{Complexity: 15, Depth: 5, Stmts: 100, Pipeline: 8}
+
+// Complexity reports functions and pipelines beyond DefaultLimits.
+var Complexity = NewComplexity(DefaultLimits)
+
+func init() { Register(Complexity) }
+
+// NewComplexity returns a complexity analyzer with custom limits. It
+// isn't registered.
+func NewComplexity(limits Limits) *Analyzer {
+	return &Analyzer{
+		Name: "complexity",
+		Doc: `report functions and pipelines that are too complex
+
+Functions are measured by their cyclomatic complexity, how deep their
+if, case and loops nest, and how many statements they have. The top
+level of a file is measured as if it was a function. Pipelines are
+measured by the number of commands in them.`,
+		Code:     "SH1015",
+		Severity: Warning,
+		Run: func(pass *Pass) {
+			runComplexity(pass, limits)
+		},
+	}
+}
+
+func runComplexity(pass *Pass, limits Limits) {
+	top, fms := analysis.MeasureFuncs(pass.File, pass.Funcs())
+	check := func(node syntax.Node, what string, m analysis.Metrics) {
+		over := func(name string, value, limit int) {
+			if limit > 0 && value > limit {
+				pass.Reportf(node, "%s has %s %d, over the limit of %d",
+					what, name, value, limit)
+			}
+		}
+		over("a cyclomatic complexity of", m.Complexity, limits.Complexity)
+		over("a nesting depth of", m.Depth, limits.Depth)
+		over("a statement count of", m.Stmts, limits.Stmts)
+	}
+	if len(pass.File.Stmts) > 0 {
+		check(pass.File.Stmts[0], "the top level", top)
+	}
+	for _, fm := range fms {
+		check(fm.Func.Decl.Name, fmt.Sprintf("function %s", fm.Func.Name), fm.Metrics)
+	}
+	if limits.Pipeline <= 0 {
+		return
+	}
+	syntax.Walk(pipeVisitor(func(s *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) {
+		if len(stmts) > limits.Pipeline {
+			pass.Reportf(s, "pipeline has %d commands, over the limit of %d",
+				len(stmts), limits.Pipeline)
+		}
+	}), pass.File)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestComplexity(t *testing.T) {
+	t.Parallel()
+	a := NewComplexity(Limits{Complexity: 3, Depth: 2, Stmts: 7, Pipeline: 2})
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"f() { a || b; }\na | b", nil},
+		{"foo | >f; <<EOF | b\nfoo\nEOF", nil},
+		{"f() {\n\ta && b && c || d\n}", []string{
+			"1:1: warning: function f has a cyclomatic complexity of 4, over the limit of 3 (SH1015)",
+			"1:1: warning: function f has a statement count of 8, over the limit of 7 (SH1015)",
+		}},
+		{"echo\nif a; then while b; do for i in 1; do :; done; done; fi", []string{
+			"1:1: warning: the top level has a cyclomatic complexity of 4, over the limit of 3 (SH1015)",
+			"1:1: warning: the top level has a nesting depth of 3, over the limit of 2 (SH1015)",
+		}},
+		{"echo $(a | b | c)", []string{
+			"1:8: warning: pipeline has 3 commands, over the limit of 2 (SH1015)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, a)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
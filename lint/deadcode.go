@@ -0,0 +1,236 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strconv"
+
+	"github.com/mvdan/sh/cfg"
+	"github.com/mvdan/sh/cond"
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/pattern"
+	"github.com/mvdan/sh/syntax"
+)
+
+// DeadCode reports code that never runs.
+var DeadCode = &Analyzer{
+	Name: "deadcode",
+	Doc: `report code that never runs
+
+Statements after an unconditional exit, return, exec, break or continue
+are never run, and neither are case patterns that only match what an
+earlier pattern already matched, such as any pattern after "*)". The
+conditions of if clauses that don't depend on anything, like "true" or
+"[ a = b ]", make one of the branches dead.`,
+	Code:       "SH1016",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2317", "SC2221", "SC2222", "SC2050"},
+	Run:        runDeadCode,
+}
+
+func init() { Register(DeadCode) }
+
+func runDeadCode(pass *Pass) {
+	unreachable(pass, cfg.File(pass.File))
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.FuncDecl:
+			unreachable(pass, cfg.Func(x))
+		case *syntax.CaseClause:
+			shadowedPatterns(pass, x)
+		case *syntax.IfClause:
+			constCond(pass, x.CondStmts, x.ElseStmts != nil || len(x.Elifs) > 0)
+			for i, elif := range x.Elifs {
+				constCond(pass, elif.CondStmts, x.ElseStmts != nil || i+1 < len(x.Elifs))
+			}
+		}
+		return true
+	}), pass.File)
+}
+
+// unreachable reports the first statement of each run of unreachable
+// statements in a graph.
+func unreachable(pass *Pass, g *cfg.Graph) {
+	reach := g.Reachable()
+	var last *syntax.Stmt
+	for _, n := range g.Nodes {
+		if n.Stmt == nil || reach[n.Index] {
+			continue
+		}
+		if last != nil && n.Stmt.Pos() < last.End() {
+			continue // within the last one
+		}
+		last = n.Stmt
+		follows := false
+		for _, pred := range n.Preds {
+			if !reach[pred.Index] && pred.Stmt != nil && pred.Stmt.Pos() < n.Stmt.Pos() {
+				follows = true
+			}
+		}
+		if !follows {
+			pass.Reportf(n.Stmt, "unreachable code")
+		}
+	}
+}
+
+// shadowedPatterns reports the patterns of a case clause that can only
+// match strings that an earlier pattern matches first.
+func shadowedPatterns(pass *Pass, cc *syntax.CaseClause) {
+	type earlier struct {
+		word *syntax.Word
+		pat  string
+	}
+	var prev []earlier
+	for _, pl := range cc.List {
+		var cur []earlier
+		for _, w := range pl.Patterns {
+			value, ok := syntax.StaticValue(w)
+			if !ok {
+				continue
+			}
+			pat, err := expand.Config{}.Pattern(w)
+			if err != nil {
+				continue
+			}
+			for _, e := range prev {
+				if e.pat == "*" || e.pat == pat ||
+					(!pattern.HasMeta(pat, pattern.ExtGlob) &&
+						pattern.Match(e.pat, value, pattern.ExtGlob)) {
+					pos := pass.File.Position(e.word.Pos())
+					pass.Reportf(w, "pattern %s never matches, as %s at %d:%d matches first",
+						sourceText(pass.File, w), sourceText(pass.File, e.word),
+						pos.Line, pos.Column)
+					break
+				}
+			}
+			cur = append(cur, earlier{w, pat})
+		}
+		// with ;;&, the following patterns are tested too
+		if pl.Op != syntax.DblSemiFall {
+			prev = append(prev, cur...)
+		}
+	}
+}
+
+// constCond reports a condition that is always true or false.
+func constCond(pass *Pass, stmts []*syntax.Stmt, hasElse bool) {
+	if len(stmts) != 1 {
+		return
+	}
+	s := stmts[0]
+	if s.Background || len(s.Assigns) > 0 || len(s.Redirs) > 0 {
+		return
+	}
+	b, ok := constStatus(s.Cmd)
+	if !ok {
+		return
+	}
+	if s.Negated {
+		b = !b
+	}
+	switch {
+	case !b:
+		pass.Reportf(s, "condition is always false, so its branch never runs")
+	case hasElse:
+		pass.Reportf(s, "condition is always true, so the following branches never run")
+	default:
+		pass.Reportf(s, "condition is always true")
+	}
+}
+
+// constStatus returns whether a command always succeeds, if it doesn't
+// depend on anything like variables or files.
+func constStatus(cmd syntax.Command) (bool, bool) {
+	switch x := cmd.(type) {
+	case *syntax.CallExpr:
+		if len(x.Args) == 0 {
+			return false, false
+		}
+		args := make([]string, len(x.Args))
+		for i, w := range x.Args {
+			s, ok := syntax.StaticValue(w)
+			if !ok {
+				return false, false
+			}
+			args[i] = s
+		}
+		switch args[0] {
+		case "true", ":":
+			return true, true
+		case "false":
+			return false, true
+		case "[":
+			if args[len(args)-1] != "]" {
+				return false, false
+			}
+			args = args[:len(args)-1]
+		case "test":
+		default:
+			return false, false
+		}
+		args = args[1:]
+		for _, arg := range args {
+			switch arg {
+			case "-n", "-z", "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+			default:
+				if len(arg) == 2 && arg[0] == '-' {
+					// file tests, and -a and -o which may be
+					// ones too
+					return false, false
+				}
+			}
+		}
+		b, err := cond.Config{}.EvalArgs(args)
+		return b, err == nil
+	case *syntax.TestClause:
+		if !constTest(x.X) {
+			return false, false
+		}
+		b, err := cond.Config{}.Eval(x.X)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// constTest reports whether a [[ ]] expression doesn't depend on
+// anything, like variables or files.
+func constTest(expr syntax.TestExpr) bool {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		_, ok := syntax.StaticValue(x)
+		return ok
+	case *syntax.ParenTest:
+		return constTest(x.X)
+	case *syntax.UnaryTest:
+		switch x.Op {
+		case syntax.TsNot, syntax.TsEmpStr, syntax.TsNempStr:
+			return constTest(x.X)
+		}
+	case *syntax.BinaryTest:
+		switch x.Op {
+		case syntax.TsNewer, syntax.TsOlder, syntax.TsDevIno:
+			return false
+		case syntax.TsEql, syntax.TsNeq, syntax.TsLeq, syntax.TsGeq,
+			syntax.TsLss, syntax.TsGtr:
+			// the operands are arithmetic, where names are
+			// variables
+			return constInt(x.X) && constInt(x.Y)
+		}
+		return constTest(x.X) && constTest(x.Y)
+	}
+	return false
+}
+
+func constInt(expr syntax.TestExpr) bool {
+	w, ok := expr.(*syntax.Word)
+	if !ok {
+		return false
+	}
+	s, ok := syntax.StaticValue(w)
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
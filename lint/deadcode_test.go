@@ -0,0 +1,59 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDeadCode(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"a; if b; then exit; fi; c; exec >f; d", nil},
+		{"exit 1\na\nif b; then c; fi", []string{
+			"2:1: warning: unreachable code (SH1016)",
+		}},
+		{"f() {\n\treturn\n\ta\n}\nexec cmd\nb", []string{
+			"3:2: warning: unreachable code (SH1016)",
+			"6:1: warning: unreachable code (SH1016)",
+		}},
+		{"for i; do break; a; done", []string{
+			"1:18: warning: unreachable code (SH1016)",
+		}},
+		{"case $x in a|b) ;; c*) ;; cd|a) ;; *) ;; e) ;; esac", []string{
+			"1:27: warning: pattern cd never matches, as c* at 1:20 matches first (SH1016)",
+			"1:30: warning: pattern a never matches, as a at 1:12 matches first (SH1016)",
+			"1:42: warning: pattern e never matches, as * at 1:36 matches first (SH1016)",
+		}},
+		{`case $x in "*") ;; '*'|*) ;; $y) ;; esac`, []string{
+			"1:20: warning: pattern '*' never matches, as \"*\" at 1:12 matches first (SH1016)",
+		}},
+		{"case $x in a) ;;& a) ;; esac", nil},
+		{`if [ "$x" = a ]; then :; elif [[ -f a ]]; then :; elif [ -f a ]; then :; fi`, nil},
+		{"if true; then a; fi", []string{
+			"1:4: warning: condition is always true (SH1016)",
+		}},
+		{"if ! [ a = b ]; then a; else b; fi", []string{
+			"1:4: warning: condition is always true, so the following branches never run (SH1016)",
+		}},
+		{"if a; then :; elif [[ 1 -gt 2 || -z a ]]; then :; fi", []string{
+			"1:20: warning: condition is always false, so its branch never runs (SH1016)",
+		}},
+		{"if [[ x -gt 2 ]]; then :; fi", nil},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, DeadCode)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
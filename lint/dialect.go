@@ -0,0 +1,170 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// Feature is a set of shell features beyond POSIX.
+type Feature uint64
+
+const (
+	Arrays          Feature = 1 << iota // arr=(a b) and ${arr[i]}
+	AssocArrays                         // declare -A
+	TestClause                          // [[ ]]
+	ArithmCmd                           // (( ))
+	CStyleLoop                          // for ((;;))
+	ProcSubst                           // <( ) and >( )
+	HereString                          // <<<
+	DollarSglQuote                      // $'...'
+	DollarDblQuote                      // $"..."
+	ExtGlob                             // @( ) and friends
+	FuncKeyword                         // function f
+	Local                               // local
+	Declare                             // declare
+	Typeset                             // typeset
+	Nameref                             // nameref and declare -n
+	GlobalDecl                          // declare -g
+	RedirAll                            // &>
+	AppendAll                           // &>>
+	PipeAll                             // |&
+	CaseFallthrough                     // ;& and ;;&
+	Coproc                              // coproc
+	Substring                           // ${x:1:2}
+	Replace                             // ${x/a/b}
+	CaseExp                             // ${x^^} and ${x,,}
+	Let                                 // let
+	AppendAssign                        // x+=y
+	Source                              // source
+	Mapfile                             // mapfile and readarray
+	EchoN                               // echo -n
+	EchoE                               // echo -e and -E
+	Indirect                            // ${!x}
+	BraceExp                            // {a,b} and {1..3}
+	Select                              // select
+	Shopt                               // shopt
+)
+
+var featureNames = [...]string{
+	"arrays",
+	"associative arrays",
+	"[[ ]]",
+	"(( ))",
+	"C-style for loops",
+	"process substitutions",
+	"here-strings",
+	"$'' quotes",
+	"$\"\" quotes",
+	"extended globs",
+	"the function keyword",
+	"local",
+	"declare",
+	"typeset",
+	"namerefs",
+	"declare -g",
+	"&>",
+	"&>>",
+	"|&",
+	"case fallthrough",
+	"coproc",
+	"substring expansions",
+	"replace expansions",
+	"case conversion expansions",
+	"let",
+	"+=",
+	"source",
+	"mapfile",
+	"echo -n",
+	"echo -e",
+	"indirect expansions",
+	"brace expansions",
+	"select",
+	"shopt",
+}
+
+func (f Feature) String() string {
+	var names []string
+	for i, name := range featureNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Dialect is a shell that programs may be written for.
+type Dialect struct {
+	// Name is the name used in diagnostics, like "bash 4.4".
+	Name string
+
+	// Features are the features the shell supports beyond POSIX.
+	Features Feature
+}
+
+// Supports reports whether the shell supports all of a set of features.
+func (d *Dialect) Supports(f Feature) bool { return d.Features&f == f }
+
+const bash32 = Arrays | TestClause | ArithmCmd | CStyleLoop | ProcSubst |
+	HereString | DollarSglQuote | DollarDblQuote | ExtGlob | FuncKeyword |
+	Local | Declare | Typeset | RedirAll | Substring | Replace | Let |
+	AppendAssign | Source | EchoN | EchoE | Indirect | BraceExp | Select |
+	Shopt
+
+var (
+	POSIX = &Dialect{Name: "POSIX sh"}
+	Dash  = &Dialect{Name: "dash", Features: Local | EchoN}
+
+	Bash32 = &Dialect{Name: "bash 3.2", Features: bash32}
+	Bash44 = &Dialect{Name: "bash 4.4", Features: bash32 | AssocArrays |
+		Nameref | GlobalDecl | AppendAll | PipeAll | CaseFallthrough |
+		Coproc | CaseExp | Mapfile}
+
+	Mksh = &Dialect{Name: "mksh", Features: Arrays | TestClause | ArithmCmd |
+		HereString | DollarSglQuote | ExtGlob | FuncKeyword | Local |
+		Typeset | Nameref | RedirAll | Substring | Replace | Let |
+		AppendAssign | Source | EchoN | EchoE | BraceExp | Select}
+)
+
+var dialects = map[string]*Dialect{
+	"posix":   POSIX,
+	"sh":      POSIX,
+	"dash":    Dash,
+	"bash3.2": Bash32,
+	"bash4.4": Bash44,
+	"bash":    Bash44,
+	"mksh":    Mksh,
+	"ksh":     Mksh,
+}
+
+// LookupDialect returns the dialect with a name like "posix", "dash",
+// "bash3.2", "bash4.4" or "mksh", or nil if there isn't one. "sh" is
+// POSIX, and "bash" is the latest bash.
+func LookupDialect(name string) *Dialect {
+	return dialects[name]
+}
+
+// ShebangDialect returns the dialect of the interpreter in a shebang
+// line at the start of a source, like "#!/bin/sh" or "#!/usr/bin/env
+// bash", or nil if there isn't one or it's not a known shell.
+func ShebangDialect(src []byte) *Dialect {
+	if !bytes.HasPrefix(src, []byte("#!")) {
+		return nil
+	}
+	line := src[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return nil
+	}
+	name := path.Base(fields[0])
+	if name == "env" && len(fields) > 1 {
+		name = fields[1]
+	}
+	return dialects[name]
+}
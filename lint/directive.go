@@ -0,0 +1,100 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// directiveMarkers are the markers of the comments that disable
+// analyzers.
+var directiveMarkers = []string{"sh-lint", "shellcheck"}
+
+// disabled is a directive disabling some analyzers between two offsets.
+type disabled struct {
+	rules       []string
+	offset, end int
+}
+
+// disabledRules returns the analyzers disabled by the directives in a
+// file.
+func disabledRules(f *syntax.File) []disabled {
+	var list []disabled
+	dirs := analysis.DirectiveConfig{Markers: directiveMarkers}.Directives(f)
+	for _, dir := range dirs {
+		var rules []string
+		for _, field := range strings.Fields(dir.Text) {
+			if value := strings.TrimPrefix(field, "disable="); value != field {
+				rules = append(rules, strings.Split(value, ",")...)
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		d := disabled{rules: rules, end: len(f.Source)}
+		switch {
+		case len(f.Stmts) > 0 && dir.Stmt == f.Stmts[0] &&
+			dir.Comment.Pos() < f.Stmts[0].Pos():
+			// before the first statement, so it applies to the
+			// whole file
+		case dir.Stmt != nil:
+			d.offset, d.end = int(dir.Stmt.Pos())-1, int(dir.Stmt.End())-1
+		default:
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+// suppress returns the diagnostics that aren't disabled by directives.
+func suppress(f *syntax.File, analyzers []*Analyzer, diags []Diagnostic) []Diagnostic {
+	list := disabledRules(f)
+	if len(list) == 0 {
+		return diags
+	}
+	byName := make(map[string]*Analyzer, len(analyzers))
+	for _, a := range analyzers {
+		byName[a.Name] = a
+	}
+	var kept []Diagnostic
+	for _, d := range diags {
+		off := d.Pos.Offset
+		keep := true
+		for _, dis := range list {
+			if off >= dis.offset && off < dis.end && matchesRule(byName[d.Analyzer], d, dis.rules) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+func matchesRule(a *Analyzer, d Diagnostic, rules []string) bool {
+	for _, rule := range rules {
+		switch rule {
+		case "all", d.Analyzer, d.Code:
+			return true
+		}
+		if a == nil {
+			continue
+		}
+		if rule == a.Group {
+			return true
+		}
+		for _, code := range a.ShellCheck {
+			if rule == code {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"x=1\necho $x $y", []string{
+			"2:6: warning: unquoted $x is split and globbed; quote it like \"$x\" (SH1001)",
+			"2:9: warning: unquoted $y is split and globbed; quote it like \"$y\" (SH1001)",
+			"2:10: warning: y is used but never assigned (SH1002)",
+		}},
+		{"x=1\n# shellcheck disable=SC2086\necho $x $y\necho $x", []string{
+			"3:10: warning: y is used but never assigned (SH1002)",
+			"4:6: warning: unquoted $x is split and globbed; quote it like \"$x\" (SH1001)",
+		}},
+		{"x=1\necho $x $y # sh-lint: disable=quote,SH1002\necho $x", []string{
+			"3:6: warning: unquoted $x is split and globbed; quote it like \"$x\" (SH1001)",
+		}},
+		{"#!/bin/bash\n# shellcheck disable=SC2154\n\nx=1\necho \"$y\"\nf() {\n\techo \"$z\"\n}", nil},
+		{"x=1\n# sh-lint: disable=all\nif true; then\n\techo $x $y\nfi\necho $z", []string{
+			"6:6: warning: unquoted $z is split and globbed; quote it like \"$z\" (SH1001)",
+			"6:7: warning: z is used but never assigned (SH1002)",
+		}},
+		{"x=1\n# shellcheck source=lib.sh\necho $x", []string{
+			"3:6: warning: unquoted $x is split and globbed; quote it like \"$x\" (SH1001)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Quote, Undefined)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
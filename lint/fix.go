@@ -0,0 +1,141 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// TextEdit replaces the source between two byte offsets.
+type TextEdit struct {
+	Offset, End int
+	New         string
+}
+
+// TextEdits returns the changes to the source of a file that apply the
+// fix. It returns nil if the fix can't be applied, such as when Old has
+// a heredoc, which isn't contiguous.
+func (f *Fix) TextEdits() []TextEdit {
+	if f.Edits != nil {
+		return f.Edits
+	}
+	if f.Old == nil || f.New == nil || hasHeredoc(f.Old) {
+		return nil
+	}
+	end := f.Old.End()
+	newNode := f.New
+	if s, ok := f.Old.(*syntax.Stmt); ok {
+		// keep the separator in the source, so that it's not
+		// printed twice
+		old := *s
+		old.SemiPos = 0
+		end = old.End()
+		if ns, ok := newNode.(*syntax.Stmt); ok && ns.Background {
+			cp := *ns
+			cp.Background = false
+			newNode = &cp
+		}
+	}
+	return []TextEdit{{
+		Offset: int(f.Old.Pos()) - 1,
+		End:    int(end) - 1,
+		New:    printNode(newNode),
+	}}
+}
+
+func hasHeredoc(node syntax.Node) bool {
+	found := false
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		if r, ok := node.(*syntax.Redirect); ok && r.Hdoc != nil {
+			found = true
+		}
+		return !found
+	}), node)
+	return found
+}
+
+type byOffset []TextEdit
+
+func (b byOffset) Len() int           { return len(b) }
+func (b byOffset) Less(i, j int) bool { return b[i].Offset < b[j].Offset }
+func (b byOffset) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// ApplyEdits returns the source with the edits applied. The edits may
+// be in any order, but must not overlap.
+func ApplyEdits(src []byte, edits []TextEdit) ([]byte, error) {
+	edits = append([]TextEdit(nil), edits...)
+	sort.Stable(byOffset(edits))
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.Offset < last || e.End < e.Offset || e.End > len(src) {
+			return nil, fmt.Errorf("invalid or overlapping edit at offset %d", e.Offset)
+		}
+		buf.Write(src[last:e.Offset])
+		buf.WriteString(e.New)
+		last = e.End
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// ApplyFixes returns the source with the fixes of the diagnostics
+// applied, along with the diagnostics that weren't fixed. Fixes that
+// overlap with an earlier one are skipped, so linting the result again
+// may find more. Diagnostics about source that a fix replaced are
+// dropped, as that source is gone.
+func ApplyFixes(src []byte, diags []Diagnostic) ([]byte, []Diagnostic) {
+	var edits []TextEdit
+	var unfixed []Diagnostic
+	for _, d := range diags {
+		var fedits []TextEdit
+		if d.Fix != nil {
+			fedits = d.Fix.TextEdits()
+		}
+		if fedits == nil || overlaps(edits, fedits) {
+			unfixed = append(unfixed, d)
+			continue
+		}
+		edits = append(edits, fedits...)
+	}
+	res, err := ApplyEdits(src, edits)
+	if err != nil {
+		// a fix with overlapping edits of its own
+		return src, diags
+	}
+	var rest []Diagnostic
+	for _, d := range unfixed {
+		if !replaced(edits, d) {
+			rest = append(rest, d)
+		}
+	}
+	return res, rest
+}
+
+// replaced reports whether any of the edits replaces source within the
+// span of a diagnostic.
+func replaced(edits []TextEdit, d Diagnostic) bool {
+	for _, e := range edits {
+		if e.Offset < d.End.Offset && d.Pos.Offset < e.End {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(edits, more []TextEdit) bool {
+	for _, e1 := range edits {
+		for _, e2 := range more {
+			if e1.Offset < e2.End && e2.Offset < e1.End ||
+				e1.Offset == e2.Offset {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestApplyFixes(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+		rest     int
+	}{
+		{"cat f | grep x\n", "grep x <f\n", 0},
+		{"a; cat f | grep x; b\n", "a; grep x <f; b\n", 0},
+		{"cat f | grep x &\nwait\n", "grep x <f &\nwait\n", 0},
+		{"if true; then\n\tn=$(grep x file | wc -l)\nfi\n", "if true; then\n\tn=$(grep -c x file)\nfi\n", 0},
+		{"ls | wc -l\n", "find . -mindepth 1 -maxdepth 1 ! -name '.*' -printf . | wc -c\n", 0},
+		{"echo $x ${y}z $(date)\n", "echo \"$x\" \"${y}\"z \"$(date)\"\n", 0},
+		{"echo $x$y\n", "echo \"$x\"$y\n", 1},
+		// the outer fix replaces the source of the inner diagnostics
+		{"echo $(cat f | grep x)\n", "cat f | grep x\n", 0},
+		{"echo $(date)\n", "date\n", 0},
+		{"x=$(echo $(date))\n", "x=$(date)\n", 0},
+		{"echo \"$(cat <<EOF\nx\nEOF\n)\"\n", "echo \"$(cat <<EOF\nx\nEOF\n)\"\n", 1},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f := parse(t, tc.in)
+			l := &Linter{Analyzers: append(Group("useless"), Quote)}
+			got, rest := ApplyFixes(f.Source, l.Lint(f))
+			if string(got) != tc.want {
+				t.Fatalf("wrong output in %q:\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+			if len(rest) != tc.rest {
+				t.Fatalf("want %d diagnostics left in %q, got %v", tc.rest, tc.in, rest)
+			}
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	t.Parallel()
+	src := []byte("foo bar baz")
+	got, err := ApplyEdits(src, []TextEdit{
+		{Offset: 8, End: 11, New: "qux"},
+		{Offset: 0, End: 3, New: "x"},
+		{Offset: 4, End: 4, New: "new "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x new bar qux"; string(got) != want {
+		t.Fatalf("wrong output:\nwant: %q\ngot:  %q", want, got)
+	}
+	if _, err := ApplyEdits(src, []TextEdit{{0, 5, ""}, {4, 6, ""}}); err == nil {
+		t.Fatalf("expected an error with overlapping edits")
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package lint implements a linter for shell programs, which runs
+// analyzers over parsed files and reports their diagnostics.
+//
+// Analyzers are added via Register, usually from an init function, so
+// that the linter runs them by default. Each analyzer receives a Pass
+// with the file and the indexes of its variables and functions, which
+// are built once and shared by all the analyzers.
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	Info    Severity = iota // a suggestion, like a simpler form
+	Warning                 // likely a bug
+	Error                   // a bug, or a construct that can't work
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	}
+	return "error"
+}
+
+// Analyzer is a check run over files.
+type Analyzer struct {
+	// Name identifies the analyzer, like "quote".
+	Name string
+
+	// Group optionally names a group of related analyzers that are
+	// run together, like "security".
+	Group string
+
+	// Doc describes what the analyzer reports, starting with a short
+	// summary line.
+	Doc string
+
+	// Code identifies the diagnostics reported via Reportf, like
+	// "SH1001".
+	Code string
+
+	// Severity is the severity of the diagnostics reported via
+	// Reportf.
+	Severity Severity
+
+	// ShellCheck are the codes of the ShellCheck warnings that the
+	// analyzer replaces, like "SC2086", so that the directives that
+	// disable them also disable the analyzer.
+	ShellCheck []string
+
+	// Run reports the diagnostics for the file in the pass.
+	Run func(pass *Pass)
+}
+
+// Pass is an analyzer being run over a file.
+type Pass struct {
+	Analyzer *Analyzer
+	File     *syntax.File
+
+	// Program is the program that the file is part of, along with the
+	// files it sources, or nil if they weren't loaded.
+	Program *loader.Program
+
+	// Dialect is the shell the file is written for, or nil if it
+	// should be found from its shebang.
+	Dialect *Dialect
+
+	// Commands are the names of the commands that exist where the file
+	// runs, or nil if they aren't known.
+	Commands []string
+
+	// shared holds the indexes built so far, which all the passes
+	// over a file share
+	shared *shared
+
+	diags []Diagnostic
+}
+
+type shared struct {
+	varsOnce, funcsOnce, taintOnce sync.Once
+
+	vars  []*analysis.VarRef
+	funcs *analysis.FuncTable
+	taint []*analysis.TaintFlow
+}
+
+// Vars returns the references to variables in the file, in the order
+// they appear, as returned by analysis.Vars.
+func (p *Pass) Vars() []*analysis.VarRef {
+	p.shared.varsOnce.Do(func() { p.shared.vars = analysis.Vars(p.File) })
+	return p.shared.vars
+}
+
+// Funcs returns the functions and call sites in the file, as returned by
+// analysis.Funcs.
+func (p *Pass) Funcs() *analysis.FuncTable {
+	p.shared.funcsOnce.Do(func() { p.shared.funcs = analysis.Funcs(p.File) })
+	return p.shared.funcs
+}
+
+// Taint returns where untrusted data reaches a sink like eval in the
+// file, as returned by analysis.Taint.
+func (p *Pass) Taint() []*analysis.TaintFlow {
+	p.shared.taintOnce.Do(func() { p.shared.taint = analysis.Taint(p.File) })
+	return p.shared.taint
+}
+
+// Report reports a diagnostic. If its code is empty, the code and
+// severity of the analyzer are used.
+func (p *Pass) Report(d Diagnostic) {
+	if d.Analyzer == "" {
+		d.Analyzer = p.Analyzer.Name
+	}
+	if d.Code == "" {
+		d.Code, d.Severity = p.Analyzer.Code, p.Analyzer.Severity
+	}
+	d.Filename = p.File.Name
+	p.diags = append(p.diags, d)
+}
+
+// Reportf reports a diagnostic spanning a node, with the code and
+// severity of the analyzer.
+func (p *Pass) Reportf(node syntax.Node, format string, a ...interface{}) {
+	p.Report(Diagnostic{
+		Pos:     p.File.Position(node.Pos()),
+		End:     p.File.Position(node.End()),
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// Diagnostic is a problem found in a file.
+type Diagnostic struct {
+	// Analyzer is the name of the analyzer that reported it.
+	Analyzer string
+	Code     string
+	Severity Severity
+
+	// Filename, Pos and End give the span of source that the
+	// diagnostic is about.
+	Filename string
+	Pos, End syntax.Position
+
+	Message string
+
+	// Fix is a suggested change that resolves the diagnostic, if any.
+	Fix *Fix
+}
+
+// Fix is a change to a file suggested by a diagnostic, either replacing
+// a node with a new one or editing the source directly.
+type Fix struct {
+	// Message describes the change, like "use grep -c".
+	Message string
+
+	// Old is the node in the file, and New is its replacement, which
+	// may reuse parts of Old. They are usually statements.
+	Old, New syntax.Node
+
+	// Edits are the changes to the source, used instead of Old and
+	// New if not nil.
+	Edits []TextEdit
+}
+
+func (d Diagnostic) String() string {
+	prefix := ""
+	if d.Filename != "" {
+		prefix = d.Filename + ":"
+	}
+	return fmt.Sprintf("%s%d:%d: %s: %s (%s)", prefix, d.Pos.Line,
+		d.Pos.Column, d.Severity, d.Message, d.Code)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Analyzer)
+)
+
+// Register adds an analyzer to the ones run by default. It panics if an
+// analyzer with the same name was already registered.
+func Register(a *Analyzer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registry[a.Name] != nil {
+		panic(fmt.Sprintf("lint: analyzer %q registered twice", a.Name))
+	}
+	registry[a.Name] = a
+}
+
+// Lookup returns the registered analyzer with a name, or nil if there
+// isn't one.
+func Lookup(name string) *Analyzer {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return registry[name]
+}
+
+// Analyzers returns the registered analyzers, sorted by name.
+func Analyzers() []*Analyzer {
+	registryMu.Lock()
+	list := make([]*Analyzer, 0, len(registry))
+	for _, a := range registry {
+		list = append(list, a)
+	}
+	registryMu.Unlock()
+	sort.Sort(byName(list))
+	return list
+}
+
+// Group returns the registered analyzers in a group, sorted by name.
+func Group(name string) []*Analyzer {
+	var list []*Analyzer
+	for _, a := range Analyzers() {
+		if a.Group == name {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+type byName []*Analyzer
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Linter runs analyzers over files.
+type Linter struct {
+	// Analyzers are the analyzers to run. If nil, all the registered
+	// ones are run.
+	Analyzers []*Analyzer
+
+	// Program, if not nil, is the program that the linted files are
+	// part of, so that analyzers can look at the files they source.
+	Program *loader.Program
+
+	// Dialect, if not nil, is the shell that the linted files are
+	// written for, instead of the one in their shebang.
+	Dialect *Dialect
+
+	// Commands, if not nil, are the names of the commands that exist
+	// where the linted files run, like the executables in their $PATH,
+	// which the command analyzer checks the calls against.
+	Commands []string
+
+	// Disable are the analyzers not to run, named like in the
+	// directives that disable them.
+	Disable []string
+
+	// NoDirectives disables the directives in the files' comments
+	// that disable analyzers.
+	NoDirectives bool
+}
+
+// Lint runs all the registered analyzers over a file.
+func Lint(f *syntax.File) []Diagnostic {
+	return (&Linter{}).Lint(f)
+}
+
+// Lint runs the analyzers over a file, and returns their diagnostics
+// sorted by position. Parsing the file with syntax.ParseComments gives
+// the analyzers access to its comments, and enables the directives that
+// disable analyzers, like:
+//
+//	# sh-lint: disable=quote,SH1002
+//	# shellcheck disable=SC2086
+//
+// A directive applies to the statement on its line or the one after it,
+// or to the whole file if it's before the first statement. Analyzers are
+// named by their name, group or code, or the ShellCheck codes they
+// replace, and "all" matches all of them.
+func (l *Linter) Lint(f *syntax.File) []Diagnostic {
+	analyzers := l.Analyzers
+	if analyzers == nil {
+		analyzers = Analyzers()
+	}
+	sh := &shared{}
+	var diags []Diagnostic
+	for _, a := range analyzers {
+		if matchesRule(a, Diagnostic{Analyzer: a.Name, Code: a.Code}, l.Disable) {
+			continue
+		}
+		pass := &Pass{Analyzer: a, File: f, Program: l.Program,
+			Dialect: l.Dialect, Commands: l.Commands, shared: sh}
+		a.Run(pass)
+		diags = append(diags, pass.diags...)
+	}
+	if !l.NoDirectives {
+		diags = suppress(f, analyzers, diags)
+	}
+	sort.Stable(byPos(diags))
+	return diags
+}
+
+type byPos []Diagnostic
+
+func (b byPos) Len() int      { return len(b) }
+func (b byPos) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byPos) Less(i, j int) bool {
+	if b[i].Pos.Offset != b[j].Pos.Offset {
+		return b[i].Pos.Offset < b[j].Pos.Offset
+	}
+	return b[i].Code < b[j].Code
+}
+
+// sourceText returns the source of a node.
+func sourceText(f *syntax.File, node syntax.Node) string {
+	return string(f.Source[node.Pos()-1 : node.End()-1])
+}
+
+// funcVisitor is a Visitor that only visits the children of the nodes
+// for which it returns true.
+type funcVisitor func(syntax.Node) bool
+
+func (f funcVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node == nil || !f(node) {
+		return nil
+	}
+	return f
+}
+
+// printNode returns the source of a statement or command as formatted
+// by the printer.
+func printNode(node syntax.Node) string {
+	var s *syntax.Stmt
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		s = x
+	case syntax.Command:
+		s = &syntax.Stmt{Cmd: x}
+	default:
+		return ""
+	}
+	var buf bytes.Buffer
+	syntax.Fprint(&buf, &syntax.File{Stmts: []*syntax.Stmt{s}})
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func parse(tb testing.TB, src string) *syntax.File {
+	f, err := syntax.Parse([]byte(src), "", syntax.ParseComments)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return f
+}
+
+// lintStrings runs analyzers over a source and formats their
+// diagnostics.
+func lintStrings(tb testing.TB, src string, analyzers ...*Analyzer) []string {
+	l := &Linter{Analyzers: analyzers}
+	var got []string
+	for _, d := range l.Lint(parse(tb, src)) {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestLinter(t *testing.T) {
+	t.Parallel()
+	calls := &Analyzer{
+		Name:     "calls",
+		Code:     "T1",
+		Severity: Info,
+		Run: func(pass *Pass) {
+			for _, c := range pass.Funcs().Calls {
+				pass.Reportf(c.Expr, "call to %s", c.Name)
+			}
+		},
+	}
+	vars := &Analyzer{
+		Name: "vars",
+		Code: "T2",
+		Run: func(pass *Pass) {
+			for _, ref := range pass.Vars() {
+				pass.Report(Diagnostic{
+					Code:     "T3",
+					Severity: Warning,
+					Pos:      pass.File.Position(ref.Pos()),
+					Message:  ref.Kind.String() + " of " + ref.Name,
+				})
+			}
+		},
+	}
+	got := lintStrings(t, "foo=bar\necho $foo\n", vars, calls)
+	want := []string{
+		"1:1: warning: assign of foo (T3)",
+		"2:1: info: call to echo (T1)",
+		"2:7: warning: read of foo (T3)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	l := &Linter{Analyzers: []*Analyzer{vars, calls}, Disable: []string{"T2"}}
+	diags := l.Lint(parse(t, "foo=bar\n"))
+	if len(diags) != 0 {
+		t.Fatalf("disabled analyzer was run: %v", diags)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	a := &Analyzer{Name: "test-register", Run: func(*Pass) {}}
+	Register(a)
+	if got := Lookup(a.Name); got != a {
+		t.Fatalf("Lookup returned %v", got)
+	}
+	found := false
+	for _, a2 := range Analyzers() {
+		found = found || a2 == a
+	}
+	if !found {
+		t.Fatalf("the registered analyzer isn't in Analyzers")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("registering twice did not panic")
+		}
+	}()
+	Register(a)
+}
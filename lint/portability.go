@@ -0,0 +1,181 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Portability reports the features that the target shell doesn't
+// support.
+var Portability = &Analyzer{
+	Name: "portability",
+	Doc: `report features that the target shell doesn't support
+
+The target is the dialect given to the linter, or the shell in the
+shebang of the file otherwise, like "#!/bin/sh" for POSIX sh. Files
+without either aren't checked. The known dialects are POSIX sh, dash,
+bash 3.2, bash 4.4 and mksh.`,
+	Code:       "SH1009",
+	Severity:   Error,
+	ShellCheck: []string{"SC2039"},
+	Run:        runPortability,
+}
+
+func init() { Register(Portability) }
+
+func runPortability(pass *Pass) {
+	d := pass.Dialect
+	if d == nil {
+		d = ShebangDialect(pass.File.Source)
+	}
+	if d == nil {
+		return
+	}
+	report := func(node syntax.Node, f Feature) {
+		if !d.Supports(f) {
+			pass.Reportf(node, "unsupported in %s: %s", d.Name, f)
+		}
+	}
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.Assign:
+			if x.Append {
+				report(x, AppendAssign)
+			}
+			if x.Name != nil && !analysis.ValidName(x.Name.Value) {
+				report(x.Name, Arrays)
+			}
+		case *syntax.ArrayExpr:
+			report(x, Arrays)
+		case *syntax.ParamExp:
+			if x.Ind != nil {
+				report(x, Arrays)
+			} else if x.Param != nil && len(x.Param.Value) > 1 && x.Param.Value[0] == '!' {
+				report(x, Indirect)
+			}
+			if x.Slice != nil {
+				report(x, Substring)
+			}
+			if x.Repl != nil {
+				report(x, Replace)
+			}
+			if x.Exp != nil {
+				switch x.Exp.Op {
+				case syntax.UpperFirst, syntax.UpperAll,
+					syntax.LowerFirst, syntax.LowerAll:
+					report(x, CaseExp)
+				}
+			}
+		case *syntax.TestClause:
+			report(x, TestClause)
+		case *syntax.ArithmCmd:
+			report(x, ArithmCmd)
+		case *syntax.CStyleLoop:
+			report(x, CStyleLoop)
+		case *syntax.ProcSubst:
+			report(x, ProcSubst)
+		case *syntax.Redirect:
+			switch x.Op {
+			case syntax.WordHdoc:
+				report(x, HereString)
+			case syntax.RdrAll:
+				report(x, RedirAll)
+			case syntax.AppAll:
+				report(x, AppendAll)
+			}
+		case *syntax.SglQuoted:
+			if x.Dollar {
+				report(x, DollarSglQuote)
+			}
+		case *syntax.DblQuoted:
+			if x.Dollar {
+				report(x, DollarDblQuote)
+			}
+		case *syntax.ExtGlob:
+			report(x, ExtGlob)
+		case *syntax.FuncDecl:
+			if x.BashStyle {
+				report(x, FuncKeyword)
+			}
+		case *syntax.DeclClause:
+			portDecl(pass, x, report)
+		case *syntax.BinaryCmd:
+			if x.Op == syntax.PipeAll {
+				report(x, PipeAll)
+			}
+		case *syntax.CaseClause:
+			for _, pl := range x.List {
+				if pl.Op != syntax.DblSemicolon && !d.Supports(CaseFallthrough) {
+					pass.Report(Diagnostic{
+						Pos:     pass.File.Position(pl.OpPos),
+						End:     pass.File.Position(pl.OpPos + syntax.Pos(len(pl.Op.String()))),
+						Message: "unsupported in " + d.Name + ": " + CaseFallthrough.String(),
+					})
+				}
+			}
+		case *syntax.CoprocClause:
+			report(x, Coproc)
+		case *syntax.LetClause:
+			report(x, Let)
+		case *syntax.WordIter:
+			portBraces(x.List, report)
+		case *syntax.CallExpr:
+			switch name, _ := syntax.StaticValue(x.Args[0]); name {
+			case "source":
+				report(x, Source)
+			case "mapfile", "readarray":
+				report(x, Mapfile)
+			case "select":
+				report(x, Select)
+			case "shopt":
+				report(x, Shopt)
+			}
+			portBraces(x.Args, report)
+		}
+		return true
+	}), pass.File)
+}
+
+// portBraces reports the words that are subject to brace expansion.
+func portBraces(words []*syntax.Word, report func(syntax.Node, Feature)) {
+	for _, w := range words {
+		if len(expand.Braces(w)) > 1 {
+			report(w, BraceExp)
+		}
+	}
+}
+
+func portDecl(pass *Pass, dc *syntax.DeclClause, report func(syntax.Node, Feature)) {
+	switch dc.Variant {
+	case "":
+		// declare and typeset are both parsed without a variant
+		if pass.File.Source[dc.Pos()-1] == 't' {
+			report(dc, Typeset)
+		} else {
+			report(dc, Declare)
+		}
+	case "local":
+		report(dc, Local)
+	case "nameref":
+		report(dc, Nameref)
+	}
+	for _, w := range dc.Opts {
+		s, _ := syntax.StaticValue(w)
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case 'a':
+				report(w, Arrays)
+			case 'A':
+				report(w, AssocArrays)
+			case 'n':
+				report(w, Nameref)
+			case 'g':
+				report(w, GlobalDecl)
+			}
+		}
+	}
+}
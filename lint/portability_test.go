@@ -0,0 +1,119 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPortability(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		shell, in string
+		want      []string
+	}{
+		{"posix", "foo() { x=$((1 + 2)); echo ${x#1} >&2; }", nil},
+		{"posix", "echo ${#:-a}", nil},
+		{"", "[[ -n $x ]]", nil},
+		{"", "#!/bin/sh\n[[ -n $x ]]", []string{
+			"2:1: error: unsupported in POSIX sh: [[ ]] (SH1009)",
+		}},
+		{"", "#!/usr/bin/env bash\n[[ -n $x ]]; mapfile -t l", nil},
+		{"dash", "local x; arr=(a b); echo ${arr[0]} <<<$'a\\n'", []string{
+			"1:14: error: unsupported in dash: arrays (SH1009)",
+			"1:26: error: unsupported in dash: arrays (SH1009)",
+			"1:36: error: unsupported in dash: here-strings (SH1009)",
+			"1:39: error: unsupported in dash: $'' quotes (SH1009)",
+		}},
+		{"posix", "local x; function f { :; }; source x; let i++", []string{
+			"1:1: error: unsupported in POSIX sh: local (SH1009)",
+			"1:10: error: unsupported in POSIX sh: the function keyword (SH1009)",
+			"1:29: error: unsupported in POSIX sh: source (SH1009)",
+			"1:39: error: unsupported in POSIX sh: let (SH1009)",
+		}},
+		{"bash3.2", "declare -A m; mapfile -t l; echo ${x^^} |& cat; coproc cat", []string{
+			"1:9: error: unsupported in bash 3.2: associative arrays (SH1009)",
+			"1:15: error: unsupported in bash 3.2: mapfile (SH1009)",
+			"1:29: error: unsupported in bash 3.2: |& (SH1009)",
+			"1:34: error: unsupported in bash 3.2: case conversion expansions (SH1009)",
+			"1:49: error: unsupported in bash 3.2: coproc (SH1009)",
+		}},
+		{"bash4.4", "declare -A m; mapfile -t l; echo ${x^^} |& cat; coproc cat", nil},
+		{"mksh", "typeset x; echo $\"a\" <(cat) ${x/a/b} &>/dev/null", []string{
+			"1:17: error: unsupported in mksh: $\"\" quotes (SH1009)",
+			"1:22: error: unsupported in mksh: process substitutions (SH1009)",
+		}},
+		{"posix", "x+=y; a[1]=b; echo ${x:1} @(a|b); case x in a) ;& esac; for ((;;)); do :; done", []string{
+			"1:1: error: unsupported in POSIX sh: += (SH1009)",
+			"1:7: error: unsupported in POSIX sh: arrays (SH1009)",
+			"1:20: error: unsupported in POSIX sh: substring expansions (SH1009)",
+			"1:27: error: unsupported in POSIX sh: extended globs (SH1009)",
+			"1:48: error: unsupported in POSIX sh: case fallthrough (SH1009)",
+			"1:61: error: unsupported in POSIX sh: C-style for loops (SH1009)",
+		}},
+		{"posix", "echo ${!x} {a,b} {1..3} {a}; shopt -s extglob", []string{
+			"1:6: error: unsupported in POSIX sh: indirect expansions (SH1009)",
+			"1:12: error: unsupported in POSIX sh: brace expansions (SH1009)",
+			"1:18: error: unsupported in POSIX sh: brace expansions (SH1009)",
+			"1:30: error: unsupported in POSIX sh: shopt (SH1009)",
+		}},
+		{"posix", "for f in x{a,b}; do :; done; select x in a b; do :; done", []string{
+			"1:10: error: unsupported in POSIX sh: brace expansions (SH1009)",
+			"1:30: error: unsupported in POSIX sh: select (SH1009)",
+		}},
+		{"dash", "echo ${!x} {a,b} {1..3} {a}; shopt -s extglob", []string{
+			"1:6: error: unsupported in dash: indirect expansions (SH1009)",
+			"1:12: error: unsupported in dash: brace expansions (SH1009)",
+			"1:18: error: unsupported in dash: brace expansions (SH1009)",
+			"1:30: error: unsupported in dash: shopt (SH1009)",
+		}},
+		{"dash", "for f in x{a,b}; do :; done; select x in a b; do :; done", []string{
+			"1:10: error: unsupported in dash: brace expansions (SH1009)",
+			"1:30: error: unsupported in dash: select (SH1009)",
+		}},
+		{"bash3.2", "echo ${!x} ${!a[@]} {a,b}; shopt -s extglob; select x in a; do :; done", nil},
+		{"mksh", "echo ${!x} {a,b}; shopt -s extglob; select x in a; do :; done", []string{
+			"1:6: error: unsupported in mksh: indirect expansions (SH1009)",
+			"1:19: error: unsupported in mksh: shopt (SH1009)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			l := &Linter{Analyzers: []*Analyzer{Portability}}
+			if tc.shell != "" {
+				l.Dialect = LookupDialect(tc.shell)
+			}
+			var got []string
+			for _, d := range l.Lint(parse(t, tc.in)) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestShebangDialect(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want *Dialect
+	}{
+		{"echo", nil},
+		{"#!/bin/sh\necho", POSIX},
+		{"#! /bin/dash -e", Dash},
+		{"#!/usr/bin/env bash", Bash44},
+		{"#!/bin/mksh", Mksh},
+		{"#!/usr/bin/python", nil},
+	}
+	for _, tc := range tests {
+		if got := ShebangDialect([]byte(tc.in)); got != tc.want {
+			t.Errorf("wrong dialect in %q:\nwant: %v\ngot:  %v", tc.in, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Printf reports misuses of printf and echo.
+var Printf = &Analyzer{
+	Name: "printf",
+	Doc: `report misuses of printf and echo
+
+The format of printf is checked like in "go vet": its directives must
+be valid, the number of arguments must match them, and the arguments to
+numeric directives like %d must be numbers. Expansions in a format are
+interpreted as part of it, so a "%" in their value breaks the output;
+they should be arguments to "%s" instead.
+
+The flags of echo like -n and -e aren't portable, so they're reported
+if the shell that the file is written for doesn't support them.`,
+	Code:       "SH1018",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2059", "SC2182", "SC2183", "SC3037"},
+	Run:        runPrintf,
+}
+
+func init() { Register(Printf) }
+
+func runPrintf(pass *Pass) {
+	d := pass.Dialect
+	if d == nil {
+		d = ShebangDialect(pass.File.Source)
+	}
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		ce, ok := node.(*syntax.CallExpr)
+		if !ok || len(ce.Args) == 0 {
+			return true
+		}
+		switch wordValue(ce.Args[0]) {
+		case "printf":
+			checkPrintf(pass, ce.Args[1:])
+		case "echo":
+			if d != nil {
+				checkEcho(pass, d, ce.Args[1:])
+			}
+		}
+		return true
+	}), pass.File)
+}
+
+// fmtArg is an argument that a printf format consumes.
+type fmtArg struct {
+	directive string
+	verb      byte // 'd' for integers, 'f' for floats, 's' otherwise
+}
+
+// parseFormat returns the arguments that a printf format consumes, or
+// a problem with the format.
+func parseFormat(format string) ([]fmtArg, string) {
+	var args []fmtArg
+	for i := 0; i < len(format); i++ {
+		switch {
+		case format[i] == '\\':
+			i++
+			continue
+		case format[i] != '%':
+			continue
+		case i+1 < len(format) && format[i+1] == '%':
+			i++
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("-+ #0", format[i]) >= 0 {
+			i++
+		}
+		stars := 0
+		for i < len(format) && strings.IndexByte("*.0123456789", format[i]) >= 0 {
+			if format[i] == '*' {
+				stars++
+			}
+			i++
+		}
+		if i == len(format) {
+			return nil, "ends with an incomplete directive " + format[start:]
+		}
+		directive := format[start : i+1]
+		for ; stars > 0; stars-- {
+			args = append(args, fmtArg{directive, 'd'})
+		}
+		switch c := format[i]; c {
+		case 's', 'b', 'q', 'c':
+			args = append(args, fmtArg{directive, 's'})
+		case 'd', 'i', 'u', 'o', 'x', 'X':
+			args = append(args, fmtArg{directive, 'd'})
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			args = append(args, fmtArg{directive, 'f'})
+		default:
+			return nil, "has an unknown directive " + directive
+		}
+	}
+	return args, ""
+}
+
+func checkPrintf(pass *Pass, args []*syntax.Word) {
+	if len(args) > 1 && wordValue(args[0]) == "-v" {
+		args = args[2:]
+	}
+	if len(args) > 0 && wordValue(args[0]) == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return
+	}
+	fw, args := args[0], args[1:]
+	format, ok := syntax.StaticValue(fw)
+	if !ok {
+		// a format like "$fmt" along with its arguments is likely
+		// on purpose
+		parts := fw.Parts
+		if dq, ok := parts[0].(*syntax.DblQuoted); ok && len(parts) == 1 {
+			parts = dq.Parts
+		}
+		if len(args) > 0 && len(parts) == 1 {
+			return
+		}
+		if exp := firstExpansion(fw); exp != nil {
+			pass.Reportf(exp, "%s is interpreted as part of the printf format; use %%s and pass it as an argument",
+				sourceText(pass.File, exp))
+		}
+		return
+	}
+	text := sourceText(pass.File, fw)
+	fargs, problem := parseFormat(format)
+	if problem != "" {
+		pass.Reportf(fw, "printf format %s %s", text, problem)
+		return
+	}
+	for _, w := range args {
+		if !oneField(w) {
+			// the number of arguments is unknown
+			return
+		}
+	}
+	n, got := len(fargs), len(args)
+	switch {
+	case n == 0 && got > 0:
+		pass.Reportf(fw, "printf format %s has no directives, so its arguments are ignored", text)
+		return
+	case n == 0:
+		return
+	case got < n:
+		pass.Reportf(fw, "printf format %s needs %d arguments, but %d are given", text, n, got)
+		return
+	case got%n != 0:
+		pass.Reportf(fw, "printf format %s is reused for every %d arguments, but %d are given", text, n, got)
+		return
+	}
+	for i, w := range args {
+		fa := fargs[i%n]
+		value, ok := syntax.StaticValue(w)
+		if !ok || fa.verb == 's' || value == "" || value[0] == '\'' || value[0] == '"' {
+			continue
+		}
+		var err error
+		if fa.verb == 'd' {
+			_, err = strconv.ParseInt(strings.TrimSpace(value), 0, 64)
+		} else {
+			_, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+		if err != nil {
+			pass.Reportf(w, "printf argument %s for %s is not a number",
+				sourceText(pass.File, w), fa.directive)
+		}
+	}
+}
+
+// oneField reports whether a word always expands to a single field.
+func oneField(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			if strings.ContainsAny(x.Value, "*?[{") {
+				return false
+			}
+		case *syntax.SglQuoted:
+		case *syntax.DblQuoted:
+			for _, part := range x.Parts {
+				pe, ok := part.(*syntax.ParamExp)
+				if !ok {
+					continue
+				}
+				if pe.Param != nil && pe.Param.Value == "@" {
+					return false
+				}
+				if pe.Ind != nil {
+					if w, ok := pe.Ind.Expr.(*syntax.Word); ok && wordValue(w) == "@" {
+						return false
+					}
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func checkEcho(pass *Pass, d *Dialect, args []*syntax.Word) {
+	for _, w := range args {
+		value := wordValue(w)
+		if len(value) < 2 || value[0] != '-' || strings.Trim(value[1:], "neE") != "" {
+			return
+		}
+		f := EchoN
+		if strings.Trim(value[1:], "n") != "" {
+			f = EchoE
+		}
+		if !d.Supports(f) {
+			pass.Reportf(w, "echo %s isn't portable to %s; use printf", value, d.Name)
+			return
+		}
+	}
+}
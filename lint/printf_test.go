@@ -0,0 +1,79 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPrintf(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{`printf '%s: %d%%\n' "$name" 42; printf "$fmt" a; printf '%s\n' "$@"`, nil},
+		{`printf '%s %s\n' a b c d; printf -v x '%*d' 3 4; echo -n a`, nil},
+		{"printf \"hello\\n\"; printf 'done'; printf -- '%%\\n'", nil},
+		{`printf '%s\n' "${#:-a}"`, nil},
+		{`printf "hello $name\n"`, []string{
+			"1:15: warning: $name is interpreted as part of the printf format; use %s and pass it as an argument (SH1018)",
+		}},
+		{`printf "$(date): %s\n" x`, []string{
+			"1:9: warning: $(date) is interpreted as part of the printf format; use %s and pass it as an argument (SH1018)",
+		}},
+		{`printf '%s %s\n' a`, []string{
+			`1:8: warning: printf format '%s %s\n' needs 2 arguments, but 1 are given (SH1018)`,
+		}},
+		{`printf '%s=%s\n' a b c`, []string{
+			`1:8: warning: printf format '%s=%s\n' is reused for every 2 arguments, but 3 are given (SH1018)`,
+		}},
+		{`printf 'done\n' "$x"`, []string{
+			`1:8: warning: printf format 'done\n' has no directives, so its arguments are ignored (SH1018)`,
+		}},
+		{`printf '%y' a; printf 'a %-5'`, []string{
+			`1:8: warning: printf format '%y' has an unknown directive %y (SH1018)`,
+			`1:23: warning: printf format 'a %-5' ends with an incomplete directive %-5 (SH1018)`,
+		}},
+		{`printf '%d %.2f\n' 1 two 0x10 3.5 "'a" ""`, []string{
+			`1:22: warning: printf argument two for %.2f is not a number (SH1018)`,
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Printf)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintfEcho(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"#!/bin/bash\necho -n a; echo -e 'a\\tb'", nil},
+		{"#!/bin/dash\necho -n a; echo -ne b; echo -x", []string{
+			"2:17: warning: echo -ne isn't portable to dash; use printf (SH1018)",
+		}},
+		{"#!/bin/sh\necho -n a \"$b\"", []string{
+			"2:6: warning: echo -n isn't portable to POSIX sh; use printf (SH1018)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Printf)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Quote reports unquoted expansions in arguments and redirects.
+var Quote = &Analyzer{
+	Name: "quote",
+	Doc: `report unquoted expansions that are split and globbed
+
+The result of an unquoted expansion like $file, $@ or $(cmd) in the
+arguments of a command is split into fields at whitespace, and each
+field is expanded as a glob pattern, which breaks with values like
+"my file" or "*". Assignments, [[ ]], case words and here-documents
+aren't split, nor are expansions that result in numbers like $# or
+${#list}.`,
+	Code:       "SH1001",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2086", "SC2046", "SC2068"},
+	Run:        runQuote,
+}
+
+func init() { Register(Quote) }
+
+func runQuote(pass *Pass) {
+	syntax.Walk(quoteVisitor{pass}, pass.File)
+}
+
+type quoteVisitor struct {
+	pass *Pass
+}
+
+func (v quoteVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.CallExpr:
+		for _, w := range x.Args[1:] {
+			v.word(w)
+		}
+	case *syntax.Redirect:
+		switch x.Op {
+		case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc,
+			syntax.DplIn, syntax.DplOut:
+		default:
+			v.word(x.Word)
+		}
+	}
+	return v
+}
+
+// word reports the unquoted expansions in a word that are split.
+func (v quoteVisitor) word(w *syntax.Word) {
+	for _, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.ParamExp:
+			if numericParam(x) {
+				continue
+			}
+		case *syntax.CmdSubst:
+		default:
+			continue
+		}
+		text := sourceText(v.pass.File, part)
+		pos, end := int(part.Pos())-1, int(part.End())-1
+		v.pass.Report(Diagnostic{
+			Pos:     v.pass.File.Position(part.Pos()),
+			End:     v.pass.File.Position(part.End()),
+			Message: fmt.Sprintf("unquoted %s is split and globbed; quote it like \"%s\"", text, text),
+			Fix: &Fix{Message: "add double quotes", Edits: []TextEdit{
+				{Offset: pos, End: pos, New: `"`},
+				{Offset: end, End: end, New: `"`},
+			}},
+		})
+	}
+}
+
+// numericParam reports whether a parameter expansion always results in
+// a number, which isn't split nor globbed.
+func numericParam(pe *syntax.ParamExp) bool {
+	if pe.Length {
+		return true
+	}
+	switch pe.Param.Value {
+	case "#", "?", "$", "!":
+		return pe.Ind == nil && pe.Slice == nil && pe.Repl == nil && pe.Exp == nil
+	}
+	return false
+}
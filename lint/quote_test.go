@@ -0,0 +1,55 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{`echo "$x" "${y}" "$(z)" '$w'`, nil},
+		{"echo $x", []string{
+			`1:6: warning: unquoted $x is split and globbed; quote it like "$x" (SH1001)`,
+		}},
+		{"rm -rf $dir/* ${files[@]}", []string{
+			`1:8: warning: unquoted $dir is split and globbed; quote it like "$dir" (SH1001)`,
+			`1:15: warning: unquoted ${files[@]} is split and globbed; quote it like "${files[@]}" (SH1001)`,
+		}},
+		{"cmd $@ `date` $(date)", []string{
+			`1:5: warning: unquoted $@ is split and globbed; quote it like "$@" (SH1001)`,
+			"1:8: warning: unquoted `date` is split and globbed; quote it like \"`date`\" (SH1001)",
+			`1:15: warning: unquoted $(date) is split and globbed; quote it like "$(date)" (SH1001)`,
+		}},
+		{"cat <$in >$out", []string{
+			`1:6: warning: unquoted $in is split and globbed; quote it like "$in" (SH1001)`,
+			`1:11: warning: unquoted $out is split and globbed; quote it like "$out" (SH1001)`,
+		}},
+		{"[ $a = b ]", []string{
+			`1:3: warning: unquoted $a is split and globbed; quote it like "$a" (SH1001)`,
+		}},
+		{"x=$y; local z=$y; [[ $a == $b ]]; case $c in $d) ;; esac", nil},
+		{"cat <<EOF\n$x\nEOF\ncat <<< $y; echo >&$fd", nil},
+		{"echo $# $? $$ $! ${#x} ${#list[@]} $((1 + x))", nil},
+		{"$cmd arg", nil},
+		{`echo "$(echo $x)"`, []string{
+			`1:14: warning: unquoted $x is split and globbed; quote it like "$x" (SH1001)`,
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Quote)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
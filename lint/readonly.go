@@ -0,0 +1,183 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Readonly reports writes to readonly variables.
+var Readonly = &Analyzer{
+	Name: "readonly",
+	Doc: `report writes to readonly variables
+
+Once a variable is declared with readonly or declare -r, assigning or
+unsetting it fails. Writes that follow the declaration are reported,
+including those in functions called after it. When the files that a
+program sources are loaded, the writes done by sourcing them are
+reported too.
+
+Declarations in functions only apply to the rest of the function, as
+local and declare make local variables.`,
+	Code:     "SH1020",
+	Severity: Error,
+	Run:      runReadonly,
+}
+
+func init() { Register(Readonly) }
+
+// roDecl is the declaration of a readonly variable.
+type roDecl struct {
+	name string
+	lit  *syntax.Lit
+
+	// fn is the function that the declaration is in, if any
+	fn *analysis.Func
+}
+
+func runReadonly(pass *Pass) {
+	funcs := pass.Funcs()
+	var decls []*roDecl
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		dc, ok := node.(*syntax.DeclClause)
+		if !ok || !readonlyDecl(dc) {
+			return true
+		}
+		for _, as := range dc.Assigns {
+			lit := as.Name
+			if lit == nil && len(as.Value.Parts) == 1 {
+				lit, _ = as.Value.Parts[0].(*syntax.Lit)
+			}
+			if lit != nil && analysis.ValidName(lit.Value) {
+				decls = append(decls, &roDecl{lit.Value, lit, enclosingFunc(funcs, lit.Pos())})
+			}
+		}
+		return true
+	}), pass.File)
+	if len(decls) == 0 {
+		return
+	}
+	for _, ref := range pass.Vars() {
+		if ref.Kind != analysis.AssignRef && ref.Kind != analysis.UnsetRef {
+			continue
+		}
+		fn := enclosingFunc(funcs, ref.Pos())
+		for _, d := range decls {
+			if d.name != ref.Name || d.lit == ref.Lit || !d.writtenBy(funcs, fn, ref.Pos()) {
+				continue
+			}
+			pos := pass.File.Position(d.lit.Pos())
+			verb := "assigning"
+			if ref.Kind == analysis.UnsetRef {
+				verb = "unsetting"
+			}
+			pass.Reportf(ref.Lit, "%s is readonly since %d:%d, so %s it fails",
+				ref.Name, pos.Line, pos.Column, verb)
+			break
+		}
+	}
+	if pass.Program == nil {
+		return
+	}
+	for _, inc := range pass.Program.IncludesFrom(pass.File) {
+		var global []*roDecl
+		for _, d := range decls {
+			if d.fn == nil && d.lit.Pos() < inc.Stmt.Pos() {
+				global = append(global, d)
+			}
+		}
+		sourcedWrites(pass, pass.Program, inc, inc, global, make(map[*syntax.File]bool))
+	}
+}
+
+// readonlyDecl reports whether a declaration makes its variables
+// readonly.
+func readonlyDecl(dc *syntax.DeclClause) bool {
+	switch dc.Variant {
+	case "readonly":
+		return !declFlag(dc, 'f')
+	case "", "local":
+		return declFlag(dc, 'r') && !declFlag(dc, 'f')
+	}
+	return false
+}
+
+// declFlag reports whether a declaration has an option like -r.
+func declFlag(dc *syntax.DeclClause, opt byte) bool {
+	for _, w := range dc.Opts {
+		s := wordValue(w)
+		if len(s) > 1 && s[0] == '-' && strings.IndexByte(s[1:], opt) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingFunc returns the innermost function whose body contains a
+// position, if any.
+func enclosingFunc(t *analysis.FuncTable, pos syntax.Pos) *analysis.Func {
+	var found *analysis.Func
+	for _, fn := range t.Funcs {
+		if fn.Pos() <= pos && pos < fn.Decl.End() {
+			found = fn
+		}
+	}
+	return found
+}
+
+// writtenBy reports whether a write at a position in a function, if
+// any, happens after the declaration.
+func (d *roDecl) writtenBy(t *analysis.FuncTable, fn *analysis.Func, pos syntax.Pos) bool {
+	switch {
+	case pos < d.lit.Pos() && fn == d.fn:
+		return false
+	case d.fn != nil:
+		return fn == d.fn
+	case fn == nil:
+		return true
+	}
+	// a function may run after the declaration if it's called
+	// after it, or from another function
+	for _, c := range t.CallsTo(fn) {
+		if c.Caller != nil || c.Expr.Pos() > d.lit.Pos() {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcedWrites reports the writes to readonly variables done while
+// sourcing a file, including the files it sources in turn. They are
+// reported at the source statement in the linted file, top.
+func sourcedWrites(pass *Pass, prog *loader.Program, top, inc *loader.Include,
+	decls []*roDecl, seen map[*syntax.File]bool) {
+	f := inc.File
+	if f == nil || seen[f] || len(decls) == 0 {
+		return
+	}
+	seen[f] = true
+	funcs := analysis.Funcs(f)
+	for _, ref := range analysis.Vars(f) {
+		if ref.Kind != analysis.AssignRef && ref.Kind != analysis.UnsetRef ||
+			enclosingFunc(funcs, ref.Pos()) != nil {
+			continue
+		}
+		for _, d := range decls {
+			if d.name != ref.Name {
+				continue
+			}
+			rpos, dpos := f.Position(ref.Pos()), pass.File.Position(d.lit.Pos())
+			pass.Reportf(top.Stmt, "sourcing %s writes to %s at %d:%d, which is readonly since %d:%d",
+				f.Name, ref.Name, rpos.Line, rpos.Column, dpos.Line, dpos.Column)
+			break
+		}
+	}
+	for _, next := range prog.IncludesFrom(f) {
+		sourcedWrites(pass, prog, top, next, decls, seen)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestReadonly(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"x=1; readonly x; echo $x; readonly -f f", nil},
+		{"f() { local -r a=1; }; a=2; g() { declare -r b; }; b=3", nil},
+		{"readonly x=1\nx=2", []string{
+			"2:1: error: x is readonly since 1:10, so assigning it fails (SH1020)",
+		}},
+		{"declare -r y\nread y; unset y", []string{
+			"2:6: error: y is readonly since 1:12, so assigning it fails (SH1020)",
+			"2:15: error: y is readonly since 1:12, so unsetting it fails (SH1020)",
+		}},
+		{"f() { z=2; }\nreadonly z=1\nf", []string{
+			"1:7: error: z is readonly since 2:10, so assigning it fails (SH1020)",
+		}},
+		{"f() { z=2; }\nf\nreadonly z=1", nil},
+		{"f() {\n\tlocal -r v=1\n\t((v++))\n}", []string{
+			"3:4: error: v is readonly since 2:11, so assigning it fails (SH1020)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Readonly)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReadonlySourced(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"main.sh":  ". early.sh\nreadonly PREFIX=/usr\n. lib.sh\n",
+		"early.sh": "PREFIX=/opt\n",
+		"lib.sh":   ". more.sh\nf() { PREFIX=x; }\n",
+		"more.sh":  "PREFIX=/tmp\n",
+	}
+	cfg := loader.Config{
+		Mode: syntax.ParseComments,
+		ReadFile: func(path string) ([]byte, error) {
+			if src, ok := files[path]; ok {
+				return []byte(src), nil
+			}
+			return nil, fmt.Errorf("%s: not found", path)
+		},
+	}
+	prog, err := cfg.Load("main.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Linter{Analyzers: []*Analyzer{Readonly}, Program: prog}
+	var got []string
+	for _, d := range l.Lint(prog.Files[0]) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"main.sh:3:1: error: sourcing more.sh writes to PREFIX at 1:1, which is readonly since 2:10 (SH1020)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// The analyzers in the security group report code that may run or
+// remove more than intended, especially with untrusted input like the
+// program's arguments. Their diagnostics are errors when the input is
+// known to be untrusted, as found by analysis.Taint.
+var (
+	Eval = &Analyzer{
+		Name:  "eval",
+		Group: "security",
+		Doc: `report eval of expansions
+
+eval runs its arguments as code, so expanding a variable or a command
+in them runs whatever it contains. Arrays or functions can usually be
+used instead.`,
+		Code:     "SH1005",
+		Severity: Warning,
+		Run:      runEval,
+	}
+	ShellCmd = &Analyzer{
+		Name:  "shellcmd",
+		Group: "security",
+		Doc: `report shell command strings built from expansions
+
+In sh -c "$cmd", the value of the expansion is run as code. Pass the
+values as arguments instead, like sh -c 'echo "$1"' sh "$x".`,
+		Code:     "SH1006",
+		Severity: Warning,
+		Run:      runShellCmd,
+	}
+	RmVar = &Analyzer{
+		Name:  "rmvar",
+		Group: "security",
+		Doc: `report rm -r and rm -f of unsafe expansions
+
+Unquoted expansions in the arguments of rm -r or rm -f are split and
+globbed, so they may remove other files. Paths like "$dir/" remove
+from the root directory if the variable is empty or unset; use
+"${dir:?}/" to fail instead.`,
+		Code:       "SH1007",
+		Severity:   Error,
+		ShellCheck: []string{"SC2115"},
+		Run:        runRmVar,
+	}
+	Backtick = &Analyzer{
+		Name:  "backtick",
+		Group: "security",
+		Doc: "report backticks that run untrusted input\n\n" +
+			"In `$1` or `$input`, the value of the expansion is run as a command.\n" +
+			"Backticks also make nested quoting hard to get right; $( ) is easier\n" +
+			"to read.",
+		Code:       "SH1008",
+		Severity:   Error,
+		ShellCheck: []string{"SC2006"},
+		Run:        runBacktick,
+	}
+)
+
+func init() {
+	Register(Eval)
+	Register(ShellCmd)
+	Register(RmVar)
+	Register(Backtick)
+}
+
+// firstExpansion returns the first parameter expansion or command
+// substitution within a node whose result isn't a number, if any.
+func firstExpansion(node syntax.Node) syntax.Node {
+	var found syntax.Node
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		if found != nil {
+			return false
+		}
+		switch x := node.(type) {
+		case *syntax.ArithmExp:
+			return false
+		case *syntax.ParamExp:
+			if !numericParam(x) {
+				found = x
+			}
+			return false
+		case *syntax.CmdSubst:
+			found = x
+			return false
+		}
+		return true
+	}), node)
+	return found
+}
+
+// taintIn returns the first flow of a kind from an expansion within a
+// node, if any.
+func taintIn(pass *Pass, kind analysis.SinkKind, node syntax.Node) *analysis.TaintFlow {
+	for _, flow := range pass.Taint() {
+		if flow.Kind == kind && node.Pos() <= flow.Pos() && flow.Pos() < node.End() {
+			return flow
+		}
+	}
+	return nil
+}
+
+// originText returns how the origin of a taint flow is shown in
+// messages, with parameters like 1 and @ shown as their expansions.
+func originText(origin string) string {
+	switch {
+	case origin == "@" || origin == "*":
+		return "$" + origin
+	case strings.Trim(origin, "0123456789") != "":
+		return origin
+	case len(origin) > 1:
+		return "${" + origin + "}"
+	}
+	return "$" + origin
+}
+
+// reportUntrusted reports a sink as an error if the expansion in it
+// comes from untrusted input, or with the analyzer severity otherwise.
+func reportUntrusted(pass *Pass, kind analysis.SinkKind, node syntax.Node, msg string) {
+	exp := firstExpansion(node)
+	if exp == nil {
+		return
+	}
+	text := sourceText(pass.File, exp)
+	if flow := taintIn(pass, kind, node); flow != nil {
+		pass.Report(Diagnostic{
+			Code:     pass.Analyzer.Code,
+			Severity: Error,
+			Pos:      pass.File.Position(flow.Exp.Pos()),
+			End:      pass.File.Position(flow.Exp.End()),
+			Message: fmt.Sprintf(msg, sourceText(pass.File, flow.Exp)) +
+				fmt.Sprintf(", which comes from untrusted input (%s)", originText(flow.Origin)),
+		})
+		return
+	}
+	pass.Reportf(exp, msg, text)
+}
+
+func runEval(pass *Pass) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.EvalClause:
+			if x.Stmt != nil {
+				reportUntrusted(pass, analysis.EvalSink, x.Stmt, "eval runs the value of %s as code")
+			}
+			return false
+		case *syntax.CallExpr:
+			if name, _ := syntax.StaticValue(x.Args[0]); name == "eval" {
+				for _, w := range x.Args[1:] {
+					reportUntrusted(pass, analysis.EvalSink, w, "eval runs the value of %s as code")
+				}
+			}
+		}
+		return true
+	}), pass.File)
+}
+
+// shellNames are the commands that run a command string given via -c.
+var shellNames = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"dash": true,
+	"ksh":  true,
+	"mksh": true,
+	"zsh":  true,
+}
+
+func runShellCmd(pass *Pass) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		ce, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		if name, _ := syntax.StaticValue(ce.Args[0]); !shellNames[name] {
+			return true
+		}
+		args := ce.Args[1:]
+		for i, w := range args {
+			s, _ := syntax.StaticValue(w)
+			if len(s) > 1 && s[0] == '-' && s[1] != '-' &&
+				strings.IndexByte(s[1:], 'c') >= 0 && i+1 < len(args) {
+				reportUntrusted(pass, analysis.ShellSink, args[i+1], "the shell runs the value of %s as code")
+				break
+			}
+		}
+		return true
+	}), pass.File)
+}
+
+func runRmVar(pass *Pass) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		ce, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		if name, _ := syntax.StaticValue(ce.Args[0]); name != "rm" {
+			return true
+		}
+		args := ce.Args[1:]
+		if !rmForced(args) {
+			return true
+		}
+		for _, w := range args {
+			rmArg(pass, w)
+		}
+		return true
+	}), pass.File)
+}
+
+// rmForced reports whether the arguments of rm include -r or -f.
+func rmForced(args []*syntax.Word) bool {
+	for _, w := range args {
+		s, _ := syntax.StaticValue(w)
+		switch {
+		case s == "--":
+			return false
+		case s == "--recursive", s == "--force":
+			return true
+		case len(s) > 1 && s[0] == '-' && s[1] != '-' &&
+			strings.ContainsAny(s[1:], "rRf"):
+			return true
+		}
+	}
+	return false
+}
+
+func rmArg(pass *Pass, w *syntax.Word) {
+	for _, part := range w.Parts {
+		switch part.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst:
+			if exp := firstExpansion(part); exp != nil {
+				pass.Reportf(exp, "unquoted %s in rm may be split into other paths or globbed", sourceText(pass.File, exp))
+				return
+			}
+		}
+	}
+	// the word starts with an expansion followed by a slash
+	parts := w.Parts
+	if dq, ok := parts[0].(*syntax.DblQuoted); ok {
+		parts = append(dq.Parts[:len(dq.Parts):len(dq.Parts)], parts[1:]...)
+	}
+	if len(parts) < 2 {
+		return
+	}
+	pe, ok := parts[0].(*syntax.ParamExp)
+	if !ok || numericParam(pe) || (pe.Exp != nil && (pe.Exp.Op == syntax.SubstQuest ||
+		pe.Exp.Op == syntax.SubstColQuest)) {
+		return
+	}
+	if lit, ok := parts[1].(*syntax.Lit); ok && strings.HasPrefix(lit.Value, "/") {
+		pass.Report(Diagnostic{
+			Code:     pass.Analyzer.Code,
+			Severity: Warning,
+			Pos:      pass.File.Position(pe.Pos()),
+			End:      pass.File.Position(pe.End()),
+			Message: fmt.Sprintf("if %s is empty, rm removes from the root directory; use ${%s:?}",
+				sourceText(pass.File, pe), pe.Param.Value),
+		})
+	}
+}
+
+func runBacktick(pass *Pass) {
+	src := pass.File.Source
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		cs, ok := node.(*syntax.CmdSubst)
+		if !ok {
+			return true
+		}
+		if i := int(cs.Pos()) - 1; i < 0 || i >= len(src) || src[i] != '`' {
+			return true
+		}
+		// eval and shells in backticks are reported by their own
+		// analyzers
+		if flow := taintIn(pass, analysis.CommandSink, cs); flow != nil {
+			pass.Reportf(flow.Exp, "backticks run the value of %s as a command, which comes from untrusted input (%s)",
+				sourceText(pass.File, flow.Exp), originText(flow.Origin))
+		}
+		return false
+	}), pass.File)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSecurity(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"eval 'echo hi'; sh -c 'echo \"$1\"' sh \"$x\"; rm -rf \"$dir\"", nil},
+		{"eval \"$cmd\"", []string{
+			"1:7: warning: eval runs the value of $cmd as code (SH1005)",
+		}},
+		{"eval \"echo $1\"", []string{
+			"1:12: error: eval runs the value of $1 as code, which comes from untrusted input ($1) (SH1005)",
+		}},
+		{"bash -c \"$(cat file)\"; read -r x; sh -ec \"ls $x\"", []string{
+			"1:10: warning: the shell runs the value of $(cat file) as code (SH1006)",
+			"1:46: error: the shell runs the value of $x as code, which comes from untrusted input (read) (SH1006)",
+		}},
+		{"x=$*; eval \"$x\"; eval \"${10}\"", []string{
+			"1:13: error: eval runs the value of $x as code, which comes from untrusted input ($*) (SH1005)",
+			"1:24: error: eval runs the value of ${10} as code, which comes from untrusted input (${10}) (SH1005)",
+		}},
+		{"rm -rf $dir; rm -- -f $x; rm $y", []string{
+			"1:8: error: unquoted $dir in rm may be split into other paths or globbed (SH1007)",
+		}},
+		{"rm -r \"$dir/\"* \"${dir:?}/x\" $tmp/x", []string{
+			"1:8: warning: if $dir is empty, rm removes from the root directory; use ${dir:?} (SH1007)",
+			"1:29: error: unquoted $tmp in rm may be split into other paths or globbed (SH1007)",
+		}},
+		{"x=`$1`; y=`date`; z=$($1)", []string{
+			"1:4: error: backticks run the value of $1 as a command, which comes from untrusted input ($1) (SH1008)",
+		}},
+		{"x=`eval \"$1\"`; y=`sh -c \"$2\"`", []string{
+			"1:10: error: eval runs the value of $1 as code, which comes from untrusted input ($1) (SH1005)",
+			"1:26: error: the shell runs the value of $2 as code, which comes from untrusted input ($2) (SH1006)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Group("security")...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBacktickNoSource(t *testing.T) {
+	t.Parallel()
+	f := parse(t, "x=`date`")
+	f.Source = nil
+	l := &Linter{Analyzers: []*Analyzer{Backtick}}
+	if got := l.Lint(f); len(got) > 0 {
+		t.Fatalf("wanted no diagnostics, got %q", got)
+	}
+}
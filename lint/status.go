@@ -0,0 +1,233 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Status reports exit statuses that are lost or ignored by mistake.
+var Status = &Analyzer{
+	Name: "status",
+	Doc: `report exit statuses that are lost or ignored
+
+$? is the status of the last command, so reading it after echo or
+"local x=$(cmd)" gives the status of those instead of the command
+before. A cd that fails leaves the program running in the wrong
+directory, so it should be followed by "|| exit" unless set -e is on.
+With set -e, failures inside a function called in an if condition
+are ignored, as errexit is disabled within the whole condition.`,
+	Code:       "SH1010",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2320", "SC2155", "SC2164"},
+	Run:        runStatus,
+}
+
+func init() { Register(Status) }
+
+func runStatus(pass *Pass) {
+	errexit := errexitSet(pass.File)
+	syntax.WalkScoped(pass.File, func(node syntax.Node, sc syntax.Scope) bool {
+		body, cond := stmtLists(node)
+		for _, stmts := range body {
+			for i, s := range stmts {
+				if i > 0 {
+					statusAfter(pass, stmts[i-1], s)
+				}
+				if !errexit {
+					unchecked(pass, s, sc.Func != nil)
+				}
+			}
+		}
+		if errexit {
+			for _, stmts := range cond {
+				for _, s := range stmts {
+					maskedFunc(pass, s.Cmd)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// stmtLists returns the lists of statements directly within a node,
+// split between bodies and conditions.
+func stmtLists(node syntax.Node) (body, cond [][]*syntax.Stmt) {
+	switch x := node.(type) {
+	case *syntax.File:
+		body = append(body, x.Stmts)
+	case *syntax.Block:
+		body = append(body, x.Stmts)
+	case *syntax.Subshell:
+		body = append(body, x.Stmts)
+	case *syntax.CmdSubst:
+		body = append(body, x.Stmts)
+	case *syntax.ProcSubst:
+		body = append(body, x.Stmts)
+	case *syntax.IfClause:
+		cond = append(cond, x.CondStmts)
+		body = append(body, x.ThenStmts, x.ElseStmts)
+		for _, elif := range x.Elifs {
+			cond = append(cond, elif.CondStmts)
+			body = append(body, elif.ThenStmts)
+		}
+	case *syntax.WhileClause:
+		cond = append(cond, x.CondStmts)
+		body = append(body, x.DoStmts)
+	case *syntax.UntilClause:
+		cond = append(cond, x.CondStmts)
+		body = append(body, x.DoStmts)
+	case *syntax.ForClause:
+		body = append(body, x.DoStmts)
+	case *syntax.CaseClause:
+		for _, pl := range x.List {
+			body = append(body, pl.Stmts)
+		}
+	}
+	return body, cond
+}
+
+// errexitSet reports whether a file enables errexit, via set or its
+// shebang.
+func errexitSet(f *syntax.File) bool {
+	if line := string(f.Source); strings.HasPrefix(line, "#!") {
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.Fields(line)[1:] {
+			if errexitFlag(field) {
+				return true
+			}
+		}
+	}
+	found := false
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		ce, ok := node.(*syntax.CallExpr)
+		if found || !ok {
+			return !found
+		}
+		if name, _ := syntax.StaticValue(ce.Args[0]); name != "set" {
+			return true
+		}
+		for i, w := range ce.Args[1:] {
+			s, _ := syntax.StaticValue(w)
+			if errexitFlag(s) || (s == "-o" && i+2 < len(ce.Args) &&
+				wordValue(ce.Args[i+2]) == "errexit") {
+				found = true
+			}
+		}
+		return true
+	}), f)
+	return found
+}
+
+func errexitFlag(s string) bool {
+	return len(s) > 1 && s[0] == '-' && s[1] != '-' && s[1] != 'o' &&
+		strings.IndexByte(s[1:], 'e') >= 0
+}
+
+func wordValue(w *syntax.Word) string {
+	s, _ := syntax.StaticValue(w)
+	return s
+}
+
+// statusAfter reports a read of $? that gets the status of the previous
+// statement by mistake.
+func statusAfter(pass *Pass, prev, s *syntax.Stmt) {
+	pe := statusParam(s)
+	if pe == nil {
+		return
+	}
+	switch x := prev.Cmd.(type) {
+	case *syntax.CallExpr:
+		switch name := wordValue(x.Args[0]); name {
+		case "echo", "printf":
+			pass.Reportf(pe, "$? is the status of %s here, not of the command before it; save it right after the command, like status=$?", name)
+		}
+	case *syntax.DeclClause:
+		for _, a := range x.Assigns {
+			if a.Name == nil || a.Value == nil || !hasCmdSubst(a.Value) {
+				continue
+			}
+			name := x.Variant
+			if name == "" {
+				name = "declare"
+			}
+			pass.Reportf(pe, "$? is the status of %s, not of the command substitution; declare the variable first, like %s %s; %s=$(...)",
+				name, name, a.Name.Value, a.Name.Value)
+			return
+		}
+	}
+}
+
+// statusParam returns the first $? in a statement outside of command
+// substitutions, if any.
+func statusParam(s *syntax.Stmt) *syntax.ParamExp {
+	var found *syntax.ParamExp
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.CmdSubst, *syntax.ProcSubst, *syntax.FuncDecl:
+			return false
+		case *syntax.ParamExp:
+			if found == nil && x.Param != nil && x.Param.Value == "?" {
+				found = x
+			}
+		}
+		return found == nil
+	}), s)
+	return found
+}
+
+func hasCmdSubst(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.CmdSubst:
+			return true
+		case *syntax.DblQuoted:
+			for _, part := range x.Parts {
+				if _, ok := part.(*syntax.CmdSubst); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// unchecked reports a cd whose failure isn't handled.
+func unchecked(pass *Pass, s *syntax.Stmt, inFunc bool) {
+	ce, ok := s.Cmd.(*syntax.CallExpr)
+	if !ok || s.Background {
+		return
+	}
+	switch name := wordValue(ce.Args[0]); name {
+	case "cd", "pushd", "popd":
+		exit := "exit"
+		if inFunc {
+			exit = "return"
+		}
+		pass.Reportf(s, "the program keeps running if %s fails; use %s || %s",
+			name, sourceText(pass.File, ce), exit)
+	}
+}
+
+// maskedFunc reports calls to functions on the left of && in an if
+// condition, where errexit doesn't apply.
+func maskedFunc(pass *Pass, cmd syntax.Command) {
+	bc, ok := cmd.(*syntax.BinaryCmd)
+	if !ok || bc.Op != syntax.AndStmt {
+		return
+	}
+	maskedFunc(pass, bc.X.Cmd)
+	maskedFunc(pass, bc.Y.Cmd)
+	ce, ok := bc.X.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return
+	}
+	if name := wordValue(ce.Args[0]); pass.Funcs().Lookup(name) != nil {
+		pass.Reportf(ce, "set -e is ignored while %s runs in a condition, so failures inside it are masked; run it on its own first and check its status", name)
+	}
+}
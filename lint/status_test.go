@@ -0,0 +1,48 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"cmd; if [ $? -ne 0 ]; then :; fi; cd dir || exit", nil},
+		{"echo ${#:-a}; x=${#:-a}", nil},
+		{"cmd\necho done\nif [ $? -ne 0 ]; then :; fi", []string{
+			"3:6: warning: $? is the status of echo here, not of the command before it; save it right after the command, like status=$? (SH1010)",
+		}},
+		{"f() {\n\tlocal out=$(cmd)\n\techo $?\n}", []string{
+			"3:7: warning: $? is the status of local, not of the command substitution; declare the variable first, like local out; out=$(...) (SH1010)",
+		}},
+		{"echo; x=$(false; echo $?)", nil},
+		{"cd /tmp\nf() { cd \"$1\"; }", []string{
+			"1:1: warning: the program keeps running if cd fails; use cd /tmp || exit (SH1010)",
+			"2:7: warning: the program keeps running if cd fails; use cd \"$1\" || return (SH1010)",
+		}},
+		{"set -e\ncd /tmp", nil},
+		{"#!/bin/sh -e\ncd /tmp", nil},
+		{"f() { false; }\nif f && g; then :; fi", nil},
+		{"set -eu\nf() { false; }\nif f && g; then :; elif g && f && h; then :; fi", []string{
+			"3:4: warning: set -e is ignored while f runs in a condition, so failures inside it are masked; run it on its own first and check its status (SH1010)",
+			"3:30: warning: set -e is ignored while f runs in a condition, so failures inside it are masked; run it on its own first and check its status (SH1010)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Status)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
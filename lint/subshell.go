@@ -0,0 +1,116 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Subshell reports variables read after being assigned in a subshell,
+// where the assignment is lost.
+var Subshell = &Analyzer{
+	Name: "subshell",
+	Doc: `report variables assigned in a subshell and read outside of it
+
+Pipelines, ( ), $( ) and background commands run in a subshell, which
+is a copy of the shell. The variables assigned in it are lost once it
+exits, so they keep their old values outside of it, like in:
+
+	n=0
+	cat file | while read line; do n=$((n+1)); done
+	echo $n # always 0
+
+A read is only reported if the variable isn't assigned again in the
+main shell between the subshell and the read.`,
+	Code:       "SH1003",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2030", "SC2031"},
+	Run:        runSubshell,
+}
+
+func init() { Register(Subshell) }
+
+func runSubshell(pass *Pass) {
+	// the outermost nodes that run in a subshell
+	var subshells []syntax.Node
+	persistent := make(map[*syntax.Lit]bool)
+	syntax.WalkScoped(pass.File, func(node syntax.Node, sc syntax.Scope) bool {
+		if !sc.Persistent() {
+			return true
+		}
+		switch x := node.(type) {
+		case *syntax.Lit:
+			persistent[x] = true
+		case *syntax.Subshell, *syntax.CmdSubst, *syntax.ProcSubst,
+			*syntax.CoprocClause:
+			subshells = append(subshells, x)
+		case *syntax.Stmt:
+			if x.Background {
+				subshells = append(subshells, x)
+			}
+		case *syntax.BinaryCmd:
+			if x.Op == syntax.Pipe || x.Op == syntax.PipeAll {
+				subshells = append(subshells, x)
+			}
+		}
+		return true
+	})
+	within := func(pos syntax.Pos) syntax.Node {
+		for _, node := range subshells {
+			if node.Pos() <= pos && pos < node.End() {
+				return node
+			}
+		}
+		return nil
+	}
+	type lost struct {
+		ref      *analysis.VarRef
+		subshell syntax.Node
+	}
+	// the last assignment of each variable, if it was in a subshell
+	last := make(map[string]lost)
+	for _, ref := range pass.Vars() {
+		switch ref.Kind {
+		case analysis.AssignRef, analysis.DeclRef, analysis.UnsetRef:
+			if persistent[ref.Lit] {
+				delete(last, ref.Name)
+			} else {
+				last[ref.Name] = lost{ref, within(ref.Pos())}
+			}
+			continue
+		}
+		l, ok := last[ref.Name]
+		if !ok || l.subshell == nil || within(ref.Pos()) == l.subshell {
+			continue
+		}
+		pos := pass.File.Position(l.ref.Pos())
+		pass.Reportf(ref.Lit, "%s was assigned in %s at %d:%d, which runs in a subshell, so the assignment is lost here%s",
+			ref.Name, subshellName(l.subshell), pos.Line, pos.Column, subshellHint(l.subshell))
+	}
+}
+
+func subshellName(node syntax.Node) string {
+	switch node.(type) {
+	case *syntax.BinaryCmd:
+		return "a pipeline"
+	case *syntax.CmdSubst:
+		return "a command substitution"
+	case *syntax.ProcSubst:
+		return "a process substitution"
+	case *syntax.Subshell:
+		return "( )"
+	}
+	return "a background command"
+}
+
+func subshellHint(node syntax.Node) string {
+	switch node.(type) {
+	case *syntax.BinaryCmd:
+		return "; redirect a file or a process substitution into the loop instead"
+	case *syntax.Subshell:
+		return "; group the commands with { } instead"
+	}
+	return ""
+}
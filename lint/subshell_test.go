@@ -0,0 +1,44 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSubshell(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"n=0; while read l; do n=$((n+1)); done <file; echo $n", nil},
+		{"n=0\ncat file | while read l; do n=$((n+1)); done\necho $n", []string{
+			"3:7: warning: n was assigned in a pipeline at 2:29, which runs in a subshell, so the assignment is lost here; redirect a file or a process substitution into the loop instead (SH1003)",
+		}},
+		{"(x=1); echo $x", []string{
+			"1:14: warning: x was assigned in ( ) at 1:2, which runs in a subshell, so the assignment is lost here; group the commands with { } instead (SH1003)",
+		}},
+		{"y=$(x=1); echo $x $(echo $x)", []string{
+			"1:17: warning: x was assigned in a command substitution at 1:5, which runs in a subshell, so the assignment is lost here (SH1003)",
+			"1:27: warning: x was assigned in a command substitution at 1:5, which runs in a subshell, so the assignment is lost here (SH1003)",
+		}},
+		{"x=1 & echo $x", []string{
+			"1:13: warning: x was assigned in a background command at 1:1, which runs in a subshell, so the assignment is lost here (SH1003)",
+		}},
+		{"(x=1; echo $x); x=2; echo $x", nil},
+		{"{ x=1; }; echo $x", nil},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Subshell)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Trap reports temporary files that are never removed, and traps that
+// run undefined functions.
+var Trap = &Analyzer{
+	Name: "trap",
+	Doc: `report leaked temporary files and broken traps
+
+A temporary file or directory created with "tmp=$(mktemp)" is left
+behind when the program exits, unless a trap on EXIT removes it, like
+"trap 'rm -f "$tmp"' EXIT". A trap that runs a function that is never
+defined fails when it's triggered, which is often too late to notice.`,
+	Code:     "SH1019",
+	Severity: Warning,
+	Run:      runTrap,
+}
+
+func init() { Register(Trap) }
+
+// trapInfo holds the traps and functions in a program.
+type trapInfo struct {
+	funcs map[string]*syntax.FuncDecl
+
+	// cleaned are the variables that the traps on EXIT reference,
+	// directly or via the functions they run
+	cleaned map[string]bool
+}
+
+func runTrap(pass *Pass) {
+	files := []*syntax.File{pass.File}
+	if pass.Program != nil {
+		files = pass.Program.Files
+	}
+	info := &trapInfo{
+		funcs:   make(map[string]*syntax.FuncDecl),
+		cleaned: make(map[string]bool),
+	}
+	for _, f := range files {
+		syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+			if fd, ok := node.(*syntax.FuncDecl); ok {
+				info.funcs[fd.Name.Value] = fd
+			}
+			return true
+		}), f)
+	}
+	known := make(map[string]bool)
+	for _, name := range pass.Commands {
+		known[name] = true
+	}
+	for _, f := range files {
+		syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+			ce, ok := node.(*syntax.CallExpr)
+			if !ok || len(ce.Args) < 3 || wordValue(ce.Args[0]) != "trap" ||
+				wordValue(ce.Args[1]) == "-" {
+				return true
+			}
+			action := trapAction(f, ce.Args[1])
+			if action == nil {
+				return true
+			}
+			exit := false
+			for _, w := range ce.Args[2:] {
+				switch strings.ToUpper(wordValue(w)) {
+				case "EXIT", "SIGEXIT", "0":
+					exit = true
+				}
+			}
+			if exit {
+				info.references(ce.Args[1], make(map[string]bool))
+				info.references(action, make(map[string]bool))
+			}
+			if f == pass.File {
+				info.checkAction(pass, ce.Args[1], action, known)
+			}
+			return true
+		}), f)
+	}
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		as, ok := node.(*syntax.Assign)
+		if !ok || as.Name == nil || as.Value == nil {
+			return true
+		}
+		args := mktempArgs(as.Value)
+		if args == nil || info.cleaned[as.Name.Value] {
+			return true
+		}
+		kind, rm := "file", "rm -f"
+		for _, w := range args[1:] {
+			value := wordValue(w)
+			if value == "" || value[0] != '-' || strings.HasPrefix(value, "--") {
+				continue
+			}
+			if strings.Contains(value, "u") {
+				// a dry run, which creates nothing
+				return true
+			}
+			if strings.Contains(value, "d") {
+				kind, rm = "directory", "rm -rf"
+			}
+		}
+		name := as.Name.Value
+		pass.Reportf(as, "temporary %s in %s is never removed; add a trap like trap '%s \"$%s\"' EXIT",
+			kind, name, rm, name)
+		return true
+	}), pass.File)
+}
+
+// mktempArgs returns the arguments of mktemp if a word is the output of
+// a command substitution that calls it, like "$(mktemp -d)".
+func mktempArgs(w *syntax.Word) []*syntax.Word {
+	parts := w.Parts
+	if dq, ok := parts[0].(*syntax.DblQuoted); ok && len(parts) == 1 {
+		parts = dq.Parts
+	}
+	if len(parts) != 1 {
+		return nil
+	}
+	cs, ok := parts[0].(*syntax.CmdSubst)
+	if !ok || len(cs.Stmts) != 1 || substName(cs) != "mktemp" {
+		return nil
+	}
+	ce, ok := cs.Stmts[0].Cmd.(*syntax.CallExpr)
+	if !ok {
+		return nil
+	}
+	return ce.Args
+}
+
+// trapAction parses the action of a trap, which may be static or in
+// double quotes, and returns nil if it can't.
+func trapAction(f *syntax.File, w *syntax.Word) *syntax.File {
+	src, ok := syntax.StaticValue(w)
+	if !ok {
+		if len(w.Parts) != 1 {
+			return nil
+		}
+		dq, ok := w.Parts[0].(*syntax.DblQuoted)
+		if !ok || dq.Dollar {
+			return nil
+		}
+		// the expansions are done when the trap is set, but they
+		// still reference their variables
+		src = sourceText(f, w)
+		src = src[1 : len(src)-1]
+	}
+	af, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		return nil
+	}
+	return af
+}
+
+// references records the variables referenced in a node, including in
+// the bodies of the functions it calls.
+func (t *trapInfo) references(node syntax.Node, seen map[string]bool) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.ParamExp:
+			if x.Param != nil {
+				t.cleaned[x.Param.Value] = true
+			}
+		case *syntax.CallExpr:
+			if len(x.Args) == 0 {
+				break
+			}
+			name := wordValue(x.Args[0])
+			if fd := t.funcs[name]; fd != nil && !seen[name] {
+				seen[name] = true
+				t.references(fd.Body, seen)
+			}
+		}
+		return true
+	}), node)
+}
+
+// checkAction reports the commands run by a trap that are never
+// defined. If the known commands aren't given, only an action that is
+// a lone simple command is checked, like "trap cleanup EXIT".
+func (t *trapInfo) checkAction(pass *Pass, w *syntax.Word, action *syntax.File, known map[string]bool) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		ce, ok := node.(*syntax.CallExpr)
+		if !ok || len(ce.Args) == 0 {
+			return true
+		}
+		name := wordValue(ce.Args[0])
+		switch {
+		case name == "", t.funcs[name] != nil, analysis.IsBuiltin(name),
+			strings.Contains(name, "/"), known[name]:
+		case pass.Commands != nil,
+			len(ce.Args) == 1 && len(action.Stmts) == 1 && action.Stmts[0].Cmd == ce:
+			pass.Reportf(w, "trap runs %s, which is never defined", name)
+		}
+		return true
+	}), action)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTrap(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{`tmp=$(mktemp); trap 'rm -f "$tmp"' EXIT`, nil},
+		{`trap "rm -rf $dir" 0; dir="$(mktemp -d)"`, nil},
+		{"cleanup() { rm -f \"$a\"; }\ntrap cleanup EXIT INT\na=$(mktemp)", nil},
+		{"trap - EXIT; trap '' INT; x=$(mktemp -u)", nil},
+		{"trap 'echo ${#:-a}' EXIT; x=$(mktemp)", []string{
+			`1:27: warning: temporary file in x is never removed; add a trap like trap 'rm -f "$x"' EXIT (SH1019)`,
+		}},
+		{"tmp=$(mktemp)", []string{
+			`1:1: warning: temporary file in tmp is never removed; add a trap like trap 'rm -f "$tmp"' EXIT (SH1019)`,
+		}},
+		{"trap 'rm -f \"$a\"' INT\nf() {\n\tlocal d=$(mktemp -d --tmpdir x.XXX)\n}", []string{
+			`3:8: warning: temporary directory in d is never removed; add a trap like trap 'rm -rf "$d"' EXIT (SH1019)`,
+		}},
+		{"trap cleanup EXIT; trap 'echo bye; rm -f x' INT", []string{
+			"1:6: warning: trap runs cleanup, which is never defined (SH1019)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Trap)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
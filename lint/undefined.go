@@ -0,0 +1,124 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Undefined reports variables that are used but never assigned, or
+// used before they are assigned.
+var Undefined = &Analyzer{
+	Name: "undefined",
+	Doc: `report variables that are used but never assigned
+
+A variable is assigned by an assignment, a declaration like local or
+export, a for loop, or builtins like read, getopts and mapfile. Names
+without lowercase letters like $HOME or $CI are assumed to come from
+the environment, and uses with a default value like ${x:-} or tests
+like [[ -v x ]] are allowed.
+
+Variables used in the top level of the program before any of their
+assignments, outside of loops and functions, are also reported. When
+the sourced files are loaded, their assignments count as well.`,
+	Code:       "SH1002",
+	Severity:   Warning,
+	ShellCheck: []string{"SC2154"},
+	Run:        runUndefined,
+}
+
+func init() { Register(Undefined) }
+
+func runUndefined(pass *Pass) {
+	// the offset of the first assignment of each name, or 0 if it's
+	// assigned in another file
+	first := make(map[string]syntax.Pos)
+	if pass.Program != nil {
+		for _, f := range pass.Program.Files {
+			if f == pass.File {
+				continue
+			}
+			for _, ref := range analysis.Vars(f) {
+				if ref.Kind == analysis.AssignRef || ref.Kind == analysis.DeclRef {
+					first[ref.Name] = 0
+				}
+			}
+		}
+	}
+	for _, ref := range pass.Vars() {
+		if ref.Kind != analysis.AssignRef && ref.Kind != analysis.DeclRef {
+			continue
+		}
+		if _, ok := first[ref.Name]; !ok {
+			first[ref.Name] = ref.Pos()
+		}
+	}
+	uv := &undefVisitor{guarded: make(map[*syntax.Lit]bool)}
+	syntax.Walk(uv, pass.File)
+	for _, ref := range pass.Vars() {
+		if ref.Kind != analysis.ReadRef || uv.guarded[ref.Lit] || envName(ref.Name) {
+			continue
+		}
+		pos, ok := first[ref.Name]
+		switch {
+		case !ok:
+			pass.Reportf(ref.Lit, "%s is used but never assigned", ref.Name)
+		case ref.Pos() < pos && !uv.nested(ref.Pos()):
+			pass.Reportf(ref.Lit, "%s is used before it's assigned", ref.Name)
+		}
+	}
+}
+
+// envName reports whether a variable name has no lowercase letters, the
+// convention for environment and special shell variables.
+func envName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if 'a' <= name[i] && name[i] <= 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// undefVisitor finds the uses of variables that allow them to be unset,
+// and the loops and functions, where code may run in any order.
+type undefVisitor struct {
+	guarded map[*syntax.Lit]bool
+	nests   []syntax.Node
+}
+
+func (v *undefVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.ParamExp:
+		if x.Exp != nil {
+			switch x.Exp.Op {
+			case syntax.SubstPlus, syntax.SubstColPlus, syntax.SubstMinus,
+				syntax.SubstColMinus, syntax.SubstQuest, syntax.SubstColQuest,
+				syntax.SubstAssgn, syntax.SubstColAssgn:
+				v.guarded[x.Param] = true
+			}
+		}
+	case *syntax.UnaryTest:
+		if w, ok := x.X.(*syntax.Word); ok && x.Op == syntax.TsVarSet && len(w.Parts) == 1 {
+			if lit, ok := w.Parts[0].(*syntax.Lit); ok {
+				v.guarded[lit] = true
+			}
+		}
+	case *syntax.FuncDecl, *syntax.WhileClause, *syntax.UntilClause,
+		*syntax.ForClause:
+		v.nests = append(v.nests, x)
+	}
+	return v
+}
+
+// nested reports whether a position is within a loop or a function.
+func (v *undefVisitor) nested(pos syntax.Pos) bool {
+	for _, node := range v.nests {
+		if node.Pos() <= pos && pos < node.End() {
+			return true
+		}
+	}
+	return false
+}
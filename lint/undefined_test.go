@@ -0,0 +1,87 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/loader"
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestUndefined(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"foo=bar; echo $foo", nil},
+		{"echo $foo", []string{
+			"1:7: warning: foo is used but never assigned (SH1002)",
+		}},
+		{"echo $HOME $PATH $CI_TOKEN $1 $@", nil},
+		{"echo ${foo:-x} ${bar-x} ${baz:=x} ${qux:?}; [[ -v quux ]]", nil},
+		{"echo ${foo%x} $((bar + 1))", []string{
+			"1:8: warning: foo is used but never assigned (SH1002)",
+			"1:18: warning: bar is used but never assigned (SH1002)",
+		}},
+		{"read -r line; echo $line", nil},
+		{"for f in *; do echo $f; done", nil},
+		{"while getopts ab opt; do echo $opt; done", nil},
+		{"declare -a list; local x; echo $list $x", nil},
+		{"declare -A m; m[x]=1; echo \"${m[x]}\"", nil},
+		{"a[0]=1; echo \"${a[x]}\"", []string{
+			"1:19: warning: x is used but never assigned (SH1002)",
+		}},
+		{"mapfile -t lines; printf -v out x; echo $lines $out", nil},
+		{"echo $foo; foo=bar", []string{
+			"1:7: warning: foo is used before it's assigned (SH1002)",
+		}},
+		{"f() { echo $foo; }; foo=bar; f", nil},
+		{"while true; do echo $prev; prev=x; done", nil},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Undefined)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUndefinedSourced(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"main.sh": ". lib.sh\necho $fromlib $missing\n",
+		"lib.sh":  "fromlib=x\n",
+	}
+	cfg := loader.Config{
+		Mode: syntax.ParseComments,
+		ReadFile: func(path string) ([]byte, error) {
+			if src, ok := files[path]; ok {
+				return []byte(src), nil
+			}
+			return nil, fmt.Errorf("%s: not found", path)
+		},
+	}
+	prog, err := cfg.Load("main.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Linter{Analyzers: []*Analyzer{Undefined}, Program: prog}
+	var got []string
+	for _, d := range l.Lint(prog.Files[0]) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"main.sh:2:16: warning: missing is used but never assigned (SH1002)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
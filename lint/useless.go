@@ -0,0 +1,305 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// The analyzers in the useless group report commands that can be
+// simpler and faster, with a fix replacing them.
+var (
+	UselessCat = &Analyzer{
+		Name:  "uselesscat",
+		Group: "useless",
+		Doc: `report cat of a single file into a pipe
+
+"cat file | cmd" starts a process just to copy the file, which can be
+redirected into the command directly, like "cmd <file".`,
+		Code:       "SH1011",
+		Severity:   Info,
+		ShellCheck: []string{"SC2002"},
+		Run:        runUselessCat,
+	}
+	UselessEcho = &Analyzer{
+		Name:  "uselessecho",
+		Group: "useless",
+		Doc: `report echo of a command substitution
+
+"echo $(cmd)" captures the output of the command only to print it
+again, so the command can be run directly.`,
+		Code:       "SH1012",
+		Severity:   Info,
+		ShellCheck: []string{"SC2005"},
+		Run:        runUselessEcho,
+	}
+	GrepCount = &Analyzer{
+		Name:  "grepcount",
+		Group: "useless",
+		Doc: `report grep piped into wc -l
+
+"grep x | wc -l" counts the matching lines, which grep -c does
+itself.`,
+		Code:       "SH1013",
+		Severity:   Info,
+		ShellCheck: []string{"SC2126"},
+		Run:        runGrepCount,
+	}
+	LsCount = &Analyzer{
+		Name:  "lscount",
+		Group: "useless",
+		Doc: `report ls piped into wc -l
+
+"ls | wc -l" miscounts names with newlines. find can print a
+character per file instead, to be counted with wc -c.`,
+		Code:       "SH1014",
+		Severity:   Info,
+		ShellCheck: []string{"SC2012"},
+		Run:        runLsCount,
+	}
+)
+
+func init() {
+	Register(UselessCat)
+	Register(UselessEcho)
+	Register(GrepCount)
+	Register(LsCount)
+}
+
+// litWord returns a new literal word. Its position should be near the
+// nodes it's used with, so that the printer keeps them on one line.
+func litWord(pos syntax.Pos, s string) *syntax.Word {
+	return &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{ValuePos: pos, Value: s}}}
+}
+
+// callArgs returns the static values of the arguments of a statement's
+// simple command, including its name, and whether it is one. Arguments
+// that aren't static are empty.
+func callArgs(s *syntax.Stmt) ([]string, bool) {
+	ce, ok := s.Cmd.(*syntax.CallExpr)
+	if !ok || len(s.Assigns) > 0 {
+		return nil, false
+	}
+	args := make([]string, len(ce.Args))
+	for i, w := range ce.Args {
+		args[i] = wordValue(w)
+	}
+	return args, true
+}
+
+// pipeline returns the statements that are piped together in a
+// statement, along with the operators between them.
+func pipeline(s *syntax.Stmt) ([]*syntax.Stmt, []syntax.BinCmdOperator) {
+	var stmts []*syntax.Stmt
+	var ops []syntax.BinCmdOperator
+	for {
+		bc, ok := s.Cmd.(*syntax.BinaryCmd)
+		if !ok || (bc.Op != syntax.Pipe && bc.Op != syntax.PipeAll) {
+			break
+		}
+		stmts = append(stmts, bc.X)
+		ops = append(ops, bc.Op)
+		s = bc.Y
+	}
+	return append(stmts, s), ops
+}
+
+// joinPipeline returns a copy of the statement old with its pipeline
+// replaced by stmts and ops.
+func joinPipeline(old *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) *syntax.Stmt {
+	last := stmts[len(stmts)-1]
+	for i := len(stmts) - 2; i >= 0; i-- {
+		last = &syntax.Stmt{Position: stmts[i].Position, Cmd: &syntax.BinaryCmd{
+			OpPos: stmts[i].End(),
+			Op:    ops[i],
+			X:     stmts[i],
+			Y:     last,
+		}}
+	}
+	s := *last
+	s.Negated = old.Negated
+	s.Background = old.Background
+	s.Assigns = append(old.Assigns[:len(old.Assigns):len(old.Assigns)], s.Assigns...)
+	s.Redirs = append(s.Redirs[:len(s.Redirs):len(s.Redirs)], old.Redirs...)
+	return &s
+}
+
+// pipeVisitor calls f with every pipeline in a node, once for the
+// outermost statement.
+type pipeVisitor func(s *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator)
+
+func (f pipeVisitor) Visit(node syntax.Node) syntax.Visitor {
+	s, ok := node.(*syntax.Stmt)
+	if !ok {
+		return f
+	}
+	stmts, ops := pipeline(s)
+	if len(stmts) < 2 {
+		return f
+	}
+	f(s, stmts, ops)
+	for _, st := range stmts {
+		if st.Cmd != nil {
+			syntax.Walk(f, st.Cmd)
+		}
+		for _, r := range st.Redirs {
+			syntax.Walk(f, r)
+		}
+	}
+	return nil
+}
+
+func runUselessCat(pass *Pass) {
+	syntax.Walk(pipeVisitor(func(s *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) {
+		args, ok := callArgs(stmts[0])
+		if !ok || len(args) != 2 || args[0] != "cat" ||
+			strings.HasPrefix(args[1], "-") || len(stmts[0].Redirs) > 0 {
+			return
+		}
+		next := stmts[1]
+		if _, ok := next.Cmd.(*syntax.CallExpr); !ok {
+			return
+		}
+		for _, r := range next.Redirs {
+			switch r.Op {
+			case syntax.RdrIn, syntax.RdrInOut, syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc:
+				return
+			}
+		}
+		if ops[0] == syntax.PipeAll {
+			return
+		}
+		repl := *next
+		file := stmts[0].Cmd.(*syntax.CallExpr).Args[1]
+		repl.Redirs = append(repl.Redirs[:len(repl.Redirs):len(repl.Redirs)],
+			&syntax.Redirect{OpPos: next.End(), Op: syntax.RdrIn, Word: file})
+		news := append([]*syntax.Stmt{&repl}, stmts[2:]...)
+		fixed := joinPipeline(s, news, ops[1:])
+		text := printNode(fixed)
+		pass.Report(Diagnostic{
+			Pos:     pass.File.Position(stmts[0].Pos()),
+			End:     pass.File.Position(next.Pos()),
+			Message: "useless use of cat; redirect the file instead: " + text,
+			Fix:     &Fix{Message: "redirect the file into " + args[1], Old: s, New: fixed},
+		})
+	}), pass.File)
+}
+
+func runUselessEcho(pass *Pass) {
+	syntax.Walk(funcVisitor(func(node syntax.Node) bool {
+		s, ok := node.(*syntax.Stmt)
+		if !ok {
+			return true
+		}
+		ce, ok := s.Cmd.(*syntax.CallExpr)
+		if !ok || len(s.Assigns) > 0 || len(ce.Args) != 2 || wordValue(ce.Args[0]) != "echo" {
+			return true
+		}
+		parts := ce.Args[1].Parts
+		if dq, ok := parts[0].(*syntax.DblQuoted); ok && len(parts) == 1 {
+			parts = dq.Parts
+		}
+		if len(parts) != 1 {
+			return true
+		}
+		cs, ok := parts[0].(*syntax.CmdSubst)
+		if !ok || len(cs.Stmts) != 1 || cs.Stmts[0].Background {
+			return true
+		}
+		fixed := joinPipeline(s, cs.Stmts, nil)
+		pass.Report(Diagnostic{
+			Pos:     pass.File.Position(ce.Pos()),
+			End:     pass.File.Position(ce.End()),
+			Message: "useless use of echo; run the command directly: " + printNode(fixed),
+			Fix:     &Fix{Message: "run the command directly", Old: s, New: fixed},
+		})
+		return true
+	}), pass.File)
+}
+
+// countPipe returns the index of the statement piped into "wc -l" at
+// the end of a pipeline, if any.
+func countPipe(stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) int {
+	n := len(stmts)
+	args, ok := callArgs(stmts[n-1])
+	if !ok || len(args) != 2 || args[0] != "wc" || args[1] != "-l" ||
+		len(stmts[n-1].Redirs) > 0 || ops[n-2] != syntax.Pipe {
+		return -1
+	}
+	return n - 2
+}
+
+func runGrepCount(pass *Pass) {
+	syntax.Walk(pipeVisitor(func(s *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) {
+		i := countPipe(stmts, ops)
+		if i < 0 {
+			return
+		}
+		ce, ok := stmts[i].Cmd.(*syntax.CallExpr)
+		if !ok || len(stmts[i].Assigns) > 0 || wordValue(ce.Args[0]) != "grep" {
+			return
+		}
+		for _, w := range ce.Args[1:] {
+			// options that change what is counted
+			arg := wordValue(w)
+			if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") &&
+				strings.ContainsAny(arg, "ocABCl") {
+				return
+			}
+		}
+		grep := *stmts[i]
+		args := []*syntax.Word{ce.Args[0], litWord(ce.Args[0].End(), "-c")}
+		grep.Cmd = &syntax.CallExpr{Args: append(args, ce.Args[1:]...)}
+		news := append(stmts[:i:i], &grep)
+		fixed := joinPipeline(s, news, ops[:i])
+		pass.Report(Diagnostic{
+			Pos:     pass.File.Position(stmts[i].Pos()),
+			End:     pass.File.Position(stmts[i+1].End()),
+			Message: "grep -c counts the matching lines without wc: " + printNode(fixed),
+			Fix:     &Fix{Message: "use grep -c", Old: s, New: fixed},
+		})
+	}), pass.File)
+}
+
+func runLsCount(pass *Pass) {
+	syntax.Walk(pipeVisitor(func(s *syntax.Stmt, stmts []*syntax.Stmt, ops []syntax.BinCmdOperator) {
+		i := countPipe(stmts, ops)
+		if i != 0 || len(stmts[0].Redirs) > 0 {
+			return
+		}
+		args, ok := callArgs(stmts[0])
+		if !ok || args[0] != "ls" {
+			return
+		}
+		pos := stmts[0].Pos()
+		dir := litWord(pos, ".")
+		for j, arg := range args[1:] {
+			switch {
+			case arg == "-1":
+			case strings.HasPrefix(arg, "-") || dir.Parts[0].(*syntax.Lit).Value != ".":
+				return
+			default:
+				dir = stmts[0].Cmd.(*syntax.CallExpr).Args[j+1]
+			}
+		}
+		find := &syntax.Stmt{Position: pos, Cmd: &syntax.CallExpr{Args: []*syntax.Word{
+			litWord(pos, "find"), dir, litWord(pos, "-mindepth"), litWord(pos, "1"),
+			litWord(pos, "-maxdepth"), litWord(pos, "1"), litWord(pos, "!"), litWord(pos, "-name"),
+			{Parts: []syntax.WordPart{&syntax.SglQuoted{Position: pos, Value: ".*"}}},
+			litWord(pos, "-printf"), litWord(pos, "."),
+		}}}
+		wc := &syntax.Stmt{Position: pos, Cmd: &syntax.CallExpr{Args: []*syntax.Word{
+			litWord(pos, "wc"), litWord(pos, "-c"),
+		}}}
+		fixed := joinPipeline(s, []*syntax.Stmt{find, wc}, ops)
+		pass.Report(Diagnostic{
+			Pos:     pass.File.Position(stmts[0].Pos()),
+			End:     pass.File.Position(stmts[1].End()),
+			Message: "ls | wc -l miscounts names with newlines; count with find instead: " + printNode(fixed),
+			Fix:     &Fix{Message: "count with find", Old: s, New: fixed},
+		})
+	}), pass.File)
+}
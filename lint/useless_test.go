@@ -0,0 +1,50 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUseless(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want []string
+	}{
+		{"cat a b | grep x; cat -n f | less; echo \"x $(cmd)\"; grep -o x | wc -l", nil},
+		{"foo | >f; cat f | >out; <<EOF | wc -l\nfoo\nEOF", nil},
+		{"cat file | grep x", []string{
+			"1:1: info: useless use of cat; redirect the file instead: grep x <file (SH1011)",
+		}},
+		{"cat \"$f\" | sort | uniq >out", []string{
+			"1:1: info: useless use of cat; redirect the file instead: sort <\"$f\" | uniq >out (SH1011)",
+		}},
+		{"echo $(date +%s) >now; echo \"$(cmd | tr a b)\"", []string{
+			"1:1: info: useless use of echo; run the command directly: date +%s >now (SH1012)",
+			"1:24: info: useless use of echo; run the command directly: cmd | tr a b (SH1012)",
+		}},
+		{"n=$(grep -i foo file | wc -l)", []string{
+			"1:5: info: grep -c counts the matching lines without wc: grep -c -i foo file (SH1013)",
+		}},
+		{"cmd | grep x | wc -l", []string{
+			"1:7: info: grep -c counts the matching lines without wc: cmd | grep -c x (SH1013)",
+		}},
+		{"ls | wc -l; ls -1 \"$dir\" | wc -l; ls -la | wc -l", []string{
+			"1:1: info: ls | wc -l miscounts names with newlines; count with find instead: find . -mindepth 1 -maxdepth 1 ! -name '.*' -printf . | wc -c (SH1014)",
+			"1:13: info: ls | wc -l miscounts names with newlines; count with find instead: find \"$dir\" -mindepth 1 -maxdepth 1 ! -name '.*' -printf . | wc -c (SH1014)",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := lintStrings(t, tc.in, Group("useless")...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("diagnostics mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Inline returns the source of the root file with each source statement
+// replaced by the contents of the file it sources, recursively, so that
+// the result is a self-contained program. Each inlined file is wrapped
+// in a block with braces, so that a source statement that is part of a
+// bigger command, like "[ -f lib.sh ] && . lib.sh", still is.
+//
+// An error is returned if a sourced file couldn't be loaded, or if a
+// source statement can't be replaced, as when it has arguments or
+// redirections, or when files source each other. Files with a return
+// statement outside of a function aren't inlined either, as it would
+// no longer stop just the sourced file.
+func (p *Program) Inline() ([]byte, error) {
+	return p.inline(p.Files[0], make(map[*syntax.File]bool))
+}
+
+type byStmtPos []*Include
+
+func (b byStmtPos) Len() int           { return len(b) }
+func (b byStmtPos) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStmtPos) Less(i, j int) bool { return b[i].Stmt.Pos() < b[j].Stmt.Pos() }
+
+func (p *Program) inline(f *syntax.File, stack map[*syntax.File]bool) ([]byte, error) {
+	stack[f] = true
+	defer delete(stack, f)
+	incs := p.IncludesFrom(f)
+	sort.Sort(byStmtPos(incs))
+	var buf bytes.Buffer
+	last := 0
+	for _, inc := range incs {
+		pos := f.Position(inc.Stmt.Pos())
+		errorf := func(format string, a ...interface{}) error {
+			return fmt.Errorf("%s:%d:%d: cannot inline: %s", f.Name,
+				pos.Line, pos.Column, fmt.Sprintf(format, a...))
+		}
+		switch {
+		case inc.Name == "":
+			return nil, errorf("sourced name isn't static")
+		case inc.Err != nil:
+			return nil, errorf("%v", inc.Err)
+		case stack[inc.File]:
+			return nil, errorf("%s sources itself", inc.Name)
+		case hasTopReturn(inc.File):
+			return nil, errorf("%s returns outside of a function", inc.Name)
+		}
+		s := inc.Stmt
+		if len(s.Cmd.(*syntax.CallExpr).Args) != 2 || len(s.Redirs) > 0 ||
+			len(s.Assigns) > 0 || s.Negated || s.Background {
+			return nil, errorf("source statement has arguments or redirections")
+		}
+		body, err := p.inline(inc.File, stack)
+		if err != nil {
+			return nil, err
+		}
+		start := int(s.Pos()) - 1
+		buf.Write(f.Source[last:start])
+		buf.WriteString("{\n")
+		if len(inc.File.Stmts) == 0 {
+			// a block can't be empty
+			body = []byte(":")
+		}
+		buf.Write(body)
+		if body[len(body)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("}")
+		last = int(s.End()) - 1
+	}
+	buf.Write(f.Source[last:])
+	return buf.Bytes(), nil
+}
+
+// hasTopReturn reports whether a file has a return statement that isn't
+// part of a function body, which would return from the source
+// statement.
+func hasTopReturn(f *syntax.File) bool {
+	found := false
+	syntax.Walk(returnVisitor(func(node syntax.Node) bool {
+		switch x := node.(type) {
+		case *syntax.FuncDecl:
+			return false
+		case *syntax.CallExpr:
+			if name, _ := syntax.StaticValue(x.Args[0]); name == "return" {
+				found = true
+			}
+		}
+		return !found
+	}), f)
+	return found
+}
+
+// returnVisitor calls itself for every node, and stops walking into a
+// node's children if it returns false.
+type returnVisitor func(syntax.Node) bool
+
+func (v returnVisitor) Visit(node syntax.Node) syntax.Visitor {
+	if node == nil || !v(node) {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package loader parses shell programs along with all the files they
+// include via source statements.
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Config controls how a program and its sourced files are resolved and
+// parsed.
+type Config struct {
+	Mode syntax.ParseMode
+
+	// Dir is the directory that relative paths are resolved against.
+	// If empty, they are resolved against the directory of the file
+	// containing the source statement.
+	Dir string
+
+	// Path is the list of directories searched for names that
+	// contain no slash, like $PATH in Bash. If the name isn't found
+	// in any of them, it is resolved as a relative path.
+	Path []string
+
+	// Resolve, if not nil, replaces the default resolution. It
+	// receives the path of the file containing the source statement
+	// and the name as written, and returns the path to load.
+	Resolve func(from, name string) (string, error)
+
+	// ReadFile is used to read all files. If nil, ioutil.ReadFile
+	// is used.
+	ReadFile func(path string) ([]byte, error)
+}
+
+// Program is a shell program made up of multiple files.
+type Program struct {
+	// Files holds all the parsed files in the order they were
+	// loaded. The first one is the root file.
+	Files []*syntax.File
+
+	// Includes holds all the source statements found, in the order
+	// they were encountered.
+	Includes []*Include
+}
+
+// Include represents a source statement within a file.
+type Include struct {
+	From *syntax.File
+	Stmt *syntax.Stmt
+
+	// Name is the sourced name, or the empty string if it could not
+	// be determined statically.
+	Name string
+
+	// File is the loaded file, or nil if it could not be resolved.
+	File *syntax.File
+	// Err is the reason why the file could not be resolved, if any.
+	Err error
+}
+
+// File returns the loaded file with the given path, or nil if it isn't
+// part of the program.
+func (p *Program) File(path string) *syntax.File {
+	path = filepath.Clean(path)
+	for _, f := range p.Files {
+		if f.Name == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// IncludesFrom returns the source statements found in the given file.
+func (p *Program) IncludesFrom(f *syntax.File) []*Include {
+	var incs []*Include
+	for _, inc := range p.Includes {
+		if inc.From == f {
+			incs = append(incs, inc)
+		}
+	}
+	return incs
+}
+
+// Load parses the shell program at the given path and all the files it
+// sources. It calls Config.Load with its default settings.
+func Load(path string) (*Program, error) {
+	return Config{}.Load(path)
+}
+
+// Load parses the shell program at the given path and all the files it
+// sources, recursively. Each file is only parsed once, so cyclic
+// includes are allowed.
+//
+// Sourced names that aren't static, such as "$dir/lib.sh", or that
+// cannot be resolved are recorded in Program.Includes without a File.
+// An error is only returned if a file could be read but not parsed, or
+// if the root file could not be read.
+func (c Config) Load(path string) (*Program, error) {
+	l := &loader{c: c, prog: &Program{}, files: make(map[string]*syntax.File)}
+	if _, err := l.load(filepath.Clean(path)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(l.prog.Files); i++ {
+		if err := l.includes(l.prog.Files[i]); err != nil {
+			return nil, err
+		}
+	}
+	return l.prog, nil
+}
+
+type loader struct {
+	c     Config
+	prog  *Program
+	files map[string]*syntax.File
+}
+
+func (l *loader) readFile(path string) ([]byte, error) {
+	if l.c.ReadFile != nil {
+		return l.c.ReadFile(path)
+	}
+	return ioutil.ReadFile(path)
+}
+
+func (l *loader) load(path string) (*syntax.File, error) {
+	if f := l.files[path]; f != nil {
+		return f, nil
+	}
+	src, err := l.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := syntax.Parse(src, path, l.c.Mode)
+	if err != nil {
+		return nil, err
+	}
+	l.files[path] = f
+	l.prog.Files = append(l.prog.Files, f)
+	return f, nil
+}
+
+func (l *loader) includes(f *syntax.File) error {
+	var err error
+	syntax.Walk(sourceVisitor(func(s *syntax.Stmt, w *syntax.Word) {
+		if err != nil {
+			return
+		}
+		inc := &Include{From: f, Stmt: s}
+		l.prog.Includes = append(l.prog.Includes, inc)
+		name, ok := syntax.StaticValue(w)
+		if !ok {
+			return
+		}
+		inc.Name = name
+		var path string
+		if path, inc.Err = l.resolve(f.Name, name); inc.Err != nil {
+			return
+		}
+		if inc.File, inc.Err = l.load(path); inc.Err != nil {
+			if _, ok := inc.Err.(*syntax.ParseError); ok {
+				err = inc.Err
+			}
+		}
+	}), f)
+	return err
+}
+
+func (l *loader) resolve(from, name string) (string, error) {
+	if l.c.Resolve != nil {
+		path, err := l.c.Resolve(from, name)
+		return filepath.Clean(path), err
+	}
+	if !strings.Contains(name, "/") {
+		for _, dir := range l.c.Path {
+			path := filepath.Join(dir, name)
+			if l.exists(path) {
+				return path, nil
+			}
+		}
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		dir := l.c.Dir
+		if dir == "" {
+			dir = filepath.Dir(from)
+		}
+		path = filepath.Join(dir, path)
+	}
+	if !l.exists(path) {
+		return "", fmt.Errorf("%s: file not found", name)
+	}
+	return path, nil
+}
+
+func (l *loader) exists(path string) bool {
+	if l.files[path] != nil {
+		return true
+	}
+	if l.c.ReadFile != nil {
+		_, err := l.c.ReadFile(path)
+		return err == nil
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// sourceVisitor calls itself for every source statement, along with the
+// word holding the sourced name.
+type sourceVisitor func(*syntax.Stmt, *syntax.Word)
+
+func (v sourceVisitor) Visit(node syntax.Node) syntax.Visitor {
+	s, ok := node.(*syntax.Stmt)
+	if !ok {
+		return v
+	}
+	ce, ok := s.Cmd.(*syntax.CallExpr)
+	if !ok || len(ce.Args) < 2 {
+		return v
+	}
+	if name, _ := syntax.StaticValue(ce.Args[0]); name == "source" || name == "." {
+		v(s, ce.Args[1])
+	}
+	return v
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mapReadFile(files map[string]string) func(string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		src, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(src), nil
+	}
+}
+
+func fileNames(prog *Program) []string {
+	var names []string
+	for _, f := range prog.Files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"main.sh":         "source lib/a.sh\n. 'lib/b.sh'\nsource $dyn\nsource missing.sh",
+		"lib/a.sh":        "foo() { . \"lib/b.sh\"; }",
+		"lib/b.sh":        "source util.sh; source main.sh",
+		"bin/util.sh":     "bar",
+		"lib/unused.sh":   "baz",
+		"other/broken.sh": "foo(",
+	}
+	c := Config{
+		Dir:      ".",
+		Path:     []string{"bin"},
+		ReadFile: mapReadFile(files),
+	}
+	prog, err := c.Load("main.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"main.sh", "lib/a.sh", "lib/b.sh", "bin/util.sh"}
+	if got := fileNames(prog); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Files mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+	incs := prog.IncludesFrom(prog.Files[0])
+	if len(incs) != 4 {
+		t.Fatalf("wanted 4 includes in main.sh, got %d", len(incs))
+	}
+	if incs[2].Name != "" || incs[2].File != nil {
+		t.Fatalf("dynamic source should not be resolved")
+	}
+	if incs[3].Name != "missing.sh" || incs[3].Err == nil {
+		t.Fatalf("missing source should have an error")
+	}
+	if f := prog.File("./lib/b.sh"); f == nil {
+		t.Fatalf("lib/b.sh not found in program")
+	}
+	if len(prog.Includes) != 7 {
+		t.Fatalf("wanted 7 includes in total, got %d", len(prog.Includes))
+	}
+	if _, err := c.Load("other/broken.sh"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestLoadRelative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sh-loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.sh", "source sub/a.sh")
+	write("sub/a.sh", "source b.sh")
+	write("sub/b.sh", "foo")
+	prog, err := Load(filepath.Join(dir, "main.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog.Files) != 3 {
+		t.Fatalf("wanted 3 files, got %q", fileNames(prog))
+	}
+}
+
+func TestInline(t *testing.T) {
+	t.Parallel()
+	files := map[string]string{
+		"main.sh":      "#!/bin/sh\n. lib/a.sh\nfoo && source lib/b.sh; bar\n",
+		"lib/a.sh":     "a1\nsource lib/b.sh",
+		"lib/b.sh":     "b1 # comment\n",
+		"empty.sh":     "source lib/none.sh",
+		"lib/none.sh":  "# nothing\n",
+		"cycle.sh":     "source cycle.sh\n",
+		"dyn.sh":       "source \"$lib\"\n",
+		"args.sh":      "source lib/b.sh arg\n",
+		"missing.sh":   "foo\n. nope.sh\n",
+		"guard.sh":     "source lib/guard.sh\n",
+		"lib/guard.sh": "[ -n \"$X_LOADED\" ] && return\nX_LOADED=1\n",
+		"funcs.sh":     "source lib/funcs.sh\n",
+		"lib/funcs.sh": "f() { return 1; }\n",
+	}
+	c := Config{Dir: ".", ReadFile: mapReadFile(files)}
+	tests := []struct {
+		path, want, wantErr string
+	}{
+		{path: "main.sh", want: "#!/bin/sh\n{\na1\n{\nb1 # comment\n}\n}\nfoo && {\nb1 # comment\n}; bar\n"},
+		{path: "empty.sh", want: "{\n:\n}"},
+		{path: "cycle.sh", wantErr: "cycle.sh:1:1: cannot inline: cycle.sh sources itself"},
+		{path: "dyn.sh", wantErr: "dyn.sh:1:1: cannot inline: sourced name isn't static"},
+		{path: "args.sh", wantErr: "args.sh:1:1: cannot inline: source statement has arguments or redirections"},
+		{path: "missing.sh", wantErr: "missing.sh:2:1: cannot inline: nope.sh: file not found"},
+		{path: "guard.sh", wantErr: "guard.sh:1:1: cannot inline: lib/guard.sh returns outside of a function"},
+		{path: "funcs.sh", want: "{\nf() { return 1; }\n}\n"},
+	}
+	for _, tc := range tests {
+		prog, err := c.Load(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := prog.Inline()
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("%s: wrong error:\nwant: %s\ngot:  %v", tc.path, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("%s: Inline mismatch\nwant: %q\ngot:  %q", tc.path, tc.want, got)
+		}
+	}
+}
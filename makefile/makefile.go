@@ -0,0 +1,231 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package makefile handles the shell programs in the recipes of
+// Makefiles, undoing the escaping that make does before running them,
+// so that tools working with Makefiles can parse and lint them.
+package makefile
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Config holds the settings of a Makefile that affect its recipes.
+type Config struct {
+	// Prefix is the character that recipe lines start with, set by
+	// .RECIPEPREFIX. If zero, it's a tab.
+	Prefix byte
+
+	// Shell is the shell set by the SHELL variable. If empty, it's
+	// "/bin/sh".
+	Shell string
+
+	// OneShell is set if the Makefile has a .ONESHELL target, in
+	// which case all the lines of a recipe run in a single shell.
+	OneShell bool
+
+	// Mode is the mode used to parse the shell programs.
+	Mode syntax.ParseMode
+}
+
+func (c Config) prefix() byte {
+	if c.Prefix == 0 {
+		return '\t'
+	}
+	return c.Prefix
+}
+
+// Recipe is the recipe of a Makefile rule.
+type Recipe struct {
+	// Filename is the name of the Makefile.
+	Filename string
+
+	// Shell is the name of the shell that runs the lines, like "sh"
+	// or "bash".
+	Shell string
+
+	// Lines are the recipe lines, each run by a separate shell. With
+	// OneShell, there is only one.
+	Lines []*Line
+}
+
+// Line is a recipe line, including the lines it's continued on.
+type Line struct {
+	// Silent, IgnoreErrors and Always are set by the "@", "-" and
+	// "+" prefixes. They mean that make doesn't echo the line, that
+	// it keeps going if the line fails, and that it runs the line
+	// even with -n.
+	Silent, IgnoreErrors, Always bool
+
+	// Text is the program given to the shell, without the prefixes
+	// and with each "$$" unescaped to "$". Make variables and
+	// functions, like $(CC) or $@, are kept as they are.
+	Text string
+
+	// File is the parsed program. Its positions are relative to
+	// Text; use Position to map them to the Makefile.
+	File *syntax.File
+
+	// pos holds the position in the Makefile of each byte of Text,
+	// and end the one where it ends.
+	pos []syntax.Position
+	end syntax.Position
+}
+
+// ParseRecipe parses the recipe of a rule, which is the source after its
+// target line, like:
+//
+//	@echo building $@
+//	-rm -f $@.tmp
+//	for f in $^; do \
+//		cat $$f; \
+//	done >$@
+//
+// where line is the number of its first line in the Makefile, starting at
+// 1. Like in make, the prefix character of each line is stripped, along
+// with the prefix character of lines continued with a backslash, and
+// blank lines and lines with make comments are ignored.
+//
+// If a line can't be parsed, the error is a *syntax.ParseError with its
+// position in the Makefile.
+func (c Config) ParseRecipe(filename, src string, line int) (*Recipe, error) {
+	r := &Recipe{Filename: filename, Shell: "sh"}
+	if c.Shell != "" {
+		r.Shell = path.Base(c.Shell)
+	}
+	pfx := c.prefix()
+	var cur *Line
+	var text []byte
+	add := func(b byte, pos syntax.Position) {
+		text = append(text, b)
+		cur.pos = append(cur.pos, pos)
+	}
+	continued := false
+	offset := 0
+	for i, raw := range strings.SplitAfter(src, "\n") {
+		if raw == "" {
+			break
+		}
+		ln := strings.TrimSuffix(raw, "\n")
+		start := offset
+		offset += len(raw)
+		col := 1
+		if len(ln) > 0 && ln[0] == pfx {
+			ln = ln[1:]
+			col++
+		} else if !continued {
+			if trimmed := strings.TrimLeft(ln, " \t"); trimmed == "" || trimmed[0] == '#' {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: recipe line doesn't start with %q",
+				filename, line+i, pfx)
+		}
+		switch {
+		case continued:
+			add('\n', cur.end)
+		case cur == nil || !c.OneShell:
+			cur = &Line{}
+			r.Lines = append(r.Lines, cur)
+			text = text[:0]
+		prefixes:
+			for ln != "" {
+				switch ln[0] {
+				case '@':
+					cur.Silent = true
+				case '-':
+					cur.IgnoreErrors = true
+				case '+':
+					cur.Always = true
+				case ' ', '\t':
+				default:
+					break prefixes
+				}
+				ln = ln[1:]
+				col++
+			}
+		default:
+			// with .ONESHELL, make removes the prefixes of the
+			// lines after the first
+			add('\n', cur.end)
+			trimmed := strings.TrimLeft(ln, "@-+ \t")
+			col += len(ln) - len(trimmed)
+			ln = trimmed
+		}
+		for j := 0; j < len(ln); j++ {
+			add(ln[j], syntax.Position{
+				Offset: start + col - 1 + j,
+				Line:   line + i,
+				Column: col + j,
+			})
+			if ln[j] == '$' && j+1 < len(ln) && ln[j+1] == '$' {
+				j++
+			}
+		}
+		continued = (len(ln)-len(strings.TrimRight(ln, "\\")))%2 == 1
+		cur.Text = string(text)
+		cur.end = syntax.Position{
+			Offset: start + col - 1 + len(ln),
+			Line:   line + i,
+			Column: col + len(ln),
+		}
+	}
+	for _, l := range r.Lines {
+		f, err := syntax.Parse([]byte(l.Text), filename, c.Mode)
+		if err != nil {
+			if perr, ok := err.(*syntax.ParseError); ok {
+				perr.Position = l.outer(perr.Position.Offset)
+			}
+			return nil, err
+		}
+		l.File = f
+	}
+	return r, nil
+}
+
+// outer maps an offset in the program to a position in the Makefile.
+func (l *Line) outer(offset int) syntax.Position {
+	switch {
+	case offset == len(l.pos):
+		return l.end
+	case offset < 0 || offset > len(l.pos):
+		return syntax.Position{}
+	}
+	return l.pos[offset]
+}
+
+// Position returns the position in the Makefile of a position in the
+// line's program. Its offset is relative to the start of the recipe.
+func (l *Line) Position(pos syntax.Pos) syntax.Position {
+	return l.outer(int(pos) - 1)
+}
+
+// Lint lints the programs of a recipe, and returns the diagnostics with
+// their positions and filename in the Makefile. If l is nil, the
+// default linter is used. Unless l has a dialect, the one of the
+// recipe's shell is used, or POSIX. Their fixes, if any, still apply to
+// the Text of each line.
+func (r *Recipe) Lint(l *lint.Linter) []lint.Diagnostic {
+	var lc lint.Linter
+	if l != nil {
+		lc = *l
+	}
+	if lc.Dialect == nil {
+		if lc.Dialect = lint.LookupDialect(r.Shell); lc.Dialect == nil {
+			lc.Dialect = lint.POSIX
+		}
+	}
+	var diags []lint.Diagnostic
+	for _, line := range r.Lines {
+		for _, d := range lc.Lint(line.File) {
+			d.Filename = r.Filename
+			d.Pos, d.End = line.outer(d.Pos.Offset), line.outer(d.End.Offset)
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
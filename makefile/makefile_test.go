@@ -0,0 +1,93 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package makefile
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestParseRecipe(t *testing.T) {
+	t.Parallel()
+	type line struct {
+		silent, ignore, always bool
+		text                   string
+	}
+	var tests = []struct {
+		c    Config
+		src  string
+		want []line
+	}{
+		{Config{}, "\tmake all\n", []line{{text: "make all"}}},
+		{Config{}, "\t@echo $$HOME\n\t-rm x\n", []line{
+			{silent: true, text: "echo $HOME"},
+			{ignore: true, text: "rm x"},
+		}},
+		{Config{}, "\t@ - +a\n", []line{{true, true, true, "a"}}},
+		{Config{}, "\ta \\\n\tb\n\n# comment\n\tc", []line{
+			{text: "a \\\nb"},
+			{text: "c"},
+		}},
+		{Config{}, "\ta \\\\\n\tb\n", []line{{text: "a \\\\"}, {text: "b"}}},
+		{Config{Prefix: '>'}, ">a\n>b\n", []line{{text: "a"}, {text: "b"}}},
+		{Config{OneShell: true}, "\t@cd x\n\t@make\n\tb\n", []line{
+			{silent: true, text: "cd x\nmake\nb"},
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			r, err := tc.c.ParseRecipe("Makefile", tc.src, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []line
+			for _, l := range r.Lines {
+				got = append(got, line{l.Silent, l.IgnoreErrors, l.Always, l.Text})
+				if l.File == nil {
+					t.Fatalf("line %q wasn't parsed", l.Text)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("ParseRecipe mismatch in %q:\nwant: %+v\ngot:  %+v", tc.src, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRecipeError(t *testing.T) {
+	t.Parallel()
+	_, err := Config{}.ParseRecipe("Makefile", "\ta\n\t@echo $$(foo\n", 4)
+	perr, ok := err.(*syntax.ParseError)
+	if !ok {
+		t.Fatalf("want a *syntax.ParseError, got %v", err)
+	}
+	if got, want := perr.Position, (syntax.Position{Offset: 10, Line: 5, Column: 8}); got != want {
+		t.Fatalf("wrong position:\nwant: %+v\ngot:  %+v", want, got)
+	}
+	if _, err := (Config{}).ParseRecipe("Makefile", "a\n", 1); err == nil {
+		t.Fatal("want an error for a line without a tab")
+	}
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	src := "\t@for f in *.go; do \\\n\t\techo $$f; \\\n\tdone\n"
+	r, err := Config{}.ParseRecipe("Makefile", src, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := r.Lint(nil)
+	if len(diags) != 1 {
+		t.Fatalf("want 1 diagnostic, got %d: %v", len(diags), diags)
+	}
+	want := `Makefile:11:8: warning: unquoted $f is split and globbed; quote it like "$f" (SH1001)`
+	if got := diags[0].String(); got != want {
+		t.Fatalf("wrong diagnostic:\nwant: %s\ngot:  %s", want, got)
+	}
+	if got, want := r.Lines[0].Position(r.Lines[0].File.Pos()), (syntax.Position{Offset: 2, Line: 10, Column: 3}); got != want {
+		t.Fatalf("wrong position:\nwant: %+v\ngot:  %+v", want, got)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package markdown handles the shell programs in the fenced code blocks
+// of Markdown documents, so that the examples in READMEs and other docs
+// can be formatted and linted like any other program.
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/mvdan/sh/embedded"
+	"github.com/mvdan/sh/lint"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Block is a fenced code block of shell in a Markdown document, like:
+//
+//	```bash
+//	echo foo
+//	```
+type Block struct {
+	// Lang is the language in the block's info string: "sh", "bash"
+	// or "shell".
+	Lang string
+
+	// Code is the code in the block, without the indentation of its
+	// fence.
+	Code string
+
+	// Line is the line of the first line of Code in the document,
+	// starting at 1.
+	Line int
+
+	// Indent is the indentation of the block's fence, like in a list
+	// item, which is removed from the lines of Code.
+	Indent string
+
+	// Start and End are the offsets of the block's code in the
+	// document.
+	Start, End int
+}
+
+// langs are the languages of the blocks with shell programs.
+var langs = map[string]bool{"sh": true, "bash": true, "shell": true}
+
+// Blocks returns the fenced code blocks of shell in a Markdown document.
+// The blocks that aren't closed are skipped.
+func Blocks(src []byte) []Block {
+	var blocks []Block
+	var cur *Block
+	open, fence := false, ""
+	offset := 0
+	for i, line := range strings.SplitAfter(string(src), "\n") {
+		start := offset
+		offset += len(line)
+		trimmed := strings.TrimLeft(line, " ")
+		if open {
+			if fenceEnd(trimmed, fence) {
+				open = false
+				if cur != nil {
+					cur.End = start
+					cur.Code = unindent(string(src[cur.Start:cur.End]), cur.Indent)
+					blocks = append(blocks, *cur)
+					cur = nil
+				}
+			}
+			continue
+		}
+		if fence = fenceStart(trimmed); fence == "" {
+			continue
+		}
+		open = true
+		info := trimmed[len(fence):]
+		// the info strings of backtick fences can't have backticks
+		words := strings.Fields(info)
+		if len(words) > 0 && langs[words[0]] && (fence[0] == '~' || !strings.Contains(info, "`")) {
+			cur = &Block{
+				Lang:   words[0],
+				Line:   i + 2,
+				Indent: line[:len(line)-len(trimmed)],
+				Start:  offset,
+			}
+		}
+	}
+	return blocks
+}
+
+// fenceStart returns the fence that a line starts with, like "```" or
+// "~~~~", or an empty string if it doesn't start a fenced block.
+func fenceStart(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// fenceEnd reports whether a line closes a block opened by fence, with
+// at least as many of its characters and nothing else after them.
+func fenceEnd(line, fence string) bool {
+	f := fenceStart(line)
+	return f != "" && f[0] == fence[0] && len(f) >= len(fence) &&
+		strings.TrimSpace(line[len(f):]) == ""
+}
+
+// unindent removes an indentation from the lines of code that have it.
+func unindent(code, indent string) string {
+	if indent == "" {
+		return code
+	}
+	lines := strings.SplitAfter(code, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, indent)
+	}
+	return strings.Join(lines, "")
+}
+
+// reindent adds an indentation to the lines of code that aren't empty.
+func reindent(code, indent string) string {
+	if indent == "" {
+		return code
+	}
+	lines := strings.SplitAfter(code, "\n")
+	for i, line := range lines {
+		if line != "\n" && line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// Config holds how the blocks of a document are parsed and printed.
+type Config struct {
+	// Mode is the mode used to parse the blocks. The blocks of "sh"
+	// are always parsed as POSIX shell.
+	Mode syntax.ParseMode
+
+	// Print is the configuration used to print the blocks.
+	Print syntax.PrintConfig
+}
+
+func (c Config) parse(filename string, b Block) (*embedded.Script, error) {
+	mode := c.Mode
+	if b.Lang == "sh" {
+		mode |= syntax.PosixConformant
+	}
+	chunk := embedded.Chunk{Text: b.Code, Line: b.Line, Column: len(b.Indent) + 1}
+	return embedded.Parse(filename, []embedded.Chunk{chunk}, mode)
+}
+
+// Format returns a Markdown document with the code in its blocks of
+// shell formatted, and everything else as it was. If a block can't be
+// parsed, the error is a *syntax.ParseError with its position in the
+// document.
+func (c Config) Format(filename string, src []byte) ([]byte, error) {
+	var buf, code bytes.Buffer
+	last := 0
+	for _, b := range Blocks(src) {
+		s, err := c.parse(filename, b)
+		if err != nil {
+			return nil, err
+		}
+		code.Reset()
+		if err := c.Print.Fprint(&code, s.File); err != nil {
+			return nil, err
+		}
+		buf.Write(src[last:b.Start])
+		buf.WriteString(reindent(code.String(), b.Indent))
+		last = b.End
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// Lint lints the blocks of shell in a Markdown document, and returns
+// the diagnostics with their positions in the document. If l is nil,
+// the default linter is used. Unless l has a dialect, the one of each
+// block's language is used, which is bash for "shell".
+func (c Config) Lint(filename string, src []byte, l *lint.Linter) ([]lint.Diagnostic, error) {
+	var lc lint.Linter
+	if l != nil {
+		lc = *l
+	}
+	var diags []lint.Diagnostic
+	for _, b := range Blocks(src) {
+		s, err := c.parse(filename, b)
+		if err != nil {
+			return nil, err
+		}
+		bl := lc
+		if bl.Dialect == nil {
+			switch b.Lang {
+			case "sh":
+				bl.Dialect = lint.POSIX
+			default:
+				bl.Dialect = lint.LookupDialect("bash")
+			}
+		}
+		diags = append(diags, s.Lint(&bl)...)
+	}
+	return diags, nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package markdown
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"# Title\n\ntext\n", "# Title\n\ntext\n"},
+		{"```sh\nfoo  bar\n```\n", "```sh\nfoo bar\n```\n"},
+		{"```bash\nif a;then\nb;fi\n```\n", "```bash\nif a; then\n\tb\nfi\n```\n"},
+		{"```go\nfoo  bar\n```\n", "```go\nfoo  bar\n```\n"},
+		{"```\nfoo  bar\n```\n", "```\nfoo  bar\n```\n"},
+		{"~~~~ shell title\nfoo  bar\n~~~\n~~~~\n", "~~~~ shell title\nfoo bar\n~~~\n~~~~\n"},
+		{"- item\n\n  ```sh\n  if a;then\n  b;fi\n\n  ```\n", "- item\n\n  ```sh\n  if a; then\n  \tb\n  fi\n  ```\n"},
+		{"```sh\nfoo  bar\n", "```sh\nfoo  bar\n"},
+		{"a\n```sh\n\n```\nb  c\n", "a\n```sh\n\n```\nb  c\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := Config{}.Format("README.md", []byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("Format mismatch in %q:\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	t.Parallel()
+	src := "# Title\n\n```sh\necho 'foo\n```\n"
+	_, err := Config{}.Format("README.md", []byte(src))
+	if err == nil {
+		t.Fatal("want an error in an unclosed quote")
+	}
+	if got, want := err.Error(), "README.md:4:6: reached EOF without closing quote '"; got != want {
+		t.Fatalf("wrong error:\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	src := "Run:\n\n  ```sh\n  echo $HOME\n  [[ -f x ]]\n  ```\n"
+	diags, err := Config{}.Lint("README.md", []byte(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`README.md:4:8: warning: unquoted $HOME is split and globbed; quote it like "$HOME" (SH1001)`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Lint mismatch:\nwant: %q\ngot:  %q", want, got)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package pattern
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Match reports whether name matches the whole shell pattern pat. The
+// Shortest mode has no effect.
+//
+// Unlike Regexp, Match supports negated extended globs like !(a|b).
+// Malformed parts of a pattern, such as an unclosed bracket or extended
+// glob, are matched literally, like shells do.
+func Match(pat, name string, mode Mode) bool {
+	p := parser{pat: pat, mode: mode}
+	return match(p.seq(false), name, mode)
+}
+
+type nodeKind uint8
+
+const (
+	litNode   nodeKind = iota // a single rune
+	anyNode                   // ?
+	starNode                  // *
+	classNode                 // a bracket expression
+	extNode                   // an extended glob
+)
+
+type node struct {
+	kind nodeKind
+	r    rune
+
+	// the bracket expression's items and whether it is negated
+	items   []classItem
+	negated bool
+
+	// the extended glob's operator and alternatives
+	op   byte
+	alts [][]node
+}
+
+// classItem is a range of runes or a named class like [:alpha:] within
+// a bracket expression.
+type classItem struct {
+	lo, hi rune
+	class  func(rune) bool
+}
+
+type parser struct {
+	pat  string
+	mode Mode
+	i    int
+}
+
+// seq parses a sequence of nodes until the end of the pattern or, if
+// nested, until the | or ) within an extended glob, which are not
+// consumed.
+func (p *parser) seq(nested bool) []node {
+	var nodes []node
+	for p.i < len(p.pat) {
+		c := p.pat[p.i]
+		if p.mode&ExtGlob != 0 && strings.IndexByte("?*+@!", c) >= 0 &&
+			p.i+1 < len(p.pat) && p.pat[p.i+1] == '(' {
+			if n, ok := p.extGlob(c); ok {
+				nodes = append(nodes, n)
+				continue
+			}
+		}
+		switch c {
+		case '*':
+			if len(nodes) == 0 || nodes[len(nodes)-1].kind != starNode {
+				nodes = append(nodes, node{kind: starNode})
+			}
+			p.i++
+			continue
+		case '?':
+			nodes = append(nodes, node{kind: anyNode})
+			p.i++
+			continue
+		case '[':
+			if n, ok := p.bracket(); ok {
+				nodes = append(nodes, n)
+				continue
+			}
+		case '\\':
+			if p.i+1 < len(p.pat) {
+				p.i++
+			}
+		case '|', ')':
+			if nested {
+				return nodes
+			}
+		}
+		r, size := utf8.DecodeRuneInString(p.pat[p.i:])
+		nodes = append(nodes, node{kind: litNode, r: r})
+		p.i += size
+	}
+	return nodes
+}
+
+// extGlob parses an extended glob starting at the current position. It
+// returns false if it isn't closed, in which case nothing is consumed.
+func (p *parser) extGlob(op byte) (node, bool) {
+	start := p.i
+	p.i += 2
+	n := node{kind: extNode, op: op}
+	for {
+		n.alts = append(n.alts, p.seq(true))
+		if p.i >= len(p.pat) {
+			p.i = start
+			return node{}, false
+		}
+		p.i++
+		if p.pat[p.i-1] == ')' {
+			return n, true
+		}
+	}
+}
+
+// bracket parses a bracket expression starting at the current
+// position. It returns false if it isn't closed, in which case nothing
+// is consumed.
+func (p *parser) bracket() (node, bool) {
+	i := p.i + 1
+	n := node{kind: classNode}
+	if i < len(p.pat) && (p.pat[i] == '!' || p.pat[i] == '^') {
+		n.negated = true
+		i++
+	}
+	first := true
+	for i < len(p.pat) {
+		c := p.pat[i]
+		switch {
+		case c == ']' && !first:
+			p.i = i + 1
+			return n, true
+		case c == '[' && i+1 < len(p.pat) && p.pat[i+1] == ':':
+			end := strings.Index(p.pat[i+2:], ":]")
+			if end < 0 {
+				return node{}, false
+			}
+			fn := classes[p.pat[i+2:i+2+end]]
+			if fn == nil {
+				return node{}, false
+			}
+			n.items = append(n.items, classItem{class: fn})
+			i += 2 + end + 2
+			first = false
+			continue
+		case c == '\\' && i+1 < len(p.pat):
+			i++
+		}
+		lo, size := utf8.DecodeRuneInString(p.pat[i:])
+		i += size
+		hi := lo
+		if i+1 < len(p.pat) && p.pat[i] == '-' && p.pat[i+1] != ']' {
+			i++
+			if p.pat[i] == '\\' && i+1 < len(p.pat) {
+				i++
+			}
+			hi, size = utf8.DecodeRuneInString(p.pat[i:])
+			i += size
+		}
+		n.items = append(n.items, classItem{lo: lo, hi: hi})
+		first = false
+	}
+	return node{}, false
+}
+
+func isASCII(r rune) bool { return r < utf8.RuneSelf }
+
+func isAlpha(r rune) bool { return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' }
+func isDigit(r rune) bool { return '0' <= r && r <= '9' }
+func isPunct(r rune) bool { return r > ' ' && r < 0x7f && !isAlpha(r) && !isDigit(r) }
+
+// classes are the supported character classes, which like in regular
+// expressions only match ASCII characters.
+var classes = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return isAlpha(r) || isDigit(r) },
+	"alpha":  isAlpha,
+	"ascii":  isASCII,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  func(r rune) bool { return r < ' ' || r == 0x7f },
+	"digit":  isDigit,
+	"graph":  func(r rune) bool { return r > ' ' && r < 0x7f },
+	"lower":  func(r rune) bool { return 'a' <= r && r <= 'z' },
+	"print":  func(r rune) bool { return r >= ' ' && r < 0x7f },
+	"punct":  isPunct,
+	"space":  func(r rune) bool { return strings.ContainsRune(" \t\n\v\f\r", r) },
+	"upper":  func(r rune) bool { return 'A' <= r && r <= 'Z' },
+	"word":   func(r rune) bool { return isAlpha(r) || isDigit(r) || r == '_' },
+	"xdigit": func(r rune) bool { return isDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F' },
+}
+
+func (n *node) matchesClass(r rune) bool {
+	for _, item := range n.items {
+		if item.class != nil {
+			if item.class(r) {
+				return !n.negated
+			}
+		} else if item.lo <= r && r <= item.hi {
+			return !n.negated
+		}
+	}
+	return n.negated
+}
+
+// match reports whether a sequence of nodes matches all of s.
+func match(nodes []node, s string, mode Mode) bool {
+	if len(nodes) == 0 {
+		return s == ""
+	}
+	n, rest := &nodes[0], nodes[1:]
+	switch n.kind {
+	case starNode:
+		for i := 0; i <= len(s); i++ {
+			if i < len(s) && !utf8.RuneStart(s[i]) {
+				continue
+			}
+			if match(rest, s[i:], mode) {
+				return true
+			}
+			if i < len(s) && s[i] == '/' && mode&Filenames != 0 {
+				return false
+			}
+		}
+		return false
+	case extNode:
+		return n.matchExt(rest, s, mode)
+	}
+	if s == "" {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	switch n.kind {
+	case litNode:
+		if r != n.r {
+			return false
+		}
+	case anyNode:
+		if r == '/' && mode&Filenames != 0 {
+			return false
+		}
+	case classNode:
+		if r == '/' && mode&Filenames != 0 && n.negated {
+			return false
+		}
+		if !n.matchesClass(r) {
+			return false
+		}
+	}
+	return match(rest, s[size:], mode)
+}
+
+// matchExt reports whether an extended glob followed by the rest of the
+// nodes matches all of s.
+func (n *node) matchExt(rest []node, s string, mode Mode) bool {
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && !utf8.RuneStart(s[i]) {
+			continue
+		}
+		head := s[:i]
+		if mode&Filenames != 0 && strings.IndexByte(head, '/') >= 0 {
+			break
+		}
+		var ok bool
+		switch n.op {
+		case '@':
+			ok = n.matchAlt(head, mode)
+		case '?':
+			ok = head == "" || n.matchAlt(head, mode)
+		case '*':
+			ok = head == "" || n.matchRepeat(head, mode)
+		case '+':
+			ok = n.matchAlt(head, mode) || n.matchRepeat(head, mode)
+		case '!':
+			ok = !n.matchAlt(head, mode)
+		}
+		if ok && match(rest, s[i:], mode) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node) matchAlt(s string, mode Mode) bool {
+	for _, alt := range n.alts {
+		if match(alt, s, mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRepeat reports whether s is a sequence of one or more non-empty
+// strings that each match one of the alternatives.
+func (n *node) matchRepeat(s string, mode Mode) bool {
+	if s == "" {
+		return false
+	}
+	for i := 1; i <= len(s); i++ {
+		if i < len(s) && !utf8.RuneStart(s[i]) {
+			continue
+		}
+		if n.matchAlt(s[:i], mode) && (i == len(s) || n.matchRepeat(s[i:], mode)) {
+			return true
+		}
+	}
+	return false
+}
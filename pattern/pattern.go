@@ -0,0 +1,282 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package pattern matches shell patterns, as used in case clauses, test
+// expressions and filename expansion, and converts them to regular
+// expressions.
+package pattern
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Mode controls how patterns are translated via a set of flags.
+type Mode uint
+
+const (
+	Shortest  Mode = 1 << iota // make wildcards match as little as possible
+	Filenames                  // wildcards and classes don't match slashes
+	ExtGlob                    // support extended globbing like @(a|b)
+)
+
+// ErrNotStatic is returned when a pattern word contains expansions, so
+// it cannot be translated before running the program.
+var ErrNotStatic = errors.New("pattern is not static")
+
+// SyntaxError is returned when a pattern uses unsupported syntax.
+type SyntaxError struct {
+	Pattern string
+	Reason  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid pattern %q: %s", e.Pattern, e.Reason)
+}
+
+// Regexp translates a shell pattern into the source of an equivalent
+// regular expression. The result isn't anchored.
+//
+// Backslashes escape the character that follows them. Unclosed
+// brackets are treated as literal characters. Negated extended globs,
+// !(pattern), cannot be represented in the regexp syntax and are
+// rejected.
+func Regexp(pat string, mode Mode) (string, error) {
+	t := translator{pat: pat, mode: mode}
+	if err := t.translate(false); err != nil {
+		return "", err
+	}
+	if t.i < len(pat) {
+		return "", &SyntaxError{pat, "unbalanced parenthesis"}
+	}
+	return t.buf.String(), nil
+}
+
+// HasMeta reports whether a pattern contains any characters that
+// aren't matched literally.
+func HasMeta(pat string, mode Mode) bool {
+	for i := 0; i < len(pat); i++ {
+		switch pat[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		case '+', '@', '!':
+			if mode&ExtGlob != 0 && i+1 < len(pat) && pat[i+1] == '(' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Compile translates a pattern word into a regular expression that
+// matches entire strings, newlines included. Quoted parts of the word
+// are matched literally. ErrNotStatic is returned if the word contains
+// expansions.
+func Compile(w *syntax.Word, mode Mode) (*regexp.Regexp, error) {
+	src, err := WordRegexp(w, mode)
+	if err != nil {
+		return nil, err
+	}
+	// like in the shell, * and ? match newlines too
+	return regexp.Compile("(?s)^" + src + "$")
+}
+
+// WordRegexp is like Compile, but returns the regexp source without
+// anchors.
+func WordRegexp(w *syntax.Word, mode Mode) (string, error) {
+	var buf bytes.Buffer
+	for _, wp := range w.Parts {
+		switch x := wp.(type) {
+		case *syntax.Lit:
+			src, err := Regexp(x.Value, mode&^ExtGlob)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(src)
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			s, ok := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+			if !ok {
+				return "", ErrNotStatic
+			}
+			buf.WriteString(regexp.QuoteMeta(s))
+		case *syntax.ExtGlob:
+			pat := x.Op.String() + x.Pattern.Value + ")"
+			src, err := Regexp(pat, mode|ExtGlob)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(src)
+		default:
+			return "", ErrNotStatic
+		}
+	}
+	return buf.String(), nil
+}
+
+type translator struct {
+	pat  string
+	mode Mode
+	i    int
+	buf  bytes.Buffer
+}
+
+// translate writes the regexp for the pattern until its end or, if
+// nested, until the closing parenthesis of an extended glob, which is
+// not consumed.
+func (t *translator) translate(nested bool) error {
+	any := "."
+	if t.mode&Filenames != 0 {
+		any = "[^/]"
+	}
+	lazy := ""
+	if t.mode&Shortest != 0 {
+		lazy = "?"
+	}
+	for t.i < len(t.pat) {
+		c := t.pat[t.i]
+		if t.mode&ExtGlob != 0 && strings.IndexByte("?*+@!", c) >= 0 &&
+			t.i+1 < len(t.pat) && t.pat[t.i+1] == '(' {
+			if err := t.extGlob(c, lazy); err != nil {
+				return err
+			}
+			continue
+		}
+		switch c {
+		case '*':
+			t.buf.WriteString(any + "*" + lazy)
+			// consecutive stars are equivalent to one
+			for t.i+1 < len(t.pat) && t.pat[t.i+1] == '*' {
+				t.i++
+			}
+		case '?':
+			t.buf.WriteString(any)
+		case '\\':
+			if t.i+1 < len(t.pat) {
+				t.i++
+				c = t.pat[t.i]
+			}
+			t.buf.WriteString(regexp.QuoteMeta(string(c)))
+		case '[':
+			if t.bracket() {
+				continue
+			}
+			t.buf.WriteString(`\[`)
+		case '|', ')':
+			if nested {
+				return nil
+			}
+			t.buf.WriteString(regexp.QuoteMeta(string(c)))
+		default:
+			t.buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		t.i++
+	}
+	if nested {
+		return &SyntaxError{t.pat, "unclosed extended glob"}
+	}
+	return nil
+}
+
+func (t *translator) extGlob(op byte, lazy string) error {
+	if op == '!' {
+		return &SyntaxError{t.pat, "negated extended globs are not supported"}
+	}
+	t.i += 2
+	t.buf.WriteString("(?:")
+	for {
+		if err := t.translate(true); err != nil {
+			return err
+		}
+		if t.pat[t.i] == ')' {
+			break
+		}
+		t.buf.WriteByte('|')
+		t.i++
+	}
+	t.i++
+	t.buf.WriteByte(')')
+	switch op {
+	case '?':
+		t.buf.WriteString("?" + lazy)
+	case '*':
+		t.buf.WriteString("*" + lazy)
+	case '+':
+		t.buf.WriteString("+" + lazy)
+	}
+	return nil
+}
+
+// bracket translates a bracket expression starting at the current
+// position. It returns false if the bracket isn't closed, in which case
+// nothing is consumed.
+func (t *translator) bracket() bool {
+	i := t.i + 1
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	if i < len(t.pat) && (t.pat[i] == '!' || t.pat[i] == '^') {
+		buf.WriteByte('^')
+		i++
+	}
+	negated := buf.Len() > 1
+	first := true
+	for i < len(t.pat) {
+		c := t.pat[i]
+		switch {
+		case c == ']' && !first:
+			if negated && t.mode&Filenames != 0 {
+				buf.WriteByte('/')
+			}
+			buf.WriteByte(']')
+			t.buf.Write(buf.Bytes())
+			t.i = i + 1
+			return true
+		case c == '[' && i+1 < len(t.pat) && t.pat[i+1] == ':':
+			end := strings.Index(t.pat[i+2:], ":]")
+			if end < 0 {
+				return false
+			}
+			class := t.pat[i : i+2+end+2]
+			if !validClass(class) {
+				return false
+			}
+			buf.WriteString(class)
+			i += len(class)
+			first = false
+			continue
+		case c == '\\':
+			if i+1 < len(t.pat) {
+				i++
+				c = t.pat[i]
+			}
+			fallthrough
+		case c == ']', c == '[', c == '^':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '-' && (first || i+1 < len(t.pat) && t.pat[i+1] == ']'):
+			buf.WriteString(`\-`)
+		default:
+			buf.WriteByte(c)
+		}
+		first = false
+		i++
+	}
+	return false
+}
+
+func validClass(class string) bool {
+	switch class {
+	case "[:alnum:]", "[:alpha:]", "[:ascii:]", "[:blank:]",
+		"[:cntrl:]", "[:digit:]", "[:graph:]", "[:lower:]",
+		"[:print:]", "[:punct:]", "[:space:]", "[:upper:]",
+		"[:word:]", "[:xdigit:]":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package pattern
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+var regexpTests = []struct {
+	pat  string
+	mode Mode
+	want string
+	err  bool
+}{
+	{pat: "foo", want: `foo`},
+	{pat: "*.go", want: `.*\.go`},
+	{pat: "a**b", want: `a.*b`},
+	{pat: "?", want: `.`},
+	{pat: `\*\\`, want: `\*\\`},
+	{pat: "*", mode: Shortest, want: `.*?`},
+	{pat: "*/?", mode: Filenames, want: `[^/]*/[^/]`},
+	{pat: "[abc]", want: `[abc]`},
+	{pat: "[!a-z]", want: `[^a-z]`},
+	{pat: "[^a]", mode: Filenames, want: `[^a/]`},
+	{pat: "[]a]", want: `[\]a]`},
+	{pat: "[a-]", want: `[a\-]`},
+	{pat: "[[:alpha:]_]", want: `[[:alpha:]_]`},
+	{pat: "[[:foo:]]", want: `\[[:foo:]\]`},
+	{pat: "[abc", want: `\[abc`},
+	{pat: "a|b)", want: `a\|b\)`},
+	{pat: "@(a|b)", mode: ExtGlob, want: `(?:a|b)`},
+	{pat: "+(a|*.c)x", mode: ExtGlob, want: `(?:a|.*\.c)+x`},
+	{pat: "?(a)*(b)", mode: ExtGlob, want: `(?:a)?(?:b)*`},
+	{pat: "@(a|?(b))", mode: ExtGlob, want: `(?:a|(?:b)?)`},
+	{pat: "@(a", mode: ExtGlob, err: true},
+	{pat: "!(a)", mode: ExtGlob, err: true},
+	{pat: "@(a)", want: `@\(a\)`},
+}
+
+func TestRegexp(t *testing.T) {
+	t.Parallel()
+	for i, tc := range regexpTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := Regexp(tc.pat, tc.mode)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error in %q", tc.pat)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Regexp mismatch in %q\nwant: %q\ngot:  %q",
+					tc.pat, tc.want, got)
+			}
+		})
+	}
+}
+
+var compileTests = []struct {
+	in      string
+	matches []string
+	no      []string
+}{
+	{"case x in *.sh) ;; esac", []string{"a.sh", ".sh"}, []string{"a.bash"}},
+	{"case x in a*) ;; esac", []string{"a\nb", "a\n"}, []string{"b\na"}},
+	{"case x in a?b) ;; esac", []string{"a\nb"}, []string{"a\n\nb"}},
+	{`case x in '*'.sh) ;; esac`, []string{"*.sh"}, []string{"a.sh"}},
+	{`case x in "a?"*) ;; esac`, []string{"a?", "a?b"}, []string{"ab"}},
+	{"case x in +([0-9])) ;; esac", []string{"1", "123"}, []string{"", "1a"}},
+	{"[[ $x == foo@(bar|baz) ]]", []string{"foobar", "foobaz"}, []string{"foo"}},
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+	for i, tc := range compileTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			w := patternWord(t, tc.in)
+			rx, err := Compile(w, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.matches {
+				if !rx.MatchString(s) {
+					t.Errorf("%q did not match %q", rx, s)
+				}
+			}
+			for _, s := range tc.no {
+				if rx.MatchString(s) {
+					t.Errorf("%q matched %q", rx, s)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileNotStatic(t *testing.T) {
+	t.Parallel()
+	w := patternWord(t, "case x in $pat) ;; esac")
+	if _, err := Compile(w, 0); err != ErrNotStatic {
+		t.Fatalf("wanted ErrNotStatic, got %v", err)
+	}
+}
+
+func patternWord(tb testing.TB, src string) *syntax.Word {
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	switch x := f.Stmts[0].Cmd.(type) {
+	case *syntax.CaseClause:
+		return x.List[0].Patterns[0]
+	case *syntax.TestClause:
+		return x.X.(*syntax.BinaryTest).Y.(*syntax.Word)
+	}
+	tb.Fatalf("no pattern found in %q", src)
+	return nil
+}
+
+func TestHasMeta(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{"*", "a?", "[ab]", `\\*`} {
+		if !HasMeta(s, 0) {
+			t.Errorf("HasMeta(%q) returned false", s)
+		}
+	}
+	for _, s := range []string{"foo", `\*`, "@(a)"} {
+		if HasMeta(s, 0) {
+			t.Errorf("HasMeta(%q) returned true", s)
+		}
+	}
+}
+
+var matchTests = []struct {
+	pat     string
+	mode    Mode
+	matches []string
+	no      []string
+}{
+	{pat: "foo", matches: []string{"foo"}, no: []string{"", "fo", "foo2"}},
+	{pat: "*", matches: []string{"", "a", "a/b"}},
+	{pat: "*.go", matches: []string{"a.go", ".go", "a.b.go"}, no: []string{"a.goo"}},
+	{pat: "a*b*c", matches: []string{"abc", "aXbYc", "abbcc"}, no: []string{"acb"}},
+	{pat: "?", matches: []string{"a", "é"}, no: []string{"", "ab"}},
+	{pat: "*é?", matches: []string{"éé", "aéb"}, no: []string{"é"}},
+	{pat: `\*\?`, matches: []string{"*?"}, no: []string{"a?"}},
+	{pat: `a\`, matches: []string{`a\`}},
+	{pat: "[abc]", matches: []string{"a", "c"}, no: []string{"d", ""}},
+	{pat: "[!a-c]", matches: []string{"d", "-"}, no: []string{"b"}},
+	{pat: "[^a]", matches: []string{"b"}, no: []string{"a"}},
+	{pat: "[]a]", matches: []string{"]", "a"}},
+	{pat: "[a-]", matches: []string{"-", "a"}, no: []string{"b"}},
+	{pat: `[\]]`, matches: []string{"]"}},
+	{pat: "[abc", matches: []string{"[abc"}, no: []string{"a"}},
+	{pat: "[[:alpha:]]*", matches: []string{"a1", "Z"}, no: []string{"1a", ""}},
+	{pat: "[[:digit:][:space:]]", matches: []string{"3", " "}, no: []string{"a"}},
+	{pat: "[![:upper:]]", matches: []string{"a"}, no: []string{"A"}},
+	{pat: "[[:foo:]]", matches: []string{"[f]", "[:]"}, no: []string{"f"}},
+	{pat: "*/?", mode: Filenames, matches: []string{"a/b", "/b"}, no: []string{"a/b/c"}},
+	{pat: "[!a]", mode: Filenames, no: []string{"/"}},
+	{pat: "@(a|b)", mode: ExtGlob, matches: []string{"a", "b"}, no: []string{"", "ab"}},
+	{pat: "@(a|b)", matches: []string{"@(a|b)"}, no: []string{"a"}},
+	{pat: "?(a)x", mode: ExtGlob, matches: []string{"x", "ax"}, no: []string{"aax"}},
+	{pat: "*(ab)", mode: ExtGlob, matches: []string{"", "ab", "abab"}, no: []string{"aba"}},
+	{pat: "+([0-9])", mode: ExtGlob, matches: []string{"1", "123"}, no: []string{"", "1a"}},
+	{pat: "+(a|*.c)x", mode: ExtGlob, matches: []string{"ax", "b.cax"}, no: []string{"x"}},
+	{pat: "@(a|?(b))c", mode: ExtGlob, matches: []string{"ac", "bc", "c"}, no: []string{"abc"}},
+	{pat: "!(a)", mode: ExtGlob, matches: []string{"", "b", "aa"}, no: []string{"a"}},
+	{pat: "!(*.go|*.sh)", mode: ExtGlob, matches: []string{"a.c", "go"}, no: []string{"a.go", "b.sh"}},
+	{pat: "foo!(bar)", mode: ExtGlob, matches: []string{"foo", "foobaz"}, no: []string{"foobar"}},
+	{pat: "@(a", mode: ExtGlob, matches: []string{"@(a"}},
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+	for i, tc := range matchTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			for _, s := range tc.matches {
+				if !Match(tc.pat, s, tc.mode) {
+					t.Errorf("%q did not match %q", tc.pat, s)
+				}
+			}
+			for _, s := range tc.no {
+				if Match(tc.pat, s, tc.mode) {
+					t.Errorf("%q matched %q", tc.pat, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Modernization is a set of rewrites done by Modernize.
+type Modernization uint
+
+const (
+	// ModernTests rewrites [ ] and test commands to [[ ]].
+	ModernTests Modernization = 1 << iota
+	// ModernArithm rewrites $(expr ...) to $(( )).
+	ModernArithm
+	// ModernRanges rewrites $(seq ...) in for loops to brace ranges.
+	ModernRanges
+
+	ModernAll = ModernTests | ModernArithm | ModernRanges
+)
+
+// Modernize rewrites POSIX shell idioms in a file to their bash
+// equivalents, doing only the rewrites in m. The file is modified in
+// place and returned. bash is the version the result must run on, like
+// "3.2"; rewrites that need a newer version aren't done. If empty, any
+// recent version is assumed.
+//
+// These are converted:
+//
+//	[ -n "$a" -a "$b" = x ]   [[ -n "$a" && "$b" == x ]]
+//	test -f f                 [[ -f f ]]
+//	$(expr $n + 1)            $((n + 1))
+//	for i in $(seq 2 5)       for i in {2..5}
+//	for i in $(seq 1 2 9)     for i in {1..9..2}, since bash 4.0
+//
+// Constructs that would behave differently aren't converted, like a
+// test with unquoted patterns or "$@", or a seq that counts down.
+// Command substitutions with backquotes need no rewrite, as they are
+// always printed as $( ). An sh shebang is replaced by one for bash.
+func Modernize(f *syntax.File, m Modernization, bash string) *syntax.File {
+	if len(f.Comments) > 0 && f.Comments[0].Hash == 1 {
+		// the shebang
+		shebang := f.Comments[0]
+		shebang.Text = strings.Replace(shebang.Text, "/bin/sh", "/bin/bash", 1)
+		shebang.Text = strings.Replace(shebang.Text, "env sh", "env bash", 1)
+	}
+	syntax.Walk(&modernConv{m: m, bash: bash}, f)
+	return f
+}
+
+type modernConv struct {
+	m    Modernization
+	bash string
+}
+
+// since reports whether the target bash version is at least
+// major.minor.
+func (c *modernConv) since(major, minor int) bool {
+	if c.bash == "" {
+		return true
+	}
+	var vmajor, vminor int
+	fmt.Sscanf(c.bash, "%d.%d", &vmajor, &vminor)
+	return vmajor > major || (vmajor == major && vminor >= minor)
+}
+
+func (c *modernConv) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		if c.m&ModernTests != 0 && c.since(2, 2) && len(x.Assigns) == 0 {
+			if tc := extendedTest(x.Cmd); tc != nil {
+				x.Cmd = tc
+			}
+		}
+	case *syntax.Word:
+		c.parts(x.Parts)
+	case *syntax.DblQuoted:
+		c.parts(x.Parts)
+	case *syntax.WordIter:
+		if c.m&ModernRanges == 0 || !c.since(3, 0) {
+			break
+		}
+		for i, w := range x.List {
+			if r := c.braceRange(w); r != nil {
+				x.List[i] = r
+			}
+		}
+	}
+	return c
+}
+
+func (c *modernConv) parts(parts []syntax.WordPart) {
+	if c.m&ModernArithm == 0 {
+		return
+	}
+	for i, part := range parts {
+		if cs, ok := part.(*syntax.CmdSubst); ok {
+			if ae := exprArithm(cs); ae != nil {
+				parts[i] = ae
+			}
+		}
+	}
+}
+
+// simpleCall returns the arguments of the call in a command
+// substitution with a single, plain command whose name is name.
+func simpleCall(cs *syntax.CmdSubst, name string) []*syntax.Word {
+	if len(cs.Stmts) != 1 {
+		return nil
+	}
+	s := cs.Stmts[0]
+	if len(s.Assigns) > 0 || len(s.Redirs) > 0 || s.Negated || s.Background {
+		return nil
+	}
+	ce, ok := s.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return nil
+	}
+	if lit := singleLit(ce.Args[0]); lit == nil || lit.Value != name {
+		return nil
+	}
+	return ce.Args[1:]
+}
+
+// opValue returns the text of a word that may be an operator, with
+// the quotes or backslash that protect it in [ ] and expr removed.
+func opValue(w *syntax.Word) string {
+	if len(w.Parts) != 1 {
+		return ""
+	}
+	switch x := w.Parts[0].(type) {
+	case *syntax.Lit:
+		return strings.TrimPrefix(x.Value, "\\")
+	case *syntax.SglQuoted:
+		if !x.Dollar {
+			return x.Value
+		}
+	case *syntax.DblQuoted:
+		if len(x.Parts) == 1 {
+			if lit, ok := x.Parts[0].(*syntax.Lit); ok {
+				return lit.Value
+			}
+		}
+	}
+	return ""
+}
+
+// extendedTest returns the [[ ]] equivalent of a [ ] or test command,
+// or nil if it has none.
+func extendedTest(cmd syntax.Command) *syntax.TestClause {
+	ce, ok := cmd.(*syntax.CallExpr)
+	if !ok {
+		return nil
+	}
+	lit := singleLit(ce.Args[0])
+	if lit == nil {
+		return nil
+	}
+	args := ce.Args[1:]
+	switch lit.Value {
+	case "[":
+		if len(args) == 0 || opValue(args[len(args)-1]) != "]" {
+			return nil
+		}
+		args = args[:len(args)-1]
+	case "test":
+	default:
+		return nil
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	for _, w := range args {
+		if hasPattern(w) || splitsArgs(w) {
+			// [[ ]] would match the pattern, or keep the word
+			// as a single operand
+			return nil
+		}
+	}
+	tp := &testParser{args: args}
+	expr := tp.or()
+	if expr == nil || len(tp.args) > 0 {
+		return nil
+	}
+	return &syntax.TestClause{Left: ce.Pos(), Right: ce.End() - 2, X: expr}
+}
+
+// splitsArgs reports whether a word may expand to any number of
+// arguments, like "$@".
+func splitsArgs(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		parts := []syntax.WordPart{part}
+		if dq, ok := part.(*syntax.DblQuoted); ok {
+			parts = dq.Parts
+		}
+		for _, part := range parts {
+			pe, ok := part.(*syntax.ParamExp)
+			if ok && pe.Param != nil && (pe.Param.Value == "@" || pe.Param.Value == "*" || pe.Ind != nil) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	unTestOps = map[string]syntax.UnTestOperator{}
+
+	binTestOps = map[string]syntax.BinTestOperator{
+		"=":   syntax.TsEqual,
+		"==":  syntax.TsEqual,
+		"!=":  syntax.TsNequal,
+		"-nt": syntax.TsNewer,
+		"-ot": syntax.TsOlder,
+		"-ef": syntax.TsDevIno,
+		"-eq": syntax.TsEql,
+		"-ne": syntax.TsNeq,
+		"-le": syntax.TsLeq,
+		"-ge": syntax.TsGeq,
+		"-lt": syntax.TsLss,
+		"-gt": syntax.TsGtr,
+	}
+)
+
+func init() {
+	for op := syntax.TsExists; op <= syntax.TsRefVar; op++ {
+		unTestOps[op.String()] = op
+	}
+}
+
+// testParser parses the arguments of a [ ] command.
+type testParser struct {
+	args []*syntax.Word
+}
+
+func (p *testParser) peek(n int) string {
+	if n >= len(p.args) {
+		return ""
+	}
+	return opValue(p.args[n])
+}
+
+func (p *testParser) next() *syntax.Word {
+	w := p.args[0]
+	p.args = p.args[1:]
+	return w
+}
+
+func (p *testParser) or() syntax.TestExpr {
+	x := p.and()
+	for x != nil && p.peek(0) == "-o" {
+		pos := p.next().Pos()
+		y := p.and()
+		if y == nil {
+			return nil
+		}
+		x = &syntax.BinaryTest{OpPos: pos, Op: syntax.OrTest, X: x, Y: y}
+	}
+	return x
+}
+
+func (p *testParser) and() syntax.TestExpr {
+	x := p.not()
+	for x != nil && p.peek(0) == "-a" {
+		pos := p.next().Pos()
+		y := p.not()
+		if y == nil {
+			return nil
+		}
+		x = &syntax.BinaryTest{OpPos: pos, Op: syntax.AndTest, X: x, Y: y}
+	}
+	return x
+}
+
+func (p *testParser) not() syntax.TestExpr {
+	if p.peek(0) == "!" && len(p.args) > 1 {
+		pos := p.next().Pos()
+		x := p.not()
+		if x == nil {
+			return nil
+		}
+		return &syntax.UnaryTest{OpPos: pos, Op: syntax.TsNot, X: x}
+	}
+	return p.primary()
+}
+
+func (p *testParser) primary() syntax.TestExpr {
+	if len(p.args) == 0 {
+		return nil
+	}
+	if op, ok := binTestOps[p.peek(1)]; ok && len(p.args) > 2 {
+		x := p.next()
+		pos := p.next().Pos()
+		y := p.next()
+		if op == syntax.TsEqual || op == syntax.TsNequal {
+			// the right side of == is a pattern in [[ ]]
+			y = quoteWord(y)
+		}
+		return &syntax.BinaryTest{OpPos: pos, Op: op, X: x, Y: y}
+	}
+	switch p.peek(0) {
+	case "(":
+		lparen := p.next().Pos()
+		x := p.or()
+		if x == nil || p.peek(0) != ")" {
+			return nil
+		}
+		rparen := p.next().Pos()
+		return &syntax.ParenTest{Lparen: lparen, Rparen: rparen, X: x}
+	case ")", "-a", "-o":
+		return nil
+	}
+	if op, ok := unTestOps[p.peek(0)]; ok && len(p.args) > 1 {
+		pos := p.next().Pos()
+		return &syntax.UnaryTest{OpPos: pos, Op: op, X: p.next()}
+	}
+	return p.next()
+}
+
+// exprArithm returns the arithmetic expansion equivalent to a command
+// substitution of expr, or nil if it has none.
+func exprArithm(cs *syntax.CmdSubst) *syntax.ArithmExp {
+	args := simpleCall(cs, "expr")
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil
+	}
+	operands := make([]syntax.ArithmExpr, 0, len(args)/2+1)
+	ops := make([]syntax.BinAritOperator, 0, len(args)/2)
+	for i, w := range args {
+		if i%2 == 0 {
+			x := arithmOperand(w)
+			if x == nil {
+				return nil
+			}
+			operands = append(operands, x)
+			continue
+		}
+		switch opValue(w) {
+		case "+":
+			ops = append(ops, syntax.Add)
+		case "-":
+			ops = append(ops, syntax.Sub)
+		case "*":
+			ops = append(ops, syntax.Mul)
+		case "/":
+			ops = append(ops, syntax.Quo)
+		case "%":
+			ops = append(ops, syntax.Rem)
+		default:
+			return nil
+		}
+	}
+	// * / and % bind tighter than + and -, like in $(( ))
+	var sum, term syntax.ArithmExpr
+	var sumOp syntax.BinAritOperator
+	term = operands[0]
+	for i, op := range ops {
+		y := operands[i+1]
+		if op == syntax.Mul || op == syntax.Quo || op == syntax.Rem {
+			term = &syntax.BinaryArithm{Op: op, X: term, Y: y}
+			continue
+		}
+		if sum == nil {
+			sum = term
+		} else {
+			sum = &syntax.BinaryArithm{Op: sumOp, X: sum, Y: term}
+		}
+		sumOp, term = op, y
+	}
+	if sum != nil {
+		term = &syntax.BinaryArithm{Op: sumOp, X: sum, Y: term}
+	}
+	return &syntax.ArithmExp{Left: cs.Left, Right: cs.Right, X: term}
+}
+
+// arithmOperand returns the arithmetic operand equivalent to an expr
+// argument, which must be an integer or a plain parameter expansion.
+func arithmOperand(w *syntax.Word) syntax.ArithmExpr {
+	if len(w.Parts) != 1 {
+		return nil
+	}
+	part := w.Parts[0]
+	if dq, ok := part.(*syntax.DblQuoted); ok && len(dq.Parts) == 1 {
+		part = dq.Parts[0]
+	}
+	switch x := part.(type) {
+	case *syntax.Lit:
+		if _, err := strconv.Atoi(x.Value); err != nil {
+			return nil
+		}
+		return litWord(x.Value)
+	case *syntax.ParamExp:
+		if x.Length || x.Ind != nil || x.Slice != nil || x.Repl != nil || x.Exp != nil {
+			return nil
+		}
+		if validName(x.Param.Value) {
+			// $(( )) expands names on its own
+			return litWord(x.Param.Value)
+		}
+		return &syntax.Word{Parts: []syntax.WordPart{x}}
+	}
+	return nil
+}
+
+// braceRange returns the brace range equivalent to a word that is a
+// command substitution of seq, or nil if it has none.
+func (c *modernConv) braceRange(w *syntax.Word) *syntax.Word {
+	if len(w.Parts) != 1 {
+		return nil
+	}
+	cs, ok := w.Parts[0].(*syntax.CmdSubst)
+	if !ok {
+		return nil
+	}
+	args := simpleCall(cs, "seq")
+	if len(args) == 0 || len(args) > 3 {
+		return nil
+	}
+	nums := make([]int, len(args))
+	for i, arg := range args {
+		lit := singleLit(arg)
+		if lit == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(lit.Value)
+		if err != nil {
+			return nil
+		}
+		nums[i] = n
+	}
+	first, step, last := 1, 1, nums[len(nums)-1]
+	switch len(nums) {
+	case 2:
+		first = nums[0]
+	case 3:
+		first, step = nums[0], nums[1]
+	}
+	if step < 1 || first > last {
+		// seq prints nothing, but a brace range counts down
+		return nil
+	}
+	if step == 1 {
+		return litWord(fmt.Sprintf("{%d..%d}", first, last))
+	}
+	if !c.since(4, 0) {
+		return nil
+	}
+	return litWord(fmt.Sprintf("{%d..%d..%d}", first, last, step))
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestModernize(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+		m        Modernization
+		bash     string
+	}{
+		{"[ -n \"$a\" -a \"$b\" = x ]", "[[ -n \"$a\" && \"$b\" == x ]]\n", ModernAll, ""},
+		{"test -f f || [ ! -d $d ]", "[[ -f f ]] || [[ ! -d $d ]]\n", ModernAll, ""},
+		{"[ \\( a -o b \\) -a c ]", "[[ (a || b) && c ]]\n", ModernAll, ""},
+		{"[ $a = $b ]; [ $n -lt 3 ]", "[[ $a == \"$b\" ]]\n[[ $n -lt 3 ]]\n", ModernAll, ""},
+		{"[ \"${#:-a}\" = 1 ]", "[[ \"${#:-a}\" == 1 ]]\n", ModernAll, ""},
+		{"[ $a = *.sh ]; [ \"$@\" ]; [ ]", "[ $a = *.sh ]\n[ \"$@\" ]\n[ ]\n", ModernAll, ""},
+		{"echo `echo x`", "echo $(echo x)\n", ModernAll, ""},
+		{"n=$(expr $n + 1)", "n=$((n + 1))\n", ModernAll, ""},
+		{"echo \"$(expr 1 + \"$2\" \\* 3 - 4)\"", "echo \"$((1 + $2 * 3 - 4))\"\n", ModernAll, ""},
+		{"echo $(expr $a : x) $(expr $a)", "echo $(expr $a : x) $(expr $a)\n", ModernAll, ""},
+		{"for i in $(seq 5) $(seq 2 4); do :; done", "for i in {1..5} {2..4}; do :; done\n", ModernAll, ""},
+		{"for i in $(seq 1 2 9); do :; done", "for i in {1..9..2}; do :; done\n", ModernAll, ""},
+		{"for i in $(seq 1 2 9); do :; done", "for i in $(seq 1 2 9); do :; done\n", ModernAll, "3.2"},
+		{"for i in $(seq 5 1) $(seq $n); do :; done", "for i in $(seq 5 1) $(seq $n); do :; done\n", ModernAll, ""},
+		{"[ a ]; x=$(expr 1 + 1)", "[ a ]\nx=$((1 + 1))\n", ModernArithm | ModernRanges, ""},
+		{"[ a ]; x=$(expr 1 + 1)", "[[ a ]]\nx=$(expr 1 + 1)\n", ModernTests, ""},
+		{"#!/bin/sh -e\n[ a ]", "#!/bin/bash -e\n[[ a ]]\n", ModernAll, ""},
+		{"#!/usr/bin/env sh\nfoo", "#!/usr/bin/env bash\nfoo\n", ModernAll, ""},
+		{"#!/bin/bash\nfoo # /bin/sh", "#!/bin/bash\nfoo # /bin/sh\n", ModernAll, ""},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f := Modernize(parse(t, tc.in), tc.m, tc.bash)
+			if got := printFile(t, f); got != tc.want {
+				t.Fatalf("Modernize mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
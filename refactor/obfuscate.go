@@ -0,0 +1,169 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// specialVars are the variables that have a meaning to the shell or to
+// the programs it runs, so renaming them would change the behaviour of
+// a program.
+var specialVars = map[string]bool{
+	"BASH": true, "CDPATH": true, "COLUMNS": true, "COMPREPLY": true,
+	"EDITOR": true, "ENV": true, "EUID": true, "FUNCNAME": true,
+	"GLOBIGNORE": true, "GROUPS": true, "HISTFILE": true, "HOME": true,
+	"HOSTNAME": true, "IFS": true, "LANG": true, "LINENO": true,
+	"LINES": true, "MAIL": true, "OLDPWD": true, "OPTARG": true,
+	"OPTERR": true, "OPTIND": true, "PATH": true, "PIPESTATUS": true,
+	"PPID": true, "PROMPT_COMMAND": true, "PS1": true, "PS2": true,
+	"PS3": true, "PS4": true, "PWD": true, "RANDOM": true,
+	"REPLY": true, "SECONDS": true, "SHELL": true, "SHELLOPTS": true,
+	"SHLVL": true, "TERM": true, "TMOUT": true, "TMPDIR": true,
+	"UID": true, "USER": true,
+}
+
+func keepVar(name string) bool {
+	return specialVars[name] || strings.HasPrefix(name, "BASH_") ||
+		strings.HasPrefix(name, "LC_")
+}
+
+// Obfuscate renames the variables and functions declared in a file to
+// opaque names like v1 and f1, and removes all comments except the
+// shebang. The file is modified in place and returned.
+//
+// Only the variables that are assigned in the file are renamed, as
+// others may come from the environment. Variables and functions that
+// are exported, as well as variables with a special meaning like PATH
+// or IFS, keep their names. Names that are only referenced dynamically,
+// such as via eval or ${!name}, are not renamed consistently.
+func Obfuscate(f *syntax.File) *syntax.File {
+	used := make(map[string]bool)
+	assigned := make(map[string]bool)
+	exported := make(map[string]bool)
+	var varNames []string
+	for _, ref := range analysis.Vars(f) {
+		if !used[ref.Name] {
+			used[ref.Name] = true
+			varNames = append(varNames, ref.Name)
+		}
+		if ref.Kind == analysis.AssignRef {
+			assigned[ref.Name] = true
+		}
+	}
+	syntax.Walk(exportVisitor(exported), f)
+	table := analysis.Funcs(f)
+	var funcNames []string
+	for _, fn := range table.Funcs {
+		if table.Lookup(fn.Name)[0] == fn {
+			funcNames = append(funcNames, fn.Name)
+		}
+		used[fn.Name] = true
+	}
+	for _, c := range table.Calls {
+		used[c.Name] = true
+	}
+	n := 0
+	fresh := func(prefix string) string {
+		for {
+			n++
+			if name := fmt.Sprintf("%s%d", prefix, n); !used[name] {
+				used[name] = true
+				return name
+			}
+		}
+	}
+	for _, name := range varNames {
+		if assigned[name] && !exported[name] && !keepVar(name) {
+			RenameVar(f, name, fresh("v"))
+		}
+	}
+	n = 0
+	for _, name := range funcNames {
+		if !exported["-f "+name] {
+			RenameFunc(f, name, fresh("f"))
+		}
+	}
+	var comments []*syntax.Comment
+	if len(f.Comments) > 0 && f.Comments[0].Hash == 1 &&
+		strings.HasPrefix(f.Comments[0].Text, "!") {
+		comments = f.Comments[:1]
+	}
+	f.Comments = comments
+	return f
+}
+
+// exportVisitor records the names that are exported to other programs.
+// Exported functions are recorded with a "-f " prefix. Variables
+// assigned as a prefix to a command, like in "foo=bar cmd", are
+// considered exported too.
+type exportVisitor map[string]bool
+
+func (v exportVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		if _, ok := x.Cmd.(*syntax.CallExpr); ok {
+			for _, a := range x.Assigns {
+				if a.Name != nil {
+					v[a.Name.Value] = true
+				}
+			}
+		}
+	case *syntax.CallExpr:
+		// export is a regular command in POSIX mode
+		if lit := singleLit(x.Args[0]); lit == nil || lit.Value != "export" {
+			break
+		}
+		var opts []*syntax.Lit
+		for _, w := range x.Args[1:] {
+			opts = append(opts, singleLit(w))
+		}
+		prefix := ""
+		if hasFuncOpt(opts, "f") {
+			prefix = "-f "
+		}
+		for _, lit := range opts {
+			if lit != nil && len(lit.Value) > 0 && lit.Value[0] != '-' {
+				v[prefix+strings.SplitN(lit.Value, "=", 2)[0]] = true
+			}
+		}
+	case *syntax.DeclClause:
+		var opts []*syntax.Lit
+		for _, w := range x.Opts {
+			opts = append(opts, singleLit(w))
+		}
+		if x.Variant != "export" && !hasOpt(opts, 'x') {
+			break
+		}
+		prefix := ""
+		if hasFuncOpt(opts, "f") || (x.Variant == "" && hasFuncOpt(opts, "F")) {
+			prefix = "-f "
+		}
+		for _, a := range x.Assigns {
+			switch {
+			case a.Name != nil:
+				v[prefix+a.Name.Value] = true
+			case a.Value != nil:
+				if lit := singleLit(a.Value); lit != nil {
+					v[prefix+lit.Value] = true
+				}
+			}
+		}
+	}
+	return v
+}
+
+func hasOpt(lits []*syntax.Lit, opt byte) bool {
+	for _, lit := range lits {
+		if lit != nil && len(lit.Value) > 1 && lit.Value[0] == '-' &&
+			strings.IndexByte(lit.Value[1:], opt) >= 0 {
+			return true
+		}
+	}
+	return false
+}
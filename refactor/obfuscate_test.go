@@ -0,0 +1,37 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestObfuscate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"#!/bin/sh\n# secret\nfoo=1 # more\necho $foo", "#!/bin/sh\n\nv1=1\necho $v1\n"},
+		{"echo $HOME $unset; IFS=:; PATH=$PATH:x", "echo $HOME $unset\nIFS=:\nPATH=$PATH:x\n"},
+		{"export token=x; cfg=y; declare -x other=z", "export token=x\nv1=y\ndeclare -x other=z\n"},
+		{"debug=1 make; debug=2", "debug=1 make\ndebug=2\n"},
+		{
+			"deploy() { local host=$1; ssh \"$host\"; }; deploy prod",
+			"f1() {\n\tlocal v1=$1\n\tssh \"$v1\"\n}\nf1 prod\n",
+		},
+		{"helper() { :; }; export -f helper", "helper() { :; }\nexport -f helper\n"},
+		{"v1=a; b=c; f1() { :; }", "v2=a\nv3=c\nf2() { :; }\n"},
+		{"x=1; x() { echo $x; }; x", "v1=1\nf1() { echo $v1; }\nf1\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got := printFile(t, Obfuscate(parse(t, tc.in)))
+			if got != tc.want {
+				t.Fatalf("Obfuscate mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
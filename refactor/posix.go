@@ -0,0 +1,557 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"strings"
+
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Problem is a construct that a transformation couldn't convert.
+type Problem struct {
+	Pos     syntax.Pos
+	Message string
+}
+
+// ToPOSIX rewrites the bash constructs in a file to POSIX shell where it
+// can, so that the program runs in shells like dash or busybox ash. The
+// file is modified in place and returned, along with the constructs
+// that it couldn't convert, like arrays.
+//
+// These are converted:
+//
+//	[[ -n $a && $b == x ]]   [ -n "$a" ] && [ "$b" = x ]
+//	((n > 2))                [ $((n > 2)) -ne 0 ]
+//	$'a\tb'                  'a	b', with the escapes decoded
+//	${v//x/y}                $(printf '%s' "$v" | sed 's/x/y/g')
+//	declare -r v=x           readonly v=x
+//	v+=x                     v=${v}x
+//	function f {             f() {
+//	source f                 . f
+//	cmd &>f                  cmd >f 2>&1
+//	a |& b                   a 2>&1 | b
+//
+// Pattern matching in [[ ]], replacements with patterns, brace
+// expansions, indirect expansions and select can't be converted.
+// Declarations in functions become local variables via "local", which
+// isn't POSIX but is supported by dash, busybox ash and other shells
+// that ship as /bin/sh. Note that a command substitution drops the
+// trailing newlines of a replacement's result. A bash shebang is
+// replaced by one for sh.
+func ToPOSIX(f *syntax.File) (*syntax.File, []Problem) {
+	if len(f.Comments) > 0 && f.Comments[0].Hash == 1 {
+		// the shebang
+		shebang := f.Comments[0]
+		shebang.Text = strings.Replace(shebang.Text, "/bin/bash", "/bin/sh", 1)
+		shebang.Text = strings.Replace(shebang.Text, "env bash", "env sh", 1)
+	}
+	c := &posixConv{inBinary: make(map[*syntax.Stmt]bool)}
+	syntax.Walk(funcBodies{&c.funcs}, f)
+	syntax.Walk(c, f)
+	return f, c.problems
+}
+
+// funcBodies records the function declarations in a file.
+type funcBodies struct{ list *[]*syntax.FuncDecl }
+
+func (v funcBodies) Visit(node syntax.Node) syntax.Visitor {
+	if fd, ok := node.(*syntax.FuncDecl); ok {
+		*v.list = append(*v.list, fd)
+	}
+	return v
+}
+
+type posixConv struct {
+	funcs    []*syntax.FuncDecl
+	problems []Problem
+
+	// inBinary are the statements that are part of a binary command,
+	// which need a block if they become one themselves
+	inBinary map[*syntax.Stmt]bool
+}
+
+func (c *posixConv) problem(node syntax.Node, msg string) {
+	c.problems = append(c.problems, Problem{Pos: node.Pos(), Message: msg})
+}
+
+func (c *posixConv) inFunc(pos syntax.Pos) bool {
+	for _, fd := range c.funcs {
+		if fd.Pos() <= pos && pos < fd.End() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *posixConv) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.Stmt:
+		c.stmt(x)
+	case *syntax.BinaryCmd:
+		if x.Op == syntax.PipeAll {
+			x.Op = syntax.Pipe
+			x.X.Redirs = append(x.X.Redirs, dupStderr())
+		}
+		c.inBinary[x.X], c.inBinary[x.Y] = true, true
+	case *syntax.FuncDecl:
+		x.BashStyle = false
+	case *syntax.CallExpr:
+		c.call(x)
+	case *syntax.Word:
+		x.Parts = c.parts(x.Parts)
+	case *syntax.DblQuoted:
+		x.Dollar = false
+		x.Parts = c.parts(x.Parts)
+	case *syntax.Redirect:
+		if x.Op == syntax.WordHdoc {
+			c.problem(x, "here-strings aren't POSIX; use a heredoc or a pipe")
+		}
+	case *syntax.ParamExp:
+		c.paramExp(x)
+	case *syntax.UnaryArithm:
+		if x.Op == syntax.Inc || x.Op == syntax.Dec {
+			c.problem(x, x.Op.String()+" isn't POSIX; use an assignment like n=$((n + 1))")
+		}
+	case *syntax.BinaryArithm:
+		if x.Op == syntax.Pow {
+			c.problem(x, "** isn't POSIX")
+		}
+	case *syntax.ArrayExpr:
+		c.problem(x, "arrays aren't POSIX")
+	case *syntax.ProcSubst:
+		c.problem(x, "process substitutions aren't POSIX; use a pipe or a temporary file")
+	case *syntax.ExtGlob:
+		c.problem(x, "extended globs aren't POSIX")
+	case *syntax.CStyleLoop:
+		c.problem(x, "C-style for loops aren't POSIX; use a while loop")
+	case *syntax.WordIter:
+		c.braces(x.List)
+	case *syntax.LetClause:
+		c.problem(x, "let isn't POSIX; use $(( ))")
+	case *syntax.CoprocClause:
+		c.problem(x, "coprocesses aren't POSIX")
+	}
+	return c
+}
+
+// dupStderr returns the redirection 2>&1.
+func dupStderr() *syntax.Redirect {
+	return &syntax.Redirect{
+		Op:   syntax.DplOut,
+		N:    &syntax.Lit{Value: "2"},
+		Word: litWord("1"),
+	}
+}
+
+func litWord(s string) *syntax.Word {
+	return &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: s}}}
+}
+
+func (c *posixConv) stmt(s *syntax.Stmt) {
+	for i := 0; i < len(s.Redirs); i++ {
+		r := s.Redirs[i]
+		switch r.Op {
+		case syntax.RdrAll, syntax.AppAll:
+			if r.Op == syntax.RdrAll {
+				r.Op = syntax.RdrOut
+			} else {
+				r.Op = syntax.AppOut
+			}
+			s.Redirs = append(s.Redirs[:i+1], append([]*syntax.Redirect{dupStderr()}, s.Redirs[i+1:]...)...)
+			i++
+		}
+	}
+	for _, as := range s.Assigns {
+		c.assign(as)
+	}
+	var conv *syntax.Stmt
+	switch x := s.Cmd.(type) {
+	case *syntax.TestClause:
+		conv = c.test(x.X)
+	case *syntax.ArithmCmd:
+		conv = &syntax.Stmt{Cmd: &syntax.CallExpr{Args: []*syntax.Word{
+			litWord("["),
+			{Parts: []syntax.WordPart{&syntax.ArithmExp{X: x.X}}},
+			litWord("-ne"), litWord("0"), litWord("]"),
+		}}}
+	case *syntax.DeclClause:
+		c.decl(s, x)
+	}
+	if conv == nil {
+		return
+	}
+	if _, ok := conv.Cmd.(*syntax.BinaryCmd); ok &&
+		(s.Negated || len(s.Redirs) > 0 || c.inBinary[s]) {
+		conv = block(conv)
+	}
+	s.Cmd = conv.Cmd
+	s.Negated = s.Negated != conv.Negated
+}
+
+func block(s *syntax.Stmt) *syntax.Stmt {
+	return &syntax.Stmt{Cmd: &syntax.Block{Stmts: []*syntax.Stmt{s}}}
+}
+
+// test converts the expression of a [[ ]] test to [ ] commands, or
+// returns nil if it can't.
+func (c *posixConv) test(expr syntax.TestExpr) *syntax.Stmt {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return testCall(litWord("-n"), quoteWord(x))
+	case *syntax.ParenTest:
+		s := c.test(x.X)
+		if s == nil {
+			return nil
+		}
+		if _, ok := s.Cmd.(*syntax.BinaryCmd); ok {
+			s = block(s)
+		}
+		return s
+	case *syntax.UnaryTest:
+		switch x.Op {
+		case syntax.TsNot:
+			s := c.test(x.X)
+			if s == nil {
+				return nil
+			}
+			if _, ok := s.Cmd.(*syntax.BinaryCmd); ok {
+				s = block(s)
+			}
+			s.Negated = !s.Negated
+			return s
+		case syntax.TsOptSet, syntax.TsVarSet, syntax.TsRefVar:
+			c.problem(x, "[[ "+x.Op.String()+" ]] isn't POSIX")
+			return nil
+		}
+		w, ok := x.X.(*syntax.Word)
+		if !ok {
+			c.problem(x, "[[ ]] with a nested expression can't be converted")
+			return nil
+		}
+		return testCall(litWord(x.Op.String()), quoteWord(w))
+	case *syntax.BinaryTest:
+		switch x.Op {
+		case syntax.AndTest, syntax.OrTest:
+			l, r := c.test(x.X), c.test(x.Y)
+			if l == nil || r == nil {
+				return nil
+			}
+			if _, ok := r.Cmd.(*syntax.BinaryCmd); ok {
+				// shell lists have no precedence, unlike tests
+				r = block(r)
+			}
+			op := syntax.AndStmt
+			if x.Op == syntax.OrTest {
+				op = syntax.OrStmt
+			}
+			return &syntax.Stmt{Cmd: &syntax.BinaryCmd{Op: op, X: l, Y: r}}
+		case syntax.TsReMatch:
+			c.problem(x, "[[ =~ ]] isn't POSIX; use case, grep or expr")
+			return nil
+		case syntax.TsBefore, syntax.TsAfter:
+			c.problem(x, "[[ "+x.Op.String()+" ]] string comparisons aren't POSIX")
+			return nil
+		case syntax.TsNewer, syntax.TsOlder, syntax.TsDevIno:
+			c.problem(x, "[[ "+x.Op.String()+" ]] isn't POSIX")
+			return nil
+		}
+		l, ok1 := x.X.(*syntax.Word)
+		r, ok2 := x.Y.(*syntax.Word)
+		if !ok1 || !ok2 {
+			c.problem(x, "[[ ]] with a nested expression can't be converted")
+			return nil
+		}
+		op := x.Op.String()
+		switch x.Op {
+		case syntax.TsEqual, syntax.TsAssgn, syntax.TsNequal:
+			if hasPattern(r) {
+				c.problem(x, "pattern matching in [[ ]] isn't POSIX; use case")
+				return nil
+			}
+			if x.Op != syntax.TsNequal {
+				op = "="
+			}
+		}
+		return testCall(quoteWord(l), litWord(op), quoteWord(r))
+	}
+	return nil
+}
+
+func testCall(args ...*syntax.Word) *syntax.Stmt {
+	args = append([]*syntax.Word{litWord("[")}, args...)
+	args = append(args, litWord("]"))
+	return &syntax.Stmt{Cmd: &syntax.CallExpr{Args: args}}
+}
+
+// hasPattern reports whether a word has unquoted pattern characters.
+func hasPattern(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			if strings.ContainsAny(x.Value, "*?[") {
+				return true
+			}
+		case *syntax.ExtGlob:
+			return true
+		}
+	}
+	return false
+}
+
+// quoteWord quotes the expansions and pattern characters of a word, as
+// [[ ]] doesn't split or glob its operands, unlike [ ].
+func quoteWord(w *syntax.Word) *syntax.Word {
+	parts := make([]syntax.WordPart, len(w.Parts))
+	for i, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst, *syntax.ArithmExp:
+			part = &syntax.DblQuoted{Parts: []syntax.WordPart{x}}
+		case *syntax.Lit:
+			if strings.ContainsAny(x.Value, "*?[") && !strings.ContainsAny(x.Value, "\\'") {
+				part = &syntax.SglQuoted{Position: x.ValuePos, Value: x.Value}
+			}
+		}
+		parts[i] = part
+	}
+	return &syntax.Word{Parts: parts}
+}
+
+func (c *posixConv) call(ce *syntax.CallExpr) {
+	lit := singleLit(ce.Args[0])
+	if lit == nil {
+		return
+	}
+	c.braces(ce.Args)
+	switch lit.Value {
+	case "source":
+		lit.Value = "."
+	case "select":
+		c.problem(ce, "select isn't POSIX; use a while loop with read")
+	case "[", "test":
+		for _, w := range ce.Args[1:] {
+			if l := singleLit(w); l != nil && l.Value == "==" {
+				l.Value = "="
+			}
+		}
+	}
+}
+
+// braces reports the words in a list that are subject to brace
+// expansion, which can't be converted in general.
+func (c *posixConv) braces(words []*syntax.Word) {
+	for _, w := range words {
+		if len(expand.Braces(w)) > 1 {
+			c.problem(w, "brace expansions aren't POSIX")
+		}
+	}
+}
+
+// parts converts the quotes and expansions in a list of word parts.
+func (c *posixConv) parts(parts []syntax.WordPart) []syntax.WordPart {
+	var res []syntax.WordPart
+	for _, part := range parts {
+		switch x := part.(type) {
+		case *syntax.SglQuoted:
+			if x.Dollar {
+				res = append(res, ansiQuoted(x)...)
+				continue
+			}
+		case *syntax.ParamExp:
+			if x.Repl != nil {
+				if cs := c.replace(x); cs != nil {
+					res = append(res, cs)
+					continue
+				}
+			}
+		}
+		res = append(res, part)
+	}
+	return res
+}
+
+// ansiQuoted returns the plain single-quoted parts equivalent to a
+// string like $'a\tb', with its escapes decoded. Single quotes are
+// escaped outside of the quotes.
+func ansiQuoted(sq *syntax.SglQuoted) []syntax.WordPart {
+	value, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{sq}})
+	var parts []syntax.WordPart
+	for i, s := range strings.Split(value, "'") {
+		if i > 0 {
+			parts = append(parts, &syntax.Lit{Value: `\'`})
+		}
+		if s != "" || i == 0 && !strings.Contains(value, "'") {
+			parts = append(parts, &syntax.SglQuoted{Position: sq.Position, Value: s})
+		}
+	}
+	return parts
+}
+
+func (c *posixConv) paramExp(pe *syntax.ParamExp) {
+	switch {
+	case pe.Ind != nil:
+		c.problem(pe, "arrays aren't POSIX")
+	case pe.Param != nil && len(pe.Param.Value) > 1 && pe.Param.Value[0] == '!':
+		c.problem(pe, "indirect expansions like ${!ref} aren't POSIX; use eval")
+	case pe.Slice != nil:
+		c.problem(pe, "${v:offset:length} isn't POSIX")
+	case pe.Repl != nil:
+		c.problem(pe, "${v/pattern/string} with a pattern can't be converted")
+	case pe.Exp != nil && pe.Exp.Op >= syntax.UpperFirst && pe.Exp.Op <= syntax.LowerAll:
+		c.problem(pe, "case conversions like ${v"+pe.Exp.Op.String()+"} aren't POSIX; use tr")
+	}
+}
+
+// replace converts an expansion like ${v//x/y} to a sed command, or
+// returns nil if it can't, leaving it to be reported.
+func (c *posixConv) replace(pe *syntax.ParamExp) *syntax.CmdSubst {
+	if pe.Ind != nil || pe.Length || pe.Param == nil || !validName(pe.Param.Value) {
+		return nil
+	}
+	orig, ok1 := syntax.StaticValue(pe.Repl.Orig)
+	with, ok2 := syntax.StaticValue(pe.Repl.With)
+	if !ok1 || !ok2 || hasPattern(pe.Repl.Orig) || orig == "" ||
+		strings.ContainsAny(orig+with, "\n'") {
+		return nil
+	}
+	anchor := ""
+	switch orig[0] {
+	case '#', '%':
+		anchor, orig = orig[:1], orig[1:]
+	}
+	re := escapeSed(orig, `\/.[]*^$`)
+	switch anchor {
+	case "#":
+		re = "^" + re
+	case "%":
+		re += "$"
+	}
+	script := "s/" + re + "/" + escapeSed(with, `\/&`) + "/"
+	if pe.Repl.All && anchor == "" {
+		script += "g"
+	}
+	printf := &syntax.Stmt{Cmd: &syntax.CallExpr{Args: []*syntax.Word{
+		litWord("printf"),
+		{Parts: []syntax.WordPart{&syntax.SglQuoted{Value: "%s"}}},
+		{Parts: []syntax.WordPart{&syntax.DblQuoted{Parts: []syntax.WordPart{
+			&syntax.ParamExp{Short: true, Param: &syntax.Lit{Value: pe.Param.Value}},
+		}}}},
+	}}}
+	sed := &syntax.Stmt{Cmd: &syntax.CallExpr{Args: []*syntax.Word{
+		litWord("sed"),
+		{Parts: []syntax.WordPart{&syntax.SglQuoted{Value: script}}},
+	}}}
+	return &syntax.CmdSubst{Stmts: []*syntax.Stmt{{
+		Cmd: &syntax.BinaryCmd{Op: syntax.Pipe, X: printf, Y: sed},
+	}}}
+}
+
+func escapeSed(s, special string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) >= 0 {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+func validName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// assign converts appending assignments like v+=x.
+func (c *posixConv) assign(as *syntax.Assign) {
+	if !as.Append || as.Name == nil {
+		return
+	}
+	if as.Value != nil && len(as.Value.Parts) == 1 {
+		if _, ok := as.Value.Parts[0].(*syntax.ArrayExpr); ok {
+			return // reported as an array
+		}
+	}
+	as.Append = false
+	parts := []syntax.WordPart{&syntax.ParamExp{Param: &syntax.Lit{Value: as.Name.Value}}}
+	if as.Value != nil {
+		parts = append(parts, as.Value.Parts...)
+	}
+	as.Value = &syntax.Word{Parts: parts}
+}
+
+// decl converts declare, typeset and local to their POSIX equivalents.
+func (c *posixConv) decl(s *syntax.Stmt, dc *syntax.DeclClause) {
+	for _, as := range dc.Assigns {
+		c.assign(as)
+	}
+	var opts []*syntax.Lit
+	for _, w := range dc.Opts {
+		lit := singleLit(w)
+		if lit == nil {
+			c.problem(dc, "declaration options that aren't static can't be converted")
+			return
+		}
+		opts = append(opts, lit)
+	}
+	convOpts := func(allowed string) bool {
+		for _, lit := range opts {
+			if lit.Value == "" || lit.Value[0] != '-' ||
+				strings.Trim(lit.Value[1:], allowed) != "" {
+				return false
+			}
+		}
+		return true
+	}
+	switch dc.Variant {
+	case "export", "readonly":
+		if !convOpts("p") {
+			c.problem(dc, dc.Variant+" with options other than -p isn't POSIX")
+		}
+		return
+	case "nameref":
+		c.problem(dc, "namerefs aren't POSIX")
+		return
+	case "local":
+		if len(opts) > 0 {
+			c.problem(dc, "local with options can't be converted")
+		}
+		return
+	}
+	if len(opts) > 1 {
+		c.problem(dc, "declarations with multiple options can't be converted")
+		return
+	}
+	switch {
+	case c.inFunc(dc.Pos()):
+		if len(opts) > 0 {
+			c.problem(dc, "declarations with options in functions can't be converted")
+			return
+		}
+		dc.Variant = "local"
+	case len(opts) == 0:
+		for _, as := range dc.Assigns {
+			if as.Name == nil || as.Value == nil {
+				c.problem(dc, "declarations without values can't be converted")
+				return
+			}
+		}
+		s.Cmd = nil
+		s.Assigns = append(s.Assigns, dc.Assigns...)
+	case opts[0].Value == "-x":
+		dc.Variant, dc.Opts = "export", nil
+	case opts[0].Value == "-r":
+		dc.Variant, dc.Opts = "readonly", nil
+	default:
+		c.problem(dc, "declare "+opts[0].Value+" isn't POSIX")
+	}
+}
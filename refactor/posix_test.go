@@ -0,0 +1,81 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestToPOSIX(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+		problems []string
+	}{
+		{"#!/bin/bash\nfoo", "#!/bin/sh\nfoo\n", nil},
+		{"#!/usr/bin/env bash\nfoo", "#!/usr/bin/env sh\nfoo\n", nil},
+		{"[[ -n $a && $b == x ]]", "[ -n \"$a\" ] && [ \"$b\" = x ]\n", nil},
+		{"[[ $a ]]; [[ -f $d/*.sh ]]", "[ -n \"$a\" ]\n[ -f \"$d\"'/*.sh' ]\n", nil},
+		{"x || [[ a && b ]]", "x || { [ -n a ] && [ -n b ]; }\n", nil},
+		{"[[ a || b && c ]]", "[ -n a ] || { [ -n b ] && [ -n c ]; }\n", nil},
+		{"! [[ a || b ]]", "! { [ -n a ] || [ -n b ]; }\n", nil},
+		{"[[ ! -d $d ]]", "! [ -d \"$d\" ]\n", nil},
+		{"((n > 2)) && echo", "[ $((n > 2)) -ne 0 ] && echo\n", nil},
+		{`echo $'a\tb\x27c' $"d"`, "echo 'a\tb'\\''c' \"d\"\n", nil},
+		{
+			`echo "${v//a.b/c&d}" ${v/#x/y}`,
+			"echo \"$(printf '%s' \"$v\" | sed 's/a\\.b/c\\&d/g')\" $(printf '%s' \"$v\" | sed 's/^x/y/')\n",
+			nil,
+		},
+		{"declare -r v=x; declare -x e=1; typeset a=1 b=2", "readonly v=x\nexport e=1\na=1 b=2\n", nil},
+		{"f() { declare l=1; }", "f() { local l=1; }\n", nil},
+		{"v+=x; function g { source lib.sh; }", "v=${v}x\ng() { . lib.sh; }\n", nil},
+		{"cmd &>f; cmd &>>f; a |& b; [ a == b ]", "cmd >f 2>&1\ncmd >>f 2>&1\na 2>&1 | b\n[ a = b ]\n", nil},
+		{"[[ $a =~ x ]]", "[[ $a =~ x ]]\n", []string{"1:4: [[ =~ ]] isn't POSIX; use case, grep or expr"}},
+		{"[[ $a == *.sh ]]", "[[ $a == *.sh ]]\n", []string{"1:4: pattern matching in [[ ]] isn't POSIX; use case"}},
+		{"echo ${v/x*/y}", "echo ${v/x*/y}\n", []string{"1:6: ${v/pattern/string} with a pattern can't be converted"}},
+		{
+			"a=(1 2); echo ${a[0]} ${v:1} ${v^^} <(ls)",
+			"a=(1 2)\necho ${a[0]} ${v:1} ${v^^} <(ls)\n",
+			[]string{
+				"1:3: arrays aren't POSIX",
+				"1:15: arrays aren't POSIX",
+				"1:23: ${v:offset:length} isn't POSIX",
+				"1:30: case conversions like ${v^^} aren't POSIX; use tr",
+				"1:37: process substitutions aren't POSIX; use a pipe or a temporary file",
+			},
+		},
+		{"let x=1; cat <<<x", "let x=1\ncat <<<x\n", []string{
+			"1:1: let isn't POSIX; use $(( ))",
+			"1:14: here-strings aren't POSIX; use a heredoc or a pipe",
+		}},
+		{"for i in {1..3}; do echo {a,b} ${!ref}; done; select x in a b; do :; done", "for i in {1..3}; do echo {a,b} ${!ref}; done\nselect x in a b\ndo :\ndone\n", []string{
+			"1:10: brace expansions aren't POSIX",
+			"1:26: brace expansions aren't POSIX",
+			"1:32: indirect expansions like ${!ref} aren't POSIX; use eval",
+			"1:47: select isn't POSIX; use a while loop with read",
+		}},
+		{"f() { declare -i n; }", "f() { declare -i n; }\n", []string{
+			"1:7: declarations with options in functions can't be converted",
+		}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, problems := ToPOSIX(parse(t, tc.in))
+			if got := printFile(t, f); got != tc.want {
+				t.Fatalf("ToPOSIX mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+			var got []string
+			for _, p := range problems {
+				pos := f.Position(p.Pos)
+				got = append(got, fmt.Sprintf("%d:%d: %s", pos.Line, pos.Column, p.Message))
+			}
+			if !reflect.DeepEqual(got, tc.problems) {
+				t.Fatalf("problems mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.problems, got)
+			}
+		})
+	}
+}
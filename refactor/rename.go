@@ -0,0 +1,156 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package refactor implements transformations of shell programs that
+// preserve their behaviour.
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/analysis"
+	"github.com/mvdan/sh/syntax"
+)
+
+// RenameVar renames all references to a variable in a file, including
+// expansions, assignments, arithmetic expressions and builtins such as
+// export, unset or read. The file is modified in place and returned,
+// along with the original positions of the renamed references.
+func RenameVar(f *syntax.File, from, to string) (*syntax.File, []syntax.Pos, error) {
+	if !analysis.ValidName(to) {
+		return nil, nil, fmt.Errorf("invalid variable name: %q", to)
+	}
+	var changed []syntax.Pos
+	for _, ref := range analysis.Vars(f) {
+		if ref.Name == from {
+			renameLit(ref.Lit, from, to)
+			changed = append(changed, ref.Pos())
+		}
+	}
+	return f, changed, nil
+}
+
+// RenameFunc renames a function in a file, including its declarations,
+// its call sites and builtins such as "unset -f" or "export -f". The
+// file is modified in place and returned, along with the original
+// positions of the renamed references.
+func RenameFunc(f *syntax.File, from, to string) (*syntax.File, []syntax.Pos, error) {
+	if !validFuncName(to) {
+		return nil, nil, fmt.Errorf("invalid function name: %q", to)
+	}
+	lits := funcRefs(f, from)
+	changed := make([]syntax.Pos, len(lits))
+	for i, lit := range lits {
+		renameLit(lit, from, to)
+		changed[i] = lit.Pos()
+	}
+	return f, changed, nil
+}
+
+func renameLit(lit *syntax.Lit, from, to string) {
+	lit.Value = to + lit.Value[len(from):]
+}
+
+func validFuncName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\n\\'\"`$;&|<>(){}[]=#*?")
+}
+
+// funcRefs returns the literals that refer to a function name, in
+// source order.
+func funcRefs(f *syntax.File, name string) []*syntax.Lit {
+	var lits []*syntax.Lit
+	syntax.Walk(funcRefVisitor(func(lit *syntax.Lit) {
+		if lit.Value == name {
+			lits = append(lits, lit)
+		}
+	}), f)
+	return lits
+}
+
+type funcRefVisitor func(*syntax.Lit)
+
+func (v funcRefVisitor) Visit(node syntax.Node) syntax.Visitor {
+	switch x := node.(type) {
+	case *syntax.FuncDecl:
+		v(x.Name)
+	case *syntax.CallExpr:
+		lits := make([]*syntax.Lit, len(x.Args))
+		for i, w := range x.Args {
+			lits[i] = singleLit(w)
+		}
+		if lits[0] == nil {
+			break
+		}
+		switch lits[0].Value {
+		case "unset", "export", "declare", "typeset", "readonly":
+			// only declare and typeset list functions with -F
+			funcOpts := "f"
+			if lits[0].Value == "declare" || lits[0].Value == "typeset" {
+				funcOpts = "fF"
+			}
+			if !hasFuncOpt(lits[1:], funcOpts) {
+				v(lits[0])
+				break
+			}
+			for _, lit := range lits[1:] {
+				if lit != nil && len(lit.Value) > 0 && lit.Value[0] != '-' {
+					v(lit)
+				}
+			}
+		case "command", "builtin", "type", "exec":
+			for _, lit := range lits[1:] {
+				if lit != nil && len(lit.Value) > 0 && lit.Value[0] != '-' {
+					v(lit)
+					break
+				}
+			}
+		default:
+			v(lits[0])
+		}
+	case *syntax.DeclClause:
+		var opts []*syntax.Lit
+		for _, w := range x.Opts {
+			opts = append(opts, singleLit(w))
+		}
+		funcOpts := "f"
+		if x.Variant == "" {
+			// declare or typeset
+			funcOpts = "fF"
+		}
+		if !hasFuncOpt(opts, funcOpts) {
+			break
+		}
+		for _, a := range x.Assigns {
+			if a.Name != nil || a.Value == nil {
+				continue
+			}
+			if lit := singleLit(a.Value); lit != nil {
+				v(lit)
+			}
+		}
+	}
+	return v
+}
+
+// hasFuncOpt reports whether any of the options in lits is one of the
+// letters in funcOpts, which make a builtin work on functions.
+func hasFuncOpt(lits []*syntax.Lit, funcOpts string) bool {
+	for _, lit := range lits {
+		if lit == nil || len(lit.Value) < 2 || lit.Value[0] != '-' {
+			continue
+		}
+		if strings.ContainsAny(lit.Value[1:], funcOpts) {
+			return true
+		}
+	}
+	return false
+}
+
+func singleLit(w *syntax.Word) *syntax.Lit {
+	if len(w.Parts) != 1 {
+		return nil
+	}
+	lit, _ := w.Parts[0].(*syntax.Lit)
+	return lit
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package refactor
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func parse(tb testing.TB, src string) *syntax.File {
+	f, err := syntax.Parse([]byte(src), "", syntax.ParseComments)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return f
+}
+
+func printFile(tb testing.TB, f *syntax.File) string {
+	var buf bytes.Buffer
+	if err := syntax.Fprint(&buf, f); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.String()
+}
+
+var renameVarTests = []struct {
+	in, want string
+	changed  int
+}{
+	{"foo=1; echo $foo ${foo} foo", "bar=1\necho $bar ${bar} foo\n", 3},
+	{"echo ${foo:-$foo} ${#foo} ${foo[1]}", "echo ${bar:-$bar} ${#bar} ${bar[1]}\n", 4},
+	{"((foo++)); echo $((foo + 2)); let foo=foo*2", "((bar++))\necho $((bar + 2))\nlet bar=bar*2\n", 4},
+	{"export foo; local foo=x; unset foo", "export bar\nlocal bar=x\nunset bar\n", 3},
+	{"read -r foo; for foo in a; do :; done", "read -r bar\nfor bar in a; do :; done\n", 2},
+	{"foo() { :; }; foo; echo $foobar", "foo() { :; }\nfoo\necho $foobar\n", 0},
+	{"arr[2]=x; echo ${arr[foo]}", "arr[2]=x\necho ${arr[bar]}\n", 1},
+}
+
+func TestRenameVar(t *testing.T) {
+	t.Parallel()
+	for i, tc := range renameVarTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, changed, err := RenameVar(parse(t, tc.in), "foo", "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := printFile(t, f); got != tc.want {
+				t.Fatalf("RenameVar mismatch\nwant: %q\ngot:  %q", tc.want, got)
+			}
+			if len(changed) != tc.changed {
+				t.Fatalf("wanted %d changes, got %d", tc.changed, len(changed))
+			}
+		})
+	}
+}
+
+var renameFuncTests = []struct {
+	in, want string
+}{
+	{"foo() { :; }; foo a", "bar() { :; }\nbar a\n"},
+	{"function foo { foo; }; echo foo", "function bar() { bar; }\necho foo\n"},
+	{"unset -f foo; export -f foo; unset foo", "unset -f bar\nexport -f bar\nunset foo\n"},
+	{"command foo; foo=1", "command bar\nfoo=1\n"},
+	{"unset -F foo; export -F foo; declare -F foo", "unset -F foo\nexport -F foo\ndeclare -F bar\n"},
+}
+
+func TestRenameFunc(t *testing.T) {
+	t.Parallel()
+	for i, tc := range renameFuncTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, _, err := RenameFunc(parse(t, tc.in), "foo", "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := printFile(t, f); got != tc.want {
+				t.Fatalf("RenameFunc mismatch\nwant: %q\ngot:  %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRenameInvalid(t *testing.T) {
+	t.Parallel()
+	if _, _, err := RenameVar(parse(t, "foo=1"), "foo", "1a"); err == nil {
+		t.Fatal("expected error on invalid variable name")
+	}
+	if _, _, err := RenameFunc(parse(t, "foo"), "foo", "a b"); err == nil {
+		t.Fatal("expected error on invalid function name")
+	}
+}
+
+func TestRenameFuncEmptyLit(t *testing.T) {
+	t.Parallel()
+	// hand-built trees may have empty literals
+	f := parse(t, "unset -f x foo; command x foo")
+	for i, s := range f.Stmts {
+		s.Cmd.(*syntax.CallExpr).Args[2-i].Parts[0].(*syntax.Lit).Value = ""
+	}
+	if _, _, err := RenameFunc(f, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell_test
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/shell"
+)
+
+func ExampleExpand() {
+	env := func(name string) string {
+		if name == "USER" {
+			return "gopher"
+		}
+		return ""
+	}
+	s, err := shell.Expand("/home/$USER/${DIR:-src}", env)
+	if err != nil {
+		return
+	}
+	fmt.Println(s)
+	// Output: /home/gopher/src
+}
+
+func ExampleFields() {
+	args, err := shell.Fields(`grep -r "foo bar" 'dir/'`)
+	if err != nil {
+		return
+	}
+	for _, arg := range args {
+		fmt.Println(arg)
+	}
+	// Output:
+	// grep
+	// -r
+	// foo bar
+	// dir/
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mvdan/sh/expand"
+)
+
+// Expand performs shell expansion on s, using env to resolve variables.
+// If env is nil, os.Getenv is used.
+//
+// The string is parsed as a list of words, each of which is expanded
+// without field splitting nor filename expansion, and joined with
+// single spaces. Parameter expansions like $VAR and ${VAR:-default},
+// arithmetic expansions and tildes are supported. Command
+// substitutions result in an error, as no commands are run.
+//
+// Variables that env resolves to the empty string are considered
+// unset.
+func Expand(s string, env func(string) string) (string, error) {
+	words, err := parseWords(s)
+	if err != nil {
+		return "", err
+	}
+	if env == nil {
+		env = os.Getenv
+	}
+	cfg := expand.Config{Env: &funcEnviron{get: env}}
+	fields := make([]string, len(words))
+	for i, w := range words {
+		if fields[i], err = cfg.Literal(w); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// funcEnviron implements expand.Environ on top of a function. Variables
+// that are assigned to, like with ${VAR:=value}, are kept separately.
+type funcEnviron struct {
+	get func(string) string
+	set expand.MapEnviron
+}
+
+func (f *funcEnviron) Get(name string) expand.Variable {
+	if vr, ok := f.set[name]; ok {
+		return vr
+	}
+	val := f.get(name)
+	return expand.Variable{Set: val != "", Exported: true, Value: val}
+}
+
+func (f *funcEnviron) Set(name string, vr expand.Variable) error {
+	if f.set == nil {
+		f.set = make(expand.MapEnviron)
+	}
+	// keep unset variables, so that they aren't read from get
+	f.set[name] = vr
+	return nil
+}
+
+// Each only visits the variables that were set, as the ones behind get
+// cannot be listed.
+func (f *funcEnviron) Each(fn func(name string, vr expand.Variable) bool) {
+	f.set.Each(fn)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testEnv(name string) string {
+	switch name {
+	case "foo":
+		return "bar"
+	case "HOME":
+		return "/home/user"
+	case "sp":
+		return "a  b"
+	}
+	return ""
+}
+
+var expandTests = []struct {
+	in, want string
+}{
+	{"", ""},
+	{"foo", "foo"},
+	{"a   b", "a b"},
+	{"$foo", "bar"},
+	{"${foo}x $foo", "barx bar"},
+	{"$sp '$sp'", "a  b $sp"},
+	{`"x  y" \$foo`, "x  y $foo"},
+	{"${unset:-def} ${foo:-def}", "def bar"},
+	{"${empty-def}", "def"},
+	{"${unset:=new} $unset", "new new"},
+	{"${foo:+set}${unset:+set}", "set"},
+	{"~/dir ~", "/home/user/dir /home/user"},
+	{"$((1 + 2))", "3"},
+	{"a=$foo if", "a=bar if"},
+	{"--flag=${foo#b}", "--flag=ar"},
+	{"*", "*"},
+}
+
+func TestExpand(t *testing.T) {
+	t.Parallel()
+	for i, tc := range expandTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := Expand(tc.in, testEnv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("Expand mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var expandErrTests = []struct {
+	in, want string
+}{
+	{"$(rm -rf /)", "command substitutions are not supported"},
+	{"`foo`", "command substitutions are not supported"},
+	{"${unset:?required}", "unset: required"},
+	{"a; b", `"a; b" is not a list of words`},
+	{"a | b", `"a | b" is not a list of words`},
+	{"a >b", `"a >b" is not a list of words`},
+	{"'foo", "1:1: reached EOF without closing quote '"},
+}
+
+func TestExpandError(t *testing.T) {
+	t.Parallel()
+	for i, tc := range expandErrTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, err := Expand(tc.in, testEnv)
+			if err == nil {
+				t.Fatalf("Expected error in %q: %v", tc.in, tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("Error mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Fields splits s into arguments like a POSIX shell would split a
+// command line, removing quotes and escapes.
+//
+// No expansions are performed. Words that would need any, like $VAR,
+// $(cmd) or a leading tilde, result in an error. Pattern characters and
+// brace expressions are kept as they are.
+func Fields(s string) ([]string, error) {
+	words, err := parseWords(s)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(words))
+	for i, w := range words {
+		val, ok := syntax.StaticValue(w)
+		if !ok {
+			start := int(w.Pos()) - 1 - len(wordsPrefix)
+			end := int(w.End()) - 1 - len(wordsPrefix)
+			return nil, fmt.Errorf("%q requires expansions", s[start:end])
+		}
+		fields[i] = val
+	}
+	return fields, nil
+}
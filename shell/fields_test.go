@@ -0,0 +1,70 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var fieldsTests = []struct {
+	in   string
+	want []string
+}{
+	{"", []string{}},
+	{"foo", []string{"foo"}},
+	{"  a \t b\n", []string{"a", "b"}},
+	{`"a b" 'c d'`, []string{"a b", "c d"}},
+	{`a\ b \"c\"`, []string{"a b", `"c"`}},
+	{`"" ''`, []string{"", ""}},
+	{`"\$x \a" '\$x'`, []string{`$x \a`, `\$x`}},
+	{`a"b"'c'd`, []string{"abcd"}},
+	{`$'a\tb'`, []string{"a\tb"}},
+	{"* {a,b} a=b if", []string{"*", "{a,b}", "a=b", "if"}},
+	{"a\\\nb", []string{"ab"}},
+}
+
+func TestFields(t *testing.T) {
+	t.Parallel()
+	for i, tc := range fieldsTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			got, err := Fields(tc.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Fields mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var fieldsErrTests = []struct {
+	in, want string
+}{
+	{"a $foo", `"$foo" requires expansions`},
+	{`x"${foo}"y`, `"x\"${foo}\"y" requires expansions`},
+	{"$(rm -rf /)", `"$(rm -rf /)" requires expansions`},
+	{"~/bin", `"~/bin" requires expansions`},
+	{"a && b", `"a && b" is not a list of words`},
+	{`"foo`, `1:1: reached EOF without closing quote "`},
+}
+
+func TestFieldsError(t *testing.T) {
+	t.Parallel()
+	for i, tc := range fieldsErrTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, err := Fields(tc.in)
+			if err == nil {
+				t.Fatalf("Expected error in %q: %v", tc.in, tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("Error mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package shell contains high-level features that use the syntax and
+// expand packages, such as expanding strings as a shell would. None of
+// them run any commands.
+package shell
+
+import (
+	"fmt"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// wordsPrefix is prepended to the strings parsed as a list of words,
+// so that they are never parsed as assignments or reserved words.
+const wordsPrefix = "_ "
+
+// parseWords parses a string as a list of words, separated by blanks.
+// Anything else, like operators or redirects, results in an error.
+func parseWords(s string) ([]*syntax.Word, error) {
+	f, err := syntax.Parse([]byte(wordsPrefix+s), "", 0)
+	if err != nil {
+		if perr, ok := err.(*syntax.ParseError); ok && perr.Line == 1 {
+			perr.Column -= len(wordsPrefix)
+		}
+		return nil, err
+	}
+	if len(f.Stmts) != 1 {
+		return nil, fmt.Errorf("%q is not a list of words", s)
+	}
+	st := f.Stmts[0]
+	ce, ok := st.Cmd.(*syntax.CallExpr)
+	if !ok || st.Negated || st.Background || len(st.Redirs) > 0 {
+		return nil, fmt.Errorf("%q is not a list of words", s)
+	}
+	return ce.Args[1:], nil
+}
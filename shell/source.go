@@ -0,0 +1,291 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/cond"
+	"github.com/mvdan/sh/expand"
+	"github.com/mvdan/sh/syntax"
+)
+
+// SourceFile sources a shell program from a file and returns the
+// variables it sets. See SourceNode for details.
+func SourceFile(path string) (map[string]string, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := syntax.Parse(src, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	return SourceNode(f)
+}
+
+// SourceNode sources a shell program and returns the variables it sets,
+// like env files or /etc/os-release. The node must be a *syntax.File or
+// a *syntax.Stmt. The program starts with no variables set.
+//
+// No commands are run. Only assignments, declarations like export,
+// unset, if clauses, && and || lists, blocks and tests like [ and [[
+// are supported. Anything else, including command substitutions,
+// results in an error.
+func SourceNode(node syntax.Node) (map[string]string, error) {
+	s := &sourcer{vars: make(map[string]string)}
+	switch x := node.(type) {
+	case *syntax.File:
+		s.file = x
+		s.stmts(x.Stmts)
+	case *syntax.Stmt:
+		s.stmt(x)
+	default:
+		return nil, fmt.Errorf("unsupported node type: %T", node)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.vars, nil
+}
+
+type sourcer struct {
+	// file is used to report positions in errors, and may be nil
+	file *syntax.File
+
+	vars map[string]string
+	exit int
+
+	// err is the first error found, which stops the program
+	err error
+}
+
+func (s *sourcer) Get(name string) expand.Variable {
+	if name == "?" {
+		return expand.Variable{Set: true, Value: strconv.Itoa(s.exit)}
+	}
+	val, ok := s.vars[name]
+	return expand.Variable{Set: ok, Value: val}
+}
+
+func (s *sourcer) Set(name string, vr expand.Variable) error {
+	if !vr.Set {
+		delete(s.vars, name)
+		return nil
+	}
+	s.vars[name] = vr.String()
+	return nil
+}
+
+func (s *sourcer) Each(fn func(name string, vr expand.Variable) bool) {
+	for name, val := range s.vars {
+		if !fn(name, expand.Variable{Set: true, Value: val}) {
+			return
+		}
+	}
+}
+
+func (s *sourcer) errf(pos syntax.Pos, format string, a ...interface{}) {
+	if s.err != nil {
+		return
+	}
+	msg := fmt.Sprintf(format, a...)
+	if s.file != nil {
+		p := s.file.Position(pos)
+		msg = fmt.Sprintf("%d:%d: %s", p.Line, p.Column, msg)
+		if s.file.Name != "" {
+			msg = s.file.Name + ":" + msg
+		}
+	}
+	s.err = fmt.Errorf("%s", msg)
+}
+
+func (s *sourcer) expandConfig() expand.Config {
+	return expand.Config{Env: s, NoGlob: true}
+}
+
+func (s *sourcer) fields(words []*syntax.Word) []string {
+	cfg := s.expandConfig()
+	var fields []string
+	for _, w := range words {
+		wfields, err := cfg.Fields(w)
+		if err != nil {
+			s.errf(w.Pos(), "%v", err)
+			return nil
+		}
+		fields = append(fields, wfields...)
+	}
+	return fields
+}
+
+func (s *sourcer) literal(w *syntax.Word) string {
+	if w == nil {
+		return ""
+	}
+	val, err := s.expandConfig().Literal(w)
+	if err != nil {
+		s.errf(w.Pos(), "%v", err)
+	}
+	return val
+}
+
+func (s *sourcer) stmts(stmts []*syntax.Stmt) {
+	for _, st := range stmts {
+		if s.err != nil {
+			return
+		}
+		s.stmt(st)
+	}
+}
+
+func (s *sourcer) stmt(st *syntax.Stmt) {
+	switch {
+	case st.Background:
+		s.errf(st.Pos(), "background commands are not supported")
+		return
+	case len(st.Redirs) > 0:
+		s.errf(st.Redirs[0].Pos(), "redirects are not supported")
+		return
+	case st.Cmd == nil:
+		s.exit = 0
+		for _, as := range st.Assigns {
+			s.assign(as.Name.Value, as.Append, s.literal(as.Value))
+		}
+	default:
+		// assignments before a command would only apply to it
+		s.cmd(st.Cmd)
+	}
+	if st.Negated {
+		s.exit = oneIf(s.exit == 0)
+	}
+}
+
+func (s *sourcer) assign(name string, appnd bool, value string) {
+	if appnd {
+		value = s.vars[name] + value
+	}
+	s.vars[name] = value
+}
+
+func (s *sourcer) cmd(cm syntax.Command) {
+	switch x := cm.(type) {
+	case *syntax.Block:
+		s.stmts(x.Stmts)
+	case *syntax.IfClause:
+		s.stmts(x.CondStmts)
+		if s.exit == 0 {
+			s.stmts(x.ThenStmts)
+			return
+		}
+		for _, elif := range x.Elifs {
+			s.stmts(elif.CondStmts)
+			if s.exit == 0 {
+				s.stmts(elif.ThenStmts)
+				return
+			}
+		}
+		s.exit = 0
+		s.stmts(x.ElseStmts)
+	case *syntax.BinaryCmd:
+		switch x.Op {
+		case syntax.AndStmt:
+			s.stmt(x.X)
+			if s.exit == 0 {
+				s.stmt(x.Y)
+			}
+		case syntax.OrStmt:
+			s.stmt(x.X)
+			if s.exit != 0 {
+				s.stmt(x.Y)
+			}
+		default:
+			s.errf(x.OpPos, "pipelines are not supported")
+		}
+	case *syntax.TestClause:
+		ok, err := cond.Config{Expand: s.expandConfig()}.Eval(x.X)
+		s.test(x.Pos(), ok, err)
+	case *syntax.DeclClause:
+		s.declClause(x)
+	case *syntax.CallExpr:
+		s.call(x)
+	default:
+		s.errf(cm.Pos(), "unsupported command")
+	}
+}
+
+func (s *sourcer) test(pos syntax.Pos, ok bool, err error) {
+	switch err.(type) {
+	case nil:
+		s.exit = oneIf(!ok)
+	case *cond.EvalError:
+		// like a shell, a malformed test is just a failure
+		s.exit = 2
+	default:
+		s.errf(pos, "%v", err)
+	}
+}
+
+func (s *sourcer) call(ce *syntax.CallExpr) {
+	fields := s.fields(ce.Args)
+	if s.err != nil {
+		return
+	}
+	if len(fields) == 0 {
+		s.exit = 0
+		return
+	}
+	name, args := fields[0], fields[1:]
+	switch name {
+	case "true", ":":
+		s.exit = 0
+	case "false":
+		s.exit = 1
+	case "[":
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			s.exit = 2
+			return
+		}
+		args = args[:len(args)-1]
+		fallthrough
+	case "test":
+		ok, err := cond.Config{Expand: s.expandConfig()}.EvalArgs(args)
+		s.test(ce.Pos(), ok, err)
+	case "unset":
+		for _, arg := range args {
+			if arg != "-v" {
+				delete(s.vars, arg)
+			}
+		}
+		s.exit = 0
+	default:
+		s.errf(ce.Pos(), "cannot run command %q", name)
+	}
+}
+
+func (s *sourcer) declClause(dc *syntax.DeclClause) {
+	s.exit = 0
+	for _, as := range dc.Assigns {
+		if as.Name != nil {
+			s.assign(as.Name.Value, as.Append, s.literal(as.Value))
+			continue
+		}
+		// "export foo" declares a variable without setting it,
+		// unless its name comes from an expansion like "$x=y"
+		for _, name := range s.fields([]*syntax.Word{as.Value}) {
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				s.vars[name[:i]] = name[i+1:]
+			}
+		}
+	}
+}
+
+func oneIf(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
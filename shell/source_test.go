@@ -0,0 +1,129 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package shell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+var sourceTests = []struct {
+	in   string
+	want map[string]string
+}{
+	{"", map[string]string{}},
+	{"# comment", map[string]string{}},
+	{"a=b", map[string]string{"a": "b"}},
+	{"a=b\nc=${a}c a+=d", map[string]string{"a": "bd", "c": "bc"}},
+	{`a="x y" b='$a' c=$a`, map[string]string{"a": "x y", "b": "$a", "c": "x y"}},
+	{"export a=b; readonly c=d", map[string]string{"a": "b", "c": "d"}},
+	{"export a; declare b", map[string]string{}},
+	{"a=b; unset a", map[string]string{}},
+	{"a=b true", map[string]string{}},
+	{"if true; then a=b; else a=c; fi", map[string]string{"a": "b"}},
+	{"if false; then a=b; elif ! false; then a=c; fi", map[string]string{"a": "c"}},
+	{`a=1; [ "$a" = 1 ] && b=y || b=n`, map[string]string{"a": "1", "b": "y"}},
+	{`test -z "$a" || b=y`, map[string]string{}},
+	{`[[ $a == x* ]] || { b=y; c=z; }`, map[string]string{"b": "y", "c": "z"}},
+	{`: ${a:=def}; b=$?`, map[string]string{"a": "def", "b": "0"}},
+	{"[ a = b", map[string]string{}},
+}
+
+func TestSourceNode(t *testing.T) {
+	t.Parallel()
+	for i, tc := range sourceTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := SourceNode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("SourceNode mismatch in %q\nwant: %v\ngot:  %v",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+var sourceErrTests = []struct {
+	in, want string
+}{
+	{"a=$(uname)", "1:3: command substitutions are not supported"},
+	{"a=b\nrm -rf /", `2:1: cannot run command "rm"`},
+	{"a=b >file", "1:5: redirects are not supported"},
+	{"a | b", "1:3: pipelines are not supported"},
+	{"a=b &", "1:1: background commands are not supported"},
+	{"for i in 1 2; do a=$i; done", "1:1: unsupported command"},
+	{"${a:?required}", "1:1: a: required"},
+}
+
+func TestSourceNodeError(t *testing.T) {
+	t.Parallel()
+	for i, tc := range sourceErrTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = SourceNode(f)
+			if err == nil {
+				t.Fatalf("Expected error in %q: %v", tc.in, tc.want)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("Error mismatch in %q\nwant: %q\ngot:  %q",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSourceFile(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "sh-shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "os-release")
+	src := strings.Join([]string{
+		`NAME="Debian GNU/Linux"`,
+		`VERSION_ID="9"`,
+		`PRETTY_NAME="$NAME $VERSION_ID"`,
+		`ID=debian`,
+	}, "\n")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := SourceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"NAME":        "Debian GNU/Linux",
+		"VERSION_ID":  "9",
+		"PRETTY_NAME": "Debian GNU/Linux 9",
+		"ID":          "debian",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SourceFile mismatch\nwant: %v\ngot:  %v", want, got)
+	}
+	if err := ioutil.WriteFile(path, []byte("ls"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = SourceFile(path)
+	if want := path + `:1:1: cannot run command "ls"`; err == nil || err.Error() != want {
+		t.Fatalf("SourceFile error mismatch\nwant: %q\ngot:  %v", want, err)
+	}
+}
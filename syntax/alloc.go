@@ -0,0 +1,415 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+// allocator hands out the nodes that the parser doesn't allocate
+// together with others, like the Lit and Stmt nodes, from batches of
+// each type. The batches are kept with the parser, so parsing many
+// programs with the same one makes few allocations.
+//
+// The sizes of the batches follow how common each node is in real
+// programs, so that parsing a small one doesn't allocate much more
+// memory than it needs.
+type allocator struct {
+	arithmExpBatch    []ArithmExp
+	cmdSubstBatch     []CmdSubst
+	paramExpBatch     []ParamExp
+	procSubstBatch    []ProcSubst
+	sglQuotedBatch    []SglQuoted
+	dblQuotedBatch    []DblQuoted
+	templateExprBatch []TemplateExpr
+	extGlobBatch      []ExtGlob
+	binaryArithmBatch []BinaryArithm
+	unaryArithmBatch  []UnaryArithm
+	parenArithmBatch  []ParenArithm
+	indexBatch        []Index
+	replaceBatch      []Replace
+	sliceBatch        []Slice
+	expansionBatch    []Expansion
+	assignBatch       []Assign
+	arrayExprBatch    []ArrayExpr
+	redirectBatch     []Redirect
+	binaryCmdBatch    []BinaryCmd
+	subshellBatch     []Subshell
+	arithmCmdBatch    []ArithmCmd
+	blockBatch        []Block
+	ifClauseBatch     []IfClause
+	elifBatch         []Elif
+	whileClauseBatch  []WhileClause
+	untilClauseBatch  []UntilClause
+	forClauseBatch    []ForClause
+	cStyleLoopBatch   []CStyleLoop
+	wordIterBatch     []WordIter
+	caseClauseBatch   []CaseClause
+	patternListBatch  []PatternList
+	testClauseBatch   []TestClause
+	binaryTestBatch   []BinaryTest
+	unaryTestBatch    []UnaryTest
+	parenTestBatch    []ParenTest
+	declClauseBatch   []DeclClause
+	evalClauseBatch   []EvalClause
+	coprocClauseBatch []CoprocClause
+	letClauseBatch    []LetClause
+	funcDeclBatch     []FuncDecl
+}
+
+func (a *allocator) arithmExp() *ArithmExp {
+	if len(a.arithmExpBatch) == 0 {
+		a.arithmExpBatch = make([]ArithmExp, 8)
+	}
+	x := &a.arithmExpBatch[0]
+	a.arithmExpBatch = a.arithmExpBatch[1:]
+	return x
+}
+
+func (a *allocator) cmdSubst() *CmdSubst {
+	if len(a.cmdSubstBatch) == 0 {
+		a.cmdSubstBatch = make([]CmdSubst, 16)
+	}
+	x := &a.cmdSubstBatch[0]
+	a.cmdSubstBatch = a.cmdSubstBatch[1:]
+	return x
+}
+
+func (a *allocator) paramExp() *ParamExp {
+	if len(a.paramExpBatch) == 0 {
+		a.paramExpBatch = make([]ParamExp, 32)
+	}
+	x := &a.paramExpBatch[0]
+	a.paramExpBatch = a.paramExpBatch[1:]
+	return x
+}
+
+func (a *allocator) procSubst() *ProcSubst {
+	if len(a.procSubstBatch) == 0 {
+		a.procSubstBatch = make([]ProcSubst, 4)
+	}
+	x := &a.procSubstBatch[0]
+	a.procSubstBatch = a.procSubstBatch[1:]
+	return x
+}
+
+func (a *allocator) sglQuoted() *SglQuoted {
+	if len(a.sglQuotedBatch) == 0 {
+		a.sglQuotedBatch = make([]SglQuoted, 32)
+	}
+	x := &a.sglQuotedBatch[0]
+	a.sglQuotedBatch = a.sglQuotedBatch[1:]
+	return x
+}
+
+func (a *allocator) dblQuoted() *DblQuoted {
+	if len(a.dblQuotedBatch) == 0 {
+		a.dblQuotedBatch = make([]DblQuoted, 32)
+	}
+	x := &a.dblQuotedBatch[0]
+	a.dblQuotedBatch = a.dblQuotedBatch[1:]
+	return x
+}
+
+func (a *allocator) templateExpr() *TemplateExpr {
+	if len(a.templateExprBatch) == 0 {
+		a.templateExprBatch = make([]TemplateExpr, 8)
+	}
+	x := &a.templateExprBatch[0]
+	a.templateExprBatch = a.templateExprBatch[1:]
+	return x
+}
+
+func (a *allocator) extGlob() *ExtGlob {
+	if len(a.extGlobBatch) == 0 {
+		a.extGlobBatch = make([]ExtGlob, 4)
+	}
+	x := &a.extGlobBatch[0]
+	a.extGlobBatch = a.extGlobBatch[1:]
+	return x
+}
+
+func (a *allocator) binaryArithm() *BinaryArithm {
+	if len(a.binaryArithmBatch) == 0 {
+		a.binaryArithmBatch = make([]BinaryArithm, 8)
+	}
+	x := &a.binaryArithmBatch[0]
+	a.binaryArithmBatch = a.binaryArithmBatch[1:]
+	return x
+}
+
+func (a *allocator) unaryArithm() *UnaryArithm {
+	if len(a.unaryArithmBatch) == 0 {
+		a.unaryArithmBatch = make([]UnaryArithm, 8)
+	}
+	x := &a.unaryArithmBatch[0]
+	a.unaryArithmBatch = a.unaryArithmBatch[1:]
+	return x
+}
+
+func (a *allocator) parenArithm() *ParenArithm {
+	if len(a.parenArithmBatch) == 0 {
+		a.parenArithmBatch = make([]ParenArithm, 4)
+	}
+	x := &a.parenArithmBatch[0]
+	a.parenArithmBatch = a.parenArithmBatch[1:]
+	return x
+}
+
+func (a *allocator) index() *Index {
+	if len(a.indexBatch) == 0 {
+		a.indexBatch = make([]Index, 4)
+	}
+	x := &a.indexBatch[0]
+	a.indexBatch = a.indexBatch[1:]
+	return x
+}
+
+func (a *allocator) replace() *Replace {
+	if len(a.replaceBatch) == 0 {
+		a.replaceBatch = make([]Replace, 4)
+	}
+	x := &a.replaceBatch[0]
+	a.replaceBatch = a.replaceBatch[1:]
+	return x
+}
+
+func (a *allocator) slice() *Slice {
+	if len(a.sliceBatch) == 0 {
+		a.sliceBatch = make([]Slice, 4)
+	}
+	x := &a.sliceBatch[0]
+	a.sliceBatch = a.sliceBatch[1:]
+	return x
+}
+
+func (a *allocator) expansion() *Expansion {
+	if len(a.expansionBatch) == 0 {
+		a.expansionBatch = make([]Expansion, 8)
+	}
+	x := &a.expansionBatch[0]
+	a.expansionBatch = a.expansionBatch[1:]
+	return x
+}
+
+func (a *allocator) assign() *Assign {
+	if len(a.assignBatch) == 0 {
+		a.assignBatch = make([]Assign, 16)
+	}
+	x := &a.assignBatch[0]
+	a.assignBatch = a.assignBatch[1:]
+	return x
+}
+
+func (a *allocator) arrayExpr() *ArrayExpr {
+	if len(a.arrayExprBatch) == 0 {
+		a.arrayExprBatch = make([]ArrayExpr, 4)
+	}
+	x := &a.arrayExprBatch[0]
+	a.arrayExprBatch = a.arrayExprBatch[1:]
+	return x
+}
+
+func (a *allocator) redirect() *Redirect {
+	if len(a.redirectBatch) == 0 {
+		a.redirectBatch = make([]Redirect, 16)
+	}
+	x := &a.redirectBatch[0]
+	a.redirectBatch = a.redirectBatch[1:]
+	return x
+}
+
+func (a *allocator) binaryCmd() *BinaryCmd {
+	if len(a.binaryCmdBatch) == 0 {
+		a.binaryCmdBatch = make([]BinaryCmd, 16)
+	}
+	x := &a.binaryCmdBatch[0]
+	a.binaryCmdBatch = a.binaryCmdBatch[1:]
+	return x
+}
+
+func (a *allocator) subshell() *Subshell {
+	if len(a.subshellBatch) == 0 {
+		a.subshellBatch = make([]Subshell, 4)
+	}
+	x := &a.subshellBatch[0]
+	a.subshellBatch = a.subshellBatch[1:]
+	return x
+}
+
+func (a *allocator) arithmCmd() *ArithmCmd {
+	if len(a.arithmCmdBatch) == 0 {
+		a.arithmCmdBatch = make([]ArithmCmd, 4)
+	}
+	x := &a.arithmCmdBatch[0]
+	a.arithmCmdBatch = a.arithmCmdBatch[1:]
+	return x
+}
+
+func (a *allocator) block() *Block {
+	if len(a.blockBatch) == 0 {
+		a.blockBatch = make([]Block, 8)
+	}
+	x := &a.blockBatch[0]
+	a.blockBatch = a.blockBatch[1:]
+	return x
+}
+
+func (a *allocator) ifClause() *IfClause {
+	if len(a.ifClauseBatch) == 0 {
+		a.ifClauseBatch = make([]IfClause, 8)
+	}
+	x := &a.ifClauseBatch[0]
+	a.ifClauseBatch = a.ifClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) elif() *Elif {
+	if len(a.elifBatch) == 0 {
+		a.elifBatch = make([]Elif, 4)
+	}
+	x := &a.elifBatch[0]
+	a.elifBatch = a.elifBatch[1:]
+	return x
+}
+
+func (a *allocator) whileClause() *WhileClause {
+	if len(a.whileClauseBatch) == 0 {
+		a.whileClauseBatch = make([]WhileClause, 4)
+	}
+	x := &a.whileClauseBatch[0]
+	a.whileClauseBatch = a.whileClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) untilClause() *UntilClause {
+	if len(a.untilClauseBatch) == 0 {
+		a.untilClauseBatch = make([]UntilClause, 4)
+	}
+	x := &a.untilClauseBatch[0]
+	a.untilClauseBatch = a.untilClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) forClause() *ForClause {
+	if len(a.forClauseBatch) == 0 {
+		a.forClauseBatch = make([]ForClause, 4)
+	}
+	x := &a.forClauseBatch[0]
+	a.forClauseBatch = a.forClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) cStyleLoop() *CStyleLoop {
+	if len(a.cStyleLoopBatch) == 0 {
+		a.cStyleLoopBatch = make([]CStyleLoop, 4)
+	}
+	x := &a.cStyleLoopBatch[0]
+	a.cStyleLoopBatch = a.cStyleLoopBatch[1:]
+	return x
+}
+
+func (a *allocator) wordIter() *WordIter {
+	if len(a.wordIterBatch) == 0 {
+		a.wordIterBatch = make([]WordIter, 4)
+	}
+	x := &a.wordIterBatch[0]
+	a.wordIterBatch = a.wordIterBatch[1:]
+	return x
+}
+
+func (a *allocator) caseClause() *CaseClause {
+	if len(a.caseClauseBatch) == 0 {
+		a.caseClauseBatch = make([]CaseClause, 4)
+	}
+	x := &a.caseClauseBatch[0]
+	a.caseClauseBatch = a.caseClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) patternList() *PatternList {
+	if len(a.patternListBatch) == 0 {
+		a.patternListBatch = make([]PatternList, 8)
+	}
+	x := &a.patternListBatch[0]
+	a.patternListBatch = a.patternListBatch[1:]
+	return x
+}
+
+func (a *allocator) testClause() *TestClause {
+	if len(a.testClauseBatch) == 0 {
+		a.testClauseBatch = make([]TestClause, 8)
+	}
+	x := &a.testClauseBatch[0]
+	a.testClauseBatch = a.testClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) binaryTest() *BinaryTest {
+	if len(a.binaryTestBatch) == 0 {
+		a.binaryTestBatch = make([]BinaryTest, 8)
+	}
+	x := &a.binaryTestBatch[0]
+	a.binaryTestBatch = a.binaryTestBatch[1:]
+	return x
+}
+
+func (a *allocator) unaryTest() *UnaryTest {
+	if len(a.unaryTestBatch) == 0 {
+		a.unaryTestBatch = make([]UnaryTest, 8)
+	}
+	x := &a.unaryTestBatch[0]
+	a.unaryTestBatch = a.unaryTestBatch[1:]
+	return x
+}
+
+func (a *allocator) parenTest() *ParenTest {
+	if len(a.parenTestBatch) == 0 {
+		a.parenTestBatch = make([]ParenTest, 4)
+	}
+	x := &a.parenTestBatch[0]
+	a.parenTestBatch = a.parenTestBatch[1:]
+	return x
+}
+
+func (a *allocator) declClause() *DeclClause {
+	if len(a.declClauseBatch) == 0 {
+		a.declClauseBatch = make([]DeclClause, 8)
+	}
+	x := &a.declClauseBatch[0]
+	a.declClauseBatch = a.declClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) evalClause() *EvalClause {
+	if len(a.evalClauseBatch) == 0 {
+		a.evalClauseBatch = make([]EvalClause, 4)
+	}
+	x := &a.evalClauseBatch[0]
+	a.evalClauseBatch = a.evalClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) coprocClause() *CoprocClause {
+	if len(a.coprocClauseBatch) == 0 {
+		a.coprocClauseBatch = make([]CoprocClause, 4)
+	}
+	x := &a.coprocClauseBatch[0]
+	a.coprocClauseBatch = a.coprocClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) letClause() *LetClause {
+	if len(a.letClauseBatch) == 0 {
+		a.letClauseBatch = make([]LetClause, 4)
+	}
+	x := &a.letClauseBatch[0]
+	a.letClauseBatch = a.letClauseBatch[1:]
+	return x
+}
+
+func (a *allocator) funcDecl() *FuncDecl {
+	if len(a.funcDeclBatch) == 0 {
+		a.funcDeclBatch = make([]FuncDecl, 8)
+	}
+	x := &a.funcDeclBatch[0]
+	a.funcDeclBatch = a.funcDeclBatch[1:]
+	return x
+}
@@ -22,3 +22,29 @@ func Example() {
 	//	bar
 	// }
 }
+
+func ExampleParser() {
+	p := syntax.Parser{Mode: syntax.PosixConformant}
+	for _, in := range []string{"foo=bar", "echo $foo", "foo() { bar; }"} {
+		f, err := p.Parse([]byte(in), "")
+		if err != nil {
+			return
+		}
+		syntax.Fprint(os.Stdout, f)
+	}
+	// Output:
+	// foo=bar
+	// echo $foo
+	// foo() { bar; }
+}
+
+func ExampleFormat() {
+	out, err := syntax.Format([]byte("if a;then b;fi # c"), syntax.ParseComments,
+		syntax.PrintConfig{Spaces: 2})
+	if err != nil {
+		return
+	}
+	os.Stdout.Write(out)
+	// Output:
+	// if a; then b; fi # c
+}
@@ -1428,6 +1428,15 @@ var fileTests = []testCase{
 		Strs:   []string{`$`, `$ #`},
 		common: litWord("$"),
 	},
+	{
+		Strs: []string{`$foo/bar $a.b $_1} $12`},
+		common: call(
+			word(litParamExp("foo"), lit("/bar")),
+			word(litParamExp("a"), lit(".b")),
+			word(litParamExp("_1"), lit("}")),
+			word(litParamExp("1"), lit("2")),
+		),
+	},
 	{
 		Strs: []string{`${@} ${*} ${#} ${$} ${?} ${!} ${0} ${-}`},
 		common: call(
@@ -3651,11 +3660,11 @@ func clearPosRecurse(tb testing.TB, src string, v interface{}) {
 	}
 }
 
-func checkNewlines(tb testing.TB, src string, got []int) {
-	want := []int{0}
+func checkNewlines(tb testing.TB, src string, got []uint32) {
+	want := []uint32{0}
 	for i, b := range src {
 		if b == '\n' {
-			want = append(want, i+1)
+			want = append(want, uint32(i+1))
 		}
 	}
 	if !reflect.DeepEqual(got, want) {
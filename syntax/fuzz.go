@@ -0,0 +1,40 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// fuzz is what Fuzz runs, kept out of the gofuzz build so that the
+// tests can run it over their programs too. Besides finding crashes in
+// the parser and the printer, it panics if printing a program changes
+// its structure, or if printing it again doesn't give the same output.
+func fuzz(data []byte) int {
+	f, err := Parse(data, "", ParseComments)
+	if err != nil {
+		return 0
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		panic(err)
+	}
+	out := append([]byte(nil), buf.Bytes()...)
+	f2, err := Parse(out, "", ParseComments)
+	if err != nil {
+		panic(fmt.Sprintf("printed program doesn't parse: %v\n%s", err, out))
+	}
+	if Hash(f) != Hash(f2) {
+		panic(fmt.Sprintf("printing changed the program:\n%s", out))
+	}
+	buf.Reset()
+	if err := Fprint(&buf, f2); err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(buf.Bytes(), out) {
+		panic(fmt.Sprintf("printing isn't idempotent:\n%s\n---\n%s", out, buf.Bytes()))
+	}
+	return 1
+}
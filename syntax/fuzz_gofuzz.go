@@ -0,0 +1,10 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+//go:build gofuzz
+// +build gofuzz
+
+package syntax
+
+// Fuzz is the entry point for go-fuzz.
+func Fuzz(data []byte) int { return fuzz(data) }
@@ -0,0 +1,103 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+var (
+	posType    = reflect.TypeOf(Pos(0))
+	fileType   = reflect.TypeOf(File{})
+	arithmType = reflect.TypeOf(ArithmExp{})
+)
+
+// Hash returns a hash of the structure of a node. Positions, comments
+// and the file name are ignored, so two programs that only differ in
+// their whitespace, formatting or comments have the same hash. So are
+// the forms that the printer rewrites, like $[ ] to $(( )).
+//
+// The hash is stable across runs and platforms, so it can be used as a
+// cache key.
+func Hash(node Node) uint64 {
+	h := hasher{sum: fnvOffset}
+	h.value(reflect.ValueOf(node))
+	return h.sum
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hasher implements 64-bit FNV-1a.
+type hasher struct {
+	sum uint64
+	buf [8]byte
+}
+
+func (h *hasher) uint(n uint64) {
+	binary.LittleEndian.PutUint64(h.buf[:], n)
+	for _, b := range h.buf {
+		h.sum ^= uint64(b)
+		h.sum *= fnvPrime
+	}
+}
+
+func (h *hasher) str(s string) {
+	h.uint(uint64(len(s)))
+	for i := 0; i < len(s); i++ {
+		h.sum ^= uint64(s[i])
+		h.sum *= fnvPrime
+	}
+}
+
+func (h *hasher) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			h.uint(0)
+			return
+		}
+		h.uint(1)
+		h.value(v.Elem())
+	case reflect.Struct:
+		typ := v.Type()
+		h.str(typ.Name())
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Type == posType {
+				continue
+			}
+			if typ == fileType && field.Name != "Stmts" {
+				continue
+			}
+			if typ == arithmType && field.Name == "Bracket" {
+				continue
+			}
+			h.value(v.Field(i))
+		}
+	case reflect.Slice:
+		h.uint(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			h.value(v.Index(i))
+		}
+	case reflect.String:
+		h.str(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			h.uint(1)
+		} else {
+			h.uint(0)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		h.uint(v.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.uint(uint64(v.Int()))
+	default:
+		panic(fmt.Sprintf("syntax.Hash: unexpected kind %s", v.Kind()))
+	}
+}
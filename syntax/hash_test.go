@@ -0,0 +1,91 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"fmt"
+	"testing"
+)
+
+func hashSrc(tb testing.TB, src string) uint64 {
+	f, err := Parse([]byte(src), "", ParseComments)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return Hash(f)
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+	var equal = [][2]string{
+		{"foo; bar", "foo\nbar"},
+		{"foo   bar", "foo bar"},
+		{"# comment\nfoo # inline", "foo"},
+		{"if a; then b; fi", "if a\nthen\n\tb\nfi"},
+		{"foo && \\\n\tbar", "foo && bar"},
+		{"$(foo)", "`foo`"},
+		{"$[1 + 2]", "$((1 + 2))"},
+	}
+	for i, pair := range equal {
+		t.Run(fmt.Sprintf("eq%03d", i), func(t *testing.T) {
+			if hashSrc(t, pair[0]) != hashSrc(t, pair[1]) {
+				t.Fatalf("%q and %q should have the same hash", pair[0], pair[1])
+			}
+		})
+	}
+	var differ = [][2]string{
+		{"foo", "bar"},
+		{"foo bar", "foobar"},
+		{"'foo'", `"foo"`},
+		{"while a; do b; done", "until a; do b; done"},
+		{"foo &", "foo"},
+		{"a=b c", "a=b; c"},
+		{"", "foo"},
+	}
+	for i, pair := range differ {
+		t.Run(fmt.Sprintf("ne%03d", i), func(t *testing.T) {
+			if hashSrc(t, pair[0]) == hashSrc(t, pair[1]) {
+				t.Fatalf("%q and %q should have different hashes", pair[0], pair[1])
+			}
+		})
+	}
+}
+
+func TestHashAllNodes(t *testing.T) {
+	t.Parallel()
+	for i, c := range fileTests {
+		for j, prog := range c.All {
+			t.Run(fmt.Sprintf("%03d-%d", i, j), func(t *testing.T) {
+				Hash(prog)
+			})
+		}
+	}
+}
+
+// fuzzKnownFailures are the programs that the printer doesn't print
+// back faithfully yet.
+var fuzzKnownFailures = map[string]bool{
+	// the backslash at the end escapes the newline that is added
+	"\\":        true,
+	"foo\\":     true,
+	"f\\\noo\\": true,
+	"<<EOF\n\\": true,
+	// the escaped newline within backquotes moves on each print
+	"`fo\\\no`": true,
+}
+
+func TestFuzzFileTests(t *testing.T) {
+	t.Parallel()
+	all := append(fileTests[:len(fileTests):len(fileTests)], fileTestsNoPrint...)
+	for i, c := range all {
+		for j, in := range c.Strs {
+			if fuzzKnownFailures[in] {
+				continue
+			}
+			t.Run(fmt.Sprintf("%03d-%d", i, j), func(t *testing.T) {
+				fuzz([]byte(in))
+			})
+		}
+	}
+}
@@ -3,7 +3,10 @@
 
 package syntax
 
-import "bytes"
+import (
+	"bytes"
+	"unsafe"
+)
 
 // bytes that form or start a token
 func regOps(b byte) bool {
@@ -65,16 +68,17 @@ func (p *parser) next() {
 	case dblQuotes:
 		if b == '`' || b == '"' || b == '$' {
 			p.tok = p.dqToken(b)
-		} else {
+		} else if !p.tmplToken() {
 			p.advanceLitDquote()
 		}
 		return
 	case hdocBody, hdocBodyTabs:
-		if b == '`' || b == '$' {
+		switch {
+		case b == '`' || b == '$':
 			p.tok = p.dqToken(b)
-		} else if p.hdocStop == nil {
+		case p.hdocStop == nil:
 			p.tok = illegalTok
-		} else {
+		case !p.tmplToken():
 			p.advanceLitHdoc()
 		}
 		return
@@ -111,7 +115,7 @@ skipSpace:
 				return
 			}
 			p.npos++
-			p.f.Lines = append(p.f.Lines, p.npos)
+			p.addLine(p.npos)
 			if len(p.heredocs) > p.buriedHdocs {
 				if p.doHeredocs(); p.tok == _EOF {
 					return
@@ -120,7 +124,7 @@ skipSpace:
 		case '\\':
 			if p.npos < len(p.src)-1 && p.src[p.npos+1] == '\n' {
 				p.npos += 2
-				p.f.Lines = append(p.f.Lines, p.npos)
+				p.addLine(p.npos)
 			} else {
 				break skipSpace
 			}
@@ -135,6 +139,8 @@ skipSpace:
 	}
 	p.pos = Pos(p.npos + 1)
 	switch {
+	case q&allRegTokens != 0 && p.tmplToken():
+		// template markup, read already
 	case q&allRegTokens != 0:
 		switch b {
 		case ';', '"', '\'', '(', ')', '$', '|', '&', '>', '<', '`':
@@ -146,7 +152,7 @@ skipSpace:
 			if p.mode&ParseComments > 0 {
 				p.f.Comments = append(p.f.Comments, &Comment{
 					Hash: p.pos,
-					Text: string(bs),
+					Text: p.str(bs),
 				})
 			}
 			p.next()
@@ -190,6 +196,42 @@ skipSpace:
 	}
 }
 
+// tmplStart returns the template delimiters that start at an offset,
+// if any.
+func (p *parser) tmplStart(i int) (TemplateDelims, bool) {
+	for _, d := range p.tmpl {
+		if bytes.HasPrefix(p.src[i:], []byte(d.Left)) {
+			return d, true
+		}
+	}
+	return TemplateDelims{}, false
+}
+
+// tmplToken reads the template markup at the current offset, if any,
+// and reports whether it did.
+func (p *parser) tmplToken() bool {
+	d, ok := p.tmplStart(p.npos)
+	if !ok {
+		return false
+	}
+	start := p.npos
+	i := bytes.Index(p.src[start+len(d.Left):], []byte(d.Right))
+	if i < 0 {
+		p.tok = _EOF
+		p.posErr(p.pos, "reached EOF without matching %s with %s", d.Left, d.Right)
+		return true
+	}
+	end := start + len(d.Left) + i + len(d.Right)
+	for j := start; j < end; j++ {
+		if p.src[j] == '\n' {
+			p.addLine(j + 1)
+		}
+	}
+	p.tok, p.val = tmplExpr, p.str(p.src[start:end])
+	p.npos = end
+	return true
+}
+
 func byteAt(src []byte, i int) byte {
 	if i >= len(src) {
 		return 0
@@ -603,6 +645,7 @@ func (p *parser) arithmToken(b byte) token {
 
 func (p *parser) advanceLitOther(q quoteState) {
 	bs := p.litBuf[:0]
+	start := p.npos
 	tok := _LitWord
 loop:
 	for p.npos < len(p.src) {
@@ -616,7 +659,7 @@ loop:
 			b = p.src[p.npos]
 			p.npos++
 			if b == '\n' {
-				p.f.Lines = append(p.f.Lines, p.npos)
+				p.addLine(p.npos)
 			} else {
 				bs = append(bs, '\\', b)
 			}
@@ -627,7 +670,7 @@ loop:
 			default:
 				break loop
 			}
-			p.f.Lines = append(p.f.Lines, p.npos+1)
+			p.addLine(p.npos + 1)
 		case '\'':
 			switch q {
 			case paramExpExp, paramExpRepl:
@@ -644,7 +687,7 @@ loop:
 				break loop
 			}
 		case '/':
-			if q&allParamExp != 0 && q != paramExpExp {
+			if q&(allParamExp|allArithmExpr) != 0 && q != paramExpExp {
 				break loop
 			}
 		case ']':
@@ -680,16 +723,48 @@ loop:
 		bs = append(bs, b)
 		p.npos++
 	}
-	p.tok, p.val = tok, string(bs)
+	p.tok, p.val = tok, p.litStr(start, bs)
+}
+
+// plainByte reports whether a byte can never be special when reading
+// an unquoted literal.
+func plainByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' ||
+		'0' <= b && b <= '9' || b == '_' || b == '-' || b == '.' ||
+		b == '/' || b == ',' || b == ':' || b == '%' || b == '^'
 }
 
 func (p *parser) advanceLitNone() {
-	bs := p.litBuf[:0]
+	start := p.npos
 	p.asPos = 0
+	if len(p.tmpl) == 0 {
+		// fast path for plain words like "foo" or "./bar.sh",
+		// which need neither escaping nor a copy to litBuf
+		i := start
+		for i < len(p.src) && plainByte(p.src[i]) {
+			i++
+		}
+		if i == len(p.src) {
+			p.npos = i
+			p.tok, p.val = _LitWord, p.str(p.src[start:i])
+			return
+		}
+		switch p.src[i] {
+		case ' ', '\t', '\n', '\r', '&', '|', ';', ')':
+			p.npos = i
+			p.tok, p.val = _LitWord, p.str(p.src[start:i])
+			return
+		}
+	}
+	bs := p.litBuf[:0]
 	tok := _LitWord
 loop:
 	for p.npos < len(p.src) {
 		b := p.src[p.npos]
+		if _, ok := p.tmplStart(p.npos); ok {
+			tok = _Lit
+			break
+		}
 		switch b {
 		case '\\': // escaped byte follows
 			if p.npos++; p.npos == len(p.src) {
@@ -698,7 +773,7 @@ loop:
 			}
 			if b = p.src[p.npos]; b == '\n' {
 				p.npos++
-				p.f.Lines = append(p.f.Lines, p.npos)
+				p.addLine(p.npos)
 				continue
 			}
 			bs = append(bs, '\\')
@@ -731,7 +806,7 @@ loop:
 		bs = append(bs, b)
 		p.npos++
 	}
-	p.tok, p.val = tok, string(bs)
+	p.tok, p.val = tok, p.litStr(start, bs)
 }
 
 func (p *parser) advanceLitDquote() {
@@ -739,13 +814,17 @@ func (p *parser) advanceLitDquote() {
 	tok := _LitWord
 loop:
 	for i = p.npos; i < len(p.src); i++ {
+		if _, ok := p.tmplStart(i); ok {
+			tok = _Lit
+			break
+		}
 		switch p.src[i] {
 		case '\\': // escaped byte follows
 			if i++; i == len(p.src) {
 				break loop
 			}
 			if p.src[i] == '\n' {
-				p.f.Lines = append(p.f.Lines, i+1)
+				p.addLine(i + 1)
 			}
 		case '"':
 			break loop
@@ -753,13 +832,32 @@ loop:
 			tok = _Lit
 			break loop
 		case '\n':
-			p.f.Lines = append(p.f.Lines, i+1)
+			p.addLine(i + 1)
 		}
 	}
-	p.tok, p.val = tok, string(p.src[p.npos:i])
+	p.tok, p.val = tok, p.str(p.src[p.npos:i])
 	p.npos = i
 }
 
+// litStr returns the string of a literal read into bs from the source
+// starting at start, which differs from the source only if escaped
+// newlines were dropped.
+func (p *parser) litStr(start int, bs []byte) string {
+	if len(bs) == p.npos-start {
+		return p.str(p.src[start:p.npos])
+	}
+	return string(bs)
+}
+
+// str returns a string with the bytes of a part of the source, which
+// shares them with ShareSource.
+func (p *parser) str(b []byte) string {
+	if p.mode&ShareSource != 0 {
+		return *(*string)(unsafe.Pointer(&b))
+	}
+	return string(b)
+}
+
 func (p *parser) isHdocEnd(i int) bool {
 	end := p.hdocStop
 	if end == nil || len(p.src) < i+len(end) {
@@ -779,7 +877,7 @@ func (p *parser) advanceLitHdoc() {
 		}
 	}
 	if p.isHdocEnd(n) {
-		p.tok, p.val = _LitWord, string(p.src[p.npos:n])
+		p.tok, p.val = _LitWord, p.str(p.src[p.npos:n])
 		p.npos = n + len(p.hdocStop)
 		p.hdocStop = nil
 		return
@@ -787,65 +885,85 @@ func (p *parser) advanceLitHdoc() {
 	var i int
 loop:
 	for i = p.npos; i < len(p.src); i++ {
+		if _, ok := p.tmplStart(i); ok {
+			break
+		}
 		switch p.src[i] {
 		case '\\': // escaped byte follows
 			if i++; i == len(p.src) {
 				break loop
 			}
 			if p.src[i] == '\n' {
-				p.f.Lines = append(p.f.Lines, i+1)
+				p.addLine(i + 1)
 			}
 		case '`', '$':
 			break loop
 		case '\n':
 			n := i + 1
-			p.f.Lines = append(p.f.Lines, n)
+			p.addLine(n)
 			if p.quote == hdocBodyTabs {
 				for n < len(p.src) && p.src[n] == '\t' {
 					n++
 				}
 			}
 			if p.isHdocEnd(n) {
-				p.tok, p.val = _LitWord, string(p.src[p.npos:n])
+				p.tok, p.val = _LitWord, p.str(p.src[p.npos:n])
 				p.npos = n + len(p.hdocStop)
 				p.hdocStop = nil
 				return
 			}
 		}
 	}
-	p.tok, p.val = _Lit, string(p.src[p.npos:i])
+	p.tok, p.val = _Lit, p.str(p.src[p.npos:i])
 	p.npos = i
 }
 
-func (p *parser) hdocLitWord() *Word {
+// hdocLitWord reads a heredoc body as a single literal. If escapes is
+// true, as in unquoted heredocs, a line ending in an escaped newline
+// continues on the next one, which can't end the body.
+func (p *parser) hdocLitWord(escapes bool) *Word {
 	pos := p.npos
 	end := pos
+	cont, stopped := false, false
 	for p.npos < len(p.src) {
 		end = p.npos
 		bs, found := p.readUntil('\n')
 		p.npos += len(bs) + 1
 		if found {
-			p.f.Lines = append(p.f.Lines, p.npos)
+			p.addLine(p.npos)
 		}
 		if p.quote == hdocBodyTabs {
 			for end < len(p.src) && p.src[end] == '\t' {
 				end++
 			}
 		}
-		if p.isHdocEnd(end) {
+		if !cont && p.isHdocEnd(end) {
+			stopped = true
 			break
 		}
+		cont = escapes && found && oddBackslashes(bs)
 	}
-	if p.npos == len(p.src) {
-		end = p.npos
+	if !stopped {
+		// reached EOF without the terminator
+		end = len(p.src)
 	}
 	oldNpos := p.npos
 	p.npos = end // since we're slicing until end
-	l := p.lit(Pos(pos+1), string(p.src[pos:end]))
+	l := p.lit(Pos(pos+1), p.str(p.src[pos:end]))
 	p.npos = oldNpos
 	return p.word(p.singleWps(l))
 }
 
+// oddBackslashes reports whether a line ends with an odd number of
+// backslashes, escaping its newline.
+func oddBackslashes(line []byte) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
 func (p *parser) readUntil(b byte) ([]byte, bool) {
 	rem := p.src[p.npos:]
 	if i := bytes.IndexByte(rem, b); i >= 0 {
@@ -872,7 +990,7 @@ byteLoop:
 		p.npos++
 	}
 	p.tok = _LitWord
-	p.val = string(p.src[start:p.npos])
+	p.val = p.str(p.src[start:p.npos])
 }
 
 func testUnaryOp(val string) token {
@@ -3,6 +3,8 @@
 
 package syntax
 
+import "bytes"
+
 // Node represents an AST node.
 type Node interface {
 	// Pos returns the first character of the node
@@ -19,8 +21,18 @@ type File struct {
 	Comments []*Comment
 
 	// Lines contains the offset of the first character for each
-	// line (the first entry is always 0)
-	Lines []int
+	// line (the first entry is always 0). Offsets are stored as
+	// uint32 like Pos, so the table costs four bytes per line. It is
+	// nil if the file was parsed with SkipLines.
+	Lines []uint32
+
+	// Source is the source code that the file was parsed from. The
+	// printer uses it to keep the regions with formatting disabled
+	// via "# fmt: off" as they are.
+	Source []byte
+
+	// Mode is the mode that the file was parsed with.
+	Mode ParseMode
 }
 
 func (f *File) Pos() Pos {
@@ -37,18 +49,57 @@ func (f *File) End() Pos {
 	return f.Stmts[len(f.Stmts)-1].End()
 }
 
+// Position computes the line and column of a position from the line
+// table. Nodes only hold a Pos, which keeps the syntax tree small.
 func (f *File) Position(p Pos) (pos Position) {
-	intp := int(p)
-	pos.Offset = intp - 1
-	if i := searchInts(f.Lines, intp); i >= 0 {
-		pos.Line, pos.Column = i+1, intp-f.Lines[i]
+	pos.Offset = int(p) - 1
+	if i, start := f.line(p); i >= 0 {
+		pos.Line, pos.Column = i+1, int(p)-start
 	}
 	return
 }
 
+// Line returns the line of a position, starting at 1.
+func (f *File) Line(p Pos) int {
+	i, _ := f.line(p)
+	return i + 1
+}
+
+// Column returns the column of a position, starting at 1.
+func (f *File) Column(p Pos) int {
+	if i, start := f.line(p); i >= 0 {
+		return int(p) - start
+	}
+	return 0
+}
+
+// line returns the index of the line that a position is in and the
+// offset that the line starts at. The index is -1 if there is no such
+// line. A newline is the last character of its line, so that the end
+// of a node that stops at one is on that line.
+func (f *File) line(p Pos) (int, int) {
+	offset := int(p) - 1
+	if offset < 0 {
+		offset = 0
+	}
+	if f.Lines == nil && f.Source != nil {
+		// parsed with SkipLines, so count the lines
+		if offset > len(f.Source) {
+			offset = len(f.Source)
+		}
+		src := f.Source[:offset]
+		return bytes.Count(src, []byte("\n")), bytes.LastIndexByte(src, '\n') + 1
+	}
+	i := searchInts(f.Lines, uint32(offset))
+	if i < 0 {
+		return -1, 0
+	}
+	return i, int(f.Lines[i])
+}
+
 // Inlined version of:
 // sort.Search(len(a), func(i int) bool { return a[i] > x }) - 1
-func searchInts(a []int, x int) int {
+func searchInts(a []uint32, x uint32) int {
 	i, j := 0, len(a)
 	for i < j {
 		h := i + (j-i)/2
@@ -316,15 +367,16 @@ type WordPart interface {
 	wordPartNode()
 }
 
-func (*Lit) wordPartNode()       {}
-func (*SglQuoted) wordPartNode() {}
-func (*DblQuoted) wordPartNode() {}
-func (*ParamExp) wordPartNode()  {}
-func (*CmdSubst) wordPartNode()  {}
-func (*ArithmExp) wordPartNode() {}
-func (*ProcSubst) wordPartNode() {}
-func (*ArrayExpr) wordPartNode() {}
-func (*ExtGlob) wordPartNode()   {}
+func (*Lit) wordPartNode()          {}
+func (*SglQuoted) wordPartNode()    {}
+func (*DblQuoted) wordPartNode()    {}
+func (*ParamExp) wordPartNode()     {}
+func (*CmdSubst) wordPartNode()     {}
+func (*ArithmExp) wordPartNode()    {}
+func (*ProcSubst) wordPartNode()    {}
+func (*ArrayExpr) wordPartNode()    {}
+func (*ExtGlob) wordPartNode()      {}
+func (*TemplateExpr) wordPartNode() {}
 
 // Lit represents an unquoted string consisting of characters that were
 // not tokenized.
@@ -621,6 +673,18 @@ type ExtGlob struct {
 func (e *ExtGlob) Pos() Pos { return e.OpPos }
 func (e *ExtGlob) End() Pos { return e.Pattern.End() + 1 }
 
+// TemplateExpr represents template markup, like {{ .Name }}, including
+// its delimiters.
+//
+// This node will only appear when using ParseTemplate.
+type TemplateExpr struct {
+	Left Pos
+	Text string
+}
+
+func (t *TemplateExpr) Pos() Pos { return t.Left }
+func (t *TemplateExpr) End() Pos { return t.Left + Pos(len(t.Text)) }
+
 // ProcSubst represents a Bash process substitution.
 //
 // This node will never appear when in PosixConformant mode.
@@ -39,7 +39,21 @@ func (v *posVisitor) Visit(n Node) Visitor {
 	if n == nil {
 		return v
 	}
-	pos := v.f.Position(n.Pos())
+	v.check(n.Pos())
+	v.check(n.End())
+	return v
+}
+
+func (v *posVisitor) check(p Pos) {
+	if p == 0 {
+		// like the positions of an empty file
+		return
+	}
+	pos := v.f.Position(p)
+	if pos.Column < 1 {
+		// like the end of a node at a newline, which is on its line
+		v.t.Fatalf("Invalid Position: line %d, col %d", pos.Line, pos.Column)
+	}
 	offs := 0
 	for l := 0; l < pos.Line-1; l++ {
 		// since lines here are missing the trailing newline
@@ -51,7 +65,10 @@ func (v *posVisitor) Visit(n Node) Visitor {
 		v.t.Fatalf("Inconsistent Position: line %d, col %d; wanted offset %d, got %d ",
 			pos.Line, pos.Column, pos.Offset, offs)
 	}
-	return v
+	if l, c := v.f.Line(p), v.f.Column(p); l != pos.Line || c != pos.Column {
+		v.t.Fatalf("Line and Column mismatch: want %d:%d, got %d:%d",
+			pos.Line, pos.Column, l, c)
+	}
 }
 
 func TestWeirdOperatorString(t *testing.T) {
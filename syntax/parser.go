@@ -7,36 +7,136 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 )
 
 // ParseMode controls the parser behaviour via a set of flags.
+//
+// With ShareSource, the strings in the AST, like the values of Lit
+// nodes and the text of comments, point into the source bytes instead
+// of being copies of them. This avoids most of the parser's
+// allocations, which suits programs that only inspect the AST, but the
+// source must not be modified while the AST is in use, as its strings
+// would change with it.
+//
+// With LazyHeredocs, the bodies of unquoted heredocs are kept as a
+// single Lit with their source, like the bodies of quoted ones, instead
+// of being parsed into their expansions. Programs that never look into
+// heredocs, like formatters, skip the work of parsing large bodies that
+// way, and ParseHeredoc parses one of them when needed. The printer
+// writes such bodies as they are, so it doesn't rewrite the `cmd`
+// substitutions in them to $(cmd) either. The interpreter parses the
+// bodies of such files as it runs them, but the programs that walk the
+// AST, like the analysis and lint packages, only see each body as a
+// literal, so they miss the references in it. Files for them must be
+// parsed without LazyHeredocs.
+//
+// With SkipLines, the parser doesn't record the offset of each line in
+// File.Lines. Nodes keep their positions, and File.Position finds their
+// lines by counting them in the source instead, which is slow but
+// rarely needed by programs that only look at the structure of the
+// AST. The printer relies on the line table, so such files shouldn't be
+// printed. Since comments are only recorded with ParseComments, leaving
+// out both flags gives the lightest parse.
 type ParseMode uint
 
 const (
 	ParseComments   ParseMode = 1 << iota // add comments to the AST
 	PosixConformant                       // match the POSIX standard where it differs from bash
+	ShareSource                           // don't copy the source for the strings in the AST
+	LazyHeredocs                          // don't parse the expansions in heredoc bodies
+	SkipLines                             // don't record the offset of each line
 )
 
 var parserFree = sync.Pool{
-	New: func() interface{} {
-		return &parser{helperBuf: new(bytes.Buffer)}
-	},
+	New: func() interface{} { return newParser() },
+}
+
+func newParser() *parser {
+	return &parser{helperBuf: new(bytes.Buffer)}
 }
 
 // Parse reads and parses a shell program with an optional name. It
 // returns the parsed program if no issues were encountered. Otherwise,
 // an error is returned.
 func Parse(src []byte, name string, mode ParseMode) (*File, error) {
+	return parse(src, name, mode, nil)
+}
+
+// TemplateDelims are the delimiters of a kind of template markup, like
+// {{ and }} in Go templates.
+type TemplateDelims struct {
+	Left, Right string
+}
+
+// The delimiters of common template languages. Note that envsubst and
+// similar tools use $VAR and ${VAR}, which are parameter expansions
+// already.
+var (
+	GoTemplate = []TemplateDelims{{"{{", "}}"}}
+	Jinja      = []TemplateDelims{{"{{", "}}"}, {"{%", "%}"}, {"{#", "#}"}}
+)
+
+// ParseTemplate is like Parse, but for shell programs that are
+// templates, like the ones rendered by Go's text/template or Jinja2.
+// The markup between each pair of delimiters is kept as it is in a
+// TemplateExpr, which may be part of a word or form a word on its own,
+// like in "echo {{ .Name }}-x" or "{% if debug %}". Markup isn't found
+// within single quotes, nor within expansions like $(( )) or ${ }.
+func ParseTemplate(src []byte, name string, mode ParseMode, delims []TemplateDelims) (*File, error) {
+	return parse(src, name, mode, delims)
+}
+
+func parse(src []byte, name string, mode ParseMode, delims []TemplateDelims) (*File, error) {
 	p := parserFree.Get().(*parser)
+	f, err := p.parse(src, name, mode, delims)
+	parserFree.Put(p)
+	return f, err
+}
+
+// Parser is a shell parser with its own configuration and internal
+// buffers, which are reused by each of its Parse calls. Unlike the
+// Parse func, which takes parsers from a pool shared by the whole
+// program, it lets the caller decide how many parsers there are and
+// which goroutines use them.
+//
+// The zero value is ready to use. A Parser must not be used by more
+// than one goroutine at a time.
+type Parser struct {
+	// Mode controls the parser behaviour.
+	Mode ParseMode
+
+	// Template holds the delimiters of the template markup to keep
+	// in the programs, like in ParseTemplate. If empty, programs
+	// aren't templates.
+	Template []TemplateDelims
+
+	p *parser
+}
+
+// Parse reads and parses a shell program with an optional name, like
+// the Parse func but with the parser's configuration.
+func (p *Parser) Parse(src []byte, name string) (*File, error) {
+	if p.p == nil {
+		p.p = newParser()
+	}
+	return p.p.parse(src, name, p.Mode, p.Template)
+}
+
+func (p *parser) parse(src []byte, name string, mode ParseMode, delims []TemplateDelims) (*File, error) {
 	p.reset()
+	p.tmpl = delims
 	alloc := &struct {
 		f File
-		l [16]int
+		l [16]uint32
 	}{}
 	p.f = &alloc.f
 	p.f.Name = name
-	p.f.Lines = alloc.l[:1]
+	if mode&SkipLines == 0 {
+		p.f.Lines = alloc.l[:1]
+	}
+	p.f.Source, p.f.Mode = src, mode
 	p.src, p.mode = src, mode
 	p.next()
 	p.f.Stmts = p.stmts()
@@ -46,16 +146,63 @@ func Parse(src []byte, name string, mode ParseMode) (*File, error) {
 		p.doHeredocs()
 	}
 	f, err := p.f, p.err
-	parserFree.Put(p)
+	// don't keep the last program alive while the parser is idle
+	p.f, p.src = nil, nil
 	return f, err
 }
 
+// ParseHeredoc parses the body of a heredoc in a file parsed with
+// LazyHeredocs, returning a word with its expansions. The bodies of
+// quoted heredocs have no expansions, and those of files parsed without
+// LazyHeredocs already have theirs, so their words are returned as they
+// are.
+func ParseHeredoc(f *File, r *Redirect) (*Word, error) {
+	if r.Op != Hdoc && r.Op != DashHdoc {
+		return nil, fmt.Errorf("not a heredoc: %s", r.Op)
+	}
+	p := parserFree.Get().(*parser)
+	defer parserFree.Put(p)
+	p.reset()
+	stop, quoted := p.unquotedWordBytes(r.Word)
+	if quoted || r.Hdoc == nil || f.Mode&LazyHeredocs == 0 {
+		return r.Hdoc, nil
+	}
+	start := int(r.Hdoc.Pos()) - 1
+	// a file of our own, so that the lines of the body aren't added
+	// to f again
+	alloc := &struct {
+		f File
+		l [16]uint32
+	}{}
+	p.f = &alloc.f
+	p.f.Name = f.Name
+	p.f.Source = f.Source
+	if f.Mode&SkipLines == 0 {
+		p.f.Lines = append(alloc.l[:0], f.Lines[:searchInts(f.Lines, uint32(start))+1]...)
+	}
+	p.src, p.mode = f.Source, f.Mode&^LazyHeredocs
+	p.tmpl = nil
+	p.npos = start
+	p.quote = hdocBody
+	if r.Op == DashHdoc {
+		p.quote = hdocBodyTabs
+	}
+	p.hdocStop = stop
+	p.next()
+	w, err := p.getWordOrEmpty(), p.err
+	p.f, p.src = nil, nil
+	return w, err
+}
+
 type parser struct {
 	src []byte
 
 	f    *File
 	mode ParseMode
 
+	// tmpl are the delimiters of template markup, if any
+	tmpl []TemplateDelims
+
 	spaced, newLine bool
 
 	err error
@@ -82,6 +229,9 @@ type parser struct {
 	stmtBatch   []Stmt
 	stListBatch []*Stmt
 	callBatch   []callAlloc
+	lwBatch     []litWordAlloc
+
+	alloc allocator
 
 	litBuf [128]byte
 }
@@ -146,6 +296,37 @@ func (p *parser) stList() []*Stmt {
 	return stmts
 }
 
+// addLine records that a line starts at an offset.
+func (p *parser) addLine(off int) {
+	if p.mode&SkipLines == 0 {
+		p.f.Lines = append(p.f.Lines, uint32(off))
+	}
+}
+
+type litWordAlloc struct {
+	w   Word
+	wps [1]WordPart
+	l   Lit
+}
+
+// litWord is a fast path for words made of a single literal, such as
+// most command names and arguments. It allocates the word, its parts
+// and the literal at once.
+func (p *parser) litWord(pos Pos, val string) *Word {
+	if len(p.lwBatch) == 0 {
+		p.lwBatch = make([]litWordAlloc, 32)
+	}
+	alloc := &p.lwBatch[0]
+	p.lwBatch = p.lwBatch[1:]
+	l := &alloc.l
+	l.ValuePos = pos
+	l.ValueEnd = Pos(p.npos + 1)
+	l.Value = val
+	alloc.wps[0] = l
+	alloc.w.Parts = alloc.wps[:]
+	return &alloc.w
+}
+
 type callAlloc struct {
 	ce CallExpr
 	ws [4]*Word
@@ -274,14 +455,14 @@ func (p *parser) doHeredocs() {
 		p.hdocStop, quoted = p.unquotedWordBytes(r.Word)
 		if i > 0 && p.npos < len(p.src) && p.src[p.npos] == '\n' {
 			p.npos++
-			p.f.Lines = append(p.f.Lines, p.npos)
+			p.addLine(p.npos)
 		}
-		if !quoted {
+		if !quoted && p.mode&LazyHeredocs == 0 {
 			p.next()
 			r.Hdoc = p.getWordOrEmpty()
 			continue
 		}
-		r.Hdoc = p.hdocLitWord()
+		r.Hdoc = p.hdocLitWord(!quoted)
 	}
 	p.quote = old
 }
@@ -408,6 +589,12 @@ func (p *parser) errPass(err error) {
 type ParseError struct {
 	Position
 	Filename, Text string
+
+	// Incomplete is set if the error is due to the source ending
+	// before a construct was finished, like an unclosed quote or an if
+	// without a fi. When reading interactively, more lines of input
+	// would fix the error.
+	Incomplete bool
 }
 
 func (e *ParseError) Error() string {
@@ -420,9 +607,10 @@ func (e *ParseError) Error() string {
 
 func (p *parser) posErr(pos Pos, format string, a ...interface{}) {
 	p.errPass(&ParseError{
-		Position: p.f.Position(pos),
-		Filename: p.f.Name,
-		Text:     fmt.Sprintf(format, a...),
+		Position:   p.f.Position(pos),
+		Filename:   p.f.Name,
+		Text:       fmt.Sprintf(format, a...),
+		Incomplete: p.tok == _EOF,
 	})
 }
 
@@ -487,7 +675,7 @@ func (p *parser) invalidStmtStart() {
 
 func (p *parser) getWord() *Word {
 	if p.tok == _LitWord {
-		w := p.word(p.singleWps(p.lit(p.pos, p.val)))
+		w := p.litWord(p.pos, p.val)
 		p.next()
 		return w
 	}
@@ -503,7 +691,7 @@ func (p *parser) getWordOrEmpty() *Word {
 		oldNpos := p.npos
 		// force Lit.Pos() == Lit.End()
 		p.npos = int(p.pos) - 1
-		w := p.word(p.singleWps(p.lit(p.pos, "")))
+		w := p.litWord(p.pos, "")
 		p.npos = oldNpos
 		return w
 	}
@@ -545,7 +733,8 @@ func (p *parser) wordPart() WordPart {
 		return p.paramExp()
 	case dollDblParen, dollBrack:
 		left := p.tok
-		ar := &ArithmExp{Left: p.pos, Bracket: left == dollBrack}
+		ar := p.alloc.arithmExp()
+		ar.Left, ar.Bracket = p.pos, left == dollBrack
 		old := p.preNested(arithmExpr)
 		if ar.Bracket {
 			p.quote = arithmExprBrack
@@ -578,7 +767,8 @@ func (p *parser) wordPart() WordPart {
 		if p.quote == hdocWord {
 			p.curErr("nested statements not allowed in heredoc words")
 		}
-		cs := &CmdSubst{Left: p.pos}
+		cs := p.alloc.cmdSubst()
+		cs.Left = p.pos
 		old := p.preNested(subCmd)
 		p.next()
 		cs.Stmts = p.stmts()
@@ -590,24 +780,29 @@ func (p *parser) wordPart() WordPart {
 		if p.npos < len(p.src) {
 			b = p.src[p.npos]
 		}
-		if b == 0 || wordBreak(b) || b == '"' || b == '\'' || b == '`' || b == '[' {
+		if !shortParamStart(b) {
 			l := p.lit(p.pos, "$")
 			p.next()
 			return l
 		}
-		pe := &ParamExp{Dollar: p.pos, Short: true}
+		pe := p.alloc.paramExp()
+		pe.Dollar, pe.Short = p.pos, true
 		p.pos++
-		switch b {
-		case '@', '*', '#', '$', '?', '!', '0', '-':
-			p.npos++
-			p.tok, p.val = _Lit, string(b)
-		default:
-			p.advanceLitOther(p.quote)
+		end := p.npos + 1
+		if nameByte(b, false) {
+			// unlike special and positional parameters like $1,
+			// names span as many bytes as possible
+			for end < len(p.src) && nameByte(p.src[end], true) {
+				end++
+			}
 		}
+		p.tok, p.val = _Lit, p.str(p.src[p.npos:end])
+		p.npos = end
 		pe.Param = p.getLit()
 		return pe
 	case cmdIn, cmdOut:
-		ps := &ProcSubst{Op: ProcOperator(p.tok), OpPos: p.pos}
+		ps := p.alloc.procSubst()
+		ps.Op, ps.OpPos = ProcOperator(p.tok), p.pos
 		old := p.preNested(subCmd)
 		p.next()
 		ps.Stmts = p.stmts()
@@ -615,7 +810,8 @@ func (p *parser) wordPart() WordPart {
 		ps.Rparen = p.matched(ps.OpPos, token(ps.Op), rightParen)
 		return ps
 	case sglQuote:
-		sq := &SglQuoted{Position: p.pos}
+		sq := p.alloc.sglQuoted()
+		sq.Position = p.pos
 		bs, found := p.readUntil('\'')
 		rem := bs
 		for {
@@ -625,18 +821,21 @@ func (p *parser) wordPart() WordPart {
 				break
 			}
 			p.npos += i + 1
-			p.f.Lines = append(p.f.Lines, p.npos)
+			p.addLine(p.npos)
 			rem = rem[i+1:]
 		}
 		p.npos++
 		if !found {
+			// the quote took the rest of the source
+			p.tok = _EOF
 			p.posErr(sq.Pos(), "reached EOF without closing quote %s", sglQuote)
 		}
-		sq.Value = string(bs)
+		sq.Value = p.str(bs)
 		p.next()
 		return sq
 	case dollSglQuote:
-		sq := &SglQuoted{Position: p.pos, Dollar: true}
+		sq := p.alloc.sglQuoted()
+		sq.Position, sq.Dollar = p.pos, true
 		old := p.quote
 		p.quote = sglQuotes
 		p.next()
@@ -655,7 +854,8 @@ func (p *parser) wordPart() WordPart {
 		}
 		fallthrough
 	case dollDblQuote:
-		q := &DblQuoted{Position: p.pos, Dollar: p.tok == dollDblQuote}
+		q := p.alloc.dblQuoted()
+		q.Position, q.Dollar = p.pos, p.tok == dollDblQuote
 		old := p.quote
 		p.quote = dblQuotes
 		p.next()
@@ -677,7 +877,8 @@ func (p *parser) wordPart() WordPart {
 		case subCmdBckquo:
 			return nil
 		}
-		cs := &CmdSubst{Left: p.pos}
+		cs := p.alloc.cmdSubst()
+		cs.Left = p.pos
 		old := p.preNested(subCmdBckquo)
 		p.next()
 		cs.Stmts = p.stmts()
@@ -687,8 +888,14 @@ func (p *parser) wordPart() WordPart {
 			p.quoteErr(cs.Pos(), bckQuote)
 		}
 		return cs
+	case tmplExpr:
+		te := p.alloc.templateExpr()
+		te.Left, te.Text = p.pos, p.val
+		p.next()
+		return te
 	case globQuest, globStar, globPlus, globAt, globExcl:
-		eg := &ExtGlob{Op: GlobOperator(p.tok), OpPos: p.pos}
+		eg := p.alloc.extGlob()
+		eg.Op, eg.OpPos = GlobOperator(p.tok), p.pos
 		start := p.npos
 		lparens := 0
 	byteLoop:
@@ -699,7 +906,7 @@ func (p *parser) wordPart() WordPart {
 			case ')':
 				if lparens--; lparens < 0 {
 					eg.Pattern = p.lit(Pos(start+1),
-						string(p.src[start:p.npos]))
+						p.str(p.src[start:p.npos]))
 					p.npos++
 					break byteLoop
 				}
@@ -810,11 +1017,8 @@ func (p *parser) arithmExpr(ftok token, fpos Pos, level int, compact, tern bool)
 	if newLevel < 0 || newLevel < level {
 		return left
 	}
-	b := &BinaryArithm{
-		OpPos: p.pos,
-		Op:    BinAritOperator(p.tok),
-		X:     left,
-	}
+	b := p.alloc.binaryArithm()
+	b.OpPos, b.Op, b.X = p.pos, BinAritOperator(p.tok), left
 	if p.next(); compact && p.spaced {
 		p.followErrExp(b.OpPos, b.Op.String())
 	}
@@ -829,14 +1033,16 @@ func (p *parser) arithmExprBase(ftok token, fpos Pos, compact bool) ArithmExpr {
 	var x ArithmExpr
 	switch p.tok {
 	case addAdd, subSub, exclMark:
-		ue := &UnaryArithm{OpPos: p.pos, Op: UnAritOperator(p.tok)}
+		ue := p.alloc.unaryArithm()
+		ue.OpPos, ue.Op = p.pos, UnAritOperator(p.tok)
 		p.next()
 		if ue.X = p.arithmExprBase(token(ue.Op), ue.OpPos, compact); ue.X == nil {
 			p.followErrExp(ue.OpPos, ue.Op.String())
 		}
 		return ue
 	case leftParen:
-		pe := &ParenArithm{Lparen: p.pos}
+		pe := p.alloc.parenArithm()
+		pe.Lparen = p.pos
 		p.next()
 		pe.X = p.arithmExpr(leftParen, pe.Lparen, 0, false, false)
 		if pe.X == nil {
@@ -845,7 +1051,8 @@ func (p *parser) arithmExprBase(ftok token, fpos Pos, compact bool) ArithmExpr {
 		pe.Rparen = p.matched(pe.Lparen, leftParen, rightParen)
 		x = pe
 	case plus, minus:
-		ue := &UnaryArithm{OpPos: p.pos, Op: UnAritOperator(p.tok)}
+		ue := p.alloc.unaryArithm()
+		ue.OpPos, ue.Op = p.pos, UnAritOperator(p.tok)
 		if p.next(); compact && p.spaced {
 			p.followErrExp(ue.OpPos, ue.Op.String())
 		}
@@ -870,12 +1077,8 @@ func (p *parser) arithmExprBase(ftok token, fpos Pos, compact bool) ArithmExpr {
 		return x
 	}
 	if p.tok == addAdd || p.tok == subSub {
-		u := &UnaryArithm{
-			Post:  true,
-			OpPos: p.pos,
-			Op:    UnAritOperator(p.tok),
-			X:     x,
-		}
+		u := p.alloc.unaryArithm()
+		u.Post, u.OpPos, u.Op, u.X = true, p.pos, UnAritOperator(p.tok), x
 		p.next()
 		return u
 	}
@@ -883,7 +1086,8 @@ func (p *parser) arithmExprBase(ftok token, fpos Pos, compact bool) ArithmExpr {
 }
 
 func (p *parser) paramExp() *ParamExp {
-	pe := &ParamExp{Dollar: p.pos}
+	pe := p.alloc.paramExp()
+	pe.Dollar = p.pos
 	old := p.preNested(paramExpName)
 	p.next()
 	switch p.tok {
@@ -925,9 +1129,8 @@ func (p *parser) paramExp() *ParamExp {
 		if p.tok == star {
 			p.tok, p.val = _LitWord, "*"
 		}
-		pe.Ind = &Index{
-			Expr: p.arithmExpr(leftBrack, lpos, 0, false, false),
-		}
+		pe.Ind = p.alloc.index()
+		pe.Ind.Expr = p.arithmExpr(leftBrack, lpos, 0, false, false)
 		if pe.Ind.Expr == nil {
 			p.followErrExp(lpos, "[")
 		}
@@ -944,7 +1147,8 @@ func (p *parser) paramExp() *ParamExp {
 		if !p.bash() {
 			p.curErr("search and replace is a bash feature")
 		}
-		pe.Repl = &Replace{All: p.tok == dblSlash}
+		pe.Repl = p.alloc.replace()
+		pe.Repl.All = p.tok == dblSlash
 		p.quote = paramExpRepl
 		p.next()
 		pe.Repl.Orig = p.getWordOrEmpty()
@@ -957,7 +1161,7 @@ func (p *parser) paramExp() *ParamExp {
 		if !p.bash() {
 			p.curErr("slicing is a bash feature")
 		}
-		pe.Slice = &Slice{}
+		pe.Slice = p.alloc.slice()
 		colonPos := p.pos
 		p.quote = paramExpOff
 		p.next()
@@ -981,7 +1185,8 @@ func (p *parser) paramExp() *ParamExp {
 		}
 		fallthrough
 	default:
-		pe.Exp = &Expansion{Op: ParExpOperator(p.tok)}
+		pe.Exp = p.alloc.expansion()
+		pe.Exp.Op = ParExpOperator(p.tok)
 		p.quote = paramExpExp
 		p.next()
 		pe.Exp.Word = p.getWordOrEmpty()
@@ -1022,13 +1227,17 @@ func (p *parser) validIdent() bool {
 		return false
 	}
 	s := p.val[:p.asPos]
+	if i := strings.IndexByte(s, '['); i > 0 && s[len(s)-1] == ']' && p.bash() {
+		// an array element like a[i+1]=x, whose index may be
+		// any literal
+		s = s[:i]
+	}
 	for i, c := range s {
 		switch {
 		case 'a' <= c && c <= 'z':
 		case 'A' <= c && c <= 'Z':
 		case c == '_':
 		case i > 0 && '0' <= c && c <= '9':
-		case i > 0 && (c == '[' || c == ']') && p.bash():
 		default:
 			return false
 		}
@@ -1036,9 +1245,32 @@ func (p *parser) validIdent() bool {
 	return true
 }
 
+// nameByte reports whether a byte can be part of a variable name. Only
+// bytes after the first one may be digits.
+func nameByte(b byte, digit bool) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', b == '_':
+		return true
+	case digit && '0' <= b && b <= '9':
+		return true
+	}
+	return false
+}
+
+// shortParamStart reports whether a byte can follow a dollar sign in a
+// parameter expansion without braces, like $foo or $?.
+func shortParamStart(b byte) bool {
+	switch b {
+	case '@', '*', '#', '$', '?', '!', '-':
+		return true
+	}
+	return nameByte(b, true)
+}
+
 func (p *parser) getAssign() *Assign {
 	asPos := p.asPos
-	as := &Assign{Name: p.lit(p.pos, p.val[:asPos])}
+	as := p.alloc.assign()
+	as.Name = p.lit(p.pos, p.val[:asPos])
 	// since we're not using the entire p.val
 	as.Name.ValueEnd = as.Name.ValuePos + Pos(asPos)
 	if p.val[asPos] == '+' {
@@ -1058,7 +1290,8 @@ func (p *parser) getAssign() *Assign {
 		if !p.bash() {
 			p.curErr("arrays are a bash feature")
 		}
-		ae := &ArrayExpr{Lparen: p.pos}
+		ae := p.alloc.arrayExpr()
+		ae.Lparen = p.pos
 		p.next()
 		for p.tok != _EOF && p.tok != rightParen {
 			if w := p.getWord(); w == nil {
@@ -1097,7 +1330,7 @@ func (p *parser) peekRedir() bool {
 }
 
 func (p *parser) doRedirect(s *Stmt) {
-	r := &Redirect{}
+	r := p.alloc.redirect()
 	r.N = p.getLit()
 	r.Op, r.OpPos = RedirOperator(p.tok), p.pos
 	p.next()
@@ -1160,7 +1393,8 @@ preLoop:
 	}
 	switch p.tok {
 	case andAnd, orOr:
-		b := &BinaryCmd{OpPos: p.pos, Op: BinCmdOperator(p.tok), X: s}
+		b := p.alloc.binaryCmd()
+		b.OpPos, b.Op, b.X = p.pos, BinCmdOperator(p.tok), s
 		p.next()
 		if b.Y, _ = p.getStmt(false); b.Y == nil {
 			p.followErr(b.OpPos, b.Op.String(), "a statement")
@@ -1243,7 +1477,7 @@ func (p *parser) gotStmtPipe(s *Stmt) *Stmt {
 		fallthrough
 	case _Lit, dollBrace, dollDblParen, dollParen, dollar, cmdIn, cmdOut,
 		sglQuote, dollSglQuote, dblQuote, dollDblQuote, dollBrack,
-		globQuest, globStar, globPlus, globAt, globExcl:
+		globQuest, globStar, globPlus, globAt, globExcl, tmplExpr:
 		w := p.word(p.wordParts())
 		if p.gotSameLine(leftParen) && p.err == nil {
 			rawName := string(p.src[w.Pos()-1 : w.End()-1])
@@ -1258,7 +1492,8 @@ func (p *parser) gotStmtPipe(s *Stmt) *Stmt {
 		return nil
 	}
 	if p.tok == or || p.tok == pipeAll {
-		b := &BinaryCmd{OpPos: p.pos, Op: BinCmdOperator(p.tok), X: s}
+		b := p.alloc.binaryCmd()
+		b.OpPos, b.Op, b.X = p.pos, BinCmdOperator(p.tok), s
 		p.next()
 		if b.Y = p.gotStmtPipe(p.stmt(p.pos)); b.Y == nil {
 			p.followErr(b.OpPos, b.Op.String(), "a statement")
@@ -1270,7 +1505,8 @@ func (p *parser) gotStmtPipe(s *Stmt) *Stmt {
 }
 
 func (p *parser) subshell() *Subshell {
-	s := &Subshell{Lparen: p.pos}
+	s := p.alloc.subshell()
+	s.Lparen = p.pos
 	old := p.preNested(subCmd)
 	p.next()
 	s.Stmts = p.stmts()
@@ -1280,7 +1516,8 @@ func (p *parser) subshell() *Subshell {
 }
 
 func (p *parser) arithmExpCmd() Command {
-	ar := &ArithmCmd{Left: p.pos}
+	ar := p.alloc.arithmCmd()
+	ar.Left = p.pos
 	old := p.preNested(arithmExprCmd)
 	if !p.couldBeArithm() {
 		p.postNested(old)
@@ -1301,7 +1538,8 @@ func (p *parser) arithmExpCmd() Command {
 }
 
 func (p *parser) block() *Block {
-	b := &Block{Lbrace: p.pos}
+	b := p.alloc.block()
+	b.Lbrace = p.pos
 	p.next()
 	b.Stmts = p.stmts("}")
 	b.Rbrace = p.pos
@@ -1312,14 +1550,16 @@ func (p *parser) block() *Block {
 }
 
 func (p *parser) ifClause() *IfClause {
-	ic := &IfClause{If: p.pos}
+	ic := p.alloc.ifClause()
+	ic.If = p.pos
 	p.next()
 	ic.CondStmts = p.followStmts("if", ic.If, "then")
 	ic.Then = p.followRsrv(ic.If, "if <cond>", "then")
 	ic.ThenStmts = p.followStmts("then", ic.Then, "fi", "elif", "else")
 	elifPos := p.pos
 	for p.gotRsrv("elif") {
-		elf := &Elif{Elif: elifPos}
+		elf := p.alloc.elif()
+		elf.Elif = elifPos
 		elf.CondStmts = p.followStmts("elif", elf.Elif, "then")
 		elf.Then = p.followRsrv(elf.Elif, "elif <cond>", "then")
 		elf.ThenStmts = p.followStmts("then", elf.Then, "fi", "elif", "else")
@@ -1335,7 +1575,8 @@ func (p *parser) ifClause() *IfClause {
 }
 
 func (p *parser) whileClause() *WhileClause {
-	wc := &WhileClause{While: p.pos}
+	wc := p.alloc.whileClause()
+	wc.While = p.pos
 	p.next()
 	wc.CondStmts = p.followStmts("while", wc.While, "do")
 	wc.Do = p.followRsrv(wc.While, "while <cond>", "do")
@@ -1345,7 +1586,8 @@ func (p *parser) whileClause() *WhileClause {
 }
 
 func (p *parser) untilClause() *UntilClause {
-	uc := &UntilClause{Until: p.pos}
+	uc := p.alloc.untilClause()
+	uc.Until = p.pos
 	p.next()
 	uc.CondStmts = p.followStmts("until", uc.Until, "do")
 	uc.Do = p.followRsrv(uc.Until, "until <cond>", "do")
@@ -1355,7 +1597,8 @@ func (p *parser) untilClause() *UntilClause {
 }
 
 func (p *parser) forClause() *ForClause {
-	fc := &ForClause{For: p.pos}
+	fc := p.alloc.forClause()
+	fc.For = p.pos
 	p.next()
 	fc.Loop = p.loop(fc.For)
 	fc.Do = p.followRsrv(fc.For, "for foo [in words]", "do")
@@ -1366,7 +1609,8 @@ func (p *parser) forClause() *ForClause {
 
 func (p *parser) loop(forPos Pos) Loop {
 	if p.tok == dblLeftParen {
-		cl := &CStyleLoop{Lparen: p.pos}
+		cl := p.alloc.cStyleLoop()
+		cl.Lparen = p.pos
 		old := p.preNested(arithmExprCmd)
 		p.next()
 		if p.tok == dblSemicolon {
@@ -1390,7 +1634,7 @@ func (p *parser) loop(forPos Pos) Loop {
 		p.gotSameLine(semicolon)
 		return cl
 	}
-	wi := &WordIter{}
+	wi := p.alloc.wordIter()
 	if wi.Name = p.getLit(); wi.Name == nil {
 		p.followErr(forPos, "for", "a literal")
 	}
@@ -1410,7 +1654,8 @@ func (p *parser) loop(forPos Pos) Loop {
 }
 
 func (p *parser) caseClause() *CaseClause {
-	cc := &CaseClause{Case: p.pos}
+	cc := p.alloc.caseClause()
+	cc.Case = p.pos
 	p.next()
 	cc.Word = p.followWord("case", cc.Case)
 	p.followRsrv(cc.Case, "case x", "in")
@@ -1421,7 +1666,7 @@ func (p *parser) caseClause() *CaseClause {
 
 func (p *parser) patLists() (pls []*PatternList) {
 	for p.tok != _EOF && !(p.tok == _LitWord && p.val == "esac") {
-		pl := &PatternList{}
+		pl := p.alloc.patternList()
 		p.got(leftParen)
 		for p.tok != _EOF {
 			if w := p.getWord(); w == nil {
@@ -1454,7 +1699,8 @@ func (p *parser) patLists() (pls []*PatternList) {
 }
 
 func (p *parser) testClause() *TestClause {
-	tc := &TestClause{Left: p.pos}
+	tc := p.alloc.testClause()
+	tc.Left = p.pos
 	p.next()
 	if p.tok == _EOF || p.gotRsrv("]]") {
 		p.posErr(tc.Left, "test clause requires at least one expression")
@@ -1500,11 +1746,8 @@ func (p *parser) testExpr(ftok token, fpos Pos, level int) TestExpr {
 			p.curErr("not a valid test operator: %s", p.val)
 		}
 	}
-	b := &BinaryTest{
-		OpPos: p.pos,
-		Op:    BinTestOperator(p.tok),
-		X:     left,
-	}
+	b := p.alloc.binaryTest()
+	b.OpPos, b.Op, b.X = p.pos, BinTestOperator(p.tok), left
 	if b.Op == TsReMatch {
 		old := p.preNested(testRegexp)
 		p.next()
@@ -1529,7 +1772,8 @@ func (p *parser) testExprBase(ftok token, fpos Pos) TestExpr {
 	}
 	switch p.tok {
 	case exclMark:
-		u := &UnaryTest{OpPos: p.pos, Op: TsNot}
+		u := p.alloc.unaryTest()
+		u.OpPos, u.Op = p.pos, TsNot
 		p.next()
 		u.X = p.testExpr(token(u.Op), u.OpPos, 0)
 		return u
@@ -1537,12 +1781,14 @@ func (p *parser) testExprBase(ftok token, fpos Pos) TestExpr {
 		tsSocket, tsSmbLink, tsGIDSet, tsUIDSet, tsRead, tsWrite,
 		tsExec, tsNoEmpty, tsFdTerm, tsEmpStr, tsNempStr, tsOptSet,
 		tsVarSet, tsRefVar:
-		u := &UnaryTest{OpPos: p.pos, Op: UnTestOperator(p.tok)}
+		u := p.alloc.unaryTest()
+		u.OpPos, u.Op = p.pos, UnTestOperator(p.tok)
 		p.next()
 		u.X = p.followWordTok(ftok, fpos)
 		return u
 	case leftParen:
-		pe := &ParenTest{Lparen: p.pos}
+		pe := p.alloc.parenTest()
+		pe.Lparen = p.pos
 		p.next()
 		if pe.X = p.testExpr(leftParen, pe.Lparen, 0); pe.X == nil {
 			p.posErr(pe.Lparen, "parentheses must enclose an expression")
@@ -1558,7 +1804,8 @@ func (p *parser) testExprBase(ftok token, fpos Pos) TestExpr {
 
 func (p *parser) declClause() *DeclClause {
 	name := p.val
-	ds := &DeclClause{Position: p.pos}
+	ds := p.alloc.declClause()
+	ds.Position = p.pos
 	switch name {
 	case "declare", "typeset": // typeset is an obsolete synonym
 	default:
@@ -1574,14 +1821,17 @@ func (p *parser) declClause() *DeclClause {
 		} else if w := p.getWord(); w == nil {
 			p.followErr(p.pos, name, "words")
 		} else {
-			ds.Assigns = append(ds.Assigns, &Assign{Value: w})
+			as := p.alloc.assign()
+			as.Value = w
+			ds.Assigns = append(ds.Assigns, as)
 		}
 	}
 	return ds
 }
 
 func (p *parser) evalClause() *EvalClause {
-	ec := &EvalClause{Eval: p.pos}
+	ec := p.alloc.evalClause()
+	ec.Eval = p.pos
 	p.next()
 	ec.Stmt, _ = p.getStmt(false)
 	return ec
@@ -1605,7 +1855,8 @@ func isBashCompoundCommand(tok token, val string) bool {
 }
 
 func (p *parser) coprocClause() *CoprocClause {
-	cc := &CoprocClause{Coproc: p.pos}
+	cc := p.alloc.coprocClause()
+	cc.Coproc = p.pos
 	p.next()
 	if isBashCompoundCommand(p.tok, p.val) {
 		// has no name
@@ -1638,7 +1889,8 @@ func (p *parser) coprocClause() *CoprocClause {
 }
 
 func (p *parser) letClause() *LetClause {
-	lc := &LetClause{Let: p.pos}
+	lc := p.alloc.letClause()
+	lc.Let = p.pos
 	old := p.preNested(arithmExprLet)
 	p.next()
 	for !p.newLine && !stopToken(p.tok) && !p.peekRedir() {
@@ -1687,9 +1939,7 @@ func (p *parser) callExpr(s *Stmt, w *Word) *CallExpr {
 				p.doRedirect(s)
 				continue
 			}
-			ce.Args = append(ce.Args, p.word(
-				p.singleWps(p.lit(p.pos, p.val)),
-			))
+			ce.Args = append(ce.Args, p.litWord(p.pos, p.val))
 			p.next()
 		case bckQuote:
 			if p.quote == subCmdBckquo {
@@ -1698,7 +1948,7 @@ func (p *parser) callExpr(s *Stmt, w *Word) *CallExpr {
 			fallthrough
 		case _Lit, dollBrace, dollDblParen, dollParen, dollar, cmdIn, cmdOut,
 			sglQuote, dollSglQuote, dblQuote, dollDblQuote, dollBrack,
-			globQuest, globStar, globPlus, globAt, globExcl:
+			globQuest, globStar, globPlus, globAt, globExcl, tmplExpr:
 			ce.Args = append(ce.Args, p.word(p.wordParts()))
 		case rdrOut, appOut, rdrIn, dplIn, dplOut, clbOut, rdrInOut,
 			hdoc, dashHdoc, wordHdoc, rdrAll, appAll:
@@ -1716,11 +1966,8 @@ func (p *parser) callExpr(s *Stmt, w *Word) *CallExpr {
 }
 
 func (p *parser) funcDecl(name *Lit, pos Pos) *FuncDecl {
-	fd := &FuncDecl{
-		Position:  pos,
-		BashStyle: pos != name.ValuePos,
-		Name:      name,
-	}
+	fd := p.alloc.funcDecl()
+	fd.Position, fd.BashStyle, fd.Name = pos, pos != name.ValuePos, name
 	if fd.Body, _ = p.getStmt(false); fd.Body == nil {
 		p.followErr(fd.Pos(), "foo()", "a statement")
 	}
@@ -55,6 +55,202 @@ func TestParsePosix(t *testing.T) {
 	}
 }
 
+func TestParseShareSource(t *testing.T) {
+	t.Parallel()
+	for i, c := range append(fileTests, fileTestsNoPrint...) {
+		want := c.Bash
+		if want == nil {
+			continue
+		}
+		for j, in := range c.Strs {
+			t.Run(fmt.Sprintf("%03d-%d", i, j), singleParse(in, want, ShareSource))
+		}
+	}
+	src := []byte("foo 'bar' a\\\nb # baz")
+	f, err := Parse(src, "", ShareSource|ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := f.Stmts[0].Cmd.(*CallExpr).Args
+	copy(src, "FOO 'BAR' A\\\nB # BAZ")
+	got := []string{
+		args[0].Parts[0].(*Lit).Value,
+		args[1].Parts[0].(*SglQuoted).Value,
+		args[2].Parts[0].(*Lit).Value,
+		f.Comments[0].Text,
+	}
+	// the literal with an escaped newline is a copy, as it differs
+	// from the source
+	want := []string{"FOO", "BAR", "ab", " BAZ"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ShareSource strings mismatch:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestParseLazyHeredocs(t *testing.T) {
+	t.Parallel()
+	tests := []string{
+		"cat <<EOF\nfoo $bar\nEOF",
+		"cat <<EOF\nfoo\\\nEOF\n$(baz)\nEOF\necho",
+		"cat <<'EOF'\nfoo $bar\nEOF",
+		"cat <<-EOF\n\tfoo ${bar}\n\tEOF",
+		"cat <<EOF\nEOF",
+		"cat <<EOF\n\nEOF",
+		"cat <<EOF\n$a\nEOF\ncat <<EOF2 <<EOF3\n$(b)\nEOF2\n$((c))\nEOF3",
+		"if true; then\n\tcat <<EOF\nfoo $bar\nEOF\nfi",
+		"cat <<EOF\nfoo\n",
+		"cat <<EOF\nfoo $a\nEOF\n",
+		"cat <<'EOF'\nfoo\nEOF\n",
+		"cat <<EOF\nfoo",
+	}
+	hdocs := func(f *File) []*Redirect {
+		var rs []*Redirect
+		Walk(walkFunc(func(node Node) {
+			if r, ok := node.(*Redirect); ok && r.Hdoc != nil {
+				rs = append(rs, r)
+			}
+		}), f)
+		return rs
+	}
+	for i, in := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			want, err := Parse([]byte(in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lazy, err := Parse([]byte(in), "", LazyHeredocs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lazy.Lines, want.Lines) {
+				t.Fatalf("Lines mismatch in %q:\nwant: %v\ngot:  %v",
+					in, want.Lines, lazy.Lines)
+			}
+			wantRs, lazyRs := hdocs(want), hdocs(lazy)
+			if len(lazyRs) != len(wantRs) {
+				t.Fatalf("got %d heredocs in %q, want %d", len(lazyRs), in, len(wantRs))
+			}
+			for j, r := range lazyRs {
+				if len(r.Hdoc.Parts) != 1 {
+					t.Fatalf("lazy heredoc in %q has %d parts", in, len(r.Hdoc.Parts))
+				}
+				got, err := ParseHeredoc(lazy, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, wantRs[j].Hdoc) {
+					t.Fatalf("heredoc mismatch in %q\ndiff:\n%s", in,
+						strings.Join(pretty.Diff(wantRs[j].Hdoc, got), "\n"))
+				}
+			}
+			var buf bytes.Buffer
+			if err := Fprint(&buf, lazy); err != nil {
+				t.Fatal(err)
+			}
+			var wantBuf bytes.Buffer
+			Fprint(&wantBuf, want)
+			if buf.String() != wantBuf.String() {
+				t.Fatalf("Fprint mismatch in %q:\nwant: %q\ngot:  %q",
+					in, wantBuf.String(), buf.String())
+			}
+		})
+	}
+}
+
+func TestParseSkipLines(t *testing.T) {
+	t.Parallel()
+	positions := func(f *File) []Position {
+		var ps []Position
+		Walk(walkFunc(func(node Node) {
+			ps = append(ps, f.Position(node.Pos()), f.Position(node.End()))
+		}), f)
+		return ps
+	}
+	for i, c := range fileTests {
+		for j, in := range c.Strs {
+			t.Run(fmt.Sprintf("%03d-%d", i, j), func(t *testing.T) {
+				want, err := Parse([]byte(in), "", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := Parse([]byte(in), "", SkipLines)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Lines != nil {
+					t.Fatalf("Unexpected lines in %q: %v", in, got.Lines)
+				}
+				wantPs, gotPs := positions(want), positions(got)
+				if !reflect.DeepEqual(gotPs, wantPs) {
+					t.Fatalf("Position mismatch in %q:\nwant: %v\ngot:  %v",
+						in, wantPs, gotPs)
+				}
+			})
+		}
+	}
+	for i, c := range shellTests {
+		t.Run(fmt.Sprintf("err%03d", i), func(t *testing.T) {
+			_, want := Parse([]byte(c.in), "", 0)
+			_, got := Parse([]byte(c.in), "", SkipLines)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("Error mismatch in %q:\nwant: %v\ngot:  %v",
+					c.in, want, got)
+			}
+		})
+	}
+}
+
+type walkFunc func(Node)
+
+func (f walkFunc) Visit(node Node) Visitor {
+	if node != nil {
+		f(node)
+	}
+	return f
+}
+
+func TestParserReuse(t *testing.T) {
+	t.Parallel()
+	var p Parser
+	var ins []string
+	var wants, gots []*File
+	for _, c := range append(fileTests, fileTestsNoPrint...) {
+		if c.Bash == nil {
+			continue
+		}
+		for _, in := range c.Strs {
+			got, err := p.Parse([]byte(in), "")
+			if err != nil {
+				t.Fatalf("Unexpected error in %q: %v", in, err)
+			}
+			ins = append(ins, in)
+			wants = append(wants, c.Bash)
+			gots = append(gots, got)
+		}
+	}
+	// check the files once all have been parsed, as later ones must
+	// not reuse the nodes of earlier ones
+	for i, got := range gots {
+		in := ins[i]
+		checkNewlines(t, in, got.Lines)
+		got.Lines = nil
+		got.Source, got.Mode = nil, 0
+		clearPosRecurse(t, in, got)
+		if !reflect.DeepEqual(got, wants[i]) {
+			t.Fatalf("AST mismatch in %q\ndiff:\n%s", in,
+				strings.Join(pretty.Diff(wants[i], got), "\n"),
+			)
+		}
+	}
+	p.Mode = PosixConformant
+	if _, err := p.Parse([]byte("foo() { bar; }"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Parse([]byte("function foo() { bar; }"), ""); err == nil {
+		t.Fatal("Expected an error with PosixConformant")
+	}
+}
+
 func TestMain(m *testing.M) {
 	bashVersion, bashError = checkBash()
 	os.Exit(m.Run())
@@ -186,6 +382,7 @@ func singleParse(in string, want *File, mode ParseMode) func(t *testing.T) {
 		}
 		checkNewlines(t, in, got.Lines)
 		got.Lines = nil
+		got.Source, got.Mode = nil, 0
 		clearPosRecurse(t, in, got)
 		if !reflect.DeepEqual(got, want) {
 			t.Fatalf("AST mismatch in %q\ndiff:\n%s", in,
@@ -227,14 +424,21 @@ func BenchmarkParse(b *testing.B) {
 		},
 	}
 	for _, c := range benchmarks {
+		in := []byte(c.in)
 		b.Run(c.name, func(b *testing.B) {
-			in := []byte(c.in)
 			for i := 0; i < b.N; i++ {
 				if _, err := Parse(in, "", ParseComments); err != nil {
 					b.Fatal(err)
 				}
 			}
 		})
+		b.Run(c.name+"+Share", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(in, "", ParseComments|ShareSource); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
 	}
 }
 
@@ -1013,3 +1217,84 @@ func TestInputName(t *testing.T) {
 			in, want, got)
 	}
 }
+
+func TestParseErrIncomplete(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"echo 'foo", true},
+		{`echo "foo`, true},
+		{"if foo; then", true},
+		{"while foo", true},
+		{"case x in", true},
+		{"foo &&", true},
+		{"foo |", true},
+		{"{ foo", true},
+		{"echo $(foo", true},
+		{"((foo", true},
+		{"[[ foo", true},
+		{"echo `foo", true},
+		{"echo )", false},
+		{"foo; }", false},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			_, err := Parse([]byte(tc.in), "", 0)
+			pe, ok := err.(*ParseError)
+			if !ok {
+				t.Fatalf("Expected a parse error in %q, got: %v", tc.in, err)
+			}
+			if pe.Incomplete != tc.want {
+				t.Fatalf("Incomplete mismatch in %q: want %t, got %t",
+					tc.in, tc.want, pe.Incomplete)
+			}
+		})
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in, want string
+		parts    int
+	}{
+		{"echo {{ .Name }}", "echo {{ .Name }}", 1},
+		{"echo  x{{ .A }}y", "echo x{{ .A }}y", 3},
+		{`echo "a {{ .B }} $c"`, `echo "a {{ .B }} $c"`, 1},
+		{"echo '{{ .C'", "echo '{{ .C'", 1},
+		{"FOO={{ .Foo }} make", "FOO={{ .Foo }} make", 1},
+		{"{% if debug %}\nset -x\n{% endif %}", "{% if debug %}\nset -x\n{% endif %}", 1},
+		{"{# a\ncomment #}\n{%- for x in y %}\n  echo {{ x }}\n{% endfor %}", "{# a\ncomment #}\n{%- for x in y %}\necho {{ x }}\n{% endfor %}", 1},
+		{"cat <<EOF\n{{ .Body }} $x\nEOF", "cat <<EOF\n{{ .Body }} $x\nEOF", 1},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := ParseTemplate([]byte(tc.in), "", 0, Jinja)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := (PrintConfig{}).Fprint(&buf, f); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+				t.Fatalf("ParseTemplate mismatch in %q:\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+			if ce, ok := f.Stmts[0].Cmd.(*CallExpr); ok {
+				w := ce.Args[len(ce.Args)-1]
+				if len(f.Stmts[0].Assigns) > 0 {
+					w = f.Stmts[0].Assigns[0].Value
+				}
+				if len(w.Parts) != tc.parts {
+					t.Fatalf("wrong number of parts in %q: want %d, got %d", tc.in, tc.parts, len(w.Parts))
+				}
+			}
+		})
+	}
+	_, err := ParseTemplate([]byte("echo ok {{ .X"), "", 0, GoTemplate)
+	if want := "1:9: reached EOF without matching {{ with }}"; err == nil || err.Error() != want {
+		t.Fatalf("ParseTemplate error mismatch:\nwant: %s\ngot:  %v", want, err)
+	}
+}
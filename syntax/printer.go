@@ -5,19 +5,36 @@ package syntax
 
 import (
 	"bufio"
+	"bytes"
 	"io"
+	"strings"
 	"sync"
 )
 
 // PrintConfig controls how the printing of an AST node will behave.
 type PrintConfig struct {
 	Spaces int // 0 (default) for tabs, >0 for number of spaces
+
+	// BinaryEndLine puts the operators of binary commands split
+	// across lines, like && and |, at the end of the first line
+	// instead of at the start of the next one.
+	BinaryEndLine bool
+
+	// Minify makes programs smaller by dropping their comments,
+	// except for a shebang, along with their indentation and empty
+	// lines.
+	Minify bool
+
+	// SingleLine prints programs on a single line, separating their
+	// statements with semicolons, as in "if a; then b; c; fi". It
+	// drops comments, and heredocs still end their lines.
+	SingleLine bool
 }
 
 var printerFree = sync.Pool{
 	New: func() interface{} {
 		return &printer{
-			bufWriter:  bufio.NewWriter(nil),
+			bufio:      bufio.NewWriter(nil),
 			lenPrinter: new(printer),
 		}
 	},
@@ -29,11 +46,31 @@ func (c PrintConfig) Fprint(w io.Writer, f *File) error {
 	p.reset()
 	p.f, p.c = f, c
 	p.comments = f.Comments
-	p.bufWriter.Reset(w)
+	if c.Minify {
+		p.comments = nil
+		if cs := f.Comments; len(cs) > 0 && cs[0].Hash == 1 && strings.HasPrefix(cs[0].Text, "!") {
+			p.comments = cs[:1]
+		}
+	}
+	if c.SingleLine {
+		// no position is ever past the next newline
+		p.comments = nil
+		p.nline = maxPos
+	}
+	if buf, ok := w.(*bytes.Buffer); ok {
+		// no need to batch the writes twice
+		p.bufWriter = directWriter{buf}
+	} else {
+		p.bufio.Reset(w)
+		p.bufWriter = p.bufio
+	}
 	p.stmts(f.Stmts)
 	p.commentsUpTo(0)
 	p.newline(0)
 	err := p.bufWriter.Flush()
+	// don't keep the writer nor the file alive in the pool
+	p.bufio.Reset(nil)
+	p.bufWriter, p.f, p.comments = nil, nil, nil
 	printerFree.Put(p)
 	return err
 }
@@ -44,15 +81,46 @@ func Fprint(w io.Writer, f *File) error {
 	return PrintConfig{}.Fprint(w, f)
 }
 
+// Format parses a program and prints it with the given settings in one
+// go, which is what most formatters need. The AST isn't kept around, so
+// the source strings are shared as with ShareSource, and SkipLines is
+// ignored as the printer needs the line table. Use ParseComments in
+// mode to keep the comments.
+func Format(src []byte, mode ParseMode, c PrintConfig) ([]byte, error) {
+	p := parserFree.Get().(*parser)
+	f, err := p.parse(src, "", (mode|ShareSource)&^SkipLines, nil)
+	parserFree.Put(p)
+	if err != nil {
+		return nil, err
+	}
+	// formatting rarely changes the size of a program by much
+	buf := bytes.NewBuffer(make([]byte, 0, len(src)+len(src)/8))
+	if err := c.Fprint(buf, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 type bufWriter interface {
+	Write([]byte) (int, error)
 	WriteByte(byte) error
 	WriteString(string) (int, error)
 	Reset(io.Writer)
 	Flush() error
 }
 
+// directWriter writes straight into a buffer, as wrapping it with a
+// bufio.Writer would only copy each byte twice.
+type directWriter struct {
+	*bytes.Buffer
+}
+
+func (w directWriter) Reset(io.Writer) {}
+func (w directWriter) Flush() error    { return nil }
+
 type printer struct {
 	bufWriter
+	bufio *bufio.Writer
 
 	f *File
 	c PrintConfig
@@ -133,6 +201,12 @@ func (p *printer) spacedString(s string, spaceAfter bool) {
 }
 
 func (p *printer) semiOrNewl(s string, pos Pos) {
+	if len(p.comments) > 0 && p.comments[0].Hash < pos {
+		// like in "for i in a # c\ndo", where the comment would
+		// swallow the reserved word if it were on the same line
+		p.commentsUpTo(pos)
+		p.wantNewline = true
+	}
 	if p.wantNewline {
 		p.newline(pos)
 		p.indent()
@@ -169,7 +243,7 @@ func (p *printer) decLevel() {
 func (p *printer) indent() {
 	p.lastLevel = p.level
 	switch {
-	case p.level == 0:
+	case p.level == 0, p.c.Minify:
 	case p.c.Spaces == 0:
 		for i := 0; i < p.level; i++ {
 			p.WriteByte('\t')
@@ -201,7 +275,9 @@ func (p *printer) newlines(pos Pos) {
 	p.newline(pos)
 	if pos > p.nline {
 		// preserve single empty lines
-		p.WriteByte('\n')
+		if !p.c.Minify {
+			p.WriteByte('\n')
+		}
 		p.incLine()
 	}
 	p.indent()
@@ -352,6 +428,9 @@ func (p *printer) wordPart(wp WordPart) {
 		p.WriteString(x.Op.String())
 		p.WriteString(x.Pattern.Value)
 		p.WriteByte(')')
+	case *TemplateExpr:
+		p.WriteString(x.Text)
+		p.incLines(x.End())
 	case *ProcSubst:
 		// avoid conflict with << and others
 		if p.wantSpace {
@@ -609,11 +688,19 @@ func (p *printer) command(cmd Command, redirs []*Redirect) (startRedirs int) {
 			p.incLevel()
 		}
 		_, p.nestedBinary = x.Y.Cmd.(*BinaryCmd)
-		if len(p.pendingHdocs) == 0 && x.Y.Pos() > p.nline {
+		switch {
+		case len(p.pendingHdocs) > 0 || x.Y.Pos() <= p.nline:
+			p.spacedString(x.Op.String(), true)
+		case p.c.BinaryEndLine:
+			p.spacedString(x.Op.String(), false)
+			p.WriteByte('\n')
+			p.incLine()
+			p.indent()
+		default:
 			p.bslashNewl()
 			p.indent()
+			p.spacedString(x.Op.String(), true)
 		}
-		p.spacedString(x.Op.String(), true)
 		p.incLines(x.Y.Pos())
 		p.stmt(x.Y)
 		if indent {
@@ -646,7 +733,8 @@ func (p *printer) command(cmd Command, redirs []*Redirect) (startRedirs int) {
 			}
 			p.WriteByte(')')
 			p.wantSpace = true
-			sep := len(pl.Stmts) > 1 || (len(pl.Stmts) > 0 && pl.Stmts[0].Pos() > p.nline)
+			sep := !p.c.SingleLine && (len(pl.Stmts) > 1 ||
+				(len(pl.Stmts) > 0 && pl.Stmts[0].Pos() > p.nline))
 			p.nestedStmts(pl.Stmts, 0)
 			p.level++
 			if sep {
@@ -656,7 +744,7 @@ func (p *printer) command(cmd Command, redirs []*Redirect) (startRedirs int) {
 			p.spacedString(pl.Op.String(), true)
 			p.incLines(pl.OpPos)
 			p.level--
-			if sep || pl.OpPos == x.Esac {
+			if sep || (pl.OpPos == x.Esac && !p.c.SingleLine) {
 				p.wantNewline = true
 			}
 		}
@@ -739,6 +827,9 @@ func (p *printer) stmts(stmts []*Stmt) {
 	case 0:
 		return
 	case 1:
+		if p.verbatim(stmts) > 0 {
+			return
+		}
 		s := stmts[0]
 		pos := s.Pos()
 		p.commentsUpTo(pos)
@@ -756,11 +847,27 @@ func (p *printer) stmts(stmts []*Stmt) {
 		return
 	}
 	inlineIndent := 0
-	for i, s := range stmts {
+	for i := 0; i < len(stmts); i++ {
+		if n := p.verbatim(stmts[i:]); n > 0 {
+			i += n - 1
+			inlineIndent = 0
+			p.commentPadding = 0
+			continue
+		}
+		s := stmts[i]
 		pos := s.Pos()
 		ind := p.nlineIndex
 		p.commentsUpTo(pos)
-		if p.nlineIndex > 0 {
+		switch {
+		case p.c.SingleLine && len(p.pendingHdocs) > 0:
+			p.newline(pos)
+		case p.c.SingleLine && i > 0:
+			if !stmts[i-1].Background {
+				p.WriteByte(';')
+			}
+			p.WriteByte(' ')
+			p.wantSpace = false
+		case p.nlineIndex > 0:
 			p.newlines(pos)
 		}
 		p.incLines(pos)
@@ -808,11 +915,120 @@ func (p *printer) stmts(stmts []*Stmt) {
 			p.commentPadding = inlineIndent - p.stmtLen(s)
 		}
 	}
+	p.wantNewline = !p.c.SingleLine || len(p.pendingHdocs) > 0
+}
+
+// fmtDirective reports whether a comment is a directive that turns
+// formatting off or on.
+func fmtDirective(c *Comment) (off, ok bool) {
+	switch strings.TrimSpace(c.Text) {
+	case "fmt: off", "shfmt: off":
+		return true, true
+	case "fmt: on", "shfmt: on":
+		return false, true
+	}
+	return false, false
+}
+
+// verbatim prints the statements that follow a "# fmt: off" comment
+// exactly as they appear in the source, until a "# fmt: on" comment or
+// the end of the list. It returns the number of statements printed,
+// which is zero if formatting isn't turned off.
+func (p *printer) verbatim(stmts []*Stmt) int {
+	if p.f.Source == nil {
+		return 0
+	}
+	first := stmts[0].Pos()
+	var off *Comment
+	for _, c := range p.comments {
+		if c.Hash >= first {
+			break
+		}
+		if o, ok := fmtDirective(c); ok {
+			off = nil
+			if o {
+				off = c
+			}
+		}
+	}
+	if off == nil {
+		return 0
+	}
+	lines := p.f.Lines
+	i := searchInts(lines, uint32(off.Hash)-1)
+	if i+1 >= len(lines) {
+		return 0
+	}
+	start := lines[i+1]
+	n, end := 0, Pos(0)
+	for ; n < len(stmts); n++ {
+		s := stmts[n]
+		if p.fmtOnBefore(s.Pos()) {
+			break
+		}
+		end = posMax(end, stmtEnd(s))
+	}
+	p.commentsUpTo(off.Hash + 1)
+	src := p.f.Source
+	endOff := int(end) - 1
+	if endOff > len(src) {
+		endOff = len(src)
+	}
+	if j := bytes.IndexByte(src[endOff:], '\n'); j >= 0 {
+		endOff += j
+	} else {
+		endOff = len(src)
+	}
+	p.newline(Pos(start + 1))
+	p.Write(src[start:endOff])
+	p.incLines(Pos(endOff + 1))
+	for len(p.comments) > 0 && int(p.comments[0].Hash) <= endOff {
+		p.comments = p.comments[1:]
+	}
+	p.wantSpace = false
 	p.wantNewline = true
+	return n
+}
+
+// fmtOnBefore reports whether any of the pending comments before a
+// position turns formatting back on.
+func (p *printer) fmtOnBefore(pos Pos) bool {
+	for _, c := range p.comments {
+		if c.Hash >= pos {
+			break
+		}
+		if off, ok := fmtDirective(c); ok && !off {
+			return true
+		}
+	}
+	return false
+}
+
+// stmtEnd is like Stmt.End, but also includes the heredocs of the
+// statement and of any statements nested within it.
+func stmtEnd(s *Stmt) Pos {
+	end := s.End()
+	Walk(hdocVisitor{&end}, s)
+	return end
+}
+
+type hdocVisitor struct {
+	end *Pos
+}
+
+func (v hdocVisitor) Visit(node Node) Visitor {
+	if r, ok := node.(*Redirect); ok && r.Hdoc != nil {
+		*v.end = posMax(*v.end, r.Hdoc.End())
+	}
+	return v
 }
 
 type byteCounter int
 
+func (c *byteCounter) Write(b []byte) (int, error) {
+	*c += byteCounter(len(b))
+	return 0, nil
+}
 func (c *byteCounter) WriteByte(b byte) error {
 	*c++
 	return nil
@@ -6,6 +6,7 @@ package syntax
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -36,6 +37,75 @@ func TestFprintCompact(t *testing.T) {
 	}
 }
 
+func TestFprintWriters(t *testing.T) {
+	t.Parallel()
+	for i, c := range fileTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			in := c.Strs[0]
+			prog, err := Parse([]byte(in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := strFprint(prog, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// hide the buffer, so that the printer batches the
+			// writes itself
+			var buf bytes.Buffer
+			if err := Fprint(struct{ io.Writer }{&buf}, prog); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != want {
+				t.Fatalf("Fprint mismatch\nwant: %q\ngot:  %q",
+					want, got)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	for i, c := range fileTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			in := c.Strs[0]
+			prog, err := Parse([]byte(in), "", ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := strFprint(prog, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format([]byte(in), ParseComments|SkipLines, PrintConfig{Spaces: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Fatalf("Format mismatch\nwant: %q\ngot:  %q",
+					want, got)
+			}
+		})
+	}
+	_, err := Format([]byte("foo)"), 0, PrintConfig{})
+	want := `1:4: a command can only contain words and redirects`
+	if err == nil || err.Error() != want {
+		t.Fatalf("Format error mismatch\nwant: %s\ngot:  %v", want, err)
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	src, err := ioutil.ReadFile(canonicalPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := Format(src, ParseComments, PrintConfig{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func strFprint(f *File, spaces int) (string, error) {
 	var buf bytes.Buffer
 	c := PrintConfig{Spaces: spaces}
@@ -78,6 +148,7 @@ func TestFprintWeirdFormat(t *testing.T) {
 		},
 		samePrint("foo <<EOF\nEOF\n\nbar"),
 		samePrint("foo <<'EOF'\nEOF\n\nbar"),
+		{"foo <<'EOF'\nbar\nEOF\n", "foo <<'EOF'\nbar\nEOF"},
 		{
 			"{ foo; bar; }",
 			"{\n\tfoo\n\tbar\n}",
@@ -191,6 +262,8 @@ func TestFprintWeirdFormat(t *testing.T) {
 			"for a in 1 2; do\n\t# bar\ndone",
 		},
 		samePrint("for a in 1 2; do\n\n\tbar\ndone"),
+		samePrint("for a in 1 2 # c\ndo bar; done"),
+		samePrint("while a # c\ndo bar; done"),
 		samePrint("a \\\n\t&& b"),
 		samePrint("a \\\n\t&& b\nc"),
 		{
@@ -337,6 +410,52 @@ func TestFprintWeirdFormat(t *testing.T) {
 	}
 }
 
+func TestFprintFmtOff(t *testing.T) {
+	t.Parallel()
+	var tests = [...]printCase{
+		{
+			"a\n# fmt: off\nfoo   bar\n  x=(1  2)\n# fmt: on\nb   c",
+			"a\n# fmt: off\nfoo   bar\n  x=(1  2)\n# fmt: on\nb c",
+		},
+		samePrint("f() {\n\t# fmt: off\n  foo   |  bar\n    # table\n  x   y\n}"),
+		samePrint("# shfmt: off\ncat  <<EOF\n  foo\nEOF\nb   c"),
+		{
+			"{\n# fmt: off\nfoo   bar\n}",
+			"{\n\t# fmt: off\nfoo   bar\n}",
+		},
+		{
+			"# fmt: off\na   b\n\n\nc   d; e\n# fmt: on\n\n\nd  e",
+			"# fmt: off\na   b\n\n\nc   d; e\n# fmt: on\n\nd e",
+		},
+		{
+			"# fmt: off\n# fmt: on\na   b",
+			"# fmt: off\n# fmt: on\na b",
+		},
+		{
+			"if a; then\n\t# fmt: off\n\tb   c # d\nfi;  e   f",
+			"if a; then\n\t# fmt: off\n\tb   c # d\nfi\ne f",
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			prog, err := Parse([]byte(tc.in), "", ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := strFprint(prog, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got != want {
+				t.Fatalf("Fprint mismatch:\n"+
+					"in:\n%s\nwant:\n%sgot:\n%s",
+					tc.in, want, got)
+			}
+		})
+	}
+}
+
 func parsePath(tb testing.TB, path string) *File {
 	f, err := os.Open(path)
 	if err != nil {
@@ -381,6 +500,17 @@ func BenchmarkFprint(b *testing.B) {
 	}
 }
 
+func BenchmarkFprintBuffer(b *testing.B) {
+	prog := parsePath(b, canonicalPath)
+	var buf bytes.Buffer
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := Fprint(&buf, prog); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestFprintSpaces(t *testing.T) {
 	var spaceFormats = [...]struct {
 		spaces   int
@@ -427,6 +557,91 @@ func TestFprintSpaces(t *testing.T) {
 	}
 }
 
+func TestFprintBinaryEndLine(t *testing.T) {
+	var tests = [...]printCase{
+		{"a &&\nb", "a &&\n\tb"},
+		{"a \\\n&& b \\\n|| c", "a &&\n\tb ||\n\tc"},
+		{"a | b", "a | b"},
+		{"{\n\ta |\n\t\tb\n}", "{\n\ta |\n\t\tb\n}"},
+	}
+	c := PrintConfig{BinaryEndLine: true}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			prog, err := Parse([]byte(tc.in), "", ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := c.Fprint(&buf, prog); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got := buf.String(); got != want {
+				t.Fatalf("Fprint mismatch:\nin:\n%s\nwant:\n%sgot:\n%s",
+					tc.in, want, got)
+			}
+		})
+	}
+}
+
+func TestFprintMinify(t *testing.T) {
+	var tests = [...]printCase{
+		{"#!/bin/sh\n# comment\nfoo # bar", "#!/bin/sh\nfoo"},
+		{"foo\n\n\nbar", "foo\nbar"},
+		{"if a; then\n\t# c\n\tb\nfi", "if a; then\nb\nfi"},
+		{"f() {\n\tcat <<EOF\n\tx\nEOF\n}", "f() {\ncat <<EOF\n\tx\nEOF\n}"},
+		{"foo # fmt: off\n  bar", "foo\nbar"},
+	}
+	c := PrintConfig{Minify: true}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			prog, err := Parse([]byte(tc.in), "", ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := c.Fprint(&buf, prog); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got := buf.String(); got != want {
+				t.Fatalf("Fprint mismatch:\nin:\n%s\nwant:\n%sgot:\n%s",
+					tc.in, want, got)
+			}
+		})
+	}
+}
+
+func TestFprintSingleLine(t *testing.T) {
+	var tests = [...]printCase{
+		{"foo # bar\nbar", "foo; bar"},
+		{"if a; then\n\tb\n\tc\nelse\n\td\nfi", "if a; then b; c; else d; fi"},
+		{"for i in a b; do\n\tx\n\ty\ndone &\nwait", "for i in a b; do x; y; done & wait"},
+		{"case $a in\nx)\n\tfoo\n\tbar\n\t;;\nesac", "case $a in x) foo; bar ;; esac"},
+		{"f() {\n\ta\n\tb\n}", "f() { a; b; }"},
+		{"a &&\n\tb \\\n\tc", "a && b c"},
+		{"cat <<EOF\nx\nEOF\nfoo", "cat <<EOF\nx\nEOF\nfoo"},
+	}
+	c := PrintConfig{SingleLine: true}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			prog, err := Parse([]byte(tc.in), "", ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := c.Fprint(&buf, prog); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + "\n"
+			if got := buf.String(); got != want {
+				t.Fatalf("Fprint mismatch:\nin:\n%s\nwant:\n%sgot:\n%s",
+					tc.in, want, got)
+			}
+		})
+	}
+}
+
 var errBadWriter = fmt.Errorf("write: expected error")
 
 type badWriter struct{}
@@ -0,0 +1,96 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+// ScopeFlags describes the constructs that a node is nested in.
+type ScopeFlags uint
+
+const (
+	InFunc       ScopeFlags = 1 << iota // within a function body
+	InSubshell                          // within ( )
+	InCmdSubst                          // within $( ) or ` `
+	InProcSubst                         // within <( ) or >( )
+	InPipeline                          // within a pipeline component
+	InBackground                        // within a statement ending in & or a coproc
+)
+
+// Scope describes the execution context of a node.
+type Scope struct {
+	Flags ScopeFlags
+
+	// Func is the innermost function declaration whose body contains
+	// the node, if any.
+	Func *FuncDecl
+
+	// Subshells is the number of nested subshell environments that
+	// the node is executed in.
+	Subshells int
+}
+
+// Persistent reports whether the changes a node makes to the shell
+// state, such as assigning variables or changing directories, persist
+// in the main shell environment. That is, whether the node isn't
+// executed in a subshell environment.
+func (s Scope) Persistent() bool { return s.Subshells == 0 }
+
+// WalkScoped traverses an AST in depth-first order like Walk, calling f
+// for each node along with the scope it is executed in. If f returns
+// false, the children of the node are not visited.
+//
+// Note that Bash runs every pipeline component in a subshell, so
+// assignments within pipelines don't persist.
+func WalkScoped(node Node, f func(node Node, sc Scope) bool) {
+	Walk(scopeVisitor{f: f}, node)
+}
+
+type scopeVisitor struct {
+	f  func(Node, Scope) bool
+	sc Scope
+
+	// pipe is set when visiting the children of a pipeline, so that
+	// nested pipelines like "a | b | c" aren't counted twice.
+	pipe bool
+}
+
+func isPipe(cmd Command) bool {
+	b, ok := cmd.(*BinaryCmd)
+	return ok && (b.Op == Pipe || b.Op == PipeAll)
+}
+
+func (v scopeVisitor) Visit(node Node) Visitor {
+	if node == nil || !v.f(node, v.sc) {
+		return nil
+	}
+	w := scopeVisitor{f: v.f, sc: v.sc}
+	subshell := func(flag ScopeFlags) {
+		w.sc.Flags |= flag
+		w.sc.Subshells++
+	}
+	switch x := node.(type) {
+	case *FuncDecl:
+		w.sc.Flags |= InFunc
+		w.sc.Func = x
+	case *Subshell:
+		subshell(InSubshell)
+	case *CmdSubst:
+		subshell(InCmdSubst)
+	case *ProcSubst:
+		subshell(InProcSubst)
+	case *CoprocClause:
+		subshell(InBackground)
+	case *Stmt:
+		if x.Background {
+			subshell(InBackground)
+		}
+		w.pipe = v.pipe && isPipe(x.Cmd)
+	case *BinaryCmd:
+		if isPipe(x) {
+			if !v.pipe {
+				subshell(InPipeline)
+			}
+			w.pipe = true
+		}
+	}
+	return w
+}
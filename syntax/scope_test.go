@@ -0,0 +1,62 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWalkScoped(t *testing.T) {
+	t.Parallel()
+	type scoped struct {
+		name      string
+		flags     ScopeFlags
+		subshells int
+	}
+	var tests = []struct {
+		in   string
+		want []scoped
+	}{
+		{"a; { b; }", []scoped{{"a", 0, 0}, {"b", 0, 0}}},
+		{"(a; (b))", []scoped{{"a", InSubshell, 1}, {"b", InSubshell, 2}}},
+		{"a | b | c", []scoped{
+			{"a", InPipeline, 1},
+			{"b", InPipeline, 1},
+			{"c", InPipeline, 1},
+		}},
+		{"a; b &", []scoped{{"a", 0, 0}, {"b", InBackground, 1}}},
+		{"f() { a $(b) <(c); }", []scoped{
+			{"a", InFunc, 0},
+			{"b", InFunc | InCmdSubst, 1},
+			{"c", InFunc | InProcSubst, 1},
+		}},
+		{"a | (b)", []scoped{{"a", InPipeline, 1}, {"b", InPipeline | InSubshell, 2}}},
+		{"coproc a", []scoped{{"a", InBackground, 1}}},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []scoped
+			WalkScoped(f, func(node Node, sc Scope) bool {
+				if ce, ok := node.(*CallExpr); ok {
+					name := ce.Args[0].Parts[0].(*Lit).Value
+					got = append(got, scoped{name, sc.Flags, sc.Subshells})
+					if (sc.Flags&InFunc != 0) != (sc.Func != nil) {
+						t.Fatalf("InFunc does not match Func")
+					}
+				}
+				return true
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("WalkScoped mismatch in %q\nwant: %v\ngot:  %v",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
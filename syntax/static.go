@@ -0,0 +1,137 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"bytes"
+	"strings"
+)
+
+// StaticValue returns the value of a word if it can be determined
+// without performing any expansions or substitutions, that is, if it
+// only consists of literals and quotes. Quotes and escapes are removed,
+// and $'...' strings have their escape sequences decoded.
+//
+// Words starting with an unquoted tilde are not static. Note that
+// pattern characters like * and brace expressions like {a,b} are
+// returned as they are.
+func StaticValue(w *Word) (string, bool) {
+	var buf bytes.Buffer
+	for i, wp := range w.Parts {
+		switch x := wp.(type) {
+		case *Lit:
+			if i == 0 && x.Value != "" && x.Value[0] == '~' {
+				return "", false
+			}
+			unescape(&buf, x.Value, "")
+		case *SglQuoted:
+			if x.Dollar {
+				decodeANSIC(&buf, x.Value)
+			} else {
+				buf.WriteString(x.Value)
+			}
+		case *DblQuoted:
+			for _, wp2 := range x.Parts {
+				lit, ok := wp2.(*Lit)
+				if !ok {
+					return "", false
+				}
+				unescape(&buf, lit.Value, "\\\"$`\n")
+			}
+		default:
+			return "", false
+		}
+	}
+	return buf.String(), true
+}
+
+// unescape writes s to buf, removing the backslashes that escape any of
+// the escapable bytes, or any byte if escapable is empty. Escaped
+// newlines are removed altogether.
+func unescape(buf *bytes.Buffer, s, escapable string) {
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b == '\\' && i+1 < len(s) {
+			if escapable == "" || strings.IndexByte(escapable, s[i+1]) >= 0 {
+				if i++; s[i] == '\n' {
+					continue
+				}
+				b = s[i]
+			}
+		}
+		buf.WriteByte(b)
+	}
+}
+
+// decodeANSIC writes s to buf, decoding the escape sequences supported
+// within $'...' strings.
+func decodeANSIC(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b != '\\' || i+1 == len(s) {
+			buf.WriteByte(b)
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'e', 'E':
+			buf.WriteByte(0x1b)
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			buf.WriteByte(c)
+		case 'x':
+			n, l := readBase(s[i+1:], 16, 2)
+			if l == 0 {
+				buf.WriteString("\\x")
+				break
+			}
+			buf.WriteByte(byte(n))
+			i += l
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n, l := readBase(s[i:], 8, 3)
+			buf.WriteByte(byte(n))
+			i += l - 1
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// readBase reads up to max digits in the given base from the start of
+// s, returning the resulting number and the amount of digits read.
+func readBase(s string, base, max int) (n, l int) {
+	for l < max && l < len(s) {
+		var d int
+		switch c := s[l]; {
+		case '0' <= c && c <= '9':
+			d = int(c - '0')
+		case 'a' <= c && c <= 'f':
+			d = int(c-'a') + 10
+		case 'A' <= c && c <= 'F':
+			d = int(c-'A') + 10
+		default:
+			return
+		}
+		if d >= base {
+			return
+		}
+		n = n*base + d
+		l++
+	}
+	return
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package syntax
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStaticValue(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`foo`, "foo", true},
+		{`foo\ bar`, "foo bar", true},
+		{`'foo bar'\'`, "foo bar'", true},
+		{`"a\"b\$c\d"`, `a"b$c\d`, true},
+		{`"foo"'bar'baz`, "foobarbaz", true},
+		{"\"a\\\nb\"", "ab", true},
+		{`$'a\nb\x41\101\q'`, "a\nbAA\\q", true},
+		{`*.sh`, "*.sh", true},
+		{`a~`, "a~", true},
+		{`~/foo`, "", false},
+		{`$foo`, "", false},
+		{`"$foo"`, "", false},
+		{`foo$(bar)`, "", false},
+		{`$((1))`, "", false},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := f.Stmts[0].Cmd.(*CallExpr).Args[0]
+			got, ok := StaticValue(w)
+			if got != tc.want || ok != tc.ok {
+				t.Fatalf("StaticValue mismatch in %q\nwant: %q, %t\ngot:  %q, %t",
+					tc.in, tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
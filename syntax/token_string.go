@@ -4,9 +4,9 @@ package syntax
 
 import "fmt"
 
-const _token_name = "illegalTokEOFLitLitWord'\"`&&&||||&$$'$\"${$[$($(([(((}])));;;;&;;&!++--***==!=<=>=+=-=*=/=%=&=|=^=<<=>>=>>><<><&>&>|<<<<-<<<&>&>><(>(+:+-:-?:?=:=%%%###^^^,,,///:-e-f-d-c-b-p-S-L-g-u-r-w-x-s-t-z-n-o-v-R=~-nt-ot-ef-eq-ne-le-ge-lt-gt?(*(+(@(!("
+const _token_name = "illegalTokEOFLitLitWord'\"`&&&||||&$$'$\"${$[$($(([(((}])));;;;&;;&!++--***==!=<=>=+=-=*=/=%=&=|=^=<<=>>=>>><<><&>&>|<<<<-<<<&>&>><(>(+:+-:-?:?=:=%%%###^^^,,,///:-e-f-d-c-b-p-S-L-g-u-r-w-x-s-t-z-n-o-v-R=~-nt-ot-ef-eq-ne-le-ge-lt-gt?(*(+(@(!(template"
 
-var _token_index = [...]uint8{0, 10, 13, 16, 23, 24, 25, 26, 27, 29, 31, 32, 34, 35, 37, 39, 41, 43, 45, 48, 49, 50, 52, 53, 54, 55, 57, 58, 60, 62, 65, 66, 68, 70, 71, 73, 75, 77, 79, 81, 83, 85, 87, 89, 91, 93, 95, 97, 100, 103, 104, 106, 107, 109, 111, 113, 115, 117, 120, 123, 125, 128, 130, 132, 133, 135, 136, 138, 139, 141, 142, 144, 145, 147, 148, 150, 151, 153, 154, 156, 157, 159, 160, 162, 164, 166, 168, 170, 172, 174, 176, 178, 180, 182, 184, 186, 188, 190, 192, 194, 196, 198, 200, 202, 205, 208, 211, 214, 217, 220, 223, 226, 229, 231, 233, 235, 237, 239}
+var _token_index = [...]uint8{0, 10, 13, 16, 23, 24, 25, 26, 27, 29, 31, 32, 34, 35, 37, 39, 41, 43, 45, 48, 49, 50, 52, 53, 54, 55, 57, 58, 60, 62, 65, 66, 68, 70, 71, 73, 75, 77, 79, 81, 83, 85, 87, 89, 91, 93, 95, 97, 100, 103, 104, 106, 107, 109, 111, 113, 115, 117, 120, 123, 125, 128, 130, 132, 133, 135, 136, 138, 139, 141, 142, 144, 145, 147, 148, 150, 151, 153, 154, 156, 157, 159, 160, 162, 164, 166, 168, 170, 172, 174, 176, 178, 180, 182, 184, 186, 188, 190, 192, 194, 196, 198, 200, 202, 205, 208, 211, 214, 217, 220, 223, 226, 229, 231, 233, 235, 237, 239, 247}
 
 func (i token) String() string {
 	if i >= token(len(_token_index)-1) {
@@ -139,6 +139,8 @@ const (
 	globPlus  // +(
 	globAt    // @(
 	globExcl  // !(
+
+	tmplExpr // template
 )
 
 type RedirOperator token
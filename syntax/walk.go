@@ -111,6 +111,7 @@ func Walk(v Visitor, node Node) {
 		}
 	case *Lit:
 	case *SglQuoted:
+	case *TemplateExpr:
 	case *DblQuoted:
 		for _, wp := range x.Parts {
 			Walk(v, wp)
@@ -124,6 +125,14 @@ func Walk(v Visitor, node Node) {
 		if x.Ind != nil {
 			Walk(v, x.Ind.Expr)
 		}
+		if x.Slice != nil {
+			if x.Slice.Offset != nil {
+				Walk(v, x.Slice.Offset)
+			}
+			if x.Slice.Length != nil {
+				Walk(v, x.Slice.Length)
+			}
+		}
 		if x.Repl != nil {
 			Walk(v, x.Repl.Orig)
 			Walk(v, x.Repl.With)
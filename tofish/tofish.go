@@ -0,0 +1,422 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package tofish converts a subset of shell programs to fish.
+//
+// It's a best effort to help move scripts and dotfiles to fish, for the
+// constructs that map cleanly to it. The result is meant to be read and
+// edited, and the constructs that fish has no equivalent for are
+// reported as errors instead of being converted wrongly.
+package tofish
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/internal/convert"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Error is a construct that couldn't be converted.
+type Error convert.Error
+
+func (e *Error) Error() string { return (*convert.Error)(e).Error() }
+
+// indent is the indentation of fish_indent.
+const indent = "    "
+
+// Convert returns the fish source of a shell program, or an error with
+// the first construct that it doesn't support.
+//
+// These are supported: assignments, exports and local variables,
+// arrays, words with quotes, parameter expansions like $v, $1, $# or
+// ${#v}, $(( )) with integers, command substitutions, pipes, &&, ||
+// and !, redirections to and from files, if, while, until, for and case
+// clauses, blocks, functions, tests with [[ ]], and running commands.
+// Positional parameters become $argv, and [[ ]] becomes test.
+//
+// Subshells, here-documents, C-style loops, (( )), eval, coproc and the
+// set builtin aren't supported, nor are parameter expansions with
+// operators like ${v:-x}. Unquoted expansions are never split into
+// fields, as if they were quoted. Comments are kept before the statement
+// that follows them, or after the one they trail. A shebang is replaced
+// by one for fish.
+func Convert(f *syntax.File) ([]byte, error) {
+	c := &converter{f: f}
+	var buf bytes.Buffer
+	if len(f.Comments) > 0 && f.Comments[0].Hash == 1 &&
+		strings.HasPrefix(f.Comments[0].Text, "!") {
+		buf.WriteString("#!/usr/bin/env fish\n")
+		c.comment++
+	}
+	if f.Name != "" {
+		fmt.Fprintf(&buf, "# This program was converted from %s.\n", f.Name)
+	}
+	buf.WriteString(c.lines(f.Stmts, ""))
+	buf.WriteString(c.comments(0, ""))
+	if c.err != nil {
+		return nil, c.err
+	}
+	return buf.Bytes(), nil
+}
+
+type converter struct {
+	f   *syntax.File
+	err error
+
+	// comment is the index of the next comment to print
+	comment int
+
+	// funcs is how many functions the converted code is in
+	funcs int
+}
+
+func (c *converter) errorf(node syntax.Node, format string, a ...interface{}) {
+	if c.err == nil {
+		err := Error(convert.Errorf(c.f, node, format, a...))
+		c.err = &err
+	}
+}
+
+// comments returns the comments before a position, or all the ones
+// left if it's zero, each on its own line.
+func (c *converter) comments(before syntax.Pos, ind string) string {
+	var s string
+	for ; c.comment < len(c.f.Comments); c.comment++ {
+		cm := c.f.Comments[c.comment]
+		if before > 0 && cm.Pos() >= before {
+			break
+		}
+		s += ind + "#" + cm.Text + "\n"
+	}
+	return s
+}
+
+// block returns the source of the statements in a block, which is
+// indented one level more than ind.
+func (c *converter) block(stmts []*syntax.Stmt, ind string) string {
+	return c.lines(stmts, ind+indent)
+}
+
+// lines returns the source of a list of statements indented with ind,
+// with a newline after each.
+func (c *converter) lines(stmts []*syntax.Stmt, ind string) string {
+	var s string
+	for _, st := range stmts {
+		s += c.comments(st.Pos(), ind)
+		s += ind + c.stmt(st, ind)
+		if c.comment < len(c.f.Comments) {
+			// keep a comment on the same line if nothing but a
+			// semicolon separates it from the statement
+			cm := c.f.Comments[c.comment]
+			if cm.Pos() > st.End() && strings.Trim(string(
+				c.f.Source[st.End()-1:cm.Pos()-1]), " \t;") == "" {
+				s += " #" + cm.Text
+				c.comment++
+			}
+		}
+		s += "\n"
+	}
+	return s
+}
+
+// stmt returns the source of a statement, which starts at the current
+// line; any other lines are indented with ind.
+func (c *converter) stmt(s *syntax.Stmt, ind string) string {
+	var parts []string
+	if s.Negated {
+		parts = append(parts, "not")
+	}
+	switch {
+	case s.Cmd == nil:
+		var sets []string
+		for _, as := range s.Assigns {
+			sets = append(sets, c.set("", as))
+		}
+		parts = append(parts, strings.Join(sets, "; "))
+	case len(s.Assigns) > 0:
+		if _, ok := s.Cmd.(*syntax.CallExpr); !ok {
+			c.errorf(s, "assignments before %s aren't supported", convert.CmdName(s.Cmd))
+		}
+		for _, as := range s.Assigns {
+			if as.Append || as.Value != nil && isArray(as.Value) {
+				c.errorf(as, "this assignment before a command isn't supported")
+			}
+			value := `""`
+			if as.Value != nil {
+				value = c.word(as.Value)
+			}
+			parts = append(parts, as.Name.Value+"="+value)
+		}
+		fallthrough
+	default:
+		parts = append(parts, c.command(s.Cmd, ind))
+	}
+	for _, r := range s.Redirs {
+		parts = append(parts, c.redirect(r))
+	}
+	if s.Background {
+		parts = append(parts, "&")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *converter) command(cmd syntax.Command, ind string) string {
+	switch x := cmd.(type) {
+	case *syntax.CallExpr:
+		return c.call(x)
+	case *syntax.BinaryCmd:
+		var op string
+		switch x.Op {
+		case syntax.AndStmt:
+			op = "&&"
+		case syntax.OrStmt:
+			op = "||"
+		case syntax.Pipe:
+			op = "|"
+		case syntax.PipeAll:
+			op = "&|"
+		}
+		return c.stmt(x.X, ind) + " " + op + " " + c.stmt(x.Y, ind)
+	case *syntax.Block:
+		return "begin\n" + c.block(x.Stmts, ind) + ind + "end"
+	case *syntax.IfClause:
+		s := "if " + c.cond(x, x.CondStmts, ind) + "\n" + c.block(x.ThenStmts, ind)
+		for _, elif := range x.Elifs {
+			s += ind + "else if " + c.cond(x, elif.CondStmts, ind) + "\n" +
+				c.block(elif.ThenStmts, ind)
+		}
+		if len(x.ElseStmts) > 0 {
+			s += ind + "else\n" + c.block(x.ElseStmts, ind)
+		}
+		return s + ind + "end"
+	case *syntax.WhileClause:
+		return "while " + c.cond(x, x.CondStmts, ind) + "\n" + c.block(x.DoStmts, ind) + ind + "end"
+	case *syntax.UntilClause:
+		return "while not " + c.cond(x, x.CondStmts, ind) + "\n" + c.block(x.DoStmts, ind) + ind + "end"
+	case *syntax.ForClause:
+		wi, ok := x.Loop.(*syntax.WordIter)
+		if !ok {
+			c.errorf(x, "C-style for loops aren't supported")
+			return ""
+		}
+		list := "$argv"
+		if len(wi.List) > 0 {
+			list = c.words(wi.List)
+		}
+		return "for " + wi.Name.Value + " in " + list + "\n" + c.block(x.DoStmts, ind) + ind + "end"
+	case *syntax.CaseClause:
+		return c.caseClause(x, ind)
+	case *syntax.FuncDecl:
+		body, ok := x.Body.Cmd.(*syntax.Block)
+		if !ok || !plain(x.Body) {
+			c.errorf(x, "functions are only supported with a { } body")
+			return ""
+		}
+		c.funcs++
+		s := "function " + x.Name.Value + "\n" + c.block(body.Stmts, ind) + ind + "end"
+		c.funcs--
+		return s
+	case *syntax.DeclClause:
+		return c.decl(x)
+	case *syntax.TestClause:
+		return "test " + c.test(x.X)
+	}
+	c.errorf(cmd, "%s isn't supported", convert.CmdName(cmd))
+	return ""
+}
+
+// plain reports whether a statement is only its command.
+func plain(s *syntax.Stmt) bool {
+	return !s.Negated && !s.Background && len(s.Assigns) == 0 && len(s.Redirs) == 0
+}
+
+// cond returns the fish condition for the statements of a clause. As
+// fish only takes one command as the condition, more than one are put
+// in a begin block.
+func (c *converter) cond(node syntax.Node, stmts []*syntax.Stmt, ind string) string {
+	if len(stmts) == 1 {
+		return c.stmt(stmts[0], ind)
+	}
+	var strs []string
+	for _, s := range stmts {
+		str := c.stmt(s, ind)
+		if strings.Contains(str, "\n") {
+			c.errorf(node, "conditions with more than one command must fit in a line")
+		}
+		strs = append(strs, str)
+	}
+	return "begin; " + strings.Join(strs, "; ") + "; end"
+}
+
+func (c *converter) caseClause(cc *syntax.CaseClause, ind string) string {
+	s := "switch " + c.word(cc.Word) + "\n"
+	for _, pl := range cc.List {
+		if pl.Op != syntax.DblSemicolon {
+			c.errorf(cc, "case fallthroughs aren't supported")
+			return ""
+		}
+		var pats []string
+		for _, w := range pl.Patterns {
+			pats = append(pats, c.pattern(w))
+		}
+		s += ind + indent + "case " + strings.Join(pats, " ") + "\n" +
+			c.block(pl.Stmts, ind+indent)
+	}
+	return s + ind + "end"
+}
+
+// set returns the fish command that assigns a variable, with the flags
+// for its scope, if any.
+func (c *converter) set(flags string, as *syntax.Assign) string {
+	if as.Name == nil {
+		c.errorf(as.Value, "this assignment isn't supported")
+		return ""
+	}
+	cmd := "set "
+	if flags != "" {
+		cmd += flags + " "
+	}
+	cmd += as.Name.Value
+	switch {
+	case as.Value == nil:
+		if !as.Append {
+			cmd += ` ""`
+		}
+	case isArray(as.Value):
+		if as.Append {
+			cmd = strings.Replace(cmd, "set ", "set -a ", 1)
+		}
+		if list := c.words(as.Value.Parts[0].(*syntax.ArrayExpr).List); list != "" {
+			cmd += " " + list
+		}
+	case as.Append:
+		cmd += " " + c.join([]token{{kind: varToken, s: "$" + as.Name.Value}}, c.tokens(as.Value))
+	default:
+		cmd += " " + c.word(as.Value)
+	}
+	return cmd
+}
+
+func isArray(w *syntax.Word) bool {
+	_, ok := w.Parts[0].(*syntax.ArrayExpr)
+	return ok && len(w.Parts) == 1
+}
+
+func (c *converter) decl(dc *syntax.DeclClause) string {
+	flags := ""
+	switch dc.Variant {
+	case "export":
+		flags = "-gx"
+	case "local":
+		flags = "-l"
+	case "":
+		if c.funcs > 0 {
+			flags = "-l"
+		} else {
+			flags = "-g"
+		}
+	default:
+		c.errorf(dc, "%s isn't supported", dc.Variant)
+		return ""
+	}
+	for _, w := range dc.Opts {
+		opt, _ := syntax.StaticValue(w)
+		switch {
+		case dc.Variant == "" && opt == "-x":
+			flags += "x"
+		case dc.Variant == "" && opt == "-a":
+		default:
+			c.errorf(w, "%s %s isn't supported", dc.Variant, opt)
+			return ""
+		}
+	}
+	var sets []string
+	for _, as := range dc.Assigns {
+		if as.Name == nil {
+			// "export v" or "local v", without a value
+			name, ok := syntax.StaticValue(as.Value)
+			if !ok || !validName(name) {
+				c.errorf(as.Value, "this declaration isn't supported")
+				return ""
+			}
+			value := ""
+			if dc.Variant == "export" {
+				value = ` "$` + name + `"`
+			}
+			sets = append(sets, "set "+flags+" "+name+value)
+			continue
+		}
+		sets = append(sets, c.set(flags, as))
+	}
+	return strings.Join(sets, "; ")
+}
+
+// call converts a simple command.
+func (c *converter) call(ce *syntax.CallExpr) string {
+	name, _ := syntax.StaticValue(ce.Args[0])
+	args := ce.Args[1:]
+	switch name {
+	case ".":
+		return c.renamed("source", args)
+	case ":":
+		return c.renamed("true", args)
+	case "set", "shopt":
+		c.errorf(ce, "%s isn't supported, as fish has no shell options", name)
+		return ""
+	case "unset":
+		cmd := "set -e"
+		if len(args) > 0 {
+			switch opt, _ := syntax.StaticValue(args[0]); opt {
+			case "-v":
+				args = args[1:]
+			case "-f":
+				cmd, args = "functions -e", args[1:]
+			}
+		}
+		return c.renamed(cmd, args)
+	case "local", "export", "declare", "typeset", "readonly":
+		c.errorf(ce, "this %s isn't supported", name)
+		return ""
+	}
+	return c.words(ce.Args)
+}
+
+// renamed returns a call to a fish command with the given arguments.
+func (c *converter) renamed(name string, args []*syntax.Word) string {
+	if len(args) == 0 {
+		return name
+	}
+	return name + " " + c.words(args)
+}
+
+func (c *converter) redirect(r *syntax.Redirect) string {
+	var op string
+	switch r.Op {
+	case syntax.RdrOut:
+		op = ">"
+	case syntax.AppOut:
+		op = ">>"
+	case syntax.RdrIn:
+		op = "<"
+	case syntax.DplIn:
+		op = "<&"
+	case syntax.DplOut:
+		op = ">&"
+	case syntax.RdrAll:
+		op = "&>"
+	case syntax.AppAll:
+		op = "&>>"
+	case syntax.Hdoc, syntax.DashHdoc:
+		c.errorf(r, "here-documents aren't supported")
+		return ""
+	default:
+		c.errorf(r, "%s redirections aren't supported", r.Op)
+		return ""
+	}
+	if r.N != nil {
+		op = r.N.Value + op
+	}
+	return op + c.word(r.Word)
+}
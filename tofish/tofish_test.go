@@ -0,0 +1,114 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package tofish
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"echo foo 'bar baz' \"a\\$b\" *.sh ~/x", "echo foo \"bar baz\" \"a\\$b\" *.sh ~/x\n"},
+		{"a=1; a+=\"x$a\"; echo \"$a/b\" ${a}c", "set a 1\nset a \"$a\"\"x$a\"\necho \"$a/b\" \"$a\"\"c\"\n"},
+		{"export PATH=$PATH:$HOME/bin E=vim", "set -gx PATH \"$PATH:$HOME/bin\"; set -gx E vim\n"},
+		{"echo $1 \"$@\" $# $? ${#x} $0", "echo \"$argv[1]\" $argv (count $argv) \"$status\" (string length -- \"$x\") (status filename)\n"},
+		{"d=$(date +%s) cmd 2>/dev/null", "d=(date +%s) cmd 2>/dev/null\n"},
+		{"echo $((n + 1 / 2)) <(ls)", "echo (math -s0 \"$n + 1 / 2\") (ls | psub)\n"},
+		{"arr=(a \"b c\"); ! a | b &", "set arr a \"b c\"\nnot a | b &\n"},
+		{"a && b || c", "a && b || c\n"},
+		{
+			"if [[ -n $x && $a == b ]]; then echo; elif [ -f f ]; then :; else exit 1; fi",
+			"if test -n \"$x\" -a \"$a\" = b\n    echo\nelse if [ -f f ]\n    true\nelse\n    exit 1\nend\n",
+		},
+		{"while read -r l; do break; done <in >>out", "while read -r l\n    break\nend <in >>out\n"},
+		{"until false; do :; done", "while not false\n    true\nend\n"},
+		{"for i in a $b; do echo $i; done", "for i in a \"$b\"\n    echo \"$i\"\nend\n"},
+		{"for i; do :; done", "for i in $argv\n    true\nend\n"},
+		{"case $1 in -h | --help) usage ;; *) exit ;; esac", "switch \"$argv[1]\"\n    case -h --help\n        usage\n    case \"*\"\n        exit\nend\n"},
+		{"f() {\n\tlocal n=$1\n\t# doc\n\techo \"hi $n\" >&2\n}", "function f\n    set -l n \"$argv[1]\"\n    # doc\n    echo \"hi $n\" >&2\nend\n"},
+		{"declare x=1; f() { declare -x y; }", "set -g x 1\nfunction f\n    set -lx y\nend\n"},
+		{"a # x\n# y\nif b; then c; fi # z", "a # x\n# y\nif b\n    c\nend # z\n"},
+		{". ./f; unset a; unset -f g", "source ./f\nset -e a\nfunctions -e g\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", syntax.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := Convert(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(src); got != tc.want {
+				t.Fatalf("Convert mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertShebang(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"#!/bin/sh\necho", "#!/usr/bin/env fish\n# This program was converted from f.sh.\necho\n"},
+		{"# doc\necho", "# This program was converted from f.sh.\n# doc\necho\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "f.sh", syntax.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := Convert(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(src); got != tc.want {
+				t.Fatalf("Convert mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"(cd x)", "1:1: a subshell isn't supported"},
+		{"cat <<EOF\nx\nEOF", "1:5: here-documents aren't supported"},
+		{"echo ${a:-b}", "1:6: this parameter expansion isn't supported"},
+		{"echo ${#:-a}", "1:6: this parameter expansion isn't supported"},
+		{"echo $(())", "1:6: empty arithmetic expansions aren't supported"},
+		{"echo {1..3}", "1:6: sequence expressions aren't supported"},
+		{"echo \"x$@\"", "1:6: $@ is only supported as a whole word"},
+		{"set -e", "1:1: set isn't supported, as fish has no shell options"},
+		{"[[ $a == *.go ]]", "1:10: pattern matching isn't supported; use string match"},
+		{"for ((;;)); do :; done", "1:1: C-style for loops aren't supported"},
+		{"echo [ab]", "1:6: character classes in globs aren't supported"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Convert(f)
+			if err == nil {
+				t.Fatalf("Convert in %q did not error", tc.in)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("error mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
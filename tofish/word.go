@@ -0,0 +1,418 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package tofish
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+type tokenKind int
+
+const (
+	// rawToken is unquoted, like a glob
+	rawToken tokenKind = iota
+	// litToken is a literal string, quoted if needed
+	litToken
+	// varToken is a variable like $v, put in double quotes
+	varToken
+	// listToken is a list variable like $argv, which is unquoted so
+	// that each element is a separate argument
+	listToken
+	// cmdToken is a command substitution like (date), which fish
+	// doesn't expand in double quotes
+	cmdToken
+)
+
+// token is a part of a fish word.
+type token struct {
+	kind tokenKind
+	s    string
+}
+
+func (c *converter) words(ws []*syntax.Word) string {
+	strs := make([]string, len(ws))
+	for i, w := range ws {
+		strs[i] = c.word(w)
+	}
+	return strings.Join(strs, " ")
+}
+
+// word returns the fish word for a shell word.
+func (c *converter) word(w *syntax.Word) string {
+	return c.join(c.tokens(w))
+}
+
+// pattern returns the fish word for a pattern of a case clause, which
+// is quoted so that fish only matches it against the value.
+func (c *converter) pattern(w *syntax.Word) string {
+	toks := c.tokens(w)
+	for i, t := range toks {
+		if t.kind == rawToken {
+			toks[i].kind = litToken
+		}
+	}
+	return c.join(toks)
+}
+
+// join returns a fish word made of tokens, quoting the ones that need
+// it.
+func (c *converter) join(groups ...[]token) string {
+	var toks []token
+	for _, g := range groups {
+		toks = append(toks, g...)
+	}
+	var buf bytes.Buffer
+	quoted, forceQuote := false, false
+	setQuoted := func(q bool) {
+		if quoted != q {
+			buf.WriteByte('"')
+			quoted = q
+		}
+	}
+	for i, t := range toks {
+		switch t.kind {
+		case rawToken, listToken, cmdToken:
+			setQuoted(false)
+			buf.WriteString(t.s)
+		case varToken:
+			setQuoted(true)
+			buf.WriteString(t.s)
+			// a name character or an index after the variable
+			// would be part of it
+			if i+1 < len(toks) && toks[i+1].kind == litToken && continuesVar(toks[i+1].s) {
+				setQuoted(false)
+				forceQuote = true
+			}
+		case litToken:
+			if !quoted && !forceQuote && bare(t.s) {
+				buf.WriteString(t.s)
+				continue
+			}
+			forceQuote = false
+			setQuoted(true)
+			buf.WriteString(escapeQuoted(t.s))
+		}
+	}
+	setQuoted(false)
+	if buf.Len() == 0 {
+		return `""`
+	}
+	return buf.String()
+}
+
+// bare reports whether a literal needs no quotes in fish.
+func bare(s string) bool {
+	for _, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("_./:,+=@%[]-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func continuesVar(s string) bool {
+	if s == "" {
+		return false
+	}
+	b := s[0]
+	return b == '_' || b == '[' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+var quotedEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+
+func escapeQuoted(s string) string {
+	return quotedEscaper.Replace(s)
+}
+
+// tokens returns the tokens of a shell word.
+func (c *converter) tokens(w *syntax.Word) []token {
+	var toks []token
+	lit := func(part syntax.WordPart) {
+		s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{part}})
+		toks = append(toks, token{litToken, s})
+	}
+	for i, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			switch {
+			case strings.Contains(x.Value, `\`):
+				lit(x)
+			case strings.Contains(x.Value, "[") && x.Value != "[" && x.Value != "[[":
+				c.errorf(x, "character classes in globs aren't supported")
+			case strings.Contains(x.Value, "{") && strings.Contains(x.Value, ".."):
+				c.errorf(x, "sequence expressions aren't supported")
+			case strings.ContainsAny(x.Value, "*?{") || i == 0 && strings.HasPrefix(x.Value, "~"):
+				toks = append(toks, token{rawToken, x.Value})
+			default:
+				toks = append(toks, token{litToken, x.Value})
+			}
+		case *syntax.SglQuoted:
+			lit(x)
+		case *syntax.DblQuoted:
+			if len(x.Parts) == 0 {
+				toks = append(toks, token{litToken, ""})
+			}
+			for _, qp := range x.Parts {
+				if l, ok := qp.(*syntax.Lit); ok {
+					lit(&syntax.DblQuoted{Parts: []syntax.WordPart{l}})
+				} else {
+					toks = append(toks, c.wordPart(qp, true)...)
+				}
+			}
+		default:
+			toks = append(toks, c.wordPart(part, false)...)
+		}
+	}
+	for _, t := range toks {
+		if t.kind == listToken && len(toks) > 1 {
+			c.errorf(w, "$@ is only supported as a whole word")
+		}
+	}
+	return toks
+}
+
+func (c *converter) wordPart(part syntax.WordPart, quoted bool) []token {
+	switch x := part.(type) {
+	case *syntax.ParamExp:
+		return []token{c.paramExp(x, quoted)}
+	case *syntax.CmdSubst:
+		return []token{{cmdToken, "(" + c.inline(x.Stmts) + ")"}}
+	case *syntax.ProcSubst:
+		if x.Op == syntax.CmdIn {
+			return []token{{cmdToken, "(" + c.inline(x.Stmts) + " | psub)"}}
+		}
+	case *syntax.ArithmExp:
+		if x.X == nil {
+			c.errorf(x, "empty arithmetic expansions aren't supported")
+			return nil
+		}
+		toks := c.arithm(x.X)
+		cmd := "(math "
+		if hasDivision(x.X) {
+			// like the shell, only keep the integer part
+			cmd += "-s0 "
+		}
+		return []token{{cmdToken, cmd + c.join(toks) + ")"}}
+	}
+	c.errorf(part, "%s isn't supported", partName(part))
+	return nil
+}
+
+func partName(part syntax.WordPart) string {
+	switch part.(type) {
+	case *syntax.ProcSubst:
+		return "an output process substitution"
+	case *syntax.ExtGlob:
+		return "an extended glob"
+	case *syntax.ArrayExpr:
+		return "an array outside of an assignment"
+	}
+	return "this expansion"
+}
+
+// inline returns the source of statements on a single line.
+func (c *converter) inline(stmts []*syntax.Stmt) string {
+	strs := make([]string, len(stmts))
+	for i, s := range stmts {
+		strs[i] = c.stmt(s, "")
+	}
+	return strings.Join(strs, "; ")
+}
+
+func (c *converter) paramExp(pe *syntax.ParamExp, quoted bool) token {
+	if pe.Ind != nil || pe.Slice != nil || pe.Repl != nil || pe.Exp != nil ||
+		pe.Param == nil {
+		c.errorf(pe, "this parameter expansion isn't supported")
+		return token{litToken, ""}
+	}
+	name := pe.Param.Value
+	if pe.Length {
+		if !validName(name) {
+			c.errorf(pe, "${#%s} isn't supported", name)
+		}
+		return token{cmdToken, `(string length -- "$` + name + `")`}
+	}
+	switch {
+	case name == "@" || name == "*" && !quoted:
+		return token{listToken, "$argv"}
+	case name == "*":
+		return token{varToken, "$argv"}
+	case name == "#":
+		return token{cmdToken, "(count $argv)"}
+	case name == "?":
+		return token{varToken, "$status"}
+	case name == "$":
+		return token{varToken, "$fish_pid"}
+	case name == "0":
+		return token{cmdToken, "(status filename)"}
+	case strings.Trim(name, "0123456789") == "":
+		return token{varToken, "$argv[" + name + "]"}
+	case validName(name):
+		return token{varToken, "$" + name}
+	}
+	c.errorf(pe, "$%s isn't supported", name)
+	return token{litToken, ""}
+}
+
+var arithmOps = map[syntax.BinAritOperator]string{
+	syntax.Add: "+",
+	syntax.Sub: "-",
+	syntax.Mul: "*",
+	syntax.Quo: "/",
+	syntax.Rem: "%",
+	syntax.Pow: "^",
+}
+
+// arithm returns the tokens of the math expression for an arithmetic
+// expression.
+func (c *converter) arithm(expr syntax.ArithmExpr) []token {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		if s, ok := syntax.StaticValue(x); ok {
+			switch {
+			case strings.Trim(s, "0123456789") == "" && s != "":
+				return []token{{litToken, s}}
+			case validName(s):
+				return []token{{varToken, "$" + s}}
+			}
+		}
+		if len(x.Parts) == 1 {
+			if pe, ok := x.Parts[0].(*syntax.ParamExp); ok {
+				return []token{c.paramExp(pe, true)}
+			}
+		}
+	case *syntax.ParenArithm:
+		toks := []token{{litToken, "("}}
+		toks = append(toks, c.arithm(x.X)...)
+		return append(toks, token{litToken, ")"})
+	case *syntax.UnaryArithm:
+		if x.Op == syntax.Minus && !x.Post {
+			return append([]token{{litToken, "-"}}, c.arithm(x.X)...)
+		}
+	case *syntax.BinaryArithm:
+		if op, ok := arithmOps[x.Op]; ok {
+			toks := c.arithm(x.X)
+			toks = append(toks, token{litToken, " " + op + " "})
+			return append(toks, c.arithm(x.Y)...)
+		}
+	}
+	c.errorf(expr, "this arithmetic expression isn't supported")
+	return nil
+}
+
+// hasDivision reports whether an arithmetic expression divides.
+func hasDivision(expr syntax.ArithmExpr) bool {
+	switch x := expr.(type) {
+	case *syntax.ParenArithm:
+		return hasDivision(x.X)
+	case *syntax.UnaryArithm:
+		return hasDivision(x.X)
+	case *syntax.BinaryArithm:
+		return x.Op == syntax.Quo || hasDivision(x.X) || hasDivision(x.Y)
+	}
+	return false
+}
+
+// unaryTests are the [[ ]] operators that fish's test supports, with
+// their names in it.
+var unaryTests = map[syntax.UnTestOperator]string{
+	syntax.TsExists:  "-e",
+	syntax.TsRegFile: "-f",
+	syntax.TsDirect:  "-d",
+	syntax.TsCharSp:  "-c",
+	syntax.TsBlckSp:  "-b",
+	syntax.TsNmPipe:  "-p",
+	syntax.TsSocket:  "-S",
+	syntax.TsSmbLink: "-L",
+	syntax.TsGIDSet:  "-g",
+	syntax.TsUIDSet:  "-u",
+	syntax.TsRead:    "-r",
+	syntax.TsWrite:   "-w",
+	syntax.TsExec:    "-x",
+	syntax.TsNoEmpty: "-s",
+	syntax.TsFdTerm:  "-t",
+	syntax.TsEmpStr:  "-z",
+	syntax.TsNempStr: "-n",
+}
+
+var binaryTests = map[syntax.BinTestOperator]string{
+	syntax.AndTest:  "-a",
+	syntax.OrTest:   "-o",
+	syntax.TsEqual:  "=",
+	syntax.TsAssgn:  "=",
+	syntax.TsNequal: "!=",
+	syntax.TsEql:    "-eq",
+	syntax.TsNeq:    "-ne",
+	syntax.TsLss:    "-lt",
+	syntax.TsLeq:    "-le",
+	syntax.TsGtr:    "-gt",
+	syntax.TsGeq:    "-ge",
+}
+
+// test returns the arguments of fish's test for a [[ ]] expression.
+func (c *converter) test(expr syntax.TestExpr) string {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return "-n " + c.operand(x)
+	case *syntax.ParenTest:
+		return `\( ` + c.test(x.X) + ` \)`
+	case *syntax.UnaryTest:
+		if x.Op == syntax.TsNot {
+			return "! " + c.test(x.X)
+		}
+		if op, ok := unaryTests[x.Op]; ok {
+			return op + " " + c.operand(x.X)
+		}
+		c.errorf(x, "%s isn't supported", x.Op)
+	case *syntax.BinaryTest:
+		op, ok := binaryTests[x.Op]
+		if !ok {
+			c.errorf(x, "%s isn't supported", x.Op)
+			return ""
+		}
+		if w, ok := x.Y.(*syntax.Word); ok && (op == "=" || op == "!=") {
+			for _, t := range c.tokens(w) {
+				if t.kind == rawToken {
+					c.errorf(w, "pattern matching isn't supported; use string match")
+				}
+			}
+		}
+		if x.Op == syntax.AndTest || x.Op == syntax.OrTest {
+			return c.test(x.X) + " " + op + " " + c.test(x.Y)
+		}
+		return c.operand(x.X) + " " + op + " " + c.operand(x.Y)
+	}
+	return ""
+}
+
+// operand returns the fish word for an operand of a [[ ]] operator,
+// where globs aren't expanded.
+func (c *converter) operand(expr syntax.TestExpr) string {
+	w, ok := expr.(*syntax.Word)
+	if !ok {
+		c.errorf(expr, "this test expression isn't supported")
+		return ""
+	}
+	return c.pattern(w)
+}
+
+func validName(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
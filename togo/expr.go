@@ -0,0 +1,386 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package togo
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/internal/convert"
+	"github.com/mvdan/sh/syntax"
+)
+
+// goExpr is a Go expression, along with the precedence of its
+// outermost operator.
+type goExpr struct {
+	s    string
+	prec int
+}
+
+// The precedences of the Go operators.
+const (
+	precOr = 1 + iota
+	precAnd
+	precCmp
+	precAdd
+	precMul
+	precOperand
+)
+
+// in returns the expression, with parentheses if it binds less tightly
+// than prec.
+func (x goExpr) in(prec int) string {
+	if x.prec < prec {
+		return "(" + x.s + ")"
+	}
+	return x.s
+}
+
+func binary(x goExpr, op string, prec int, y goExpr) goExpr {
+	// the right side needs parentheses at the same precedence, as
+	// in a - (b - c); for && and || it doesn't matter
+	return goExpr{x.in(prec) + " " + op + " " + y.in(prec+1), prec}
+}
+
+func not(x goExpr) goExpr {
+	return goExpr{"!" + x.in(precOperand), precOperand}
+}
+
+func call(name string, args ...goExpr) goExpr {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.s
+	}
+	return goExpr{name + "(" + strings.Join(strs, ", ") + ")", precOperand}
+}
+
+// words returns the Go arguments for a list of words.
+func (c *converter) words(ws []*syntax.Word) string {
+	strs := make([]string, len(ws))
+	for i, w := range ws {
+		strs[i] = c.word(w).s
+	}
+	return strings.Join(strs, ", ")
+}
+
+// word returns the Go string for a word, concatenating its parts.
+func (c *converter) word(w *syntax.Word) goExpr {
+	var strs []string
+	var lit []byte
+	flush := func() {
+		if lit != nil {
+			strs = append(strs, strconv.Quote(string(lit)))
+			lit = nil
+		}
+	}
+	var add func(parts []syntax.WordPart, quoted bool)
+	add = func(parts []syntax.WordPart, quoted bool) {
+		for i, part := range parts {
+			switch x := part.(type) {
+			case *syntax.Lit:
+				if !quoted && strings.ContainsAny(x.Value, "*?[") {
+					c.errorf(x, "globs aren't supported")
+				}
+				if !quoted && i == 0 && strings.HasPrefix(x.Value, "~") {
+					c.errorf(x, "tilde expansions aren't supported")
+				}
+				var lp syntax.WordPart = x
+				if quoted {
+					lp = &syntax.DblQuoted{Parts: []syntax.WordPart{x}}
+				}
+				s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{lp}})
+				lit = append(lit, s...)
+			case *syntax.SglQuoted:
+				s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+				lit = append(lit, s...)
+			case *syntax.DblQuoted:
+				if len(x.Parts) == 0 && lit == nil {
+					lit = []byte{}
+				}
+				add(x.Parts, true)
+			default:
+				flush()
+				strs = append(strs, c.wordPart(part).in(precAdd))
+			}
+		}
+	}
+	add(w.Parts, false)
+	flush()
+	if len(strs) == 0 {
+		return goExpr{`""`, precOperand}
+	}
+	if len(strs) == 1 {
+		// a single part has no + operator
+		return goExpr{strs[0], precOperand}
+	}
+	return goExpr{strings.Join(strs, " + "), precAdd}
+}
+
+func (c *converter) wordPart(part syntax.WordPart) goExpr {
+	switch x := part.(type) {
+	case *syntax.ParamExp:
+		return c.paramExp(x)
+	case *syntax.CmdSubst:
+		if len(x.Stmts) != 1 {
+			break
+		}
+		s := x.Stmts[0]
+		ce, ok := s.Cmd.(*syntax.CallExpr)
+		if !ok || len(s.Assigns) > 0 || len(s.Redirs) > 0 || s.Negated || s.Background {
+			break
+		}
+		return goExpr{c.use("output") + "(" + c.words(ce.Args) + ")", precOperand}
+	case *syntax.ArithmExp:
+		if x.X == nil {
+			c.errorf(x, "empty arithmetic expansions aren't supported")
+			break
+		}
+		return call(c.use("strconv")+".Itoa", c.arithm(x.X))
+	}
+	c.errorf(part, "%s isn't supported", partName(part))
+	return goExpr{`""`, precOperand}
+}
+
+func partName(part syntax.WordPart) string {
+	switch part.(type) {
+	case *syntax.CmdSubst:
+		return "a command substitution with more than one command"
+	case *syntax.ProcSubst:
+		return "a process substitution"
+	case *syntax.ExtGlob:
+		return "an extended glob"
+	}
+	return "this expansion"
+}
+
+func (c *converter) paramExp(pe *syntax.ParamExp) goExpr {
+	if pe.Ind != nil || pe.Slice != nil || pe.Repl != nil || pe.Param == nil {
+		c.errorf(pe, "this parameter expansion isn't supported")
+		return goExpr{`""`, precOperand}
+	}
+	x := c.param(pe.Param.Value)
+	switch name := pe.Param.Value; {
+	case name == "@" || name == "*":
+		c.errorf(pe, "$%s is only supported in for loops", name)
+	case name == "#" || name == "0" || validName(name):
+	case strings.Trim(name, "0123456789") == "":
+	default:
+		c.errorf(pe, "$%s isn't supported", name)
+	}
+	if pe.Length {
+		return call(c.use("strconv")+".Itoa", call("len", x))
+	}
+	if pe.Exp != nil {
+		if pe.Exp.Op != syntax.SubstColMinus {
+			c.errorf(pe, "${v%sx} isn't supported", pe.Exp.Op)
+		}
+		def := goExpr{`""`, precOperand}
+		if pe.Exp.Word != nil {
+			def = c.word(pe.Exp.Word)
+		}
+		return call(c.use("orDefault"), x, def)
+	}
+	return x
+}
+
+// param returns the Go string for the value of a parameter.
+func (c *converter) param(name string) goExpr {
+	switch {
+	case name == "#":
+		return call(c.use("strconv")+".Itoa", goExpr{"len(" + c.use("os") + ".Args) - 1", precAdd})
+	case name == "0":
+		return goExpr{c.use("os") + ".Args[0]", precOperand}
+	case !validName(name):
+		return call(c.use("arg"), goExpr{name, precOperand})
+	}
+	if goName, ok := c.goNames[name]; ok {
+		return goExpr{goName, precOperand}
+	}
+	return call(c.use("os")+".Getenv", goExpr{strconv.Quote(name), precOperand})
+}
+
+func validName(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// cond returns the Go condition for the statements of a clause.
+func (c *converter) cond(node syntax.Node, stmts []*syntax.Stmt) goExpr {
+	if len(stmts) != 1 {
+		c.errorf(node, "conditions with more than one command aren't supported")
+		return goExpr{"false", precOperand}
+	}
+	return c.condStmt(stmts[0])
+}
+
+// condStmt returns the Go condition that runs a statement and reports
+// whether it succeeded.
+func (c *converter) condStmt(s *syntax.Stmt) goExpr {
+	switch {
+	case s.Background:
+		c.errorf(s, "background commands aren't supported")
+	case len(s.Redirs) > 0:
+		c.errorf(s.Redirs[0], "redirections aren't supported")
+	case len(s.Assigns) > 0:
+		c.errorf(s, "assignments in conditions aren't supported")
+	}
+	var x goExpr
+	switch cmd := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		name, _ := syntax.StaticValue(cmd.Args[0])
+		switch name {
+		case "true", ":":
+			x = goExpr{"true", precOperand}
+		case "false":
+			x = goExpr{"false", precOperand}
+		case "[", "test":
+			c.errorf(cmd, "this test command can't be converted")
+		default:
+			x = c.run(cmd)
+		}
+	case *syntax.TestClause:
+		x = c.test(cmd.X)
+	case *syntax.BinaryCmd:
+		switch cmd = convert.LeftAssoc(cmd); cmd.Op {
+		case syntax.AndStmt:
+			x = binary(c.condStmt(cmd.X), "&&", precAnd, c.condStmt(cmd.Y))
+		case syntax.OrStmt:
+			x = binary(c.condStmt(cmd.X), "||", precOr, c.condStmt(cmd.Y))
+		default:
+			c.errorf(cmd, "pipes aren't supported")
+		}
+	case *syntax.Block:
+		x = c.cond(cmd, cmd.Stmts)
+	default:
+		c.errorf(s, "%s isn't supported as a condition", convert.CmdName(cmd))
+	}
+	if s.Negated {
+		x = not(x)
+	}
+	return x
+}
+
+var fileTests = map[syntax.UnTestOperator]string{
+	syntax.TsExists:  "exists",
+	syntax.TsRegFile: "isFile",
+	syntax.TsDirect:  "isDir",
+}
+
+var cmpTests = map[syntax.BinTestOperator]string{
+	syntax.TsEqual:  "==",
+	syntax.TsAssgn:  "==",
+	syntax.TsNequal: "!=",
+	syntax.TsBefore: "<",
+	syntax.TsAfter:  ">",
+	syntax.TsEql:    "==",
+	syntax.TsNeq:    "!=",
+	syntax.TsLss:    "<",
+	syntax.TsLeq:    "<=",
+	syntax.TsGtr:    ">",
+	syntax.TsGeq:    ">=",
+}
+
+// test returns the Go condition for a [[ ]] expression.
+func (c *converter) test(expr syntax.TestExpr) goExpr {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return binary(c.word(x), "!=", precCmp, goExpr{`""`, precOperand})
+	case *syntax.ParenTest:
+		return c.test(x.X)
+	case *syntax.UnaryTest:
+		if x.Op == syntax.TsNot {
+			return not(c.test(x.X))
+		}
+		w, ok := x.X.(*syntax.Word)
+		if !ok {
+			break
+		}
+		switch x.Op {
+		case syntax.TsEmpStr:
+			return binary(c.word(w), "==", precCmp, goExpr{`""`, precOperand})
+		case syntax.TsNempStr:
+			return binary(c.word(w), "!=", precCmp, goExpr{`""`, precOperand})
+		}
+		if name, ok := fileTests[x.Op]; ok {
+			return call(c.use(name), c.word(w))
+		}
+		c.errorf(x, "%s isn't supported", x.Op)
+		return goExpr{"false", precOperand}
+	case *syntax.BinaryTest:
+		switch x.Op {
+		case syntax.AndTest:
+			return binary(c.test(x.X), "&&", precAnd, c.test(x.Y))
+		case syntax.OrTest:
+			return binary(c.test(x.X), "||", precOr, c.test(x.Y))
+		}
+		l, ok1 := x.X.(*syntax.Word)
+		r, ok2 := x.Y.(*syntax.Word)
+		op, ok3 := cmpTests[x.Op]
+		if !ok1 || !ok2 {
+			break
+		}
+		if !ok3 {
+			c.errorf(x, "%s isn't supported", x.Op)
+			return goExpr{"false", precOperand}
+		}
+		if x.Op >= syntax.TsEql && x.Op <= syntax.TsGtr {
+			return binary(c.arithm(l), op, precCmp, c.arithm(r))
+		}
+		return binary(c.word(l), op, precCmp, c.word(r))
+	}
+	c.errorf(expr, "this test expression isn't supported")
+	return goExpr{"false", precOperand}
+}
+
+var arithmOps = map[syntax.BinAritOperator]struct {
+	op   string
+	prec int
+}{
+	syntax.Add: {"+", precAdd},
+	syntax.Sub: {"-", precAdd},
+	syntax.Mul: {"*", precMul},
+	syntax.Quo: {"/", precMul},
+	syntax.Rem: {"%", precMul},
+}
+
+// arithm returns the Go integer for an arithmetic expression.
+func (c *converter) arithm(expr syntax.ArithmExpr) goExpr {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		if s, ok := syntax.StaticValue(x); ok {
+			if _, err := strconv.Atoi(s); err == nil {
+				return goExpr{s, precOperand}
+			}
+			if validName(s) {
+				return call(c.use("atoi"), c.param(s))
+			}
+		}
+		if len(x.Parts) == 1 {
+			if pe, ok := x.Parts[0].(*syntax.ParamExp); ok {
+				return call(c.use("atoi"), c.paramExp(pe))
+			}
+		}
+	case *syntax.ParenArithm:
+		return c.arithm(x.X)
+	case *syntax.UnaryArithm:
+		if x.Op == syntax.Minus && !x.Post {
+			return goExpr{"-" + c.arithm(x.X).in(precOperand), precOperand}
+		}
+	case *syntax.BinaryArithm:
+		if op, ok := arithmOps[x.Op]; ok {
+			return binary(c.arithm(x.X), op.op, op.prec, c.arithm(x.Y))
+		}
+	}
+	c.errorf(expr, "this arithmetic expression isn't supported")
+	return goExpr{"0", precOperand}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package togo
+
+// helper is a function that converted programs may need.
+type helper struct {
+	imports []string
+	src     string
+}
+
+var helpers = map[string]*helper{
+	"run": {[]string{"fmt", "os", "os/exec"}, `// run runs a command, and reports whether it succeeded.
+func run(name string, args ...string) bool {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return false
+	}
+	return true
+}
+`},
+	"output": {[]string{"fmt", "os", "os/exec", "strings"}, `// output runs a command and returns its output without the trailing
+// newlines, like $(cmd).
+func output(name string, args ...string) string {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+`},
+	"arg": {[]string{"os"}, `// arg returns an argument of the program, like $1.
+func arg(n int) string {
+	if n < len(os.Args) {
+		return os.Args[n]
+	}
+	return ""
+}
+`},
+	"atoi": {[]string{"strconv", "strings"}, `// atoi returns the integer in a string, or 0 if there isn't one.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+`},
+	"orDefault": {nil, `// orDefault returns s, or def if s is empty, like ${s:-def}.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+`},
+	"exists": {[]string{"os"}, `// exists reports whether a file exists, like [[ -e path ]].
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+`},
+	"isFile": {[]string{"os"}, `// isFile reports whether a file is a regular file, like [[ -f path ]].
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+`},
+	"isDir": {[]string{"os"}, `// isDir reports whether a file is a directory, like [[ -d path ]].
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+`},
+}
@@ -0,0 +1,446 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package togo converts a subset of shell programs to Go source code.
+//
+// It's experimental, and meant to help move small but important scripts
+// off shell one at a time. The result is a main package that is meant
+// to be read and edited, not kept in sync with the original program.
+package togo
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/internal/convert"
+	"github.com/mvdan/sh/refactor"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Error is a construct that couldn't be converted.
+type Error convert.Error
+
+func (e *Error) Error() string { return (*convert.Error)(e).Error() }
+
+// Convert returns the source of a Go main package that does what a
+// shell program does, or an error with the first construct that it
+// doesn't support.
+//
+// These are supported: assignments and exports, words with quotes,
+// parameter expansions like $v, ${#v} or ${v:-x}, $(( )) with integers,
+// command substitutions, if, while, until, for and case clauses, &&
+// and ||, tests with [ ] or [[ ]], and running commands. Variables
+// become Go strings, unless they are exported, and commands are run
+// via os/exec. echo, cd, exit, true and false are converted to Go too.
+//
+// Pipes, redirections, functions, subshells, globs and arrays aren't
+// supported. Unquoted expansions are never split into fields, as if
+// they were quoted. The [ ] commands of the file are turned into [[ ]]
+// in place.
+func Convert(f *syntax.File) ([]byte, error) {
+	refactor.Modernize(f, refactor.ModernTests, "")
+	c := &converter{
+		f:       f,
+		goNames: make(map[string]string),
+		used:    make(map[string]bool),
+	}
+	c.declare()
+	c.stmts(f.Stmts)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.finish()
+}
+
+type converter struct {
+	f   *syntax.File
+	buf bytes.Buffer
+	err error
+
+	// goNames holds the Go names of the shell variables that are Go
+	// variables, in the order of vars; the other ones are environment
+	// variables
+	goNames map[string]string
+	vars    []string
+
+	// unread are the variables that are assigned but never read
+	unread map[string]bool
+
+	// used are the packages and helpers used by the program
+	used map[string]bool
+}
+
+func (c *converter) errorf(node syntax.Node, format string, a ...interface{}) {
+	if c.err == nil {
+		err := Error(convert.Errorf(c.f, node, format, a...))
+		c.err = &err
+	}
+}
+
+func (c *converter) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&c.buf, format, a...)
+}
+
+// use records that the program uses a package or a helper, returning
+// its name.
+func (c *converter) use(name string) string {
+	c.used[name] = true
+	return name
+}
+
+// declare finds the variables of the program and decides which ones
+// are Go variables.
+func (c *converter) declare() {
+	exported := make(map[string]bool)
+	assigned := make(map[string]bool)
+	read := make(map[string]bool)
+	var order []string
+	see := func(name string) {
+		if !assigned[name] && !read[name] {
+			order = append(order, name)
+		}
+	}
+	syntax.Walk(varVisitor(func(node syntax.Node) {
+		switch x := node.(type) {
+		case *syntax.DeclClause:
+			if x.Variant == "export" {
+				for _, as := range x.Assigns {
+					if as.Name != nil {
+						exported[as.Name.Value] = true
+					}
+				}
+			}
+		case *syntax.Assign:
+			if x.Name != nil {
+				see(x.Name.Value)
+				assigned[x.Name.Value] = true
+			}
+		case *syntax.WordIter:
+			see(x.Name.Value)
+			assigned[x.Name.Value] = true
+		case *syntax.ParamExp:
+			if x.Param != nil {
+				see(x.Param.Value)
+				read[x.Param.Value] = true
+			}
+		}
+	}), c.f)
+	c.unread = make(map[string]bool)
+	for _, name := range order {
+		switch {
+		case exported[name] || !assigned[name]:
+		case !read[name]:
+			c.unread[name] = true
+		default:
+			c.goNames[name] = goName(name)
+			c.vars = append(c.vars, name)
+		}
+	}
+}
+
+type varVisitor func(syntax.Node)
+
+func (v varVisitor) Visit(node syntax.Node) syntax.Visitor {
+	v(node)
+	return v
+}
+
+// reserved are the predeclared Go names, and the ones used by the
+// converted program, which can't be used for variables.
+var reserved = map[string]bool{
+	"bool": true, "byte": true, "error": true, "int": true, "rune": true,
+	"string": true, "true": true, "false": true, "nil": true, "iota": true,
+	"append": true, "cap": true, "close": true, "copy": true, "delete": true,
+	"len": true, "make": true, "new": true, "panic": true, "print": true,
+	"println": true, "recover": true,
+
+	"main": true, "err": true, "fmt": true, "os": true, "exec": true,
+	"strconv": true, "strings": true,
+}
+
+func goName(name string) string {
+	if token.Lookup(name).IsKeyword() || reserved[name] || helpers[name] != nil {
+		return name + "_"
+	}
+	return name
+}
+
+func (c *converter) finish() ([]byte, error) {
+	var buf bytes.Buffer
+	if c.f.Name != "" {
+		fmt.Fprintf(&buf, "// This program was converted from %s.\n", c.f.Name)
+	}
+	buf.WriteString("package main\n\n")
+	var names []string
+	for name := range c.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	imports := make(map[string]bool)
+	for _, name := range names {
+		if h := helpers[name]; h != nil {
+			for _, path := range h.imports {
+				imports[path] = true
+			}
+		} else {
+			imports[packages[name]] = true
+		}
+	}
+	var paths []string
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		buf.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&buf, "%q\n", path)
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.WriteString("func main() {\n")
+	if len(c.vars) > 0 {
+		goNames := make([]string, len(c.vars))
+		for i, name := range c.vars {
+			goNames[i] = c.goNames[name]
+		}
+		fmt.Fprintf(&buf, "var %s string\n", strings.Join(goNames, ", "))
+	}
+	buf.Write(c.buf.Bytes())
+	buf.WriteString("}\n")
+	for _, name := range names {
+		if h := helpers[name]; h != nil {
+			buf.WriteString("\n" + h.src)
+		}
+	}
+	return format.Source(buf.Bytes())
+}
+
+var packages = map[string]string{
+	"fmt":     "fmt",
+	"os":      "os",
+	"strconv": "strconv",
+}
+
+func (c *converter) stmts(stmts []*syntax.Stmt) {
+	for _, s := range stmts {
+		c.stmt(s)
+	}
+}
+
+func (c *converter) stmt(s *syntax.Stmt) {
+	switch {
+	case s.Background:
+		c.errorf(s, "background commands aren't supported")
+		return
+	case len(s.Redirs) > 0:
+		c.errorf(s.Redirs[0], "redirections aren't supported")
+		return
+	case s.Cmd == nil:
+		for _, as := range s.Assigns {
+			c.assign(as)
+		}
+		return
+	case len(s.Assigns) > 0:
+		c.errorf(s, "assignments before commands aren't supported")
+		return
+	}
+	switch x := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		c.call(x)
+	case *syntax.BinaryCmd:
+		switch x = convert.LeftAssoc(x); x.Op {
+		case syntax.AndStmt, syntax.OrStmt:
+			cond := c.condStmt(x.X)
+			if x.Op == syntax.OrStmt {
+				cond = not(cond)
+			}
+			c.printf("if %s {\n", cond.s)
+			c.stmt(x.Y)
+			c.printf("}\n")
+		default:
+			c.errorf(x, "pipes aren't supported")
+		}
+	case *syntax.Block:
+		c.stmts(x.Stmts)
+	case *syntax.IfClause:
+		c.printf("if %s {\n", c.cond(x, x.CondStmts).s)
+		c.stmts(x.ThenStmts)
+		for _, elif := range x.Elifs {
+			c.printf("} else if %s {\n", c.cond(x, elif.CondStmts).s)
+			c.stmts(elif.ThenStmts)
+		}
+		if len(x.ElseStmts) > 0 {
+			c.printf("} else {\n")
+			c.stmts(x.ElseStmts)
+		}
+		c.printf("}\n")
+	case *syntax.WhileClause:
+		c.printf("for %s {\n", c.cond(x, x.CondStmts).s)
+		c.stmts(x.DoStmts)
+		c.printf("}\n")
+	case *syntax.UntilClause:
+		c.printf("for %s {\n", not(c.cond(x, x.CondStmts)).s)
+		c.stmts(x.DoStmts)
+		c.printf("}\n")
+	case *syntax.ForClause:
+		c.forClause(x)
+	case *syntax.CaseClause:
+		c.caseClause(x)
+	case *syntax.DeclClause:
+		c.decl(x)
+	case *syntax.TestClause:
+		c.errorf(x, "tests are only supported as conditions")
+	default:
+		c.errorf(x, "%s isn't supported", convert.CmdName(x))
+	}
+}
+
+func (c *converter) assign(as *syntax.Assign) {
+	if as.Name == nil {
+		c.errorf(as, "arrays aren't supported")
+		return
+	}
+	name := as.Name.Value
+	value := goExpr{`""`, precOperand}
+	if as.Value != nil {
+		value = c.word(as.Value)
+	}
+	switch goName, ok := c.goNames[name]; {
+	case ok && as.Append:
+		c.printf("%s += %s\n", goName, value.s)
+	case ok:
+		c.printf("%s = %s\n", goName, value.s)
+	case c.unread[name]:
+		if as.Value != nil {
+			if _, static := syntax.StaticValue(as.Value); !static {
+				c.printf("_ = %s\n", value.s)
+			}
+		}
+	default:
+		if as.Append {
+			value = binary(c.param(name), "+", precAdd, value)
+		}
+		c.printf("%s.Setenv(%q, %s)\n", c.use("os"), name, value.s)
+	}
+}
+
+func (c *converter) decl(dc *syntax.DeclClause) {
+	if dc.Variant != "export" || len(dc.Opts) > 0 {
+		c.errorf(dc, "%s isn't supported", dc.Variant)
+		return
+	}
+	for _, as := range dc.Assigns {
+		if as.Value != nil || as.Append {
+			c.assign(as)
+		}
+		// exporting a variable without a value changes nothing, as
+		// all exported variables are environment variables
+	}
+}
+
+// call converts a command that is run as a statement.
+func (c *converter) call(ce *syntax.CallExpr) {
+	name, _ := syntax.StaticValue(ce.Args[0])
+	args := ce.Args[1:]
+	switch name {
+	case "true", "false", ":":
+		return
+	case "echo":
+		if len(args) > 0 {
+			if s, ok := syntax.StaticValue(args[0]); ok && strings.HasPrefix(s, "-") {
+				break // options
+			}
+		}
+		c.printf("%s.Println(%s)\n", c.use("fmt"), c.words(args))
+		return
+	case "cd":
+		if len(args) != 1 {
+			break
+		}
+		c.printf("if err := %s.Chdir(%s); err != nil {\n", c.use("os"), c.word(args[0]).s)
+		c.printf("%s.Fprintln(os.Stderr, err)\n}\n", c.use("fmt"))
+		return
+	case "exit":
+		s := "0"
+		if len(args) > 0 {
+			s, _ = syntax.StaticValue(args[0])
+		}
+		if _, err := strconv.Atoi(s); err != nil || len(args) != 1 {
+			c.errorf(ce, "exit is only supported with a number")
+			return
+		}
+		c.printf("%s.Exit(%s)\n", c.use("os"), s)
+		return
+	case "break", "continue":
+		if len(args) > 0 {
+			c.errorf(ce, "%s is only supported without a number", name)
+			return
+		}
+		c.printf("%s\n", name)
+		return
+	case "[", "test":
+		c.errorf(ce, "this test command can't be converted")
+		return
+	}
+	c.printf("%s\n", c.run(ce).s)
+}
+
+// run converts a command that is run via os/exec.
+func (c *converter) run(ce *syntax.CallExpr) goExpr {
+	return goExpr{c.use("run") + "(" + c.words(ce.Args) + ")", precOperand}
+}
+
+func (c *converter) forClause(fc *syntax.ForClause) {
+	wi, ok := fc.Loop.(*syntax.WordIter)
+	if !ok {
+		c.errorf(fc, "C-style for loops aren't supported")
+		return
+	}
+	list := c.use("os") + ".Args[1:]"
+	if len(wi.List) > 0 && !convert.AllArgs(wi.List) {
+		list = "[]string{" + c.words(wi.List) + "}"
+	}
+	switch goName, ok := c.goNames[wi.Name.Value]; {
+	case ok:
+		c.printf("for _, %s = range %s {\n", goName, list)
+	case c.unread[wi.Name.Value]:
+		c.printf("for range %s {\n", list)
+	default:
+		c.errorf(wi, "for loops over exported variables aren't supported")
+		return
+	}
+	c.stmts(fc.DoStmts)
+	c.printf("}\n")
+}
+
+func (c *converter) caseClause(cc *syntax.CaseClause) {
+	c.printf("switch %s {\n", c.word(cc.Word).s)
+	for _, pl := range cc.List {
+		if pl.Op != syntax.DblSemicolon {
+			c.errorf(cc, "case fallthroughs aren't supported")
+			return
+		}
+		var values []string
+		for _, w := range pl.Patterns {
+			s, ok := syntax.StaticValue(w)
+			if ok && s == "*" && len(pl.Patterns) == 1 {
+				values = nil
+				break
+			}
+			values = append(values, c.word(w).s)
+		}
+		if values == nil {
+			c.printf("default:\n")
+		} else {
+			c.printf("case %s:\n", strings.Join(values, ", "))
+		}
+		c.stmts(pl.Stmts)
+	}
+	c.printf("}\n")
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package togo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// mainBody returns the statements of the main func of a program,
+// without indentation.
+func mainBody(src string) string {
+	start := strings.Index(src, "func main() {\n") + len("func main() {\n")
+	end := start + strings.Index(src[start:], "\n}\n")
+	lines := strings.Split(src[start:end], "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "\t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"echo foo 'bar baz' \"a\\$b\"", `fmt.Println("foo", "bar baz", "a$b")`},
+		{"a=1; a+=\"x$a\"; echo $a", "var a string\na = \"1\"\na += \"x\" + a\nfmt.Println(a)"},
+		{"unused=1; export E=$HOME/x", `os.Setenv("E", os.Getenv("HOME")+"/x")`},
+		{"echo ${1:-def} $# ${#HOME}", `fmt.Println(orDefault(arg(1), "def"), strconv.Itoa(len(os.Args)-1), strconv.Itoa(len(os.Getenv("HOME"))))`},
+		{"ls -l /tmp", `run("ls", "-l", "/tmp")`},
+		{"d=$(date); echo $d $((1 + 2 * (3 - $n)))", "var d string\nd = output(\"date\")\nfmt.Println(d, strconv.Itoa(1+2*(3-atoi(os.Getenv(\"n\")))))"},
+		{"unused=$(date)", `_ = output("date")`},
+		{"true && cd /x || exit 3; a || b && c", "if !(true && run(\"cd\", \"/x\")) {\n\tos.Exit(3)\n}\nif run(\"a\") || run(\"b\") {\n\trun(\"c\")\n}"},
+		{"[ -f a ] && echo", "if isFile(\"a\") {\n\tfmt.Println()\n}"},
+		{"cmd || exit 3", "if !run(\"cmd\") {\n\tos.Exit(3)\n}"},
+		{
+			"if [ -z \"$a\" -o \"$a\" = x ]; then :; elif ! [[ -d $a && $a > b ]]; then :; else break; fi",
+			"if os.Getenv(\"a\") == \"\" || os.Getenv(\"a\") == \"x\" {\n} else if !(isDir(os.Getenv(\"a\")) && os.Getenv(\"a\") > \"b\") {\n} else {\n\tbreak\n}",
+		},
+		{"while [ $n -lt 3 ]; do continue; done", "for atoi(os.Getenv(\"n\")) < 3 {\n\tcontinue\n}"},
+		{"until false; do :; done", "for !false {\n}"},
+		{"for i in a \"$b\"; do echo $i; done", "var i string\nfor _, i = range []string{\"a\", os.Getenv(\"b\")} {\n\tfmt.Println(i)\n}"},
+		{"for i; do :; done", "for range os.Args[1:] {\n}"},
+		{"case $1 in a | b) ;; *) exit 1 ;; esac", "switch arg(1) {\ncase \"a\", \"b\":\ndefault:\n\tos.Exit(1)\n}"},
+		{"type=x; echo $type", "var type_ string\ntype_ = \"x\"\nfmt.Println(type_)"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := Convert(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mainBody(string(src)); got != tc.want {
+				t.Fatalf("Convert mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"a | b", "1:1: pipes aren't supported"},
+		{"echo >f", "1:6: redirections aren't supported"},
+		{"f() { :; }", "1:1: a function isn't supported"},
+		{"echo *.sh", "1:6: globs aren't supported"},
+		{"echo \"$@\"", "1:7: $@ is only supported in for loops"},
+		{"echo ${a/b/c}", "1:6: this parameter expansion isn't supported"},
+		{"echo ${#:-a}", "1:6: this parameter expansion isn't supported"},
+		{"echo $(())", "1:6: empty arithmetic expansions aren't supported"},
+		{"if a; b; then :; fi", "1:1: conditions with more than one command aren't supported"},
+		{"[[ -n a ]]", "1:1: tests are only supported as conditions"},
+		{"exit", "1:1: exit is only supported with a number"},
+		{"for ((;;)); do :; done", "1:1: C-style for loops aren't supported"},
+		{"A=1 cmd", "1:1: assignments before commands aren't supported"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Convert(f)
+			if err == nil {
+				t.Fatalf("Convert in %q did not error", tc.in)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("error mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package topwsh converts a subset of shell programs to PowerShell.
+//
+// It's experimental, and meant for the simple steps of CI pipelines
+// that also need to run natively on Windows. The result targets
+// PowerShell 7 or later, as it uses its && and || operators.
+package topwsh
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/mvdan/sh/internal/convert"
+	"github.com/mvdan/sh/refactor"
+	"github.com/mvdan/sh/syntax"
+)
+
+// Error is a construct that couldn't be converted.
+type Error convert.Error
+
+func (e *Error) Error() string { return (*convert.Error)(e).Error() }
+
+const indent = "    "
+
+// Convert returns the source of a PowerShell script that does what a
+// shell program does, or an error with the first construct that it
+// doesn't support.
+//
+// These are supported: commands with their arguments quoted for
+// PowerShell, pipes, && and ||, output redirections, assignments and
+// exports, parameter expansions like $v, $1 or ${#v}, command
+// substitutions, if, while, until and for clauses, and tests with [ ]
+// or [[ ]] as their conditions. Variables become PowerShell variables,
+// unless they are exported or never assigned, in which case they are
+// environment variables like $env:HOME. echo, cd, exit, true and break
+// are converted to PowerShell too, and the rest of commands are run
+// as they are, where PowerShell may run one of its aliases instead.
+//
+// Functions, subshells, background commands, case clauses, globs,
+// arrays, arithmetic and input redirections aren't supported, and
+// conditions can only run a command in an if clause without elif.
+// Unquoted expansions are never split into fields, as if they were
+// quoted. The [ ] commands of the file are turned into [[ ]] in place.
+func Convert(f *syntax.File) ([]byte, error) {
+	refactor.Modernize(f, refactor.ModernTests, "")
+	c := &converter{f: f, psNames: make(map[string]string)}
+	if f.Name != "" {
+		c.printf("# This program was converted from %s.\n", f.Name)
+	}
+	c.declare()
+	c.stmts(f.Stmts)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.buf.Bytes(), nil
+}
+
+type converter struct {
+	f   *syntax.File
+	buf bytes.Buffer
+	err error
+
+	// ind is the indentation of the current lines
+	ind string
+
+	// psNames holds the PowerShell names of the shell variables that
+	// are PowerShell variables; the other ones are environment
+	// variables
+	psNames map[string]string
+}
+
+func (c *converter) errorf(node syntax.Node, format string, a ...interface{}) {
+	if c.err == nil {
+		err := Error(convert.Errorf(c.f, node, format, a...))
+		c.err = &err
+	}
+}
+
+func (c *converter) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&c.buf, format, a...)
+}
+
+// line prints a line at the current indentation.
+func (c *converter) line(s string) {
+	c.printf("%s%s\n", c.ind, s)
+}
+
+// block prints the statements of a block, followed by its closing
+// brace.
+func (c *converter) block(stmts []*syntax.Stmt) {
+	ind := c.ind
+	c.ind += indent
+	c.stmts(stmts)
+	c.ind = ind
+	c.line("}")
+}
+
+// declare finds the variables of the program and decides which ones
+// are PowerShell variables.
+func (c *converter) declare() {
+	exported := make(map[string]bool)
+	var assigned []string
+	syntax.Walk(varVisitor(func(node syntax.Node) {
+		switch x := node.(type) {
+		case *syntax.DeclClause:
+			if x.Variant == "export" {
+				for _, as := range x.Assigns {
+					if as.Name != nil {
+						exported[as.Name.Value] = true
+					} else if name, ok := syntax.StaticValue(as.Value); ok {
+						exported[name] = true
+					}
+				}
+			}
+		case *syntax.Assign:
+			if x.Name != nil {
+				assigned = append(assigned, x.Name.Value)
+			}
+		case *syntax.WordIter:
+			assigned = append(assigned, x.Name.Value)
+		}
+	}), c.f)
+	// PowerShell names are case insensitive
+	taken := make(map[string]bool)
+	for _, name := range assigned {
+		if _, ok := c.psNames[name]; ok || exported[name] {
+			continue
+		}
+		psName := name
+		for automatic[strings.ToLower(psName)] || taken[strings.ToLower(psName)] {
+			psName += "_"
+		}
+		taken[strings.ToLower(psName)] = true
+		c.psNames[name] = psName
+	}
+}
+
+type varVisitor func(syntax.Node)
+
+func (v varVisitor) Visit(node syntax.Node) syntax.Visitor {
+	v(node)
+	return v
+}
+
+// automatic are the lowercase names of the automatic variables of
+// PowerShell, which can't be used for shell variables.
+var automatic = map[string]bool{
+	"_": true, "args": true, "input": true, "this": true, "true": true,
+	"false": true, "null": true, "home": true, "host": true, "pid": true,
+	"pwd": true, "error": true, "profile": true, "psitem": true,
+	"matches": true, "lastexitcode": true, "myinvocation": true,
+	"executioncontext": true, "foreach": true, "switch": true,
+	"event": true, "sender": true, "ofs": true, "shellid": true,
+	"stacktrace": true, "pshome": true, "psscriptroot": true,
+	"pscommandpath": true, "psversiontable": true, "iswindows": true,
+	"islinux": true, "ismacos": true, "env": true,
+}
+
+func (c *converter) stmts(stmts []*syntax.Stmt) {
+	for _, s := range stmts {
+		c.stmt(s)
+	}
+}
+
+func (c *converter) stmt(s *syntax.Stmt) {
+	switch {
+	case s.Background:
+		c.errorf(s, "background commands aren't supported")
+		return
+	case s.Negated:
+		c.errorf(s, "negated commands are only supported as conditions")
+		return
+	case s.Cmd == nil:
+		for _, as := range s.Assigns {
+			c.assign(as)
+		}
+		return
+	}
+	switch x := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		if name, _ := syntax.StaticValue(x.Args[0]); (name == "true" || name == ":") &&
+			len(s.Assigns) == 0 && len(s.Redirs) == 0 {
+			return
+		}
+		c.line(c.pipeline(s))
+		return
+	case *syntax.BinaryCmd:
+		if x = convert.LeftAssoc(x); convert.IsList(x) && isTest(x.X) && len(s.Redirs) == 0 {
+			cond := c.condExpr(x, []*syntax.Stmt{x.X})
+			if x.Op == syntax.OrStmt {
+				cond = not(cond)
+			}
+			c.line("if (" + cond.s + ") {")
+			c.block([]*syntax.Stmt{x.Y})
+			return
+		}
+		c.line(c.pipeline(s))
+		return
+	}
+	if len(s.Redirs) > 0 {
+		c.errorf(s.Redirs[0], "redirections are only supported on commands")
+		return
+	}
+	switch x := s.Cmd.(type) {
+	case *syntax.Block:
+		c.stmts(x.Stmts)
+	case *syntax.IfClause:
+		c.line("if (" + c.cond(x, x.CondStmts, true) + ") {")
+		c.ind += indent
+		c.stmts(x.ThenStmts)
+		c.ind = c.ind[len(indent):]
+		for _, elif := range x.Elifs {
+			c.line("} elseif (" + c.cond(x, elif.CondStmts, false) + ") {")
+			c.ind += indent
+			c.stmts(elif.ThenStmts)
+			c.ind = c.ind[len(indent):]
+		}
+		if len(x.ElseStmts) > 0 {
+			c.line("} else {")
+			c.ind += indent
+			c.stmts(x.ElseStmts)
+			c.ind = c.ind[len(indent):]
+		}
+		c.line("}")
+	case *syntax.WhileClause:
+		c.line("while (" + c.cond(x, x.CondStmts, false) + ") {")
+		c.block(x.DoStmts)
+	case *syntax.UntilClause:
+		cond := c.condExpr(x, x.CondStmts)
+		c.line("while (" + not(cond).s + ") {")
+		c.block(x.DoStmts)
+	case *syntax.ForClause:
+		c.forClause(x)
+	case *syntax.DeclClause:
+		c.decl(x)
+	case *syntax.TestClause:
+		c.errorf(x, "tests are only supported as conditions")
+	default:
+		c.errorf(x, "%s isn't supported", convert.CmdName(x))
+	}
+}
+
+// pipeline returns the PowerShell source of a command or of a pipe or
+// list of them, which fits in a single line.
+func (c *converter) pipeline(s *syntax.Stmt) string {
+	switch {
+	case s.Background:
+		c.errorf(s, "background commands aren't supported")
+	case s.Negated:
+		c.errorf(s, "negated commands are only supported as conditions")
+	case s.Cmd == nil:
+		c.errorf(s, "assignments in pipes or lists aren't supported")
+		return ""
+	case len(s.Assigns) > 0:
+		c.errorf(s, "assignments before commands aren't supported")
+	}
+	var src string
+	switch x := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		src = c.call(x)
+	case *syntax.BinaryCmd:
+		var op string
+		switch x.Op {
+		case syntax.AndStmt, syntax.OrStmt, syntax.Pipe:
+			op = x.Op.String()
+		default:
+			c.errorf(x, "%s isn't supported", x.Op)
+		}
+		src = c.pipeline(x.X) + " " + op + " " + c.pipeline(x.Y)
+	case *syntax.TestClause:
+		c.errorf(x, "tests are only supported as conditions")
+	default:
+		c.errorf(s, "%s isn't supported in pipes or lists", convert.CmdName(x))
+	}
+	for _, r := range s.Redirs {
+		src += " " + c.redirect(r)
+	}
+	return src
+}
+
+// cond returns the PowerShell condition for the statements of a
+// clause. If run is true, a condition that runs a command is also
+// supported, by running it before the clause and checking $?.
+func (c *converter) cond(node syntax.Node, stmts []*syntax.Stmt, run bool) string {
+	if run && len(stmts) == 1 && !isTest(stmts[0]) {
+		s := stmts[0]
+		negated := s.Negated
+		s.Negated = false
+		c.line(c.pipeline(s))
+		s.Negated = negated
+		if negated {
+			return "-not $?"
+		}
+		return "$?"
+	}
+	return c.condExpr(node, stmts).s
+}
+
+// condExpr returns the PowerShell expression for the test statements
+// of a clause.
+func (c *converter) condExpr(node syntax.Node, stmts []*syntax.Stmt) psExpr {
+	if len(stmts) != 1 {
+		c.errorf(node, "conditions with more than one command aren't supported")
+		return psExpr{s: "$false", prec: precOperand}
+	}
+	s := stmts[0]
+	if !isTest(s) {
+		c.errorf(s, "commands are only supported as conditions of if clauses without elif")
+		return psExpr{s: "$false", prec: precOperand}
+	}
+	var x psExpr
+	switch cmd := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		name, _ := syntax.StaticValue(cmd.Args[0])
+		x = psExpr{s: "$" + name, prec: precOperand}
+		if name == ":" {
+			x.s = "$true"
+		}
+	case *syntax.TestClause:
+		x = c.test(cmd.X)
+	case *syntax.BinaryCmd:
+		op := "-and"
+		if cmd.Op == syntax.OrStmt {
+			op = "-or"
+		}
+		l := c.condExpr(cmd.X, []*syntax.Stmt{cmd.X})
+		r := c.condExpr(cmd.Y, []*syntax.Stmt{cmd.Y})
+		x = logical(l, op, r)
+	}
+	if s.Negated {
+		x = not(x)
+	}
+	return x
+}
+
+// isTest reports whether a statement is made of tests and true or
+// false, which can be PowerShell expressions.
+func isTest(s *syntax.Stmt) bool {
+	if s.Background || len(s.Assigns) > 0 || len(s.Redirs) > 0 {
+		return false
+	}
+	switch x := s.Cmd.(type) {
+	case *syntax.CallExpr:
+		if len(x.Args) != 1 {
+			return false
+		}
+		name, _ := syntax.StaticValue(x.Args[0])
+		return name == "true" || name == "false" || name == ":"
+	case *syntax.TestClause:
+		return true
+	case *syntax.BinaryCmd:
+		return (x.Op == syntax.AndStmt || x.Op == syntax.OrStmt) &&
+			isTest(x.X) && isTest(x.Y)
+	}
+	return false
+}
+
+func (c *converter) assign(as *syntax.Assign) {
+	if as.Name == nil || isArray(as.Value) {
+		c.errorf(as, "arrays aren't supported")
+		return
+	}
+	value := "''"
+	if as.Value != nil {
+		value = c.value(as.Value)
+	}
+	op := "="
+	if as.Append {
+		op = "+="
+	}
+	c.line(c.variable(as.Name.Value) + " " + op + " " + value)
+}
+
+func isArray(w *syntax.Word) bool {
+	if w == nil || len(w.Parts) != 1 {
+		return false
+	}
+	_, ok := w.Parts[0].(*syntax.ArrayExpr)
+	return ok
+}
+
+func (c *converter) decl(dc *syntax.DeclClause) {
+	if dc.Variant != "export" || len(dc.Opts) > 0 {
+		c.errorf(dc, "%s isn't supported", dc.Variant)
+		return
+	}
+	for _, as := range dc.Assigns {
+		if as.Name != nil {
+			c.assign(as)
+		}
+		// exporting a variable without a value changes nothing, as
+		// all exported variables are environment variables
+	}
+}
+
+// call returns the PowerShell source of a simple command.
+func (c *converter) call(ce *syntax.CallExpr) string {
+	name, _ := syntax.StaticValue(ce.Args[0])
+	args := ce.Args[1:]
+	switch name {
+	case "echo":
+		if len(args) > 0 {
+			if s, ok := syntax.StaticValue(args[0]); ok && strings.HasPrefix(s, "-") {
+				c.errorf(ce, "echo options aren't supported")
+				return ""
+			}
+		}
+		return "Write-Output " + c.joined(args)
+	case "cd":
+		return c.renamed("Set-Location", args)
+	case "true", ":":
+		return "$null"
+	case "false":
+		c.errorf(ce, "false is only supported as a condition")
+		return ""
+	case "exit":
+		if len(args) == 0 {
+			return "exit $LASTEXITCODE"
+		}
+		if s, ok := syntax.StaticValue(args[0]); len(args) > 1 || !ok || !isNumber(s) {
+			c.errorf(ce, "exit is only supported with a number")
+			return ""
+		}
+		return "exit " + c.arg(args[0])
+	case "break", "continue":
+		if len(args) > 0 {
+			c.errorf(ce, "%s is only supported without a number", name)
+		}
+		return name
+	case "[", "test":
+		c.errorf(ce, "this test command can't be converted")
+		return ""
+	case "export", "local", "declare", "typeset", "readonly":
+		c.errorf(ce, "this %s isn't supported", name)
+		return ""
+	}
+	if lit, ok := ce.Args[0].Parts[0].(*syntax.Lit); !ok || len(ce.Args[0].Parts) > 1 || !bare(lit.Value) {
+		// not a plain command name, so it must be invoked
+		return "& " + c.words(ce.Args)
+	}
+	return c.words(ce.Args)
+}
+
+// renamed returns a call to a PowerShell command with the given
+// arguments.
+func (c *converter) renamed(name string, args []*syntax.Word) string {
+	if len(args) == 0 {
+		return name
+	}
+	return name + " " + c.words(args)
+}
+
+func (c *converter) forClause(fc *syntax.ForClause) {
+	wi, ok := fc.Loop.(*syntax.WordIter)
+	if !ok {
+		c.errorf(fc, "C-style for loops aren't supported")
+		return
+	}
+	psName, ok := c.psNames[wi.Name.Value]
+	if !ok {
+		c.errorf(wi, "for loops over exported variables aren't supported")
+		return
+	}
+	list := "$args"
+	if len(wi.List) > 0 && !convert.AllArgs(wi.List) {
+		items := make([]string, len(wi.List))
+		for i, w := range wi.List {
+			items[i] = c.value(w)
+		}
+		list = strings.Join(items, ", ")
+	}
+	c.line("foreach ($" + psName + " in " + list + ") {")
+	c.block(fc.DoStmts)
+}
+
+func (c *converter) redirect(r *syntax.Redirect) string {
+	var op string
+	switch r.Op {
+	case syntax.RdrOut, syntax.AppOut:
+		op = r.Op.String()
+		if r.N != nil && r.N.Value != "1" {
+			if r.N.Value != "2" {
+				c.errorf(r, "redirections of file descriptor %s aren't supported", r.N.Value)
+			}
+			op = r.N.Value + op
+		}
+	case syntax.RdrAll:
+		op = "*>"
+	case syntax.AppAll:
+		op = "*>>"
+	case syntax.DplOut:
+		if s, _ := syntax.StaticValue(r.Word); r.N == nil || r.N.Value != "2" || s != "1" {
+			c.errorf(r, "only 2>&1 is supported of the >& redirections")
+		}
+		return "2>&1"
+	default:
+		c.errorf(r, "%s redirections aren't supported", r.Op)
+		return ""
+	}
+	if s, _ := syntax.StaticValue(r.Word); s == "/dev/null" {
+		return op + " $null"
+	}
+	return op + " " + c.arg(r.Word)
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package topwsh
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"go build -o 'a b' ./... 1kb {} \"it's\"", "go build -o 'a b' ./... '1kb' '{}' 'it''s'\n"},
+		{"echo foo \"a\\$b\" '$x'", "Write-Output 'foo a$b $x'\n"},
+		{"out=build/$GOOS; echo \"${out}_x\" $out.y", "$out = \"build/$env:GOOS\"\nWrite-Output \"${out}_x $out.y\"\n"},
+		{"export E=$HOME/x; export E; echo $E", "$env:E = \"$env:HOME/x\"\nWrite-Output $env:E\n"},
+		{"cmd $1 \"$@\" $# ${#x} $?", "cmd $args[0] @args $args.Count $env:x.Length $LASTEXITCODE\n"},
+		{"v=$(git describe --tags); \"$v\" x", "$v = $(git describe --tags)\n& $v x\n"},
+		{"a | b && c || true", "a | b && c || $null\n"},
+		{"go test >/dev/null 2>&1; ls 2>>err &>all", "go test > $null 2>&1\nls 2>> err *> all\n"},
+		{
+			"if [ -n \"$CI\" ] && [[ $x == y ]]; then :; elif [[ -d $d || ! -f f ]]; then cd $d; else exit 1; fi",
+			"if ($env:CI -ne '' -and $env:x -ceq 'y') {\n} elseif ((Test-Path $env:d -PathType Container) -or -not (Test-Path f -PathType Leaf)) {\n    Set-Location $env:d\n} else {\n    exit 1\n}\n",
+		},
+		{"if ! go vet; then exit; fi", "go vet\nif (-not $?) {\n    exit $LASTEXITCODE\n}\n"},
+		{"[[ $v == v1.* ]] && echo release", "if ($env:v -clike 'v1.*') {\n    Write-Output 'release'\n}\n"},
+		{"while [ $n -lt 3 ]; do break; done", "while ([int]$env:n -lt 3) {\n    break\n}\n"},
+		{"until [ -e f ]; do sleep 1; done", "while (-not (Test-Path f)) {\n    sleep 1\n}\n"},
+		{"for i in a \"b c\"; do echo $i; done", "foreach ($i in 'a', 'b c') {\n    Write-Output $i\n}\n"},
+		{"for i; do :; done", "foreach ($i in $args) {\n}\n"},
+		{"args=1; Home=2; echo $args $Home", "$args_ = '1'\n$Home_ = '2'\nWrite-Output \"$args_ $Home_\"\n"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := Convert(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(src); got != tc.want {
+				t.Fatalf("Convert mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		in, want string
+	}{
+		{"f() { :; }", "1:1: a function isn't supported"},
+		{"a &", "1:1: background commands aren't supported"},
+		{"cat <in", "1:5: < redirections aren't supported"},
+		{"cmd >&2", "1:5: only 2>&1 is supported of the >& redirections"},
+		{"echo *.sh", "1:6: globs aren't supported"},
+		{"echo ${a:-b}", "1:6: this parameter expansion isn't supported"},
+		{"for i in \"${#:-a}\"; do :; done", "1:11: this parameter expansion isn't supported"},
+		{"echo $((1 + 2))", "1:6: arithmetic expansions aren't supported"},
+		{"cmd \"x$@\"", "1:5: $@ is only supported as a whole word"},
+		{"if a; then b; elif c; then d; fi", "1:20: commands are only supported as conditions of if clauses without elif"},
+		{"A=1 cmd", "1:1: assignments before commands aren't supported"},
+		{"case a in b) ;; esac", "1:1: a case clause isn't supported"},
+		{"exit $n", "1:1: exit is only supported with a number"},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Convert(f)
+			if err == nil {
+				t.Fatalf("Convert in %q did not error", tc.in)
+			}
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("error mismatch in %q\nwant: %q\ngot:  %q", tc.in, tc.want, got)
+			}
+		})
+	}
+}
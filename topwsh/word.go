@@ -0,0 +1,475 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package topwsh
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// piece is a part of a word: a literal string, a variable, or another
+// PowerShell expression.
+type piece struct {
+	lit  string
+	name string // like "x" or "env:HOME", for $x or $env:HOME
+	expr string
+
+	// splat is "$@", which expands to all the arguments
+	splat bool
+}
+
+func (p piece) isLit() bool {
+	return p.name == "" && p.expr == "" && !p.splat
+}
+
+// words returns the PowerShell arguments for a list of words.
+func (c *converter) words(ws []*syntax.Word) string {
+	strs := make([]string, len(ws))
+	for i, w := range ws {
+		strs[i] = c.arg(w)
+	}
+	return strings.Join(strs, " ")
+}
+
+// arg returns the PowerShell argument for a word, which is only quoted
+// if needed.
+func (c *converter) arg(w *syntax.Word) string {
+	ps := c.pieces(w)
+	if len(ps) == 1 && ps[0].splat {
+		return "@args"
+	}
+	if s, ok := allLit(ps); ok {
+		if bare(s) {
+			return s
+		}
+		return quote(s)
+	}
+	return c.render(w, ps)
+}
+
+// value returns the PowerShell expression for the string of a word.
+func (c *converter) value(w *syntax.Word) string {
+	ps := c.pieces(w)
+	if len(ps) == 1 && ps[0].splat {
+		return "$args"
+	}
+	if s, ok := allLit(ps); ok {
+		return quote(s)
+	}
+	return c.render(w, ps)
+}
+
+// joined returns the PowerShell string for a list of words joined with
+// spaces, like echo prints them.
+func (c *converter) joined(ws []*syntax.Word) string {
+	var ps []piece
+	for i, w := range ws {
+		if i > 0 {
+			ps = append(ps, piece{lit: " "})
+		}
+		for _, p := range c.pieces(w) {
+			if p.splat {
+				// $args is joined with spaces in strings
+				p = piece{name: "args"}
+			}
+			ps = append(ps, p)
+		}
+	}
+	if s, ok := allLit(ps); ok {
+		return quote(s)
+	}
+	return c.render(nil, ps)
+}
+
+func allLit(ps []piece) (string, bool) {
+	var s string
+	for _, p := range ps {
+		if !p.isLit() {
+			return "", false
+		}
+		s += p.lit
+	}
+	return s, true
+}
+
+// render returns the PowerShell expression for pieces that aren't all
+// literals, which is a double-quoted string unless there's just one
+// expression.
+func (c *converter) render(w *syntax.Word, ps []piece) string {
+	if len(ps) == 1 {
+		if ps[0].name != "" {
+			return "$" + ps[0].name
+		}
+		return ps[0].expr
+	}
+	s := `"`
+	for i, p := range ps {
+		switch {
+		case p.splat:
+			c.errorf(w, "$@ is only supported as a whole word")
+		case p.name != "":
+			if i+1 < len(ps) && ps[i+1].isLit() && continuesName(ps[i+1].lit) {
+				s += "${" + p.name + "}"
+			} else {
+				s += "$" + p.name
+			}
+		case p.expr != "":
+			if strings.HasPrefix(p.expr, "$(") {
+				s += p.expr
+			} else {
+				s += "$(" + p.expr + ")"
+			}
+		default:
+			s += escapeQuoted(p.lit)
+		}
+	}
+	return s + `"`
+}
+
+// continuesName reports whether a string would continue the name of a
+// variable that goes before it in a PowerShell string.
+func continuesName(s string) bool {
+	if s == "" {
+		return false
+	}
+	c := s[0]
+	return c == '_' || c == ':' || c == '?' || validName(string(c)) ||
+		('0' <= c && c <= '9')
+}
+
+// bare reports whether a literal argument doesn't need quotes.
+func bare(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("_./:=+-", c):
+		default:
+			return false
+		}
+	}
+	// PowerShell parses arguments like 1kb or 0x10 as numbers
+	return s[0] < '0' || s[0] > '9' || isNumber(s)
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// quote returns a literal PowerShell string, in single quotes.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// escapeQuoted escapes the characters that are special between double
+// quotes.
+func escapeQuoted(s string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '`', '"', '$':
+			buf = append(buf, '`')
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+// pieces returns the pieces of a word, merging adjacent literals.
+func (c *converter) pieces(w *syntax.Word) []piece {
+	var ps []piece
+	add := func(p piece) {
+		if n := len(ps); n > 0 && p.isLit() && ps[n-1].isLit() {
+			ps[n-1].lit += p.lit
+			return
+		}
+		ps = append(ps, p)
+	}
+	var walk func(parts []syntax.WordPart, quoted bool)
+	walk = func(parts []syntax.WordPart, quoted bool) {
+		for i, part := range parts {
+			switch x := part.(type) {
+			case *syntax.Lit:
+				switch {
+				case quoted:
+				case strings.ContainsAny(x.Value, "*?["):
+					c.errorf(x, "globs aren't supported")
+				case i == 0 && strings.HasPrefix(x.Value, "~"):
+					c.errorf(x, "tilde expansions aren't supported")
+				case strings.Contains(x.Value, "{") &&
+					strings.ContainsAny(x.Value, ",."):
+					c.errorf(x, "brace expansions aren't supported")
+				}
+				var lp syntax.WordPart = x
+				if quoted {
+					lp = &syntax.DblQuoted{Parts: []syntax.WordPart{x}}
+				}
+				s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{lp}})
+				add(piece{lit: s})
+			case *syntax.SglQuoted:
+				s, _ := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+				add(piece{lit: s})
+			case *syntax.DblQuoted:
+				if len(x.Parts) == 0 {
+					add(piece{})
+				}
+				walk(x.Parts, true)
+			case *syntax.ParamExp:
+				add(c.paramExp(x, quoted))
+			case *syntax.CmdSubst:
+				strs := make([]string, len(x.Stmts))
+				for i, s := range x.Stmts {
+					strs[i] = c.pipeline(s)
+				}
+				add(piece{expr: "$(" + strings.Join(strs, "; ") + ")"})
+			case *syntax.ArithmExp:
+				c.errorf(x, "arithmetic expansions aren't supported")
+			case *syntax.ProcSubst:
+				c.errorf(x, "process substitutions aren't supported")
+			default:
+				c.errorf(part, "this expansion isn't supported")
+			}
+		}
+	}
+	walk(w.Parts, false)
+	return ps
+}
+
+func (c *converter) paramExp(pe *syntax.ParamExp, quoted bool) piece {
+	if pe.Ind != nil || pe.Slice != nil || pe.Repl != nil || pe.Exp != nil {
+		c.errorf(pe, "this parameter expansion isn't supported")
+		return piece{}
+	}
+	var p piece
+	switch name := pe.Param.Value; {
+	case name == "@" || name == "*":
+		if name == "*" && quoted {
+			// a single string of the arguments joined by spaces
+			p.expr = `"$args"`
+		} else {
+			p.splat = true
+		}
+	case name == "#":
+		p.expr = "$args.Count"
+	case name == "?":
+		p.name = "LASTEXITCODE"
+	case name == "$":
+		p.name = "PID"
+	case name == "0":
+		p.name = "PSCommandPath"
+	case isNumber(name):
+		n, _ := strconv.Atoi(name)
+		p.expr = "$args[" + strconv.Itoa(n-1) + "]"
+	case validName(name):
+		p.name = c.variable(name)[1:]
+	default:
+		c.errorf(pe, "$%s isn't supported", name)
+	}
+	if pe.Length {
+		if p.name != "" {
+			p.expr, p.name = "$"+p.name, ""
+		}
+		if p.splat {
+			p.expr, p.splat = "$args", false
+		}
+		p.expr += ".Length"
+	}
+	return p
+}
+
+// variable returns the PowerShell variable for a shell one.
+func (c *converter) variable(name string) string {
+	if psName, ok := c.psNames[name]; ok {
+		return "$" + psName
+	}
+	return "$env:" + name
+}
+
+func validName(s string) bool {
+	for i, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case c == '_':
+		case i > 0 && '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// psExpr is a PowerShell expression, along with the precedence of its
+// outermost operator.
+type psExpr struct {
+	s    string
+	prec int
+
+	// logic is the -and or -or operator of a logical expression
+	logic string
+}
+
+// The precedences of the PowerShell operators.
+const (
+	// precCall is a command like Test-Path, which needs parentheses
+	// in any expression
+	precCall = iota
+	precLogic
+	precCmp
+	precUnary
+	precOperand
+)
+
+// logical returns a logical expression. -and and -or have the same
+// precedence in PowerShell, so mixing them needs parentheses.
+func logical(x psExpr, op string, y psExpr) psExpr {
+	in := func(x psExpr) string {
+		if x.prec == precCall || (x.prec == precLogic && x.logic != op) {
+			return "(" + x.s + ")"
+		}
+		return x.s
+	}
+	return psExpr{in(x) + " " + op + " " + in(y), precLogic, op}
+}
+
+func not(x psExpr) psExpr {
+	if x.prec < precUnary {
+		return psExpr{s: "-not (" + x.s + ")", prec: precUnary}
+	}
+	return psExpr{s: "-not " + x.s, prec: precUnary}
+}
+
+func compare(x string, op string, y string) psExpr {
+	return psExpr{s: x + " " + op + " " + y, prec: precCmp}
+}
+
+var pathTests = map[syntax.UnTestOperator]string{
+	syntax.TsExists:  "",
+	syntax.TsRegFile: " -PathType Leaf",
+	syntax.TsDirect:  " -PathType Container",
+}
+
+var cmpTests = map[syntax.BinTestOperator]string{
+	syntax.TsEqual:   "-ceq",
+	syntax.TsAssgn:   "-ceq",
+	syntax.TsNequal:  "-cne",
+	syntax.TsBefore:  "-clt",
+	syntax.TsAfter:   "-cgt",
+	syntax.TsReMatch: "-cmatch",
+	syntax.TsEql:     "-eq",
+	syntax.TsNeq:     "-ne",
+	syntax.TsLss:     "-lt",
+	syntax.TsLeq:     "-le",
+	syntax.TsGtr:     "-gt",
+	syntax.TsGeq:     "-ge",
+}
+
+// test returns the PowerShell condition for a [[ ]] expression.
+func (c *converter) test(expr syntax.TestExpr) psExpr {
+	switch x := expr.(type) {
+	case *syntax.Word:
+		return compare(c.value(x), "-ne", "''")
+	case *syntax.ParenTest:
+		return psExpr{s: "(" + c.test(x.X).s + ")", prec: precOperand}
+	case *syntax.UnaryTest:
+		if x.Op == syntax.TsNot {
+			return not(c.test(x.X))
+		}
+		w, ok := x.X.(*syntax.Word)
+		if !ok {
+			break
+		}
+		switch x.Op {
+		case syntax.TsEmpStr:
+			return compare(c.value(w), "-eq", "''")
+		case syntax.TsNempStr:
+			return compare(c.value(w), "-ne", "''")
+		}
+		if opt, ok := pathTests[x.Op]; ok {
+			return psExpr{s: "Test-Path " + c.arg(w) + opt, prec: precCall}
+		}
+		c.errorf(x, "%s isn't supported", x.Op)
+		return psExpr{s: "$false", prec: precOperand}
+	case *syntax.BinaryTest:
+		switch x.Op {
+		case syntax.AndTest:
+			return logical(c.test(x.X), "-and", c.test(x.Y))
+		case syntax.OrTest:
+			return logical(c.test(x.X), "-or", c.test(x.Y))
+		}
+		l, ok1 := x.X.(*syntax.Word)
+		r, ok2 := x.Y.(*syntax.Word)
+		op, ok3 := cmpTests[x.Op]
+		if !ok1 || !ok2 {
+			break
+		}
+		if !ok3 {
+			c.errorf(x, "%s isn't supported", x.Op)
+			return psExpr{s: "$false", prec: precOperand}
+		}
+		switch {
+		case x.Op >= syntax.TsEql && x.Op <= syntax.TsGtr:
+			return compare(c.number(l), op, c.number(r))
+		case x.Op == syntax.TsReMatch:
+			return compare(c.value(l), op, c.value(r))
+		case hasGlob(r):
+			op = "-clike"
+			if x.Op == syntax.TsNequal {
+				op = "-cnotlike"
+			}
+			return compare(c.value(l), op, c.pattern(r))
+		}
+		return compare(c.value(l), op, c.value(r))
+	}
+	c.errorf(expr, "this test expression isn't supported")
+	return psExpr{s: "$false", prec: precOperand}
+}
+
+// number returns the PowerShell integer for a word.
+func (c *converter) number(w *syntax.Word) string {
+	if s, ok := syntax.StaticValue(w); ok && isNumber(s) {
+		return s
+	}
+	return "[int]" + c.value(w)
+}
+
+// hasGlob reports whether a word has unquoted glob characters.
+func hasGlob(w *syntax.Word) bool {
+	for _, part := range w.Parts {
+		if lit, ok := part.(*syntax.Lit); ok && strings.ContainsAny(lit.Value, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern returns the PowerShell wildcard for a pattern word, with the
+// quoted characters escaped.
+func (c *converter) pattern(w *syntax.Word) string {
+	var s string
+	for _, part := range w.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			s += x.Value
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			lit, ok := syntax.StaticValue(&syntax.Word{Parts: []syntax.WordPart{x}})
+			if !ok {
+				c.errorf(x, "patterns with expansions aren't supported")
+			}
+			for _, r := range lit {
+				if strings.ContainsRune("*?[]`", r) {
+					s += "`"
+				}
+				s += string(r)
+			}
+		default:
+			c.errorf(x, "patterns with expansions aren't supported")
+		}
+	}
+	return quote(s)
+}
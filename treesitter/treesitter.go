@@ -0,0 +1,618 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+// Package treesitter converts syntax trees to the named nodes of the
+// tree-sitter-bash grammar, so that editors and tools built around that
+// grammar can use the results of this parser instead.
+//
+// Only the named nodes are kept, like in the trees printed by the
+// tree-sitter CLI; the anonymous ones, like keywords and operators, are
+// left out. Where tree-sitter-bash has no node for a construct, like
+// coproc, the closest one is used. Since this parser doesn't parse the
+// expressions given to "[", those are kept as commands instead of test
+// commands. Comments are children of the innermost node containing them.
+package treesitter
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+// Point is a position in a source, like the TSPoint of tree-sitter.
+// Both Row and Column start at 0, and Column counts bytes.
+type Point struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// Node is a named node of the tree-sitter-bash grammar.
+type Node struct {
+	// Type is the name of the node in the grammar, like "command" or
+	// "variable_name".
+	Type string `json:"type"`
+
+	// Field is the name of the field of its parent that the node is
+	// in, like "name" or "argument", if any.
+	Field string `json:"field,omitempty"`
+
+	// StartByte and EndByte are the offsets where the node starts and
+	// ends, and StartPoint and EndPoint their positions.
+	StartByte  int   `json:"startByte"`
+	EndByte    int   `json:"endByte"`
+	StartPoint Point `json:"startPoint"`
+	EndPoint   Point `json:"endPoint"`
+
+	Children []*Node `json:"children,omitempty"`
+}
+
+// String returns the node as an S-expression, like the ones printed by
+// the tree-sitter CLI:
+//
+//	(program (command name: (command_name (word)) argument: (word)))
+func (n *Node) String() string {
+	var buf bytes.Buffer
+	n.write(&buf)
+	return buf.String()
+}
+
+func (n *Node) write(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	buf.WriteString(n.Type)
+	for _, c := range n.Children {
+		buf.WriteByte(' ')
+		if c.Field != "" {
+			buf.WriteString(c.Field)
+			buf.WriteString(": ")
+		}
+		c.write(buf)
+	}
+	buf.WriteByte(')')
+}
+
+// Convert returns the tree-sitter-bash tree of a file, whose root is a
+// "program" node.
+func Convert(f *syntax.File) *Node {
+	c := &converter{f: f, lines: f.Lines}
+	if c.lines == nil {
+		// parsed with SkipLines, so find the lines in the source
+		c.lines = []uint32{0}
+		for i, b := range f.Source {
+			if b == '\n' {
+				c.lines = append(c.lines, uint32(i+1))
+			}
+		}
+	}
+	root := c.node("program", 1, syntax.Pos(len(f.Source)+1), c.stmts(f.Stmts)...)
+	if len(f.Source) == 0 {
+		root = c.span("program", root.Children...)
+	}
+	for _, cm := range f.Comments {
+		insert(root, c.node("comment", cm.Pos(), cm.End()))
+	}
+	return root
+}
+
+// insert adds a node to the innermost node containing it, keeping the
+// children in order.
+func insert(parent, n *Node) {
+	for {
+		var inner *Node
+		for _, c := range parent.Children {
+			if c.StartByte <= n.StartByte && n.EndByte <= c.EndByte {
+				inner = c
+				break
+			}
+		}
+		if inner == nil {
+			break
+		}
+		parent = inner
+	}
+	i := 0
+	for i < len(parent.Children) && parent.Children[i].StartByte < n.StartByte {
+		i++
+	}
+	parent.Children = append(parent.Children, nil)
+	copy(parent.Children[i+1:], parent.Children[i:])
+	parent.Children[i] = n
+}
+
+type converter struct {
+	f *syntax.File
+
+	// lines are the offsets of the lines of the file, like File.Lines
+	lines []uint32
+}
+
+// point returns the offset and the point of a position. Unlike
+// File.Position, the newline at the end of a line is in that line.
+func (c *converter) point(p syntax.Pos) (int, Point) {
+	offset := int(p) - 1
+	lines := c.lines
+	row := sort.Search(len(lines), func(i int) bool { return int(lines[i]) > offset }) - 1
+	if row < 0 {
+		return offset, Point{Column: offset}
+	}
+	return offset, Point{Row: row, Column: offset - int(lines[row])}
+}
+
+// node returns a node with a range and its children, skipping the nil
+// ones.
+func (c *converter) node(typ string, pos, end syntax.Pos, children ...*Node) *Node {
+	n := &Node{Type: typ}
+	n.StartByte, n.StartPoint = c.point(pos)
+	n.EndByte, n.EndPoint = c.point(end)
+	for _, child := range children {
+		if child != nil {
+			n.Children = append(n.Children, child)
+		}
+	}
+	return n
+}
+
+// span returns a node whose range is the one of its children.
+func (c *converter) span(typ string, children ...*Node) *Node {
+	n := &Node{Type: typ}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if len(n.Children) == 0 || child.StartByte < n.StartByte {
+			n.StartByte, n.StartPoint = child.StartByte, child.StartPoint
+		}
+		if len(n.Children) == 0 || child.EndByte > n.EndByte {
+			n.EndByte, n.EndPoint = child.EndByte, child.EndPoint
+		}
+		n.Children = append(n.Children, child)
+	}
+	return n
+}
+
+// field sets the field of a node, if it isn't nil.
+func field(name string, n *Node) *Node {
+	if n != nil {
+		n.Field = name
+	}
+	return n
+}
+
+func fields(name string, ns []*Node) []*Node {
+	for _, n := range ns {
+		field(name, n)
+	}
+	return ns
+}
+
+func (c *converter) stmts(stmts []*syntax.Stmt) []*Node {
+	var ns []*Node
+	for _, s := range stmts {
+		if n := c.stmt(s); n != nil {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// plain reports whether a statement is only its command, so that it can
+// be merged with the one it's a part of.
+func plain(s *syntax.Stmt) bool {
+	return !s.Negated && !s.Background && len(s.Assigns) == 0 && len(s.Redirs) == 0
+}
+
+func (c *converter) stmt(s *syntax.Stmt) *Node {
+	var n *Node
+	switch {
+	case s.Cmd != nil:
+		n = c.command(s.Cmd, s.Assigns)
+	case len(s.Assigns) == 1:
+		n = c.assign(s.Assigns[0])
+	case len(s.Assigns) > 1:
+		var as []*Node
+		for _, a := range s.Assigns {
+			as = append(as, c.assign(a))
+		}
+		n = c.span("variable_assignments", as...)
+	}
+	if len(s.Redirs) > 0 {
+		rs := []*Node{field("body", n)}
+		for _, r := range s.Redirs {
+			rs = append(rs, field("redirect", c.redirect(r)))
+		}
+		n = c.span("redirected_statement", rs...)
+	}
+	if s.Negated && n != nil {
+		n = c.span("negated_command", n)
+		n.StartByte, n.StartPoint = c.point(s.Position)
+	}
+	return n
+}
+
+func (c *converter) command(cmd syntax.Command, assigns []*syntax.Assign) *Node {
+	switch x := cmd.(type) {
+	case *syntax.CallExpr:
+		var ns []*Node
+		for _, a := range assigns {
+			ns = append(ns, c.assign(a))
+		}
+		name := c.span("command_name", c.word(x.Args[0]))
+		ns = append(ns, field("name", name))
+		for _, w := range x.Args[1:] {
+			ns = append(ns, field("argument", c.word(w)))
+		}
+		return c.span("command", ns...)
+	case *syntax.IfClause:
+		ns := fields("condition", c.stmts(x.CondStmts))
+		ns = append(ns, c.stmts(x.ThenStmts)...)
+		for _, elif := range x.Elifs {
+			ens := fields("condition", c.stmts(elif.CondStmts))
+			ens = append(ens, c.stmts(elif.ThenStmts)...)
+			ns = append(ns, c.node("elif_clause", elif.Elif, stmtsEnd(elif.ThenStmts, elif.Then+4), ens...))
+		}
+		if x.Else > 0 {
+			ns = append(ns, c.node("else_clause", x.Else, stmtsEnd(x.ElseStmts, x.Else+4),
+				c.stmts(x.ElseStmts)...))
+		}
+		return c.node("if_statement", x.If, x.End(), ns...)
+	case *syntax.WhileClause:
+		ns := fields("condition", c.stmts(x.CondStmts))
+		ns = append(ns, field("body", c.doGroup(x.Do, x.Done, x.DoStmts)))
+		return c.node("while_statement", x.While, x.End(), ns...)
+	case *syntax.UntilClause:
+		// tree-sitter-bash has a single node for both loops
+		ns := fields("condition", c.stmts(x.CondStmts))
+		ns = append(ns, field("body", c.doGroup(x.Do, x.Done, x.DoStmts)))
+		return c.node("while_statement", x.Until, x.End(), ns...)
+	case *syntax.ForClause:
+		body := field("body", c.doGroup(x.Do, x.Done, x.DoStmts))
+		switch l := x.Loop.(type) {
+		case *syntax.WordIter:
+			ns := []*Node{field("variable", c.node("variable_name", l.Name.Pos(), l.Name.End()))}
+			for _, w := range l.List {
+				ns = append(ns, field("value", c.word(w)))
+			}
+			return c.node("for_statement", x.For, x.End(), append(ns, body)...)
+		case *syntax.CStyleLoop:
+			return c.node("c_style_for_statement", x.For, x.End(),
+				field("initializer", c.arithm(l.Init)),
+				field("condition", c.arithm(l.Cond)),
+				field("update", c.arithm(l.Post)),
+				body)
+		}
+	case *syntax.CaseClause:
+		ns := []*Node{field("value", c.word(x.Word))}
+		for _, pl := range x.List {
+			var ins []*Node
+			for _, w := range pl.Patterns {
+				ins = append(ins, field("value", c.word(w)))
+			}
+			ins = append(ins, c.stmts(pl.Stmts)...)
+			item := c.span("case_item", ins...)
+			if pl.OpPos > 0 {
+				item.EndByte, item.EndPoint = c.point(pl.OpPos + syntax.Pos(len(pl.Op.String())))
+			}
+			ns = append(ns, item)
+		}
+		return c.node("case_statement", x.Case, x.End(), ns...)
+	case *syntax.Block:
+		return c.node("compound_statement", x.Lbrace, x.Rbrace+1, c.stmts(x.Stmts)...)
+	case *syntax.Subshell:
+		return c.node("subshell", x.Lparen, x.Rparen+1, c.stmts(x.Stmts)...)
+	case *syntax.BinaryCmd:
+		ops := c.operands(x)
+		if x.Op == syntax.Pipe || x.Op == syntax.PipeAll {
+			return c.span("pipeline", ops...)
+		}
+		// the parser nests lists to the right, and tree-sitter-bash
+		// to the left
+		n := ops[0]
+		for _, y := range ops[1:] {
+			n = c.span("list", n, y)
+		}
+		return n
+	case *syntax.FuncDecl:
+		return c.node("function_definition", x.Pos(), x.End(),
+			field("name", c.node("word", x.Name.Pos(), x.Name.End())),
+			field("body", c.stmt(x.Body)))
+	case *syntax.ArithmCmd:
+		return c.node("compound_statement", x.Pos(), x.End(), c.arithm(x.X))
+	case *syntax.TestClause:
+		return c.node("test_command", x.Pos(), x.End(), c.test(x.X))
+	case *syntax.DeclClause:
+		var ns []*Node
+		for _, w := range x.Opts {
+			ns = append(ns, c.word(w))
+		}
+		for _, a := range x.Assigns {
+			if a.Name == nil {
+				ns = append(ns, c.nameWord(a.Value))
+			} else {
+				ns = append(ns, c.assign(a))
+			}
+		}
+		return c.node("declaration_command", x.Pos(), x.End(), ns...)
+	case *syntax.EvalClause:
+		ns := []*Node{field("name", c.keyword(x.Eval, "eval"))}
+		if x.Stmt != nil {
+			if call, ok := x.Stmt.Cmd.(*syntax.CallExpr); ok && plain(x.Stmt) {
+				for _, w := range call.Args {
+					ns = append(ns, field("argument", c.word(w)))
+				}
+			} else {
+				ns = append(ns, c.stmt(x.Stmt))
+			}
+		}
+		return c.span("command", ns...)
+	case *syntax.LetClause:
+		ns := []*Node{field("name", c.keyword(x.Let, "let"))}
+		for _, expr := range x.Exprs {
+			ns = append(ns, field("argument", c.node("word", expr.Pos(), expr.End())))
+		}
+		return c.span("command", ns...)
+	case *syntax.CoprocClause:
+		ns := []*Node{field("name", c.keyword(x.Coproc, "coproc"))}
+		if x.Name != nil {
+			ns = append(ns, field("argument", c.node("word", x.Name.Pos(), x.Name.End())))
+		}
+		return c.span("command", append(ns, c.stmt(x.Stmt))...)
+	}
+	return nil
+}
+
+// keyword returns the command name of a shell keyword, like eval, that
+// tree-sitter-bash parses as a command.
+func (c *converter) keyword(pos syntax.Pos, name string) *Node {
+	return c.node("command_name", pos, pos+syntax.Pos(len(name)),
+		c.node("word", pos, pos+syntax.Pos(len(name))))
+}
+
+// operands returns the nodes of the operands of a chain of binary
+// commands with operators of the same kind, like "a | b | c".
+func (c *converter) operands(b *syntax.BinaryCmd) []*Node {
+	pipe := b.Op == syntax.Pipe || b.Op == syntax.PipeAll
+	var ns []*Node
+	for _, s := range [...]*syntax.Stmt{b.X, b.Y} {
+		if y, ok := s.Cmd.(*syntax.BinaryCmd); ok && plain(s) &&
+			pipe == (y.Op == syntax.Pipe || y.Op == syntax.PipeAll) {
+			ns = append(ns, c.operands(y)...)
+		} else {
+			ns = append(ns, c.stmt(s))
+		}
+	}
+	return ns
+}
+
+func (c *converter) doGroup(do, done syntax.Pos, stmts []*syntax.Stmt) *Node {
+	return c.node("do_group", do, done+4, c.stmts(stmts)...)
+}
+
+func stmtsEnd(stmts []*syntax.Stmt, def syntax.Pos) syntax.Pos {
+	if len(stmts) == 0 {
+		return def
+	}
+	return stmts[len(stmts)-1].End()
+}
+
+func (c *converter) assign(a *syntax.Assign) *Node {
+	var value *Node
+	if a.Value != nil {
+		value = field("value", c.word(a.Value))
+	}
+	return c.node("variable_assignment", a.Pos(), a.End(),
+		field("name", c.node("variable_name", a.Name.Pos(), a.Name.End())), value)
+}
+
+func (c *converter) redirect(r *syntax.Redirect) *Node {
+	switch r.Op {
+	case syntax.Hdoc, syntax.DashHdoc:
+		ns := []*Node{c.node("heredoc_start", r.Word.Pos(), r.Word.End())}
+		if r.Hdoc != nil {
+			var bns []*Node
+			for _, part := range r.Hdoc.Parts {
+				if _, ok := part.(*syntax.Lit); !ok {
+					bns = append(bns, c.wordPart(part, true))
+				}
+			}
+			ns = append(ns, c.node("heredoc_body", r.Hdoc.Pos(), r.Hdoc.End(), bns...))
+		}
+		n := c.span("heredoc_redirect", ns...)
+		n.StartByte, n.StartPoint = c.point(r.Pos())
+		return n
+	case syntax.WordHdoc:
+		return c.node("herestring_redirect", r.Pos(), r.End(), c.word(r.Word))
+	}
+	var desc *Node
+	if r.N != nil {
+		desc = field("descriptor", c.node("file_descriptor", r.N.Pos(), r.N.End()))
+	}
+	return c.node("file_redirect", r.Pos(), r.End(), desc, field("destination", c.word(r.Word)))
+}
+
+func (c *converter) word(w *syntax.Word) *Node {
+	if w == nil {
+		return nil
+	}
+	if len(w.Parts) == 1 {
+		return c.wordPart(w.Parts[0], false)
+	}
+	var ns []*Node
+	for _, part := range w.Parts {
+		ns = append(ns, c.wordPart(part, false))
+	}
+	return c.node("concatenation", w.Pos(), w.End(), ns...)
+}
+
+// nameWord returns the node of a word that may be the name of a variable,
+// like in "local foo".
+func (c *converter) nameWord(w *syntax.Word) *Node {
+	if lit, ok := w.Parts[0].(*syntax.Lit); ok && len(w.Parts) == 1 && validName(lit.Value) {
+		return c.node("variable_name", lit.Pos(), lit.End())
+	}
+	return c.word(w)
+}
+
+func (c *converter) wordPart(part syntax.WordPart, quoted bool) *Node {
+	switch x := part.(type) {
+	case *syntax.Lit:
+		switch {
+		case quoted:
+			return c.node("string_content", x.Pos(), x.End())
+		case number(x.Value):
+			return c.node("number", x.Pos(), x.End())
+		}
+		return c.node("word", x.Pos(), x.End())
+	case *syntax.SglQuoted:
+		if x.Dollar {
+			return c.node("ansi_c_string", x.Pos(), x.End())
+		}
+		return c.node("raw_string", x.Pos(), x.End())
+	case *syntax.DblQuoted:
+		var ns []*Node
+		for _, p := range x.Parts {
+			ns = append(ns, c.wordPart(p, true))
+		}
+		if x.Dollar {
+			return c.node("translated_string", x.Pos(), x.End(),
+				c.node("string", x.Pos()+1, x.End(), ns...))
+		}
+		return c.node("string", x.Pos(), x.End(), ns...)
+	case *syntax.ParamExp:
+		return c.paramExp(x)
+	case *syntax.CmdSubst:
+		return c.node("command_substitution", x.Pos(), x.End(), c.stmts(x.Stmts)...)
+	case *syntax.ArithmExp:
+		return c.node("arithmetic_expansion", x.Pos(), x.End(), c.arithm(x.X))
+	case *syntax.ProcSubst:
+		return c.node("process_substitution", x.Pos(), x.End(), c.stmts(x.Stmts)...)
+	case *syntax.ArrayExpr:
+		var ns []*Node
+		for _, w := range x.List {
+			ns = append(ns, c.word(w))
+		}
+		return c.node("array", x.Pos(), x.End(), ns...)
+	case *syntax.ExtGlob:
+		return c.node("extglob_pattern", x.Pos(), x.End())
+	case *syntax.TemplateExpr:
+		return c.node("word", x.Pos(), x.End())
+	}
+	return nil
+}
+
+func (c *converter) paramExp(pe *syntax.ParamExp) *Node {
+	param := pe.Param
+	if param == nil {
+		// ${#:-a} is parsed as a length without a name, but it's $#
+		// followed by an operator
+		pos := pe.Pos() + 2
+		param = &syntax.Lit{ValuePos: pos, ValueEnd: pos + 1, Value: "#"}
+	}
+	name := c.varName(param)
+	if pe.Short {
+		return c.node("simple_expansion", pe.Pos(), pe.End(), name)
+	}
+	ns := []*Node{name}
+	switch {
+	case pe.Ind != nil:
+		index := field("index", c.arithm(pe.Ind.Expr))
+		// the index has no positions of its own, so it's up to the "]"
+		// after its expression, if it has one
+		end := param.End() + 2
+		if index != nil {
+			end = pe.Ind.Expr.End() + 1
+		}
+		ns[0] = c.node("subscript", param.Pos(), end, field("name", name), index)
+	}
+	switch {
+	case pe.Slice != nil:
+		ns = append(ns, c.arithm(pe.Slice.Offset), c.arithm(pe.Slice.Length))
+	case pe.Repl != nil:
+		ns = append(ns, c.word(pe.Repl.Orig), c.word(pe.Repl.With))
+	case pe.Exp != nil:
+		ns = append(ns, c.word(pe.Exp.Word))
+	}
+	return c.node("expansion", pe.Pos(), pe.End(), ns...)
+}
+
+// varName returns the node of the name of a parameter, which is a
+// special_variable_name for the special parameters like $@.
+func (c *converter) varName(lit *syntax.Lit) *Node {
+	if len(lit.Value) == 1 && strings.Contains("*@?!#-$0_", lit.Value) {
+		return c.node("special_variable_name", lit.Pos(), lit.End())
+	}
+	return c.node("variable_name", lit.Pos(), lit.End())
+}
+
+func (c *converter) arithm(expr syntax.ArithmExpr) *Node {
+	switch x := expr.(type) {
+	case *syntax.BinaryArithm:
+		return c.node("binary_expression", x.Pos(), x.End(),
+			field("left", c.arithm(x.X)), field("right", c.arithm(x.Y)))
+	case *syntax.UnaryArithm:
+		if x.Post {
+			return c.node("postfix_expression", x.Pos(), x.End(), c.arithm(x.X))
+		}
+		return c.node("unary_expression", x.Pos(), x.End(), c.arithm(x.X))
+	case *syntax.ParenArithm:
+		return c.node("parenthesized_expression", x.Pos(), x.End(), c.arithm(x.X))
+	case *syntax.Word:
+		if lit, ok := x.Parts[0].(*syntax.Lit); ok && len(x.Parts) == 1 && validName(lit.Value) {
+			return c.node("variable_name", lit.Pos(), lit.End())
+		}
+		return c.word(x)
+	}
+	return nil
+}
+
+func (c *converter) test(expr syntax.TestExpr) *Node {
+	switch x := expr.(type) {
+	case *syntax.BinaryTest:
+		var op *Node
+		if s := x.Op.String(); strings.HasPrefix(s, "-") {
+			op = field("operator", c.node("test_operator", x.OpPos, x.OpPos+syntax.Pos(len(s))))
+		}
+		return c.node("binary_expression", x.Pos(), x.End(),
+			field("left", c.test(x.X)), op, field("right", c.test(x.Y)))
+	case *syntax.UnaryTest:
+		var op *Node
+		if s := x.Op.String(); strings.HasPrefix(s, "-") {
+			op = c.node("test_operator", x.OpPos, x.OpPos+syntax.Pos(len(s)))
+		}
+		return c.node("unary_expression", x.Pos(), x.End(), op, c.test(x.X))
+	case *syntax.ParenTest:
+		return c.node("parenthesized_expression", x.Pos(), x.End(), c.test(x.X))
+	case *syntax.Word:
+		return c.word(x)
+	}
+	return nil
+}
+
+// number reports whether a literal is a number, like "42" or "-1".
+func number(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validName reports whether a string is a valid variable name.
+func validName(s string) bool {
+	for i, r := range s {
+		switch {
+		case 'a' <= r && r <= 'z':
+		case 'A' <= r && r <= 'Z':
+		case r == '_':
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2016, Daniel Martí <mvdan@mvdan.cc>
+// See LICENSE for licensing information
+
+package treesitter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mvdan/sh/syntax"
+)
+
+var convertTests = []struct {
+	in, want string
+}{
+	{
+		"echo foo 1 >x",
+		"(program (redirected_statement body: (command name: (command_name (word)) argument: (word) argument: (number)) redirect: (file_redirect destination: (word))))",
+	},
+	{
+		"a=1 b=$x cmd \"x $y\" 'z'",
+		"(program (command (variable_assignment name: (variable_name) value: (number)) (variable_assignment name: (variable_name) value: (simple_expansion (variable_name))) name: (command_name (word)) argument: (string (string_content) (simple_expansion (variable_name))) argument: (raw_string)))",
+	},
+	{
+		"a && b || c",
+		"(program (list (list (command name: (command_name (word))) (command name: (command_name (word)))) (command name: (command_name (word)))))",
+	},
+	{
+		"! a | b | c",
+		"(program (pipeline (negated_command (command name: (command_name (word)))) (command name: (command_name (word))) (command name: (command_name (word)))))",
+	},
+	{
+		"foo=bar",
+		"(program (variable_assignment name: (variable_name) value: (word)))",
+	},
+	{
+		"x=(a b) y=",
+		"(program (variable_assignments (variable_assignment name: (variable_name) value: (array (word) (word))) (variable_assignment name: (variable_name))))",
+	},
+	{
+		"for i in a$b; do echo $@; done # c",
+		"(program (for_statement variable: (variable_name) value: (concatenation (word) (simple_expansion (variable_name))) body: (do_group (command name: (command_name (word)) argument: (simple_expansion (special_variable_name))))) (comment))",
+	},
+	{
+		"if [[ -n $x && $a -eq 2 ]]; then :; elif b; then c; else d; fi",
+		"(program (if_statement condition: (test_command (binary_expression left: (unary_expression (test_operator) (simple_expansion (variable_name))) right: (binary_expression left: (simple_expansion (variable_name)) operator: (test_operator) right: (number)))) (command name: (command_name (word))) (elif_clause condition: (command name: (command_name (word))) (command name: (command_name (word)))) (else_clause (command name: (command_name (word))))))",
+	},
+	{
+		"until a; do b; done",
+		"(program (while_statement condition: (command name: (command_name (word))) body: (do_group (command name: (command_name (word))))))",
+	},
+	{
+		"f() {\n\t# doc\n\tlocal -r a=1 b\n}",
+		"(program (function_definition name: (word) body: (compound_statement (comment) (declaration_command (word) (variable_assignment name: (variable_name) value: (number)) (variable_name)))))",
+	},
+	{
+		"case $x in a | b) c ;; *) d ;; esac",
+		"(program (case_statement value: (simple_expansion (variable_name)) (case_item value: (word) value: (word) (command name: (command_name (word)))) (case_item value: (word) (command name: (command_name (word))))))",
+	},
+	{
+		"echo ${x[1]} ${#y} ${z:-foo} $((a + 1)) $(ls) <(cat) $'a' $\"b\"",
+		"(program (command name: (command_name (word)) argument: (expansion (subscript name: (variable_name) index: (number))) argument: (expansion (variable_name)) argument: (expansion (variable_name) (word)) argument: (arithmetic_expansion (binary_expression left: (variable_name) right: (number))) argument: (command_substitution (command name: (command_name (word)))) argument: (process_substitution (command name: (command_name (word)))) argument: (ansi_c_string) argument: (translated_string (string (string_content)))))",
+	},
+	{
+		"cat <<EOF\nhi $x\nEOF",
+		"(program (redirected_statement body: (command name: (command_name (word))) redirect: (heredoc_redirect (heredoc_start) (heredoc_body (simple_expansion (variable_name))))))",
+	},
+	{
+		"while read l; do ((n++)); done <f 2>&1",
+		"(program (redirected_statement body: (while_statement condition: (command name: (command_name (word)) argument: (word)) body: (do_group (compound_statement (postfix_expression (variable_name))))) redirect: (file_redirect destination: (word)) redirect: (file_redirect descriptor: (file_descriptor) destination: (number))))",
+	},
+	{
+		"for ((i = 0; i < 3; i++)); do (a); done",
+		"(program (c_style_for_statement initializer: (binary_expression left: (variable_name) right: (number)) condition: (binary_expression left: (variable_name) right: (number)) update: (postfix_expression (variable_name)) body: (do_group (subshell (command name: (command_name (word)))))))",
+	},
+	{
+		"echo ${#:-a} ${#[1]}",
+		"(program (command name: (command_name (word)) argument: (expansion (special_variable_name) (word)) argument: (expansion (subscript name: (special_variable_name) index: (number)))))",
+	},
+	{
+		"cat <<<foo",
+		"(program (redirected_statement body: (command name: (command_name (word))) redirect: (herestring_redirect (word))))",
+	},
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+	for i, tc := range convertTests {
+		t.Run(fmt.Sprintf("%03d", i), func(t *testing.T) {
+			f, err := syntax.Parse([]byte(tc.in), "", syntax.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Convert(f).String()
+			if got != tc.want {
+				t.Fatalf("Convert mismatch in %q:\nwant: %s\ngot:  %s",
+					tc.in, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertRanges(t *testing.T) {
+	t.Parallel()
+	src := "cat <<EOF\nhi $x\nEOF\necho"
+	f, err := syntax.Parse([]byte(src), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type span struct {
+		typ        string
+		start, end int
+		from, to   Point
+	}
+	var got []span
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		got = append(got, span{n.Type, n.StartByte, n.EndByte, n.StartPoint, n.EndPoint})
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(Convert(f))
+	want := []span{
+		{"program", 0, 24, Point{0, 0}, Point{3, 4}},
+		{"redirected_statement", 0, 19, Point{0, 0}, Point{2, 3}},
+		{"command", 0, 3, Point{0, 0}, Point{0, 3}},
+		{"command_name", 0, 3, Point{0, 0}, Point{0, 3}},
+		{"word", 0, 3, Point{0, 0}, Point{0, 3}},
+		{"heredoc_redirect", 4, 19, Point{0, 4}, Point{2, 3}},
+		{"heredoc_start", 6, 9, Point{0, 6}, Point{0, 9}},
+		{"heredoc_body", 10, 19, Point{1, 0}, Point{2, 3}},
+		{"simple_expansion", 13, 15, Point{1, 3}, Point{1, 5}},
+		{"variable_name", 14, 15, Point{1, 4}, Point{1, 5}},
+		{"command", 20, 24, Point{3, 0}, Point{3, 4}},
+		{"command_name", 20, 24, Point{3, 0}, Point{3, 4}},
+		{"word", 20, 24, Point{3, 0}, Point{3, 4}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Convert ranges mismatch:\nwant: %v\ngot:  %v", want, got)
+	}
+	// files without a line table have the same ranges
+	if f, err = syntax.Parse([]byte(src), "", syntax.SkipLines); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	walk(Convert(f))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Convert ranges mismatch with SkipLines:\nwant: %v\ngot:  %v", want, got)
+	}
+}